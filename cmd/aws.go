@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	awsSession = sessionSpec{
+		Key:          "aws",
+		Name:         "AWS",
+		AgentType:    agent.AgentTypeAWS,
+		ExecuterType: executer.AWSExecuterType,
+	}
+
+	awsCmd = &cobra.Command{
+		Use:   "aws",
+		Short: "Interact with the AWS troubleshooting assistant",
+		Long: `Interact with the AWS troubleshooting assistant to investigate and resolve issues in
+AWS accounts using read-only AWS CLI commands.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSession(awsSession)
+		},
+	}
+)
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/eliran89c/klama/internal/ui"
+)
+
+// unsafeFileChars are replaced in the file names of the command caches.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// commandCachePath returns the command cache file of a kube context. The name keeps the
+// context readable and adds a hash, so contexts that differ only in special characters,
+// such as EKS ARNs, never share a cache.
+func commandCachePath(kubeContext string) (string, error) {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(kubeContext))
+	name := unsafeFileChars.ReplaceAllString(kubeContext, "_") + "-" + hex.EncodeToString(sum[:4]) + ".json"
+	return filepath.Join(stateDir, "cache", name), nil
+}
+
+// loadCommandCache adds the saved command outputs of the session's kube context to the
+// executer cache when the cache is persisted. It returns the file to save the cache to,
+// empty when it is not persisted.
+func loadCommandCache(cfg *config.Config, target ui.Target, exec sessionExecuter) (string, error) {
+	if !cfg.Cache.Persist || cfg.Cache.Disabled || target.Context == "" {
+		return "", nil
+	}
+
+	path, err := commandCachePath(target.Context)
+	if err != nil {
+		return "", err
+	}
+	entries, err := executer.LoadCacheFile(path)
+	if err != nil {
+		// a broken cache is only a missed speedup
+		log.Warn("Failed to load the command cache", "error", err)
+		return path, nil
+	}
+	exec.RestoreCacheEntries(entries)
+	return path, nil
+}
+
+// saveCommandCache saves the fresh command outputs of the session when the cache is
+// persisted, with their credentials redacted.
+func saveCommandCache(parts *sessionParts) {
+	if parts.cachePath == "" {
+		return
+	}
+	entries := parts.exec.CacheEntries()
+	for command, entry := range entries {
+		entry.Output, _ = parts.redactor.Redact(entry.Output)
+		entry.Stderr, _ = parts.redactor.Redact(entry.Stderr)
+		entries[command] = entry
+	}
+	if err := executer.SaveCacheFile(parts.cachePath, entries); err != nil {
+		log.Warn("Failed to save the command cache", "error", err)
+		fmt.Fprintf(os.Stderr, "[WARNING] Failed to save the command cache: %v\n", err)
+	}
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/eliran89c/klama/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const endpointCheckTimeout = 10 * time.Second
+
+var (
+	setTokenDelete bool
+	initForce      bool
+
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Manage the Klama configuration",
+	}
+
+	configInitCmd = &cobra.Command{
+		Use:          "init",
+		Short:        "Write a commented default config file",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := configFilePath()
+			if err != nil {
+				return err
+			}
+			if err := config.WriteDefault(path, initForce); err != nil {
+				return err
+			}
+			fmt.Println("Config file written to", path)
+			return nil
+		},
+	}
+
+	configViewCmd = &cobra.Command{
+		Use:          "view",
+		Short:        "Print the effective config with secrets masked",
+		Long:         "Print the effective config, including defaults and environment variables, with auth tokens masked.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			encoder := yaml.NewEncoder(os.Stdout)
+			encoder.SetIndent(2)
+			return encoder.Encode(cfg.Masked())
+		},
+	}
+
+	configSetCmd = &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set a value in the config file",
+		Long: `Set a value in the config file, keeping its comments. Keys are dotted paths such as
+agent.name or limits.max_cost_usd, and values are parsed as YAML.`,
+		Example: `  klama config set agent.name gpt-4o
+  klama config set limits.max_cost_usd 1.5
+  klama config set kubernetes.protected_contexts '["prod-*"]'`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := configFilePath()
+			if err != nil {
+				return err
+			}
+			if err := config.SetValue(path, args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("Set %s in %s\n", args[0], path)
+			return nil
+		},
+	}
+
+	configValidateCmd = &cobra.Command{
+		Use:          "validate",
+		Short:        "Check the config and the reachability of its model endpoints",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := configFilePath()
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf("no config file at %s, create one with klama config init", path)
+			}
+
+			cfg, err := config.Load(path)
+			if err != nil {
+				return fmt.Errorf("invalid config: %w", err)
+			}
+			fmt.Println("✓ Config file", path, "is valid")
+
+			models := map[string]config.ModelConfig{"agent": cfg.Agent}
+			names := []string{"agent"}
+			for name, model := range cfg.Models {
+				models["models."+name] = model
+				names = append(names, "models."+name)
+			}
+			sort.Strings(names[1:])
+
+			failed := 0
+			for _, name := range names {
+				if err := checkEndpoint(models[name]); err != nil {
+					fmt.Printf("✗ %s (%s): %v\n", name, models[name].Name, err)
+					failed++
+					continue
+				}
+				fmt.Printf("✓ %s (%s) is reachable at %s\n", name, models[name].Name, models[name].BaseURL)
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d model endpoint(s) failed the check", failed)
+			}
+			return nil
+		},
+	}
+
+	setTokenCmd = &cobra.Command{
+		Use:   "set-token",
+		Short: "Store the agent auth token in the OS keyring",
+		Long: `Store the agent auth token in the OS keyring (macOS Keychain, Secret Service on Linux,
+or Windows Credential Manager) instead of the plaintext config file. The token is read
+from the terminal without echo, or from stdin when it is piped.
+
+The token is used when neither the KLAMA_AGENT_TOKEN environment variable nor
+agent.auth_token in the config file is set. With --model, the token is stored for a
+model profile and used when the profile has no auth_token.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := config.AgentTokenKey
+			if modelProfile != "" {
+				key = config.ModelTokenKey(modelProfile)
+			}
+
+			if setTokenDelete {
+				if err := config.DeleteToken(key); err != nil && !errors.Is(err, config.ErrTokenNotFound) {
+					return err
+				}
+				fmt.Println("Token removed from the keyring.")
+				return nil
+			}
+
+			token, err := readToken()
+			if err != nil {
+				return err
+			}
+			if token == "" {
+				return fmt.Errorf("token is empty")
+			}
+
+			if err := config.SetToken(key, token); err != nil {
+				return err
+			}
+			fmt.Println("Token stored in the keyring. Remove auth_token from your config file so the keyring token is used.")
+			return nil
+		},
+	}
+)
+
+func init() {
+	configInitCmd.Flags().BoolVar(&initForce, "force", false, "Replace an existing config file")
+	setTokenCmd.Flags().BoolVar(&setTokenDelete, "delete", false, "Remove the token from the keyring")
+
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configViewCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(setTokenCmd)
+}
+
+// configFilePath returns the config file given with --config, or the default one.
+func configFilePath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	return config.Path()
+}
+
+// checkEndpoint checks that the model endpoint is reachable and accepts the auth token.
+func checkEndpoint(model config.ModelConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), endpointCheckTimeout)
+	defer cancel()
+	llmModel, err := newModel(model)
+	if err != nil {
+		return err
+	}
+	return llmModel.Ping(ctx)
+}
+
+// readToken reads the token from the terminal without echo, or the first line of stdin.
+func readToken() (string, error) {
+	if term.IsTerminal(os.Stdin.Fd()) {
+		fmt.Fprint(os.Stderr, "Agent auth token: ")
+		token, err := term.ReadPassword(os.Stdin.Fd())
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the token: %w", err)
+		}
+		return strings.TrimSpace(string(token)), nil
+	}
+
+	token, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && token == "" {
+		return "", fmt.Errorf("failed to read the token from stdin: %w", err)
+	}
+	return strings.TrimSpace(token), nil
+}
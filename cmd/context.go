@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/eliran89c/klama/internal/ui"
+)
+
+// tabContexts switches the kube context of a tab with /context. The kubeconfig is not
+// changed, the session pins its commands to the context instead.
+type tabContexts struct {
+	tab  *sessionTab
+	cfg  config.Config // configuration of the context the tab runs commands against
+	spec sessionSpec
+}
+
+// Contexts returns the contexts of the kubeconfig.
+func (c *tabContexts) Contexts() ([]string, error) {
+	return c.spec.Contexts(&c.cfg)
+}
+
+// SwitchContext builds the executer, target, environment and memory of the context and
+// points the tab at them. The command cache starts empty, or from the saved cache of the
+// context when it is persisted.
+func (c *tabContexts) SwitchContext(ctx context.Context, name string) (ui.SwitchedContext, error) {
+	cfg := c.cfg
+	cfg.Kubernetes.Context = name
+
+	var exec sessionExecuter = executer.NewTerminalExecuter(c.spec.ExecuterType)
+	if c.spec.NewExecuter != nil {
+		var err error
+		if exec, err = c.spec.NewExecuter(&cfg); err != nil {
+			return ui.SwitchedContext{}, fmt.Errorf("failed to initialize executer: %w", err)
+		}
+	}
+	exec.SetCacheOptions(executer.CacheOptions{TTL: cfg.Cache.TTL, Disabled: cfg.Cache.Disabled})
+
+	target := ui.Target{Context: name}
+	if c.spec.Target != nil {
+		target = c.spec.Target(&cfg)
+	}
+	sessionMemory, err := newSessionMemory(&cfg, c.spec, target)
+	if err != nil {
+		return ui.SwitchedContext{}, err
+	}
+	var facts []string
+	if sessionMemory != nil {
+		if facts, err = sessionMemory.Facts(); err != nil {
+			return ui.SwitchedContext{}, err
+		}
+	}
+
+	parts := c.tab.parts
+	saveCommandCache(parts)
+	cachePath, err := loadCommandCache(&cfg, target, exec)
+	if err != nil {
+		return ui.SwitchedContext{}, err
+	}
+
+	parts.agent.SetEnvironment(sessionEnvironment(ctx, &cfg, c.spec))
+	if sessionMemory != nil {
+		parts.agent.SetMemory(facts)
+	}
+	if parts.policy != nil {
+		parts.policy.Context = target.Context
+		parts.policy.Namespace = target.Namespace
+		if rewriter, ok := exec.(interface{ EffectiveCommand(string) string }); ok {
+			parts.policy.Rewrite = rewriter.EffectiveCommand
+		}
+	}
+	parts.exec, parts.target, parts.memory, parts.cachePath = exec, target, sessionMemory, cachePath
+	c.cfg = cfg
+
+	switched := ui.SwitchedContext{Executer: exec, Target: target}
+	if sessionMemory != nil {
+		switched.Memory = sessionMemory
+	}
+	return switched, nil
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/llm"
+	"github.com/muesli/termenv"
+	"github.com/spf13/cobra"
+)
+
+// minTerminalWidth is the narrowest terminal the chat renders well in.
+const minTerminalWidth = 80
+
+// checkStatus is the outcome of a doctor check.
+type checkStatus int
+
+const (
+	checkOK checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+// checkResult is the outcome of a doctor check, with a fix when it did not pass.
+type checkResult struct {
+	Name   string
+	Status checkStatus
+	Detail string
+	Fix    string
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment Klama runs in",
+	Long: `Check the config file, the model endpoint and its auth token, kubectl, the cluster
+connection and the terminal, and print how to fix any problem found.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, result := checkConfig()
+		results := []checkResult{result}
+		if cfg != nil {
+			results = append(results, checkModel(cfg))
+		} else {
+			// the kube checks only need the kubernetes section, which defaults to the current context
+			cfg = &config.Config{}
+		}
+		results = append(results, checkKubectl(), checkCluster(cfg), checkTerminal())
+
+		failed := 0
+		for _, result := range results {
+			printCheck(result)
+			if result.Status == checkFail {
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+		return nil
+	},
+}
+
+func printCheck(result checkResult) {
+	symbol := map[checkStatus]string{checkOK: "✓", checkWarn: "!", checkFail: "✗"}[result.Status]
+	fmt.Printf("%s %s: %s\n", symbol, result.Name, result.Detail)
+	if result.Status != checkOK && result.Fix != "" {
+		fmt.Printf("    fix: %s\n", result.Fix)
+	}
+}
+
+// checkConfig loads the config file without creating a default one. It returns a nil
+// config when the file is missing or invalid.
+func checkConfig() (*config.Config, checkResult) {
+	result := checkResult{Name: "Config"}
+
+	path, err := configFilePath()
+	if err != nil {
+		result.Status, result.Detail = checkFail, err.Error()
+		return nil, result
+	}
+	if _, err := os.Stat(path); err != nil {
+		result.Status = checkFail
+		result.Detail = fmt.Sprintf("no config file at %s", path)
+		result.Fix = "Run klama config init, then set agent.name and agent.base_url"
+		return nil, result
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		result.Status = checkFail
+		result.Detail = fmt.Sprintf("%s is invalid: %v", path, err)
+		result.Fix = fmt.Sprintf("Fix %s, klama config set changes a single value", path)
+		return nil, result
+	}
+	if err := cfg.SelectModel(modelProfile, ""); err != nil {
+		result.Status, result.Detail = checkFail, err.Error()
+		result.Fix = "Pass a profile defined in the models section of the config to --model"
+		return nil, result
+	}
+
+	result.Detail = path
+	return cfg, result
+}
+
+// checkModel checks that the agent model endpoint is reachable and accepts the token.
+func checkModel(cfg *config.Config) checkResult {
+	result := checkResult{Name: "Model endpoint"}
+
+	if err := checkEndpoint(cfg.Agent); err != nil {
+		result.Status = checkFail
+		result.Detail = fmt.Sprintf("%s at %s: %v", cfg.Agent.Name, cfg.Agent.BaseURL, err)
+		if errors.Is(err, llm.ErrUnauthorized) {
+			result.Fix = "Store a valid token with klama config set-token, or set KLAMA_AGENT_TOKEN"
+		} else {
+			result.Fix = "Check agent.base_url and agent.name, and that the endpoint is reachable from this network"
+		}
+		return result
+	}
+
+	result.Detail = fmt.Sprintf("%s at %s is reachable", cfg.Agent.Name, cfg.Agent.BaseURL)
+	return result
+}
+
+// checkKubectl checks that kubectl is installed. It is only a warning, the Kubernetes
+// assistant falls back to the API without it.
+func checkKubectl() checkResult {
+	result := checkResult{Name: "kubectl"}
+
+	path, err := exec.LookPath("kubectl")
+	if err != nil {
+		result.Status = checkWarn
+		result.Detail = "not found in PATH, the Kubernetes assistant queries the API directly"
+		result.Fix = "Install kubectl to run every kubectl command, see https://kubernetes.io/docs/tasks/tools/"
+		return result
+	}
+
+	result.Detail = path
+	return result
+}
+
+// checkCluster checks that the API server of the session context answers.
+func checkCluster(cfg *config.Config) checkResult {
+	result := checkResult{Name: "Cluster"}
+
+	client, err := newKubeClient(cfg)
+	if err != nil {
+		result.Status = checkWarn
+		result.Detail = err.Error()
+		result.Fix = "Only needed for klama k8s, point KUBECONFIG or --kubeconfig at a valid kubeconfig"
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), endpointCheckTimeout)
+	defer cancel()
+
+	contextName := client.Config().Context
+	data, err := client.Get(ctx, "/version", nil, "")
+	if err != nil {
+		result.Status = checkWarn
+		result.Detail = fmt.Sprintf("context %s is not reachable: %v", contextName, err)
+		result.Fix = "Check the VPN and the credentials of the context, or switch context with kubectl config use-context"
+		return result
+	}
+
+	var version struct {
+		GitVersion string `json:"gitVersion"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil || version.GitVersion == "" {
+		result.Detail = fmt.Sprintf("context %s is reachable", contextName)
+		return result
+	}
+
+	result.Detail = fmt.Sprintf("context %s is reachable, server version %s", contextName, version.GitVersion)
+	return result
+}
+
+// checkTerminal checks that the terminal can run the chat UI.
+func checkTerminal() checkResult {
+	result := checkResult{Name: "Terminal"}
+
+	if !term.IsTerminal(os.Stdout.Fd()) {
+		result.Status = checkWarn
+		result.Detail = "the output is not a terminal"
+		result.Fix = "Run Klama sessions in an interactive terminal"
+		return result
+	}
+
+	colors := map[termenv.Profile]string{
+		termenv.TrueColor: "true color",
+		termenv.ANSI256:   "256 colors",
+		termenv.ANSI:      "16 colors",
+		termenv.Ascii:     "no colors",
+	}[lipgloss.ColorProfile()]
+
+	width, _, err := term.GetSize(os.Stdout.Fd())
+	if err == nil && width < minTerminalWidth {
+		result.Status = checkWarn
+		result.Detail = fmt.Sprintf("%d columns wide, %s", width, colors)
+		result.Fix = fmt.Sprintf("Use a terminal at least %d columns wide", minTerminalWidth)
+		return result
+	}
+
+	if lipgloss.ColorProfile() == termenv.Ascii {
+		result.Status = checkWarn
+		result.Detail = fmt.Sprintf("%s, TERM=%q", colors, os.Getenv("TERM"))
+		result.Fix = "Set TERM to a color terminal such as xterm-256color, and unset NO_COLOR"
+		return result
+	}
+
+	result.Detail = colors
+	if err == nil {
+		result.Detail = fmt.Sprintf("%d columns wide, %s", width, colors)
+	}
+	return result
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ghaSession = sessionSpec{
+		Key:          "gha",
+		Name:         "GitHub Actions",
+		AgentType:    agent.AgentTypeGitHubActions,
+		ExecuterType: executer.CombineExecuterTypes(executer.GitHubActionsExecuterType, executer.GitExecuterType),
+		Environment:  gitEnvironment,
+	}
+
+	ghaCmd = &cobra.Command{
+		Use:     "gha",
+		Aliases: []string{"github-actions"},
+		Short:   "Interact with the GitHub Actions troubleshooting assistant",
+		Long: `Interact with the GitHub Actions troubleshooting assistant to analyze failing workflow runs
+and correlate them with recent commits, using read-only gh and git commands. The gh CLI must
+be installed and authenticated.`,
+		Example: `  klama gha
+  klama gha -p "why does the deploy workflow fail on main since yesterday?"`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSession(ghaSession)
+		},
+	}
+)
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gitSession = sessionSpec{
+		Key:          "git",
+		Name:         "Git",
+		AgentType:    agent.AgentTypeGit,
+		ExecuterType: executer.GitExecuterType,
+		Environment:  gitEnvironment,
+	}
+
+	gitCmd = &cobra.Command{
+		Use:   "git",
+		Short: "Interact with the Git forensics assistant",
+		Long: `Interact with the Git forensics assistant to find the changes that likely broke a deployment
+or caused a regression, using read-only git commands in the current repository.`,
+		Example: `  klama git
+  klama git -p "what change likely broke the deployment between Friday and today?"`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSession(gitSession)
+		},
+	}
+)
+
+// gitEnvironment tells the agent the current date, so it can resolve relative dates,
+// and which repository and branch the commands run in.
+func gitEnvironment(ctx context.Context, cfg *config.Config) string {
+	lines := []string{fmt.Sprintf("- Current date: %s", time.Now().Format("Monday, 2006-01-02 15:04 MST"))}
+
+	root, err := gitOutput(ctx, "rev-parse", "--show-toplevel")
+	if err != nil {
+		log.Debug("Skipping git repository details", "error", err)
+		return strings.Join(append(lines, "- The current directory is not in a git repository."), "\n")
+	}
+	lines = append(lines, fmt.Sprintf("- Repository: %s", root))
+
+	if branch, err := gitOutput(ctx, "branch", "--show-current"); err == nil && branch != "" {
+		lines = append(lines, fmt.Sprintf("- Current branch: %s", branch))
+	}
+	if head, err := gitOutput(ctx, "log", "-1", "--format=%h %ad %s", "--date=iso"); err == nil && head != "" {
+		lines = append(lines, fmt.Sprintf("- HEAD: %s", head))
+	}
+	if upstream, err := gitOutput(ctx, "rev-parse", "--abbrev-ref", "@{upstream}"); err == nil && upstream != "" {
+		lines = append(lines, fmt.Sprintf("- Upstream: %s", upstream))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func gitOutput(ctx context.Context, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", args...).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/headless"
+	"github.com/eliran89c/klama/internal/session"
+	"github.com/spf13/cobra"
+)
+
+// output formats of a headless run
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var (
+	headlessPrompt string
+	outputFormat   string
+)
+
+// addHeadlessFlags adds the flags that answer a single question without the chat UI.
+func addHeadlessFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&headlessPrompt, "prompt", "p", "", `Answer this question without the chat UI and exit, "-" reads it from stdin`)
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", outputText, "Output format of --prompt, text or json")
+}
+
+// beginSession answers the --prompt question, or starts a new interactive session.
+func beginSession(cfg *config.Config, spec sessionSpec) error {
+	if headlessPrompt == "" {
+		if outputFormat != outputText {
+			return fmt.Errorf("--output %s requires --prompt", outputFormat)
+		}
+		return startSession(cfg, spec, newOrRecoveredSession(cfg, spec))
+	}
+	return runHeadless(cfg, spec)
+}
+
+// runHeadless lets the agent answer the --prompt question, running the commands that
+// need no confirmation, and prints the answer in the --output format.
+func runHeadless(cfg *config.Config, spec sessionSpec) error {
+	if outputFormat != outputText && outputFormat != outputJSON {
+		return fmt.Errorf("unknown output format %q, use %s or %s", outputFormat, outputText, outputJSON)
+	}
+
+	question := headlessPrompt
+	if question == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read the prompt: %w", err)
+		}
+		question = strings.TrimSpace(string(data))
+	}
+	if question == "" {
+		return fmt.Errorf("the prompt is empty")
+	}
+
+	sess := session.New(spec.Key)
+	logSession(sess)
+	parts, err := newSessionParts(cfg, spec)
+	if err != nil {
+		return err
+	}
+
+	runner := headless.Runner{
+		Agent:           parts.agent,
+		Executer:        parts.exec,
+		MaxCommands:     cfg.AutoApprove.MaxCommands,
+		Redactor:        parts.redactor,
+		OutputProcessor: parts.outputProcessor,
+		Hints:           parts.hints,
+		ConfirmHighRisk: cfg.Policy.HighRiskKeyword != "",
+		Workers:         cfg.Plan.Parallelism,
+		AgentTimeout:    cfg.Timeouts.Agent,
+		ExecTimeout:     cfg.Timeouts.Exec,
+		DryRun:          dryRun,
+		Logger:          log,
+	}
+	if parts.policy != nil {
+		runner.Policy = parts.policy
+	}
+	if parts.memory != nil {
+		runner.Memory = parts.memory
+	}
+	if parts.target.Protected || parts.target.ProtectedFlags != nil {
+		runner.ProtectedContext = parts.target.ProtectedContext
+	}
+
+	startUsage := parts.model.Usage
+	result, runErr := runner.Run(context.Background(), question)
+	saveCommandCache(parts)
+
+	if err := recordUsage(sess, parts.model, startUsage); err != nil {
+		log.Warn("Failed to record usage", "error", err)
+		fmt.Fprintf(os.Stderr, "[WARNING] Failed to record usage: %v\n", err)
+	}
+
+	if outputFormat == outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	} else if result.Answer != "" {
+		fmt.Println(result.Answer)
+	}
+
+	return runErr
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	helmSession = sessionSpec{
+		Key:       "helm",
+		Name:      "Helm",
+		AgentType: agent.AgentTypeHelm,
+		Tools: []sessionTool{
+			{Name: "helm", Description: "read-only helm commands on releases, their values, manifests and history", ExecuterType: executer.HelmExecuterType},
+			{Name: "kubectl", Description: "read-only kubectl commands on the resources of a release", NewExecuterType: helmKubectlExecuterType},
+			{Name: "promtool", Description: "Prometheus queries and rule checks, for alerts and metrics of a release", ExecuterType: executer.PromtoolExecuterType},
+		},
+		Target: kubernetesTarget,
+	}
+
+	helmCmd = &cobra.Command{
+		Use:   "helm",
+		Short: "Interact with the Helm debugging assistant",
+		Long: `Interact with the Helm debugging assistant to troubleshoot failed releases, stuck upgrades,
+and values drift. The assistant can run helm, kubectl and promtool commands in the same session.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSession(helmSession)
+		},
+	}
+)
+
+// helmKubectlExecuterType runs the kubectl tool as the Kubernetes assistant runs kubectl,
+// with the denied resources rejected and the session scope applied.
+func helmKubectlExecuterType(cfg *config.Config) (executer.TerminalExecuterType, error) {
+	return kubectlExecuterType(cfg)
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eliran89c/klama/internal/session"
+	"github.com/spf13/cobra"
+)
+
+const historyTitleWidth = 50
+
+var (
+	historyJSON  bool
+	historyAgent string
+	historySince string
+	historyUntil string
+
+	historyCmd = &cobra.Command{
+		Use:   "history",
+		Short: "List saved sessions",
+		Long: `List the sessions saved in $XDG_STATE_HOME/klama/sessions with their first prompt,
+token cost, and outcome. Use the session ID with "klama resume" to continue a session.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter := session.Filter{Agent: historyAgent}
+
+			var err error
+			if filter.Since, err = parseTimeFlag(historySince, time.Now()); err != nil {
+				return fmt.Errorf("invalid --since value: %w", err)
+			}
+			if filter.Until, err = parseTimeFlag(historyUntil, time.Now()); err != nil {
+				return fmt.Errorf("invalid --until value: %w", err)
+			}
+
+			store, err := session.DefaultStore()
+			if err != nil {
+				return err
+			}
+
+			sessions, err := store.List(filter)
+			if err != nil {
+				return err
+			}
+
+			summaries := make([]session.Summary, 0, len(sessions))
+			for _, sess := range sessions {
+				summaries = append(summaries, sess.Summary())
+			}
+
+			if historyJSON {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(summaries)
+			}
+
+			if len(summaries) == 0 {
+				fmt.Println("No saved sessions found.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tCREATED\tAGENT\tPROMPT\tTOKENS\tCOST\tOUTCOME")
+			for _, s := range summaries {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%.4f$\t%s\n",
+					s.ID,
+					s.CreatedAt.Local().Format("2006-01-02 15:04"),
+					s.Agent,
+					truncate(s.Title, historyTitleWidth),
+					s.Usage.TotalTokens,
+					s.Cost,
+					s.Outcome,
+				)
+			}
+			return w.Flush()
+		},
+	}
+)
+
+func init() {
+	historyCmd.Flags().BoolVar(&historyJSON, "json", false, "Output the sessions as JSON")
+	historyCmd.Flags().StringVar(&historyAgent, "agent", "", "Only show sessions of the given agent (e.g. k8s, aws, helm, git)")
+	historyCmd.Flags().StringVar(&historySince, "since", "", "Only show sessions created after a date (2006-01-02), time (RFC3339), or duration ago (e.g. 24h)")
+	historyCmd.Flags().StringVar(&historyUntil, "until", "", "Only show sessions created before a date (2006-01-02), time (RFC3339), or duration ago (e.g. 24h)")
+}
+
+// parseTimeFlag parses a date, an RFC3339 timestamp, or a duration relative to now.
+// An empty value returns the zero time.
+func parseTimeFlag(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("expected a date (2006-01-02), RFC3339 time, or duration, got %q", value)
+}
+
+// truncate shortens a single line representation of s to at most width runes.
+func truncate(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -1,23 +1,35 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"os/exec"
+	"strings"
 
-	tea "github.com/charmbracelet/bubbletea"
 	"github.com/eliran89c/klama/config"
 	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/audit"
 	"github.com/eliran89c/klama/internal/executer"
-	"github.com/eliran89c/klama/internal/llm"
-	"github.com/eliran89c/klama/internal/logger"
+	"github.com/eliran89c/klama/internal/kube"
 	"github.com/eliran89c/klama/internal/ui"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"k8s.io/klog/v2"
 )
 
 var (
+	k8sSession = sessionSpec{
+		Key:          "k8s",
+		Name:         "Kubernetes",
+		AgentType:    agent.AgentTypeKubernetes,
+		ExecuterType: executer.KubernetesExecuterType,
+		NewExecuter:  newKubernetesExecuter,
+		Environment:  kubernetesEnvironment,
+		Hints:        lokiHints,
+		Target:       kubernetesTarget,
+		Contexts:     kubeContexts,
+	}
+
 	k8sCmd = &cobra.Command{
 		Use:   "k8s",
 		Short: "Interact with the Kubernetes debugging assistant",
@@ -26,52 +38,344 @@ Kubernetes clusters.`,
 		Args:         cobra.NoArgs,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			debug := viper.GetBool("debug")
+			return runSession(k8sSession)
+		},
+	}
+)
+
+func init() {
+	k8sCmd.Flags().Bool("api", false, "Query the Kubernetes API directly instead of running kubectl")
+	viper.BindPFlag("kubernetes.use_api", k8sCmd.Flags().Lookup("api"))
+
+	k8sCmd.Flags().String("kubeconfig", "", "Path to the kubeconfig file to use for every command")
+	viper.BindPFlag("kubernetes.kubeconfig", k8sCmd.Flags().Lookup("kubeconfig"))
+	k8sCmd.Flags().String("context", "", "Kube context to use for every command")
+	viper.BindPFlag("kubernetes.context", k8sCmd.Flags().Lookup("context"))
+	k8sCmd.Flags().StringP("namespace", "n", "", "Namespace to use for every command")
+	viper.BindPFlag("kubernetes.namespace", k8sCmd.Flags().Lookup("namespace"))
+
+	k8sCmd.Flags().Bool("allow-mutations", false, "Let the assistant suggest the allowed kubectl write operations, each confirmed by typing the resource name")
+	viper.BindPFlag("kubernetes.mutations.enabled", k8sCmd.Flags().Lookup("allow-mutations"))
+	k8sCmd.Flags().Bool("allow-interactive", false, "Let the assistant suggest kubectl exec -it and port-forward, which take over the terminal once approved")
+	viper.BindPFlag("kubernetes.interactive", k8sCmd.Flags().Lookup("allow-interactive"))
+	k8sCmd.Flags().Bool("allow-node-debug", false, "Let the assistant suggest kubectl debug node/<name> -it, confirmed by typing the node name")
+	viper.BindPFlag("kubernetes.node_debug", k8sCmd.Flags().Lookup("allow-node-debug"))
+}
+
+// kubectlScope returns the kubeconfig, context and namespace the session is pinned to.
+func kubectlScope(cfg *config.Config) executer.KubectlScope {
+	return executer.KubectlScope{
+		Kubeconfig: cfg.Kubernetes.Kubeconfig,
+		Context:    cfg.Kubernetes.Context,
+		Namespace:  cfg.Kubernetes.Namespace,
+	}
+}
 
-			if debug {
-				//TODO: get debugger file location from user
-				file, err := os.Create("klama.debug")
-				if err != nil {
-					return fmt.Errorf("failed to create debug file: %w", err)
+// newKubernetesExecuter runs the commands of the Kubernetes assistant, see
+// kubernetesExecuter, and answers events commands with the condensed events of a
+// namespace or an object.
+func newKubernetesExecuter(cfg *config.Config) (sessionExecuter, error) {
+	kubeExec, err := kubernetesExecuter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return executer.NewEventsExecuter(kubeExec), nil
+}
+
+// kubernetesExecuter runs commands with kubectl, or through the Kubernetes API when
+// configured to or when kubectl is not installed, and answers logcli commands from Loki
+// when it is configured. Fix mode, interactive commands and node debugging need kubectl.
+func kubernetesExecuter(cfg *config.Config) (executer.CachingExecuter, error) {
+	var others []executer.TerminalExecuterType
+	if cfg.Loki.URL != "" {
+		others = append(others, executer.LokiExecuterType)
+	}
+	executerType, err := kubectlExecuterType(cfg, others...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.Kubernetes.UseAPI {
+		if _, err := exec.LookPath("kubectl"); err == nil {
+			kubectlExec, err := withMutations(cfg, executerType, withKubectlTools(cfg, executer.NewTerminalExecuter(executerType)))
+			if err != nil || !cfg.Kubernetes.Interactive && !cfg.Kubernetes.NodeDebug {
+				return kubectlExec, err
+			}
+			commands := executer.InteractiveCommands{Terminal: cfg.Kubernetes.Interactive, NodeDebug: cfg.Kubernetes.NodeDebug}
+			if cfg.Kubernetes.NodeDebug {
+				if commands.Audit, err = newAuditLog(cfg); err != nil {
+					return nil, err
 				}
-				logger.Init(file)
-				defer file.Close()
-			} else {
-				logger.Init(io.Discard)
 			}
+			return executer.NewKubectlInteractiveExecuter(kubectlExec, commands, executerType.RewriteCommand), nil
+		}
+		log.Info("kubectl not found, using the Kubernetes API")
+	}
+	if cfg.Kubernetes.Mutations.Enabled {
+		return nil, fmt.Errorf("--allow-mutations runs kubectl, which is not available with the Kubernetes API")
+	}
+	if cfg.Kubernetes.Interactive {
+		return nil, fmt.Errorf("--allow-interactive runs kubectl, which is not available with the Kubernetes API")
+	}
+	if cfg.Kubernetes.NodeDebug {
+		return nil, fmt.Errorf("--allow-node-debug runs kubectl, which is not available with the Kubernetes API")
+	}
 
-			cfg, err := config.Load(cfgFile)
-			if err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
-			}
+	client, err := newKubeClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return withLoki(cfg, withKubectlTools(cfg, executer.NewK8sAPIExecuter(client, executerType)))
+}
 
-			client := &http.Client{}
+// kubectlExecuterType returns the kubectl executer type, combined with the others, that
+// rejects the denied resources of the configuration and runs kubectl in the session scope.
+func kubectlExecuterType(cfg *config.Config, others ...executer.TerminalExecuterType) (executer.TerminalExecuterType, error) {
+	if err := executer.ValidateResourcePatterns(cfg.Kubernetes.DeniedResources); err != nil {
+		return executer.TerminalExecuterType{}, fmt.Errorf("kubernetes.denied_resources: %w", err)
+	}
+	executerType := executer.DenyKubernetesResources(executer.KubernetesExecuterType, cfg.Kubernetes.DeniedResources)
+	if len(others) > 0 {
+		executerType = executer.CombineExecuterTypes(append([]executer.TerminalExecuterType{executerType}, others...)...)
+	}
+	if scope := kubectlScope(cfg); !scope.IsZero() {
+		executerType.RewriteCommand = scope.Apply
+	}
+	return executerType, nil
+}
 
-			llmModel := llm.NewModel(client, cfg.Agent)
+// withKubectlTools adds the built-in tools that run kubectl commands to the executer:
+// diff commands compare the outputs of two commands, podlogs commands read the logs of
+// all the pods a selector matches, and when helm is installed, helmrelease commands read
+// the values and history of a release.
+func withKubectlTools(cfg *config.Config, exec executer.CachingExecuter) executer.CachingExecuter {
+	if helmInstalled() {
+		exec = executer.NewHelmReleaseExecuter(exec, executer.NewTerminalExecuter(executer.HelmExecuterType), kubectlScope(cfg))
+	}
+	return executer.NewDiffExecuter(executer.NewPodLogsExecuter(exec))
+}
 
-			k8sAgent, err := agent.New(llmModel, agent.AgentTypeKubernetes)
-			if err != nil {
-				return fmt.Errorf("failed to initialize agent: %w", err)
-			}
+// helmInstalled reports whether the helm binary is available for helmrelease commands.
+func helmInstalled() bool {
+	_, err := exec.LookPath("helm")
+	return err == nil
+}
 
-			exec := executer.NewTerminalExecuter(executer.KubernetesExecuterType)
+// withMutations wraps the kubectl executer to also run the allowed mutations of fix
+// mode, when it is enabled. Mutations are recorded in the audit log.
+func withMutations(cfg *config.Config, executerType executer.TerminalExecuterType, exec executer.CachingExecuter) (executer.CachingExecuter, error) {
+	mutations := cfg.Kubernetes.Mutations
+	if !mutations.Enabled {
+		return withLoki(cfg, exec)
+	}
 
-			uiConfig := ui.Config{
-				Agent:    k8sAgent,
-				Executer: exec,
-			}
+	auditLog, err := newAuditLog(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	mutationExec := executer.NewKubectlMutationExecuter(exec, mutations.AllowedCommands, cfg.Kubernetes.DeniedResources, executerType.RewriteCommand, auditLog)
+	return withLoki(cfg, mutationExec)
+}
 
-			p := tea.NewProgram(
-				ui.InitialModel(uiConfig),
-				tea.WithAltScreen(),
-				tea.WithMouseCellMotion(),
-			)
+// newAuditLog returns the log that records the mutations of fix mode and node debugging.
+func newAuditLog(cfg *config.Config) (*audit.Log, error) {
+	auditPath := cfg.Kubernetes.Mutations.AuditLog
+	if auditPath == "" {
+		path, err := audit.DefaultPath()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate the audit log: %w", err)
+		}
+		auditPath = path
+	}
+	return &audit.Log{Path: auditPath, Agent: "k8s", Context: kubernetesTarget(cfg).Context}, nil
+}
+
+// kubernetesEnvironment summarizes the current cluster for the agent, so it can skip
+// basic discovery commands, and tells it which context and namespace the session is
+// pinned to.
+func kubernetesEnvironment(ctx context.Context, cfg *config.Config) string {
+	sections := []string{diffNote, eventsNote, podLogsNote}
+	if helmInstalled() {
+		sections = append(sections, helmReleaseNote)
+	}
+	if pinned := pinnedScopeNote(kubectlScope(cfg)); pinned != "" {
+		sections = append(sections, pinned)
+	}
+	if cfg.Kubernetes.Mutations.Enabled {
+		sections = append(sections, mutationsNote(cfg.Kubernetes.Mutations.AllowedCommands))
+	}
+	if cfg.Kubernetes.Interactive {
+		sections = append(sections, interactiveNote)
+	}
+	if cfg.Kubernetes.NodeDebug {
+		sections = append(sections, nodeDebugNote)
+	}
 
-			if _, err := p.Run(); err != nil {
-				return fmt.Errorf("error running program: %w", err)
+	if cfg.Loki.URL != "" {
+		sections = append(sections, lokiEnvironment(cfg))
+	}
+
+	if !cfg.Kubernetes.DisableClusterSummary {
+		if summary, err := clusterSummary(ctx, cfg); err != nil {
+			log.Debug("Skipping cluster summary", "error", err)
+		} else {
+			sections = append(sections, summary)
+		}
+	}
+
+	return strings.Join(sections, "\n")
+}
+
+func clusterSummary(ctx context.Context, cfg *config.Config) (string, error) {
+	client, err := newKubeClient(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	summary, err := client.Summarize(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return summary.String(), nil
+}
+
+// pinnedScopeNote tells the agent which scope flags are added to its commands.
+func pinnedScopeNote(scope executer.KubectlScope) string {
+	var pinned []string
+	if scope.Kubeconfig != "" {
+		pinned = append(pinned, fmt.Sprintf("kubeconfig %s", scope.Kubeconfig))
+	}
+	if scope.Context != "" {
+		pinned = append(pinned, fmt.Sprintf("context %s", scope.Context))
+	}
+	if scope.Namespace != "" {
+		pinned = append(pinned, fmt.Sprintf("namespace %s", scope.Namespace))
+	}
+	if len(pinned) == 0 {
+		return ""
+	}
+
+	note := fmt.Sprintf("- This session is pinned to %s. The matching flags are added to every kubectl command, so do not pass them yourself.", strings.Join(pinned, ", "))
+	if scope.Namespace != "" {
+		note += " Commands cannot use --all-namespaces."
+	}
+	return note
+}
+
+// mutationsNote tells the agent which write operations it may suggest in fix mode.
+func mutationsNote(allowed []string) string {
+	return fmt.Sprintf("- Fix mode is enabled: once the cause is diagnosed, you may suggest these write operations: %s. "+
+		"Suggest one at a time, each on a single resource named as kind/name, without selectors, --all or piping. "+
+		"The user confirms each one by typing the resource name.", strings.Join(allowed, ", "))
+}
+
+// diffNote tells the agent how to compare two resources.
+const diffNote = "- To compare two resources, such as the pod template of a deployment and a running pod, or a resource " +
+	"in two namespaces, suggest `diff '<command>' '<command>'` with two read-only kubectl commands, such as " +
+	"`diff 'kubectl get deploy web -o yaml' 'kubectl get pod web-0 -o yaml'`. It answers with a unified diff of their " +
+	"outputs, prefer it over reading both documents."
+
+// eventsNote tells the agent how to collect the events of a namespace or an object.
+const eventsNote = "- To see what happened to a namespace or an object, suggest `events -n <namespace>` or " +
+	"`events -n <namespace> <kind>/<name>`, such as `events -n shop pod/web-0`, instead of kubectl get events. " +
+	"It answers with the recent events sorted by time, repeated events merged, and runs without confirmation in autopilot."
+
+// podLogsNote tells the agent how to read the logs of all the replicas of a workload.
+const podLogsNote = "- To read the logs of all the pods of a workload, suggest `podlogs -n <namespace> -l <selector>` " +
+	"instead of kubectl logs of a single pod, such as `podlogs -n shop -l app=web --since 5m --for 10s`. " +
+	"It follows the logs of every matching pod for the --for duration, at most 20s, and answers with their recent lines " +
+	"prefixed with the pod and merged by time, to find problems that only appear on some replicas."
+
+// helmReleaseNote tells the agent how to read the values and history of a Helm release.
+const helmReleaseNote = "- When a workload is managed by Helm, as its app.kubernetes.io/managed-by label or meta.helm.sh annotations show, " +
+	"suggest `helmrelease -n <namespace> <release>` to read the values and the recent history of its release, " +
+	"and check them for chart-level misconfiguration, such as wrong resources, images or probes, or a failed upgrade."
+
+// interactiveNote tells the agent it may suggest commands that take over the terminal.
+const interactiveNote = "- Interactive commands are enabled: when a live look is needed, you may suggest `kubectl exec -it <pod> -- <command>` " +
+	"or `kubectl port-forward <target> <ports>` on localhost. They run in the user's terminal and their output is not captured, " +
+	"the user describes what they found when the command exits. Prefer read-only commands whenever they answer the question."
+
+// nodeDebugNote tells the agent it may suggest debugging a node.
+const nodeDebugNote = "- Node debugging is enabled: for problems of a node itself, such as disk or memory pressure or an unhealthy kubelet, " +
+	"you may suggest `kubectl debug node/<name> -it --image=busybox`, which starts a privileged pod on the node with its filesystem at /host. " +
+	"Tell the user what to look at, such as `df -h /host` or `chroot /host journalctl -u kubelet`, as the output is not captured. " +
+	"The user approves it by typing the node name, and the node-debugger pod it leaves behind should be deleted afterwards. " +
+	"Only suggest it when read-only commands, such as kubectl describe node, do not explain the problem."
+
+// kubernetesTarget describes the kube context commands run against. With protected
+// contexts configured, a context that cannot be loaded is protected, and so is every
+// command that names a protected context or cluster with its flags.
+func kubernetesTarget(cfg *config.Config) ui.Target {
+	patterns := cfg.Kubernetes.ProtectedContexts
+	var target ui.Target
+	if len(patterns) > 0 {
+		scope := kubectlScope(cfg)
+		target.ProtectedFlags = func(command string) string {
+			for _, name := range scope.NamedContexts(command) {
+				if kube.MatchContext(patterns, name) {
+					return name
+				}
 			}
+			return ""
+		}
+	}
 
-			return nil
-		},
+	restConfig, err := loadRestConfig(cfg)
+	if err != nil {
+		log.Warn("Failed to load the current kube context", "error", err)
+		if len(patterns) > 0 {
+			target.Protected = true
+		}
+		return target
 	}
-)
+
+	target.Context = restConfig.Context
+	if target.Context == "" {
+		target.Context = "in-cluster"
+	}
+	target.Namespace = restConfig.Namespace
+	target.Protected = kube.MatchContext(patterns, target.Context)
+	return target
+}
+
+// kubeContexts lists the contexts of the kubeconfig for /context.
+func kubeContexts(cfg *config.Config) ([]string, error) {
+	kubeconfig, err := kube.LoadKubeconfig(kube.KubeconfigPaths(cfg.Kubernetes.Kubeconfig))
+	if err != nil {
+		return nil, err
+	}
+	return kubeconfig.ContextNames(), nil
+}
+
+func newKubeClient(cfg *config.Config) (*kube.Client, error) {
+	restConfig, err := loadRestConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Kubernetes config: %w", err)
+	}
+
+	client, err := kube.NewClient(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	client.Logger = log
+	// client-go logs to stderr, which would garble the chat
+	klog.SetSlogLogger(log)
+	return client, nil
+}
+
+// loadRestConfig loads the REST config of the context the session is pinned to.
+func loadRestConfig(cfg *config.Config) (*kube.RestConfig, error) {
+	restConfig, err := kube.LoadRestConfig(cfg.Kubernetes.Kubeconfig, cfg.Kubernetes.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Kubernetes.Namespace != "" {
+		restConfig.Namespace = cfg.Kubernetes.Namespace
+	}
+	return restConfig, nil
+}
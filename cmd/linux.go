@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	linuxSession = sessionSpec{
+		Key:          "linux",
+		Name:         "Linux",
+		AgentType:    agent.AgentTypeLinux,
+		ExecuterType: executer.LinuxExecuterType,
+	}
+
+	linuxCmd = &cobra.Command{
+		Use:   "linux",
+		Short: "Interact with the Linux sysadmin assistant",
+		Long: `Interact with the Linux sysadmin assistant to troubleshoot host-level issues, such as failed
+services, full disks and memory pressure, using read-only host commands.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSession(linuxSession)
+		},
+	}
+)
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/eliran89c/klama/internal/loki"
+)
+
+// lokiLabelHints bounds the label values hinted with a message.
+const lokiLabelHints = 30
+
+// withLoki wraps the executer to answer logcli commands from the configured Loki server.
+func withLoki(cfg *config.Config, exec executer.CachingExecuter) (executer.CachingExecuter, error) {
+	if cfg.Loki.URL == "" {
+		return exec, nil
+	}
+
+	client, err := newLokiClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return executer.NewLokiExecuter(exec, client, executer.LokiLimits{
+		DefaultRange: cfg.Loki.DefaultRange,
+		MaxRange:     cfg.Loki.MaxRange,
+		MaxLines:     cfg.Loki.MaxLines,
+	}), nil
+}
+
+func newLokiClient(cfg *config.Config) (*loki.Client, error) {
+	return loki.NewClient(cfg.Loki.URL, cfg.Loki.BearerToken, cfg.Loki.TenantID, &http.Client{})
+}
+
+// lokiEnvironment tells the agent how to query Loki.
+func lokiEnvironment(cfg *config.Config) string {
+	return fmt.Sprintf("- Logs are stored in Loki, also for pods that were deleted or restarted. Query them with "+
+		"`logcli query '<LogQL>' --since=1h --limit=100`, list the label names with `logcli labels` and the values of a label "+
+		"with `logcli labels <name>`. Queries cover at most %s. Build the stream selector from the namespaces, pods and apps "+
+		"the user mentioned, whose Loki labels come with the user's messages when they are found, and prefer Loki over "+
+		"kubectl logs --previous for past containers.", cfg.Loki.MaxRange)
+}
+
+// lokiHints lists the Loki labels of the namespaces, apps and pods a message mentions,
+// so the agent can select their streams without listing the label values first.
+func lokiHints(ctx context.Context, cfg *config.Config, message string) string {
+	if cfg.Loki.URL == "" {
+		return ""
+	}
+
+	client, err := newLokiClient(cfg)
+	if err != nil {
+		log.Debug("Skipping Loki hints", "error", err)
+		return ""
+	}
+
+	end := time.Now()
+	hints, err := client.Hints(ctx, message, end.Add(-cfg.Loki.DefaultRange), end, lokiLabelHints)
+	if err != nil {
+		log.Debug("Skipping Loki hints", "error", err)
+		return ""
+	}
+	if len(hints) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Loki labels of what the user mentioned: %s.\n", strings.Join(hints, ", "))
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/memory"
+	"github.com/eliran89c/klama/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	memoryCmd = &cobra.Command{
+		Use:   "memory",
+		Short: "List the facts remembered about each kube context",
+		Long: `List the durable facts the agent remembered about each kube context, grouped by
+assistant and context. Enable memory with "memory.enabled: true" in the config file.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := memoryStore()
+			if err != nil {
+				return err
+			}
+
+			facts, err := store.Facts()
+			if err != nil {
+				return err
+			}
+			if len(facts) == 0 {
+				fmt.Println("No facts are remembered.")
+				return nil
+			}
+
+			scopes := make([]string, 0, len(facts))
+			for scope := range facts {
+				scopes = append(scopes, scope)
+			}
+			slices.Sort(scopes)
+
+			for _, scope := range scopes {
+				fmt.Printf("%s:\n", scope)
+				for _, fact := range facts[scope] {
+					fmt.Printf("  - %s (%s)\n", fact.Text, fact.Learned.Local().Format("2006-01-02"))
+				}
+			}
+			return nil
+		},
+	}
+
+	memoryForgetAll bool
+
+	memoryForgetCmd = &cobra.Command{
+		Use:   "forget [scope]",
+		Short: "Forget the facts remembered about a kube context",
+		Long: `Forget the facts remembered for a scope, such as k8s/prod-eu, as listed by
+"klama memory". Use --all to forget every fact.`,
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && !memoryForgetAll {
+				return fmt.Errorf("name the scope to forget, or pass --all")
+			}
+
+			store, err := memoryStore()
+			if err != nil {
+				return err
+			}
+
+			scope := ""
+			if len(args) == 1 {
+				scope = args[0]
+			}
+			if err := store.Forget(scope); err != nil {
+				return err
+			}
+
+			if scope == "" {
+				fmt.Println("Forgot every remembered fact.")
+			} else {
+				fmt.Printf("Forgot the facts remembered for %s.\n", scope)
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	memoryForgetCmd.Flags().BoolVar(&memoryForgetAll, "all", false, "Forget every remembered fact")
+	memoryCmd.AddCommand(memoryForgetCmd)
+}
+
+// memoryStore loads the configuration and returns its memory store.
+func memoryStore() (*memory.Store, error) {
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return newMemoryStore(cfg)
+}
+
+// newMemoryStore returns the memory store at the configured path.
+func newMemoryStore(cfg *config.Config) (*memory.Store, error) {
+	path := cfg.Memory.Path
+	if path == "" {
+		var err error
+		if path, err = memory.DefaultPath(); err != nil {
+			return nil, fmt.Errorf("failed to locate the memory: %w", err)
+		}
+	}
+	return &memory.Store{Path: path, MaxFacts: cfg.Memory.MaxFacts}, nil
+}
+
+// newSessionMemory returns the memory of the session's target context, or nil when memory
+// is disabled or the session has no target.
+func newSessionMemory(cfg *config.Config, spec sessionSpec, target ui.Target) (*memory.Scope, error) {
+	if !cfg.Memory.Enabled || target.Context == "" {
+		return nil, nil
+	}
+
+	store, err := newMemoryStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return store.Scope(memory.ScopeName(spec.Key, target.Context)), nil
+}
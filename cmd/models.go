@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/llm"
+	"github.com/spf13/cobra"
+)
+
+// listedModel is a model of the endpoint, as printed by klama models --json.
+type listedModel struct {
+	llm.ModelInfo
+	Configured bool `json:"configured,omitempty"`
+}
+
+var (
+	modelsJSON bool
+
+	modelsCmd = &cobra.Command{
+		Use:   "models",
+		Short: "List the models of the configured endpoint",
+		Long: `List the models served by the configured endpoint with its /models API, with their
+context size when the endpoint reports it. The configured model is marked with *, use the
+IDs as the name of a model in the config. Select a model profile with --model.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if err := cfg.SelectModel(modelProfile, ""); err != nil {
+				return err
+			}
+			if cfg.Agent.Provider == config.ProviderMock {
+				return fmt.Errorf("the mock provider has no endpoint to list models from")
+			}
+
+			client, err := llm.NewHTTPClient(cfg.Agent)
+			if err != nil {
+				return fmt.Errorf("failed to configure the model client: %w", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), endpointCheckTimeout)
+			defer cancel()
+			infos, err := llm.NewModel(client, cfg.Agent).ListModels(ctx)
+			if err != nil {
+				return fmt.Errorf("%s: %w", cfg.Agent.BaseURL, err)
+			}
+
+			models := make([]listedModel, len(infos))
+			found := false
+			for i, info := range infos {
+				models[i] = listedModel{ModelInfo: info, Configured: info.ID == cfg.Agent.Name}
+				found = found || models[i].Configured
+			}
+
+			if modelsJSON {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(models)
+			}
+			return printModels(models, cfg.Agent, found)
+		},
+	}
+)
+
+func init() {
+	modelsCmd.Flags().BoolVar(&modelsJSON, "json", false, "Output the models as JSON")
+}
+
+// printModels prints the models as a table, and a note when the configured model is
+// not among them.
+func printModels(models []listedModel, modelConfig config.ModelConfig, found bool) error {
+	if len(models) == 0 {
+		fmt.Printf("%s lists no models.\n", modelConfig.BaseURL)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  MODEL\tCONTEXT")
+	for _, model := range models {
+		marker := " "
+		if model.Configured {
+			marker = "*"
+		}
+		contextSize := "-"
+		if model.ContextWindow > 0 {
+			contextSize = strconv.Itoa(model.ContextWindow)
+		}
+		fmt.Fprintf(w, "%s %s\t%s\n", marker, model.ID, contextSize)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !found && modelConfig.Name != "" {
+		fmt.Fprintf(os.Stderr, "\nThe configured model %q is not in the list, set one of the IDs above as its name.\n", modelConfig.Name)
+	}
+	return nil
+}
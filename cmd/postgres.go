@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	postgresSession = sessionSpec{
+		Key:          "postgres",
+		Name:         "PostgreSQL",
+		AgentType:    agent.AgentTypePostgres,
+		ExecuterType: executer.PostgresExecuterType,
+		NewExecuter:  newPostgresExecuter,
+		Environment:  postgresEnvironment,
+	}
+
+	postgresCmd = &cobra.Command{
+		Use:   "postgres",
+		Short: "Interact with the PostgreSQL troubleshooting assistant",
+		Long: `Interact with the PostgreSQL troubleshooting assistant to diagnose locks, bloat and slow
+queries with read-only psql queries against the database in postgres.dsn. Every query runs in a
+read-only transaction with a statement timeout.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSession(postgresSession)
+		},
+	}
+)
+
+func init() {
+	postgresCmd.Flags().String("dsn", "", "Connection string or URI of the database, overrides postgres.dsn")
+	viper.BindPFlag("postgres.dsn", postgresCmd.Flags().Lookup("dsn"))
+}
+
+// psqlConnection returns the connection of the session's psql commands.
+func psqlConnection(cfg *config.Config) (executer.PsqlConnection, error) {
+	if cfg.Postgres.DSN == "" {
+		return executer.PsqlConnection{}, fmt.Errorf("set postgres.dsn in the config file, KLAMA_POSTGRES_DSN or --dsn to the database to troubleshoot")
+	}
+
+	dsn, password := cfg.Postgres.ConnInfo()
+	return executer.PsqlConnection{
+		DSN:              dsn,
+		Password:         password,
+		StatementTimeout: cfg.Postgres.StatementTimeout,
+	}, nil
+}
+
+// newPostgresExecuter runs psql commands against the configured database. The password
+// is passed in the environment, so it does not show up on the command line.
+func newPostgresExecuter(cfg *config.Config) (sessionExecuter, error) {
+	conn, err := psqlConnection(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	executerType := executer.PostgresExecuterType
+	executerType.RewriteCommand = conn.Apply
+	executerType.Env = conn.Env()
+	return executer.NewTerminalExecuter(executerType), nil
+}
+
+// postgresEnvironment tells the agent which server, database and user it is connected
+// to, and whether pg_stat_statements is installed.
+func postgresEnvironment(ctx context.Context, cfg *config.Config) string {
+	conn, err := psqlConnection(cfg)
+	if err != nil {
+		return ""
+	}
+
+	query := "SELECT current_database(), current_user, current_setting('server_version'), " +
+		"EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_stat_statements')"
+	c := exec.CommandContext(ctx, "psql", "--no-psqlrc", "--dbname="+conn.DSN, "-At", "-F", "\t", "-c", query)
+	c.Env = append(os.Environ(), conn.Env()...)
+	out, err := c.Output()
+	if err != nil {
+		log.Debug("Skipping database details", "error", err)
+		return ""
+	}
+
+	fields := strings.Split(strings.TrimSpace(string(out)), "\t")
+	if len(fields) != 4 {
+		return ""
+	}
+
+	lines := []string{
+		fmt.Sprintf("- Connected to database %s as %s, PostgreSQL %s.", fields[0], fields[1], fields[2]),
+	}
+	if fields[3] == "t" {
+		lines = append(lines, "- pg_stat_statements is installed.")
+	} else {
+		lines = append(lines, "- pg_stat_statements is not installed, find slow queries in pg_stat_activity instead.")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/llm"
+)
+
+var (
+	recordDir string
+	replayDir string
+
+	// the recorder and replayer are shared by every model of the process, so the
+	// exchanges of the agent and the summarizer keep their order
+	modelTransportOnce sync.Once
+	recorder           *llm.Recorder
+	replayer           *llm.Replayer
+	modelTransportErr  error
+
+	// models warned about their unknown pricing, so every tab does not repeat it
+	pricingWarned sync.Map
+)
+
+// newModel returns the model with its HTTP client. With --record its exchanges are
+// saved, and with --replay they are answered from a recording without network calls.
+// The mock provider answers from its scenario, also without network calls.
+func newModel(modelConfig config.ModelConfig) (*llm.Model, error) {
+	modelTransportOnce.Do(func() {
+		switch {
+		case recordDir != "" && replayDir != "":
+			modelTransportErr = fmt.Errorf("--record and --replay cannot be used together")
+		case recordDir != "":
+			recorder, modelTransportErr = llm.NewRecorder(recordDir)
+		case replayDir != "":
+			replayer, modelTransportErr = llm.NewReplayer(replayDir)
+		}
+	})
+	if modelTransportErr != nil {
+		return nil, modelTransportErr
+	}
+
+	if replayer != nil {
+		model := llm.NewModel(&http.Client{Transport: replayer}, modelConfig)
+		// replayed requests need no credentials
+		model.TokenSource = nil
+		model.Logger = log
+		warnUnknownPricing(model)
+		return model, nil
+	}
+
+	client, err := llm.NewHTTPClient(modelConfig)
+	if err != nil {
+		return nil, err
+	}
+	if modelConfig.Provider == config.ProviderMock {
+		scenario, err := agent.LoadScenario(modelConfig.Scenario)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = agent.NewMockTransport(scenario)
+	}
+	if recorder != nil {
+		client.Transport = recorder.Wrap(client.Transport)
+	}
+	model := llm.NewModel(client, modelConfig)
+	model.Logger = log
+	warnUnknownPricing(model)
+	return model, nil
+}
+
+// warnUnknownPricing warns once per model that its cost cannot be reported, when the
+// configuration sets no pricing and the model is not in the built-in pricing table.
+func warnUnknownPricing(model *llm.Model) {
+	if !model.UnknownPricing {
+		return
+	}
+	if _, warned := pricingWarned.LoadOrStore(model.Name, true); warned {
+		return
+	}
+	log.Warn("Unknown model pricing", "model", model.Name)
+	fmt.Fprintf(os.Stderr, "[WARNING] No pricing is known for model %q, set pricing in the config to report its cost\n", model.Name)
+}
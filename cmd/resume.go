@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var (
+	resumeCmd = &cobra.Command{
+		Use:   "resume <session-id>",
+		Short: "Resume a saved session",
+		Long: `Resume a session saved in $XDG_STATE_HOME/klama/sessions. Sessions are saved automatically
+when Klama exits, and periodically while it runs.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			closeLogger, err := initLogger(cfg.Log)
+			if err != nil {
+				return err
+			}
+			defer closeLogger()
+
+			stopTracing, err := initTracing(cfg.Tracing)
+			if err != nil {
+				return err
+			}
+			defer stopTracing()
+
+			store, err := session.DefaultStore()
+			if err != nil {
+				return err
+			}
+
+			sess, err := store.Load(args[0])
+			if err != nil {
+				return err
+			}
+
+			spec, err := resolveSessionSpec(cfg, sess.Agent)
+			if err != nil {
+				return fmt.Errorf("failed to resume session %s: %w", sess.ID, err)
+			}
+
+			return startSession(cfg, spec, sess)
+		},
+	}
+)
+
+// newOrRecoveredSession offers to recover the session a crashed Klama left unfinished,
+// and returns it when the user accepts, or a new session.
+func newOrRecoveredSession(cfg *config.Config, spec sessionSpec) *session.Session {
+	sess := session.New(spec.Key)
+	if cfg.UI.Autosave <= 0 || !term.IsTerminal(os.Stdin.Fd()) {
+		return sess
+	}
+
+	store, err := session.DefaultStore()
+	if err != nil {
+		log.Warn("Failed to look for a session to recover", "error", err)
+		return sess
+	}
+	// a running Klama saves its session at every interval, older snapshots were left by a crash
+	crashed, err := store.Recoverable(spec.Key, 2*cfg.UI.Autosave)
+	if err != nil {
+		log.Warn("Failed to look for a session to recover", "error", err)
+		return sess
+	}
+	if crashed == nil {
+		return sess
+	}
+
+	fmt.Fprintf(os.Stderr, "Klama did not exit cleanly during %q, last saved at %s.\nRecover previous session? [y/N] ",
+		crashed.Title, crashed.UpdatedAt.Format(time.DateTime))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		log.Info("Recovering session", "session", crashed.ID)
+		return crashed
+	}
+
+	// the declined session is kept, and not offered again
+	crashed.Unfinished = false
+	if err := store.Save(crashed); err != nil {
+		log.Warn("Failed to save the declined session", "session", crashed.ID, "error", err)
+	}
+	fmt.Fprintf(os.Stderr, "Starting a new session, resume the previous one with: klama resume %s\n", crashed.ID)
+	return sess
+}
@@ -14,7 +14,10 @@ var (
 )
 
 var (
-	cfgFile string
+	cfgFile      string
+	modelProfile string
+	dryRun       bool
+	inline       bool
 
 	rootCmd = &cobra.Command{
 		Short: "Klama is an AI-powered DevOps assistant.",
@@ -42,11 +45,48 @@ func init() {
 
 	// Add subcommands
 	rootCmd.AddCommand(k8sCmd)
+	rootCmd.AddCommand(awsCmd)
+	rootCmd.AddCommand(helmCmd)
+	rootCmd.AddCommand(linuxCmd)
+	rootCmd.AddCommand(gitCmd)
+	rootCmd.AddCommand(ghaCmd)
+	rootCmd.AddCommand(postgresCmd)
+	rootCmd.AddCommand(systemdCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(resumeCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(usageCmd)
+	rootCmd.AddCommand(memoryCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(modelsCmd)
+	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(versionCmd)
 
+	for _, cmd := range []*cobra.Command{k8sCmd, awsCmd, helmCmd, linuxCmd, gitCmd, ghaCmd, postgresCmd, systemdCmd, runCmd} {
+		addHeadlessFlags(cmd)
+	}
+
 	// add global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $XDG_CONFIG_HOME/klama/config.yaml)")
-	rootCmd.PersistentFlags().Bool("debug", false, "Enable debug mode")
+	rootCmd.PersistentFlags().StringVar(&modelProfile, "model", "", "Model profile from the models section of the config to use instead of the agent model")
+	rootCmd.PersistentFlags().Bool("debug", false, "Log at the debug level, to $XDG_STATE_HOME/klama/logs/klama.log unless a log file is set")
+	rootCmd.PersistentFlags().String("log-file", "", "Append logs to this file")
+	rootCmd.PersistentFlags().String("log-level", "", "Level of the logs: debug, info, warn or error (default info)")
+	rootCmd.PersistentFlags().String("log-format", "", "Format of the logs: text or json (default text)")
+	rootCmd.PersistentFlags().Bool("auto-approve", false, "Execute valid commands without asking for confirmation")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "Run every command instead of answering repeated commands from the cache")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Never execute commands, run approved commands yourself and paste their output back with /result")
+	rootCmd.PersistentFlags().BoolVar(&inline, "inline", false, "Print the conversation into the terminal scrollback instead of a full-screen chat")
+	rootCmd.PersistentFlags().Bool("plain", false, "Plain text without colors, decorations or animations, for screen readers, implies --inline")
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "Save every model request and response to this directory")
+	rootCmd.PersistentFlags().StringVar(&replayDir, "replay", "", "Answer model requests with the responses recorded in this directory, without network calls")
 
 	viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
+	viper.BindPFlag("log.file", rootCmd.PersistentFlags().Lookup("log-file"))
+	viper.BindPFlag("log.level", rootCmd.PersistentFlags().Lookup("log-level"))
+	viper.BindPFlag("log.format", rootCmd.PersistentFlags().Lookup("log-format"))
+	viper.BindPFlag("auto_approve.enabled", rootCmd.PersistentFlags().Lookup("auto-approve"))
+	viper.BindPFlag("cache.disabled", rootCmd.PersistentFlags().Lookup("no-cache"))
+	viper.BindPFlag("ui.plain", rootCmd.PersistentFlags().Lookup("plain"))
 }
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runCmd = &cobra.Command{
+		Use:   "run <agent-name>",
+		Short: "Interact with a custom assistant defined in the config file",
+		Long: `Interact with a custom assistant defined under the "agents" section of the config file.
+Each custom agent defines its own system prompt and allowed commands.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			closeLogger, err := initLogger(cfg.Log)
+			if err != nil {
+				return err
+			}
+			defer closeLogger()
+
+			stopTracing, err := initTracing(cfg.Tracing)
+			if err != nil {
+				return err
+			}
+			defer stopTracing()
+
+			spec, err := customSessionSpec(cfg, args[0])
+			if err != nil {
+				return err
+			}
+
+			return beginSession(cfg, spec)
+		},
+	}
+)
+
+// customSessionSpec builds the session spec for a custom agent defined in the config.
+func customSessionSpec(cfg *config.Config, name string) (sessionSpec, error) {
+	customAgent, ok := cfg.Agents[name]
+	if !ok {
+		available := make([]string, 0, len(cfg.Agents))
+		for agentName := range cfg.Agents {
+			available = append(available, agentName)
+		}
+		slices.Sort(available)
+
+		if len(available) == 0 {
+			return sessionSpec{}, fmt.Errorf("custom agent %q not found, no agents are defined in the config", name)
+		}
+		return sessionSpec{}, fmt.Errorf("custom agent %q not found, available agents: %s", name, strings.Join(available, ", "))
+	}
+
+	displayName := customAgent.DisplayName
+	if displayName == "" {
+		displayName = name
+	}
+
+	return sessionSpec{
+		Key:       name,
+		Name:      displayName,
+		AgentType: agent.AgentType(customAgent.SystemPrompt),
+		ExecuterType: executer.TerminalExecuterType{
+			AllowedCommands:      customAgent.AllowedCommands,
+			AllowedSubCommands:   customAgent.AllowedSubCommands,
+			AllowedPipedCommands: customAgent.AllowedPipedCommands,
+		},
+	}, nil
+}
@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/alertmanager"
+	"github.com/eliran89c/klama/internal/headless"
+	"github.com/eliran89c/klama/internal/mcp"
+	"github.com/eliran89c/klama/internal/session"
+	"github.com/spf13/cobra"
+)
+
+// alertQueueSize is how many alert groups may wait for triage.
+const alertQueueSize = 100
+
+var (
+	serveMCP          bool
+	serveAlertmanager bool
+	serveAgent        string
+	serveListen       string
+
+	serveCmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a Klama assistant to other tools",
+		Long: `Serve a Klama assistant to other tools. With --mcp the assistant and its guarded executer
+are exposed as Model Context Protocol tools over stdio, for IDE assistants and other MCP hosts.
+With --alertmanager Klama receives Alertmanager webhooks, investigates the firing alerts and
+posts a root cause summary to the configured alertmanager.notify_url.`,
+		Example: `  klama serve --mcp
+  klama serve --mcp --agent helm
+  klama serve --alertmanager --listen 127.0.0.1:9095`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serveMCP == serveAlertmanager {
+				return fmt.Errorf("choose what to serve with either --mcp or --alertmanager")
+			}
+
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			closeLogger, err := initLogger(cfg.Log)
+			if err != nil {
+				return err
+			}
+			defer closeLogger()
+
+			stopTracing, err := initTracing(cfg.Tracing)
+			if err != nil {
+				return err
+			}
+			defer stopTracing()
+
+			agentKey := serveAgent
+			if serveAlertmanager && !cmd.Flags().Changed("agent") {
+				agentKey = cfg.Alertmanager.Agent
+			}
+			spec, err := resolveSessionSpec(cfg, agentKey)
+			if err != nil {
+				return err
+			}
+
+			if serveAlertmanager {
+				if serveListen != "" {
+					cfg.Alertmanager.Listen = serveListen
+				}
+				return serveAlertmanagerReceiver(cfg, spec)
+			}
+			return serveMCPTools(cfg, spec)
+		},
+	}
+)
+
+func init() {
+	serveCmd.Flags().BoolVar(&serveMCP, "mcp", false, "Serve the assistant as MCP tools over stdio")
+	serveCmd.Flags().BoolVar(&serveAlertmanager, "alertmanager", false, "Triage the alerts of Alertmanager webhooks")
+	serveCmd.Flags().StringVar(&serveAgent, "agent", k8sSession.Key, "Built-in or custom assistant to serve, defaults to alertmanager.agent with --alertmanager")
+	serveCmd.Flags().StringVar(&serveListen, "listen", "", "Address the Alertmanager receiver listens on (default alertmanager.listen)")
+}
+
+// serveMCPTools serves the session assistant over stdio until the client disconnects.
+// Commands run with the same checks as headless mode, as nobody can confirm them.
+func serveMCPTools(cfg *config.Config, spec sessionSpec) error {
+	sess := session.New(spec.Key)
+	logSession(sess)
+	parts, err := newSessionParts(cfg, spec)
+	if err != nil {
+		return err
+	}
+
+	runner := newHeadlessRunner(cfg, parts)
+	server := &mcp.Server{
+		Name:    "klama",
+		Version: version,
+		Tools:   mcpTools(spec, parts, runner),
+		Logger:  log,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	startUsage := parts.model.Usage
+	serveErr := server.Serve(ctx, os.Stdin, os.Stdout)
+
+	if err := recordUsage(sess, parts.model, startUsage); err != nil {
+		log.Warn("Failed to record usage", "error", err)
+		fmt.Fprintf(os.Stderr, "[WARNING] Failed to record usage: %v\n", err)
+	}
+
+	if serveErr != nil && ctx.Err() == nil {
+		return serveErr
+	}
+	return nil
+}
+
+// serveAlertmanagerReceiver triages the alerts of Alertmanager webhooks, one alert group
+// at a time, until interrupted.
+func serveAlertmanagerReceiver(cfg *config.Config, spec sessionSpec) error {
+	if cfg.Alertmanager.NotifyURL == "" {
+		return fmt.Errorf("set alertmanager.notify_url to receive the triage results")
+	}
+	if cfg.Alertmanager.BearerToken == "" && !cfg.Alertmanager.ListensOnLoopback() {
+		return fmt.Errorf("set alertmanager.bearer_token to listen on %s, or listen on a loopback address", cfg.Alertmanager.Listen)
+	}
+
+	sess := session.New(spec.Key)
+	logSession(sess)
+	parts, err := newSessionParts(cfg, spec)
+	if err != nil {
+		return err
+	}
+
+	runner := newHeadlessRunner(cfg, parts)
+	triage := func(ctx context.Context, question string) (*headless.Result, error) {
+		// every alert group starts a new conversation
+		parts.agent.Reset()
+		return runner.Run(ctx, question)
+	}
+	notifier := alertmanager.Notifier{
+		URL:    cfg.Alertmanager.NotifyURL,
+		Format: cfg.Alertmanager.NotifyFormat,
+		Client: &http.Client{},
+	}
+
+	receiver := alertmanager.NewReceiver(triage, notifier, alertQueueSize)
+	receiver.BearerToken = cfg.Alertmanager.BearerToken
+	receiver.Log = os.Stderr
+	receiver.Logger = log
+
+	mux := http.NewServeMux()
+	mux.Handle("/alerts", receiver)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	server := &http.Server{
+		Addr:              cfg.Alertmanager.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go receiver.Run(ctx)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Receiving Alertmanager webhooks on %s/alerts\n", cfg.Alertmanager.Listen)
+
+	startUsage := parts.model.Usage
+	serveErr := server.ListenAndServe()
+
+	if err := recordUsage(sess, parts.model, startUsage); err != nil {
+		log.Warn("Failed to record usage", "error", err)
+		fmt.Fprintf(os.Stderr, "[WARNING] Failed to record usage: %v\n", err)
+	}
+
+	if !errors.Is(serveErr, http.ErrServerClosed) {
+		return serveErr
+	}
+	return nil
+}
+
+// newHeadlessRunner runs the session's commands with the checks of headless mode.
+func newHeadlessRunner(cfg *config.Config, parts *sessionParts) *headless.Runner {
+	runner := &headless.Runner{
+		Agent:           parts.agent,
+		Executer:        parts.exec,
+		MaxCommands:     cfg.AutoApprove.MaxCommands,
+		Redactor:        parts.redactor,
+		OutputProcessor: parts.outputProcessor,
+		Hints:           parts.hints,
+		ConfirmHighRisk: cfg.Policy.HighRiskKeyword != "",
+		Workers:         cfg.Plan.Parallelism,
+		AgentTimeout:    cfg.Timeouts.Agent,
+		ExecTimeout:     cfg.Timeouts.Exec,
+		DryRun:          dryRun,
+		Logger:          log,
+	}
+	if parts.policy != nil {
+		runner.Policy = parts.policy
+	}
+	if parts.memory != nil {
+		runner.Memory = parts.memory
+	}
+	if parts.target.Protected || parts.target.ProtectedFlags != nil {
+		runner.ProtectedContext = parts.target.ProtectedContext
+	}
+	return runner
+}
+
+// mcpTools are the tools of the served assistant: asking it a question, and running a
+// single command through its executer.
+func mcpTools(spec sessionSpec, parts *sessionParts, runner *headless.Runner) []mcp.Tool {
+	stringArgument := func(name, description string) map[string]any {
+		return map[string]any{
+			"type":                 "object",
+			"properties":           map[string]any{name: map[string]any{"type": "string", "description": description}},
+			"required":             []string{name},
+			"additionalProperties": false,
+		}
+	}
+
+	return []mcp.Tool{
+		{
+			Name: "ask_klama",
+			Description: fmt.Sprintf("Ask the Klama %s assistant to investigate a problem. It runs read-only commands that pass its allowlist and policy, "+
+				"and returns its answer with every command it suggested and their outputs as JSON.", spec.Name),
+			InputSchema: stringArgument("question", "The problem to investigate, with any names, namespaces and symptoms you know"),
+			Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				var args struct {
+					Question string `json:"question"`
+				}
+				if err := json.Unmarshal(arguments, &args); err != nil || strings.TrimSpace(args.Question) == "" {
+					return "", fmt.Errorf("a question is required")
+				}
+
+				// every question starts a new conversation
+				parts.agent.Reset()
+				result, err := runner.Run(ctx, args.Question)
+				data, marshalErr := json.MarshalIndent(result, "", "  ")
+				if marshalErr != nil {
+					return "", marshalErr
+				}
+				if err != nil {
+					return "", fmt.Errorf("%w\n%s", err, data)
+				}
+				return string(data), nil
+			},
+		},
+		{
+			Name: "run_command",
+			Description: fmt.Sprintf("Run a single read-only command with the Klama %s executer. Commands outside its allowlist, denied or held by "+
+				"its policy, or against a protected context are refused. Credentials in the output are redacted.", spec.Name),
+			InputSchema: stringArgument("command", "The command to run, for example: kubectl get pods -n default"),
+			Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				var args struct {
+					Command string `json:"command"`
+				}
+				if err := json.Unmarshal(arguments, &args); err != nil || strings.TrimSpace(args.Command) == "" {
+					return "", fmt.Errorf("a command is required")
+				}
+
+				result := runner.RunCommand(ctx, strings.TrimSpace(args.Command))
+				switch {
+				case result.Refused != "":
+					return "", fmt.Errorf("the command was not run: %s", result.Refused)
+				case result.Error != "":
+					return "", fmt.Errorf("the command failed: %s\n%s", result.Error, strings.TrimSpace(result.Output+"\n"+result.Stderr))
+				case result.Stderr != "":
+					return result.Output + "\nStderr:\n" + result.Stderr, nil
+				}
+				return result.Output, nil
+			},
+		},
+	}
+}
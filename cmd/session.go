@@ -0,0 +1,750 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/eliran89c/klama/internal/llm"
+	"github.com/eliran89c/klama/internal/logger"
+	"github.com/eliran89c/klama/internal/memory"
+	"github.com/eliran89c/klama/internal/policy"
+	"github.com/eliran89c/klama/internal/redact"
+	"github.com/eliran89c/klama/internal/session"
+	"github.com/eliran89c/klama/internal/telemetry"
+	"github.com/eliran89c/klama/internal/ui"
+	"github.com/eliran89c/klama/internal/usage"
+	"github.com/muesli/termenv"
+	"github.com/spf13/viper"
+)
+
+// sessionSpec describes the agent and executer used for an interactive session.
+type sessionSpec struct {
+	Key          string // identifies the agent when resuming a saved session
+	Name         string
+	AgentType    agent.AgentType
+	ExecuterType executer.TerminalExecuterType
+
+	// Tools, when set, are the tools of a multi-tool session. Every tool runs with its
+	// own terminal executer and the agent names the tool of each command.
+	Tools []sessionTool
+
+	// NewExecuter, when set, creates the session executer instead of a terminal
+	// executer of ExecuterType.
+	NewExecuter func(cfg *config.Config) (sessionExecuter, error)
+
+	// Environment, when set, gathers environment details for the agent's system prompt.
+	// It returns an empty string when nothing could be gathered.
+	Environment func(ctx context.Context, cfg *config.Config) string
+
+	// Hints, when set, gathers notes about what a message of the user mentions, which
+	// the agent gets with the message. It returns an empty string when nothing was found.
+	Hints func(ctx context.Context, cfg *config.Config, message string) string
+
+	// Target, when set, describes the environment commands run against.
+	Target func(cfg *config.Config) ui.Target
+
+	// Contexts, when set, lists the kube contexts the session can switch to with
+	// /context, which rebuilds its executer, target and environment for the context.
+	Contexts func(cfg *config.Config) ([]string, error)
+}
+
+// sessionTool is a tool of a multi-tool session.
+type sessionTool struct {
+	Name         string
+	Description  string
+	ExecuterType executer.TerminalExecuterType
+
+	// NewExecuterType, when set, creates the executer type of the tool from the
+	// configuration instead of ExecuterType.
+	NewExecuterType func(cfg *config.Config) (executer.TerminalExecuterType, error)
+}
+
+// sessionExecuter is an executer whose command cache is saved with the session.
+type sessionExecuter interface {
+	ui.Executer
+	ExecutedCommands() map[string]string
+	RestoreExecutedCommands(map[string]string)
+	SetCacheOptions(executer.CacheOptions)
+	CacheEntries() map[string]executer.CacheEntry
+	RestoreCacheEntries(map[string]executer.CacheEntry)
+}
+
+// environmentTimeout bounds gathering environment details at session start.
+const environmentTimeout = 5 * time.Second
+
+// powerShellEnvironment tells the agent how to filter output when commands run in PowerShell.
+const powerShellEnvironment = "- Commands run in PowerShell. Pipe output to Select-String, Select-Object or Sort-Object instead of grep, head or sort."
+
+// builtinSessions are the built-in assistants, keyed by their sessionSpec key.
+var builtinSessions = map[string]sessionSpec{
+	k8sSession.Key:      k8sSession,
+	awsSession.Key:      awsSession,
+	helmSession.Key:     helmSession,
+	linuxSession.Key:    linuxSession,
+	gitSession.Key:      gitSession,
+	postgresSession.Key: postgresSession,
+	systemdSession.Key:  systemdSession,
+	ghaSession.Key:      ghaSession,
+}
+
+// resolveSessionSpec returns the built-in or custom session spec for the given key.
+func resolveSessionSpec(cfg *config.Config, key string) (sessionSpec, error) {
+	if spec, ok := builtinSessions[key]; ok {
+		return spec, nil
+	}
+	return customSessionSpec(cfg, key)
+}
+
+// runSession starts an interactive debugging session for the given agent and executer types.
+func runSession(spec sessionSpec) error {
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	closeLogger, err := initLogger(cfg.Log)
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
+	stopTracing, err := initTracing(cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+
+	return beginSession(cfg, spec)
+}
+
+// log receives the records of the running command, see initLogger.
+var log = logger.Discard()
+
+// initLogger opens the configured log and returns a function that closes it. Nothing is
+// logged without a log file, and --debug logs at the debug level, to
+// $XDG_STATE_HOME/klama/logs/klama.log unless a file is configured.
+func initLogger(logConfig config.LogConfig) (func(), error) {
+	debug := viper.GetBool("debug")
+	path := logConfig.File
+	if path == "" && debug {
+		stateDir, err := config.StateDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(stateDir, "logs", "klama.log")
+	}
+	if path == "" {
+		log = logger.Discard()
+		return func() {}, nil
+	}
+
+	level, err := logger.ParseLevel(logConfig.Level)
+	if err != nil {
+		return nil, err
+	}
+	if debug {
+		level = slog.LevelDebug
+	}
+
+	rotation := logger.Rotation{MaxSize: int64(logConfig.MaxSizeMB) << 20, MaxFiles: logConfig.MaxFiles}
+	fileLog, closeFile, err := logger.Open(path, rotation, logger.Options{Level: level, Format: logConfig.Format})
+	if err != nil {
+		return nil, err
+	}
+	log = fileLog
+
+	return func() { closeFile() }, nil
+}
+
+// logSession adds the session ID to every record of the log, so the records of
+// concurrent sessions can be told apart.
+func logSession(sess *session.Session) {
+	log = log.With("session", sess.ID)
+}
+
+// initTracing exports traces when tracing is configured, and returns a function that
+// exports the remaining spans.
+func initTracing(tracing config.TracingConfig) (func(), error) {
+	shutdown, err := telemetry.Setup(context.Background(), tracing, version, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Warn("Failed to export traces", "error", err)
+		}
+	}, nil
+}
+
+// sessionParts are the components shared by interactive and headless sessions.
+type sessionParts struct {
+	model           *llm.Model
+	agent           *agent.Agent
+	exec            sessionExecuter
+	redactor        *redact.Redactor
+	outputProcessor executer.OutputProcessor
+	explainer       *agent.CommandExplainer // nil for the mock provider
+	validator       *agent.CommandValidator // nil unless validation is enabled
+	target          ui.Target
+	policy          *policy.Engine // nil when no rules are configured
+	memory          *memory.Scope  // nil when memory is disabled or the session has no target
+	cachePath       string         // file the command cache is saved to, empty when it is not persisted
+
+	hints func(ctx context.Context, message string) string // notes sent with the user's messages, nil for none
+}
+
+// newSessionParts selects the model and builds the agent, executer, redaction and policy
+// of a session.
+func newSessionParts(cfg *config.Config, spec sessionSpec) (*sessionParts, error) {
+	if err := cfg.SelectModel(modelProfile, spec.Key); err != nil {
+		return nil, err
+	}
+
+	llmModel, err := newModel(cfg.Agent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure the model client: %w", err)
+	}
+	llmModel.MaxTokens = cfg.Limits.MaxTokens
+	llmModel.MaxCost = cfg.Limits.MaxCostUSD
+
+	sessionAgent, err := agent.New(llmModel, spec.AgentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize agent: %w", err)
+	}
+
+	if environment := sessionEnvironment(context.Background(), cfg, spec); environment != "" {
+		sessionAgent.SetEnvironment(environment)
+	}
+
+	var target ui.Target
+	if spec.Target != nil {
+		target = spec.Target(cfg)
+	}
+
+	sessionMemory, err := newSessionMemory(cfg, spec, target)
+	if err != nil {
+		return nil, err
+	}
+	if sessionMemory != nil {
+		facts, err := sessionMemory.Facts()
+		if err != nil {
+			return nil, err
+		}
+		sessionAgent.SetMemory(facts)
+	}
+
+	var exec sessionExecuter = executer.NewTerminalExecuter(spec.ExecuterType)
+	if len(spec.Tools) > 0 {
+		if exec, err = newToolbox(cfg, sessionAgent, spec.Tools); err != nil {
+			return nil, fmt.Errorf("failed to initialize executer: %w", err)
+		}
+	}
+	if spec.NewExecuter != nil {
+		if exec, err = spec.NewExecuter(cfg); err != nil {
+			return nil, fmt.Errorf("failed to initialize executer: %w", err)
+		}
+	}
+
+	exec.SetCacheOptions(executer.CacheOptions{TTL: cfg.Cache.TTL, Disabled: cfg.Cache.Disabled})
+	cachePath, err := loadCommandCache(cfg, target, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	redactor, err := redact.New(cfg.Redaction.Patterns, cfg.Redaction.DisableBuiltin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize redaction: %w", err)
+	}
+
+	outputProcessor, err := newOutputProcessor(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	explainer, err := newExplainer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	validator, err := newValidator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := &sessionParts{
+		model:           llmModel,
+		agent:           sessionAgent,
+		exec:            exec,
+		redactor:        redactor,
+		outputProcessor: outputProcessor,
+		explainer:       explainer,
+		validator:       validator,
+		target:          target,
+		memory:          sessionMemory,
+		cachePath:       cachePath,
+	}
+	if spec.Hints != nil {
+		parts.hints = func(ctx context.Context, message string) string {
+			return spec.Hints(ctx, cfg, message)
+		}
+	}
+
+	if len(cfg.Policy.Rules) > 0 {
+		engine, err := policy.New(cfg.Policy.Rules)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize policy: %w", err)
+		}
+		engine.Context = parts.target.Context
+		engine.Namespace = parts.target.Namespace
+		if rewriter, ok := exec.(interface{ EffectiveCommand(string) string }); ok {
+			engine.Rewrite = rewriter.EffectiveCommand
+		}
+		parts.policy = engine
+	}
+
+	return parts, nil
+}
+
+// sessionEnvironment gathers the environment details of the agent's system prompt.
+func sessionEnvironment(ctx context.Context, cfg *config.Config, spec sessionSpec) string {
+	var environment []string
+	if spec.ExecuterType.Shell.Resolve() == executer.ShellPowerShell {
+		environment = append(environment, powerShellEnvironment)
+	}
+	// gathering the environment runs commands, dry runs never do
+	if spec.Environment != nil && !dryRun {
+		ctx, cancel := context.WithTimeout(ctx, environmentTimeout)
+		if details := spec.Environment(ctx, cfg); details != "" {
+			environment = append(environment, details)
+		}
+		cancel()
+	}
+	return strings.Join(environment, "\n")
+}
+
+// newToolbox creates the executers of the session tools and tells the agent about them.
+func newToolbox(cfg *config.Config, sessionAgent *agent.Agent, tools []sessionTool) (*executer.Toolbox, error) {
+	toolboxTools := make([]executer.Tool, 0, len(tools))
+	agentTools := make([]agent.SessionTool, 0, len(tools))
+	for _, tool := range tools {
+		executerType := tool.ExecuterType
+		if tool.NewExecuterType != nil {
+			var err error
+			if executerType, err = tool.NewExecuterType(cfg); err != nil {
+				return nil, err
+			}
+		}
+		toolboxTools = append(toolboxTools, executer.Tool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Executer:    executer.NewTerminalExecuter(executerType),
+		})
+		agentTools = append(agentTools, agent.SessionTool{Name: tool.Name, Description: tool.Description})
+	}
+
+	sessionAgent.SetTools(agentTools)
+	return executer.NewToolbox(toolboxTools...), nil
+}
+
+// sessionTab is the conversation of a TUI tab and the session it is saved to.
+type sessionTab struct {
+	sess       *session.Session
+	parts      *sessionParts
+	startUsage llm.Usage
+}
+
+// newSessionTab builds a conversation, restored from sess.
+func newSessionTab(cfg *config.Config, spec sessionSpec, sess *session.Session) (*sessionTab, error) {
+	parts, err := newSessionParts(cfg, spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(sess.History) > 0 {
+		parts.agent.Restore(sess.History, sess.Usage)
+		parts.exec.RestoreExecutedCommands(sess.ExecutedCommands)
+	}
+	return &sessionTab{sess: sess, parts: parts, startUsage: parts.model.Usage}, nil
+}
+
+// uiConfig returns the configuration of the conversation of the tab.
+func (tab *sessionTab) uiConfig(cfg *config.Config, spec sessionSpec, theme ui.Theme) ui.Config {
+	transcript := make([]ui.ChatMessage, 0, len(tab.sess.Transcript))
+	for _, entry := range tab.sess.Transcript {
+		transcript = append(transcript, ui.ChatMessage(entry))
+	}
+
+	parts := tab.parts
+	uiConfig := ui.Config{
+		Agent:      parts.agent,
+		Executer:   parts.exec,
+		AgentName:  spec.Name,
+		ModelName:  cmp.Or(modelProfile, cfg.AgentModels[spec.Key], cfg.Agent.Name),
+		Transcript: transcript,
+
+		AutoApprove:     cfg.AutoApprove.Enabled,
+		MaxAutoApproved: cfg.AutoApprove.MaxCommands,
+		MaxIterations:   max(cfg.AutoApprove.MaxIterations, 0),
+		PlanWorkers:     cfg.Plan.Parallelism,
+		AgentTimeout:    cfg.Timeouts.Agent,
+		ExecTimeout:     cfg.Timeouts.Exec,
+
+		Redactor:        parts.redactor,
+		OutputProcessor: parts.outputProcessor,
+		Hints:           parts.hints,
+
+		Target: parts.target,
+
+		HighRiskKeyword: cfg.Policy.HighRiskKeyword,
+
+		ShowThinking: cfg.UI.ShowThinking,
+		Timestamps:   cfg.UI.Timestamps,
+		SplitPane:    cfg.UI.SplitPane,
+		Inline:       inline,
+		Plain:        cfg.UI.Plain,
+
+		Notifications: ui.Notifications{
+			Method: cfg.UI.Notifications.Method,
+			After:  cfg.UI.Notifications.After,
+		},
+
+		CharLimit: cfg.UI.CharLimit,
+		Theme:     theme,
+
+		Autosave:         tab.autosave,
+		AutosaveInterval: cfg.UI.Autosave,
+		Restart:          tab.restart,
+
+		WatchInterval: cfg.UI.WatchInterval,
+
+		DryRun: dryRun,
+		Logger: log,
+	}
+	if parts.policy != nil {
+		uiConfig.Policy = parts.policy
+	}
+	if probesModel(cfg.Agent) {
+		uiConfig.Probe = tab.probe
+	}
+	if parts.memory != nil {
+		uiConfig.Memory = parts.memory
+	}
+	if parts.explainer != nil {
+		uiConfig.Explainer = parts.explainer
+	}
+	if parts.validator != nil {
+		uiConfig.Validator = parts.validator
+	}
+	if spec.Contexts != nil {
+		uiConfig.Contexts = &tabContexts{tab: tab, cfg: *cfg, spec: spec}
+	}
+	return uiConfig
+}
+
+// probe checks that the model of the tab answers and returns its latency.
+func (tab *sessionTab) probe(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	err := tab.parts.model.Ping(ctx)
+	if errors.Is(err, llm.ErrUnauthorized) {
+		err = fmt.Errorf("%w, store a valid token with klama config set-token", err)
+	}
+	return time.Since(start), err
+}
+
+// probesModel reports whether sessions check the model when they start. Recorded and
+// replayed sessions do not, as the check would be part of the recording, and neither
+// does the mock provider, which answers from its scenario.
+func probesModel(modelConfig config.ModelConfig) bool {
+	return recordDir == "" && replayDir == "" && modelConfig.Provider != config.ProviderMock
+}
+
+// autosave saves a snapshot of the conversation of the tab, to recover it after a crash.
+func (tab *sessionTab) autosave(uiModel ui.Model) error {
+	_, err := storeSession(tab.sess, tab.parts, uiModel, true)
+	return err
+}
+
+// restart saves the conversation of the tab as its own session and takes its usage out
+// of the totals, as the user chose when restarting it with Ctrl+R.
+func (tab *sessionTab) restart(uiModel ui.Model, options ui.RestartOptions) error {
+	if options.Archive {
+		if _, err := storeSession(tab.sess, tab.parts, uiModel, false); err != nil {
+			return err
+		}
+	}
+	if options.Archive || !options.KeepUsage {
+		// the usage so far belongs to the saved session, or leaves the totals
+		if err := recordUsage(tab.sess, tab.parts.model, tab.startUsage); err != nil {
+			log.Warn("Failed to record usage", "error", err)
+		}
+		tab.startUsage = tab.parts.model.Usage
+	}
+
+	if options.Archive {
+		tab.sess = session.New(tab.sess.Agent)
+	} else {
+		// the session now holds the next conversation, titled by its first message
+		tab.sess.Title = ""
+	}
+	if !options.KeepUsage {
+		tab.parts.model.Usage = llm.Usage{}
+		tab.startUsage = llm.Usage{}
+	}
+	return nil
+}
+
+// reportRetries shows the retries of the model requests of the tab in the tab.
+func (tab *sessionTab) reportRetries(p *tea.Program, id int) {
+	tab.parts.model.OnRetry = func(attempt, maxAttempts int, err error) {
+		p.Send(ui.TabMsg{Tab: id, Msg: ui.RetryMsg{Attempt: attempt, MaxAttempts: maxAttempts, Err: err}})
+	}
+}
+
+// finish records the usage of the tab and saves its command cache and session.
+func (tab *sessionTab) finish(uiModel *ui.Model) {
+	if err := recordUsage(tab.sess, tab.parts.model, tab.startUsage); err != nil {
+		log.Warn("Failed to record usage", "error", err)
+		fmt.Fprintf(os.Stderr, "[WARNING] Failed to record usage: %v\n", err)
+	}
+
+	saveCommandCache(tab.parts)
+
+	if uiModel != nil {
+		if err := saveSession(tab.sess, tab.parts, *uiModel); err != nil {
+			log.Warn("Failed to save session", "error", err)
+			fmt.Fprintf(os.Stderr, "[WARNING] Failed to save session: %v\n", err)
+		}
+	}
+}
+
+// startSession runs the TUI for the given session spec using a loaded config.
+// The conversation is restored from sess and saved back to the session store on exit.
+// Every tab opened with Ctrl+N is a new conversation, saved as its own session. Inline mode
+// has no tabs, as their conversations would interleave in the scrollback.
+func startSession(cfg *config.Config, spec sessionSpec, sess *session.Session) error {
+	logSession(sess)
+	theme, err := newTheme(cfg.UI.Theme)
+	if err != nil {
+		return err
+	}
+
+	first, err := newSessionTab(cfg, spec, sess)
+	if err != nil {
+		return err
+	}
+	tabs := []*sessionTab{first}
+
+	var p *tea.Program
+	newTab := func(id int) (ui.Config, error) {
+		tab, err := newSessionTab(cfg, spec, session.New(spec.Key))
+		if err != nil {
+			return ui.Config{}, err
+		}
+		tab.reportRetries(p, id)
+		tabs = append(tabs, tab)
+
+		uiConfig := tab.uiConfig(cfg, spec, theme)
+		uiConfig.Logger = log.With("tab_session", tab.sess.ID)
+		return uiConfig, nil
+	}
+
+	if cfg.UI.Plain {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+	options := []tea.ProgramOption{tea.WithReportFocus()}
+	if inline || cfg.UI.Plain {
+		newTab = nil
+	} else {
+		// inline mode leaves the mouse to the terminal, to select and copy text
+		options = append(options, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	}
+	p = tea.NewProgram(ui.NewTabs(ui.InitialModel(first.uiConfig(cfg, spec, theme)), newTab), options...)
+	first.reportRetries(p, 0)
+
+	finalModel, runErr := p.Run()
+
+	var models []ui.Model
+	if uiTabs, ok := finalModel.(ui.Tabs); ok {
+		models = uiTabs.Models()
+	}
+	for i, tab := range tabs {
+		var uiModel *ui.Model
+		if i < len(models) {
+			uiModel = &models[i]
+		}
+		tab.finish(uiModel)
+	}
+
+	if runErr != nil {
+		return fmt.Errorf("error running program: %w", runErr)
+	}
+
+	return nil
+}
+
+// newExplainer builds the explainer of suggested commands, which uses the summarizer
+// model. The mock provider answers from its scenario, so it explains nothing.
+func newExplainer(cfg *config.Config) (*agent.CommandExplainer, error) {
+	if cfg.Output.SummarizerModel.Provider == config.ProviderMock {
+		return nil, nil
+	}
+	model, err := newModel(cfg.Output.SummarizerModel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure the explainer model client: %w", err)
+	}
+	return &agent.CommandExplainer{Model: model}, nil
+}
+
+// newValidator builds the validator that reviews suggested commands, nil unless
+// validation is enabled. The mock provider answers from its scenario, so it reviews nothing.
+func newValidator(cfg *config.Config) (*agent.CommandValidator, error) {
+	if !cfg.Validation.Enabled || cfg.Validation.Model.Provider == config.ProviderMock {
+		return nil, nil
+	}
+	model, err := newModel(cfg.Validation.Model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure the validator model client: %w", err)
+	}
+	return &agent.CommandValidator{Model: model}, nil
+}
+
+// newOutputProcessor builds the processor that shrinks large command outputs.
+// A negative limit disables it.
+func newOutputProcessor(cfg *config.Config) (executer.OutputProcessor, error) {
+	truncator := executer.Truncator{
+		MaxLines: max(cfg.Output.MaxLines, 0),
+		MaxBytes: max(cfg.Output.MaxBytes, 0),
+	}
+
+	if !cfg.Output.Summarize {
+		return truncator, nil
+	}
+
+	summarizerModel, err := newModel(cfg.Output.SummarizerModel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure the summarizer model client: %w", err)
+	}
+
+	return executer.SummarizingProcessor{
+		Summarizer: &agent.OutputSummarizer{Model: summarizerModel},
+		Threshold:  cfg.Output.SummarizeThreshold,
+		Fallback:   truncator,
+	}, nil
+}
+
+// newTheme returns the preset theme with the configured colors applied.
+func newTheme(cfg config.ThemeConfig) (ui.Theme, error) {
+	preset := cfg.Preset
+	if preset == "" {
+		preset = ui.ThemeAuto
+	}
+	theme, ok := ui.Themes[preset]
+	if !ok {
+		return ui.Theme{}, fmt.Errorf("unknown theme preset %q, use %s, %s, %s or %s", preset, ui.ThemeAuto, ui.ThemeDark, ui.ThemeLight, ui.ThemeHighContrast)
+	}
+	if theme.Light != nil {
+		// detect the background before the chat reads the terminal, the answer is cached
+		log.Debug("Detected the terminal background", "dark", lipgloss.HasDarkBackground())
+	}
+
+	return theme.Override(ui.Theme{
+		Sender:     cfg.Sender,
+		Klama:      cfg.Klama,
+		System:     cfg.System,
+		Error:      cfg.Error,
+		Help:       cfg.Help,
+		Price:      cfg.Price,
+		Background: cfg.Background,
+	}), nil
+}
+
+// recordUsage adds the usage of this run of the session to the usage ledger.
+func recordUsage(sess *session.Session, model *llm.Model, startUsage llm.Usage) error {
+	runUsage := model.Usage.Sub(startUsage)
+	if runUsage.TotalTokens == 0 {
+		return nil
+	}
+
+	ledger, err := usage.DefaultLedger()
+	if err != nil {
+		return err
+	}
+
+	return ledger.Append(usage.Record{
+		SessionID: sess.ID,
+		Time:      time.Now(),
+		Agent:     sess.Agent,
+		Model:     model.Name,
+		Usage:     runUsage,
+		Cost:      model.Price(runUsage),
+	})
+}
+
+// saveSession persists the conversation, skipping sessions without any messages.
+func saveSession(sess *session.Session, parts *sessionParts, uiModel ui.Model) error {
+	saved, err := storeSession(sess, parts, uiModel, false)
+	if err != nil || !saved {
+		return err
+	}
+
+	fmt.Printf("Session saved, resume it with: klama resume %s\n", sess.ID)
+	return nil
+}
+
+// storeSession writes the conversation to the session store, marked unfinished for the
+// snapshots of a running session, with the credentials of the command outputs redacted.
+// It reports whether the session had messages to save.
+func storeSession(sess *session.Session, parts *sessionParts, uiModel ui.Model, unfinished bool) (bool, error) {
+	store, err := session.DefaultStore()
+	if err != nil {
+		return false, err
+	}
+
+	transcript := uiModel.Transcript()
+	if len(transcript) == 0 {
+		// a conversation restarted with Ctrl+R keeps its last snapshot, as a finished session
+		if sess.Unfinished && !unfinished {
+			sess.Unfinished = false
+			return false, store.Save(sess)
+		}
+		return false, nil
+	}
+
+	sessionAgent := parts.agent
+	sess.Unfinished = unfinished
+	sess.Model = sessionAgent.AgentModel.Name
+	sess.History = sessionAgent.AgentModel.History
+	sess.Usage = sessionAgent.AgentModel.Usage
+	sess.Cost = sessionAgent.AgentModel.Cost()
+	sess.Outcome = uiModel.Outcome()
+	sess.ExecutedCommands = parts.exec.ExecutedCommands()
+	for command, output := range sess.ExecutedCommands {
+		sess.ExecutedCommands[command], _ = parts.redactor.Redact(output)
+	}
+	sess.Transcript = make([]session.Entry, 0, len(transcript))
+	for _, msg := range transcript {
+		if sess.Title == "" && msg.Sender == ui.SenderUser {
+			sess.Title = msg.Content
+		}
+		sess.Transcript = append(sess.Transcript, session.Entry(msg))
+	}
+
+	if err := store.Save(sess); err != nil {
+		return false, err
+	}
+	return true, nil
+}
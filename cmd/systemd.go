@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/spf13/cobra"
+)
+
+// failedUnitHints bounds the failed units listed in the agent's environment.
+const failedUnitHints = 20
+
+var (
+	systemdSession = sessionSpec{
+		Key:          "systemd",
+		Name:         "systemd",
+		AgentType:    agent.AgentTypeSystemd,
+		ExecuterType: executer.SystemdExecuterType,
+		NewExecuter:  newSystemdExecuter,
+		Environment:  systemdEnvironment,
+	}
+
+	systemdCmd = &cobra.Command{
+		Use:   "systemd",
+		Short: "Interact with the systemd service debugging assistant",
+		Long: `Interact with the systemd service debugging assistant to triage failed and flapping units,
+using read-only systemctl, journalctl and loginctl commands. journalctl reads at most
+systemd.journal_max_age of logs.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSession(systemdSession)
+		},
+	}
+)
+
+// newSystemdExecuter limits journalctl to the configured journal age.
+func newSystemdExecuter(cfg *config.Config) (sessionExecuter, error) {
+	return executer.NewTerminalExecuter(executer.NewSystemdExecuterType(cfg.Systemd.JournalMaxAge)), nil
+}
+
+// systemdEnvironment tells the agent how far back it may read the journal, and which
+// units failed, so it can start the triage with them.
+func systemdEnvironment(ctx context.Context, cfg *config.Config) string {
+	lines := []string{fmt.Sprintf("- journalctl --since may go back at most %s.", cfg.Systemd.JournalMaxAge)}
+
+	out, err := exec.CommandContext(ctx, "systemctl", "list-units", "--state=failed", "--no-legend", "--plain", "--no-pager").Output()
+	if err != nil {
+		log.Debug("Skipping failed units", "error", err)
+		return strings.Join(lines, "\n")
+	}
+
+	var failed []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			failed = append(failed, fields[0])
+		}
+	}
+	switch {
+	case len(failed) == 0:
+		lines = append(lines, "- No units are in the failed state.")
+	case len(failed) > failedUnitHints:
+		lines = append(lines, fmt.Sprintf("- Failed units (%d, first %d): %s.", len(failed), failedUnitHints, strings.Join(failed[:failedUnitHints], ", ")))
+	default:
+		lines = append(lines, fmt.Sprintf("- Failed units: %s.", strings.Join(failed, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eliran89c/klama/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	usageJSON  bool
+	usageCSV   bool
+	usageBy    string
+	usageSince string
+	usageUntil string
+
+	usageCmd = &cobra.Command{
+		Use:   "usage",
+		Short: "Report token usage and spend",
+		Long: `Report the tokens and spend of all sessions, grouped by day, agent, and model.
+Usage is recorded in $XDG_STATE_HOME/klama/usage.jsonl when a session ends, so it is kept
+when saved sessions are deleted.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if usageJSON && usageCSV {
+				return fmt.Errorf("--json and --csv cannot be used together")
+			}
+
+			groups, err := usage.ParseGroups(usageBy)
+			if err != nil {
+				return fmt.Errorf("invalid --by value: %w", err)
+			}
+
+			since, err := parseTimeFlag(usageSince, time.Now())
+			if err != nil {
+				return fmt.Errorf("invalid --since value: %w", err)
+			}
+			until, err := parseTimeFlag(usageUntil, time.Now())
+			if err != nil {
+				return fmt.Errorf("invalid --until value: %w", err)
+			}
+
+			ledger, err := usage.DefaultLedger()
+			if err != nil {
+				return err
+			}
+
+			records, err := ledger.Records(since, until)
+			if err != nil {
+				return err
+			}
+			rows := usage.Summarize(records, groups)
+
+			switch {
+			case usageJSON:
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(rows)
+			case usageCSV:
+				return writeUsageCSV(rows)
+			}
+
+			if len(rows) == 0 {
+				fmt.Println("No usage recorded.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "DAY\tAGENT\tMODEL\tSESSIONS\tINPUT\tOUTPUT\tCOST")
+			for _, row := range append(rows, usageTotal(records)) {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%.4f$\n",
+					row.Day, row.Agent, row.Model, row.Sessions,
+					row.Usage.PromptTokens, row.Usage.CompletionTokens, row.Cost)
+			}
+			return w.Flush()
+		},
+	}
+)
+
+func init() {
+	usageCmd.Flags().BoolVar(&usageJSON, "json", false, "Output the report as JSON")
+	usageCmd.Flags().BoolVar(&usageCSV, "csv", false, "Output the report as CSV")
+	usageCmd.Flags().StringVar(&usageBy, "by", "day,agent,model", "Comma separated groupings: day, agent, model")
+	usageCmd.Flags().StringVar(&usageSince, "since", "", "Only include usage after a date (2006-01-02), time (RFC3339), or duration ago (e.g. 720h)")
+	usageCmd.Flags().StringVar(&usageUntil, "until", "", "Only include usage before a date (2006-01-02), time (RFC3339), or duration ago (e.g. 24h)")
+}
+
+// usageTotal returns the total row of the table.
+func usageTotal(records []usage.Record) usage.Row {
+	total := usage.Summarize(records, nil)[0]
+	total.Day = "TOTAL"
+	return total
+}
+
+// writeUsageCSV writes the report rows as CSV to stdout.
+func writeUsageCSV(rows []usage.Row) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"day", "agent", "model", "sessions", "prompt_tokens", "completion_tokens", "total_tokens", "cost"})
+	for _, row := range rows {
+		w.Write([]string{
+			row.Day,
+			row.Agent,
+			row.Model,
+			strconv.Itoa(row.Sessions),
+			strconv.Itoa(row.Usage.PromptTokens),
+			strconv.Itoa(row.Usage.CompletionTokens),
+			strconv.Itoa(row.Usage.TotalTokens),
+			strconv.FormatFloat(row.Cost, 'f', 6, 64),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -2,11 +2,14 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
 )
 
 func TestLoad(t *testing.T) {
@@ -18,6 +21,8 @@ func TestLoad(t *testing.T) {
 	viper.Set("agent.auth_token", "test-token")
 	viper.Set("agent.pricing.input", 0.01)
 	viper.Set("agent.pricing.output", 0.02)
+	viper.Set("agent.temperature", 0.2)
+	viper.Set("loki.default_range", "30m")
 
 	cfg, err := Load("")
 	require.NoError(t, err)
@@ -28,6 +33,39 @@ func TestLoad(t *testing.T) {
 	assert.Equal(t, "test-token", cfg.Agent.AuthToken)
 	assert.Equal(t, 0.01, cfg.Agent.Pricing.Input)
 	assert.Equal(t, 0.02, cfg.Agent.Pricing.Output)
+	require.NotNil(t, cfg.Agent.Temperature)
+	assert.Equal(t, 0.2, *cfg.Agent.Temperature)
+	assert.Nil(t, cfg.Agent.TopP)
+	assert.False(t, cfg.AutoApprove.Enabled)
+	assert.Equal(t, defaultMaxAutoApprovedCommands, cfg.AutoApprove.MaxCommands)
+	assert.Equal(t, defaultMaxIterations, cfg.AutoApprove.MaxIterations)
+	assert.Equal(t, defaultOutputMaxLines, cfg.Output.MaxLines)
+	assert.Equal(t, defaultOutputMaxBytes, cfg.Output.MaxBytes)
+	assert.Equal(t, cfg.Agent, cfg.Output.SummarizerModel)
+	assert.False(t, cfg.Validation.Enabled)
+	assert.Equal(t, cfg.Output.SummarizerModel, cfg.Validation.Model)
+	assert.Equal(t, defaultCharLimit, cfg.UI.CharLimit)
+	assert.Equal(t, defaultAutosave, cfg.UI.Autosave)
+	assert.Equal(t, defaultAlertmanagerListen, cfg.Alertmanager.Listen)
+	assert.Equal(t, NotifyFormatSlack, cfg.Alertmanager.NotifyFormat)
+	assert.Equal(t, 30*time.Minute, cfg.Loki.DefaultRange)
+	assert.Equal(t, defaultLokiMaxRange, cfg.Loki.MaxRange)
+	assert.Equal(t, defaultLokiMaxLines, cfg.Loki.MaxLines)
+	assert.Equal(t, defaultPostgresTimeout, cfg.Postgres.StatementTimeout)
+	assert.Equal(t, defaultJournalMaxAge, cfg.Systemd.JournalMaxAge)
+	assert.False(t, cfg.Kubernetes.Mutations.Enabled)
+	assert.False(t, cfg.Memory.Enabled)
+	assert.Equal(t, defaultMemoryMaxFacts, cfg.Memory.MaxFacts)
+	assert.Equal(t, defaultPlanParallelism, cfg.Plan.Parallelism)
+	assert.Equal(t, defaultAgentTimeout, cfg.Timeouts.Agent)
+	assert.Equal(t, defaultExecTimeout, cfg.Timeouts.Exec)
+	assert.Equal(t, defaultCacheTTL, cfg.Cache.TTL)
+	assert.False(t, cfg.Cache.Disabled)
+	assert.Equal(t, "info", cfg.Log.Level)
+	assert.Equal(t, LogFormatText, cfg.Log.Format)
+	assert.Equal(t, defaultLogMaxSizeMB, cfg.Log.MaxSizeMB)
+	assert.Equal(t, defaultLogMaxFiles, cfg.Log.MaxFiles)
+	assert.Equal(t, DefaultMutations, cfg.Kubernetes.Mutations.AllowedCommands)
 }
 
 func TestValidateConfig(t *testing.T) {
@@ -46,6 +84,108 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Azure Entra ID auth",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:            "test-agent",
+					BaseURL:         "http://test.com",
+					AzureAPIVersion: "2024-06-01",
+					AzureAuth:       AzureAuthManagedIdentity,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Azure Entra ID auth without API version",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:      "test-agent",
+					BaseURL:   "http://test.com",
+					AzureAuth: AzureAuthCLI,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Azure client secret auth without tenant",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:            "test-agent",
+					BaseURL:         "http://test.com",
+					AzureAPIVersion: "2024-06-01",
+					AzureAuth:       AzureAuthClientSecret,
+					AzureClientID:   "client",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Temperature out of range",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:        "test-agent",
+					BaseURL:     "http://test.com",
+					Temperature: func() *float64 { t := 2.5; return &t }(),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unknown provider",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:     "test-agent",
+					BaseURL:  "http://test.com",
+					Provider: "bedrock",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid OpenRouter sort",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:       "test-agent",
+					BaseURL:    "http://test.com",
+					Provider:   ProviderOpenRouter,
+					OpenRouter: OpenRouterConfig{Routing: OpenRouterRouting{Sort: "cheapest"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Relative proxy URL",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:     "test-agent",
+					BaseURL:  "http://test.com",
+					ProxyURL: "proxy.example.com:3128",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unknown Azure auth",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:            "test-agent",
+					BaseURL:         "http://test.com",
+					AzureAPIVersion: "2024-06-01",
+					AzureAuth:       "oauth",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Mock provider without base URL",
+			config: &Config{
+				Agent: ModelConfig{
+					Provider: ProviderMock,
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "Missing agent base URL",
 			config: &Config{
@@ -55,6 +195,168 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Valid custom agent",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Agents: map[string]CustomAgentConfig{
+					"terraform": {
+						SystemPrompt:    "You are a Terraform expert",
+						AllowedCommands: []string{"terraform"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Custom agent without system prompt",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Agents: map[string]CustomAgentConfig{
+					"terraform": {AllowedCommands: []string{"terraform"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Custom agent without allowed commands",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Agents: map[string]CustomAgentConfig{
+					"terraform": {SystemPrompt: "You are a Terraform expert"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Custom agent named like a built-in agent",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Agents: map[string]CustomAgentConfig{
+					"k8s": {
+						SystemPrompt:    "You are a Kubernetes expert",
+						AllowedCommands: []string{"kubectl"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative budget",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Limits: LimitsConfig{MaxCostUSD: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid policy rule",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Policy: PolicyConfig{Rules: []PolicyRule{
+					{Name: "pci", Expression: `ns.startsWith("pci-")`, Verdict: VerdictDeny},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Policy rule with an invalid verdict",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Policy: PolicyConfig{Rules: []PolicyRule{
+					{Name: "pci", Expression: `ns.startsWith("pci-")`, Verdict: "block"},
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "High risk keyword that is a confirmation answer",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Policy: PolicyConfig{HighRiskKeyword: "Yes"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid theme colors",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				UI: UIConfig{Theme: ThemeConfig{Preset: "light", Sender: "#00aa00", Klama: "91"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid theme color",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				UI: UIConfig{Theme: ThemeConfig{Error: "red"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid model profiles",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Models:      map[string]ModelConfig{"haiku": {Name: "claude-3-haiku", BaseURL: "http://test.com"}},
+				AgentModels: map[string]string{"k8s": "haiku"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Model profile without a base URL",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Models: map[string]ModelConfig{"haiku": {Name: "claude-3-haiku"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Agent with an unknown model profile",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				AgentModels: map[string]string{"k8s": "haiku"},
+			},
+			wantErr: true,
+		},
 		{
 			name: "Missing agent name",
 			config: &Config{
@@ -64,6 +366,83 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Invalid alertmanager notify format",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Alertmanager: AlertmanagerConfig{NotifyFormat: "teams"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid log level",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Log: LogConfig{Level: "verbose"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid log format",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Log: LogConfig{Format: "logfmt"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid notification method",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				UI: UIConfig{Notifications: NotificationsConfig{Method: "popup"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid notifications",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				UI: UIConfig{Notifications: NotificationsConfig{Method: NotifyOSC777, After: 30 * time.Second}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid tracing endpoint",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Tracing: TracingConfig{Endpoint: "otel-collector:4318"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Loki default range above max range",
+			config: &Config{
+				Agent: ModelConfig{
+					Name:    "test-agent",
+					BaseURL: "http://test.com",
+				},
+				Loki: LokiConfig{DefaultRange: 48 * time.Hour, MaxRange: 24 * time.Hour},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -100,3 +479,115 @@ func TestLoadWithEnvironmentVariables(t *testing.T) {
 
 	assert.Equal(t, "env-agent-token", cfg.Agent.AuthToken)
 }
+
+func TestStateDir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/tmp/state")
+
+	dir, err := StateDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/tmp/state", "klama"), dir)
+}
+
+func TestLoadWithKeyringToken(t *testing.T) {
+	keyring.MockInit()
+	require.NoError(t, SetToken(AgentTokenKey, "keyring-token"))
+	t.Cleanup(func() { DeleteToken(AgentTokenKey) })
+
+	viper.Reset()
+	viper.SetConfigType("yaml")
+	viper.Set("agent.name", "test-agent")
+	viper.Set("agent.base_url", "http://test.com")
+
+	cfg, err := Load("")
+	require.NoError(t, err)
+	assert.Equal(t, "keyring-token", cfg.Agent.AuthToken)
+
+	// the config file and the environment take precedence
+	viper.Set("agent.auth_token", "file-token")
+	cfg, err = Load("")
+	require.NoError(t, err)
+	assert.Equal(t, "file-token", cfg.Agent.AuthToken)
+
+	t.Setenv("KLAMA_AGENT_TOKEN", "env-token")
+	cfg, err = Load("")
+	require.NoError(t, err)
+	assert.Equal(t, "env-token", cfg.Agent.AuthToken)
+
+	require.NoError(t, DeleteToken(AgentTokenKey))
+	_, err = Token(AgentTokenKey)
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+}
+
+func TestConfig_SelectModel(t *testing.T) {
+	keyring.MockInit()
+	require.NoError(t, SetToken(ModelTokenKey("local"), "local-token"))
+	t.Cleanup(func() { DeleteToken(ModelTokenKey("local")) })
+
+	agent := ModelConfig{Name: "gpt-4o-mini", BaseURL: "http://openai"}
+	gpt4o := ModelConfig{Name: "gpt-4o", BaseURL: "http://openai", AuthToken: "token"}
+	local := ModelConfig{Name: "llama3", BaseURL: "http://localhost"}
+	summarizer := ModelConfig{Name: "summarizer", BaseURL: "http://openai"}
+
+	newConfig := func() *Config {
+		return &Config{
+			Agent:       agent,
+			Output:      OutputConfig{SummarizerModel: agent},
+			Validation:  ValidationConfig{Model: agent},
+			Models:      map[string]ModelConfig{"gpt4o": gpt4o, "local": local},
+			AgentModels: map[string]string{"k8s": "local"},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		profile        string
+		agentKey       string
+		wantAgent      ModelConfig
+		wantSummarizer ModelConfig
+		wantErr        bool
+	}{
+		{"No profile", "", "aws", agent, agent, false},
+		{"Agent default", "", "k8s", ModelConfig{Name: "llama3", BaseURL: "http://localhost", AuthToken: "local-token"}, ModelConfig{Name: "llama3", BaseURL: "http://localhost", AuthToken: "local-token"}, false},
+		{"Flag overrides the agent default", "gpt4o", "k8s", gpt4o, gpt4o, false},
+		{"Unknown profile", "opus", "k8s", agent, agent, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newConfig()
+			err := cfg.SelectModel(tt.profile, tt.agentKey)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAgent, cfg.Agent)
+			assert.Equal(t, tt.wantSummarizer, cfg.Output.SummarizerModel)
+			assert.Equal(t, tt.wantSummarizer, cfg.Validation.Model)
+		})
+	}
+
+	// a dedicated summarizer model is kept
+	cfg := newConfig()
+	cfg.Output.SummarizerModel = summarizer
+	cfg.Validation.Model = summarizer
+	require.NoError(t, cfg.SelectModel("gpt4o", "aws"))
+	assert.Equal(t, summarizer, cfg.Output.SummarizerModel)
+	assert.Equal(t, summarizer, cfg.Validation.Model)
+}
+
+func TestAlertmanagerConfig_ListensOnLoopback(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1:9095": true,
+		"localhost:9095": true,
+		"[::1]:9095":     true,
+		":9095":          false,
+		"0.0.0.0:9095":   false,
+		"10.0.0.5:9095":  false,
+		"klama:9095":     false,
+		"9095":           false,
+	}
+	for listen, want := range tests {
+		assert.Equal(t, want, AlertmanagerConfig{Listen: listen}.ListensOnLoopback(), listen)
+	}
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigTemplate is the commented config file written on the first run and by
+// `klama config init`.
+const defaultConfigTemplate = `# Klama configuration, see https://github.com/eliran89c/klama for all options.
+
+# The model that answers your questions, any OpenAI compatible API works.
+agent:
+  name: "gpt-4o-mini"
+  base_url: "https://api.openai.com/v1"
+  # Prefer "klama config set-token" or the KLAMA_AGENT_TOKEN environment variable
+  # over storing the token in this file.
+  auth_token: ""
+  # Azure OpenAI: set azure_api_version, and azure_auth to authenticate with Microsoft
+  # Entra ID (default, managed-identity, azure-cli or client-secret) instead of api-key.
+  # azure_api_version: "2024-06-01"
+  # azure_auth: "managed-identity"
+  # Reasoning models: the temperature is omitted when either is set.
+  # reasoning_effort: medium
+  # thinking_budget: 2048
+  # OpenRouter: report the billed cost and set provider routing preferences.
+  # provider: openrouter
+  # openrouter:
+  #   routing:
+  #     order: ["anthropic"]
+  #     data_collection: deny
+  # Try Klama without an API key: scripted responses from the built-in demo or a scenario file.
+  # provider: mock
+  # scenario: "./scenario.yaml"
+  # Models behind a gateway: extra request headers, an HTTP(S) proxy and a CA bundle.
+  # headers:
+  #   X-Org-Id: "platform"
+  # proxy_url: "http://proxy.example.com:3128"
+  # ca_cert: "/etc/ssl/certs/corp-ca.pem"
+  pricing: # USD per 1K tokens, used to show the session price
+    input: 0.00015
+    output: 0.0006
+
+# Named models, selected with --model or per assistant in agent_models.
+# models:
+#   gpt4o:
+#     name: "gpt-4o"
+#     base_url: "https://api.openai.com/v1"
+# agent_models:
+#   k8s: gpt4o
+
+# Run valid commands without asking for confirmation.
+# auto_approve:
+#   enabled: false
+#   max_commands: 10
+#   max_iterations: 8
+
+# Review every suggested command with a second, cheaper model before it is presented.
+# The summarizer model reviews them unless a model is set.
+# validation:
+#   enabled: false
+#   model:
+#     name: "gpt-4o-mini"
+#     base_url: "https://api.openai.com/v1"
+
+# Stop sending requests once a session uses this many tokens or dollars.
+# limits:
+#   max_tokens: 0
+#   max_cost_usd: 0
+
+# Remember durable facts about each kube context for later sessions.
+# memory:
+#   enabled: false
+#   max_facts: 50
+
+# Kubernetes assistant settings.
+# kubernetes:
+#   protected_contexts: ["prod-*"]
+#   # Resources commands may not touch, in addition to secrets and service account tokens.
+#   denied_resources: ["configmaps", "*.cert-manager.io"]
+#   # Suggest kubectl exec -it and port-forward, run in the terminal once approved.
+#   # Also enabled with klama k8s --allow-interactive.
+#   interactive: false
+#   # Fix mode, also enabled with klama k8s --allow-mutations.
+#   mutations:
+#     enabled: false
+#     allowed_commands: ["kubectl rollout restart", "kubectl scale", "kubectl patch"]
+
+# Troubleshooting log, --debug logs at the debug level to
+# $XDG_STATE_HOME/klama/logs/klama.log unless a file is set.
+# log:
+#   level: info # debug, info, warn or error
+#   format: text # text or json
+#   file: "/var/log/klama.log"
+#   max_size_mb: 10 # rotate the file at this size
+#   max_files: 5 # rotated files to keep
+
+# Export OpenTelemetry traces of agent requests and commands over OTLP/HTTP.
+# tracing:
+#   endpoint: "http://localhost:4318"
+#   headers:
+#     x-api-key: "your-collector-key"
+`
+
+// xdgPath returns the config file in $XDG_CONFIG_HOME (usually ~/.config/klama/config.yaml).
+func xdgPath() (string, error) {
+	xdgConfigHome := os.Getenv("XDG_CONFIG_HOME")
+	if xdgConfigHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error getting user home directory: %v", err)
+		}
+		xdgConfigHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(xdgConfigHome, "klama", "config.yaml"), nil
+}
+
+// Path returns the config file used when no path is given: the file in $XDG_CONFIG_HOME,
+// or the legacy ~/.klama.yaml when only that one exists. The file may not exist yet.
+func Path() (string, error) {
+	xdgConfigPath, err := xdgPath()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(xdgConfigPath); err == nil {
+		return xdgConfigPath, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting user home directory: %v", err)
+	}
+	legacyConfigPath := filepath.Join(home, ".klama.yaml")
+	if _, err := os.Stat(legacyConfigPath); err == nil {
+		return legacyConfigPath, nil
+	}
+
+	return xdgConfigPath, nil
+}
+
+// WriteDefault writes the commented default config file to path. An existing file is
+// only replaced when force is set.
+func WriteDefault(path string, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("config file %s already exists", path)
+	}
+	return createDefaultConfig(path)
+}
+
+// Masked returns a copy of the config with auth tokens masked, for display.
+func (c Config) Masked() Config {
+	mask := func(model ModelConfig) ModelConfig {
+		if model.AuthToken != "" {
+			model.AuthToken = "********"
+		}
+		// gateway headers often carry credentials
+		if model.Headers != nil {
+			headers := make(map[string]string, len(model.Headers))
+			for name := range model.Headers {
+				headers[name] = "********"
+			}
+			model.Headers = headers
+		}
+		return model
+	}
+
+	c.Agent = mask(c.Agent)
+	c.Output.SummarizerModel = mask(c.Output.SummarizerModel)
+	c.Validation.Model = mask(c.Validation.Model)
+	if c.Alertmanager.BearerToken != "" {
+		c.Alertmanager.BearerToken = "********"
+	}
+	if c.Loki.BearerToken != "" {
+		c.Loki.BearerToken = "********"
+	}
+	c.Postgres.DSN = c.Postgres.maskedDSN()
+	if c.Tracing.Headers != nil {
+		headers := make(map[string]string, len(c.Tracing.Headers))
+		for name := range c.Tracing.Headers {
+			headers[name] = "********"
+		}
+		c.Tracing.Headers = headers
+	}
+	if c.Models != nil {
+		models := make(map[string]ModelConfig, len(c.Models))
+		for name, model := range c.Models {
+			models[name] = mask(model)
+		}
+		c.Models = models
+	}
+	return c
+}
+
+// SetValue sets the dotted key (e.g. agent.name) to value in the YAML config file at path,
+// keeping its comments. The value is parsed as YAML, so numbers, booleans and lists keep
+// their types. The file is only written when the resulting config is valid.
+func SetValue(path, key, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	var valueDoc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &valueDoc); err != nil {
+		return fmt.Errorf("invalid value: %w", err)
+	}
+	newValue := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	if len(valueDoc.Content) > 0 {
+		newValue = valueDoc.Content[0]
+	}
+
+	if err := setNode(doc.Content[0], strings.Split(key, "."), newValue); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(buf.Bytes(), &config); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	if err := validateConfig(&config); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// setNode sets the value at the key path in a mapping node, creating missing mappings.
+func setNode(node *yaml.Node, path []string, value *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("cannot set %s, its parent is not a mapping", path[0])
+	}
+	if path[0] == "" {
+		return fmt.Errorf("invalid key")
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			// keep the comments of the replaced value
+			value.LineComment = node.Content[i+1].LineComment
+			node.Content[i+1] = value
+			return nil
+		}
+		child := node.Content[i+1]
+		if child.Kind == yaml.ScalarNode && child.Tag == "!!null" {
+			*child = yaml.Node{Kind: yaml.MappingNode}
+		}
+		return setNode(child, path[1:], value)
+	}
+
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: path[0]}
+	if len(path) == 1 {
+		node.Content = append(node.Content, keyNode, value)
+		return nil
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	node.Content = append(node.Content, keyNode, child)
+	return setNode(child, path[1:], value)
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+)
+
+func TestWriteDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "klama", "config.yaml")
+
+	require.NoError(t, WriteDefault(path, false))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, defaultConfigTemplate, string(data))
+
+	assert.Error(t, WriteDefault(path, false))
+	assert.NoError(t, WriteDefault(path, true))
+}
+
+func TestSetValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, WriteDefault(path, false))
+
+	require.NoError(t, SetValue(path, "agent.name", "gpt-4o"))
+	require.NoError(t, SetValue(path, "limits.max_cost_usd", "1.5"))
+	require.NoError(t, SetValue(path, "kubernetes.protected_contexts", `["prod-*"]`))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "# The model that answers your questions")
+	assert.Contains(t, string(data), "# USD per 1K tokens")
+
+	viper.Reset()
+	keyring.MockInit()
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o", cfg.Agent.Name)
+	assert.Equal(t, 1.5, cfg.Limits.MaxCostUSD)
+	assert.Equal(t, []string{"prod-*"}, cfg.Kubernetes.ProtectedContexts)
+}
+
+func TestSetValue_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, WriteDefault(path, false))
+
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{name: "fails validation", key: "limits.max_tokens", value: "-1"},
+		{name: "wrong type", key: "limits.max_tokens", value: "many"},
+		{name: "empty key part", key: "agent..name", value: "x"},
+		{name: "parent is not a mapping", key: "agent.name.first", value: "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, SetValue(path, tt.key, tt.value))
+		})
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, defaultConfigTemplate, string(data))
+}
+
+func TestConfig_Masked(t *testing.T) {
+	cfg := Config{
+		Agent:    ModelConfig{Name: "a", AuthToken: "secret", Headers: map[string]string{"x-org-id": "acme"}},
+		Models:   map[string]ModelConfig{"fast": {Name: "b", AuthToken: "secret"}, "local": {Name: "c"}},
+		Postgres: PostgresConfig{DSN: "postgres://app:secret@db:5432/shop"},
+	}
+	cfg.Output.SummarizerModel = ModelConfig{Name: "s", AuthToken: "secret"}
+	cfg.Validation.Model = ModelConfig{Name: "v", AuthToken: "secret"}
+
+	masked := cfg.Masked()
+	assert.Equal(t, "********", masked.Agent.AuthToken)
+	assert.Equal(t, "********", masked.Output.SummarizerModel.AuthToken)
+	assert.Equal(t, "********", masked.Validation.Model.AuthToken)
+	assert.Equal(t, "********", masked.Models["fast"].AuthToken)
+	assert.Empty(t, masked.Models["local"].AuthToken)
+	assert.Equal(t, "secret", cfg.Models["fast"].AuthToken)
+	assert.Equal(t, "postgres://app:********@db:5432/shop", masked.Postgres.DSN)
+	assert.Equal(t, map[string]string{"x-org-id": "********"}, masked.Agent.Headers)
+	assert.Equal(t, "acme", cfg.Agent.Headers["x-org-id"])
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keyringService is the service name of the tokens stored in the OS keyring
+const keyringService = "klama"
+
+// AgentTokenKey is the keyring entry of the agent model token
+const AgentTokenKey = "agent"
+
+// ModelTokenKey returns the keyring entry of the token of a model profile.
+func ModelTokenKey(profile string) string {
+	return "models/" + profile
+}
+
+// ErrTokenNotFound is returned when the keyring has no token for the given key
+var ErrTokenNotFound = errors.New("token not found in the keyring")
+
+// SetToken stores a token in the OS keyring (Keychain, Secret Service or Windows Credential Manager).
+func SetToken(key, token string) error {
+	if err := keyring.Set(keyringService, key, token); err != nil {
+		return fmt.Errorf("failed to store the token in the keyring: %w", err)
+	}
+	return nil
+}
+
+// Token reads a token from the OS keyring.
+func Token(key string) (string, error) {
+	token, err := keyring.Get(keyringService, key)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrTokenNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the token from the keyring: %w", err)
+	}
+	return token, nil
+}
+
+// DeleteToken removes a token from the OS keyring.
+func DeleteToken(key string) error {
+	err := keyring.Delete(keyringService, key)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return ErrTokenNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete the token from the keyring: %w", err)
+	}
+	return nil
+}
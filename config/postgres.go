@@ -0,0 +1,71 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// dsnPasswordPattern matches the password of a key/value connection string, quoted or not.
+var dsnPasswordPattern = regexp.MustCompile(`(?:^|\s)password\s*=\s*('(?:[^'\\]|\\.)*'|\S+)`)
+
+// ConnInfo returns the DSN without its password, and the password, so the password can
+// be passed in the PGPASSWORD environment variable instead of on the command line.
+func (p PostgresConfig) ConnInfo() (string, string) {
+	if u, ok := postgresURI(p.DSN); ok {
+		var password string
+		if u.User != nil {
+			password, _ = u.User.Password()
+			u.User = url.User(u.User.Username())
+		}
+		query := u.Query()
+		if query.Has("password") {
+			password = query.Get("password")
+			query.Del("password")
+			u.RawQuery = query.Encode()
+		}
+		return u.String(), password
+	}
+
+	match := dsnPasswordPattern.FindStringSubmatchIndex(p.DSN)
+	if match == nil {
+		return p.DSN, ""
+	}
+	password := p.DSN[match[2]:match[3]]
+	if strings.HasPrefix(password, "'") {
+		password = strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(password[1 : len(password)-1])
+	}
+	return strings.TrimSpace(strings.TrimSpace(p.DSN[:match[0]]) + " " + strings.TrimSpace(p.DSN[match[1]:])), password
+}
+
+// maskedDSN returns the DSN with its password masked.
+func (p PostgresConfig) maskedDSN() string {
+	if u, ok := postgresURI(p.DSN); ok {
+		// url.URL escapes the asterisks of the mask, so a placeholder is replaced instead
+		const placeholder = "klama-masked-password"
+		if u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), placeholder)
+			}
+		}
+		query := u.Query()
+		if query.Has("password") {
+			query.Set("password", placeholder)
+			u.RawQuery = query.Encode()
+		}
+		return strings.ReplaceAll(u.String(), placeholder, "********")
+	}
+
+	return dsnPasswordPattern.ReplaceAllStringFunc(p.DSN, func(match string) string {
+		space := match[:len(match)-len(strings.TrimLeft(match, " \t\n"))]
+		return space + "password=********"
+	})
+}
+
+func postgresURI(dsn string) (*url.URL, bool) {
+	u, err := url.Parse(dsn)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return nil, false
+	}
+	return u, true
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresConfig_ConnInfo(t *testing.T) {
+	tests := []struct {
+		name         string
+		dsn          string
+		wantDSN      string
+		wantPassword string
+		wantMasked   string
+	}{
+		{
+			name:         "URI",
+			dsn:          "postgres://app:s3cret@db:5432/shop?sslmode=require",
+			wantDSN:      "postgres://app@db:5432/shop?sslmode=require",
+			wantPassword: "s3cret",
+			wantMasked:   "postgres://app:********@db:5432/shop?sslmode=require",
+		},
+		{
+			name:         "URI password parameter",
+			dsn:          "postgresql://db/shop?user=app&password=s3cret",
+			wantDSN:      "postgresql://db/shop?user=app",
+			wantPassword: "s3cret",
+			wantMasked:   "postgresql://db/shop?password=********&user=app",
+		},
+		{
+			name:         "Key/value",
+			dsn:          "host=db user=app password=s3cret dbname=shop",
+			wantDSN:      "host=db user=app dbname=shop",
+			wantPassword: "s3cret",
+			wantMasked:   "host=db user=app password=******** dbname=shop",
+		},
+		{
+			name:         "Quoted key/value password",
+			dsn:          `host=db password = 'it\'s secret'`,
+			wantDSN:      "host=db",
+			wantPassword: "it's secret",
+			wantMasked:   "host=db password=********",
+		},
+		{
+			name:       "No password",
+			dsn:        "postgres://app@db/shop",
+			wantDSN:    "postgres://app@db/shop",
+			wantMasked: "postgres://app@db/shop",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := PostgresConfig{DSN: tt.dsn}
+			dsn, password := cfg.ConnInfo()
+			assert.Equal(t, tt.wantDSN, dsn)
+			assert.Equal(t, tt.wantPassword, password)
+			assert.Equal(t, tt.wantMasked, cfg.maskedDSN())
+		})
+	}
+}
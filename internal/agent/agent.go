@@ -2,13 +2,22 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/eliran89c/klama/internal/llm"
+	"github.com/eliran89c/klama/internal/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	modelCorrectionAttempts = 3
+
+	// tracerName names the tracer of the agent iterations.
+	tracerName = "github.com/eliran89c/klama/internal/agent"
 )
 
 // AgentResponse represents the response from the agent
@@ -16,12 +25,73 @@ type AgentResponse struct {
 	Answer     string `json:"answer,omitempty"`
 	RunCommand string `json:"run_command,omitempty"`
 	Reason     string `json:"reason_for_command"`
+	Tool       string `json:"tool,omitempty"` // tool of the command in multi-tool sessions
+
+	// Confidence and RiskLevel rate the suggested command: how sure the agent is that it
+	// helps, and its impact if it goes wrong. They are LevelLow, LevelMedium, LevelHigh
+	// or empty when the agent did not rate the command.
+	Confidence string `json:"confidence,omitempty"`
+	RiskLevel  string `json:"risk_level,omitempty"`
+
+	// Plan, when set instead of RunCommand, are independent read-only commands the user
+	// approves at once and that run concurrently.
+	Plan []PlanStep `json:"plan,omitempty"`
+
+	// SuggestedFollowups are questions the user may want to ask next, offered with a
+	// final answer.
+	SuggestedFollowups []string `json:"suggested_followups,omitempty"`
+
+	// Options, when set with a question in Answer and no command, are the choices the
+	// user picks one of, such as the pods the question may be about.
+	Options []string `json:"options,omitempty"`
+
+	// Remember are durable facts about the environment the agent learned, to remember in
+	// later sessions against it. Only set when the agent's memory is enabled.
+	Remember []string `json:"remember,omitempty"`
+
+	// Thinking is the reasoning the model returned with the response, if any.
+	Thinking string `json:"-"`
+
+	// Usage and Cost are the tokens and price of the iteration that produced the
+	// response, including correction attempts and compaction.
+	Usage llm.Usage `json:"-"`
+	Cost  float64   `json:"-"`
 }
 
+// Ratings of a suggested command
+const (
+	LevelLow    = "low"
+	LevelMedium = "medium"
+	LevelHigh   = "high"
+)
+
 // Agent represents an AI assistant.
 type Agent struct {
-	AgentModel *llm.Model
-	Type       AgentType
+	AgentModel  *llm.Model
+	Type        AgentType
+	Environment string        // facts about the user's environment, appended to the system prompt
+	Tools       []SessionTool // tools of a multi-tool session, the agent names one per command
+
+	// Memory enables recording facts to remember in later sessions, and Facts are the
+	// facts remembered from earlier sessions against the same environment.
+	Memory bool
+	Facts  []string
+
+	lastCommand string            // command the last response suggested, see commandKey
+	executed    map[string]string // outputs of the commands that ran, by commandKey
+}
+
+// PlanStep is a command of a plan.
+type PlanStep struct {
+	Command string `json:"command"`
+	Reason  string `json:"reason"`
+	Tool    string `json:"tool,omitempty"`
+}
+
+// SessionTool is a tool the agent can run commands with in a multi-tool session.
+type SessionTool struct {
+	Name        string
+	Description string
 }
 
 // New creates a new Agent with the given options.
@@ -30,35 +100,155 @@ func New(agent *llm.Model, agentType AgentType) (*Agent, error) {
 		return nil, fmt.Errorf("agent model is required")
 	}
 
-	agent.SetSystemPrompt(string(agentType))
+	if agent.NativeTools {
+		agent.Tools = nativeTools(nil, false)
+	}
 
-	return &Agent{
+	ag := &Agent{
 		AgentModel: agent,
 		Type:       agentType,
-	}, nil
+	}
+	agent.SetSystemPrompt(ag.systemPrompt())
+
+	return ag, nil
 }
 
-// Iterate sends a prompt to the AI model and returns the response.
+// Iterate sends a prompt to the AI model and returns the response. The iteration is
+// traced with the model, its token counts and the suggested command.
 func (ag *Agent) Iterate(ctx context.Context, prompt string) (AgentResponse, error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "agent.Iterate", trace.WithAttributes(
+		attribute.String("gen_ai.request.model", ag.AgentModel.Name),
+	))
+	defer span.End()
+
+	resp, err := ag.iterate(ctx, prompt)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return AgentResponse{}, err
+	}
+	span.SetAttributes(
+		attribute.Int("gen_ai.usage.input_tokens", resp.Usage.PromptTokens),
+		attribute.Int("gen_ai.usage.output_tokens", resp.Usage.CompletionTokens),
+		attribute.Float64("klama.cost_usd", resp.Cost),
+		attribute.String("klama.command", resp.RunCommand),
+		attribute.Int("klama.plan_steps", len(resp.Plan)),
+	)
+	return resp, nil
+}
+
+func (ag *Agent) iterate(ctx context.Context, prompt string) (AgentResponse, error) {
 	if prompt == "" {
 		return AgentResponse{}, fmt.Errorf("prompt is required")
 	}
 
+	ag.recordExecution(prompt)
+	before := ag.AgentModel.Usage
+
 	var modelResp AgentResponse
 	err := ag.AgentModel.GuidedAsk(ctx, prompt, modelCorrectionAttempts, &modelResp)
+	if errors.Is(err, llm.ErrToolsUnsupported) {
+		logger.Or(ag.AgentModel.Logger).Info("Model does not support tool calling, falling back to the JSON response format", "model", ag.AgentModel.Name)
+		ag.disableTools()
+		err = ag.AgentModel.GuidedAsk(ctx, prompt, modelCorrectionAttempts, &modelResp)
+	}
 	if err != nil {
 		return AgentResponse{}, err
 	}
+	if modelResp, err = ag.answerRepeats(ctx, modelResp); err != nil {
+		return AgentResponse{}, err
+	}
 
+	modelResp.Thinking = ag.AgentModel.LastReasoning()
+	modelResp.Usage = ag.AgentModel.Usage.Sub(before)
+	modelResp.Cost = ag.AgentModel.Price(modelResp.Usage)
 	return modelResp, nil
 }
 
 // Reset clears the agent's history and resets the conversation.
 func (ag *Agent) Reset() {
+	ag.lastCommand, ag.executed = "", nil
 	ag.AgentModel.History = []llm.Message{}
-	ag.AgentModel.SetSystemPrompt(
-		string(ag.Type),
-	)
+	ag.AgentModel.SetSystemPrompt(ag.systemPrompt())
+}
+
+// systemPrompt returns the agent prompt followed by the response format instructions
+// matching the model's tool calling support.
+func (ag *Agent) systemPrompt() string {
+	prompt := string(ag.Type)
+	if ag.Environment != "" {
+		prompt += fmt.Sprintf(environmentPrompt, ag.Environment)
+	}
+	if len(ag.Tools) > 0 {
+		prompt += toolsPrompt(ag.Tools)
+	}
+	if ag.Memory {
+		prompt += memoryPrompt(ag.Facts, len(ag.AgentModel.Tools) > 0)
+	}
+
+	if len(ag.AgentModel.Tools) > 0 {
+		return prompt + toolResponseFormat
+	}
+	return prompt + legacyResponseFormat
+}
+
+// SetEnvironment sets the environment details included in the system prompt.
+func (ag *Agent) SetEnvironment(environment string) {
+	ag.Environment = environment
+	ag.AgentModel.SetSystemPrompt(ag.systemPrompt())
+}
+
+// SetTools sets the tools of a multi-tool session. The agent names the tool of every
+// command it suggests.
+func (ag *Agent) SetTools(tools []SessionTool) {
+	ag.Tools = tools
+	if len(ag.AgentModel.Tools) > 0 {
+		ag.AgentModel.Tools = nativeTools(tools, ag.Memory)
+	}
+	ag.AgentModel.SetSystemPrompt(ag.systemPrompt())
+}
+
+// SetMemory enables the agent's memory with the facts remembered from earlier sessions.
+// The agent then records the durable facts it learns in its responses.
+func (ag *Agent) SetMemory(facts []string) {
+	ag.Memory = true
+	ag.Facts = facts
+	if len(ag.AgentModel.Tools) > 0 {
+		ag.AgentModel.Tools = nativeTools(ag.Tools, true)
+	}
+	ag.AgentModel.SetSystemPrompt(ag.systemPrompt())
+}
+
+// disableTools switches the agent to the legacy JSON response format.
+func (ag *Agent) disableTools() {
+	ag.AgentModel.NativeTools = false
+	ag.AgentModel.Tools = nil
+	ag.AgentModel.SetSystemPrompt(ag.systemPrompt())
+}
+
+// Restore replaces the agent's conversation with a previously saved one.
+// The system prompt is refreshed to the current agent prompt.
+func (ag *Agent) Restore(history []llm.Message, usage llm.Usage) {
+	ag.AgentModel.History = history
+	ag.AgentModel.Usage = usage
+	ag.AgentModel.SetSystemPrompt(ag.systemPrompt())
+}
+
+// ContextUsage returns the estimated tokens of the conversation and the model's context
+// window, or zeros when the context window is not configured.
+func (ag *Agent) ContextUsage() (used, window int) {
+	return ag.AgentModel.ContextUsage()
+}
+
+// Cost returns the price of the agent's model usage.
+func (ag *Agent) Cost() float64 {
+	return ag.AgentModel.Cost()
+}
+
+// BudgetLeft returns the tokens and spend left in the session budget, -1 for the limits
+// that are not set.
+func (ag *Agent) BudgetLeft() (tokens int, cost float64) {
+	return ag.AgentModel.BudgetLeft()
 }
 
 // LogUsage returns the agent's model usage log.
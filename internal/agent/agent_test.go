@@ -87,6 +87,37 @@ func TestAgent_Iterate(t *testing.T) {
 	}
 }
 
+func TestAgent_Iterate_Usage(t *testing.T) {
+	responses := []string{`invalid JSON`, `{"answer": "Corrected answer"}`}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := responses[0]
+		responses = responses[1:]
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"usage":   map[string]int{"prompt_tokens": 1000, "completion_tokens": 100, "total_tokens": 1100},
+			"choices": []map[string]interface{}{{"message": map[string]interface{}{"content": resp}}},
+		})
+	}))
+	defer mockServer.Close()
+
+	model := &llm.Model{
+		Client:      mockServer.Client(),
+		URL:         mockServer.URL,
+		AuthToken:   llm.AuthToken{Key: "test-header", Value: "test-token"},
+		InputPrice:  0.01,
+		OutputPrice: 0.03,
+		Usage:       llm.Usage{PromptTokens: 50, CompletionTokens: 5, TotalTokens: 55},
+	}
+	ag, err := New(model, AgentTypeKubernetes)
+	require.NoError(t, err)
+
+	got, err := ag.Iterate(context.Background(), "Test prompt")
+	require.NoError(t, err)
+
+	// the correction attempt is part of the iteration, earlier usage is not
+	assert.Equal(t, llm.Usage{PromptTokens: 2000, CompletionTokens: 200, TotalTokens: 2200}, got.Usage)
+	assert.InDelta(t, 0.026, got.Cost, 1e-9)
+}
+
 func TestAgent_StartSession_ContextCancellation(t *testing.T) {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Simulate a long-running operation
@@ -123,3 +154,392 @@ func TestAgent_StartSession_ContextCancellation(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "context deadline exceeded")
 }
+
+func TestAgentResponse_ParseToolCalls(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		calls    []llm.ToolCall
+		wantResp AgentResponse
+		wantErr  bool
+	}{
+		{
+			name:     "plain answer",
+			content:  "Everything looks healthy",
+			wantResp: AgentResponse{Answer: "Everything looks healthy"},
+		},
+		{
+			name:    "run command call",
+			content: "Let's check the pods",
+			calls: []llm.ToolCall{{ID: "1", Function: llm.FunctionCall{
+				Name:      runCommandToolName,
+				Arguments: `{"command": "kubectl get pods -A", "reason": "list pods"}`,
+			}}},
+			wantResp: AgentResponse{Answer: "Let's check the pods", RunCommand: "kubectl get pods -A", Reason: "list pods"},
+		},
+		{
+			name: "run command call with ratings",
+			calls: []llm.ToolCall{{ID: "1", Function: llm.FunctionCall{
+				Name:      runCommandToolName,
+				Arguments: `{"command": "kubectl get pods", "reason": "list pods", "confidence": "High", "risk_level": "none"}`,
+			}}},
+			wantResp: AgentResponse{RunCommand: "kubectl get pods", Reason: "list pods", Confidence: LevelHigh},
+		},
+		{
+			name: "run command call with a tool",
+			calls: []llm.ToolCall{{ID: "1", Function: llm.FunctionCall{
+				Name:      runCommandToolName,
+				Arguments: `{"command": "helm list -A", "reason": "list releases", "tool": "helm"}`,
+			}}},
+			wantResp: AgentResponse{RunCommand: "helm list -A", Reason: "list releases", Tool: "helm"},
+		},
+		{
+			name:    "plan call",
+			content: "Let's look at the release",
+			calls: []llm.ToolCall{{ID: "1", Function: llm.FunctionCall{
+				Name:      proposePlanToolName,
+				Arguments: `{"steps": [{"command": "kubectl get pods -n web", "reason": "list pods"}, {"command": "kubectl get events -n web", "reason": "list events"}]}`,
+			}}},
+			wantResp: AgentResponse{Answer: "Let's look at the release", Plan: []PlanStep{
+				{Command: "kubectl get pods -n web", Reason: "list pods"},
+				{Command: "kubectl get events -n web", Reason: "list events"},
+			}},
+		},
+		{
+			name: "plan of a single step",
+			calls: []llm.ToolCall{{ID: "1", Function: llm.FunctionCall{
+				Name:      proposePlanToolName,
+				Arguments: `{"steps": [{"command": "helm list -A", "reason": "list releases", "tool": "helm"}]}`,
+			}}},
+			wantResp: AgentResponse{RunCommand: "helm list -A", Reason: "list releases", Tool: "helm"},
+		},
+		{
+			name: "plan step without a command",
+			calls: []llm.ToolCall{{ID: "1", Function: llm.FunctionCall{
+				Name:      proposePlanToolName,
+				Arguments: `{"steps": [{"command": "kubectl get pods"}, {"command": " "}]}`,
+			}}},
+			wantErr: true,
+		},
+		{
+			name:    "legacy JSON plan",
+			content: `{"answer": "", "run_command": "", "plan": [{"command": "kubectl get ns", "reason": "list namespaces"}, {"command": "kubectl get nodes", "reason": "list nodes"}]}`,
+			wantResp: AgentResponse{Plan: []PlanStep{
+				{Command: "kubectl get ns", Reason: "list namespaces"},
+				{Command: "kubectl get nodes", Reason: "list nodes"},
+			}},
+		},
+		{
+			name:     "legacy JSON content",
+			content:  `{"answer": "Legacy", "run_command": "kubectl get ns"}`,
+			wantResp: AgentResponse{Answer: "Legacy", RunCommand: "kubectl get ns"},
+		},
+		{
+			name:    "followups call",
+			content: "The pod is out of memory",
+			calls: []llm.ToolCall{{ID: "1", Function: llm.FunctionCall{
+				Name:      suggestFollowupsToolName,
+				Arguments: `{"questions": ["How do I raise the memory limit?", " ", "Which pods are affected?", "Why now?", "What else?"]}`,
+			}}},
+			wantResp: AgentResponse{Answer: "The pod is out of memory", SuggestedFollowups: []string{"How do I raise the memory limit?", "Which pods are affected?", "Why now?"}},
+		},
+		{
+			name:     "legacy JSON followups",
+			content:  `{"answer": "Done", "run_command": "", "suggested_followups": ["Is the node healthy?"]}`,
+			wantResp: AgentResponse{Answer: "Done", SuggestedFollowups: []string{"Is the node healthy?"}},
+		},
+		{
+			name:    "options call",
+			content: "Which pod should I look at?",
+			calls: []llm.ToolCall{{ID: "1", Function: llm.FunctionCall{
+				Name:      offerOptionsToolName,
+				Arguments: `{"options": ["web-0", " web-1 ", "", "web-0"]}`,
+			}}},
+			wantResp: AgentResponse{Answer: "Which pod should I look at?", Options: []string{"web-0", "web-1"}},
+		},
+		{
+			name:     "legacy JSON options with a command",
+			content:  `{"answer": "", "run_command": "kubectl get pods", "options": ["web-0", "web-1"]}`,
+			wantResp: AgentResponse{RunCommand: "kubectl get pods"},
+		},
+		{
+			name:    "invalid arguments",
+			calls:   []llm.ToolCall{{ID: "1", Function: llm.FunctionCall{Name: runCommandToolName, Arguments: `{invalid`}}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown tool",
+			calls:   []llm.ToolCall{{ID: "1", Function: llm.FunctionCall{Name: "delete_cluster", Arguments: `{}`}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got AgentResponse
+			err := got.ParseToolCalls(tc.content, tc.calls)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.wantResp, got)
+			}
+		})
+	}
+}
+
+func TestAgent_Iterate_ToolsFallback(t *testing.T) {
+	var requests []map[string]interface{}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+
+		if _, ok := req["tools"]; ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "tools are not supported by this model"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"content": `{"answer": "Fallback answer", "run_command": ""}`}},
+			},
+		})
+	}))
+	defer mockServer.Close()
+
+	model := &llm.Model{
+		Client:      mockServer.Client(),
+		URL:         mockServer.URL,
+		NativeTools: true,
+		AuthToken: llm.AuthToken{
+			Key:   "test-header",
+			Value: "test-token",
+		},
+	}
+
+	ag, err := New(model, AgentTypeKubernetes)
+	require.NoError(t, err)
+	require.Len(t, model.Tools, 4)
+	assert.Contains(t, model.History[0].Content, toolResponseFormat)
+
+	got, err := ag.Iterate(context.Background(), "Test prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "Fallback answer", got.Answer)
+	assert.Len(t, requests, 2)
+	assert.False(t, model.NativeTools)
+	assert.Empty(t, model.Tools)
+	assert.Contains(t, model.History[0].Content, legacyResponseFormat)
+}
+
+func TestAgent_Restore(t *testing.T) {
+	model := &llm.Model{}
+	ag, err := New(model, AgentTypeKubernetes)
+	require.NoError(t, err)
+
+	history := []llm.Message{
+		{Role: llm.SystemRole, Content: "outdated prompt"},
+		{Role: llm.UserRole, Content: "why is my pod failing?"},
+		{Role: llm.AssistantRole, Content: `{"answer": "Let me check"}`},
+	}
+	ag.Restore(history, llm.Usage{TotalTokens: 42})
+
+	assert.Len(t, model.History, 3)
+	assert.Equal(t, ag.systemPrompt(), model.History[0].Content)
+	assert.Equal(t, "why is my pod failing?", model.History[1].Content)
+	assert.Equal(t, 42, model.Usage.TotalTokens)
+}
+
+func TestAgent_SetEnvironment(t *testing.T) {
+	model := &llm.Model{}
+	ag, err := New(model, AgentTypeKubernetes)
+	require.NoError(t, err)
+	assert.NotContains(t, model.History[0].Content, "Environment:")
+
+	ag.SetEnvironment("- Kubernetes server version: v1.30.2")
+	assert.Contains(t, model.History[0].Content, "Environment:")
+	assert.Contains(t, model.History[0].Content, "- Kubernetes server version: v1.30.2")
+
+	// the environment survives a restart
+	ag.Reset()
+	assert.Contains(t, model.History[0].Content, "v1.30.2")
+}
+
+func TestAgent_SetMemory(t *testing.T) {
+	model := &llm.Model{NativeTools: true}
+	ag, err := New(model, AgentTypeKubernetes)
+	require.NoError(t, err)
+	assert.NotContains(t, model.History[0].Content, "Memory:")
+	require.Len(t, model.Tools, 4)
+
+	ag.SetMemory([]string{"ingress is nginx in namespace infra"})
+	assert.Contains(t, model.History[0].Content, "- ingress is nginx in namespace infra")
+	assert.Contains(t, model.History[0].Content, `call the "remember_facts" tool`)
+	require.Len(t, model.Tools, 5)
+	assert.Equal(t, rememberFactsToolName, model.Tools[4].Function.Name)
+
+	var got AgentResponse
+	require.NoError(t, got.ParseToolCalls("", []llm.ToolCall{{ID: "1", Function: llm.FunctionCall{
+		Name:      rememberFactsToolName,
+		Arguments: `{"facts": ["metrics-server is not installed"]}`,
+	}}}))
+	assert.Equal(t, []string{"metrics-server is not installed"}, got.Remember)
+
+	// the memory survives a restart and the fallback to the JSON format
+	ag.Reset()
+	ag.disableTools()
+	assert.Contains(t, model.History[0].Content, "ingress is nginx")
+	assert.Contains(t, model.History[0].Content, `"remember" array`)
+}
+
+func TestAgent_SetTools(t *testing.T) {
+	model := &llm.Model{NativeTools: true}
+	ag, err := New(model, AgentTypeKubernetes)
+	require.NoError(t, err)
+	assert.NotContains(t, model.History[0].Content, "Tools:")
+	assert.NotContains(t, string(model.Tools[0].Function.Parameters), `"tool"`)
+
+	ag.SetTools([]SessionTool{
+		{Name: "kubectl", Description: "inspects cluster resources"},
+		{Name: "helm", Description: "inspects Helm releases"},
+	})
+	assert.Contains(t, model.History[0].Content, "- kubectl: inspects cluster resources")
+	assert.Contains(t, model.History[0].Content, "- helm: inspects Helm releases")
+
+	require.Len(t, model.Tools, 4)
+	var parameters struct {
+		Properties map[string]struct {
+			Enum []string `json:"enum"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	require.NoError(t, json.Unmarshal(model.Tools[0].Function.Parameters, &parameters))
+	assert.Equal(t, []string{"kubectl", "helm"}, parameters.Properties["tool"].Enum)
+	assert.Contains(t, parameters.Required, "tool")
+
+	// the tools survive a restart
+	ag.Reset()
+	assert.Contains(t, model.History[0].Content, "- helm: inspects Helm releases")
+}
+
+func TestOutputSummarizer_Summarize(t *testing.T) {
+	var received []llm.Message
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		received = req.Messages
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"content": "2 pods in CrashLoopBackOff"}},
+			},
+		})
+	}))
+	defer mockServer.Close()
+
+	model := &llm.Model{
+		Client: mockServer.Client(),
+		URL:    mockServer.URL,
+		AuthToken: llm.AuthToken{
+			Key:   "test-header",
+			Value: "test-token",
+		},
+	}
+
+	summarizer, err := NewOutputSummarizer(model)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		summary, err := summarizer.Summarize(context.Background(), "kubectl get pods -A", "NAME READY")
+		require.NoError(t, err)
+		assert.Equal(t, "2 pods in CrashLoopBackOff", summary)
+		// every summary starts a fresh conversation
+		assert.Len(t, received, 2)
+	}
+
+	_, err = NewOutputSummarizer(nil)
+	assert.Error(t, err)
+}
+
+func TestCommandExplainer_Explain(t *testing.T) {
+	var received []llm.Message
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		received = req.Messages
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"content": "- -A: all namespaces"}},
+			},
+		})
+	}))
+	defer mockServer.Close()
+
+	explainer := &CommandExplainer{Model: &llm.Model{
+		Client:    mockServer.Client(),
+		URL:       mockServer.URL,
+		AuthToken: llm.AuthToken{Key: "test-header", Value: "test-token"},
+	}}
+
+	for i := 0; i < 2; i++ {
+		explanation, err := explainer.Explain(context.Background(), "kubectl get pods -A")
+		require.NoError(t, err)
+		assert.Equal(t, "- -A: all namespaces", explanation)
+		// every explanation starts a fresh conversation
+		require.Len(t, received, 2)
+		assert.Equal(t, "Command: kubectl get pods -A", received[1].Content)
+	}
+}
+
+func TestCommandValidator_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  string
+		want    Verdict
+		wantErr bool
+	}{
+		{"Safe", `{"verdict": "safe", "reason": "reads pods"}`, Verdict{Verdict: VerdictSafe, Reason: "reads pods"}, false},
+		{"Verdict is normalized", `{"verdict": " Unsafe ", "reason": "deletes the namespace"}`, Verdict{Verdict: VerdictUnsafe, Reason: "deletes the namespace"}, false},
+		{"Unknown verdict", `{"verdict": "maybe", "reason": "?"}`, Verdict{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received []llm.Message
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req llm.ChatRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				received = req.Messages
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"choices": []map[string]interface{}{
+						{"message": map[string]interface{}{"content": tt.answer}},
+					},
+				})
+			}))
+			defer mockServer.Close()
+
+			validator := &CommandValidator{Model: &llm.Model{
+				Client:    mockServer.Client(),
+				URL:       mockServer.URL,
+				AuthToken: llm.AuthToken{Key: "test-header", Value: "test-token"},
+			}}
+
+			verdict, err := validator.Validate(context.Background(), CommandReview{
+				Command:      "kubectl get pods",
+				Reason:       "List the pods",
+				Policy:       "allowed by rule read-only",
+				Conversation: "User: why is my app down?",
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, verdict)
+
+			// every review starts a fresh conversation
+			require.Len(t, received, 2)
+			assert.Equal(t, "Command: kubectl get pods\nReason: List the pods\nPolicy: allowed by rule read-only\n\nConversation:\nUser: why is my app down?", received[1].Content)
+		})
+	}
+}
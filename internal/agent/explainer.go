@@ -0,0 +1,36 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eliran89c/klama/internal/llm"
+)
+
+const explainerPrompt = `
+You explain shell commands to engineers who must decide whether to approve them.
+
+1. Start with one sentence on what the command does as a whole.
+2. Then list every subcommand, flag and argument in order, each with a short plain language explanation, as "- <part>: <explanation>".
+3. Say whether the command only reads or changes anything, and what it could affect if it goes wrong.
+4. Do not suggest other commands. Answer in plain text, in at most 20 lines.
+`
+
+// CommandExplainer breaks suggested commands down with a separate, usually cheaper, model.
+type CommandExplainer struct {
+	Model *llm.Model
+}
+
+// Explain returns a plain language breakdown of the command. Each call starts a fresh
+// conversation.
+func (e *CommandExplainer) Explain(ctx context.Context, command string) (string, error) {
+	e.Model.History = []llm.Message{}
+	e.Model.SetSystemPrompt(explainerPrompt)
+
+	resp, err := e.Model.Ask(ctx, "Command: "+command, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to explain the command: %w", err)
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/eliran89c/klama/internal/llm"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed scenarios/demo.yaml
+var demoScenario []byte
+
+// scenarioEndedAnswer is the answer once every step of a scenario was used.
+const scenarioEndedAnswer = "The scenario has no more responses. Restart the session with Ctrl+R to play it again."
+
+// Scenario is a scripted conversation of the mock provider. Each step answers one model
+// request, in order.
+type Scenario struct {
+	Delay time.Duration  `yaml:"delay"` // wait before each response, to show the typing indicator
+	Steps []ScenarioStep `yaml:"steps"`
+}
+
+// ScenarioStep is a scripted response: an answer, a command or a plan, with the same
+// fields as a model response.
+type ScenarioStep struct {
+	Answer     string     `yaml:"answer"`
+	Command    string     `yaml:"command"`
+	Reason     string     `yaml:"reason"`
+	Tool       string     `yaml:"tool"`
+	Confidence string     `yaml:"confidence"`
+	RiskLevel  string     `yaml:"risk_level"`
+	Plan       []PlanStep `yaml:"plan"`
+	Followups  []string   `yaml:"followups"`
+	Options    []string   `yaml:"options"`
+	Remember   []string   `yaml:"remember"`
+}
+
+// LoadScenario reads a scenario file, or the built-in demo scenario when path is empty.
+func LoadScenario(path string) (*Scenario, error) {
+	data := demoScenario
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read scenario: %w", err)
+		}
+	}
+
+	var scenario Scenario
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+	if len(scenario.Steps) == 0 {
+		return nil, fmt.Errorf("scenario %s has no steps", path)
+	}
+	return &scenario, nil
+}
+
+// MockTransport is an http.RoundTripper that answers chat requests with the steps of a
+// scenario, in the JSON response format, without network calls.
+type MockTransport struct {
+	scenario *Scenario
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewMockTransport returns a transport that plays the scenario.
+func NewMockTransport(scenario *Scenario) *MockTransport {
+	return &MockTransport{scenario: scenario}
+}
+
+func (t *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var chatReq llm.ChatRequest
+	if err := json.NewDecoder(req.Body).Decode(&chatReq); err != nil {
+		return nil, fmt.Errorf("failed to decode chat request: %w", err)
+	}
+	req.Body.Close()
+
+	// like real providers, a request without messages is a bad request
+	if len(chatReq.Messages) == 0 {
+		return mockResponse(req, http.StatusBadRequest, []byte(`{"error":{"message":"messages must not be empty"}}`)), nil
+	}
+
+	step := t.nextStep()
+	if t.scenario.Delay > 0 {
+		timer := time.NewTimer(t.scenario.Delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	content, err := json.Marshal(AgentResponse{
+		Answer:             step.Answer,
+		RunCommand:         step.Command,
+		Reason:             step.Reason,
+		Tool:               step.Tool,
+		Confidence:         step.Confidence,
+		RiskLevel:          step.RiskLevel,
+		Plan:               step.Plan,
+		SuggestedFollowups: step.Followups,
+		Options:            step.Options,
+		Remember:           step.Remember,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal scenario step: %w", err)
+	}
+
+	body, err := json.Marshal(llm.ChatResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: llm.AssistantRole, Content: string(content)}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat response: %w", err)
+	}
+	return mockResponse(req, http.StatusOK, body), nil
+}
+
+// nextStep returns the next step of the scenario, or an answer that it ended.
+func (t *MockTransport) nextStep() ScenarioStep {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.next >= len(t.scenario.Steps) {
+		return ScenarioStep{Answer: scenarioEndedAnswer}
+	}
+	step := t.scenario.Steps[t.next]
+	t.next++
+	return step
+}
+
+func mockResponse(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
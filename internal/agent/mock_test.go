@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eliran89c/klama/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockTransport_DemoScenario(t *testing.T) {
+	for _, nativeTools := range []bool{true, false} {
+		scenario, err := LoadScenario("")
+		require.NoError(t, err)
+		scenario.Delay = 0
+
+		model := &llm.Model{
+			Client:      &http.Client{Transport: NewMockTransport(scenario)},
+			Name:        "mock",
+			URL:         "http://mock/chat/completions",
+			AuthToken:   llm.AuthToken{Key: "Authorization", Value: "Bearer "},
+			NativeTools: nativeTools,
+		}
+		ag, err := New(model, AgentTypeKubernetes)
+		require.NoError(t, err)
+
+		require.NoError(t, model.Ping(context.Background()))
+
+		got, err := ag.Iterate(context.Background(), "Why is my pod failing?")
+		require.NoError(t, err)
+		assert.Equal(t, "kubectl get pods -A --field-selector=status.phase!=Running", got.RunCommand)
+		assert.Equal(t, "low", got.RiskLevel)
+
+		got, err = ag.Iterate(context.Background(), "NAME READY STATUS")
+		require.NoError(t, err)
+		assert.Len(t, got.Plan, 2)
+
+		got, err = ag.Iterate(context.Background(), "outputs")
+		require.NoError(t, err)
+		assert.Contains(t, got.Answer, "demo scenario")
+		assert.Len(t, got.SuggestedFollowups, 2)
+
+		got, err = ag.Iterate(context.Background(), "And now?")
+		require.NoError(t, err)
+		assert.Equal(t, scenarioEndedAnswer, got.Answer)
+	}
+}
+
+func TestLoadScenario(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scenario.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("steps:\n  - answer: hello\n"), 0o600))
+
+	scenario, err := LoadScenario(path)
+	require.NoError(t, err)
+	assert.Equal(t, []ScenarioStep{{Answer: "hello"}}, scenario.Steps)
+
+	empty := filepath.Join(dir, "empty.yaml")
+	require.NoError(t, os.WriteFile(empty, []byte("delay: 1s\n"), 0o600))
+	_, err = LoadScenario(empty)
+	assert.ErrorContains(t, err, "has no steps")
+
+	_, err = LoadScenario(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read scenario")
+}
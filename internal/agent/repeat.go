@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eliran89c/klama/internal/logger"
+)
+
+const (
+	// maxRepeatNudges is how many times a repeated command is answered with its earlier
+	// output in one iteration before the command is suggested to the user anyway.
+	maxRepeatNudges = 2
+
+	// commandOutputHeader starts the output of a command that ran, see
+	// executer.FormatOutput. Failed commands are not recorded, running them again after
+	// a change is expected.
+	commandOutputHeader = "Command output"
+)
+
+// repeatedCommandPrompt answers a command the agent already ran with its output.
+const repeatedCommandPrompt = `You already ran this exact command earlier in this session and it was not run again. Its output was:
+%s
+
+Do not run the same command again. Use this output to move on: run a different command that gathers new information, or give your answer.`
+
+// recordExecution records the output of the command the previous response suggested,
+// when the prompt carries it.
+func (ag *Agent) recordExecution(prompt string) {
+	command := ag.lastCommand
+	ag.lastCommand = ""
+	if command == "" || !strings.Contains(prompt, commandOutputHeader) {
+		return
+	}
+	if ag.executed == nil {
+		ag.executed = map[string]string{}
+	}
+	ag.executed[command] = prompt
+}
+
+// answerRepeats asks the model again while it suggests a command it already ran,
+// answering with the earlier output instead of asking the user to run it again.
+func (ag *Agent) answerRepeats(ctx context.Context, resp AgentResponse) (AgentResponse, error) {
+	for range maxRepeatNudges {
+		output, ok := ag.executed[commandKey(resp)]
+		if !ok {
+			break
+		}
+		logger.Or(ag.AgentModel.Logger).Info("Agent repeated a command, answering with its earlier output", "command", resp.RunCommand)
+
+		var next AgentResponse
+		if err := ag.AgentModel.GuidedAsk(ctx, fmt.Sprintf(repeatedCommandPrompt, output), modelCorrectionAttempts, &next); err != nil {
+			return AgentResponse{}, err
+		}
+		resp = next
+	}
+	ag.lastCommand = commandKey(resp)
+	return resp, nil
+}
+
+// commandKey identifies the command of a response, with its tool in multi-tool sessions,
+// ignoring differences in whitespace. It is empty when the response runs no command.
+func commandKey(resp AgentResponse) string {
+	command := strings.Join(strings.Fields(resp.RunCommand), " ")
+	if command == "" || resp.Tool == "" {
+		return command
+	}
+	return resp.Tool + ": " + command
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eliran89c/klama/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent_Iterate_RepeatedCommand(t *testing.T) {
+	var (
+		responses []string
+		prompts   []string
+	)
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		prompts = append(prompts, req.Messages[len(req.Messages)-1].Content)
+
+		resp := responses[0]
+		responses = responses[1:]
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]interface{}{"content": resp}}},
+		})
+	}))
+	defer mockServer.Close()
+
+	newAgent := func() *Agent {
+		ag, err := New(&llm.Model{Client: mockServer.Client(), URL: mockServer.URL, AuthToken: llm.AuthToken{Key: "test-header", Value: "test-token"}}, AgentTypeKubernetes)
+		require.NoError(t, err)
+		return ag
+	}
+	output := "Command output:\nNAME   READY\napi-0  0/1"
+
+	t.Run("answers a repeated command with its earlier output", func(t *testing.T) {
+		ag := newAgent()
+		prompts = nil
+		responses = []string{
+			`{"run_command": "kubectl get pods -A"}`,
+			`{"run_command": "kubectl  get pods -A"}`,
+			`{"run_command": "kubectl describe pod api-0"}`,
+		}
+
+		got, err := ag.Iterate(context.Background(), "why is api down?")
+		require.NoError(t, err)
+		assert.Equal(t, "kubectl get pods -A", got.RunCommand)
+
+		got, err = ag.Iterate(context.Background(), output)
+		require.NoError(t, err)
+		assert.Equal(t, "kubectl describe pod api-0", got.RunCommand)
+		require.Len(t, prompts, 3)
+		assert.Contains(t, prompts[2], "You already ran this exact command")
+		assert.Contains(t, prompts[2], output)
+	})
+
+	t.Run("suggests the command after the nudges", func(t *testing.T) {
+		ag := newAgent()
+		responses = []string{
+			`{"run_command": "kubectl get pods -A"}`,
+			`{"run_command": "kubectl get pods -A"}`,
+			`{"run_command": "kubectl get pods -A"}`,
+			`{"run_command": "kubectl get pods -A"}`,
+		}
+
+		_, err := ag.Iterate(context.Background(), "why is api down?")
+		require.NoError(t, err)
+		got, err := ag.Iterate(context.Background(), output)
+		require.NoError(t, err)
+		assert.Equal(t, "kubectl get pods -A", got.RunCommand)
+		assert.Empty(t, responses)
+	})
+
+	t.Run("a command that did not run is not a repeat", func(t *testing.T) {
+		ag := newAgent()
+		prompts = nil
+		responses = []string{
+			`{"run_command": "kubectl get pods -A"}`,
+			`{"run_command": "kubectl get pods -A"}`,
+		}
+
+		_, err := ag.Iterate(context.Background(), "why is api down?")
+		require.NoError(t, err)
+		got, err := ag.Iterate(context.Background(), "The user declined the command, ask again later.")
+		require.NoError(t, err)
+		assert.Equal(t, "kubectl get pods -A", got.RunCommand)
+		assert.Len(t, prompts, 2)
+	})
+
+	t.Run("the same command of another tool is not a repeat", func(t *testing.T) {
+		assert.NotEqual(t,
+			commandKey(AgentResponse{RunCommand: "ls", Tool: "ssh"}),
+			commandKey(AgentResponse{RunCommand: "ls", Tool: "local"}))
+		assert.Equal(t, "kubectl get pods", commandKey(AgentResponse{RunCommand: " kubectl  get\tpods "}))
+	})
+}
@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eliran89c/klama/internal/llm"
+)
+
+const summarizerPrompt = `
+You summarize command outputs for a DevOps debugging assistant. The assistant will only see your summary, not the original output.
+
+1. Keep every error, warning, failure, restart, and abnormal status, with the names of the affected resources.
+2. Keep counts and aggregates (e.g. "42 pods Running, 3 in CrashLoopBackOff").
+3. Keep timestamps of relevant events when present.
+4. Drop healthy, repetitive, and irrelevant entries.
+5. Answer with the summary only, as plain text, in at most 40 lines.
+`
+
+// OutputSummarizer condenses command outputs with a separate, usually cheaper, model.
+// It implements executer.Summarizer.
+type OutputSummarizer struct {
+	Model *llm.Model
+}
+
+// NewOutputSummarizer creates a new OutputSummarizer.
+func NewOutputSummarizer(model *llm.Model) (*OutputSummarizer, error) {
+	if model == nil {
+		return nil, fmt.Errorf("summarizer model is required")
+	}
+	return &OutputSummarizer{Model: model}, nil
+}
+
+// Summarize returns a summary of the command output. Each call starts a fresh conversation.
+func (s *OutputSummarizer) Summarize(ctx context.Context, command, output string) (string, error) {
+	s.Model.History = []llm.Message{}
+	s.Model.SetSystemPrompt(summarizerPrompt)
+
+	resp, err := s.Model.Ask(ctx, fmt.Sprintf("Command: %s\n\nOutput:\n%s", command, output), 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize output: %w", err)
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,308 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/eliran89c/klama/internal/llm"
+)
+
+const (
+	runCommandToolName       = "run_command"
+	proposePlanToolName      = "propose_plan"
+	suggestFollowupsToolName = "suggest_followups"
+	offerOptionsToolName     = "offer_options"
+	rememberFactsToolName    = "remember_facts"
+)
+
+// MaxPlanSteps is the most commands a plan may have.
+const MaxPlanSteps = 8
+
+// MaxFollowups is the most follow-up questions kept from a response.
+const MaxFollowups = 3
+
+// MaxOptions is the most options the user picks from.
+const MaxOptions = 20
+
+// nativeTools returns the native tools the model calls to suggest a command, a plan,
+// follow-up questions or options to pick from, and to remember facts when memory is enabled. In multi-tool
+// sessions every command names one of the session tools.
+func nativeTools(tools []SessionTool, memory bool) []llm.Tool {
+	properties := map[string]any{
+		"command": map[string]any{"type": "string", "description": "The full command to execute"},
+		"reason":  map[string]any{"type": "string", "description": "Why this command is needed"},
+	}
+	required := []string{"command", "reason"}
+	if len(tools) > 0 {
+		names := make([]string, 0, len(tools))
+		for _, tool := range tools {
+			names = append(names, tool.Name)
+		}
+		properties["tool"] = map[string]any{"type": "string", "enum": names, "description": "The tool that runs the command"}
+		required = append(required, "tool")
+	}
+	command := map[string]any{"type": "object", "properties": properties, "required": required}
+
+	levels := []string{LevelLow, LevelMedium, LevelHigh}
+	ratedProperties := maps.Clone(properties)
+	ratedProperties["confidence"] = map[string]any{"type": "string", "enum": levels, "description": "How sure you are that the command helps"}
+	ratedProperties["risk_level"] = map[string]any{"type": "string", "enum": levels, "description": "Impact of the command on the environment if it goes wrong"}
+
+	runCommand, _ := json.Marshal(map[string]any{"type": "object", "properties": ratedProperties, "required": required})
+	plan, _ := json.Marshal(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"steps": map[string]any{"type": "array", "items": command, "minItems": 2, "maxItems": MaxPlanSteps},
+		},
+		"required": []string{"steps"},
+	})
+	followups, _ := json.Marshal(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"questions": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "maxItems": MaxFollowups},
+		},
+		"required": []string{"questions"},
+	})
+	options, _ := json.Marshal(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"options": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "minItems": 2, "maxItems": MaxOptions},
+		},
+		"required": []string{"options"},
+	})
+
+	nativeTools := []llm.Tool{
+		{
+			Type: "function",
+			Function: llm.ToolFunction{
+				Name:        runCommandToolName,
+				Description: "Suggest a single read-only command to run. The user approves it before execution and the output is returned as the tool result.",
+				Parameters:  runCommand,
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.ToolFunction{
+				Name: proposePlanToolName,
+				Description: "Propose a numbered plan of independent read-only commands that are all clearly needed. The user approves the plan once, " +
+					"the steps run concurrently, and their outputs are returned together in step order.",
+				Parameters: plan,
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.ToolFunction{
+				Name:        suggestFollowupsToolName,
+				Description: "Offer short follow-up questions the user may want to ask next, alongside a final answer.",
+				Parameters:  followups,
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.ToolFunction{
+				Name:        offerOptionsToolName,
+				Description: "Ask the user to pick one of several resources or options, such as which pod to look at. The question is the message content, and the user's pick is their next message.",
+				Parameters:  options,
+			},
+		},
+	}
+
+	if memory {
+		facts, _ := json.Marshal(map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"facts": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			},
+			"required": []string{"facts"},
+		})
+		nativeTools = append(nativeTools, llm.Tool{
+			Type: "function",
+			Function: llm.ToolFunction{
+				Name:        rememberFactsToolName,
+				Description: "Remember durable facts about the environment for later sessions against it, alongside a command or an answer.",
+				Parameters:  facts,
+			},
+		})
+	}
+	return nativeTools
+}
+
+// memoryPrompt tells the agent which facts it remembers and how to record new ones.
+func memoryPrompt(facts []string, nativeTools bool) string {
+	var b strings.Builder
+	b.WriteString("\nMemory:\n")
+	if len(facts) > 0 {
+		b.WriteString("These facts were learned in earlier sessions against this environment. Rely on them instead of rediscovering them, and verify any that a conclusion depends on:\n")
+		for _, fact := range facts {
+			fmt.Fprintf(&b, "- %s\n", fact)
+		}
+	}
+	record := `list them in a "remember" array of strings in your JSON response`
+	if nativeTools {
+		record = `call the "remember_facts" tool with them`
+	}
+	fmt.Fprintf(&b, "When you learn durable facts about the environment that would save time in later sessions, such as which ingress controller is installed and where, or that metrics-server is missing, %s. Record each fact once, as a short sentence. Never record secrets, or details that change often such as pod names or restart counts.\n", record)
+	return b.String()
+}
+
+// toolsPrompt lists the tools of a multi-tool session.
+func toolsPrompt(tools []SessionTool) string {
+	var b strings.Builder
+	b.WriteString("\nTools:\nThis session can run commands with several tools. Name the tool of every command in the \"tool\" field:\n")
+	for _, tool := range tools {
+		fmt.Fprintf(&b, "- %s: %s\n", tool.Name, tool.Description)
+	}
+	return b.String()
+}
+
+type runCommandArgs struct {
+	Command    string `json:"command"`
+	Reason     string `json:"reason"`
+	Tool       string `json:"tool"`
+	Confidence string `json:"confidence"`
+	RiskLevel  string `json:"risk_level"`
+}
+
+type proposePlanArgs struct {
+	Steps []PlanStep `json:"steps"`
+}
+
+type suggestFollowupsArgs struct {
+	Questions []string `json:"questions"`
+}
+
+type offerOptionsArgs struct {
+	Options []string `json:"options"`
+}
+
+type rememberFactsArgs struct {
+	Facts []string `json:"facts"`
+}
+
+// ParseToolCalls populates the response from a native tool-calling reply.
+// It implements llm.ToolCallParser.
+func (r *AgentResponse) ParseToolCalls(content string, calls []llm.ToolCall) error {
+	content = strings.TrimSpace(content)
+
+	// some models ignore the tools and answer in the legacy JSON format
+	if len(calls) == 0 && strings.HasPrefix(content, "{") && json.Valid([]byte(content)) {
+		return json.Unmarshal([]byte(content), r)
+	}
+
+	r.Answer = content
+	for _, call := range calls {
+		switch call.Function.Name {
+		case runCommandToolName:
+			if r.RunCommand != "" || len(r.Plan) > 0 {
+				continue
+			}
+			var args runCommandArgs
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return fmt.Errorf("invalid %s arguments: %w", runCommandToolName, err)
+			}
+			if args.Command == "" {
+				return fmt.Errorf("%s requires a command", runCommandToolName)
+			}
+			r.RunCommand = args.Command
+			r.Reason = args.Reason
+			r.Tool = args.Tool
+			r.Confidence = args.Confidence
+			r.RiskLevel = args.RiskLevel
+		case proposePlanToolName:
+			if r.RunCommand != "" || len(r.Plan) > 0 {
+				continue
+			}
+			var args proposePlanArgs
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return fmt.Errorf("invalid %s arguments: %w", proposePlanToolName, err)
+			}
+			r.Plan = args.Steps
+		case suggestFollowupsToolName:
+			var args suggestFollowupsArgs
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return fmt.Errorf("invalid %s arguments: %w", suggestFollowupsToolName, err)
+			}
+			r.SuggestedFollowups = args.Questions
+		case offerOptionsToolName:
+			var args offerOptionsArgs
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return fmt.Errorf("invalid %s arguments: %w", offerOptionsToolName, err)
+			}
+			r.Options = args.Options
+		case rememberFactsToolName:
+			var args rememberFactsArgs
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return fmt.Errorf("invalid %s arguments: %w", rememberFactsToolName, err)
+			}
+			r.Remember = append(r.Remember, args.Facts...)
+		default:
+			return fmt.Errorf("unknown tool: %s", call.Function.Name)
+		}
+	}
+
+	return r.normalize()
+}
+
+// UnmarshalJSON decodes a response in the legacy JSON format and checks its plan.
+func (r *AgentResponse) UnmarshalJSON(data []byte) error {
+	type response AgentResponse
+	if err := json.Unmarshal(data, (*response)(r)); err != nil {
+		return err
+	}
+	return r.normalize()
+}
+
+// normalize checks the plan of the response, keeps the first non-empty follow-ups and
+// distinct options, and drops unknown ratings. Options are only offered with a question,
+// not with a command or a plan. A plan of a single step is suggested as a single command.
+func (r *AgentResponse) normalize() error {
+	r.Confidence = normalizeLevel(r.Confidence)
+	r.RiskLevel = normalizeLevel(r.RiskLevel)
+
+	var followups []string
+	for _, followup := range r.SuggestedFollowups {
+		if followup = strings.TrimSpace(followup); followup != "" && len(followups) < MaxFollowups {
+			followups = append(followups, followup)
+		}
+	}
+	r.SuggestedFollowups = followups
+
+	var options []string
+	for _, option := range r.Options {
+		if option = strings.TrimSpace(option); option != "" && !slices.Contains(options, option) && len(options) < MaxOptions {
+			options = append(options, option)
+		}
+	}
+	r.Options = options
+
+	if len(r.Plan) > MaxPlanSteps {
+		return fmt.Errorf("a plan can have at most %d steps, got %d", MaxPlanSteps, len(r.Plan))
+	}
+	for i, step := range r.Plan {
+		if strings.TrimSpace(step.Command) == "" {
+			return fmt.Errorf("step %d of the plan requires a command", i+1)
+		}
+	}
+
+	if len(r.Plan) == 1 && r.RunCommand == "" {
+		r.RunCommand, r.Reason, r.Tool = r.Plan[0].Command, r.Plan[0].Reason, r.Plan[0].Tool
+		r.Plan = nil
+	}
+	if r.RunCommand != "" || len(r.Plan) > 0 {
+		r.Options = nil
+	}
+	return nil
+}
+
+// normalizeLevel returns the rating in lower case, or empty when it is not a known level.
+func normalizeLevel(level string) string {
+	switch level = strings.ToLower(strings.TrimSpace(level)); level {
+	case LevelLow, LevelMedium, LevelHigh:
+		return level
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eliran89c/klama/internal/llm"
+)
+
+// The verdicts of the validator.
+const (
+	VerdictSafe    = "safe"
+	VerdictCaution = "caution"
+	VerdictUnsafe  = "unsafe"
+)
+
+const validatorPrompt = `
+You review shell commands an AI assistant suggests to an engineer, before the engineer decides whether to run them.
+
+Judge whether the command is a safe and sensible next step for the conversation:
+- "safe": the command only reads, or its changes are exactly what the user asked for, and it fits the user's question.
+- "caution": the command is broader, slower or more revealing than needed, does not match the reason given for it, or changes something the user did not clearly ask to change.
+- "unsafe": the command can destroy data, cause an outage, leak credentials, or breaks the policy decision it was given.
+
+Respond with JSON only, in this format:
+{"verdict": "safe", "reason": "one short sentence explaining the verdict"}
+`
+
+// CommandReview is a suggested command with what the validator needs to review it.
+type CommandReview struct {
+	Command      string
+	Reason       string // why the agent suggested the command
+	Policy       string // decision of the command policy, empty when no rule matched
+	Conversation string // recent messages of the conversation
+}
+
+// Verdict is the validator's review of a suggested command.
+type Verdict struct {
+	Verdict string `json:"verdict"` // VerdictSafe, VerdictCaution or VerdictUnsafe
+	Reason  string `json:"reason"`
+}
+
+// Unsafe reports whether the validator flagged the command as unsafe.
+func (v Verdict) Unsafe() bool {
+	return v.Verdict == VerdictUnsafe
+}
+
+// CommandValidator reviews suggested commands with a separate, usually cheaper, model.
+type CommandValidator struct {
+	Model *llm.Model
+}
+
+// Validate reviews the command against the policy and the conversation. Each call starts
+// a fresh conversation.
+func (v *CommandValidator) Validate(ctx context.Context, review CommandReview) (Verdict, error) {
+	v.Model.History = []llm.Message{}
+	v.Model.SetSystemPrompt(validatorPrompt)
+
+	prompt := "Command: " + review.Command
+	if review.Reason != "" {
+		prompt += "\nReason: " + review.Reason
+	}
+	if review.Policy != "" {
+		prompt += "\nPolicy: " + review.Policy
+	}
+	if review.Conversation != "" {
+		prompt += "\n\nConversation:\n" + review.Conversation
+	}
+
+	var verdict Verdict
+	if err := v.Model.GuidedAsk(ctx, prompt, modelCorrectionAttempts, &verdict); err != nil {
+		return Verdict{}, fmt.Errorf("failed to validate the command: %w", err)
+	}
+
+	verdict.Verdict = strings.ToLower(strings.TrimSpace(verdict.Verdict))
+	switch verdict.Verdict {
+	case VerdictSafe, VerdictCaution, VerdictUnsafe:
+	default:
+		return Verdict{}, fmt.Errorf("the validator answered an unknown verdict %q", verdict.Verdict)
+	}
+	return verdict, nil
+}
@@ -0,0 +1,302 @@
+// Package alertmanager receives Alertmanager webhooks, triages the firing alerts with an
+// agent and posts a summary of the findings.
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/headless"
+	"github.com/eliran89c/klama/internal/logger"
+)
+
+// maxWebhookBytes bounds the size of a webhook request body.
+const maxWebhookBytes = 1 << 20
+
+// Webhook is the payload Alertmanager sends to webhook receivers.
+type Webhook struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []Alert           `json:"alerts"`
+}
+
+// Alert is a single alert of a webhook.
+type Alert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// Alert statuses
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Firing returns the alerts of the webhook that are still firing.
+func (w Webhook) Firing() []Alert {
+	var firing []Alert
+	for _, alert := range w.Alerts {
+		if alert.Status == StatusFiring {
+			firing = append(firing, alert)
+		}
+	}
+	return firing
+}
+
+// Name returns the alert name of the group, or its group key.
+func (w Webhook) Name() string {
+	if name := w.CommonLabels["alertname"]; name != "" {
+		return name
+	}
+	if name := w.GroupLabels["alertname"]; name != "" {
+		return name
+	}
+	return w.GroupKey
+}
+
+// Prompt asks the agent to find the root cause of the firing alerts, seeded with their
+// labels and annotations.
+func Prompt(w Webhook) string {
+	var b strings.Builder
+	b.WriteString("Alertmanager reported the following firing alerts. Investigate them with read-only commands and ")
+	b.WriteString("answer with the most likely root cause, the evidence for it, and the next steps to fix it.\n")
+
+	for i, alert := range w.Firing() {
+		fmt.Fprintf(&b, "\nAlert %d, firing since %s:\n", i+1, alert.StartsAt.UTC().Format(time.RFC3339))
+		writeSorted(&b, "Labels", alert.Labels)
+		writeSorted(&b, "Annotations", alert.Annotations)
+	}
+	return b.String()
+}
+
+func writeSorted(b *strings.Builder, title string, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	fmt.Fprintf(b, "%s:\n", title)
+	for _, key := range keys {
+		fmt.Fprintf(b, "- %s: %s\n", key, values[key])
+	}
+}
+
+// Triager investigates a question, see headless.Runner.
+type Triager func(ctx context.Context, question string) (*headless.Result, error)
+
+// Receiver accepts Alertmanager webhooks and triages them one at a time, in the order
+// they arrived.
+type Receiver struct {
+	Triage   Triager
+	Notifier Notifier
+
+	// BearerToken, when set, must be sent in the Authorization header.
+	BearerToken string
+
+	Log    io.Writer    // receives a line for every triaged alert group, nil discards them
+	Logger *slog.Logger // receives the records of the receiver, nil discards them
+
+	queue chan Webhook
+}
+
+// NewReceiver returns a receiver that holds up to queueSize webhooks waiting for triage.
+func NewReceiver(triage Triager, notifier Notifier, queueSize int) *Receiver {
+	return &Receiver{
+		Triage:   triage,
+		Notifier: notifier,
+		queue:    make(chan Webhook, queueSize),
+	}
+}
+
+// ServeHTTP queues the firing alerts of a webhook for triage.
+func (rc *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if rc.BearerToken != "" {
+		expected := "Bearer " + rc.BearerToken
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var webhook Webhook
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxWebhookBytes)).Decode(&webhook); err != nil {
+		http.Error(w, fmt.Sprintf("invalid webhook: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if len(webhook.Firing()) == 0 {
+		rc.log().Debug("Skipping alert group without firing alerts", "alert_group", webhook.Name())
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	select {
+	case rc.queue <- webhook:
+		rc.log().Debug("Queued alert group for triage", "alert_group", webhook.Name())
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(w, "triage queue is full", http.StatusServiceUnavailable)
+	}
+}
+
+// Run triages the queued webhooks until ctx is canceled.
+func (rc *Receiver) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case webhook := <-rc.queue:
+			rc.triage(ctx, webhook)
+		}
+	}
+}
+
+func (rc *Receiver) triage(ctx context.Context, webhook Webhook) {
+	rc.log().Info("Triaging alert group", "alert_group", webhook.Name())
+
+	result, err := rc.Triage(ctx, Prompt(webhook))
+	if err != nil {
+		rc.log().Error("Triage failed", "alert_group", webhook.Name(), "error", err)
+		rc.printf("Triage of alert group %s failed: %v\n", webhook.Name(), err)
+	}
+
+	if err := rc.Notifier.Notify(ctx, webhook, result); err != nil {
+		rc.log().Error("Failed to post the triage", "alert_group", webhook.Name(), "error", err)
+		rc.printf("Failed to post the triage of alert group %s: %v\n", webhook.Name(), err)
+		return
+	}
+	rc.log().Info("Posted the triage", "alert_group", webhook.Name())
+	rc.printf("Posted the triage of alert group %s\n", webhook.Name())
+}
+
+// log returns the logger of the receiver.
+func (rc *Receiver) log() *slog.Logger {
+	return logger.Or(rc.Logger)
+}
+
+// printf writes a line to Log.
+func (rc *Receiver) printf(format string, args ...any) {
+	if rc.Log != nil {
+		fmt.Fprintf(rc.Log, format, args...)
+	}
+}
+
+// Notifier posts triage results to a webhook.
+type Notifier struct {
+	URL    string
+	Format string // config.NotifyFormatSlack or config.NotifyFormatJSON
+	Client *http.Client
+}
+
+// Notification is the JSON format of a triage result.
+type Notification struct {
+	Alert        string            `json:"alert"`
+	GroupKey     string            `json:"group_key"`
+	CommonLabels map[string]string `json:"common_labels"`
+	ExternalURL  string            `json:"external_url,omitempty"`
+	Triage       *headless.Result  `json:"triage"`
+}
+
+// Notify posts the triage result of the webhook.
+func (n Notifier) Notify(ctx context.Context, webhook Webhook, result *headless.Result) error {
+	var payload any = Notification{
+		Alert:        webhook.Name(),
+		GroupKey:     webhook.GroupKey,
+		CommonLabels: webhook.CommonLabels,
+		ExternalURL:  webhook.ExternalURL,
+		Triage:       result,
+	}
+	if n.Format != config.NotifyFormatJSON {
+		payload = map[string]string{"text": SlackText(webhook, result)}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("notification was rejected with status code %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// SlackText formats the triage result as a Slack message.
+func SlackText(webhook Webhook, result *headless.Result) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, ":mag: *Klama triage of %s* (%d firing)\n", webhook.Name(), len(webhook.Firing()))
+
+	switch {
+	case result == nil:
+		b.WriteString("The triage did not run.\n")
+	case result.Answer != "":
+		b.WriteString(result.Answer + "\n")
+	default:
+		fmt.Fprintf(&b, "The triage did not finish: %s\n", result.Error)
+	}
+
+	if result != nil && len(result.Commands) > 0 {
+		b.WriteString("\n*Commands*\n")
+		for _, command := range result.Commands {
+			status := ""
+			if command.Refused != "" {
+				status = " (refused: " + command.Refused + ")"
+			}
+			fmt.Fprintf(&b, "• `%s`%s\n", command.Command, status)
+		}
+	}
+
+	if result != nil {
+		fmt.Fprintf(&b, "\n_%d tokens, %.4f$_", result.Usage.TotalTokens, result.Cost)
+	}
+	return b.String()
+}
@@ -0,0 +1,165 @@
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/headless"
+	"github.com/eliran89c/klama/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWebhook = `{
+  "version": "4",
+  "groupKey": "{}:{alertname=\"KubePodCrashLooping\"}",
+  "status": "firing",
+  "commonLabels": {"alertname": "KubePodCrashLooping", "namespace": "shop"},
+  "alerts": [
+    {
+      "status": "firing",
+      "labels": {"alertname": "KubePodCrashLooping", "pod": "checkout-1", "namespace": "shop"},
+      "annotations": {"summary": "Pod is crash looping"},
+      "startsAt": "2024-05-01T10:00:00Z"
+    },
+    {
+      "status": "resolved",
+      "labels": {"alertname": "KubePodCrashLooping", "pod": "checkout-2", "namespace": "shop"},
+      "startsAt": "2024-05-01T09:00:00Z"
+    }
+  ]
+}`
+
+func parseWebhook(t *testing.T, data string) Webhook {
+	t.Helper()
+	var webhook Webhook
+	require.NoError(t, json.Unmarshal([]byte(data), &webhook))
+	return webhook
+}
+
+func TestPrompt(t *testing.T) {
+	prompt := Prompt(parseWebhook(t, testWebhook))
+
+	assert.Contains(t, prompt, "Alert 1, firing since 2024-05-01T10:00:00Z:\nLabels:\n- alertname: KubePodCrashLooping\n- namespace: shop\n- pod: checkout-1\nAnnotations:\n- summary: Pod is crash looping\n")
+	assert.NotContains(t, prompt, "checkout-2")
+}
+
+func TestReceiver_ServeHTTP(t *testing.T) {
+	resolved := strings.ReplaceAll(testWebhook, `"status": "firing"`, `"status": "resolved"`)
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		token          string
+		expectedStatus int
+		queued         int
+	}{
+		{name: "firing alerts", method: http.MethodPost, body: testWebhook, token: "secret", expectedStatus: http.StatusAccepted, queued: 1},
+		{name: "resolved alerts", method: http.MethodPost, body: resolved, token: "secret", expectedStatus: http.StatusOK},
+		{name: "invalid body", method: http.MethodPost, body: "{", token: "secret", expectedStatus: http.StatusBadRequest},
+		{name: "wrong token", method: http.MethodPost, body: testWebhook, token: "wrong", expectedStatus: http.StatusUnauthorized},
+		{name: "wrong method", method: http.MethodGet, token: "secret", expectedStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := NewReceiver(nil, Notifier{}, 1)
+			receiver.BearerToken = "secret"
+
+			req := httptest.NewRequest(tt.method, "/alerts", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			receiver.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Len(t, receiver.queue, tt.queued)
+		})
+	}
+}
+
+func TestReceiver_ServeHTTPQueueFull(t *testing.T) {
+	receiver := NewReceiver(nil, Notifier{}, 1)
+
+	for _, expected := range []int{http.StatusAccepted, http.StatusServiceUnavailable} {
+		rec := httptest.NewRecorder()
+		receiver.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/alerts", strings.NewReader(testWebhook)))
+		assert.Equal(t, expected, rec.Code)
+	}
+}
+
+func TestReceiver_Run(t *testing.T) {
+	posted := make(chan map[string]string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		posted <- payload
+	}))
+	defer server.Close()
+
+	var question string
+	triage := func(_ context.Context, q string) (*headless.Result, error) {
+		question = q
+		return &headless.Result{
+			Answer:   "The checkout pods cannot reach the database.",
+			Commands: []headless.Command{{Command: "kubectl logs checkout-1 -n shop"}},
+			Usage:    llm.Usage{TotalTokens: 1200},
+			Cost:     0.0021,
+		}, nil
+	}
+
+	receiver := NewReceiver(triage, Notifier{URL: server.URL, Format: config.NotifyFormatSlack}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go receiver.Run(ctx)
+
+	receiver.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/alerts", strings.NewReader(testWebhook)))
+
+	select {
+	case payload := <-posted:
+		assert.Equal(t, ":mag: *Klama triage of KubePodCrashLooping* (1 firing)\nThe checkout pods cannot reach the database.\n\n*Commands*\n• `kubectl logs checkout-1 -n shop`\n\n_1200 tokens, 0.0021$_", payload["text"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("the triage was not posted")
+	}
+	assert.Contains(t, question, "pod: checkout-1")
+}
+
+func TestNotifier_NotifyJSON(t *testing.T) {
+	var notification Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&notification)
+	}))
+	defer server.Close()
+
+	result := &headless.Result{Question: "q", Error: "boom", Commands: []headless.Command{}}
+	notifier := Notifier{URL: server.URL, Format: config.NotifyFormatJSON}
+	require.NoError(t, notifier.Notify(context.Background(), parseWebhook(t, testWebhook), result))
+
+	assert.Equal(t, "KubePodCrashLooping", notification.Alert)
+	assert.Equal(t, "shop", notification.CommonLabels["namespace"])
+	assert.Equal(t, "boom", notification.Triage.Error)
+}
+
+func TestNotifier_NotifyRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := Notifier{URL: server.URL}.Notify(context.Background(), parseWebhook(t, testWebhook), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status code 403: invalid_token")
+}
+
+func TestSlackText_Failed(t *testing.T) {
+	text := SlackText(parseWebhook(t, testWebhook), &headless.Result{Error: "the agent did not answer within the command limit", Commands: []headless.Command{{Command: "kubectl get secret", Refused: "denied"}}})
+
+	assert.Contains(t, text, "The triage did not finish: the agent did not answer within the command limit\n")
+	assert.Contains(t, text, "• `kubectl get secret` (refused: denied)\n")
+}
@@ -0,0 +1,132 @@
+// Package audit records the commands that change the user's environment, such as the
+// remediations of fix mode, in an append-only log.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/eliran89c/klama/config"
+)
+
+// Entry statuses
+const (
+	StatusApproved  = "approved"  // the user approved the command and it is about to run
+	StatusSucceeded = "succeeded" // the command ran successfully
+	StatusFailed    = "failed"    // the command ran and failed
+)
+
+// Entry is a single audit record.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Agent   string    `json:"agent,omitempty"`
+	Context string    `json:"context,omitempty"` // kube context or other target the command ran against
+	Command string    `json:"command"`           // the command line that was executed
+	Target  string    `json:"target,omitempty"`  // resource the command changes
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Log appends audit entries to a JSON lines file. Agent and Context are added to entries
+// that do not set them.
+type Log struct {
+	Path    string
+	Agent   string
+	Context string
+}
+
+// DefaultPath returns the audit log location, $XDG_STATE_HOME/klama/audit.jsonl.
+func DefaultPath() (string, error) {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, "audit.jsonl"), nil
+}
+
+// Append adds an entry to the log. The time and user are set when they are empty.
+func (l *Log) Append(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	if entry.User == "" {
+		entry.User = currentUser()
+	}
+	if entry.Agent == "" {
+		entry.Agent = l.Agent
+	}
+	if entry.Context == "" {
+		entry.Context = l.Context
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	file, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	// the entry must be on disk before the command runs
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return file.Close()
+}
+
+// Entries returns the entries of the log, oldest first. Lines that cannot be decoded
+// are skipped.
+func (l *Log) Entries() ([]Entry, error) {
+	file, err := os.Open(l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// currentUser returns the name of the user running klama.
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog_Append(t *testing.T) {
+	log := &Log{Path: filepath.Join(t.TempDir(), "klama", "audit.jsonl"), Agent: "k8s", Context: "prod"}
+
+	entries, err := log.Entries()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	require.NoError(t, log.Append(Entry{Command: "kubectl rollout restart deployment/api", Target: "deployment/api", Status: StatusApproved}))
+	require.NoError(t, log.Append(Entry{
+		Time:    time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC),
+		User:    "alice",
+		Context: "staging",
+		Command: "kubectl rollout restart deployment/api",
+		Status:  StatusFailed,
+		Error:   "exit status 1",
+	}))
+
+	entries, err = log.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "k8s", entries[0].Agent)
+	assert.Equal(t, "prod", entries[0].Context)
+	assert.NotEmpty(t, entries[0].User)
+	assert.False(t, entries[0].Time.IsZero())
+
+	assert.Equal(t, "alice", entries[1].User)
+	assert.Equal(t, "staging", entries[1].Context)
+	assert.Equal(t, StatusFailed, entries[1].Status)
+
+	info, err := os.Stat(log.Path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestLog_AppendUnwritable(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), nil, 0600))
+
+	log := &Log{Path: filepath.Join(dir, "file", "audit.jsonl")}
+	assert.Error(t, log.Append(Entry{Command: "kubectl scale deployment/api --replicas=3"}))
+}
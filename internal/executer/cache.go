@@ -0,0 +1,147 @@
+package executer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheEntry is the cached output of a command that succeeded.
+type CacheEntry struct {
+	Output string    `json:"output"`
+	Stderr string    `json:"stderr,omitempty"`
+	At     time.Time `json:"at"` // when the command ran, zero for outputs restored without it
+}
+
+// response answers a command with the cached output.
+func (e CacheEntry) response() ExecuterResponse {
+	return ExecuterResponse{Stdout: e.Output, Stderr: e.Stderr, Cached: true, CachedAt: e.At}
+}
+
+// CacheOptions control how long command outputs are answered from the cache.
+type CacheOptions struct {
+	TTL      time.Duration // age after which a command runs again, 0 keeps outputs for the session
+	Disabled bool          // runs every command
+}
+
+// uncachedKey marks the contexts of runs that skip the cache.
+type uncachedKey struct{}
+
+// WithoutCache returns a context whose commands run even when their output is cached,
+// such as the commands of a watch, which must see every change. Their outputs still
+// refresh the cache.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, uncachedKey{}, true)
+}
+
+// cacheSkipped reports whether the commands run with ctx skip the cache.
+func cacheSkipped(ctx context.Context) bool {
+	skipped, _ := ctx.Value(uncachedKey{}).(bool)
+	return skipped
+}
+
+// SetCacheOptions changes how the cache answers repeated commands.
+func (tx *TerminalExecuter) SetCacheOptions(options CacheOptions) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	tx.cacheOptions = options
+}
+
+// CacheEntries returns a copy of the cached command outputs that did not expire.
+func (tx *TerminalExecuter) CacheEntries() map[string]CacheEntry {
+	tx.mu.RLock()
+	defer tx.mu.RUnlock()
+
+	entries := make(map[string]CacheEntry, len(tx.executedCommands))
+	for command, entry := range tx.executedCommands {
+		if tx.fresh(entry) {
+			entries[command] = entry
+		}
+	}
+	return entries
+}
+
+// RestoreCacheEntries adds cached command outputs, such as the ones saved by an earlier
+// session. Expired outputs are never answered.
+func (tx *TerminalExecuter) RestoreCacheEntries(entries map[string]CacheEntry) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	for command, entry := range entries {
+		tx.executedCommands[command] = entry
+	}
+}
+
+// cached returns the cached output of a command, unless the cache is disabled or the
+// output expired.
+func (tx *TerminalExecuter) cached(command string) (CacheEntry, bool) {
+	tx.mu.RLock()
+	defer tx.mu.RUnlock()
+
+	if tx.executerType.Uncached || tx.cacheOptions.Disabled {
+		return CacheEntry{}, false
+	}
+	entry, exists := tx.executedCommands[command]
+	if !exists || !tx.fresh(entry) {
+		return CacheEntry{}, false
+	}
+	return entry, true
+}
+
+// cache records the output of a command that succeeded.
+func (tx *TerminalExecuter) cache(command, output, stderr string) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.executerType.Uncached || tx.cacheOptions.Disabled {
+		return
+	}
+	tx.executedCommands[command] = CacheEntry{Output: output, Stderr: stderr, At: tx.now()}
+}
+
+// fresh reports whether the entry is younger than the TTL. Entries of unknown age are
+// only fresh without a TTL. The caller holds the lock.
+func (tx *TerminalExecuter) fresh(entry CacheEntry) bool {
+	if tx.cacheOptions.TTL <= 0 {
+		return true
+	}
+	return !entry.At.IsZero() && tx.now().Sub(entry.At) <= tx.cacheOptions.TTL
+}
+
+// LoadCacheFile reads the cache entries saved at path. A missing file is an empty cache.
+func LoadCacheFile(path string) (map[string]CacheEntry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]CacheEntry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command cache: %w", err)
+	}
+
+	var entries map[string]CacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse command cache %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// SaveCacheFile writes the cache entries to path, readable only by the user as command
+// outputs may hold sensitive data.
+func SaveCacheFile(path string, entries map[string]CacheEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal command cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create command cache directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write command cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package executer
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTerminalExecuter_CacheTTL(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	te := NewTerminalExecuter(testExecuterType)
+	te.now = func() time.Time { return now }
+	te.SetCacheOptions(CacheOptions{TTL: time.Minute})
+
+	if result := te.Run(context.Background(), "echo hello"); result.Cached {
+		t.Fatalf("Run() = %+v, want a fresh result", result)
+	}
+
+	now = now.Add(30 * time.Second)
+	result := te.Run(context.Background(), "echo hello")
+	if !result.Cached || !result.CachedAt.Equal(now.Add(-30*time.Second)) {
+		t.Errorf("Run() = %+v, want the cached result", result)
+	}
+
+	// expired outputs run again and are not saved
+	now = now.Add(time.Minute)
+	if len(te.CacheEntries()) != 0 {
+		t.Errorf("CacheEntries() = %v, want no fresh entries", te.CacheEntries())
+	}
+	if result := te.Run(context.Background(), "echo hello"); result.Cached {
+		t.Errorf("Run() = %+v, want the command to run again", result)
+	}
+
+	// restored outputs of unknown age expire right away
+	te.RestoreExecutedCommands(map[string]string{"echo world": "stale"})
+	if result := te.Run(context.Background(), "echo world"); result.Stdout != "world" {
+		t.Errorf("Run() = %+v, want the command to run again", result)
+	}
+}
+
+func TestTerminalExecuter_CacheDisabled(t *testing.T) {
+	te := NewTerminalExecuter(testExecuterType)
+	te.SetCacheOptions(CacheOptions{Disabled: true})
+	te.RestoreExecutedCommands(map[string]string{"echo hello": "cached"})
+
+	if result := te.Run(context.Background(), "echo hello"); result.Cached || result.Stdout != "hello" {
+		t.Errorf("Run() = %+v, want the command to run", result)
+	}
+}
+
+func TestTerminalExecuter_WithoutCache(t *testing.T) {
+	te := NewTerminalExecuter(testExecuterType)
+	te.RestoreExecutedCommands(map[string]string{"echo hello": "cached"})
+
+	if result := te.Run(WithoutCache(context.Background()), "echo hello"); result.Cached || result.Stdout != "hello" {
+		t.Errorf("Run() = %+v, want the command to run", result)
+	}
+	// the fresh output replaces the cached one
+	if result := te.Run(context.Background(), "echo hello"); !result.Cached || result.Stdout != "hello" {
+		t.Errorf("Run() = %+v, want the refreshed cached result", result)
+	}
+}
+
+func TestCacheFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "prod.json")
+
+	entries, err := LoadCacheFile(path)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("LoadCacheFile() = %v, %v, want an empty cache", entries, err)
+	}
+
+	saved := map[string]CacheEntry{"kubectl get pods": {Output: "web Running", At: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}}
+	if err := SaveCacheFile(path, saved); err != nil {
+		t.Fatalf("SaveCacheFile() error = %v", err)
+	}
+	loaded, err := LoadCacheFile(path)
+	if err != nil {
+		t.Fatalf("LoadCacheFile() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, saved) {
+		t.Errorf("LoadCacheFile() = %v, want %v", loaded, saved)
+	}
+}
@@ -0,0 +1,310 @@
+package executer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// cmdlet is a PowerShell cmdlet allowed after a pipe. Cmdlets run in Klama on the lines
+// of their input, so PowerShell command lines run without a shell like POSIX ones.
+type cmdlet struct {
+	switches []string // parameters without a value, lowercase
+	values   []string // parameters with a value, lowercase
+	run      func(params cmdletParams, lines []string) ([]string, error)
+}
+
+// cmdletParams are the parameters a cmdlet was called with.
+type cmdletParams struct {
+	named      map[string]string // values of the named parameters by lowercase name, "true" for switches
+	positional []string
+}
+
+// has reports whether the parameter was given.
+func (p cmdletParams) has(name string) bool {
+	_, ok := p.named[name]
+	return ok
+}
+
+// count returns the value of a numeric parameter, -1 when it was not given.
+func (p cmdletParams) count(name string) (int, error) {
+	value, ok := p.named[name]
+	if !ok {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: -%s must be a number", ErrOperationNotAllowed, name)
+	}
+	return n, nil
+}
+
+// powerShellCmdlets are the cmdlets of powerShellPipedCommands by lowercase name.
+var powerShellCmdlets = map[string]cmdlet{
+	"select-string":  {switches: []string{"simplematch", "notmatch", "casesensitive"}, values: []string{"pattern"}, run: selectString},
+	"select-object":  {switches: []string{"unique"}, values: []string{"first", "last", "skip"}, run: selectObject},
+	"sort-object":    {switches: []string{"descending", "unique", "casesensitive"}, run: sortObject},
+	"measure-object": {switches: []string{"line", "word", "character"}, run: measureObject},
+	"get-unique":     {run: getUnique},
+}
+
+// cmdletFor returns the cmdlet of a command name, which is case insensitive.
+func cmdletFor(name string) (cmdlet, bool) {
+	c, ok := powerShellCmdlets[strings.ToLower(name)]
+	return c, ok
+}
+
+// parseCmdletArgs parses the arguments of a cmdlet. Parameters are case insensitive and
+// take their value as the next argument or after a colon, as in -First:5.
+func parseCmdletArgs(name string, c cmdlet, args []string) (cmdletParams, error) {
+	params := cmdletParams{named: map[string]string{}}
+	for i := 0; i < len(args); i++ {
+		param, ok := strings.CutPrefix(args[i], "-")
+		if !ok || param == "" {
+			params.positional = append(params.positional, args[i])
+			continue
+		}
+		param, value, hasValue := strings.Cut(param, ":")
+		param = strings.ToLower(param)
+		switch {
+		case slices.Contains(c.switches, param) && !hasValue:
+			params.named[param] = "true"
+		case slices.Contains(c.values, param):
+			if !hasValue {
+				if i+1 >= len(args) {
+					return cmdletParams{}, fmt.Errorf("%w: %s -%s needs a value", ErrOperationNotAllowed, name, param)
+				}
+				i++
+				value = args[i]
+			}
+			params.named[param] = value
+		default:
+			return cmdletParams{}, fmt.Errorf("%w: %s %s", ErrOperationNotAllowed, name, args[i])
+		}
+	}
+	return params, nil
+}
+
+// validateCmdlet checks the arguments of a cmdlet without running it.
+func validateCmdlet(cmd Command) error {
+	c, ok := cmdletFor(cmd.Parts[0])
+	if !ok {
+		return nil
+	}
+	args := make([]string, 0, len(cmd.Parts)-1)
+	for _, part := range cmd.Parts[1:] {
+		args = append(args, unquotePowerShellWord(part))
+	}
+	params, err := parseCmdletArgs(cmd.Parts[0], c, args)
+	if err != nil {
+		return err
+	}
+	_, err = c.run(params, nil)
+	return err
+}
+
+// selectString keeps the lines that match the pattern, a case insensitive regular
+// expression unless -SimpleMatch or -CaseSensitive is given.
+func selectString(params cmdletParams, lines []string) ([]string, error) {
+	pattern, ok := params.named["pattern"]
+	switch {
+	case ok && len(params.positional) > 0, !ok && len(params.positional) != 1:
+		return nil, fmt.Errorf("%w: Select-String takes a single pattern", ErrOperationNotAllowed)
+	case !ok:
+		pattern = params.positional[0]
+	}
+
+	if params.has("simplematch") {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !params.has("casesensitive") {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: Select-String pattern: %v", ErrOperationNotAllowed, err)
+	}
+
+	var matched []string
+	for _, line := range lines {
+		if re.MatchString(line) != params.has("notmatch") {
+			matched = append(matched, line)
+		}
+	}
+	return matched, nil
+}
+
+// selectObject skips the lines of -Skip and keeps the lines of -First and -Last.
+func selectObject(params cmdletParams, lines []string) ([]string, error) {
+	if len(params.positional) > 0 {
+		return nil, fmt.Errorf("%w: Select-Object only selects lines with -First, -Last, -Skip and -Unique", ErrOperationNotAllowed)
+	}
+	first, err := params.count("first")
+	if err != nil {
+		return nil, err
+	}
+	last, err := params.count("last")
+	if err != nil {
+		return nil, err
+	}
+	skip, err := params.count("skip")
+	if err != nil {
+		return nil, err
+	}
+
+	if params.has("unique") {
+		lines = uniqueLines(lines)
+	}
+	lines = lines[min(max(skip, 0), len(lines)):]
+	if first >= 0 {
+		lines = lines[:min(first, len(lines))]
+	}
+	if last >= 0 {
+		lines = lines[len(lines)-min(last, len(lines)):]
+	}
+	return lines, nil
+}
+
+// sortObject sorts the lines, case insensitive unless -CaseSensitive is given.
+func sortObject(params cmdletParams, lines []string) ([]string, error) {
+	if len(params.positional) > 0 {
+		return nil, fmt.Errorf("%w: Sort-Object only sorts lines", ErrOperationNotAllowed)
+	}
+	key := strings.ToLower
+	if params.has("casesensitive") {
+		key = func(line string) string { return line }
+	}
+
+	sorted := slices.Clone(lines)
+	slices.SortStableFunc(sorted, func(a, b string) int {
+		if params.has("descending") {
+			a, b = b, a
+		}
+		return strings.Compare(key(a), key(b))
+	})
+	if params.has("unique") {
+		sorted = slices.CompactFunc(sorted, func(a, b string) bool { return key(a) == key(b) })
+	}
+	return sorted, nil
+}
+
+// measureObject counts the lines, and with -Line, -Word or -Character their lines, words
+// or characters.
+func measureObject(params cmdletParams, lines []string) ([]string, error) {
+	if len(params.positional) > 0 {
+		return nil, fmt.Errorf("%w: Measure-Object only counts lines, words and characters", ErrOperationNotAllowed)
+	}
+	if !params.has("line") && !params.has("word") && !params.has("character") {
+		return []string{fmt.Sprintf("Count : %d", len(lines))}, nil
+	}
+
+	var words, characters int
+	for _, line := range lines {
+		words += len(strings.Fields(line))
+		characters += len([]rune(line))
+	}
+	var result []string
+	if params.has("line") {
+		result = append(result, fmt.Sprintf("Lines : %d", len(lines)))
+	}
+	if params.has("word") {
+		result = append(result, fmt.Sprintf("Words : %d", words))
+	}
+	if params.has("character") {
+		result = append(result, fmt.Sprintf("Characters : %d", characters))
+	}
+	return result, nil
+}
+
+// getUnique drops the lines that repeat the line before them.
+func getUnique(params cmdletParams, lines []string) ([]string, error) {
+	if len(params.positional) > 0 {
+		return nil, fmt.Errorf("%w: Get-Unique takes no arguments", ErrOperationNotAllowed)
+	}
+	return slices.Compact(slices.Clone(lines)), nil
+}
+
+// uniqueLines drops the lines seen before, keeping the order of the first ones.
+func uniqueLines(lines []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, line := range lines {
+		if !seen[line] {
+			seen[line] = true
+			unique = append(unique, line)
+		}
+	}
+	return unique
+}
+
+// runPowerShellArgs runs the commands of a PowerShell command line without a shell, each
+// with the output of the previous one as its input: programs run as with runArgs, and
+// the allowed cmdlets run in Klama on the lines of their input. A failing program stops
+// the pipeline. It returns the exit code of the last program, -1 when it did not exit.
+func runPowerShellArgs(ctx context.Context, args [][]string, env []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	input := stdin
+	code := 0
+	for i := 0; i < len(args); {
+		var output bytes.Buffer
+		if c, ok := cmdletFor(args[i][0]); ok {
+			params, err := parseCmdletArgs(args[i][0], c, args[i][1:])
+			if err != nil {
+				return -1, err
+			}
+			lines, err := readLines(input)
+			if err != nil {
+				return -1, err
+			}
+			result, err := c.run(params, lines)
+			if err != nil {
+				return -1, err
+			}
+			for _, line := range result {
+				output.WriteString(line + "\n")
+			}
+			i++
+		} else {
+			// consecutive programs are piped into each other directly
+			j := i + 1
+			for j < len(args) && !isCmdlet(args[j][0]) {
+				j++
+			}
+			var err error
+			if code, err = runArgs(ctx, args[i:j], env, input, &output, stderr); err != nil {
+				io.Copy(stdout, &output)
+				return code, err
+			}
+			i = j
+		}
+		input = &output
+	}
+
+	_, err := io.Copy(stdout, input)
+	return code, err
+}
+
+// isCmdlet reports whether the command is an allowed cmdlet.
+func isCmdlet(name string) bool {
+	_, ok := cmdletFor(name)
+	return ok
+}
+
+// readLines reads the lines of the input, without their line endings.
+func readLines(input io.Reader) ([]string, error) {
+	if input == nil {
+		return nil, nil
+	}
+	var lines []string
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	return lines, scanner.Err()
+}
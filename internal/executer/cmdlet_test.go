@@ -0,0 +1,97 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestCmdlets(t *testing.T) {
+	lines := []string{"web-0 Running", "api-0 CrashLoopBackOff", "web-1 running", "db-0 Pending", "web-1 running"}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+		wantErr  error
+	}{
+		{"Select-String", []string{"Select-String", "web"}, []string{"web-0 Running", "web-1 running", "web-1 running"}, nil},
+		{"Select-String regex and case", []string{"select-string", "-Pattern", "^w.*Running$", "-CaseSensitive"}, []string{"web-0 Running"}, nil},
+		{"Select-String simple match", []string{"Select-String", "-SimpleMatch", "-NotMatch", ".* R"}, lines, nil},
+		{"Select-Object", []string{"Select-Object", "-Skip", "1", "-First:2"}, []string{"api-0 CrashLoopBackOff", "web-1 running"}, nil},
+		{"Select-Object last unique", []string{"Select-Object", "-Unique", "-Last", "2"}, []string{"web-1 running", "db-0 Pending"}, nil},
+		{"Sort-Object", []string{"Sort-Object", "-Descending", "-Unique"}, []string{"web-1 running", "web-0 Running", "db-0 Pending", "api-0 CrashLoopBackOff"}, nil},
+		{"Measure-Object", []string{"Measure-Object"}, []string{"Count : 5"}, nil},
+		{"Measure-Object words", []string{"Measure-Object", "-Line", "-Word"}, []string{"Lines : 5", "Words : 10"}, nil},
+		{"Get-Unique", []string{"Get-Unique"}, []string{"web-0 Running", "api-0 CrashLoopBackOff", "web-1 running", "db-0 Pending", "web-1 running"}, nil},
+		{"Unknown parameter", []string{"Select-Object", "-Property", "Name"}, nil, ErrOperationNotAllowed},
+		{"Missing value", []string{"Select-Object", "-First"}, nil, ErrOperationNotAllowed},
+		{"Invalid count", []string{"Select-Object", "-First", "many"}, nil, ErrOperationNotAllowed},
+		{"Invalid pattern", []string{"Select-String", "("}, nil, ErrOperationNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ok := cmdletFor(tt.args[0])
+			if !ok {
+				t.Fatalf("cmdletFor(%q) not found", tt.args[0])
+			}
+			params, err := parseCmdletArgs(tt.args[0], c, tt.args[1:])
+			var result []string
+			if err == nil {
+				result, err = c.run(params, lines)
+			}
+			if !errors.Is(err, tt.wantErr) || tt.wantErr == nil && err != nil {
+				t.Fatalf("cmdlet error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("cmdlet result = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUnquotePowerShellWord(t *testing.T) {
+	tests := []struct {
+		word     string
+		expected string
+	}{
+		{"plain", "plain"},
+		{`'it''s "quoted"'`, `it's "quoted"`},
+		{`"double ""quoted"" 'word'"`, `double "quoted" 'word'`},
+		{`C:\temp\`, `C:\temp\`},
+		{`-o=jsonpath='{.items[*].metadata.name}'`, `-o=jsonpath={.items[*].metadata.name}`},
+	}
+
+	for _, tt := range tests {
+		if got := unquotePowerShellWord(tt.word); got != tt.expected {
+			t.Errorf("unquotePowerShellWord(%q) = %q, want %q", tt.word, got, tt.expected)
+		}
+	}
+}
+
+func TestTerminalExecuter_RunPowerShellWithoutShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses echo and grep")
+	}
+	te := NewTerminalExecuter(TerminalExecuterType{
+		AllowedCommands:      []string{"echo"},
+		AllowedPipedCommands: []string{"grep"},
+		Shell:                ShellPowerShell,
+	})
+
+	result := te.Run(context.Background(), `echo 'web-0 Running' "it''s" | grep -o Running | Select-String -SimpleMatch running | Measure-Object -Character`)
+	if result.Error != nil {
+		t.Fatalf("Run() error = %v", result.Error)
+	}
+	if result.Stdout != "Characters : 7" {
+		t.Errorf("Run() stdout = %q, want %q", result.Stdout, "Characters : 7")
+	}
+
+	// the cmdlets were checked when the command was validated
+	if err := te.Validate("echo pods | Sort-Object -Property Name"); !errors.Is(err, ErrOperationNotAllowed) {
+		t.Errorf("Validate() error = %v, want %v", err, ErrOperationNotAllowed)
+	}
+}
@@ -0,0 +1,257 @@
+package executer
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ErrResourceDenied is returned for commands that touch a denied Kubernetes resource.
+var ErrResourceDenied = fmt.Errorf("access to the resource is denied")
+
+// DefaultDeniedResources are the Kubernetes resources commands may never touch,
+// whatever the verb: secret values and service account tokens.
+var DefaultDeniedResources = []string{"secrets", "serviceaccounts/token"}
+
+// kubectlResourceAliases maps the short names of built-in resources to their
+// singular names.
+var kubectlResourceAliases = map[string]string{
+	"cm":     "configmap",
+	"cj":     "cronjob",
+	"crd":    "customresourcedefinition",
+	"cs":     "componentstatus",
+	"csr":    "certificatesigningrequest",
+	"deploy": "deployment",
+	"ds":     "daemonset",
+	"ep":     "endpoint",
+	"ev":     "event",
+	"hpa":    "horizontalpodautoscaler",
+	"ing":    "ingress",
+	"limits": "limitrange",
+	"netpol": "networkpolicy",
+	"no":     "node",
+	"ns":     "namespace",
+	"pc":     "priorityclass",
+	"pdb":    "poddisruptionbudget",
+	"po":     "pod",
+	"pv":     "persistentvolume",
+	"pvc":    "persistentvolumeclaim",
+	"quota":  "resourcequota",
+	"rc":     "replicationcontroller",
+	"rs":     "replicaset",
+	"sa":     "serviceaccount",
+	"sc":     "storageclass",
+	"sts":    "statefulset",
+	"svc":    "service",
+}
+
+// kubectlVersion matches the version in resource.version.group forms such as
+// deployments.v1.apps.
+var kubectlVersion = regexp.MustCompile(`^v\d+((alpha|beta)\d+)?$`)
+
+// resourceRef is a Kubernetes resource, with its API group and subresource when known.
+type resourceRef struct {
+	Name        string // singular name, such as secret
+	Group       string
+	Subresource string
+}
+
+func (r resourceRef) String() string {
+	name := r.Name
+	if r.Group != "" {
+		name += "." + r.Group
+	}
+	if r.Subresource != "" {
+		name += "/" + r.Subresource
+	}
+	return name
+}
+
+// parseResource parses a resource as kubectl accepts it, such as secret, secrets,
+// deploy, deployments.v1.apps or certificates.cert-manager.io, or a deny pattern with
+// a subresource, such as serviceaccounts/token.
+func parseResource(resource string) resourceRef {
+	resource, subresource, _ := strings.Cut(strings.ToLower(resource), "/")
+	name, group, _ := strings.Cut(resource, ".")
+	if version, rest, _ := strings.Cut(group, "."); kubectlVersion.MatchString(version) {
+		group = rest
+	}
+
+	if alias, ok := kubectlResourceAliases[name]; ok {
+		name = alias
+	}
+	return resourceRef{Name: singular(name), Group: strings.Trim(group, "."), Subresource: subresource}
+}
+
+// singular returns the singular form of a plural resource name.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "uses"), strings.HasSuffix(name, "ches"), strings.HasSuffix(name, "shes"), strings.HasSuffix(name, "xes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && !strings.HasSuffix(name, "us"):
+		return strings.TrimSuffix(name, "s")
+	default:
+		return name
+	}
+}
+
+// matches reports whether the resource matches the deny pattern. A pattern without a
+// group matches the resource in every group, and a pattern without a subresource also
+// matches its subresources. The name and group may be glob patterns.
+func (r resourceRef) matches(pattern resourceRef) bool {
+	if ok, _ := path.Match(pattern.Name, r.Name); !ok {
+		return false
+	}
+	if pattern.Group != "" {
+		if ok, _ := path.Match(pattern.Group, r.Group); !ok {
+			return false
+		}
+	}
+	return pattern.Subresource == "" || pattern.Subresource == r.Subresource
+}
+
+// ValidateResourcePatterns checks the syntax of deny patterns.
+func ValidateResourcePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		ref := parseResource(pattern)
+		if ref.Name == "" {
+			return fmt.Errorf("invalid resource pattern %q", pattern)
+		}
+		if _, err := path.Match(ref.Name+"."+ref.Group, ""); err != nil {
+			return fmt.Errorf("invalid resource pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// deniedResource returns an error when one of the resources matches a deny pattern.
+func deniedResource(resources []resourceRef, patterns []string) error {
+	for _, resource := range resources {
+		for _, pattern := range patterns {
+			if resource.matches(parseResource(pattern)) {
+				return fmt.Errorf("%w: %s matches %q", ErrResourceDenied, resource, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// kubectlReadResources returns the resources a read-only kubectl command touches:
+// the targets of get and describe, their --subresource, and the resource of a --raw
+// API path.
+func kubectlReadResources(args kubectlArgs) ([]resourceRef, error) {
+	var resources []resourceRef
+	if raw, ok := args.Flags["--raw"]; ok {
+		ref, ok, err := rawPathResource(raw)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			resources = append(resources, ref)
+		}
+	}
+
+	switch args.SubCommand {
+	case "get", "describe":
+		for _, target := range args.targets() {
+			ref := parseResource(target.Resource)
+			ref.Subresource = args.Flags["--subresource"]
+			resources = append(resources, ref)
+		}
+	}
+	return resources, nil
+}
+
+// rawPathResource returns the resource of an API path, such as
+// /api/v1/namespaces/default/serviceaccounts/web/token. The path is unescaped and
+// cleaned first, as the API server does, so pods/../secrets or %73ecrets name secrets.
+func rawPathResource(raw string) (resourceRef, bool, error) {
+	raw, _, _ = strings.Cut(raw, "?")
+	raw, err := url.PathUnescape(raw)
+	if err != nil {
+		return resourceRef{}, false, fmt.Errorf("%w: --raw path %v", ErrOperationNotAllowed, err)
+	}
+	segments := strings.Split(strings.Trim(path.Clean("/"+raw), "/"), "/")
+
+	var group string
+	switch {
+	case len(segments) > 2 && segments[0] == "api":
+		segments = segments[2:]
+	case len(segments) > 3 && segments[0] == "apis":
+		group, segments = segments[1], segments[3:]
+	default:
+		return resourceRef{}, false, nil
+	}
+	if len(segments) > 2 && segments[0] == "namespaces" {
+		segments = segments[2:]
+	}
+
+	ref := resourceRef{Name: singular(segments[0]), Group: group}
+	if len(segments) > 2 {
+		ref.Subresource = segments[2]
+	}
+	return ref, true, nil
+}
+
+// kubectlMutationResources returns the resources a kubectl mutation touches: its
+// target, and the resource created by create forms such as create secret or create
+// token.
+func kubectlMutationResources(args []string, target MutationTarget) []resourceRef {
+	resources := []resourceRef{parseResource(target.Kind)}
+	if len(args) > 1 && args[0] == "create" {
+		switch args[1] {
+		case "token":
+			resources = append(resources, resourceRef{Name: "serviceaccount", Subresource: "token"})
+		default:
+			resources = append(resources, parseResource(args[1]))
+		}
+	}
+	return resources
+}
+
+// denyKubectlResources returns a validator that rejects kubectl commands reading a
+// resource that matches one of the patterns.
+func denyKubectlResources(patterns []string) func(Command) error {
+	return func(cmd Command) error {
+		if cmd.Parts[0] != "kubectl" {
+			return nil
+		}
+		args, err := parseKubectlArgs(cmd)
+		if err != nil {
+			return err
+		}
+		resources, err := kubectlReadResources(args)
+		if err != nil {
+			return err
+		}
+		return deniedResource(resources, patterns)
+	}
+}
+
+// DenyKubernetesResources returns the executer type with kubectl commands that read a
+// resource matching one of the patterns rejected, in addition to what the type already
+// rejects, such as DefaultDeniedResources for KubernetesExecuterType. Patterns are
+// resources as kubectl accepts them, with an optional group and subresource, such as
+// configmaps, *.cert-manager.io or serviceaccounts/token.
+func DenyKubernetesResources(executerType TerminalExecuterType, patterns []string) TerminalExecuterType {
+	if len(patterns) == 0 {
+		return executerType
+	}
+
+	deny := denyKubectlResources(patterns)
+	validate := executerType.ValidateCommand
+	executerType.ValidateCommand = func(cmd Command) error {
+		if err := deny(cmd); err != nil {
+			return err
+		}
+		if validate != nil {
+			return validate(cmd)
+		}
+		return nil
+	}
+	return executerType
+}
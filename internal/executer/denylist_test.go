@@ -0,0 +1,67 @@
+package executer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKubernetesExecuter_DeniedResources(t *testing.T) {
+	te := NewTerminalExecuter(DenyKubernetesResources(KubernetesExecuterType, []string{"configmaps", "*.cert-manager.io"}))
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"Pods", "kubectl get pods -A", nil},
+		{"Service accounts", "kubectl get sa default -o yaml", nil},
+		{"Certificates in another group", "kubectl get certificates.networking.example.com", nil},
+		{"Secrets", "kubectl get secrets -A", ErrResourceDenied},
+		{"Secret by name", "kubectl describe secret db -n shop", ErrResourceDenied},
+		{"Secret by kind and name", "kubectl get pods/web secret/db", ErrResourceDenied},
+		{"Secrets in a list", "kubectl get pods,secrets -o yaml", ErrResourceDenied},
+		{"Quoted secrets", `kubectl get secre"ts" -A`, ErrResourceDenied},
+		{"Secrets with a version", "kubectl get secrets.v1. -o json", ErrResourceDenied},
+		{"Raw secret path", "kubectl get --raw /api/v1/namespaces/shop/secrets/db", ErrResourceDenied},
+		{"Raw token path", "kubectl get --raw=/api/v1/namespaces/shop/serviceaccounts/web/token", ErrResourceDenied},
+		{"Raw path with dot dot", "kubectl get --raw /api/v1/namespaces/default/pods/../secrets", ErrResourceDenied},
+		{"Raw path with dot dot after the version", "kubectl get --raw /api/v1/namespaces/../secrets", ErrResourceDenied},
+		{"Raw path with escapes", "kubectl get --raw /api/v1/namespaces/default/%73ecrets", ErrResourceDenied},
+		{"Raw path with an escaped slash", "kubectl get --raw /api/v1/namespaces/default/pods%2F..%2Fsecrets", ErrResourceDenied},
+		{"Raw path with an invalid escape", "kubectl get --raw /api/v1/namespaces/default/%zzsecrets", ErrOperationNotAllowed},
+		{"Raw pods path", "kubectl get --raw /api/v1/namespaces/default/pods", nil},
+		{"Token subresource", "kubectl get sa web --subresource token", ErrResourceDenied},
+		{"Quoted raw path", `kubectl get "--raw=/api/v1/namespaces/default/secrets"`, ErrResourceDenied},
+		{"Quoted raw flag", `kubectl get "--raw" /api/v1/namespaces/default/secrets`, ErrResourceDenied},
+		{"Partly quoted raw flag", `kubectl get --r'aw' '/api/v1/namespaces/default/secrets'`, ErrResourceDenied},
+		{"Quoted subresource", `kubectl get sa web "--subresource=token"`, ErrResourceDenied},
+		{"Quoted subresource flag", `kubectl get sa web '--subresource' "token"`, ErrResourceDenied},
+		{"Configured short name", "kubectl get cm -n shop", ErrResourceDenied},
+		{"Configured group pattern", "kubectl get certificates.cert-manager.io -A", ErrResourceDenied},
+		{"Quoted secrets", `kubectl get "secrets"`, ErrResourceDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := te.Validate(tt.command)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Validate() error = %v, want nil", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateResourcePatterns(t *testing.T) {
+	if err := ValidateResourcePatterns([]string{"configmaps", "*.cert-manager.io", "serviceaccounts/token"}); err != nil {
+		t.Errorf("ValidateResourcePatterns() error = %v", err)
+	}
+	if err := ValidateResourcePatterns([]string{"[secrets"}); err == nil {
+		t.Error("ValidateResourcePatterns() expected an error for a bad glob")
+	}
+	if err := ValidateResourcePatterns([]string{".apps"}); err == nil {
+		t.Error("ValidateResourcePatterns() expected an error for a pattern without a resource")
+	}
+}
@@ -0,0 +1,153 @@
+package executer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// diffContextLines is how many unchanged lines are shown around each change.
+const diffContextLines = 3
+
+// DiffExecuter adds the diff command to an executer: `diff '<command>' '<command>'` runs
+// both commands with the wrapped executer and answers with a unified diff of their
+// outputs, so two resources are compared without reading both documents. Every other
+// command runs with the wrapped executer, which also validates the compared commands.
+type DiffExecuter struct {
+	CachingExecuter
+}
+
+// NewDiffExecuter wraps the executer to answer diff commands.
+func NewDiffExecuter(executer CachingExecuter) *DiffExecuter {
+	return &DiffExecuter{CachingExecuter: executer}
+}
+
+// diffCommands returns the two commands a diff command compares. ok is false for other
+// commands.
+func diffCommands(command string) (left, right string, ok bool, err error) {
+	cmds := splitPipeline(command, ShellPOSIX)
+	if len(cmds) == 0 || len(cmds[0].Parts) == 0 || cmds[0].Parts[0] != "diff" {
+		return "", "", false, nil
+	}
+	if len(cmds) > 1 {
+		return "", "", true, fmt.Errorf("%w: diff cannot be piped", ErrOperationNotAllowed)
+	}
+	if len(cmds[0].Parts) != 3 {
+		return "", "", true, fmt.Errorf("%w: diff takes two quoted commands, such as diff 'kubectl get deploy web -o yaml' 'kubectl get pod web-0 -o yaml'", ErrInvalidMainCommand)
+	}
+	return unquote(cmds[0].Parts[1]), unquote(cmds[0].Parts[2]), true, nil
+}
+
+// Validate checks that the wrapped executer accepts both commands of a diff command, and
+// other commands with the wrapped executer.
+func (dx *DiffExecuter) Validate(command string) error {
+	left, right, ok, err := diffCommands(command)
+	if !ok {
+		return dx.CachingExecuter.Validate(command)
+	}
+	if err != nil {
+		return err
+	}
+	for _, cmd := range []string{left, right} {
+		if _, _, nested, _ := diffCommands(cmd); nested {
+			return fmt.Errorf("%w: diff cannot compare diff commands", ErrOperationNotAllowed)
+		}
+		if err := dx.CachingExecuter.Validate(cmd); err != nil {
+			return fmt.Errorf("diff command %q: %w", cmd, err)
+		}
+	}
+	return nil
+}
+
+// Run answers diff commands with a unified diff of the outputs of both commands, and
+// runs other commands with the wrapped executer.
+func (dx *DiffExecuter) Run(ctx context.Context, command string) (response ExecuterResponse) {
+	left, right, ok, err := diffCommands(command)
+	if !ok {
+		return dx.CachingExecuter.Run(ctx, command)
+	}
+
+	ctx, span := startSpan(ctx, command)
+	defer func() { endSpan(span, response) }()
+
+	if err != nil {
+		return ExecuterResponse{Stderr: err.Error(), ExitCode: -1, Error: err}
+	}
+
+	var outputs []string
+	var stderr []string
+	var duration time.Duration
+	cached := true
+	for _, cmd := range []string{left, right} {
+		result := dx.CachingExecuter.Run(ctx, cmd)
+		if result.Error != nil {
+			result.Error = fmt.Errorf("%s: %w", cmd, result.Error)
+			return result
+		}
+		outputs = append(outputs, result.Stdout)
+		if result.Stderr != "" {
+			stderr = append(stderr, cmd+":\n"+result.Stderr)
+		}
+		duration += result.Duration
+		cached = cached && result.Cached
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(outputs[0]),
+		B:        difflib.SplitLines(outputs[1]),
+		FromFile: left,
+		ToFile:   right,
+		Context:  diffContextLines,
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to compare the outputs: %w", err)
+		return ExecuterResponse{Stderr: err.Error(), ExitCode: -1, Error: err}
+	}
+	if diff == "" {
+		diff = "The outputs of both commands are identical."
+	}
+
+	return ExecuterResponse{
+		Stdout:   strings.TrimSpace(diff),
+		Stderr:   strings.Join(stderr, "\n"),
+		Duration: duration,
+		Cached:   cached,
+	}
+}
+
+// Preflight checks both commands of a diff command, and other commands with the wrapped
+// executer.
+func (dx *DiffExecuter) Preflight(ctx context.Context, command string) (string, error) {
+	left, right, ok, err := diffCommands(command)
+	if !ok {
+		return dx.CachingExecuter.Preflight(ctx, command)
+	}
+	if err != nil {
+		return "", nil
+	}
+
+	var warnings []string
+	for _, cmd := range []string{left, right} {
+		warning, err := dx.CachingExecuter.Preflight(ctx, cmd)
+		if err != nil {
+			return "", err
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	return strings.Join(warnings, "\n"), nil
+}
+
+// EffectiveCommand returns the command line that is executed for command, with both
+// commands of a diff command as the wrapped executer runs them.
+func (dx *DiffExecuter) EffectiveCommand(command string) string {
+	left, right, ok, err := diffCommands(command)
+	if !ok || err != nil {
+		return dx.CachingExecuter.EffectiveCommand(command)
+	}
+	return "diff " + shellQuote(dx.CachingExecuter.EffectiveCommand(left)) + " " + shellQuote(dx.CachingExecuter.EffectiveCommand(right))
+}
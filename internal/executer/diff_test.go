@@ -0,0 +1,74 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDiffExecuter_Validate(t *testing.T) {
+	dx := NewDiffExecuter(NewTerminalExecuter(KubernetesExecuterType))
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"Diff", `diff 'kubectl get deploy web -o yaml' 'kubectl get pod web-0 -o yaml'`, nil},
+		{"Double quotes", `diff "kubectl get cm a -o yaml" "kubectl get cm b -o yaml"`, nil},
+		{"Other commands", `kubectl get pods`, nil},
+		{"One command", `diff 'kubectl get deploy web -o yaml'`, ErrInvalidMainCommand},
+		{"Unquoted commands", `diff kubectl get deploy web`, ErrInvalidMainCommand},
+		{"Piped", `diff 'kubectl get deploy a' 'kubectl get deploy b' | head`, ErrOperationNotAllowed},
+		{"Denied command", `diff 'kubectl get deploy web' 'kubectl delete deploy web'`, ErrSubCommandNotAllowed},
+		{"Denied resource", `diff 'kubectl get secret a -o yaml' 'kubectl get secret b -o yaml'`, ErrResourceDenied},
+		{"Nested diff", `diff "diff 'kubectl get deploy a' 'kubectl get deploy b'" 'kubectl get deploy c'`, ErrOperationNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dx.Validate(tt.command)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate(%q) = %v, want %v", tt.command, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiffExecuter_Run(t *testing.T) {
+	te := NewTerminalExecuter(KubernetesExecuterType)
+	te.RestoreExecutedCommands(map[string]string{
+		"kubectl get deploy web -o yaml": "spec:\n  replicas: 3\n  image: web:1.2\n  port: 8080",
+		"kubectl get pod web-0 -o yaml":  "spec:\n  replicas: 3\n  image: web:1.1\n  port: 8080",
+	})
+	dx := NewDiffExecuter(te)
+
+	response := dx.Run(context.Background(), `diff 'kubectl get deploy web -o yaml' 'kubectl get pod web-0 -o yaml'`)
+	if response.Error != nil {
+		t.Fatalf("Run() error = %v", response.Error)
+	}
+	want := "--- kubectl get deploy web -o yaml\n+++ kubectl get pod web-0 -o yaml\n@@ -1,4 +1,4 @@\n spec:\n   replicas: 3\n-  image: web:1.2\n+  image: web:1.1\n   port: 8080"
+	if response.Stdout != want {
+		t.Errorf("Run() stdout =\n%s\nwant\n%s", response.Stdout, want)
+	}
+	if !response.Cached {
+		t.Error("Run() of cached commands is not marked as cached")
+	}
+
+	response = dx.Run(context.Background(), `diff 'kubectl get deploy web -o yaml' 'kubectl get deploy web -o yaml'`)
+	if response.Stdout != "The outputs of both commands are identical." {
+		t.Errorf("Run() of identical outputs = %q", response.Stdout)
+	}
+}
+
+func TestDiffExecuter_EffectiveCommand(t *testing.T) {
+	executerType := KubernetesExecuterType
+	executerType.RewriteCommand = KubectlScope{Context: "prod"}.Apply
+	dx := NewDiffExecuter(NewTerminalExecuter(executerType))
+
+	got := dx.EffectiveCommand(`diff 'kubectl get deploy web' 'kubectl get pod web-0'`)
+	want := "diff 'kubectl get deploy web --context=prod' 'kubectl get pod web-0 --context=prod'"
+	if got != want {
+		t.Errorf("EffectiveCommand() = %q, want %q", got, want)
+	}
+}
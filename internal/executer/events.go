@@ -0,0 +1,314 @@
+package executer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxEvents is the most condensed events the events command returns, the most recent.
+const maxEvents = 30
+
+// eventKinds maps the resource names and short names kubectl accepts to the kind of the
+// objects events are about.
+var eventKinds = map[string]string{
+	"po": "Pod", "pod": "Pod", "pods": "Pod",
+	"deploy": "Deployment", "deployment": "Deployment", "deployments": "Deployment",
+	"rs": "ReplicaSet", "replicaset": "ReplicaSet", "replicasets": "ReplicaSet",
+	"sts": "StatefulSet", "statefulset": "StatefulSet", "statefulsets": "StatefulSet",
+	"ds": "DaemonSet", "daemonset": "DaemonSet", "daemonsets": "DaemonSet",
+	"job": "Job", "jobs": "Job",
+	"cj": "CronJob", "cronjob": "CronJob", "cronjobs": "CronJob",
+	"no": "Node", "node": "Node", "nodes": "Node",
+	"svc": "Service", "service": "Service", "services": "Service",
+	"pvc": "PersistentVolumeClaim", "persistentvolumeclaim": "PersistentVolumeClaim", "persistentvolumeclaims": "PersistentVolumeClaim",
+	"pv": "PersistentVolume", "persistentvolume": "PersistentVolume", "persistentvolumes": "PersistentVolume",
+	"hpa": "HorizontalPodAutoscaler", "horizontalpodautoscaler": "HorizontalPodAutoscaler", "horizontalpodautoscalers": "HorizontalPodAutoscaler",
+	"ing": "Ingress", "ingress": "Ingress", "ingresses": "Ingress",
+}
+
+// EventsExecuter adds the events command to an executer: `events [-n <namespace>]
+// [<kind>/<name>]` gets the events of the namespace, or of one object, sorted by time
+// with the wrapped executer, and answers with a condensed list in which repeated events
+// are merged. Every other command runs with the wrapped executer.
+type EventsExecuter struct {
+	CachingExecuter
+}
+
+// NewEventsExecuter wraps the executer to answer events commands.
+func NewEventsExecuter(executer CachingExecuter) *EventsExecuter {
+	return &EventsExecuter{CachingExecuter: executer}
+}
+
+// eventsQuery is a parsed events command.
+type eventsQuery struct {
+	Namespace string
+	Kind      string // kind of the object, empty for all the events of the namespace
+	Name      string
+}
+
+// parseEventsCommand parses an events command. ok is false for other commands.
+func parseEventsCommand(command string) (query eventsQuery, ok bool, err error) {
+	cmds := splitPipeline(command, ShellPOSIX)
+	if len(cmds) == 0 || len(cmds[0].Parts) == 0 || cmds[0].Parts[0] != "events" {
+		return eventsQuery{}, false, nil
+	}
+	if len(cmds) > 1 {
+		return eventsQuery{}, true, fmt.Errorf("%w: events cannot be piped", ErrOperationNotAllowed)
+	}
+
+	usage := fmt.Errorf("%w: use events [-n <namespace>] [<kind>/<name>], such as events -n shop pod/web-0", ErrInvalidMainCommand)
+	args := unquoteAll(cmds[0].Parts[1:])
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-n" || arg == "--namespace":
+			if i+1 >= len(args) || query.Namespace != "" {
+				return eventsQuery{}, true, usage
+			}
+			i++
+			query.Namespace = args[i]
+		case strings.HasPrefix(arg, "--namespace="):
+			query.Namespace = strings.TrimPrefix(arg, "--namespace=")
+		case strings.HasPrefix(arg, "-") || query.Name != "":
+			return eventsQuery{}, true, usage
+		default:
+			resource, name, found := strings.Cut(arg, "/")
+			kind, known := eventKinds[strings.ToLower(resource)]
+			if !found || name == "" {
+				return eventsQuery{}, true, usage
+			}
+			if !known {
+				return eventsQuery{}, true, fmt.Errorf("%w: events of %s are not supported", ErrInvalidMainCommand, resource)
+			}
+			query.Kind, query.Name = kind, name
+		}
+	}
+	return query, true, nil
+}
+
+// kubectlCommand returns the kubectl command that gets the events of the query.
+func (q eventsQuery) kubectlCommand() string {
+	command := "kubectl get events"
+	if q.Namespace != "" {
+		command += " -n " + shellQuote(q.Namespace)
+	}
+	if q.Name != "" {
+		command += " --field-selector " + shellQuote("involvedObject.kind="+q.Kind+",involvedObject.name="+q.Name)
+	}
+	return command + " --sort-by=.lastTimestamp -o json"
+}
+
+// Validate checks that the wrapped executer accepts the kubectl command of an events
+// command, and other commands with the wrapped executer.
+func (ex *EventsExecuter) Validate(command string) error {
+	query, ok, err := parseEventsCommand(command)
+	if !ok {
+		return ex.CachingExecuter.Validate(command)
+	}
+	if err != nil {
+		return err
+	}
+	return ex.CachingExecuter.Validate(query.kubectlCommand())
+}
+
+// Run answers events commands with the condensed events, and runs other commands with
+// the wrapped executer.
+func (ex *EventsExecuter) Run(ctx context.Context, command string) (response ExecuterResponse) {
+	query, ok, err := parseEventsCommand(command)
+	if !ok {
+		return ex.CachingExecuter.Run(ctx, command)
+	}
+
+	ctx, span := startSpan(ctx, command)
+	defer func() { endSpan(span, response) }()
+
+	if err != nil {
+		return ExecuterResponse{Stderr: err.Error(), ExitCode: -1, Error: err}
+	}
+
+	response = ex.CachingExecuter.Run(ctx, query.kubectlCommand())
+	if response.Error != nil {
+		return response
+	}
+
+	condensed, err := condenseEvents(response.Stdout)
+	if err != nil {
+		err = fmt.Errorf("failed to read the events: %w", err)
+		return ExecuterResponse{Stderr: err.Error(), ExitCode: 1, Error: err}
+	}
+	response.Stdout = condensed
+	return response
+}
+
+// Preflight checks the kubectl command of an events command, and other commands with the
+// wrapped executer.
+func (ex *EventsExecuter) Preflight(ctx context.Context, command string) (string, error) {
+	query, ok, err := parseEventsCommand(command)
+	if !ok {
+		return ex.CachingExecuter.Preflight(ctx, command)
+	}
+	if err != nil {
+		return "", nil
+	}
+	return ex.CachingExecuter.Preflight(ctx, query.kubectlCommand())
+}
+
+// EffectiveCommand returns the command line that is executed for command, the kubectl
+// command of an events command.
+func (ex *EventsExecuter) EffectiveCommand(command string) string {
+	query, ok, err := parseEventsCommand(command)
+	if !ok || err != nil {
+		return ex.CachingExecuter.EffectiveCommand(command)
+	}
+	return ex.CachingExecuter.EffectiveCommand(query.kubectlCommand())
+}
+
+// BuiltinTool reports whether the command is an events command, a read-only tool that
+// runs without confirmation in autopilot.
+func (ex *EventsExecuter) BuiltinTool(command string) bool {
+	_, ok, err := parseEventsCommand(command)
+	return ok && err == nil
+}
+
+// Mutation returns the resource the command changes when the wrapped executer runs
+// mutations, such as the mutations of fix mode.
+func (ex *EventsExecuter) Mutation(command string) (MutationTarget, bool) {
+	if checker, ok := ex.CachingExecuter.(interface {
+		Mutation(string) (MutationTarget, bool)
+	}); ok {
+		return checker.Mutation(command)
+	}
+	return MutationTarget{}, false
+}
+
+// Interactive reports whether the wrapped executer runs the command in the terminal.
+func (ex *EventsExecuter) Interactive(command string) bool {
+	runner, ok := ex.CachingExecuter.(interactiveRunner)
+	return ok && runner.Interactive(command)
+}
+
+// InteractiveCommand returns the process of an interactive command of the wrapped
+// executer.
+func (ex *EventsExecuter) InteractiveCommand(command string) (*exec.Cmd, error) {
+	runner, ok := ex.CachingExecuter.(interactiveRunner)
+	if !ok {
+		return nil, ErrInteractiveCommand
+	}
+	return runner.InteractiveCommand(command)
+}
+
+// interactiveRunner is implemented by executers that run commands in the terminal.
+type interactiveRunner interface {
+	Interactive(string) bool
+	InteractiveCommand(string) (*exec.Cmd, error)
+}
+
+// event is the part of a Kubernetes event the condensed list shows.
+type event struct {
+	Type           string `json:"type"`
+	Reason         string `json:"reason"`
+	Message        string `json:"message"`
+	Count          int    `json:"count"`
+	FirstTimestamp string `json:"firstTimestamp"`
+	LastTimestamp  string `json:"lastTimestamp"`
+	EventTime      string `json:"eventTime"`
+	Series         *struct {
+		Count            int    `json:"count"`
+		LastObservedTime string `json:"lastObservedTime"`
+	} `json:"series"`
+	InvolvedObject struct {
+		Kind      string `json:"kind"`
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+	} `json:"involvedObject"`
+}
+
+// last returns when the event was last seen, zero when it is unknown.
+func (e event) last() time.Time {
+	for _, value := range []string{e.seriesTime(), e.LastTimestamp, e.EventTime, e.FirstTimestamp} {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (e event) seriesTime() string {
+	if e.Series == nil {
+		return ""
+	}
+	return e.Series.LastObservedTime
+}
+
+// count returns how many times the event occurred.
+func (e event) count() int {
+	if e.Series != nil && e.Series.Count > 0 {
+		return e.Series.Count
+	}
+	return max(e.Count, 1)
+}
+
+// condensedEvent is an event merged with its repetitions.
+type condensedEvent struct {
+	event
+	last  time.Time
+	count int
+}
+
+// condenseEvents merges the events of a kubectl get events -o json output that only
+// differ in their time, and lists the most recent ones, oldest first, one per line.
+func condenseEvents(output string) (string, error) {
+	var list struct {
+		Items []event `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(output), &list); err != nil {
+		return "", err
+	}
+	if len(list.Items) == 0 {
+		return "No events found.", nil
+	}
+
+	var condensed []*condensedEvent
+	merged := map[string]*condensedEvent{}
+	for _, item := range list.Items {
+		key := strings.Join([]string{item.Type, item.Reason, item.InvolvedObject.Kind, item.InvolvedObject.Namespace, item.InvolvedObject.Name, item.Message}, "\x00")
+		if e, ok := merged[key]; ok {
+			e.count += item.count()
+			if last := item.last(); last.After(e.last) {
+				e.last = last
+			}
+			continue
+		}
+		e := &condensedEvent{event: item, last: item.last(), count: item.count()}
+		merged[key] = e
+		condensed = append(condensed, e)
+	}
+	sort.SliceStable(condensed, func(i, j int) bool { return condensed[i].last.Before(condensed[j].last) })
+
+	var b strings.Builder
+	if omitted := len(condensed) - maxEvents; omitted > 0 {
+		fmt.Fprintf(&b, "%d older events omitted.\n", omitted)
+		condensed = condensed[omitted:]
+	}
+	for _, e := range condensed {
+		when := "unknown time"
+		if !e.last.IsZero() {
+			when = e.last.UTC().Format(time.RFC3339)
+		}
+		object := strings.ToLower(e.InvolvedObject.Kind) + "/" + e.InvolvedObject.Name
+		if e.InvolvedObject.Namespace != "" {
+			object = e.InvolvedObject.Namespace + "/" + object
+		}
+		fmt.Fprintf(&b, "%s %s %s %s", when, e.Type, e.Reason, object)
+		if e.count > 1 {
+			fmt.Fprintf(&b, " (x%d)", e.count)
+		}
+		fmt.Fprintf(&b, ": %s\n", strings.Join(strings.Fields(e.Message), " "))
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
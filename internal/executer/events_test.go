@@ -0,0 +1,101 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseEventsCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    eventsQuery
+		ok      bool
+		wantErr error
+	}{
+		{"Namespace", "events -n shop", eventsQuery{Namespace: "shop"}, true, nil},
+		{"Object", "events --namespace=shop deploy/web", eventsQuery{Namespace: "shop", Kind: "Deployment", Name: "web"}, true, nil},
+		{"Current namespace", "events pod/web-0", eventsQuery{Kind: "Pod", Name: "web-0"}, true, nil},
+		{"Other command", "kubectl get events", eventsQuery{}, false, nil},
+		{"Unknown kind", "events -n shop widget/a", eventsQuery{}, true, ErrInvalidMainCommand},
+		{"Object without a kind", "events web-0", eventsQuery{}, true, ErrInvalidMainCommand},
+		{"Flag", "events -A", eventsQuery{}, true, ErrInvalidMainCommand},
+		{"Piped", "events -n shop | grep Warning", eventsQuery{}, true, ErrOperationNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := parseEventsCommand(tt.command)
+			if ok != tt.ok || !errors.Is(err, tt.wantErr) || tt.wantErr == nil && err != nil {
+				t.Fatalf("parseEventsCommand(%q) = %v, %v, want %v, %v", tt.command, ok, err, tt.ok, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseEventsCommand(%q) = %+v, want %+v", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventsExecuter(t *testing.T) {
+	te := NewTerminalExecuter(KubernetesExecuterType)
+	ex := NewEventsExecuter(te)
+
+	command := "events -n shop pod/web-0"
+	kubectl := "kubectl get events -n shop --field-selector 'involvedObject.kind=Pod,involvedObject.name=web-0' --sort-by=.lastTimestamp -o json"
+	if got := ex.EffectiveCommand(command); got != kubectl {
+		t.Errorf("EffectiveCommand() = %q, want %q", got, kubectl)
+	}
+	if err := ex.Validate(command); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if !ex.BuiltinTool(command) || ex.BuiltinTool("kubectl get pods") {
+		t.Error("BuiltinTool() must only report events commands")
+	}
+
+	te.RestoreExecutedCommands(map[string]string{kubectl: `{"items": [
+		{"type": "Normal", "reason": "Pulled", "message": "Container image pulled", "count": 1, "lastTimestamp": "2024-05-01T10:00:00Z",
+		 "involvedObject": {"kind": "Pod", "namespace": "shop", "name": "web-0"}},
+		{"type": "Warning", "reason": "BackOff", "message": "Back-off restarting\n failed container", "count": 4, "lastTimestamp": "2024-05-01T10:05:00Z",
+		 "involvedObject": {"kind": "Pod", "namespace": "shop", "name": "web-0"}},
+		{"type": "Warning", "reason": "BackOff", "message": "Back-off restarting\n failed container", "eventTime": "2024-05-01T10:09:00Z",
+		 "series": {"count": 3, "lastObservedTime": "2024-05-01T10:10:00Z"},
+		 "involvedObject": {"kind": "Pod", "namespace": "shop", "name": "web-0"}}
+	]}`})
+
+	response := ex.Run(context.Background(), command)
+	if response.Error != nil {
+		t.Fatalf("Run() error = %v", response.Error)
+	}
+	want := "2024-05-01T10:00:00Z Normal Pulled shop/pod/web-0: Container image pulled\n" +
+		"2024-05-01T10:10:00Z Warning BackOff shop/pod/web-0 (x7): Back-off restarting failed container"
+	if response.Stdout != want {
+		t.Errorf("Run() stdout =\n%s\nwant\n%s", response.Stdout, want)
+	}
+}
+
+func TestCondenseEvents(t *testing.T) {
+	got, err := condenseEvents(`{"items": []}`)
+	if err != nil || got != "No events found." {
+		t.Errorf("condenseEvents() of no events = %q, %v", got, err)
+	}
+
+	var items []string
+	for i := range maxEvents + 2 {
+		items = append(items, fmt.Sprintf(`{"type": "Normal", "reason": "Scheduled", "message": "pod %d", "lastTimestamp": "2024-05-01T10:%02d:00Z", "involvedObject": {"kind": "Pod", "name": "web-%d"}}`, i, i, i))
+	}
+	got, err = condenseEvents(`{"items": [` + strings.Join(items, ",") + `]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(got, "\n")
+	if len(lines) != maxEvents+1 || lines[0] != "2 older events omitted." || !strings.HasSuffix(lines[1], "pod/web-2: pod 2") {
+		t.Errorf("condenseEvents() did not keep the most recent events:\n%s", got)
+	}
+
+	if _, err := condenseEvents("No resources found"); err == nil {
+		t.Error("condenseEvents() of a table must fail")
+	}
+}
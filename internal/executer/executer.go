@@ -1,7 +1,89 @@
 package executer
 
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
 // ExecuterResponse represents the response from the executer
 type ExecuterResponse struct {
-	Result string
-	Error  error
+	Stdout   string
+	Stderr   string        // warnings and error messages, kept apart from the data
+	ExitCode int           // exit code of the command, -1 when it did not exit, such as on a timeout
+	Duration time.Duration // how long the command ran, 0 for cached results
+	Error    error
+
+	Cached   bool      // whether the result was answered from the cache instead of running the command
+	CachedAt time.Time // when a cached result was produced, zero when unknown
+}
+
+// CacheNote describes a cached result, such as " (cached 2m5s ago)", and is empty for a
+// command that ran.
+func (r ExecuterResponse) CacheNote() string {
+	switch {
+	case !r.Cached:
+		return ""
+	case r.CachedAt.IsZero():
+		return " (cached)"
+	default:
+		return fmt.Sprintf(" (cached %s ago)", time.Since(r.CachedAt).Round(time.Second))
+	}
+}
+
+// details describes how a command ran, its exit code and duration, or the age of a
+// cached result.
+func (r ExecuterResponse) details() string {
+	switch {
+	case r.Cached:
+		return r.CacheNote()
+	case r.Duration > 0:
+		return fmt.Sprintf(" (exit code %d, took %s)", r.ExitCode, r.Duration.Round(time.Millisecond))
+	case r.ExitCode != 0:
+		return fmt.Sprintf(" (exit code %d)", r.ExitCode)
+	default:
+		return ""
+	}
+}
+
+// FormatOutput formats the outcome of a command for the chat and the agent, with its
+// stdout and stderr, which may have been redacted or shrunk since.
+func FormatOutput(r ExecuterResponse, stdout, stderr string) string {
+	var b strings.Builder
+	if r.Error != nil {
+		fmt.Fprintf(&b, "Error executing command: %v%s\n", r.Error, r.details())
+		if stdout != "" {
+			fmt.Fprintf(&b, "Stdout:\n%s\n", stdout)
+		}
+		if stderr != "" {
+			fmt.Fprintf(&b, "Stderr:\n%s\n", stderr)
+		}
+		b.WriteString("FOLLOW YOUR GUIDELINES")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "Command output%s:\n%s", r.details(), stdout)
+	if stderr != "" {
+		fmt.Fprintf(&b, "\nStderr:\n%s", stderr)
+	}
+	return b.String()
+}
+
+// apiResponse returns the response of a command answered without running a process,
+// such as from the Kubernetes API. Like a CLI, a failure has exit code 1 and its error
+// message on stderr.
+func apiResponse(ctx context.Context, stdout string, err error, duration time.Duration) ExecuterResponse {
+	response := ExecuterResponse{Stdout: stdout, Duration: duration}
+	switch {
+	case err == nil:
+	case ctx.Err() == context.DeadlineExceeded:
+		response.ExitCode = -1
+		response.Error = fmt.Errorf("command execution timed out: %w", ctx.Err())
+	default:
+		response.ExitCode = 1
+		response.Stderr = err.Error()
+		response.Error = fmt.Errorf("command execution failed: %w", err)
+	}
+	return response
 }
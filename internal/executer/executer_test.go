@@ -0,0 +1,49 @@
+package executer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFormatOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		response ExecuterResponse
+		want     string
+	}{
+		{
+			"Success",
+			ExecuterResponse{Stdout: "web Running", Duration: 1500 * time.Millisecond},
+			"Command output (exit code 0, took 1.5s):\nweb Running",
+		},
+		{
+			"Success with warnings",
+			ExecuterResponse{Stdout: "web Running", Stderr: "Warning: deprecated"},
+			"Command output:\nweb Running\nStderr:\nWarning: deprecated",
+		},
+		{
+			"Cached",
+			ExecuterResponse{Stdout: "web Running", Cached: true},
+			"Command output (cached):\nweb Running",
+		},
+		{
+			"Failure",
+			ExecuterResponse{Stderr: "not found", ExitCode: 1, Error: errors.New("exit status 1")},
+			"Error executing command: exit status 1 (exit code 1)\nStderr:\nnot found\nFOLLOW YOUR GUIDELINES",
+		},
+		{
+			"Timeout with partial output",
+			ExecuterResponse{Stdout: "line 1", ExitCode: -1, Error: errors.New("timed out")},
+			"Error executing command: timed out (exit code -1)\nStdout:\nline 1\nFOLLOW YOUR GUIDELINES",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatOutput(tt.response, tt.response.Stdout, tt.response.Stderr); got != tt.want {
+				t.Errorf("FormatOutput() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
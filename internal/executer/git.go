@@ -0,0 +1,41 @@
+package executer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// gitDeniedFlags write files, run external programs or read files outside the
+// repository history.
+var gitDeniedFlags = []string{"--output", "--ext-diff", "--no-index", "--contents"}
+
+// gitBisectSubCommands are the bisect sub commands that only read the bisect state.
+var gitBisectSubCommands = []string{"view", "visualize", "log"}
+
+// validateGitCommand rejects git flags that write files or run external programs, and
+// limits bisect to reading the state of a bisect in progress.
+func validateGitCommand(cmd Command) error {
+	args := cmd.Parts[2:]
+
+	for _, arg := range args {
+		if flag, ok := matchLongFlag(unquote(arg), gitDeniedFlags); ok {
+			return fmt.Errorf("%w: git %s %s", ErrOperationNotAllowed, cmd.Parts[1], flag)
+		}
+	}
+
+	if cmd.Parts[1] != "bisect" {
+		return nil
+	}
+
+	if len(args) == 0 || !slices.Contains(gitBisectSubCommands, args[0]) {
+		return fmt.Errorf("%w: git bisect only allows %s", ErrOperationNotAllowed, strings.Join(gitBisectSubCommands, ", "))
+	}
+
+	// without options, bisect view opens gitk when a display is available, and its
+	// first argument may name any program to run
+	if args[0] != "log" && (len(args) < 2 || !strings.HasPrefix(args[1], "-")) {
+		return fmt.Errorf("%w: git bisect %s needs git log options, such as --oneline", ErrOperationNotAllowed, args[0])
+	}
+	return nil
+}
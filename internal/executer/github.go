@@ -0,0 +1,31 @@
+package executer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ghActions are the read-only actions of the gh sub commands the executer allows.
+var ghActions = map[string][]string{
+	"run":      {"list", "view"},
+	"workflow": {"list", "view"},
+}
+
+// validateGHCommand checks that the command has the form `gh <sub command> <action>`
+// with a read-only action, and does not open a browser.
+func validateGHCommand(cmd Command) error {
+	if len(cmd.Parts) < 3 || strings.HasPrefix(cmd.Parts[2], "-") {
+		return fmt.Errorf("%w: use gh %s <%s>", ErrInvalidMainCommand, cmd.Parts[1], strings.Join(ghActions[cmd.Parts[1]], "|"))
+	}
+	if !slices.Contains(ghActions[cmd.Parts[1]], cmd.Parts[2]) {
+		return fmt.Errorf("%w: gh %s %s", ErrOperationNotAllowed, cmd.Parts[1], cmd.Parts[2])
+	}
+
+	for _, arg := range cmd.Parts[3:] {
+		if name, _, _ := strings.Cut(arg, "="); name == "--web" || name == "-w" {
+			return fmt.Errorf("%w: gh %s opens a browser", ErrOperationNotAllowed, name)
+		}
+	}
+	return nil
+}
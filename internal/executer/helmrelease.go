@@ -0,0 +1,201 @@
+package executer
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxHelmRevisions is how many revisions of the release history a helmrelease command
+// returns.
+const maxHelmRevisions = 10
+
+var (
+	// helmReleaseName and helmReleaseNamespace match the annotations Helm sets on the
+	// resources of a release, in the JSON, YAML and describe outputs of kubectl.
+	helmReleaseName      = regexp.MustCompile(`meta\.helm\.sh/release-name"?:\s*"?([a-z0-9][a-z0-9.-]*)`)
+	helmReleaseNamespace = regexp.MustCompile(`meta\.helm\.sh/release-namespace"?:\s*"?([a-z0-9][a-z0-9-]*)`)
+)
+
+// HelmReleaseExecuter adds the helmrelease command to an executer: `helmrelease
+// [-n <namespace>] <release>` answers with the values of the release and its history,
+// read with helm, to reason about chart-level misconfiguration. When the output of a
+// kubectl command shows a resource managed by Helm, the executer offers the command
+// after it. Every other command runs with the wrapped executer.
+type HelmReleaseExecuter struct {
+	CachingExecuter
+	helm  CachingExecuter // runs the helm commands
+	scope KubectlScope    // cluster the kubectl commands are pinned to, which helm commands use as well
+}
+
+// NewHelmReleaseExecuter wraps the executer to answer helmrelease commands with helm.
+func NewHelmReleaseExecuter(executer, helm CachingExecuter, scope KubectlScope) *HelmReleaseExecuter {
+	return &HelmReleaseExecuter{CachingExecuter: executer, helm: helm, scope: scope}
+}
+
+// helmRelease is a parsed helmrelease command.
+type helmRelease struct {
+	Namespace string
+	Name      string
+}
+
+// parseHelmReleaseCommand parses a helmrelease command. ok is false for other commands.
+func parseHelmReleaseCommand(command string) (release helmRelease, ok bool, err error) {
+	cmds := splitPipeline(command, ShellPOSIX)
+	if len(cmds) == 0 || len(cmds[0].Parts) == 0 || cmds[0].Parts[0] != "helmrelease" {
+		return helmRelease{}, false, nil
+	}
+	if len(cmds) > 1 {
+		return helmRelease{}, true, fmt.Errorf("%w: helmrelease cannot be piped", ErrOperationNotAllowed)
+	}
+
+	usage := fmt.Errorf("%w: use helmrelease [-n <namespace>] <release>, such as helmrelease -n shop web", ErrInvalidMainCommand)
+	args := unquoteAll(cmds[0].Parts[1:])
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-n" || arg == "--namespace":
+			if i+1 >= len(args) {
+				return helmRelease{}, true, usage
+			}
+			i++
+			release.Namespace = args[i]
+		case strings.HasPrefix(arg, "--namespace="):
+			release.Namespace = strings.TrimPrefix(arg, "--namespace=")
+		case strings.HasPrefix(arg, "-") || release.Name != "":
+			return helmRelease{}, true, usage
+		default:
+			release.Name = arg
+		}
+	}
+	if release.Name == "" {
+		return helmRelease{}, true, usage
+	}
+	return release, true, nil
+}
+
+// helmFlags returns the flags that point helm at the cluster and namespace of the
+// release. A pinned namespace replaces the one of the command, as for kubectl.
+func (hx *HelmReleaseExecuter) helmFlags(release helmRelease) string {
+	var flags string
+	if hx.scope.Kubeconfig != "" {
+		flags += " --kubeconfig " + shellQuote(hx.scope.Kubeconfig)
+	}
+	if hx.scope.Context != "" {
+		flags += " --kube-context " + shellQuote(hx.scope.Context)
+	}
+	namespace := release.Namespace
+	if hx.scope.Namespace != "" {
+		namespace = hx.scope.Namespace
+	}
+	if namespace != "" {
+		flags += " -n " + shellQuote(namespace)
+	}
+	return flags
+}
+
+// helmCommands returns the helm commands that read the values and the history of the
+// release.
+func (hx *HelmReleaseExecuter) helmCommands(release helmRelease) (values, history string) {
+	flags := hx.helmFlags(release)
+	name := shellQuote(release.Name)
+	return "helm get values " + name + flags + " -o yaml",
+		fmt.Sprintf("helm history %s%s --max %d", name, flags, maxHelmRevisions)
+}
+
+// Validate checks that helm accepts the commands of a helmrelease command, and other
+// commands with the wrapped executer.
+func (hx *HelmReleaseExecuter) Validate(command string) error {
+	release, ok, err := parseHelmReleaseCommand(command)
+	if !ok {
+		return hx.CachingExecuter.Validate(command)
+	}
+	if err != nil {
+		return err
+	}
+	values, history := hx.helmCommands(release)
+	for _, cmd := range []string{values, history} {
+		if err := hx.helm.Validate(cmd); err != nil {
+			return fmt.Errorf("helmrelease command %q: %w", cmd, err)
+		}
+	}
+	return nil
+}
+
+// Run answers helmrelease commands with the values and the history of the release, and
+// runs other commands with the wrapped executer, offering helmrelease when their output
+// shows a resource managed by Helm.
+func (hx *HelmReleaseExecuter) Run(ctx context.Context, command string) (response ExecuterResponse) {
+	release, ok, err := parseHelmReleaseCommand(command)
+	if !ok {
+		response = hx.CachingExecuter.Run(ctx, command)
+		if response.Error == nil && strings.HasPrefix(command, "kubectl ") {
+			response.Stdout += helmReleaseHint(response.Stdout)
+		}
+		return response
+	}
+
+	ctx, span := startSpan(ctx, command)
+	defer func() { endSpan(span, response) }()
+
+	if err != nil {
+		return ExecuterResponse{Stderr: err.Error(), ExitCode: -1, Error: err}
+	}
+
+	values, history := hx.helmCommands(release)
+	var sections []string
+	var duration time.Duration
+	cached := true
+	for _, cmd := range []string{values, history} {
+		result := hx.helm.Run(ctx, cmd)
+		if result.Error != nil {
+			result.Error = fmt.Errorf("%s: %w", cmd, result.Error)
+			return result
+		}
+		sections = append(sections, cmd+":\n"+result.Stdout)
+		duration += result.Duration
+		cached = cached && result.Cached
+	}
+
+	return ExecuterResponse{
+		Stdout:   strings.Join(sections, "\n\n"),
+		Duration: duration,
+		Cached:   cached,
+	}
+}
+
+// helmReleaseHint offers the helmrelease command when the output shows a resource of a
+// Helm release, empty otherwise.
+func helmReleaseHint(output string) string {
+	name := helmReleaseName.FindStringSubmatch(output)
+	if name == nil {
+		return ""
+	}
+	command := "helmrelease " + name[1]
+	if namespace := helmReleaseNamespace.FindStringSubmatch(output); namespace != nil {
+		command = "helmrelease -n " + namespace[1] + " " + name[1]
+	}
+	return fmt.Sprintf("\n\nThis resource is managed by the Helm release %s, `%s` shows its values and history.", name[1], command)
+}
+
+// Preflight checks other commands than helmrelease with the wrapped executer, the
+// permission checks only cover kubectl commands.
+func (hx *HelmReleaseExecuter) Preflight(ctx context.Context, command string) (string, error) {
+	if _, ok, _ := parseHelmReleaseCommand(command); ok {
+		return "", nil
+	}
+	return hx.CachingExecuter.Preflight(ctx, command)
+}
+
+// EffectiveCommand returns the command line that is executed for command, for a
+// helmrelease command the helm command that reads the values, which policies match on.
+func (hx *HelmReleaseExecuter) EffectiveCommand(command string) string {
+	release, ok, err := parseHelmReleaseCommand(command)
+	if !ok || err != nil {
+		return hx.CachingExecuter.EffectiveCommand(command)
+	}
+	values, _ := hx.helmCommands(release)
+	return values
+}
@@ -0,0 +1,99 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseHelmReleaseCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    helmRelease
+		ok      bool
+		wantErr error
+	}{
+		{"Release", "helmrelease -n shop web", helmRelease{Namespace: "shop", Name: "web"}, true, nil},
+		{"Current namespace", "helmrelease web", helmRelease{Name: "web"}, true, nil},
+		{"Other command", "helm get values web", helmRelease{}, false, nil},
+		{"No release", "helmrelease -n shop", helmRelease{}, true, ErrInvalidMainCommand},
+		{"Two releases", "helmrelease web api", helmRelease{}, true, ErrInvalidMainCommand},
+		{"Piped", "helmrelease web | grep image", helmRelease{}, true, ErrOperationNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := parseHelmReleaseCommand(tt.command)
+			if ok != tt.ok || !errors.Is(err, tt.wantErr) || tt.wantErr == nil && err != nil {
+				t.Fatalf("parseHelmReleaseCommand(%q) = %v, %v, want %v, %v", tt.command, ok, err, tt.ok, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseHelmReleaseCommand(%q) = %+v, want %+v", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHelmReleaseExecuter(t *testing.T) {
+	kubectl := NewTerminalExecuter(KubernetesExecuterType)
+	helm := NewTerminalExecuter(HelmExecuterType)
+	hx := NewHelmReleaseExecuter(kubectl, helm, KubectlScope{Context: "prod"})
+
+	command := "helmrelease -n shop web"
+	values := "helm get values web --kube-context prod -n shop -o yaml"
+	history := "helm history web --kube-context prod -n shop --max 10"
+	if got := hx.EffectiveCommand(command); got != values {
+		t.Errorf("EffectiveCommand() = %q, want %q", got, values)
+	}
+	if err := hx.Validate(command); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	helm.RestoreExecutedCommands(map[string]string{
+		values:  "USER-SUPPLIED VALUES:\nreplicas: 3",
+		history: "REVISION  STATUS      DESCRIPTION\n1         superseded  Install complete\n2         deployed    Upgrade complete",
+	})
+	response := hx.Run(context.Background(), command)
+	if response.Error != nil {
+		t.Fatalf("Run() error = %v", response.Error)
+	}
+	want := values + ":\nUSER-SUPPLIED VALUES:\nreplicas: 3\n\n" + history + ":\nREVISION  STATUS      DESCRIPTION\n" +
+		"1         superseded  Install complete\n2         deployed    Upgrade complete"
+	if response.Stdout != want {
+		t.Errorf("Run() stdout =\n%s\nwant\n%s", response.Stdout, want)
+	}
+
+	// kubectl outputs of resources of a release offer the command
+	kubectl.RestoreExecutedCommands(map[string]string{
+		"kubectl get deploy web -n shop -o yaml": "metadata:\n  annotations:\n    meta.helm.sh/release-name: web\n    meta.helm.sh/release-namespace: shop\n  name: web",
+	})
+	response = hx.Run(context.Background(), "kubectl get deploy web -n shop -o yaml")
+	hint := "This resource is managed by the Helm release web, `helmrelease -n shop web` shows its values and history."
+	if !strings.HasSuffix(response.Stdout, hint) {
+		t.Errorf("Run() did not offer the helmrelease command:\n%s", response.Stdout)
+	}
+}
+
+func TestHelmReleaseHint(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"JSON", `{"annotations": {"meta.helm.sh/release-name": "web", "meta.helm.sh/release-namespace": "shop"}}`, "helmrelease -n shop web"},
+		{"Describe", "Annotations:  meta.helm.sh/release-name: web\n              meta.helm.sh/release-namespace: shop", "helmrelease -n shop web"},
+		{"Without namespace", "meta.helm.sh/release-name: web", "helmrelease web"},
+		{"Not Helm", "NAME   READY\nweb    1/1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := helmReleaseHint(tt.output)
+			if tt.want == "" && got != "" || tt.want != "" && !strings.Contains(got, "`"+tt.want+"`") {
+				t.Errorf("helmReleaseHint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
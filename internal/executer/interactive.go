@@ -0,0 +1,263 @@
+package executer
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/eliran89c/klama/internal/audit"
+)
+
+// ErrInteractiveCommand is returned when an interactive command is run without a terminal.
+var ErrInteractiveCommand = fmt.Errorf("interactive commands must run in the terminal")
+
+// kubectlInteractiveValueFlags are the flags of kubectl exec, port-forward and debug that
+// take a separate value.
+var kubectlInteractiveValueFlags = []string{
+	"-n", "--namespace", "--context", "--kubeconfig", "--cluster", "--user",
+	"-c", "--container", "--address", "--pod-running-timeout", "--request-timeout",
+	"--image", "--image-pull-policy", "--profile",
+}
+
+// kubectlLocalAddresses are the addresses port-forward may listen on.
+var kubectlLocalAddresses = []string{"localhost", "127.0.0.1", "::1"}
+
+// validateKubectlInteractive accepts `kubectl exec -it <pod> -- <command>`,
+// `kubectl port-forward <target> <ports>` that listens on a local address only, and
+// `kubectl debug node/<name> -it --image=<image>`.
+func validateKubectlInteractive(cmd Command) error {
+	if len(cmd.Parts) < 2 {
+		return ErrInvalidMainCommand
+	}
+
+	args := unquoteAll(cmd.Parts[1:])
+	command := slices.Index(args, "--")
+	if command < 0 {
+		command = len(args)
+	}
+
+	var positional []string
+	stdin, tty, image := false, false, false
+	for i := 1; i < command; i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		switch {
+		case !strings.HasPrefix(name, "-"):
+			positional = append(positional, args[i])
+		case name == "-f" || name == "--filename" || name == "--custom":
+			return fmt.Errorf("%w: kubectl %s %s", ErrOperationNotAllowed, args[0], name)
+		case name == "--address":
+			if !hasValue && i+1 < command {
+				i++
+				value = args[i]
+			}
+			for _, address := range strings.Split(value, ",") {
+				if !slices.Contains(kubectlLocalAddresses, address) {
+					return fmt.Errorf("%w: port-forward must listen on localhost, not %s", ErrOperationNotAllowed, address)
+				}
+			}
+		case slices.Contains(kubectlInteractiveValueFlags, name):
+			image = image || name == "--image"
+			if !hasValue {
+				i++
+			}
+		case name == "--stdin":
+			stdin = !hasValue || value == "true"
+		case name == "--tty":
+			tty = !hasValue || value == "true"
+		case !strings.HasPrefix(name, "--"):
+			flags := shortFlags(name, "nc")
+			stdin = stdin || slices.Contains(flags, 'i')
+			tty = tty || slices.Contains(flags, 't')
+		}
+	}
+
+	switch args[0] {
+	case "exec":
+		switch {
+		case !stdin || !tty:
+			return fmt.Errorf("%w: kubectl exec must use -it, suggest read-only commands to run it once", ErrOperationNotAllowed)
+		case len(positional) != 1:
+			return fmt.Errorf("%w: kubectl exec must name a single pod", ErrOperationNotAllowed)
+		case command+1 >= len(args):
+			return fmt.Errorf("%w: kubectl exec must name the command after --, such as -- sh", ErrOperationNotAllowed)
+		}
+	case "port-forward":
+		if len(positional) < 2 || command < len(args) {
+			return fmt.Errorf("%w: expected `kubectl port-forward <target> <ports>`", ErrOperationNotAllowed)
+		}
+	case "debug":
+		switch {
+		case len(positional) != 1 || !strings.HasPrefix(positional[0], "node/"):
+			return fmt.Errorf("%w: kubectl debug may only debug a single node, as in kubectl debug node/<name>", ErrOperationNotAllowed)
+		case !stdin || !tty:
+			return fmt.Errorf("%w: kubectl debug must use -it", ErrOperationNotAllowed)
+		case !image:
+			return fmt.Errorf("%w: kubectl debug must name the image of the debug container, such as --image=busybox", ErrOperationNotAllowed)
+		}
+	}
+	return nil
+}
+
+// InteractiveCommands selects the interactive kubectl commands an InteractiveExecuter
+// accepts.
+type InteractiveCommands struct {
+	// Terminal accepts kubectl exec -it and port-forward.
+	Terminal bool
+	// NodeDebug accepts kubectl debug node/<name> -it, which starts a privileged pod on
+	// the node. Node debugging is a mutation of the node, approved by typing its name and
+	// recorded in Audit before it runs.
+	NodeDebug bool
+	Audit     *audit.Log
+}
+
+// InteractiveExecuter runs the commands of the wrapped executer, and accepts the
+// interactive kubectl commands that take over the terminal: exec -it and port-forward,
+// and debug node/<name> -it when enabled. Interactive commands are never run by Run, as
+// they read from the terminal and may not exit, the caller runs the command returned by
+// InteractiveCommand instead.
+type InteractiveExecuter struct {
+	CachingExecuter
+
+	interactive *TerminalExecuter
+	audit       *audit.Log // records node debugging
+}
+
+// NewKubectlInteractiveExecuter wraps the executer to also accept the selected
+// interactive kubectl commands. rewrite, when set, is applied to the interactive
+// commands before they run.
+func NewKubectlInteractiveExecuter(executer CachingExecuter, commands InteractiveCommands, rewrite func(Command) Command) *InteractiveExecuter {
+	var subCommands []string
+	if commands.Terminal {
+		subCommands = append(subCommands, "exec", "port-forward")
+	}
+	if commands.NodeDebug {
+		subCommands = append(subCommands, "debug")
+	}
+
+	return &InteractiveExecuter{
+		CachingExecuter: executer,
+		interactive: NewTerminalExecuter(TerminalExecuterType{
+			AllowedCommands:    []string{"kubectl"},
+			AllowedSubCommands: subCommands,
+			ValidateCommand:    validateKubectlInteractive,
+			RewriteCommand:     rewrite,
+			Uncached:           true,
+		}),
+		audit: commands.Audit,
+	}
+}
+
+// Interactive reports whether the command is an interactive command that must run in the
+// terminal.
+func (ix *InteractiveExecuter) Interactive(command string) bool {
+	return ix.CachingExecuter.Validate(command) != nil && ix.interactive.Validate(command) == nil
+}
+
+// Validate accepts the commands of the wrapped executer and the interactive commands.
+// For commands that are neither, the more specific error is returned.
+func (ix *InteractiveExecuter) Validate(command string) error {
+	err := ix.CachingExecuter.Validate(command)
+	if err == nil {
+		return nil
+	}
+
+	interactiveErr := ix.interactive.Validate(command)
+	if interactiveErr == nil {
+		return nil
+	}
+	if cmds := SplitPipeline(command); len(cmds) > 0 && len(cmds[0].Parts) > 1 && cmds[0].Parts[0] == "kubectl" &&
+		slices.Contains(ix.interactive.executerType.AllowedSubCommands, cmds[0].Parts[1]) {
+		// an interactive command with arguments that are not accepted
+		return interactiveErr
+	}
+	return err
+}
+
+// Run runs the commands of the wrapped executer, and refuses interactive commands.
+func (ix *InteractiveExecuter) Run(ctx context.Context, command string) ExecuterResponse {
+	if ix.Interactive(command) {
+		return ExecuterResponse{Stderr: ErrInteractiveCommand.Error(), ExitCode: -1, Error: ErrInteractiveCommand}
+	}
+	return ix.CachingExecuter.Run(ctx, command)
+}
+
+// InteractiveCommand returns the process of an interactive command, with its standard
+// streams unset so the caller can attach them to the terminal. Node debugging is not
+// started when it cannot be recorded in the audit log.
+func (ix *InteractiveExecuter) InteractiveCommand(command string) (*exec.Cmd, error) {
+	if err := ix.interactive.Validate(command); err != nil {
+		return nil, err
+	}
+
+	if node, ok := ix.debuggedNode(command); ok {
+		err := ix.audit.Append(audit.Entry{
+			Command: ix.interactive.EffectiveCommand(command),
+			Target:  node.String(),
+			Status:  audit.StatusApproved,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("the command was not run because it could not be recorded in the audit log: %w", err)
+		}
+	}
+
+	args, err := shellArgs(ix.interactive.EffectiveCommand(command))
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(args[0][0], args[0][1:]...)
+	cmd.Env = ix.interactive.env()
+	return cmd, nil
+}
+
+// Preflight checks the commands of the wrapped executer, interactive commands are always
+// confirmed by the user.
+func (ix *InteractiveExecuter) Preflight(ctx context.Context, command string) (string, error) {
+	if ix.Interactive(command) {
+		return "", nil
+	}
+	return ix.CachingExecuter.Preflight(ctx, command)
+}
+
+// EffectiveCommand returns the command line that is executed for command.
+func (ix *InteractiveExecuter) EffectiveCommand(command string) string {
+	if ix.Interactive(command) {
+		return ix.interactive.EffectiveCommand(command)
+	}
+	return ix.CachingExecuter.EffectiveCommand(command)
+}
+
+// Mutation returns the node a kubectl debug command starts a pod on, or the resource the
+// command changes when the wrapped executer runs the mutations of fix mode.
+func (ix *InteractiveExecuter) Mutation(command string) (MutationTarget, bool) {
+	if node, ok := ix.debuggedNode(command); ok {
+		return node, true
+	}
+	if checker, ok := ix.CachingExecuter.(interface {
+		Mutation(string) (MutationTarget, bool)
+	}); ok {
+		return checker.Mutation(command)
+	}
+	return MutationTarget{}, false
+}
+
+// debuggedNode returns the node of an accepted kubectl debug command.
+func (ix *InteractiveExecuter) debuggedNode(command string) (MutationTarget, bool) {
+	cmds := SplitPipeline(command)
+	if len(cmds) == 0 || len(cmds[0].Parts) < 2 || cmds[0].Parts[1] != "debug" || !ix.Interactive(command) {
+		return MutationTarget{}, false
+	}
+	args := unquoteAll(cmds[0].Parts[2:])
+	for i := 0; i < len(args) && args[i] != "--"; i++ {
+		flag, _, hasValue := strings.Cut(args[i], "=")
+		if slices.Contains(kubectlInteractiveValueFlags, flag) && !hasValue {
+			i++ // skip the separate value
+			continue
+		}
+		if name, ok := strings.CutPrefix(args[i], "node/"); ok {
+			return MutationTarget{Kind: "node", Name: name}, true
+		}
+	}
+	return MutationTarget{}, false
+}
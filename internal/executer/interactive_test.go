@@ -0,0 +1,122 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/eliran89c/klama/internal/audit"
+)
+
+func TestInteractiveExecuter_Validate(t *testing.T) {
+	ix := NewKubectlInteractiveExecuter(NewTerminalExecuter(KubernetesExecuterType), InteractiveCommands{Terminal: true}, nil)
+
+	tests := []struct {
+		name        string
+		command     string
+		interactive bool
+		wantErr     error
+	}{
+		{name: "read-only command", command: "kubectl get pods -n shop"},
+		{name: "exec shell", command: "kubectl exec -it web-0 -n shop -- sh", interactive: true},
+		{name: "exec separate flags", command: "kubectl exec --stdin --tty deploy/web -c app -- bash -l", interactive: true},
+		{name: "exec split short flags", command: "kubectl exec -i -t web-0 -- sh", interactive: true},
+		{name: "port-forward", command: "kubectl port-forward svc/web 8080:80 -n shop", interactive: true},
+		{name: "port-forward localhost", command: "kubectl port-forward pod/web-0 5432 --address 127.0.0.1,localhost", interactive: true},
+		{name: "exec without a terminal", command: "kubectl exec web-0 -- cat /etc/hosts", wantErr: ErrOperationNotAllowed},
+		{name: "exec without a command", command: "kubectl exec -it web-0", wantErr: ErrOperationNotAllowed},
+		{name: "exec several pods", command: "kubectl exec -it web-0 web-1 -- sh", wantErr: ErrOperationNotAllowed},
+		{name: "exec from file", command: "kubectl exec -it -f pod.yaml -- sh", wantErr: ErrOperationNotAllowed},
+		{name: "port-forward on all addresses", command: "kubectl port-forward svc/web 8080:80 --address=0.0.0.0", wantErr: ErrOperationNotAllowed},
+		{name: "port-forward without ports", command: "kubectl port-forward svc/web", wantErr: ErrOperationNotAllowed},
+		{name: "piped exec", command: "kubectl exec -it web-0 -- sh | grep x", wantErr: ErrCommandNotAllowed},
+		{name: "chained exec", command: "kubectl exec -it web-0 -- sh; rm -rf /", wantErr: ErrCommandChaining},
+		{name: "other sub command", command: "kubectl delete pod web-0", wantErr: ErrSubCommandNotAllowed},
+		{name: "node debug not enabled", command: "kubectl debug node/worker-1 -it --image=busybox", wantErr: ErrSubCommandNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ix.Validate(tt.command)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Validate() error = %v, want nil", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := ix.Interactive(tt.command); got != tt.interactive {
+				t.Errorf("Interactive() = %v, want %v", got, tt.interactive)
+			}
+		})
+	}
+}
+
+func TestInteractiveExecuter_Run(t *testing.T) {
+	ix := NewKubectlInteractiveExecuter(NewTerminalExecuter(KubernetesExecuterType), InteractiveCommands{Terminal: true}, KubectlScope{Context: "staging"}.Apply)
+
+	response := ix.Run(context.Background(), "kubectl exec -it web-0 -- sh")
+	if !errors.Is(response.Error, ErrInteractiveCommand) {
+		t.Errorf("Run() error = %v, want %v", response.Error, ErrInteractiveCommand)
+	}
+
+	cmd, err := ix.InteractiveCommand("kubectl exec -it web-0 -- sh")
+	if err != nil {
+		t.Fatalf("InteractiveCommand() error = %v", err)
+	}
+	if want := []string{"kubectl", "exec", "-it", "web-0", "--context=staging", "--", "sh"}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("InteractiveCommand() runs %q, want %q", cmd.Args, want)
+	}
+
+	if _, err := ix.InteractiveCommand("kubectl get pods"); err == nil {
+		t.Error("InteractiveCommand() accepted a read-only command")
+	}
+}
+
+func TestInteractiveExecuter_NodeDebug(t *testing.T) {
+	auditLog := &audit.Log{Path: filepath.Join(t.TempDir(), "audit.jsonl")}
+	ix := NewKubectlInteractiveExecuter(NewTerminalExecuter(KubernetesExecuterType), InteractiveCommands{NodeDebug: true, Audit: auditLog}, nil)
+
+	tests := []struct {
+		name    string
+		command string
+		node    string
+		wantErr error
+	}{
+		{name: "busybox", command: "kubectl debug node/worker-1 -it --image=busybox", node: "worker-1"},
+		{name: "separate flags", command: "kubectl debug --profile sysadmin --image busybox:1.36 -i -t node/worker-1 -- chroot /host", node: "worker-1"},
+		{name: "pod", command: "kubectl debug web-0 -it --image=busybox", wantErr: ErrOperationNotAllowed},
+		{name: "without a terminal", command: "kubectl debug node/worker-1 --image=busybox -- df -h", wantErr: ErrOperationNotAllowed},
+		{name: "without an image", command: "kubectl debug node/worker-1 -it", wantErr: ErrOperationNotAllowed},
+		{name: "custom spec", command: "kubectl debug node/worker-1 -it --image=busybox --custom=spec.json", wantErr: ErrOperationNotAllowed},
+		{name: "exec not enabled", command: "kubectl exec -it web-0 -- sh", wantErr: ErrSubCommandNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ix.Validate(tt.command)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Validate() error = %v, want nil", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+			target, ok := ix.Mutation(tt.command)
+			if ok != (tt.node != "") || target.Name != tt.node {
+				t.Errorf("Mutation() = %v, %v, want node %q", target, ok, tt.node)
+			}
+		})
+	}
+
+	if _, err := ix.InteractiveCommand("kubectl debug node/worker-1 -it --image=busybox"); err != nil {
+		t.Fatalf("InteractiveCommand() error = %v", err)
+	}
+	entries, err := auditLog.Entries()
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Target != "node/worker-1" || entries[0].Status != audit.StatusApproved {
+		t.Errorf("audit log = %+v, want the approved node debug", entries)
+	}
+}
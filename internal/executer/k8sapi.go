@@ -0,0 +1,688 @@
+package executer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eliran89c/klama/internal/kube"
+	"gopkg.in/yaml.v3"
+)
+
+const tableAccept = "application/json;as=Table;v=v1;g=meta.k8s.io,application/json"
+
+// K8sAPIExecuter runs read-only kubectl commands against the Kubernetes API directly,
+// so klama works where the kubectl binary is not installed. Commands are validated
+// like KubernetesExecuterType, and piped commands run without a shell.
+type K8sAPIExecuter struct {
+	*TerminalExecuter // validation and result caching
+
+	client    *kube.Client
+	namespace string
+}
+
+// NewK8sAPIExecuter creates a new K8sAPIExecuter for the given client. Commands are
+// validated and rewritten according to executerType, usually KubernetesExecuterType.
+func NewK8sAPIExecuter(client *kube.Client, executerType TerminalExecuterType) *K8sAPIExecuter {
+	return &K8sAPIExecuter{
+		TerminalExecuter: NewTerminalExecuter(executerType),
+		client:           client,
+		namespace:        client.Config().Namespace,
+	}
+}
+
+// Run executes a kubectl command through the Kubernetes API and returns the output.
+// It caches the results of previously executed commands.
+func (kx *K8sAPIExecuter) Run(ctx context.Context, command string) (response ExecuterResponse) {
+	ctx, span := startSpan(ctx, command)
+	defer func() { endSpan(span, response) }()
+
+	if entry, exists := kx.cached(command); exists && !cacheSkipped(ctx) {
+		return entry.response()
+	}
+
+	cmds := splitPipeline(kx.EffectiveCommand(command), kx.executerType.Shell)
+	if len(cmds) == 0 || len(cmds[0].Parts) == 0 {
+		return ExecuterResponse{Error: ErrEmptyCommand}
+	}
+
+	start := time.Now()
+	output, err := kx.runKubectl(ctx, cmds[0])
+	if err == nil && len(cmds) > 1 {
+		output, err = runPipeline(ctx, output, cmds[1:], kx.executerType.Shell)
+	}
+
+	response = apiResponse(ctx, strings.TrimSpace(output), err, time.Since(start))
+	if err == nil {
+		kx.cache(command, response.Stdout, "")
+	}
+	return response
+}
+
+// Preflight checks with a SelfSubjectAccessReview whether RBAC allows the reads the
+// command performs.
+func (kx *K8sAPIExecuter) Preflight(ctx context.Context, command string) (string, error) {
+	if _, exists := kx.cached(command); exists {
+		return "", nil
+	}
+
+	cmds := splitCommandsByPipe(kx.EffectiveCommand(command))
+	if len(cmds) == 0 {
+		return "", nil
+	}
+	args, err := parseKubectlArgs(cmds[0])
+	if err != nil || args.FromFiles {
+		return "", nil
+	}
+
+	namespace := kx.namespaceFor(args)
+	var denied []string
+	for _, check := range args.accessChecks() {
+		resource, err := kx.client.ResolveResource(ctx, check.Resource)
+		if err != nil {
+			return "", err
+		}
+
+		attributes := map[string]string{
+			"verb":        check.Verb,
+			"group":       resource.Group,
+			"resource":    resource.Name,
+			"subresource": check.Subresource,
+			"name":        check.Name,
+		}
+		if resource.Namespaced {
+			attributes["namespace"] = namespace
+		}
+
+		data, err := kx.client.Post(ctx, "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews", map[string]any{
+			"apiVersion": "authorization.k8s.io/v1",
+			"kind":       "SelfSubjectAccessReview",
+			"spec":       map[string]any{"resourceAttributes": attributes},
+		})
+		if err != nil {
+			return "", err
+		}
+
+		var review struct {
+			Status struct {
+				Allowed bool `json:"allowed"`
+			} `json:"status"`
+		}
+		if err := json.Unmarshal(data, &review); err != nil {
+			return "", fmt.Errorf("failed to parse access review: %w", err)
+		}
+		if !review.Status.Allowed {
+			denied = append(denied, check.String())
+		}
+	}
+
+	return deniedWarning(denied, args, namespace), nil
+}
+
+// namespaceFor returns the namespace a command reads from, empty for all namespaces.
+func (kx *K8sAPIExecuter) namespaceFor(args kubectlArgs) string {
+	switch {
+	case args.AllNamespaces:
+		return ""
+	case args.Namespace != "":
+		return args.Namespace
+	default:
+		return kx.namespace
+	}
+}
+
+func (kx *K8sAPIExecuter) runKubectl(ctx context.Context, cmd Command) (string, error) {
+	args, err := parseKubectlArgs(cmd)
+	if err != nil {
+		return "", err
+	}
+	if args.FromFiles {
+		return "", fmt.Errorf("reading resources from files is not supported when using the Kubernetes API")
+	}
+
+	switch args.SubCommand {
+	case "get":
+		return kx.get(ctx, args)
+	case "describe":
+		return kx.describe(ctx, args)
+	case "logs":
+		return kx.logs(ctx, args)
+	case "top":
+		return kx.top(ctx, args)
+	default:
+		return "", fmt.Errorf("kubectl %s is not supported when using the Kubernetes API", args.SubCommand)
+	}
+}
+
+func (kx *K8sAPIExecuter) get(ctx context.Context, args kubectlArgs) (string, error) {
+	targets := args.targets()
+	if len(targets) == 0 {
+		return "", fmt.Errorf("you must specify the type of resource to get")
+	}
+
+	outputs := make([]string, 0, len(targets))
+	for _, target := range targets {
+		resource, err := kx.client.ResolveResource(ctx, target.Resource)
+		if err != nil {
+			return strings.Join(outputs, "\n\n"), err
+		}
+
+		var output string
+		switch args.Output {
+		case "", "wide":
+			output, err = kx.getTable(ctx, args, resource, target.Name)
+		case "yaml", "json":
+			output, err = kx.getObject(ctx, args, resource, target.Name)
+		case "name":
+			output, err = kx.getNames(ctx, args, resource, target.Name)
+		default:
+			err = fmt.Errorf("output format %q is not supported when using the Kubernetes API", args.Output)
+		}
+		if err != nil {
+			return strings.Join(outputs, "\n\n"), err
+		}
+		outputs = append(outputs, output)
+	}
+
+	return strings.Join(outputs, "\n\n"), nil
+}
+
+func (kx *K8sAPIExecuter) listQuery(args kubectlArgs) url.Values {
+	query := url.Values{}
+	if args.Selector != "" {
+		query.Set("labelSelector", args.Selector)
+	}
+	if args.FieldSelector != "" {
+		query.Set("fieldSelector", args.FieldSelector)
+	}
+	return query
+}
+
+// table is the server side rendering of a list, as requested with tableAccept.
+type table struct {
+	ColumnDefinitions []struct {
+		Name     string `json:"name"`
+		Priority int    `json:"priority"`
+	} `json:"columnDefinitions"`
+	Rows []struct {
+		Cells  []any `json:"cells"`
+		Object struct {
+			Metadata struct {
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		} `json:"object"`
+	} `json:"rows"`
+}
+
+func (kx *K8sAPIExecuter) getTable(ctx context.Context, args kubectlArgs, resource kube.Resource, name string) (string, error) {
+	namespace := kx.namespaceFor(args)
+	data, err := kx.client.Get(ctx, resource.Path(namespace, name), kx.listQuery(args), tableAccept)
+	if err != nil {
+		return "", err
+	}
+
+	var t table
+	if err := json.Unmarshal(data, &t); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(t.Rows) == 0 {
+		switch {
+		case !resource.Namespaced:
+			return "No resources found", nil
+		case namespace == "":
+			return "No resources found", nil
+		default:
+			return fmt.Sprintf("No resources found in %s namespace.", namespace), nil
+		}
+	}
+
+	withNamespace := args.AllNamespaces && resource.Namespaced
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 3, ' ', 0)
+
+	var header []string
+	if withNamespace {
+		header = append(header, "NAMESPACE")
+	}
+	for _, column := range t.ColumnDefinitions {
+		if column.Priority == 0 || args.Output == "wide" {
+			header = append(header, strings.ToUpper(column.Name))
+		}
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for _, row := range t.Rows {
+		var cells []string
+		if withNamespace {
+			cells = append(cells, row.Object.Metadata.Namespace)
+		}
+		for i, column := range t.ColumnDefinitions {
+			if column.Priority != 0 && args.Output != "wide" {
+				continue
+			}
+			cell := "<none>"
+			if i < len(row.Cells) && row.Cells[i] != nil && row.Cells[i] != "" {
+				cell = fmt.Sprint(row.Cells[i])
+			}
+			cells = append(cells, cell)
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+
+	return buf.String(), nil
+}
+
+func (kx *K8sAPIExecuter) getObject(ctx context.Context, args kubectlArgs, resource kube.Resource, name string) (string, error) {
+	data, err := kx.client.Get(ctx, resource.Path(kx.namespaceFor(args), name), kx.listQuery(args), "")
+	if err != nil {
+		return "", err
+	}
+
+	object, err := decodeObject(data)
+	if err != nil {
+		return "", err
+	}
+
+	if args.Output == "json" {
+		out, err := json.MarshalIndent(object, "", "    ")
+		return string(out), err
+	}
+	return encodeYAML(object)
+}
+
+func (kx *K8sAPIExecuter) getNames(ctx context.Context, args kubectlArgs, resource kube.Resource, name string) (string, error) {
+	prefix := strings.ToLower(resource.Kind)
+	if resource.Group != "" {
+		prefix += "." + resource.Group
+	}
+
+	if name != "" {
+		if _, err := kx.client.Get(ctx, resource.Path(kx.namespaceFor(args), name), nil, ""); err != nil {
+			return "", err
+		}
+		return prefix + "/" + name, nil
+	}
+
+	items, err := kx.listObjects(ctx, args, resource)
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		names = append(names, prefix+"/"+objectName(item))
+	}
+	return strings.Join(names, "\n"), nil
+}
+
+func (kx *K8sAPIExecuter) listObjects(ctx context.Context, args kubectlArgs, resource kube.Resource) ([]map[string]any, error) {
+	data, err := kx.client.Get(ctx, resource.Path(kx.namespaceFor(args), ""), kx.listQuery(args), "")
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Items []map[string]any `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return list.Items, nil
+}
+
+func (kx *K8sAPIExecuter) describe(ctx context.Context, args kubectlArgs) (string, error) {
+	targets := args.targets()
+	if len(targets) == 0 {
+		return "", fmt.Errorf("you must specify the type of resource to describe")
+	}
+
+	var outputs []string
+	for _, target := range targets {
+		resource, err := kx.client.ResolveResource(ctx, target.Resource)
+		if err != nil {
+			return "", err
+		}
+
+		var objects []map[string]any
+		if target.Name != "" {
+			data, err := kx.client.Get(ctx, resource.Path(kx.namespaceFor(args), target.Name), nil, "")
+			if err != nil {
+				return "", err
+			}
+			object, err := decodeObject(data)
+			if err != nil {
+				return "", err
+			}
+			objects = append(objects, object)
+		} else {
+			if objects, err = kx.listObjects(ctx, args, resource); err != nil {
+				return "", err
+			}
+		}
+
+		for _, object := range objects {
+			output, err := kx.describeObject(ctx, object)
+			if err != nil {
+				return "", err
+			}
+			outputs = append(outputs, output)
+		}
+	}
+
+	if len(outputs) == 0 {
+		return "No resources found", nil
+	}
+	return strings.Join(outputs, "\n\n"), nil
+}
+
+// describeObject renders the object followed by its events, the parts of
+// `kubectl describe` the agent relies on.
+func (kx *K8sAPIExecuter) describeObject(ctx context.Context, object map[string]any) (string, error) {
+	stripManagedFields(object)
+	out, err := encodeYAML(object)
+	if err != nil {
+		return "", err
+	}
+
+	metadata, _ := object["metadata"].(map[string]any)
+	uid, _ := metadata["uid"].(string)
+	namespace, _ := metadata["namespace"].(string)
+
+	eventsPath := "/api/v1/events"
+	if namespace != "" {
+		eventsPath = "/api/v1/namespaces/" + namespace + "/events"
+	}
+	data, err := kx.client.Get(ctx, eventsPath, url.Values{"fieldSelector": {"involvedObject.uid=" + uid}}, "")
+	if err != nil {
+		// events are a best effort addition, the object itself was read
+		return out + "\nEvents: " + err.Error(), nil
+	}
+
+	events, err := formatEvents(data, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return out + "\nEvents:" + events, nil
+}
+
+func (kx *K8sAPIExecuter) logs(ctx context.Context, args kubectlArgs) (string, error) {
+	if len(args.Positional) == 0 {
+		return "", fmt.Errorf("a pod name is required when using the Kubernetes API")
+	}
+
+	namespace := kx.namespace
+	if args.Namespace != "" {
+		namespace = args.Namespace
+	}
+
+	pod := args.Positional[0]
+	if resourceName, name, found := strings.Cut(pod, "/"); found {
+		var err error
+		if pod, err = kx.podFor(ctx, namespace, resourceName, name); err != nil {
+			return "", err
+		}
+	}
+
+	query := url.Values{}
+	if args.Container != "" {
+		query.Set("container", args.Container)
+	}
+	if args.Tail != "" && args.Tail != "-1" {
+		query.Set("tailLines", args.Tail)
+	}
+	if args.Since != "" {
+		since, err := time.ParseDuration(args.Since)
+		if err != nil {
+			return "", fmt.Errorf("invalid --since duration %q: %w", args.Since, err)
+		}
+		query.Set("sinceSeconds", strconv.Itoa(int(since.Seconds())))
+	}
+	if sinceTime := args.Flags["--since-time"]; sinceTime != "" {
+		query.Set("sinceTime", sinceTime)
+	}
+	if args.Previous {
+		query.Set("previous", "true")
+	}
+	if args.Timestamps {
+		query.Set("timestamps", "true")
+	}
+
+	data, err := kx.client.Get(ctx, "/api/v1/namespaces/"+namespace+"/pods/"+pod+"/log", query, "*/*")
+	return string(data), err
+}
+
+// podFor resolves forms such as deploy/web to the first pod the workload selects.
+func (kx *K8sAPIExecuter) podFor(ctx context.Context, namespace, resourceName, name string) (string, error) {
+	resource, err := kx.client.ResolveResource(ctx, resourceName)
+	if err != nil {
+		return "", err
+	}
+	if resource.Group == "" && resource.Name == "pods" {
+		return name, nil
+	}
+
+	data, err := kx.client.Get(ctx, resource.Path(namespace, name), nil, "")
+	if err != nil {
+		return "", err
+	}
+
+	var workload struct {
+		Spec struct {
+			Selector struct {
+				MatchLabels map[string]string `json:"matchLabels"`
+			} `json:"selector"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(data, &workload); err != nil {
+		return "", fmt.Errorf("failed to parse %s/%s: %w", resourceName, name, err)
+	}
+	if len(workload.Spec.Selector.MatchLabels) == 0 {
+		return "", fmt.Errorf("cannot select pods of %s/%s", resourceName, name)
+	}
+
+	selector := make([]string, 0, len(workload.Spec.Selector.MatchLabels))
+	for key, value := range workload.Spec.Selector.MatchLabels {
+		selector = append(selector, key+"="+value)
+	}
+	sort.Strings(selector)
+
+	data, err = kx.client.Get(ctx, "/api/v1/namespaces/"+namespace+"/pods", url.Values{"labelSelector": {strings.Join(selector, ",")}}, "")
+	if err != nil {
+		return "", err
+	}
+
+	var pods struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Status struct {
+				Phase string `json:"phase"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &pods); err != nil {
+		return "", fmt.Errorf("failed to parse pods: %w", err)
+	}
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("no pods found for %s/%s", resourceName, name)
+	}
+
+	// prefer a running pod, like kubectl does
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == "Running" {
+			return pod.Metadata.Name, nil
+		}
+	}
+	return pods.Items[0].Metadata.Name, nil
+}
+
+// runPipeline feeds the output through the piped text processing commands, which run
+// without a shell. In PowerShell, the piped commands may be cmdlets.
+func runPipeline(ctx context.Context, input string, cmds []Command, shell Shell) (string, error) {
+	parseArgs, run := shellArgs, runArgs
+	if shell.Resolve() == ShellPowerShell {
+		parseArgs, run = powerShellArgs, runPowerShellArgs
+	}
+
+	args, err := parseArgs(joinCommands(cmds))
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr bytes.Buffer
+	if _, err := run(ctx, args, nil, strings.NewReader(input), &stdout, &stderr); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			err = fmt.Errorf("%w: %s", err, message)
+		}
+		return stdout.String(), err
+	}
+	return stdout.String(), nil
+}
+
+// decodeObject decodes an API object without its managed fields.
+func decodeObject(data []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var object map[string]any
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	stripManagedFields(object)
+	return object, nil
+}
+
+// stripManagedFields removes metadata.managedFields, which kubectl hides by default.
+func stripManagedFields(object map[string]any) {
+	if metadata, ok := object["metadata"].(map[string]any); ok {
+		delete(metadata, "managedFields")
+	}
+	if items, ok := object["items"].([]any); ok {
+		for _, item := range items {
+			if itemObject, ok := item.(map[string]any); ok {
+				stripManagedFields(itemObject)
+			}
+		}
+	}
+}
+
+func encodeYAML(object any) (string, error) {
+	// round trip through JSON so numbers keep their original representation
+	data, err := json.Marshal(object)
+	if err != nil {
+		return "", err
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return "", err
+	}
+	clearStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// clearStyle turns the flow style of a node parsed from JSON into block style.
+func clearStyle(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		node.Style = 0
+	}
+	if node.Kind == yaml.ScalarNode && node.Style == yaml.DoubleQuotedStyle {
+		node.Style = 0
+	}
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+func objectName(object map[string]any) string {
+	metadata, _ := object["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+	return name
+}
+
+// formatEvents renders an event list as the indented table of `kubectl describe`.
+func formatEvents(data []byte, now time.Time) (string, error) {
+	var events struct {
+		Items []struct {
+			Type           string    `json:"type"`
+			Reason         string    `json:"reason"`
+			Message        string    `json:"message"`
+			Count          int       `json:"count"`
+			FirstTimestamp time.Time `json:"firstTimestamp"`
+			LastTimestamp  time.Time `json:"lastTimestamp"`
+			EventTime      time.Time `json:"eventTime"`
+			Source         struct {
+				Component string `json:"component"`
+			} `json:"source"`
+			ReportingController string `json:"reportingComponent"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &events); err != nil {
+		return "", fmt.Errorf("failed to parse events: %w", err)
+	}
+	if len(events.Items) == 0 {
+		return " <none>", nil
+	}
+
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return events.Items[i].LastTimestamp.Before(events.Items[j].LastTimestamp)
+	})
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\n  Type\tReason\tAge\tFrom\tMessage")
+	fmt.Fprintln(w, "  ----\t------\t---\t----\t-------")
+	for _, event := range events.Items {
+		last := event.LastTimestamp
+		if last.IsZero() {
+			last = event.EventTime
+		}
+		age := "<unknown>"
+		if !last.IsZero() {
+			age = formatAge(now.Sub(last))
+			if event.Count > 1 {
+				age = fmt.Sprintf("%s (x%d over %s)", age, event.Count, formatAge(now.Sub(event.FirstTimestamp)))
+			}
+		}
+		from := event.Source.Component
+		if from == "" {
+			from = event.ReportingController
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", event.Type, event.Reason, age, from, strings.TrimSpace(event.Message))
+	}
+	w.Flush()
+
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
+
+// formatAge formats a duration the way kubectl shows ages, such as 5m or 3d.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < 0:
+		return "0s"
+	case d < 2*time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < 3*time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,261 @@
+package executer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eliran89c/klama/internal/kube"
+	"k8s.io/client-go/rest"
+)
+
+func newTestK8sAPIExecuter(t *testing.T) *K8sAPIExecuter {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"versions":["v1"]}`))
+	})
+	mux.HandleFunc("/api/v1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"v1","resources":[
+			{"name":"pods","singularName":"pod","namespaced":true,"kind":"Pod","shortNames":["po"]},
+			{"name":"secrets","singularName":"secret","namespaced":true,"kind":"Secret"}]}`))
+	})
+	mux.HandleFunc("/apis", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"groups":[]}`))
+	})
+	mux.HandleFunc("/api/v1/namespaces/apps/pods", func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Accept"), "as=Table") {
+			w.Write([]byte(`{"kind":"Table","columnDefinitions":[
+				{"name":"Name","priority":0},{"name":"Ready","priority":0},{"name":"IP","priority":1}],
+				"rows":[{"cells":["web-0","1/1","10.0.0.1"]},{"cells":["web-1","0/1",null]}]}`))
+			return
+		}
+		w.Write([]byte(`{"kind":"PodList","items":[{"metadata":{"name":"web-0"}},{"metadata":{"name":"web-1"}}]}`))
+	})
+	mux.HandleFunc("/api/v1/namespaces/other/pods", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"kind":"Table","columnDefinitions":[{"name":"Name","priority":0}],"rows":[]}`))
+	})
+	mux.HandleFunc("/api/v1/namespaces/apps/pods/web-0", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"kind":"Pod","metadata":{"name":"web-0","namespace":"apps","uid":"uid-1","managedFields":[{"manager":"kubectl"}]},"spec":{"replicas":1}}`))
+	})
+	mux.HandleFunc("/api/v1/namespaces/apps/events", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fieldSelector") != "involvedObject.uid=uid-1" {
+			t.Errorf("unexpected events selector %q", r.URL.Query().Get("fieldSelector"))
+		}
+		last := time.Now().Add(-5 * time.Minute).UTC().Format(time.RFC3339)
+		w.Write([]byte(`{"items":[{"type":"Warning","reason":"BackOff","message":"Back-off restarting failed container","count":1,
+			"lastTimestamp":"` + last + `","source":{"component":"kubelet"}}]}`))
+	})
+	mux.HandleFunc("/api/v1/namespaces/apps/pods/web-0/log", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("tailLines") != "10" || r.URL.Query().Get("container") != "app" {
+			t.Errorf("unexpected log query %q", r.URL.RawQuery)
+		}
+		w.Write([]byte("line 1\nerror: line 2\n"))
+	})
+	mux.HandleFunc("/apis/metrics.k8s.io/v1beta1/namespaces/apps/pods", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"items":[{"metadata":{"name":"web-0","namespace":"apps"},"containers":[
+			{"usage":{"cpu":"250000000n","memory":"64Mi"}},{"usage":{"cpu":"5m","memory":"65536Ki"}}]}]}`))
+	})
+	mux.HandleFunc("/apis/authorization.k8s.io/v1/selfsubjectaccessreviews", func(w http.ResponseWriter, r *http.Request) {
+		var review struct {
+			Spec struct {
+				ResourceAttributes map[string]string `json:"resourceAttributes"`
+			} `json:"spec"`
+		}
+		json.NewDecoder(r.Body).Decode(&review)
+		allowed := review.Spec.ResourceAttributes["resource"] != "secrets"
+		json.NewEncoder(w).Encode(map[string]any{"status": map[string]any{"allowed": allowed}})
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// client-go decodes discovery documents and error statuses by their content type
+		w.Header().Set("Content-Type", "application/json")
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := kube.NewClient(&kube.RestConfig{
+		Namespace: "apps",
+		Config:    &rest.Config{Host: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return NewK8sAPIExecuter(client, KubernetesExecuterType)
+}
+
+func TestK8sAPIExecuter_Run(t *testing.T) {
+	kx := newTestK8sAPIExecuter(t)
+
+	tests := []struct {
+		name     string
+		command  string
+		expected []string
+		excluded []string
+	}{
+		{
+			name:     "Table",
+			command:  "kubectl get pods",
+			expected: []string{"NAME    READY", "web-0   1/1", "web-1   0/1"},
+			excluded: []string{"10.0.0.1"},
+		},
+		{
+			name:     "Wide table",
+			command:  "kubectl get po -o wide",
+			expected: []string{"IP", "10.0.0.1", "<none>"},
+		},
+		{
+			name:     "Empty namespace",
+			command:  "kubectl get pods -n other",
+			expected: []string{"No resources found in other namespace."},
+		},
+		{
+			name:     "YAML",
+			command:  "kubectl get pod web-0 -o yaml",
+			expected: []string{"kind: Pod", "  name: web-0", "  replicas: 1"},
+			excluded: []string{"managedFields"},
+		},
+		{
+			name:     "Names",
+			command:  "kubectl get pods -o name",
+			expected: []string{"pod/web-0\npod/web-1"},
+		},
+		{
+			name:     "Describe",
+			command:  "kubectl describe pod web-0",
+			expected: []string{"name: web-0", "Events:", "Warning  BackOff  5m", "kubelet  Back-off restarting failed container"},
+		},
+		{
+			name:     "Logs",
+			command:  "kubectl logs web-0 -c app --tail=10",
+			expected: []string{"line 1\nerror: line 2"},
+		},
+		{
+			name:     "Top",
+			command:  "kubectl top pods",
+			expected: []string{"NAME    CPU(cores)   MEMORY(bytes)", "web-0   255m         128Mi"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := kx.Run(context.Background(), tt.command)
+			if result.Error != nil {
+				t.Fatalf("Run() error = %v, output %q", result.Error, result.Stdout)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(result.Stdout, expected) {
+					t.Errorf("Run() = %q, want it to contain %q", result.Stdout, expected)
+				}
+			}
+			for _, excluded := range tt.excluded {
+				if strings.Contains(result.Stdout, excluded) {
+					t.Errorf("Run() = %q, want it not to contain %q", result.Stdout, excluded)
+				}
+			}
+		})
+	}
+}
+
+func TestK8sAPIExecuter_RunErrors(t *testing.T) {
+	kx := newTestK8sAPIExecuter(t)
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{"Unknown resource", "kubectl get widgets", `the server doesn't have a resource type "widgets"`},
+		{"Not found", "kubectl get pod missing", "Error from server (NotFound)"},
+		{"Unsupported output", "kubectl get pods -o jsonpath={.items}", `output format "jsonpath={.items}" is not supported`},
+		{"Unsupported sub command", "kubectl explain pods", "kubectl explain is not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := kx.Run(context.Background(), tt.command)
+			if result.Error == nil {
+				t.Fatalf("Run() expected an error")
+			}
+			if !strings.Contains(result.Stderr, tt.expected) {
+				t.Errorf("Run() stderr = %q, want it to contain %q", result.Stderr, tt.expected)
+			}
+			if result.ExitCode != 1 {
+				t.Errorf("Run() exit code = %d, want 1", result.ExitCode)
+			}
+		})
+	}
+
+	if len(kx.ExecutedCommands()) != 0 {
+		t.Errorf("failed commands must not be cached")
+	}
+}
+
+func TestK8sAPIExecuter_Pipeline(t *testing.T) {
+	kx := newTestK8sAPIExecuter(t)
+
+	result := kx.Run(context.Background(), "kubectl logs web-0 -c app --tail 10 | grep 'error:'")
+	if result.Error != nil {
+		t.Fatalf("Run() error = %v", result.Error)
+	}
+	if result.Stdout != "error: line 2" {
+		t.Errorf("Run() = %q, want %q", result.Stdout, "error: line 2")
+	}
+	if _, cached := kx.ExecutedCommands()["kubectl logs web-0 -c app --tail 10 | grep 'error:'"]; !cached {
+		t.Errorf("successful commands must be cached")
+	}
+}
+
+func TestK8sAPIExecuter_Preflight(t *testing.T) {
+	kx := newTestK8sAPIExecuter(t)
+
+	warning, err := kx.Preflight(context.Background(), "kubectl get pods")
+	if err != nil || warning != "" {
+		t.Errorf("Preflight() = %q, %v, want no warning", warning, err)
+	}
+
+	warning, err = kx.Preflight(context.Background(), "kubectl get secrets")
+	if err != nil {
+		t.Fatalf("Preflight() error = %v", err)
+	}
+	if expected := "RBAC will deny `list secrets` in namespace apps"; warning != expected {
+		t.Errorf("Preflight() = %q, want %q", warning, expected)
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		quantity string
+		expected float64
+	}{
+		{"250m", 0.25},
+		{"2", 2},
+		{"1500000n", 0.0015},
+		{"128Mi", 128 << 20},
+		{"1Gi", 1 << 30},
+		{"1k", 1000},
+		{"invalid", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseQuantity(tt.quantity); got != tt.expected {
+			t.Errorf("parseQuantity(%q) = %v, want %v", tt.quantity, got, tt.expected)
+		}
+	}
+}
+
+func TestK8sAPIExecuter_PinnedNamespace(t *testing.T) {
+	kx := newTestK8sAPIExecuter(t)
+	kx.executerType.RewriteCommand = KubectlScope{Namespace: "other"}.Apply
+
+	result := kx.Run(context.Background(), "kubectl get pods -n apps")
+	if result.Error != nil {
+		t.Fatalf("Run() error = %v", result.Error)
+	}
+	if expected := "No resources found in other namespace."; result.Stdout != expected {
+		t.Errorf("Run() = %q, want %q", result.Stdout, expected)
+	}
+}
@@ -0,0 +1,300 @@
+package executer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// kubectlArgs is a parsed kubectl command line.
+type kubectlArgs struct {
+	SubCommand    string
+	Positional    []string
+	Namespace     string
+	AllNamespaces bool
+	Output        string
+	Selector      string
+	FieldSelector string
+	Container     string
+	Tail          string
+	Since         string
+	Previous      bool
+	Timestamps    bool
+	FromFiles     bool              // resources are given with -f or -k
+	Flags         map[string]string // all flags with values, keyed by their long name
+}
+
+// kubectlValueFlags are the kubectl flags that take a separate value.
+var kubectlValueFlags = []string{
+	"-n", "--namespace",
+	"-l", "--selector",
+	"-o", "--output",
+	"-c", "--container",
+	"-L", "--label-columns",
+	"-f", "--filename",
+	"-k", "--kustomize",
+	"--context", "--kubeconfig", "--cluster", "--user", "-s", "--server", "--token",
+	"--as", "--as-group", "--as-uid", "--username", "--password",
+	"--certificate-authority", "--client-certificate", "--client-key", "--tls-server-name",
+	"--field-selector", "--sort-by", "--template", "--chunk-size", "--raw", "--subresource",
+	"--tail", "--since", "--since-time", "--limit-bytes",
+	"--max-log-requests", "--pod-running-timeout", "--request-timeout",
+}
+
+// kubectlShortFlags maps short kubectl flags to their long names.
+var kubectlShortFlags = map[string]string{
+	"-n": "--namespace",
+	"-l": "--selector",
+	"-o": "--output",
+	"-c": "--container",
+	"-L": "--label-columns",
+	"-f": "--filename",
+	"-k": "--kustomize",
+	"-A": "--all-namespaces",
+	"-p": "--previous",
+	"-s": "--server",
+}
+
+// kubectlShortValueFlags are the short kubectl flags that take a value, which ends a
+// cluster of short flags.
+const kubectlShortValueFlags = "nlocLfks"
+
+// kubectlConnectionFlags are the kubectl flags that point a command at another cluster,
+// user or identity than the ones of its context. A scope strips them all.
+var kubectlConnectionFlags = []string{
+	"--cluster", "--user", "-s", "--server", "--token", "--as", "--as-group", "--as-uid", "--username", "--password",
+	"--certificate-authority", "--client-certificate", "--client-key", "--tls-server-name", "--insecure-skip-tls-verify",
+}
+
+// parseKubectlArgs parses the arguments of a kubectl command.
+func parseKubectlArgs(cmd Command) (kubectlArgs, error) {
+	if len(cmd.Parts) < 2 {
+		return kubectlArgs{}, ErrInvalidMainCommand
+	}
+
+	parsed := kubectlArgs{
+		SubCommand: unquote(cmd.Parts[1]),
+		Flags:      make(map[string]string),
+	}
+
+	args := cmd.Parts[2:]
+	for i := 0; i < len(args); i++ {
+		// quotes are removed before kubectl sees the word, so "--raw" is a flag
+		arg := unquote(args[i])
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			parsed.Positional = append(parsed.Positional, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		if long, ok := kubectlShortFlags[name]; ok {
+			name = long
+		}
+		if !hasValue && slices.Contains(kubectlValueFlags, name) {
+			if i+1 >= len(args) {
+				return kubectlArgs{}, fmt.Errorf("flag needs an argument: %s", arg)
+			}
+			i++
+			value, hasValue = unquote(args[i]), true
+		}
+
+		switch name {
+		case "--namespace":
+			parsed.Namespace = value
+		case "--all-namespaces":
+			parsed.AllNamespaces = !hasValue || value == "true"
+		case "--output":
+			parsed.Output = value
+		case "--selector":
+			parsed.Selector = value
+		case "--field-selector":
+			parsed.FieldSelector = value
+		case "--container":
+			parsed.Container = value
+		case "--tail":
+			parsed.Tail = value
+		case "--since":
+			parsed.Since = value
+		case "--previous":
+			parsed.Previous = !hasValue || value == "true"
+		case "--timestamps":
+			parsed.Timestamps = !hasValue || value == "true"
+		case "--filename", "--kustomize":
+			parsed.FromFiles = true
+		}
+		if hasValue {
+			parsed.Flags[name] = value
+		}
+	}
+
+	return parsed, nil
+}
+
+// kubectlTarget is a resource type with an optional object name.
+type kubectlTarget struct {
+	Resource string
+	Name     string
+}
+
+// targets expands the resource arguments of `kubectl get` forms such as `pods`,
+// `pod web-0 web-1`, `pods,services` and `deploy/web svc/web`.
+func (a kubectlArgs) targets() []kubectlTarget {
+	if len(a.Positional) == 0 {
+		return nil
+	}
+
+	if strings.Contains(a.Positional[0], "/") {
+		var targets []kubectlTarget
+		for _, arg := range a.Positional {
+			resource, name, _ := strings.Cut(arg, "/")
+			targets = append(targets, kubectlTarget{Resource: resource, Name: name})
+		}
+		return targets
+	}
+
+	resources := strings.Split(a.Positional[0], ",")
+	if len(a.Positional) == 1 || len(resources) > 1 {
+		targets := make([]kubectlTarget, 0, len(resources))
+		for _, resource := range resources {
+			targets = append(targets, kubectlTarget{Resource: resource})
+		}
+		return targets
+	}
+
+	targets := make([]kubectlTarget, 0, len(a.Positional)-1)
+	for _, name := range a.Positional[1:] {
+		targets = append(targets, kubectlTarget{Resource: resources[0], Name: name})
+	}
+	return targets
+}
+
+// unquote returns the value a command receives for a word, so commands are validated
+// with the arguments they run with, such as secrets for secre"ts".
+func unquote(value string) string {
+	return UnquoteWord(value)
+}
+
+// KubectlScope pins kubectl commands to a kubeconfig, context and namespace. Empty
+// fields leave the command unchanged.
+type KubectlScope struct {
+	Kubeconfig string
+	Context    string
+	Namespace  string
+}
+
+// IsZero reports whether the scope pins nothing.
+func (s KubectlScope) IsZero() bool {
+	return s == KubectlScope{}
+}
+
+// Apply replaces the scope flags of a kubectl command with the pinned values, whatever
+// the command asked for. A pinned namespace also replaces --all-namespaces, and the
+// flags of kubectlConnectionFlags are dropped so the command cannot leave the scope
+// for another server, user or impersonated identity. The arguments after --, such as
+// the command of kubectl exec, are kept as they are.
+func (s KubectlScope) Apply(cmd Command) Command {
+	if len(cmd.Parts) == 0 || cmd.Parts[0] != "kubectl" || s.IsZero() {
+		return cmd
+	}
+
+	stripped := slices.Clone(kubectlConnectionFlags)
+	if s.Kubeconfig != "" {
+		stripped = append(stripped, "--kubeconfig")
+	}
+	if s.Context != "" {
+		stripped = append(stripped, "--context")
+	}
+	if s.Namespace != "" {
+		stripped = append(stripped, "--namespace", "-n", "--all-namespaces", "-A")
+	}
+
+	parts := []string{cmd.Parts[0]}
+	var rest []string
+	for i := 1; i < len(cmd.Parts); i++ {
+		part := cmd.Parts[i]
+		// flags are matched as kubectl receives them, without their quotes
+		word := unquote(part)
+		if word == "--" {
+			rest = cmd.Parts[i:]
+			break
+		}
+		name, _, hasValue := strings.Cut(word, "=")
+		flags := shortFlags(word, kubectlShortValueFlags)
+		switch {
+		case slices.Contains(stripped, name):
+			if !hasValue && slices.Contains(kubectlValueFlags, name) && i+1 < len(cmd.Parts) {
+				i++ // skip the separate value
+			}
+			continue
+		case slices.ContainsFunc(flags, func(flag rune) bool { return slices.Contains(stripped, "-"+string(flag)) }):
+			// clusters such as -nvalue, -svalue or -wA
+			if strings.ContainsRune(kubectlShortValueFlags, flags[len(flags)-1]) && len(word) == len(flags)+1 && i+1 < len(cmd.Parts) {
+				i++ // skip the separate value of the last flag
+			}
+			continue
+		}
+		parts = append(parts, part)
+	}
+
+	var flags []string
+	if s.Kubeconfig != "" {
+		flags = append(flags, "--kubeconfig="+shellQuote(s.Kubeconfig))
+	}
+	if s.Context != "" {
+		flags = append(flags, "--context="+shellQuote(s.Context))
+	}
+	if s.Namespace != "" {
+		flags = append(flags, "--namespace="+shellQuote(s.Namespace))
+	}
+
+	return Command{Parts: append(append(parts, flags...), rest...)}
+}
+
+// NamedContexts returns the contexts and clusters the kubectl commands of a command line
+// name with --context and --cluster, once the scope replaced their flags. Every value is
+// returned, the last one of a command being the one kubectl uses.
+func (s KubectlScope) NamedContexts(command string) []string {
+	var names []string
+	for _, cmd := range SplitPipeline(command) {
+		cmd = s.Apply(cmd)
+		if len(cmd.Parts) == 0 || cmd.Parts[0] != "kubectl" {
+			continue
+		}
+		for i := 1; i < len(cmd.Parts) && unquote(cmd.Parts[i]) != "--"; i++ {
+			name, value, hasValue := strings.Cut(unquote(cmd.Parts[i]), "=")
+			if name != "--context" && name != "--cluster" {
+				continue
+			}
+			if !hasValue && i+1 < len(cmd.Parts) {
+				i++
+				value = unquote(cmd.Parts[i])
+			}
+			names = append(names, value)
+		}
+	}
+	return names
+}
+
+// shellQuote quotes a value for sh unless it only contains safe characters.
+func shellQuote(value string) string {
+	safe := value != ""
+	for _, char := range value {
+		if !(char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' || strings.ContainsRune("_./:@,+-~", char)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// joinCommands rebuilds a pipeline from its commands.
+func joinCommands(cmds []Command) string {
+	parts := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		parts = append(parts, strings.Join(cmd.Parts, " "))
+	}
+	return strings.Join(parts, " | ")
+}
@@ -0,0 +1,160 @@
+package executer
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKubectlScope_Apply(t *testing.T) {
+	scope := KubectlScope{Kubeconfig: "/tmp/kube config", Context: "staging", Namespace: "apps"}
+
+	tests := []struct {
+		name     string
+		scope    KubectlScope
+		command  string
+		expected string
+	}{
+		{
+			name:     "Adds the pinned flags",
+			scope:    scope,
+			command:  "kubectl get pods",
+			expected: "kubectl get pods --kubeconfig='/tmp/kube config' --context=staging --namespace=apps",
+		},
+		{
+			name:     "Replaces the requested scope",
+			scope:    scope,
+			command:  "kubectl get pods -n kube-system --context prod --kubeconfig=/other -o wide",
+			expected: "kubectl get pods -o wide --kubeconfig='/tmp/kube config' --context=staging --namespace=apps",
+		},
+		{
+			name:     "Drops all namespaces",
+			scope:    KubectlScope{Namespace: "apps"},
+			command:  "kubectl get pods -A -nother --all-namespaces=true",
+			expected: "kubectl get pods --namespace=apps",
+		},
+		{
+			name:     "Drops the cluster",
+			scope:    KubectlScope{Context: "staging"},
+			command:  "kubectl get pods --cluster prod-cluster --cluster=prod",
+			expected: "kubectl get pods --context=staging",
+		},
+		{
+			name:     "Drops the user",
+			scope:    KubectlScope{Context: "staging"},
+			command:  "kubectl get pods --user admin --user=admin",
+			expected: "kubectl get pods --context=staging",
+		},
+		{
+			name:     "Drops the server",
+			scope:    KubectlScope{Context: "staging"},
+			command:  "kubectl get pods -s https://prod:6443 --server=https://prod:6443 -shttps://prod:6443 --insecure-skip-tls-verify",
+			expected: "kubectl get pods --context=staging",
+		},
+		{
+			name:     "Drops the token",
+			scope:    KubectlScope{Namespace: "apps"},
+			command:  "kubectl get pods --token abc --token=abc",
+			expected: "kubectl get pods --namespace=apps",
+		},
+		{
+			name:     "Drops impersonation",
+			scope:    KubectlScope{Context: "staging"},
+			command:  "kubectl get secrets --as system:admin --as=admin --as-group=system:masters",
+			expected: "kubectl get secrets --context=staging",
+		},
+		{
+			name:     "Drops quoted flags",
+			scope:    KubectlScope{Context: "dev", Namespace: "app"},
+			command:  `kubectl get pods "--as=admin" "-A" '--server=https://evil' "--token=x" "--context" prod '-n'other`,
+			expected: "kubectl get pods --context=dev --namespace=app",
+		},
+		{
+			name:     "Drops clusters of short flags",
+			scope:    KubectlScope{Namespace: "app"},
+			command:  "kubectl get pods -wA -wn kube-system -Ac web",
+			expected: "kubectl get pods --namespace=app",
+		},
+		{
+			name:     "Keeps the namespace when only the context is pinned",
+			scope:    KubectlScope{Context: "staging"},
+			command:  "kubectl get pods -n web",
+			expected: "kubectl get pods -n web --context=staging",
+		},
+		{
+			name:     "Keeps the arguments after --",
+			scope:    KubectlScope{Context: "staging", Namespace: "apps"},
+			command:  "kubectl exec -it web -n other -- env -n 1",
+			expected: "kubectl exec -it web --context=staging --namespace=apps -- env -n 1",
+		},
+		{
+			name:     "Ignores other commands",
+			scope:    scope,
+			command:  "helm list -n web",
+			expected: "helm list -n web",
+		},
+		{
+			name:     "Empty scope",
+			scope:    KubectlScope{},
+			command:  "kubectl get pods -A",
+			expected: "kubectl get pods -A",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := Command{Parts: strings.Fields(tt.command)}
+			result := strings.Join(tt.scope.Apply(cmd).Parts, " ")
+			if result != tt.expected {
+				t.Errorf("Apply() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKubectlScope_NamedContexts(t *testing.T) {
+	tests := []struct {
+		name     string
+		scope    KubectlScope
+		command  string
+		expected []string
+	}{
+		{"No flags", KubectlScope{}, "kubectl get pods", nil},
+		{"Context and cluster", KubectlScope{}, "kubectl --context prod get pods --cluster='prod cluster' | grep web", []string{"prod", "prod cluster"}},
+		{"Every value", KubectlScope{Namespace: "apps"}, "kubectl get pods --context=staging --context prod", []string{"staging", "prod"}},
+		{"Pinned context", KubectlScope{Context: "staging"}, "kubectl get pods --context prod --cluster prod", []string{"staging"}},
+		{"Quoted flags", KubectlScope{}, `kubectl get pods "--context=prod" '--cluster' "prod cluster"`, []string{"prod", "prod cluster"}},
+		{"Arguments after --", KubectlScope{}, "kubectl exec web -- app --context prod", nil},
+		{"Other commands", KubectlScope{}, "helm list --kube-context prod", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.scope.NamedContexts(tt.command); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("NamedContexts() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTerminalExecuter_RewriteCommand(t *testing.T) {
+	executerType := testExecuterType
+	executerType.RewriteCommand = func(cmd Command) Command {
+		return Command{Parts: append(cmd.Parts, "world")}
+	}
+	te := NewTerminalExecuter(executerType)
+
+	result := te.Run(context.Background(), "echo hello | grep hello")
+	if result.Error != nil {
+		t.Fatalf("Run() error = %v", result.Error)
+	}
+	if result.Stdout != "hello world" {
+		t.Errorf("Run() = %q, want %q", result.Stdout, "hello world")
+	}
+
+	expected := map[string]string{"echo hello | grep hello": "hello world"}
+	if !reflect.DeepEqual(te.ExecutedCommands(), expected) {
+		t.Errorf("ExecutedCommands() = %v, want %v", te.ExecutedCommands(), expected)
+	}
+}
@@ -0,0 +1,401 @@
+package executer
+
+import (
+	"strings"
+)
+
+// tokenKind is the kind of a token of a POSIX shell command line.
+type tokenKind int
+
+const (
+	tokenWord        tokenKind = iota
+	tokenPipe                  // |
+	tokenNewline               // a newline outside quotes, which ends a command
+	tokenControl               // control operators that chain commands, such as ; && || &
+	tokenRedirection           // < > >> << <<- <& >& <> >|
+	tokenSubshell              // ( and ), which group commands
+)
+
+// shellOperators are the operators of the POSIX shell, longest first.
+var shellOperators = []struct {
+	text string
+	kind tokenKind
+}{
+	{"<<-", tokenRedirection},
+	{"&&", tokenControl},
+	{"||", tokenControl},
+	{";;", tokenControl},
+	{"<<", tokenRedirection},
+	{">>", tokenRedirection},
+	{"<&", tokenRedirection},
+	{">&", tokenRedirection},
+	{"<>", tokenRedirection},
+	{">|", tokenRedirection},
+	{"|", tokenPipe},
+	{"&", tokenControl},
+	{";", tokenControl},
+	{"<", tokenRedirection},
+	{">", tokenRedirection},
+	{"(", tokenSubshell},
+	{")", tokenSubshell},
+}
+
+// shellToken is a token of a command line. Words keep their quotes, and record the
+// expansions the shell would apply to them.
+type shellToken struct {
+	kind tokenKind
+	text string
+
+	substitution bool // runs a command, with $(...) or backquotes
+	glob         bool // has unquoted pattern characters, which expand to file names
+	ansiQuote    bool // uses $'...' or $"...", which sh and bash read differently
+}
+
+// lexShell splits a POSIX shell command line into words and operators, following the
+// token recognition rules of the shell: quotes, backslash escapes, comments, parameter
+// expansions and command substitutions. On an unmatched quote it returns the tokens so
+// far, with the rest of the line as the last word, and ErrUnmatchedQuote.
+func lexShell(line string) ([]shellToken, error) {
+	var tokens []shellToken
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '\n' || c == '\r':
+			tokens = append(tokens, shellToken{kind: tokenNewline, text: line[i : i+1]})
+			i++
+		case c == '\\' && i+1 < len(line) && line[i+1] == '\n':
+			// a line continuation between words
+			i += 2
+		case c == '#':
+			// a comment runs to the end of the line
+			end := strings.IndexAny(line[i:], "\r\n")
+			if end < 0 {
+				return tokens, nil
+			}
+			i += end
+		case isShellOperator(c):
+			for _, op := range shellOperators {
+				if strings.HasPrefix(line[i:], op.text) {
+					tokens = append(tokens, shellToken{kind: op.kind, text: op.text})
+					i += len(op.text)
+					break
+				}
+			}
+		default:
+			token, end, err := lexWord(line, i)
+			tokens = append(tokens, token)
+			if err != nil {
+				return tokens, err
+			}
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// isShellOperator reports whether c starts an operator and ends a word outside quotes.
+func isShellOperator(c byte) bool {
+	return strings.IndexByte("|&;<>()", c) >= 0
+}
+
+// lexWord reads the word that starts at line[start] and returns it with the index
+// after it.
+func lexWord(line string, start int) (shellToken, int, error) {
+	token := shellToken{kind: tokenWord}
+	unterminated := func() (shellToken, int, error) {
+		token.text = line[start:]
+		return token, len(line), ErrUnmatchedQuote
+	}
+
+	parameters := 0                      // depth of ${...} expansions, where blanks and operators do not end the word
+	braceOpen, braceList := false, false // unquoted {a,b}, which bash expands like a glob
+	i := start
+	for i < len(line) {
+		c := line[i]
+		if parameters == 0 && (c == ' ' || c == '\t' || c == '\n' || c == '\r' || isShellOperator(c)) {
+			break
+		}
+
+		switch c {
+		case '\\':
+			i += 2
+			continue
+		case '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return unterminated()
+			}
+			i += end + 2
+			continue
+		case '"':
+			end, ok := lexDoubleQuoted(line, i+1, &token)
+			if !ok {
+				return unterminated()
+			}
+			i = end
+			continue
+		case '`':
+			end, ok := skipBackquoted(line, i+1)
+			token.substitution = true
+			if !ok {
+				return unterminated()
+			}
+			i = end
+			continue
+		case '$':
+			end, ok := lexDollar(line, i, &token)
+			if !ok {
+				return unterminated()
+			}
+			if strings.HasPrefix(line[i:], "${") {
+				parameters++
+			}
+			i = end
+			continue
+		case '}':
+			if parameters > 0 {
+				parameters--
+			} else if braceList {
+				token.glob = true
+			}
+		case '*', '?', '[':
+			if parameters == 0 {
+				token.glob = true
+			}
+		case '{':
+			braceOpen = parameters == 0
+		case ',':
+			braceList = braceList || braceOpen
+		case '.':
+			braceList = braceList || braceOpen && strings.HasPrefix(line[i:], "..")
+		}
+		i++
+	}
+	if parameters > 0 {
+		return unterminated()
+	}
+
+	token.text = line[start:min(i, len(line))]
+	return token, min(i, len(line)), nil
+}
+
+// lexDoubleQuoted reads a double quoted string from line[start], after its opening
+// quote, and returns the index after its closing quote. Backquotes and $( still run
+// commands inside double quotes.
+func lexDoubleQuoted(line string, start int, token *shellToken) (int, bool) {
+	for i := start; i < len(line); {
+		switch line[i] {
+		case '\\':
+			i += 2
+		case '"':
+			return i + 1, true
+		case '`':
+			end, ok := skipBackquoted(line, i+1)
+			token.substitution = true
+			if !ok {
+				return len(line), false
+			}
+			i = end
+		case '$':
+			if strings.HasPrefix(line[i:], "$(") {
+				token.substitution = true
+				end, ok := skipParentheses(line, i+1)
+				if !ok {
+					return len(line), false
+				}
+				i = end
+				continue
+			}
+			i++
+		default:
+			i++
+		}
+	}
+	return len(line), false
+}
+
+// lexDollar reads an expansion that starts with $ at line[start] and returns the index
+// after it, or after ${ for parameter expansions, whose end the caller tracks.
+func lexDollar(line string, start int, token *shellToken) (int, bool) {
+	if start+1 >= len(line) {
+		return start + 1, true
+	}
+
+	switch next := line[start+1]; {
+	case next == '(':
+		token.substitution = true
+		return skipParentheses(line, start+1)
+	case next == '{':
+		return start + 2, true
+	case next == '\'' || next == '"':
+		token.ansiQuote = true
+		return start + 1, true
+	case strings.IndexByte("?*#@!$-0123456789", next) >= 0:
+		// a special parameter, such as $? or $1
+		return start + 2, true
+	default:
+		return start + 1, true
+	}
+}
+
+// skipBackquoted returns the index after the closing backquote of a command
+// substitution that starts at line[start], after its opening backquote.
+func skipBackquoted(line string, start int) (int, bool) {
+	for i := start; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '`':
+			return i + 1, true
+		}
+	}
+	return len(line), false
+}
+
+// skipParentheses returns the index after the parenthesis that closes the one at
+// line[start], skipping quoted parentheses.
+func skipParentheses(line string, start int) (int, bool) {
+	depth := 0
+	for i := start; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return len(line), false
+			}
+			i += end + 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1, true
+			}
+		}
+	}
+	return len(line), false
+}
+
+// splitShellPipeline splits a POSIX shell command line into its piped commands. Words
+// keep their quotes, and operators other than the pipe are kept as parts so they are
+// rejected during validation. Newlines that end the line are dropped.
+func splitShellPipeline(line string) []Command {
+	tokens, _ := lexShell(line)
+	for len(tokens) > 0 && tokens[len(tokens)-1].kind == tokenNewline {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	commands := []Command{{}}
+	for _, token := range tokens {
+		if token.kind == tokenPipe {
+			commands = append(commands, Command{})
+			continue
+		}
+		last := &commands[len(commands)-1]
+		last.Parts = append(last.Parts, token.text)
+	}
+	return commands
+}
+
+// validateShellWords rejects anything in a part of a POSIX command line but plain
+// words: operators, command substitutions, globs and ANSI-C quotes.
+func validateShellWords(arg string) error {
+	tokens, err := lexShell(arg)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		switch token.kind {
+		case tokenPipe, tokenControl, tokenNewline:
+			return ErrCommandChaining
+		case tokenRedirection:
+			return ErrRedirection
+		case tokenSubshell:
+			return ErrSubshell
+		}
+
+		switch {
+		case token.substitution:
+			return ErrCommandSubstitution
+		case token.ansiQuote:
+			return ErrANSIQuote
+		case token.glob:
+			return ErrGlob
+		}
+	}
+	return nil
+}
+
+// UnquoteWord removes the quotes and backslash escapes of a word, as the shell does
+// before it passes the word to a command. Parameters such as $HOME are not expanded.
+func UnquoteWord(word string) string {
+	var b strings.Builder
+	for i := 0; i < len(word); i++ {
+		switch c := word[i]; c {
+		case '\\':
+			if i+1 == len(word) {
+				b.WriteByte(c)
+				continue
+			}
+			i++
+			if word[i] != '\n' {
+				b.WriteByte(word[i])
+			}
+		case '\'':
+			end := strings.IndexByte(word[i+1:], '\'')
+			if end < 0 {
+				end = len(word) - i - 1
+			}
+			b.WriteString(word[i+1 : i+1+end])
+			i += end + 1
+		case '"':
+			for i++; i < len(word) && word[i] != '"'; i++ {
+				// inside double quotes, a backslash only escapes $ ` " \ and newlines
+				if word[i] == '\\' && i+1 < len(word) && strings.IndexByte("$`\"\\\n", word[i+1]) >= 0 {
+					i++
+					if word[i] == '\n' {
+						continue
+					}
+				}
+				b.WriteByte(word[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// shellArgs splits a POSIX command line into the arguments of its piped commands, with
+// quotes and escapes removed. Anything but words and pipes is rejected, as commands run
+// without a shell.
+func shellArgs(line string) ([][]string, error) {
+	cmds := splitShellPipeline(line)
+	if len(cmds) == 0 {
+		return nil, ErrEmptyCommand
+	}
+
+	args := make([][]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		if len(cmd.Parts) == 0 {
+			return nil, ErrEmptyCommand
+		}
+		argv := make([]string, 0, len(cmd.Parts))
+		for _, part := range cmd.Parts {
+			if err := validateShellWords(part); err != nil {
+				return nil, err
+			}
+			argv = append(argv, UnquoteWord(part))
+		}
+		args = append(args, argv)
+	}
+	return args, nil
+}
@@ -0,0 +1,152 @@
+package executer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var lexerExecuterType = TerminalExecuterType{
+	AllowedCommands:      []string{"echo", "kubectl"},
+	AllowedPipedCommands: []string{"grep", "jq"},
+	Shell:                ShellPOSIX,
+}
+
+func TestTerminalExecuter_ValidateShellSyntax(t *testing.T) {
+	te := NewTerminalExecuter(lexerExecuterType)
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"Backslash in single quotes", `echo 'a\' | grep a`, nil},
+		{"Quoted operators", `echo "a; b && c > d" '$(e)' | grep ';'`, nil},
+		{"Escaped operators", `echo a\;b \> \| grep a`, nil},
+		{"Parameter expansion", `echo "$HOME" ${USER:-nobody} $?`, nil},
+		{"Parameter expansion with blanks", `echo ${A:-a b}`, nil},
+		{"Quoted jsonpath", `kubectl get pods -o jsonpath='{.items[*].metadata.name}'`, nil},
+		{"Quoted jq filter", `kubectl get pods -o json | jq '.items[] | select(.status.phase != "Running")'`, nil},
+		{"Line continuation", "echo a \\\n  b", nil},
+		{"Comment", "echo a # a comment; rm -rf /", nil},
+		{"Trailing newline", "echo a | grep a\n", nil},
+		{"Chaining", "echo a; rm x", ErrCommandChaining},
+		{"And list", "echo a&&rm x", ErrCommandChaining},
+		{"Background", "echo a & rm x", ErrCommandChaining},
+		{"Or list", "echo a || rm x", ErrCommandChaining},
+		{"Newline", "echo a\nrm x", ErrCommandChaining},
+		{"Newline after a comment", "echo a # comment\nrm x", ErrCommandChaining},
+		{"Redirection", "echo a>out", ErrRedirection},
+		{"File descriptor redirection", "echo a 2>&1", ErrRedirection},
+		{"Here document", "echo a <<EOF", ErrRedirection},
+		{"Process substitution", "echo <(rm x)", ErrRedirection},
+		{"Subshell", "echo (rm x)", ErrSubshell},
+		{"Parenthesis in a word", "echo a(b)", ErrSubshell},
+		{"Substitution", "echo $(rm x)", ErrCommandSubstitution},
+		{"Substitution in double quotes", `echo "$(rm x)"`, ErrCommandSubstitution},
+		{"Backquotes in double quotes", "echo \"`rm x`\"", ErrCommandSubstitution},
+		{"Substitution in a parameter expansion", "echo ${A:-$(rm x)}", ErrCommandSubstitution},
+		{"Arithmetic expansion", "echo $((1+2))", ErrCommandSubstitution},
+		{"ANSI-C quotes", `echo $'a\' ; rm x ; '`, ErrANSIQuote},
+		{"Glob", "kubectl get pods -n *", ErrGlob},
+		{"Bracket glob", "echo [ab]", ErrGlob},
+		{"Brace expansion", "echo {a,b}", ErrGlob},
+		{"Unmatched single quote", "echo 'a", ErrUnmatchedQuote},
+		{"Unmatched double quote", `echo "a\"`, ErrUnmatchedQuote},
+		{"Unterminated parameter expansion", "echo ${A", ErrUnmatchedQuote},
+		{"Empty pipe", "echo a |", ErrEmptyCommand},
+		{"Only blanks", "\n ", ErrEmptyCommand},
+		{"Only a comment", "# echo a", ErrEmptyCommand},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := te.Validate(tt.command)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Validate() error = %v, want nil", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSplitShellPipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		expected []Command
+	}{
+		{
+			"Quotes are kept",
+			`echo "a | b" 'c'd | grep a`,
+			[]Command{{Parts: []string{"echo", `"a | b"`, "'c'd"}}, {Parts: []string{"grep", "a"}}},
+		},
+		{
+			"Operators are parts",
+			"echo a;rm x",
+			[]Command{{Parts: []string{"echo", "a", ";", "rm", "x"}}},
+		},
+		{
+			"Comments are dropped",
+			"echo a # | grep b",
+			[]Command{{Parts: []string{"echo", "a"}}},
+		},
+		{
+			"Substitutions are one word",
+			"echo $(a b) `c d`",
+			[]Command{{Parts: []string{"echo", "$(a b)", "`c d`"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := splitShellPipeline(tt.command); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("splitShellPipeline() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// FuzzTerminalExecuter_Validate checks that accepted command lines split the same way
+// after they are rebuilt, and that command lines without quotes or escapes are only
+// accepted without shell syntax.
+func FuzzTerminalExecuter_Validate(f *testing.F) {
+	for _, seed := range []string{
+		"echo hello | grep h",
+		`echo "a | b" 'c' | grep ';'`,
+		"echo a; rm x",
+		"echo $(rm x)",
+		"echo \"`rm x`\"",
+		"echo ${A:-a b} $?",
+		"echo a # comment\nrm x",
+		`echo $'a\' ; rm x'`,
+		"kubectl get pods -o json | jq '.items[]'",
+		"echo a \\\n b",
+		"echo {a,b} [c] *",
+	} {
+		f.Add(seed)
+	}
+
+	te := NewTerminalExecuter(lexerExecuterType)
+	f.Fuzz(func(t *testing.T, command string) {
+		if te.Validate(command) != nil {
+			return
+		}
+
+		cmds := splitShellPipeline(command)
+		if rebuilt := splitShellPipeline(joinCommands(cmds)); !reflect.DeepEqual(rebuilt, cmds) {
+			t.Errorf("splitShellPipeline(%q) = %q, rebuilt %q", command, cmds, rebuilt)
+		}
+
+		if strings.ContainsAny(command, `'"\#`) || strings.Contains(command, "${") {
+			return
+		}
+		unquoted := strings.NewReplacer("$?", "", "$*", "").Replace(strings.TrimRight(command, " \t\r\n"))
+		if strings.ContainsAny(unquoted, ";&<>()`*?[\r\n") || strings.Contains(command, "$(") {
+			t.Errorf("Validate(%q) accepted shell syntax", command)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package executer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// linuxFlagRule lists the flags of a host command that change the system or never exit.
+type linuxFlagRule struct {
+	denied      []string // long flags, matched with or without a value
+	deniedShort string   // short flags, also matched inside clusters such as -xef
+	valueShort  string   // short flags that take a value, which ends a cluster
+}
+
+var linuxFlagRules = map[string]linuxFlagRule{
+	"journalctl": {
+		denied: []string{
+			"--follow", "--vacuum-size", "--vacuum-time", "--vacuum-files", "--rotate", "--flush",
+			"--sync", "--relinquish-var", "--smart-relinquish-var", "--setup-keys", "--update-catalog",
+		},
+		deniedShort: "f",
+		valueShort:  "bcDgiMnopStTuU",
+	},
+	"dmesg": {
+		denied:      []string{"--clear", "--read-clear", "--console-off", "--console-on", "--console-level", "--follow", "--follow-new"},
+		deniedShort: "cCDEnwW",
+		valueShort:  "fFlns",
+	},
+	"ss": {
+		denied:      []string{"--kill", "--diag"},
+		deniedShort: "KD",
+		valueShort:  "ADfFN",
+	},
+	"free": {
+		denied:      []string{"--seconds", "--count"},
+		deniedShort: "sc",
+		valueShort:  "sc",
+	},
+}
+
+// validateLinuxCommand rejects host command flags that change the system or never exit.
+// top must run in batch mode for a single iteration.
+func validateLinuxCommand(cmd Command) error {
+	args := cmd.Parts[1:]
+
+	if cmd.Parts[0] == "top" {
+		return validateTopCommand(args)
+	}
+
+	rule, ok := linuxFlagRules[cmd.Parts[0]]
+	if !ok {
+		return nil
+	}
+
+	for _, arg := range args {
+		// flags are matched as the program receives them, without their quotes
+		arg = unquote(arg)
+		if flag, ok := matchLongFlag(arg, rule.denied); ok {
+			return fmt.Errorf("%w: %s %s", ErrOperationNotAllowed, cmd.Parts[0], flag)
+		}
+		for _, flag := range shortFlags(arg, rule.valueShort) {
+			if strings.ContainsRune(rule.deniedShort, flag) {
+				return fmt.Errorf("%w: %s -%c", ErrOperationNotAllowed, cmd.Parts[0], flag)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateTopCommand requires `top -b -n1`, so top prints one snapshot and exits.
+func validateTopCommand(args []string) error {
+	batch, iterations := false, ""
+	for i := 0; i < len(args); i++ {
+		arg := unquote(args[i])
+		flags := shortFlags(arg, "dnopuUwEeO")
+		for j, flag := range flags {
+			switch flag {
+			case 'b':
+				batch = true
+			case 'n':
+				// the value is the rest of the cluster or the next argument
+				if value := arg[j+2:]; value != "" {
+					iterations = value
+				} else if i+1 < len(args) {
+					i++
+					iterations = unquote(args[i])
+				}
+			}
+		}
+	}
+
+	if !batch || iterations != "1" {
+		return fmt.Errorf("%w: top must run as `top -b -n1`", ErrOperationNotAllowed)
+	}
+	return nil
+}
+
+// shortFlags returns the short flags of a cluster such as -xef, up to the first flag
+// that takes a value. It returns nothing for long flags and other arguments.
+func shortFlags(arg, valueFlags string) []rune {
+	if len(arg) < 2 || arg[0] != '-' || arg[1] == '-' {
+		return nil
+	}
+
+	var flags []rune
+	for _, flag := range arg[1:] {
+		flags = append(flags, flag)
+		if strings.ContainsRune(valueFlags, flag) {
+			break
+		}
+	}
+	return flags
+}
+
+// matchLongFlag returns the flag of flags a long flag names, in full or abbreviated, as
+// git and getopt_long accept any unambiguous prefix of a long flag.
+func matchLongFlag(arg string, flags []string) (string, bool) {
+	name, _, _ := strings.Cut(arg, "=")
+	if len(name) < 3 || !strings.HasPrefix(name, "--") {
+		return "", false
+	}
+	for _, flag := range flags {
+		if strings.HasPrefix(flag, name) {
+			return flag, true
+		}
+	}
+	return "", false
+}
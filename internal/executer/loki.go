@@ -0,0 +1,241 @@
+package executer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eliran89c/klama/internal/loki"
+)
+
+// LokiExecuterType allows logcli commands, which LokiExecuter answers from a Loki server
+// without the logcli binary.
+var LokiExecuterType = TerminalExecuterType{
+	AllowedCommands:      []string{"logcli"},
+	AllowedSubCommands:   []string{"query", "labels"},
+	AllowedPipedCommands: defaultPipedCommands,
+	ValidateCommand: func(cmd Command) error {
+		_, err := parseLogcliArgs(cmd)
+		return err
+	},
+}
+
+// CachingExecuter is an executer that caches command outputs and can check commands
+// before they run, such as TerminalExecuter and K8sAPIExecuter.
+type CachingExecuter interface {
+	Run(context.Context, string) ExecuterResponse
+	Validate(string) error
+	Preflight(context.Context, string) (string, error)
+	EffectiveCommand(string) string
+	ExecutedCommands() map[string]string
+	RestoreExecutedCommands(map[string]string)
+	SetCacheOptions(CacheOptions)
+	CacheEntries() map[string]CacheEntry
+	RestoreCacheEntries(map[string]CacheEntry)
+}
+
+// LokiLimits bound the logcli queries the agent runs.
+type LokiLimits struct {
+	DefaultRange time.Duration // time range of queries without --since or --from
+	MaxRange     time.Duration // longest time range a query may cover
+	MaxLines     int           // most log lines a query returns
+}
+
+// LokiExecuter answers logcli commands from a Loki server and runs every other command
+// with the wrapped executer. The wrapped executer validates the commands, so its type
+// must include LokiExecuterType. Log queries are not cached, as their time range is
+// relative to now.
+type LokiExecuter struct {
+	CachingExecuter
+
+	client *loki.Client
+	limits LokiLimits
+	now    func() time.Time
+}
+
+// NewLokiExecuter wraps the executer to answer logcli commands with the client.
+func NewLokiExecuter(executer CachingExecuter, client *loki.Client, limits LokiLimits) *LokiExecuter {
+	return &LokiExecuter{
+		CachingExecuter: executer,
+		client:          client,
+		limits:          limits,
+		now:             time.Now,
+	}
+}
+
+// Run answers logcli commands from Loki, piping the output through the piped commands,
+// and runs other commands with the wrapped executer.
+func (lx *LokiExecuter) Run(ctx context.Context, command string) ExecuterResponse {
+	cmds := splitPipeline(command, ShellPOSIX)
+	if len(cmds) == 0 || len(cmds[0].Parts) == 0 || cmds[0].Parts[0] != "logcli" {
+		return lx.CachingExecuter.Run(ctx, command)
+	}
+
+	ctx, span := startSpan(ctx, command)
+	start := lx.now()
+	output, err := lx.runLogcli(ctx, cmds[0])
+	if err == nil && len(cmds) > 1 {
+		output, err = runPipeline(ctx, output, cmds[1:], ShellPOSIX)
+	}
+	response := apiResponse(ctx, strings.TrimSpace(output), err, lx.now().Sub(start))
+	endSpan(span, response)
+	return response
+}
+
+// Preflight checks the commands of the wrapped executer, logcli needs no check.
+func (lx *LokiExecuter) Preflight(ctx context.Context, command string) (string, error) {
+	cmds := splitPipeline(command, ShellPOSIX)
+	if len(cmds) > 0 && len(cmds[0].Parts) > 0 && cmds[0].Parts[0] == "logcli" {
+		return "", nil
+	}
+	return lx.CachingExecuter.Preflight(ctx, command)
+}
+
+func (lx *LokiExecuter) runLogcli(ctx context.Context, cmd Command) (string, error) {
+	args, err := parseLogcliArgs(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	start, end, notes := lx.timeRange(args)
+
+	switch args.SubCommand {
+	case "labels":
+		var values []string
+		if args.Label == "" {
+			values, err = lx.client.Labels(ctx, start, end)
+		} else {
+			values, err = lx.client.LabelValues(ctx, args.Label, start, end)
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(append(notes, values...), "\n"), nil
+
+	default:
+		limit := args.Limit
+		if limit == 0 || limit > lx.limits.MaxLines {
+			if limit > lx.limits.MaxLines {
+				notes = append(notes, fmt.Sprintf("Note: --limit was lowered to %d lines.", lx.limits.MaxLines))
+			}
+			limit = lx.limits.MaxLines
+		}
+
+		result, err := lx.client.QueryRange(ctx, args.Query, start, end, limit)
+		if err != nil {
+			return "", err
+		}
+		output := result.String()
+		if output == "" {
+			output = fmt.Sprintf("No logs matched between %s and %s.", start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+		}
+		return strings.Join(append(notes, output), "\n"), nil
+	}
+}
+
+// timeRange returns the time range of the command, limited to the maximum range, and
+// notes that tell the agent when its range was changed.
+func (lx *LokiExecuter) timeRange(args logcliArgs) (time.Time, time.Time, []string) {
+	end := lx.now()
+	if !args.To.IsZero() {
+		end = args.To
+	}
+
+	start := end.Add(-lx.limits.DefaultRange)
+	switch {
+	case !args.From.IsZero():
+		start = args.From
+	case args.Since > 0:
+		start = end.Add(-args.Since)
+	}
+
+	var notes []string
+	if end.Sub(start) > lx.limits.MaxRange {
+		start = end.Add(-lx.limits.MaxRange)
+		notes = append(notes, fmt.Sprintf("Note: the time range was limited to the %s before %s.", lx.limits.MaxRange, end.UTC().Format(time.RFC3339)))
+	}
+	return start, end, notes
+}
+
+// logcliArgs are the parsed arguments of a logcli command.
+type logcliArgs struct {
+	SubCommand string
+	Query      string // LogQL query of logcli query
+	Label      string // label of logcli labels, empty lists the label names
+	Since      time.Duration
+	From, To   time.Time
+	Limit      int
+}
+
+// parseLogcliArgs parses the logcli query and logcli labels commands.
+func parseLogcliArgs(cmd Command) (logcliArgs, error) {
+	if len(cmd.Parts) < 2 {
+		return logcliArgs{}, ErrInvalidMainCommand
+	}
+	args := logcliArgs{SubCommand: cmd.Parts[1]}
+
+	var positional []string
+	parts := cmd.Parts[2:]
+	for i := 0; i < len(parts); i++ {
+		part := unquote(parts[i])
+		if !strings.HasPrefix(part, "-") {
+			positional = append(positional, part)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(part, "-"), "=")
+		if !hasValue {
+			if i+1 >= len(parts) {
+				return logcliArgs{}, fmt.Errorf("logcli flag --%s needs a value", name)
+			}
+			i++
+			value = unquote(parts[i])
+		}
+
+		var err error
+		switch name {
+		case "since":
+			args.Since, err = time.ParseDuration(value)
+			if err == nil && args.Since <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "from":
+			args.From, err = time.Parse(time.RFC3339, value)
+		case "to":
+			args.To, err = time.Parse(time.RFC3339, value)
+		case "limit":
+			args.Limit, err = strconv.Atoi(value)
+			if err == nil && args.Limit <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		default:
+			return logcliArgs{}, fmt.Errorf("%w: logcli flag --%s, use --since, --from, --to or --limit", ErrOperationNotAllowed, name)
+		}
+		if err != nil {
+			return logcliArgs{}, fmt.Errorf("invalid logcli flag --%s %q: %v", name, value, err)
+		}
+	}
+
+	if !args.From.IsZero() && !args.To.IsZero() && !args.From.Before(args.To) {
+		return logcliArgs{}, fmt.Errorf("logcli --from must be before --to")
+	}
+
+	switch args.SubCommand {
+	case "query":
+		if len(positional) != 1 || positional[0] == "" {
+			return logcliArgs{}, fmt.Errorf("logcli query needs a single quoted LogQL query")
+		}
+		args.Query = positional[0]
+	case "labels":
+		if len(positional) > 1 {
+			return logcliArgs{}, fmt.Errorf("logcli labels takes at most one label name")
+		}
+		if len(positional) == 1 {
+			args.Label = positional[0]
+		}
+	}
+
+	return args, nil
+}
@@ -0,0 +1,182 @@
+package executer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eliran89c/klama/internal/loki"
+)
+
+func newTestLokiExecuter(t *testing.T) (*LokiExecuter, *[]string) {
+	var queries []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/loki/api/v1/query_range", func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[
+			{"stream":{"pod":"web-0"},"values":[["1714557602000000000","error: timeout"],["1714557601000000000","started"]]}]}}`))
+	})
+	mux.HandleFunc("/loki/api/v1/labels", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":["app","namespace","pod"]}`))
+	})
+	mux.HandleFunc("/loki/api/v1/label/namespace/values", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":["shop"]}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client, err := loki.NewClient(server.URL, "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	te := NewTerminalExecuter(CombineExecuterTypes(LinuxExecuterType, LokiExecuterType))
+	lx := NewLokiExecuter(te, client, LokiLimits{DefaultRange: time.Hour, MaxRange: 24 * time.Hour, MaxLines: 100})
+	lx.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	return lx, &queries
+}
+
+func TestLokiExecuter_Run(t *testing.T) {
+	lx, queries := newTestLokiExecuter(t)
+
+	tests := []struct {
+		name    string
+		command string
+		want    string
+		query   []string // expected query parameters
+	}{
+		{
+			name:    "query",
+			command: `logcli query '{pod="web-0"} |= "error"'`,
+			want:    "{pod=\"web-0\"}\n2024-05-01T10:00:01Z started\n2024-05-01T10:00:02Z error: timeout",
+			query:   []string{"limit=100", "start=1714561200000000000", "end=1714564800000000000"},
+		},
+		{
+			name:    "since and limit",
+			command: `logcli query --since=30m --limit 10 '{pod="web-0"}'`,
+			want:    "{pod=\"web-0\"}\n2024-05-01T10:00:01Z started\n2024-05-01T10:00:02Z error: timeout",
+			query:   []string{"limit=10", "start=1714563000000000000"},
+		},
+		{
+			name:    "limited range and lines",
+			command: `logcli query --since=72h --limit=5000 '{pod="web-0"}'`,
+			want: "Note: the time range was limited to the 24h0m0s before 2024-05-01T12:00:00Z.\n" +
+				"Note: --limit was lowered to 100 lines.\n" +
+				"{pod=\"web-0\"}\n2024-05-01T10:00:01Z started\n2024-05-01T10:00:02Z error: timeout",
+			query: []string{"limit=100", "start=1714478400000000000"},
+		},
+		{
+			name:    "absolute range",
+			command: `logcli query --from=2024-05-01T09:00:00Z --to=2024-05-01T10:00:00Z '{pod="web-0"}'`,
+			want:    "{pod=\"web-0\"}\n2024-05-01T10:00:01Z started\n2024-05-01T10:00:02Z error: timeout",
+			query:   []string{"start=1714554000000000000", "end=1714557600000000000"},
+		},
+		{
+			name:    "piped",
+			command: `logcli query '{pod="web-0"}' | grep error`,
+			want:    "2024-05-01T10:00:02Z error: timeout",
+		},
+		{
+			name:    "labels",
+			command: "logcli labels",
+			want:    "app\nnamespace\npod",
+		},
+		{
+			name:    "label values",
+			command: "logcli labels namespace",
+			want:    "shop",
+		},
+		{
+			name:    "other commands",
+			command: "echo hello",
+			want:    "hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*queries = nil
+			resp := lx.Run(context.Background(), tt.command)
+			if resp.Error != nil {
+				t.Fatalf("Run() error = %v, output %q", resp.Error, resp.Stdout)
+			}
+			if resp.Stdout != tt.want {
+				t.Errorf("Run() = %q, want %q", resp.Stdout, tt.want)
+			}
+			for _, param := range tt.query {
+				if len(*queries) != 1 || !strings.Contains((*queries)[0], param) {
+					t.Errorf("query %v does not contain %q", *queries, param)
+				}
+			}
+		})
+	}
+
+	if _, ok := lx.ExecutedCommands()["echo hello"]; !ok {
+		t.Errorf("other commands should be cached")
+	}
+	for command := range lx.ExecutedCommands() {
+		if strings.HasPrefix(command, "logcli") {
+			t.Errorf("logcli command %q should not be cached", command)
+		}
+	}
+}
+
+func TestLokiExecuter_Validate(t *testing.T) {
+	lx, _ := newTestLokiExecuter(t)
+
+	tests := []struct {
+		command string
+		wantErr bool
+	}{
+		{`logcli query '{namespace="shop"} |= "error"'`, false},
+		{`logcli query --since 2h '{app="web"}' | grep -i timeout`, false},
+		{"logcli labels pod", false},
+		{"logcli query", true},
+		{`logcli query '{app="web"}' '{app="api"}'`, true},
+		{`logcli query --output=raw '{app="web"}'`, true},
+		{`logcli query --since=-1h '{app="web"}'`, true},
+		{`logcli query --limit=0 '{app="web"}'`, true},
+		{`logcli query --from=2024-05-02T00:00:00Z --to=2024-05-01T00:00:00Z '{app="web"}'`, true},
+		{"logcli labels app pod", true},
+		{"logcli series '{}'", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			err := lx.Validate(tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLokiExecuter_Preflight(t *testing.T) {
+	lx, _ := newTestLokiExecuter(t)
+
+	warning, err := lx.Preflight(context.Background(), `logcli query '{app="web"}'`)
+	if err != nil || warning != "" {
+		t.Errorf("Preflight() = %q, %v, want no warning", warning, err)
+	}
+}
+
+func TestLokiExecuter_RunError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "parse error", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client, err := loki.NewClient(server.URL, "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lx := NewLokiExecuter(NewTerminalExecuter(LokiExecuterType), client, LokiLimits{DefaultRange: time.Hour, MaxRange: time.Hour, MaxLines: 10})
+
+	resp := lx.Run(context.Background(), `logcli query '{app="web"'`)
+	if resp.Error == nil || !strings.Contains(resp.Stderr, "parse error") {
+		t.Errorf("Run() = %+v, want the Loki error", resp)
+	}
+}
@@ -0,0 +1,222 @@
+package executer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const metricsAPIPath = "/apis/metrics.k8s.io/v1beta1"
+
+type resourceUsage struct {
+	CPU    string `json:"cpu"`
+	Memory string `json:"memory"`
+}
+
+type objectMetadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// top implements `kubectl top pods` and `kubectl top nodes` with the metrics API.
+func (kx *K8sAPIExecuter) top(ctx context.Context, args kubectlArgs) (string, error) {
+	if len(args.Positional) == 0 {
+		return "", fmt.Errorf("you must specify pods or nodes to top")
+	}
+
+	name := ""
+	if len(args.Positional) > 1 {
+		name = args.Positional[1]
+	}
+
+	switch args.Positional[0] {
+	case "pod", "pods", "po":
+		return kx.topPods(ctx, args, name)
+	case "node", "nodes", "no":
+		return kx.topNodes(ctx, args, name)
+	default:
+		return "", fmt.Errorf("unknown resource %q for top, use pods or nodes", args.Positional[0])
+	}
+}
+
+func (kx *K8sAPIExecuter) topPods(ctx context.Context, args kubectlArgs, name string) (string, error) {
+	path := metricsAPIPath
+	if namespace := kx.namespaceFor(args); namespace != "" {
+		path += "/namespaces/" + namespace
+	}
+	path += "/pods"
+	if name != "" {
+		path += "/" + name
+	}
+
+	data, err := kx.client.Get(ctx, path, kx.listQuery(args), "")
+	if err != nil {
+		return "", metricsError(err)
+	}
+
+	type podMetrics struct {
+		Metadata   objectMetadata `json:"metadata"`
+		Containers []struct {
+			Usage resourceUsage `json:"usage"`
+		} `json:"containers"`
+	}
+
+	var pods []podMetrics
+	if name != "" {
+		var pod podMetrics
+		if err := json.Unmarshal(data, &pod); err != nil {
+			return "", fmt.Errorf("failed to parse pod metrics: %w", err)
+		}
+		pods = append(pods, pod)
+	} else {
+		var list struct {
+			Items []podMetrics `json:"items"`
+		}
+		if err := json.Unmarshal(data, &list); err != nil {
+			return "", fmt.Errorf("failed to parse pod metrics: %w", err)
+		}
+		pods = list.Items
+	}
+
+	if len(pods) == 0 {
+		return "No resources found", nil
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 3, ' ', 0)
+	if args.AllNamespaces {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "NAME\tCPU(cores)\tMEMORY(bytes)")
+	for _, pod := range pods {
+		var cpu, memory float64
+		for _, container := range pod.Containers {
+			cpu += parseQuantity(container.Usage.CPU)
+			memory += parseQuantity(container.Usage.Memory)
+		}
+		if args.AllNamespaces {
+			fmt.Fprintf(w, "%s\t", pod.Metadata.Namespace)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", pod.Metadata.Name, formatCPU(cpu), formatMemory(memory))
+	}
+	w.Flush()
+
+	return buf.String(), nil
+}
+
+func (kx *K8sAPIExecuter) topNodes(ctx context.Context, args kubectlArgs, name string) (string, error) {
+	query := kx.listQuery(args)
+	data, err := kx.client.Get(ctx, metricsAPIPath+"/nodes", query, "")
+	if err != nil {
+		return "", metricsError(err)
+	}
+
+	var metrics struct {
+		Items []struct {
+			Metadata objectMetadata `json:"metadata"`
+			Usage    resourceUsage  `json:"usage"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return "", fmt.Errorf("failed to parse node metrics: %w", err)
+	}
+
+	// allocatable capacity is needed for the percentages
+	allocatable := make(map[string]resourceUsage)
+	if data, err := kx.client.Get(ctx, "/api/v1/nodes", query, ""); err == nil {
+		var nodes struct {
+			Items []struct {
+				Metadata objectMetadata `json:"metadata"`
+				Status   struct {
+					Allocatable resourceUsage `json:"allocatable"`
+				} `json:"status"`
+			} `json:"items"`
+		}
+		if json.Unmarshal(data, &nodes) == nil {
+			for _, node := range nodes.Items {
+				allocatable[node.Metadata.Name] = node.Status.Allocatable
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCPU(cores)\tCPU%\tMEMORY(bytes)\tMEMORY%")
+	found := false
+	for _, node := range metrics.Items {
+		if name != "" && node.Metadata.Name != name {
+			continue
+		}
+		found = true
+
+		cpu, memory := parseQuantity(node.Usage.CPU), parseQuantity(node.Usage.Memory)
+		capacity := allocatable[node.Metadata.Name]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", node.Metadata.Name,
+			formatCPU(cpu), formatPercent(cpu, parseQuantity(capacity.CPU)),
+			formatMemory(memory), formatPercent(memory, parseQuantity(capacity.Memory)))
+	}
+	w.Flush()
+
+	if !found {
+		if name != "" {
+			return "", fmt.Errorf("nodemetrics %q not found", name)
+		}
+		return "No resources found", nil
+	}
+	return buf.String(), nil
+}
+
+func metricsError(err error) error {
+	return fmt.Errorf("%w (is the metrics server installed?)", err)
+}
+
+func formatCPU(cores float64) string {
+	return fmt.Sprintf("%dm", int64(cores*1000+0.5))
+}
+
+func formatMemory(bytes float64) string {
+	return fmt.Sprintf("%dMi", int64(bytes/(1<<20)+0.5))
+}
+
+func formatPercent(value, total float64) string {
+	if total <= 0 {
+		return "<unknown>"
+	}
+	return fmt.Sprintf("%d%%", int64(value/total*100+0.5))
+}
+
+// quantitySuffixes are the Kubernetes quantity suffixes and their multipliers. Binary
+// suffixes come first so Mi is not mistaken for a plain i.
+var quantitySuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50}, {"Ei", 1 << 60},
+	{"n", 1e-9}, {"u", 1e-6}, {"m", 1e-3},
+	{"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15}, {"E", 1e18},
+}
+
+// parseQuantity converts a Kubernetes quantity, such as 250m or 128Mi, to a number.
+// Invalid quantities are treated as zero.
+func parseQuantity(quantity string) float64 {
+	quantity = strings.TrimSpace(quantity)
+	for _, s := range quantitySuffixes {
+		if number, found := strings.CutSuffix(quantity, s.suffix); found {
+			value, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0
+			}
+			return value * s.multiplier
+		}
+	}
+
+	value, err := strconv.ParseFloat(quantity, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
@@ -0,0 +1,259 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/eliran89c/klama/internal/audit"
+)
+
+// kubectlNodeMutations operate on nodes, which they name without a kind.
+var kubectlNodeMutations = []string{"cordon", "uncordon", "drain"}
+
+// kubectlMutationValueFlags are the flags of kubectl mutations that take a separate value.
+var kubectlMutationValueFlags = []string{
+	"-n", "--namespace", "--context", "--kubeconfig", "--cluster", "--user",
+	"--replicas", "--current-replicas", "--resource-version",
+	"-p", "--patch", "--type", "--subresource",
+	"--timeout", "--grace-period", "--field-manager", "-o", "--output",
+}
+
+// kubectlMutationDeniedFlags select several resources or read them from files, which
+// cannot be confirmed by a resource name.
+var kubectlMutationDeniedFlags = []string{
+	"--all", "-A", "--all-namespaces", "-l", "--selector", "--field-selector",
+	"-f", "--filename", "-k", "--kustomize", "-R", "--recursive", "--patch-file",
+}
+
+// MutationTarget is the single resource a mutating command changes.
+type MutationTarget struct {
+	Kind string
+	Name string
+}
+
+// String returns the target as kind/name.
+func (t MutationTarget) String() string {
+	return t.Kind + "/" + t.Name
+}
+
+// kubectlMutationPrefixes returns the kubectl arguments each allowed mutation starts
+// with, such as rollout restart for "kubectl rollout restart".
+func kubectlMutationPrefixes(allowed []string) [][]string {
+	prefixes := make([][]string, 0, len(allowed))
+	for _, mutation := range allowed {
+		if fields := strings.Fields(mutation); len(fields) > 1 && fields[0] == "kubectl" {
+			prefixes = append(prefixes, fields[1:])
+		}
+	}
+	return prefixes
+}
+
+// kubectlMutationTarget returns the resource an allowed kubectl mutation changes.
+func kubectlMutationTarget(cmd Command, prefixes [][]string) (MutationTarget, error) {
+	args := unquoteAll(cmd.Parts[1:])
+	operation := mutationOperation(args, prefixes)
+	if operation == nil {
+		if len(args) == 0 {
+			return MutationTarget{}, ErrInvalidMainCommand
+		}
+		return MutationTarget{}, fmt.Errorf("%w: %s", ErrSubCommandNotAllowed, args[0])
+	}
+	name := "kubectl " + strings.Join(operation, " ")
+
+	var positional []string
+	rest := args[len(operation):]
+	for i := 0; i < len(rest); i++ {
+		flag, _, hasValue := strings.Cut(rest[i], "=")
+		switch {
+		case !strings.HasPrefix(flag, "-"):
+			positional = append(positional, rest[i])
+		case slices.Contains(kubectlMutationDeniedFlags, flag):
+			return MutationTarget{}, fmt.Errorf("%w: %s %s, name a single resource instead", ErrOperationNotAllowed, name, flag)
+		case slices.Contains(kubectlMutationValueFlags, flag) && !hasValue:
+			i++
+		}
+	}
+
+	target, extra, err := resourceTarget(operation[0], positional)
+	if err != nil {
+		return MutationTarget{}, fmt.Errorf("%w: %s %v", ErrOperationNotAllowed, name, err)
+	}
+	for _, arg := range extra {
+		// label and annotate take key=value and key- arguments after the resource
+		if !strings.Contains(arg, "=") && !strings.HasSuffix(arg, "-") {
+			return MutationTarget{}, fmt.Errorf("%w: %s must change a single resource, got %q", ErrOperationNotAllowed, name, arg)
+		}
+	}
+	return target, nil
+}
+
+// unquoteAll returns the command parts without their quotes.
+func unquoteAll(parts []string) []string {
+	args := make([]string, 0, len(parts))
+	for _, part := range parts {
+		args = append(args, unquote(part))
+	}
+	return args
+}
+
+// mutationOperation returns the longest allowed mutation the kubectl arguments start
+// with, or nil.
+func mutationOperation(args []string, prefixes [][]string) []string {
+	var operation []string
+	for _, prefix := range prefixes {
+		if len(args) >= len(prefix) && slices.Equal(args[:len(prefix)], prefix) && len(prefix) > len(operation) {
+			operation = prefix
+		}
+	}
+	return operation
+}
+
+// resourceTarget returns the resource named by the positional arguments of a kubectl
+// mutation, as kind/name or kind name, and the arguments after it.
+func resourceTarget(operation string, positional []string) (MutationTarget, []string, error) {
+	var target MutationTarget
+	var rest []string
+	switch {
+	case slices.Contains(kubectlNodeMutations, operation) && len(positional) > 0:
+		target, rest = MutationTarget{Kind: "node", Name: positional[0]}, positional[1:]
+	case len(positional) > 0 && strings.Contains(positional[0], "/"):
+		kind, name, _ := strings.Cut(positional[0], "/")
+		target, rest = MutationTarget{Kind: kind, Name: name}, positional[1:]
+	case len(positional) > 1:
+		target, rest = MutationTarget{Kind: positional[0], Name: positional[1]}, positional[2:]
+	default:
+		return MutationTarget{}, nil, fmt.Errorf("must name the resource it changes")
+	}
+
+	if target.Kind == "" || target.Name == "" || strings.ContainsAny(target.Kind+target.Name, ",/") {
+		return MutationTarget{}, nil, fmt.Errorf("must change a single resource")
+	}
+	return target, rest, nil
+}
+
+// MutationExecuter runs the commands of the wrapped executer, and the kubectl write
+// operations of fix mode that the user confirmed. Mutations must change a single named
+// resource, cannot be piped and are never cached. Every mutation is recorded in the
+// audit log before it runs, and a mutation is not run when it cannot be recorded.
+type MutationExecuter struct {
+	CachingExecuter
+
+	prefixes  [][]string
+	mutations *TerminalExecuter
+	audit     *audit.Log
+}
+
+// NewKubectlMutationExecuter wraps the executer to also run the allowed kubectl
+// mutations, such as "kubectl rollout restart". Mutations of DefaultDeniedResources and
+// of the denied resource patterns are rejected. rewrite, when set, is applied to every
+// mutation before it runs.
+func NewKubectlMutationExecuter(executer CachingExecuter, allowed, denied []string, rewrite func(Command) Command, auditLog *audit.Log) *MutationExecuter {
+	prefixes := kubectlMutationPrefixes(allowed)
+	denied = append(slices.Clone(DefaultDeniedResources), denied...)
+	mutationType := TerminalExecuterType{
+		AllowedCommands: []string{"kubectl"},
+		ValidateCommand: func(cmd Command) error {
+			target, err := kubectlMutationTarget(cmd, prefixes)
+			if err != nil {
+				return err
+			}
+			return deniedResource(kubectlMutationResources(unquoteAll(cmd.Parts[1:]), target), denied)
+		},
+		RewriteCommand: rewrite,
+		Uncached:       true,
+	}
+
+	return &MutationExecuter{
+		CachingExecuter: executer,
+		prefixes:        prefixes,
+		mutations:       NewTerminalExecuter(mutationType),
+		audit:           auditLog,
+	}
+}
+
+// Mutation returns the resource the command changes, and false when the command is not
+// an allowed mutation.
+func (mx *MutationExecuter) Mutation(command string) (MutationTarget, bool) {
+	if mx.CachingExecuter.Validate(command) == nil || mx.mutations.Validate(command) != nil {
+		return MutationTarget{}, false
+	}
+
+	target, err := kubectlMutationTarget(SplitPipeline(command)[0], mx.prefixes)
+	return target, err == nil
+}
+
+// Validate accepts the commands of the wrapped executer and the allowed mutations. For
+// commands that are neither, the more specific error is returned.
+func (mx *MutationExecuter) Validate(command string) error {
+	err := mx.CachingExecuter.Validate(command)
+	if err == nil {
+		return nil
+	}
+
+	cmds := SplitPipeline(command)
+	if len(cmds) > 1 && len(cmds[0].Parts) > 1 && cmds[0].Parts[0] == "kubectl" &&
+		mutationOperation(unquoteAll(cmds[0].Parts[1:]), mx.prefixes) != nil {
+		return fmt.Errorf("%w: mutations cannot be piped", ErrOperationNotAllowed)
+	}
+
+	mutationErr := mx.mutations.Validate(command)
+	switch {
+	case mutationErr == nil:
+		return nil
+	case errors.Is(mutationErr, ErrOperationNotAllowed), errors.Is(mutationErr, ErrResourceDenied):
+		// an allowed mutation with arguments fix mode does not accept, or of a denied resource
+		return mutationErr
+	default:
+		return err
+	}
+}
+
+// Run records and runs mutations, and runs other commands with the wrapped executer.
+func (mx *MutationExecuter) Run(ctx context.Context, command string) ExecuterResponse {
+	target, ok := mx.Mutation(command)
+	if !ok {
+		return mx.CachingExecuter.Run(ctx, command)
+	}
+
+	entry := audit.Entry{
+		Command: mx.mutations.EffectiveCommand(command),
+		Target:  target.String(),
+		Status:  audit.StatusApproved,
+	}
+	if err := mx.audit.Append(entry); err != nil {
+		err = fmt.Errorf("the command was not run because it could not be recorded in the audit log: %w", err)
+		return ExecuterResponse{Stderr: err.Error(), ExitCode: -1, Error: err}
+	}
+
+	response := mx.mutations.Run(ctx, command)
+
+	entry.Status = audit.StatusSucceeded
+	if response.Error != nil {
+		entry.Status = audit.StatusFailed
+		entry.Error = response.Error.Error()
+	}
+	if err := mx.audit.Append(entry); err != nil {
+		response.Stderr = strings.TrimSpace(response.Stderr + fmt.Sprintf("\nWarning: the outcome could not be recorded in the audit log: %v", err))
+	}
+	return response
+}
+
+// Preflight checks the commands of the wrapped executer, mutations are confirmed by
+// their resource name instead.
+func (mx *MutationExecuter) Preflight(ctx context.Context, command string) (string, error) {
+	if _, ok := mx.Mutation(command); ok {
+		return "", nil
+	}
+	return mx.CachingExecuter.Preflight(ctx, command)
+}
+
+// EffectiveCommand returns the command line that is executed for command.
+func (mx *MutationExecuter) EffectiveCommand(command string) string {
+	if _, ok := mx.Mutation(command); ok {
+		return mx.mutations.EffectiveCommand(command)
+	}
+	return mx.CachingExecuter.EffectiveCommand(command)
+}
@@ -0,0 +1,143 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/eliran89c/klama/internal/audit"
+)
+
+func newTestMutationExecuter(t *testing.T, auditPath string) *MutationExecuter {
+	return NewKubectlMutationExecuter(
+		NewTerminalExecuter(KubernetesExecuterType),
+		[]string{"kubectl rollout restart", "kubectl scale", "kubectl patch"},
+		[]string{"configmaps"},
+		nil,
+		&audit.Log{Path: auditPath, Agent: "k8s", Context: "prod"},
+	)
+}
+
+func TestMutationExecuter_Validate(t *testing.T) {
+	mx := newTestMutationExecuter(t, filepath.Join(t.TempDir(), "audit.jsonl"))
+
+	tests := []struct {
+		name    string
+		command string
+		target  string // expected mutation target, empty for read-only commands
+		wantErr error
+	}{
+		{name: "read-only command", command: "kubectl get pods -n shop"},
+		{name: "rollout restart", command: "kubectl rollout restart deployment/web -n shop", target: "deployment/web"},
+		{name: "scale kind and name", command: "kubectl scale deployment web --replicas 3 -n shop", target: "deployment/web"},
+		{name: "patch", command: `kubectl patch deployment web -p '{"spec":{"paused":false}}'`, target: "deployment/web"},
+		{name: "not allowed mutation", command: "kubectl delete pod web-0", wantErr: ErrSubCommandNotAllowed},
+		{name: "all resources", command: "kubectl rollout restart deployment --all", wantErr: ErrOperationNotAllowed},
+		{name: "selector", command: "kubectl scale deployment -l app=web --replicas 0", wantErr: ErrOperationNotAllowed},
+		{name: "file", command: "kubectl patch -f web.yaml -p '{}'", wantErr: ErrOperationNotAllowed},
+		{name: "no resource name", command: "kubectl rollout restart deployment", wantErr: ErrOperationNotAllowed},
+		{name: "several resources", command: "kubectl scale deployment web api --replicas 1", wantErr: ErrOperationNotAllowed},
+		{name: "comma separated", command: "kubectl rollout restart deployment/web,deployment/api", wantErr: ErrOperationNotAllowed},
+		{name: "piped mutation", command: "kubectl rollout restart deployment/web | grep web", wantErr: ErrOperationNotAllowed},
+		{name: "denied resource", command: `kubectl patch secret db -p '{"data":{}}'`, wantErr: ErrResourceDenied},
+		{name: "configured denied resource", command: "kubectl rollout restart cm/app", wantErr: ErrResourceDenied},
+		{name: "chaining", command: "kubectl scale deployment web --replicas 1; rm -rf /", wantErr: ErrOperationNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := mx.Validate(tt.command)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Validate() error = %v, want nil", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+
+			target, ok := mx.Mutation(tt.command)
+			if ok != (tt.target != "") || (ok && target.String() != tt.target) {
+				t.Errorf("Mutation() = %v, %v, want %q", target, ok, tt.target)
+			}
+		})
+	}
+}
+
+func TestMutationExecuter_NodeMutations(t *testing.T) {
+	mx := NewKubectlMutationExecuter(NewTerminalExecuter(KubernetesExecuterType), []string{"kubectl cordon"}, nil, nil,
+		&audit.Log{Path: filepath.Join(t.TempDir(), "audit.jsonl")})
+
+	target, ok := mx.Mutation("kubectl cordon node-1")
+	if !ok || target.String() != "node/node-1" {
+		t.Errorf("Mutation() = %v, %v, want node/node-1", target, ok)
+	}
+}
+
+func TestMutationExecuter_Run(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as kubectl")
+	}
+
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho \"$@\"\n[ \"$2\" != fail ]\n"
+	if err := os.WriteFile(filepath.Join(bin, "kubectl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	mx := newTestMutationExecuter(t, auditPath)
+	log := &audit.Log{Path: auditPath}
+
+	for i := 0; i < 2; i++ {
+		resp := mx.Run(context.Background(), "kubectl rollout restart deployment/web")
+		if resp.Error != nil || resp.Stdout != "rollout restart deployment/web" {
+			t.Fatalf("Run() = %+v", resp)
+		}
+	}
+	if resp := mx.Run(context.Background(), "kubectl scale fail web --replicas 1"); resp.Error == nil {
+		t.Fatalf("Run() error = nil, want the command error")
+	}
+
+	entries, err := log.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// mutations are not cached, each run is recorded before and after it runs
+	wantStatuses := []string{
+		audit.StatusApproved, audit.StatusSucceeded,
+		audit.StatusApproved, audit.StatusSucceeded,
+		audit.StatusApproved, audit.StatusFailed,
+	}
+	if len(entries) != len(wantStatuses) {
+		t.Fatalf("got %d audit entries, want %d", len(entries), len(wantStatuses))
+	}
+	for i, entry := range entries {
+		if entry.Status != wantStatuses[i] {
+			t.Errorf("entry %d status = %q, want %q", i, entry.Status, wantStatuses[i])
+		}
+		if entry.Agent != "k8s" || entry.Context != "prod" {
+			t.Errorf("entry %d = %+v, want agent k8s and context prod", i, entry)
+		}
+	}
+	if entries[0].Target != "deployment/web" || entries[5].Error == "" {
+		t.Errorf("entries = %+v", entries)
+	}
+}
+
+func TestMutationExecuter_RunWithoutAudit(t *testing.T) {
+	// the audit log cannot be created under a file
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	mx := newTestMutationExecuter(t, filepath.Join(blocker, "audit.jsonl"))
+
+	resp := mx.Run(context.Background(), "kubectl rollout restart deployment/web")
+	if resp.Error == nil {
+		t.Fatal("Run() error = nil, want the mutation refused")
+	}
+}
@@ -0,0 +1,80 @@
+package executer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// OutputProcessor transforms a command output before it is sent to the agent.
+type OutputProcessor interface {
+	Process(ctx context.Context, command, output string) (string, error)
+}
+
+// Summarizer condenses a command output, usually with a cheap language model.
+type Summarizer interface {
+	Summarize(ctx context.Context, command, output string) (string, error)
+}
+
+// Truncator keeps the head and the tail of large outputs and replaces the middle
+// with a marker stating how much was omitted.
+type Truncator struct {
+	MaxLines int // maximum number of lines, 0 disables line truncation
+	MaxBytes int // maximum number of bytes, 0 disables byte truncation
+}
+
+// Process truncates the output to the configured limits.
+func (t Truncator) Process(_ context.Context, _ string, output string) (string, error) {
+	if t.MaxLines > 0 {
+		lines := strings.Split(output, "\n")
+		if len(lines) > t.MaxLines {
+			head := (t.MaxLines + 1) / 2
+			tail := t.MaxLines - head
+			omitted := len(lines) - head - tail
+
+			kept := make([]string, 0, t.MaxLines+1)
+			kept = append(kept, lines[:head]...)
+			kept = append(kept, fmt.Sprintf("... %d lines omitted ...", omitted))
+			kept = append(kept, lines[len(lines)-tail:]...)
+			output = strings.Join(kept, "\n")
+		}
+	}
+
+	if t.MaxBytes > 0 && len(output) > t.MaxBytes {
+		head := t.MaxBytes / 2
+		tail := t.MaxBytes - head
+		omitted := len(output) - head - tail
+		output = fmt.Sprintf("%s\n... %d characters omitted ...\n%s", strings.ToValidUTF8(output[:head], ""), omitted, strings.ToValidUTF8(output[len(output)-tail:], ""))
+	}
+
+	return output, nil
+}
+
+// SummarizingProcessor summarizes outputs longer than Threshold lines and falls back
+// to the Fallback processor for shorter outputs or when summarization fails.
+type SummarizingProcessor struct {
+	Summarizer Summarizer
+	Threshold  int
+	Fallback   OutputProcessor
+}
+
+// Process summarizes large outputs.
+func (p SummarizingProcessor) Process(ctx context.Context, command, output string) (string, error) {
+	if strings.Count(output, "\n")+1 <= p.Threshold {
+		return p.fallback(ctx, command, output)
+	}
+
+	summary, err := p.Summarizer.Summarize(ctx, command, output)
+	if err != nil {
+		return p.fallback(ctx, command, output)
+	}
+
+	return fmt.Sprintf("[Summary of %d lines of output]\n%s", strings.Count(output, "\n")+1, summary), nil
+}
+
+func (p SummarizingProcessor) fallback(ctx context.Context, command, output string) (string, error) {
+	if p.Fallback == nil {
+		return output, nil
+	}
+	return p.Fallback.Process(ctx, command, output)
+}
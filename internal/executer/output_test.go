@@ -0,0 +1,90 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func numberedLines(n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestTruncator_Process(t *testing.T) {
+	tests := []struct {
+		name      string
+		truncator Truncator
+		output    string
+		want      string
+	}{
+		{"Short output", Truncator{MaxLines: 5}, numberedLines(3), numberedLines(3)},
+		{"Disabled", Truncator{}, numberedLines(10), numberedLines(10)},
+		{
+			"Line truncation",
+			Truncator{MaxLines: 4},
+			numberedLines(10),
+			"line 1\nline 2\n... 6 lines omitted ...\nline 9\nline 10",
+		},
+		{
+			"Byte truncation",
+			Truncator{MaxBytes: 10},
+			strings.Repeat("a", 20),
+			"aaaaa\n... 10 characters omitted ...\naaaaa",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.truncator.Process(context.Background(), "cmd", tt.output)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Process() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type stubSummarizer struct {
+	summary string
+	err     error
+}
+
+func (s stubSummarizer) Summarize(context.Context, string, string) (string, error) {
+	return s.summary, s.err
+}
+
+func TestSummarizingProcessor_Process(t *testing.T) {
+	fallback := Truncator{MaxLines: 2}
+
+	tests := []struct {
+		name       string
+		summarizer Summarizer
+		output     string
+		want       string
+	}{
+		{"Below threshold", stubSummarizer{summary: "summary"}, numberedLines(2), numberedLines(2)},
+		{"Summarized", stubSummarizer{summary: "3 pods are failing"}, numberedLines(5), "[Summary of 5 lines of output]\n3 pods are failing"},
+		{"Summarizer error", stubSummarizer{err: errors.New("boom")}, numberedLines(5), "line 1\n... 3 lines omitted ...\nline 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := SummarizingProcessor{Summarizer: tt.summarizer, Threshold: 3, Fallback: fallback}
+			got, err := p.Process(context.Background(), "cmd", tt.output)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Process() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package executer
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// structuredPipedCommands process JSON and YAML output after a pipe, and count it.
+var structuredPipedCommands = []string{"jq", "yq", "wc"}
+
+// pipedValidators check the arguments of piped commands that could otherwise read
+// files, expose the environment or write files, as they must only process the output
+// piped into them.
+var pipedValidators = map[string]func([]string) error{
+	"jq": validateJQArgs,
+	"yq": validateYQArgs,
+	"wc": validateWCArgs,
+}
+
+// validatePipedCommand checks the arguments of a command that receives piped output.
+func validatePipedCommand(cmd Command) error {
+	validate, ok := pipedValidators[cmd.Parts[0]]
+	if !ok {
+		return nil
+	}
+	return validate(unquoteAll(cmd.Parts[1:]))
+}
+
+// jqDeniedFilter matches jq builtins that read the environment or load modules. A
+// leading dot is a field access, such as .spec.containers[].env, and is allowed.
+var jqDeniedFilter = regexp.MustCompile(`(^|[^.\w$])(env|import|include|modulemeta|get_search_list)\b|\$ENV\b|\$__prog_args\b`)
+
+// validateJQArgs allows a single jq filter with output flags. Files, modules and the
+// environment, which holds the credentials of Klama, are denied.
+func validateJQArgs(args []string) error {
+	filter := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, _, _ := strings.Cut(arg, "=")
+		switch {
+		case name == "--from-file" || name == "--rawfile" || name == "--slurpfile":
+			return fmt.Errorf("%w: jq %s reads files", ErrOperationNotAllowed, name)
+		case slices.ContainsFunc(shortFlags(arg, "fL"), func(flag rune) bool { return flag == 'f' || flag == 'L' }):
+			return fmt.Errorf("%w: jq %s reads files", ErrOperationNotAllowed, arg)
+		case name == "--args" || name == "--jsonargs":
+			// the remaining arguments are values, not files
+			return validateJQFilter(args[:i])
+		case name == "--arg" || name == "--argjson":
+			i += 2
+		case name == "--indent":
+			i++
+		case strings.HasPrefix(arg, "-") && arg != "-":
+		case !filter:
+			filter = true
+		default:
+			return fmt.Errorf("%w: jq must only read the piped output, not %s", ErrOperationNotAllowed, arg)
+		}
+	}
+	return validateJQFilter(args)
+}
+
+// validateJQFilter rejects filters that use denied builtins.
+func validateJQFilter(args []string) error {
+	for _, arg := range args {
+		if match := jqDeniedFilter.FindString(arg); match != "" {
+			return fmt.Errorf("%w: jq %s", ErrOperationNotAllowed, strings.TrimLeft(match, " \t|(;,"))
+		}
+	}
+	return nil
+}
+
+// yqDeniedFlags write files or read expressions from them.
+var yqDeniedFlags = []string{"--inplace", "--from-file", "--split-exp", "--split-exp-file", "--output-file"}
+
+// yqValueFlags take their value as the next argument.
+var yqValueFlags = []string{"--indent", "--output-format", "--input-format"}
+
+// yqDeniedExpression matches yq operators that load files, read the environment or
+// evaluate expressions built from strings.
+var yqDeniedExpression = regexp.MustCompile(`(^|[^.\w$])(load|load_str|load_xml|load_props|load_base64|env|strenv|envsubst|eval)\b`)
+
+// validateYQArgs allows a single yq expression, optionally after the eval or
+// eval-all command, with output flags. In-place edits, files and the environment are
+// denied.
+func validateYQArgs(args []string) error {
+	expression := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, _, _ := strings.Cut(arg, "=")
+		switch {
+		case slices.Contains(yqDeniedFlags, name):
+			return fmt.Errorf("%w: yq %s", ErrOperationNotAllowed, name)
+		case slices.ContainsFunc(shortFlags(arg, "Iops"), func(flag rune) bool { return flag == 'i' || flag == 's' }):
+			return fmt.Errorf("%w: yq %s", ErrOperationNotAllowed, arg)
+		case name == "--expression":
+			value, ok := strings.CutPrefix(arg, "--expression=")
+			if !ok && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			expression = true
+			if err := validateYQExpression(value); err != nil {
+				return err
+			}
+		case slices.Contains(yqValueFlags, arg) || arg == "-I" || arg == "-o" || arg == "-p":
+			i++
+		case strings.HasPrefix(arg, "-") && arg != "-":
+		case i == 0 && slices.Contains([]string{"eval", "e", "eval-all", "ea"}, arg):
+		case !expression:
+			expression = true
+			if err := validateYQExpression(arg); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: yq must only read the piped output, not %s", ErrOperationNotAllowed, arg)
+		}
+	}
+	return nil
+}
+
+// validateYQExpression rejects expressions that use denied operators.
+func validateYQExpression(expression string) error {
+	if match := yqDeniedExpression.FindString(expression); match != "" {
+		return fmt.Errorf("%w: yq %s", ErrOperationNotAllowed, strings.TrimLeft(match, " \t|(;,"))
+	}
+	return nil
+}
+
+// validateWCArgs allows counting flags only, wc must count the piped output.
+func validateWCArgs(args []string) error {
+	for _, arg := range args {
+		name, _, _ := strings.Cut(arg, "=")
+		switch {
+		case name == "--files0-from":
+			return fmt.Errorf("%w: wc %s reads files", ErrOperationNotAllowed, name)
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			return fmt.Errorf("%w: wc must only count the piped output, not %s", ErrOperationNotAllowed, arg)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package executer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKubernetesExecuter_ValidatePipedCommands(t *testing.T) {
+	te := NewTerminalExecuter(KubernetesExecuterType)
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"jq filter", "kubectl get pods -o json | jq -r '.items[].metadata.name'", nil},
+		{"jq env field", "kubectl get pods -o json | jq '.items[].spec.containers[].env'", nil},
+		{"jq with arg", "kubectl get pods -o json | jq --arg ns default '.items[] | select(.metadata.namespace == $ns)'", nil},
+		{"jq then wc", "kubectl get pods -o json | jq '.items[]' -c | wc -l", nil},
+		{"jq file argument", "kubectl get pods -o json | jq . /etc/passwd", ErrOperationNotAllowed},
+		{"jq from file", "kubectl get pods -o json | jq -rf filter.jq", ErrOperationNotAllowed},
+		{"jq rawfile", "kubectl get pods -o json | jq --rawfile key /etc/shadow .", ErrOperationNotAllowed},
+		{"jq environment", "kubectl get pods -o json | jq -n env", ErrOperationNotAllowed},
+		{"jq ENV variable", "kubectl get pods -o json | jq '$ENV.OPENAI_API_KEY'", ErrOperationNotAllowed},
+		{"jq import", `kubectl get pods -o json | jq 'import "lib" as lib; .'`, ErrOperationNotAllowed},
+		{"yq expression", "kubectl get deploy web -o yaml | yq '.spec.replicas'", nil},
+		{"yq eval with output format", "kubectl get deploy web -o yaml | yq e -o json '.metadata'", nil},
+		{"yq in place", "kubectl get deploy web -o yaml | yq -i '.a = 1' deploy.yaml", ErrOperationNotAllowed},
+		{"yq file argument", "kubectl get deploy web -o yaml | yq . config.yaml", ErrOperationNotAllowed},
+		{"yq load", `kubectl get deploy web -o yaml | yq 'load("/etc/passwd")'`, ErrOperationNotAllowed},
+		{"yq strenv", `kubectl get deploy web -o yaml | yq '--expression=strenv(HOME)'`, ErrOperationNotAllowed},
+		{"wc lines", "kubectl get pods -A | wc -l", nil},
+		{"wc file argument", "kubectl get pods -A | wc -l /etc/passwd", ErrOperationNotAllowed},
+		{"wc files0-from", "kubectl get pods -A | wc --files0-from=list", ErrOperationNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := te.Validate(tt.command)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Validate() error = %v, want nil", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package executer
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// lockedWriter serializes the writes of several processes to the same writer.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+// runArgs runs the commands without a shell, each with the output of the previous one as
+// its input, like a shell pipeline. The stderr of every command is written to stderr. It
+// returns the exit code of the last command, -1 when it did not exit, and its error.
+func runArgs(ctx context.Context, args [][]string, env []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	stderr = &lockedWriter{w: stderr}
+
+	cmds := make([]*exec.Cmd, len(args))
+	for i, argv := range args {
+		cmds[i] = exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmds[i].Env = env
+		cmds[i].Stderr = stderr
+	}
+	cmds[0].Stdin = stdin
+	cmds[len(cmds)-1].Stdout = stdout
+
+	// the parent closes its ends of the pipes once the commands started, so a command
+	// that stops reading early, such as head, ends the commands before it
+	var pipes []*os.File
+	defer func() {
+		for _, pipe := range pipes {
+			pipe.Close()
+		}
+	}()
+	for i := 1; i < len(cmds); i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return -1, err
+		}
+		pipes = append(pipes, r, w)
+		cmds[i-1].Stdout, cmds[i].Stdin = w, r
+	}
+
+	var started []*exec.Cmd
+	var startErr error
+	for _, cmd := range cmds {
+		if startErr = cmd.Start(); startErr != nil {
+			// a command that is not installed stops the whole pipeline
+			for _, cmd := range started {
+				cmd.Process.Kill()
+			}
+			break
+		}
+		started = append(started, cmd)
+	}
+	for _, pipe := range pipes {
+		pipe.Close()
+	}
+	pipes = nil
+
+	var err error
+	for _, cmd := range started {
+		err = cmd.Wait()
+	}
+	if startErr != nil {
+		return -1, startErr
+	}
+
+	last := cmds[len(cmds)-1]
+	return last.ProcessState.ExitCode(), err
+}
@@ -0,0 +1,93 @@
+package executer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestTerminalExecuter_RunWithoutShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses echo, grep and yes")
+	}
+	te := NewTerminalExecuter(TerminalExecuterType{
+		AllowedCommands:      []string{"echo", "yes"},
+		AllowedPipedCommands: []string{"grep", "head", "wc"},
+	})
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{"Quotes are removed", `echo 'a;b' "c d" e\ f`, "a;b c d e f"},
+		{"Parameters are not expanded", `echo "$HOME" $PATH`, "$HOME $PATH"},
+		{"Pipes", "echo hello world | grep -o world | wc -c", "6"},
+		{"Early exit of a piped command", "yes | head -n 2", "y\ny"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := te.Run(context.Background(), tt.command)
+			if result.Error != nil {
+				t.Fatalf("Run() error = %v", result.Error)
+			}
+			if strings.TrimSpace(result.Stdout) != tt.expected {
+				t.Errorf("Run() stdout = %q, want %q", result.Stdout, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRunArgs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses cat and grep")
+	}
+
+	var stdout, stderr bytes.Buffer
+	code, err := runArgs(context.Background(), [][]string{{"cat"}, {"grep", "-c", "b"}}, nil, strings.NewReader("a\nb\nb\n"), &stdout, &stderr)
+	if err != nil || code != 0 || stdout.String() != "2\n" {
+		t.Errorf("runArgs() = %d, %v, stdout %q", code, err, stdout.String())
+	}
+
+	// the exit code is the one of the last command, like in a shell
+	stdout.Reset()
+	code, err = runArgs(context.Background(), [][]string{{"cat", "missing-file"}, {"grep", "x"}}, nil, nil, &stdout, &stderr)
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || code != 1 {
+		t.Errorf("runArgs() = %d, %v, want exit code 1", code, err)
+	}
+	if !strings.Contains(stderr.String(), "missing-file") {
+		t.Errorf("runArgs() stderr = %q, want the cat error", stderr.String())
+	}
+
+	if code, err := runArgs(context.Background(), [][]string{{"cat"}, {"klama-missing-command"}}, nil, strings.NewReader("a"), &stdout, &stderr); err == nil || code != -1 {
+		t.Errorf("runArgs() = %d, %v, want a start error", code, err)
+	}
+}
+
+func TestUnquoteWord(t *testing.T) {
+	tests := []struct {
+		word     string
+		expected string
+	}{
+		{"plain", "plain"},
+		{`'single "quoted"'`, `single "quoted"`},
+		{`"double 'quoted'"`, `double 'quoted'`},
+		{`"escaped \" and \\ but not \n"`, `escaped " and \ but not \n`},
+		{`back\ slash\'`, `back slash'`},
+		{`-o=jsonpath='{.items[*].metadata.name}'`, `-o=jsonpath={.items[*].metadata.name}`},
+		{`secre"ts"`, "secrets"},
+		{"trailing\\", "trailing\\"},
+	}
+
+	for _, tt := range tests {
+		if got := UnquoteWord(tt.word); got != tt.expected {
+			t.Errorf("UnquoteWord(%q) = %q, want %q", tt.word, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxLogPods is the most pods a podlogs command reads the logs of.
+	maxLogPods = 10
+
+	// maxLogLines is the most log lines a podlogs command returns, the most recent.
+	maxLogLines = 200
+
+	// maxLogLineLength bounds each log line a podlogs command returns.
+	maxLogLineLength = 300
+
+	// defaultLogSince is how far back a podlogs command reads when --since is not given.
+	defaultLogSince = time.Minute
+
+	// defaultLogFollow is how long a podlogs command follows the logs when --for is not
+	// given, maxLogFollow bounds it to finish within the timeout of a command.
+	defaultLogFollow = 10 * time.Second
+	maxLogFollow     = 20 * time.Second
+)
+
+// PodLogsExecuter adds the podlogs command to an executer: `podlogs -l <selector>
+// [-n <namespace>] [-c <container>] [--since <duration>] [--for <duration>]` follows the
+// logs of all the pods the selector matches for a bounded duration, and answers with a
+// digest of their lines, prefixed with the pod and merged by time. Problems that only
+// show on some replicas are missed by the logs of a single pod. Every other command runs
+// with the wrapped executer.
+type PodLogsExecuter struct {
+	CachingExecuter
+	now func() time.Time
+}
+
+// NewPodLogsExecuter wraps the executer to answer podlogs commands.
+func NewPodLogsExecuter(executer CachingExecuter) *PodLogsExecuter {
+	return &PodLogsExecuter{CachingExecuter: executer, now: time.Now}
+}
+
+// podLogsQuery is a parsed podlogs command.
+type podLogsQuery struct {
+	Namespace string
+	Selector  string
+	Container string
+	Since     time.Duration // how far back to read before following
+	For       time.Duration // how long to follow
+}
+
+// parsePodLogsCommand parses a podlogs command. ok is false for other commands.
+func parsePodLogsCommand(command string) (query podLogsQuery, ok bool, err error) {
+	cmds := splitPipeline(command, ShellPOSIX)
+	if len(cmds) == 0 || len(cmds[0].Parts) == 0 || cmds[0].Parts[0] != "podlogs" {
+		return podLogsQuery{}, false, nil
+	}
+	if len(cmds) > 1 {
+		return podLogsQuery{}, true, fmt.Errorf("%w: podlogs cannot be piped", ErrOperationNotAllowed)
+	}
+
+	usage := fmt.Errorf("%w: use podlogs -l <selector> [-n <namespace>] [-c <container>] [--since <duration>] [--for <duration>], such as podlogs -n shop -l app=web --for 10s", ErrInvalidMainCommand)
+	query = podLogsQuery{Since: defaultLogSince, For: defaultLogFollow}
+	args := unquoteAll(cmds[0].Parts[1:])
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if !hasValue {
+			if i+1 >= len(args) {
+				return podLogsQuery{}, true, usage
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "-n", "--namespace":
+			query.Namespace = value
+		case "-l", "--selector":
+			query.Selector = value
+		case "-c", "--container":
+			query.Container = value
+		case "--since", "--for":
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration < 0 {
+				return podLogsQuery{}, true, fmt.Errorf("%w: invalid %s duration %q", ErrInvalidMainCommand, name, value)
+			}
+			if name == "--since" {
+				query.Since = duration
+			} else {
+				query.For = duration
+			}
+		default:
+			return podLogsQuery{}, true, usage
+		}
+	}
+
+	switch {
+	case query.Selector == "":
+		return podLogsQuery{}, true, usage
+	case query.For > maxLogFollow:
+		return podLogsQuery{}, true, fmt.Errorf("%w: podlogs follows the logs for at most %s", ErrInvalidMainCommand, maxLogFollow)
+	}
+	return query, true, nil
+}
+
+// namespaceFlag returns the namespace flag of the kubectl commands of the query.
+func (q podLogsQuery) namespaceFlag() string {
+	if q.Namespace == "" {
+		return ""
+	}
+	return " -n " + shellQuote(q.Namespace)
+}
+
+// podsCommand returns the kubectl command that lists the pods the selector matches.
+func (q podLogsQuery) podsCommand() string {
+	return "kubectl get pods" + q.namespaceFlag() + " -l " + shellQuote(q.Selector) + " -o name"
+}
+
+// logsCommand returns the kubectl command that reads the logs of a pod since start.
+func (q podLogsQuery) logsCommand(pod string, start time.Time) string {
+	command := "kubectl logs" + q.namespaceFlag() + " " + shellQuote(pod)
+	if q.Container != "" {
+		command += " -c " + shellQuote(q.Container)
+	}
+	return command + fmt.Sprintf(" --timestamps --tail=%d --since-time=%s", maxLogLines, start.UTC().Format(time.RFC3339))
+}
+
+// kubectlCommand returns the kubectl command that reads the same logs at once.
+func (q podLogsQuery) kubectlCommand() string {
+	command := "kubectl logs" + q.namespaceFlag() + " -l " + shellQuote(q.Selector)
+	if q.Container != "" {
+		command += " -c " + shellQuote(q.Container)
+	}
+	return command + " --prefix --timestamps"
+}
+
+// Validate checks that the wrapped executer accepts listing the pods of a podlogs
+// command, and other commands with the wrapped executer.
+func (px *PodLogsExecuter) Validate(command string) error {
+	query, ok, err := parsePodLogsCommand(command)
+	if !ok {
+		return px.CachingExecuter.Validate(command)
+	}
+	if err != nil {
+		return err
+	}
+	return px.CachingExecuter.Validate(query.podsCommand())
+}
+
+// Run answers podlogs commands with a digest of the logs of the pods, and runs other
+// commands with the wrapped executer.
+func (px *PodLogsExecuter) Run(ctx context.Context, command string) (response ExecuterResponse) {
+	query, ok, err := parsePodLogsCommand(command)
+	if !ok {
+		return px.CachingExecuter.Run(ctx, command)
+	}
+
+	ctx, span := startSpan(ctx, command)
+	defer func() { endSpan(span, response) }()
+
+	if err != nil {
+		return ExecuterResponse{Stderr: err.Error(), ExitCode: -1, Error: err}
+	}
+
+	started := time.Now()
+	start := px.now().Add(-query.Since)
+
+	response = px.CachingExecuter.Run(ctx, query.podsCommand())
+	if response.Error != nil {
+		return response
+	}
+	pods := strings.Fields(response.Stdout)
+	if len(pods) == 0 {
+		return ExecuterResponse{Stdout: fmt.Sprintf("No pods match %s.", query.Selector), Duration: time.Since(started)}
+	}
+	sort.Strings(pods)
+
+	// the logs are read once the follow duration passed, from the start of the window
+	select {
+	case <-ctx.Done():
+		err := fmt.Errorf("command execution timed out: %w", ctx.Err())
+		return ExecuterResponse{Stderr: err.Error(), ExitCode: -1, Error: err, Duration: time.Since(started)}
+	case <-time.After(query.For):
+	}
+
+	shown := pods[:min(len(pods), maxLogPods)]
+	logs := make([]podLog, len(shown))
+	var wg sync.WaitGroup
+	for i, pod := range shown {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logs[i] = px.podLog(ctx, query, pod, start)
+		}()
+	}
+	wg.Wait()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Logs of %d pods matching %s since %s:", len(pods), query.Selector, start.UTC().Format(time.RFC3339))
+	if omitted := len(pods) - len(shown); omitted > 0 {
+		fmt.Fprintf(&b, "\nOnly the first %d pods are shown, %d were omitted.", len(shown), omitted)
+	}
+	b.WriteString("\n" + digestPodLogs(logs))
+	return ExecuterResponse{Stdout: b.String(), Duration: time.Since(started)}
+}
+
+// podLog is the log of one pod.
+type podLog struct {
+	Pod   string
+	Lines []string
+	Err   error
+}
+
+// podLog reads the log of a pod with the wrapped executer.
+func (px *PodLogsExecuter) podLog(ctx context.Context, query podLogsQuery, pod string, start time.Time) podLog {
+	command := query.logsCommand(pod, start)
+	if err := px.CachingExecuter.Validate(command); err != nil {
+		return podLog{Pod: pod, Err: err}
+	}
+	result := px.CachingExecuter.Run(ctx, command)
+	if result.Error != nil {
+		if result.Stderr != "" {
+			return podLog{Pod: pod, Err: errors.New(result.Stderr)}
+		}
+		return podLog{Pod: pod, Err: result.Error}
+	}
+	if result.Stdout == "" {
+		return podLog{Pod: pod}
+	}
+	return podLog{Pod: pod, Lines: strings.Split(result.Stdout, "\n")}
+}
+
+// digestPodLogs merges the timestamped lines of the pods by time, prefixes them with the
+// pod, and keeps the most recent ones.
+func digestPodLogs(logs []podLog) string {
+	type logLine struct {
+		at   time.Time
+		text string
+	}
+
+	var lines []logLine
+	var failures []string
+	for _, log := range logs {
+		name := strings.TrimPrefix(log.Pod, "pod/")
+		if log.Err != nil {
+			failures = append(failures, fmt.Sprintf("[%s] failed to read the logs: %v", name, log.Err))
+			continue
+		}
+		var at time.Time
+		for _, line := range log.Lines {
+			// lines without a timestamp, such as continuation lines, keep the time of the
+			// line before them
+			timestamp, text, _ := strings.Cut(line, " ")
+			if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+				at = t
+			} else {
+				text = line
+			}
+			if runes := []rune(text); len(runes) > maxLogLineLength {
+				text = string(runes[:maxLogLineLength]) + "..."
+			}
+			when := "unknown time"
+			if !at.IsZero() {
+				when = at.UTC().Format(time.RFC3339)
+			}
+			lines = append(lines, logLine{at: at, text: when + " [" + name + "] " + text})
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].at.Before(lines[j].at) })
+
+	var b strings.Builder
+	for _, failure := range failures {
+		b.WriteString(failure + "\n")
+	}
+	if len(lines) == 0 {
+		b.WriteString("No log lines in this window.")
+		return b.String()
+	}
+	if omitted := len(lines) - maxLogLines; omitted > 0 {
+		fmt.Fprintf(&b, "%d earlier lines omitted.\n", omitted)
+		lines = lines[omitted:]
+	}
+	for _, line := range lines {
+		b.WriteString(line.text + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Preflight checks listing the pods of a podlogs command, and other commands with the
+// wrapped executer.
+func (px *PodLogsExecuter) Preflight(ctx context.Context, command string) (string, error) {
+	query, ok, err := parsePodLogsCommand(command)
+	if !ok {
+		return px.CachingExecuter.Preflight(ctx, command)
+	}
+	if err != nil {
+		return "", nil
+	}
+	return px.CachingExecuter.Preflight(ctx, query.podsCommand())
+}
+
+// EffectiveCommand returns the command line that is executed for command, for a podlogs
+// command the kubectl logs command that reads the same logs.
+func (px *PodLogsExecuter) EffectiveCommand(command string) string {
+	query, ok, err := parsePodLogsCommand(command)
+	if !ok || err != nil {
+		return px.CachingExecuter.EffectiveCommand(command)
+	}
+	return px.CachingExecuter.EffectiveCommand(query.kubectlCommand())
+}
@@ -0,0 +1,106 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePodLogsCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    podLogsQuery
+		ok      bool
+		wantErr error
+	}{
+		{"Defaults", "podlogs -l app=web", podLogsQuery{Selector: "app=web", Since: defaultLogSince, For: defaultLogFollow}, true, nil},
+		{"All flags", "podlogs -n shop --selector='app=web,tier in (api)' -c app --since 5m --for=0s",
+			podLogsQuery{Namespace: "shop", Selector: "app=web,tier in (api)", Container: "app", Since: 5 * time.Minute}, true, nil},
+		{"Other command", "kubectl logs web-0", podLogsQuery{}, false, nil},
+		{"No selector", "podlogs -n shop", podLogsQuery{}, true, ErrInvalidMainCommand},
+		{"Positional", "podlogs web-0", podLogsQuery{}, true, ErrInvalidMainCommand},
+		{"Invalid duration", "podlogs -l app=web --since yesterday", podLogsQuery{}, true, ErrInvalidMainCommand},
+		{"Follows too long", "podlogs -l app=web --for 5m", podLogsQuery{}, true, ErrInvalidMainCommand},
+		{"Piped", "podlogs -l app=web | grep error", podLogsQuery{}, true, ErrOperationNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := parsePodLogsCommand(tt.command)
+			if ok != tt.ok || !errors.Is(err, tt.wantErr) || tt.wantErr == nil && err != nil {
+				t.Fatalf("parsePodLogsCommand(%q) = %v, %v, want %v, %v", tt.command, ok, err, tt.ok, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parsePodLogsCommand(%q) = %+v, want %+v", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodLogsExecuter(t *testing.T) {
+	te := NewTerminalExecuter(KubernetesExecuterType)
+	px := NewPodLogsExecuter(te)
+	px.now = func() time.Time { return time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC) }
+
+	command := "podlogs -n shop -l app=web --since 5m --for 0s"
+	if got, want := px.EffectiveCommand(command), "kubectl logs -n shop -l 'app=web' --prefix --timestamps"; got != want {
+		t.Errorf("EffectiveCommand() = %q, want %q", got, want)
+	}
+	if err := px.Validate(command); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	te.RestoreExecutedCommands(map[string]string{
+		"kubectl get pods -n shop -l 'app=web' -o name": "pod/web-1\npod/web-0",
+		"kubectl logs -n shop pod/web-0 --timestamps --tail=200 --since-time=2024-05-01T10:00:00Z": "2024-05-01T10:01:00.5Z started\n" +
+			"2024-05-01T10:03:00Z panic: nil map\ngoroutine 1 [running]",
+		"kubectl logs -n shop pod/web-1 --timestamps --tail=200 --since-time=2024-05-01T10:00:00Z": "2024-05-01T10:02:00Z started",
+	})
+
+	response := px.Run(context.Background(), command)
+	if response.Error != nil {
+		t.Fatalf("Run() error = %v", response.Error)
+	}
+	want := "Logs of 2 pods matching app=web since 2024-05-01T10:00:00Z:\n" +
+		"2024-05-01T10:01:00Z [web-0] started\n" +
+		"2024-05-01T10:02:00Z [web-1] started\n" +
+		"2024-05-01T10:03:00Z [web-0] panic: nil map\n" +
+		"2024-05-01T10:03:00Z [web-0] goroutine 1 [running]"
+	if response.Stdout != want {
+		t.Errorf("Run() stdout =\n%s\nwant\n%s", response.Stdout, want)
+	}
+
+	te.RestoreExecutedCommands(map[string]string{"kubectl get pods -n shop -l 'app=api' -o name": ""})
+	response = px.Run(context.Background(), "podlogs -n shop -l app=api --for 0s")
+	if response.Error != nil || response.Stdout != "No pods match app=api." {
+		t.Errorf("Run() without pods = %q, %v", response.Stdout, response.Error)
+	}
+}
+
+func TestDigestPodLogs(t *testing.T) {
+	var lines []string
+	for i := range maxLogLines + 2 {
+		lines = append(lines, fmt.Sprintf("2024-05-01T10:%02d:%02dZ line %d", i/60, i%60, i))
+	}
+	long := strings.Repeat("x", maxLogLineLength+10)
+	got := digestPodLogs([]podLog{
+		{Pod: "pod/web-0", Lines: lines},
+		{Pod: "pod/web-1", Lines: []string{"2024-05-01T11:00:00Z " + long}},
+		{Pod: "pod/web-2", Err: errors.New("container is waiting to start")},
+	})
+
+	digest := strings.Split(got, "\n")
+	if digest[0] != "[web-2] failed to read the logs: container is waiting to start" || digest[1] != "3 earlier lines omitted." {
+		t.Errorf("digestPodLogs() did not report the failure and the omitted lines:\n%s", strings.Join(digest[:2], "\n"))
+	}
+	if len(digest) != maxLogLines+2 || !strings.HasSuffix(digest[2], "[web-0] line 3") {
+		t.Errorf("digestPodLogs() did not keep the most recent lines, got %d lines", len(digest))
+	}
+	if last := digest[len(digest)-1]; last != "2024-05-01T11:00:00Z [web-1] "+long[:maxLogLineLength]+"..." {
+		t.Errorf("digestPodLogs() did not truncate the long line: %q", last)
+	}
+}
@@ -0,0 +1,47 @@
+package executer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultWorkers is how many jobs RunParallel runs at a time by default.
+const DefaultWorkers = 4
+
+// RunParallel runs independent jobs, such as read-only commands, with at most workers
+// of them at a time, 0 uses DefaultWorkers. Each job gets its own timeout, 0 disables
+// it. The results are returned in the order of the jobs, whichever finishes first.
+func RunParallel[T any](ctx context.Context, workers int, timeout time.Duration, jobs []func(context.Context) T) []T {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	workers = min(workers, len(jobs))
+
+	results := make([]T, len(jobs))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				jobCtx, cancel := ctx, context.CancelFunc(func() {})
+				if timeout > 0 {
+					jobCtx, cancel = context.WithTimeout(ctx, timeout)
+				}
+				results[i] = jobs[i](jobCtx)
+				cancel()
+			}
+		}()
+	}
+
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
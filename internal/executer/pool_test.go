@@ -0,0 +1,49 @@
+package executer
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunParallel(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	jobs := make([]func(context.Context) int, 10)
+	for i := range jobs {
+		jobs[i] = func(context.Context) int {
+			n := running.Add(1)
+			for {
+				current := maxRunning.Load()
+				if n <= current || maxRunning.CompareAndSwap(current, n) {
+					break
+				}
+			}
+			// later jobs finish first
+			time.Sleep(time.Duration(len(jobs)-i) * time.Millisecond)
+			running.Add(-1)
+			return i
+		}
+	}
+
+	results := RunParallel(context.Background(), 3, 0, jobs)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, results)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+	assert.Greater(t, maxRunning.Load(), int32(1))
+}
+
+func TestRunParallel_Timeout(t *testing.T) {
+	jobs := []func(context.Context) error{
+		func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		func(context.Context) error { return nil },
+	}
+
+	results := RunParallel(context.Background(), 0, 10*time.Millisecond, jobs)
+	assert.ErrorIs(t, results[0], context.DeadlineExceeded)
+	assert.NoError(t, results[1])
+}
@@ -0,0 +1,168 @@
+package executer
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// psqlFlags are the psql flags the agent may use, besides -c. The connection flags are
+// added by the executer.
+var psqlFlags = []string{"--expanded", "--no-align", "--tuples-only", "--csv", "--no-psqlrc"}
+
+// psqlShortFlags are the short forms of psqlFlags, which may be clustered as in -At.
+const psqlShortFlags = "xAtX"
+
+// psqlMetaCommands are the psql meta-commands that describe objects. They also accept
+// the S (system objects) and + (details) modifiers.
+var psqlMetaCommands = []string{
+	"d", "dt", "di", "dv", "dm", "ds", "dn", "df", "dx", "dp", "du", "dg", "dT", "dconfig", "l", "conninfo",
+}
+
+// sqlStatements are the first keywords of the statements the agent may run. The
+// session is read-only, this also keeps it from changing session settings.
+var sqlStatements = []string{"select", "with", "show", "explain", "table", "values"}
+
+// sqlDeniedPattern matches functions that act on the server even in a read-only
+// transaction, and settings that would turn the transaction read-write.
+var sqlDeniedPattern = regexp.MustCompile(`(?i)\b(pg_terminate_backend|pg_cancel_backend|pg_reload_conf|pg_rotate_logfile|` +
+	`pg_switch_wal|pg_promote|pg_read_file|pg_read_binary_file|pg_ls_dir|pg_stat_file|pg_stat_reset\w*|pg_stat_statements_reset|` +
+	`lo_import|lo_export|dblink\w*|set_config|transaction_read_only|default_transaction_read_only|read\s+write)\b`)
+
+// validatePsqlCommand checks that the command is `psql -c <query>` with read-only SQL
+// statements or describing meta-commands.
+func validatePsqlCommand(cmd Command) error {
+	queries := 0
+	args := cmd.Parts[1:]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		var query string
+		switch {
+		case arg == "--command":
+			if i+1 >= len(args) {
+				return fmt.Errorf("psql %s needs a query", arg)
+			}
+			i++
+			query = args[i]
+		case strings.HasPrefix(arg, "--command="):
+			query = strings.TrimPrefix(arg, "--command=")
+		case slices.Contains(psqlFlags, arg):
+			continue
+		case len(arg) > 1 && arg[0] == '-' && arg[1] != '-':
+			flags, hasQuery := arg[1:], false
+			if c := strings.IndexByte(flags, 'c'); c >= 0 {
+				flags, query, hasQuery = flags[:c], flags[c+1:], true
+			}
+			for _, flag := range flags {
+				if !strings.ContainsRune(psqlShortFlags, flag) {
+					return fmt.Errorf("%w: psql -%c, the connection is configured by klama and queries are passed with -c", ErrOperationNotAllowed, flag)
+				}
+			}
+			if !hasQuery {
+				continue
+			}
+			if query == "" {
+				if i+1 >= len(args) {
+					return fmt.Errorf("psql -c needs a query")
+				}
+				i++
+				query = args[i]
+			}
+		default:
+			return fmt.Errorf("%w: psql %s, the connection is configured by klama and queries are passed with -c", ErrOperationNotAllowed, arg)
+		}
+
+		if strings.HasPrefix(query, `"`) && strings.Contains(query, "$") {
+			return fmt.Errorf("%w: quote queries with $ in single quotes", ErrOperationNotAllowed)
+		}
+		if err := validatePsqlQuery(unquote(query)); err != nil {
+			return err
+		}
+		queries++
+	}
+
+	if queries == 0 {
+		return fmt.Errorf("%w: psql needs a query passed with -c", ErrInvalidMainCommand)
+	}
+	return nil
+}
+
+// validatePsqlQuery checks a -c query, which is either SQL or meta-commands.
+func validatePsqlQuery(query string) error {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return fmt.Errorf("psql query is empty")
+	}
+
+	if strings.HasPrefix(query, `\`) {
+		// psql runs the output of backquoted commands in meta-command arguments, and a
+		// backslash anywhere starts another meta-command, even inside a pattern
+		if strings.Contains(query, "`") {
+			return fmt.Errorf("%w: backquotes in psql meta-commands", ErrOperationNotAllowed)
+		}
+		if strings.Contains(query[1:], `\`) {
+			return fmt.Errorf("%w: psql queries may run a single meta-command", ErrOperationNotAllowed)
+		}
+		field := strings.Fields(query)[0]
+		name := strings.TrimRight(strings.TrimPrefix(field, `\`), "S+")
+		if name == "" || !slices.Contains(psqlMetaCommands, name) {
+			return fmt.Errorf("%w: psql meta-command %s", ErrOperationNotAllowed, field)
+		}
+		return nil
+	}
+
+	for _, statement := range strings.Split(query, ";") {
+		fields := strings.Fields(statement)
+		if len(fields) == 0 {
+			continue
+		}
+		if !slices.Contains(sqlStatements, strings.ToLower(fields[0])) {
+			return fmt.Errorf("%w: %s statements, only %s are allowed", ErrOperationNotAllowed, strings.ToUpper(fields[0]), strings.ToUpper(strings.Join(sqlStatements, ", ")))
+		}
+	}
+	if match := sqlDeniedPattern.FindString(query); match != "" {
+		return fmt.Errorf("%w: %s", ErrOperationNotAllowed, match)
+	}
+	return nil
+}
+
+// PsqlConnection connects psql commands to the configured database in read-only
+// transactions.
+type PsqlConnection struct {
+	DSN              string // connection string or URI, without the password
+	Password         string
+	StatementTimeout time.Duration
+}
+
+// Apply adds the connection flags to a psql command and ignores the user's psqlrc.
+func (c PsqlConnection) Apply(cmd Command) Command {
+	if len(cmd.Parts) == 0 || cmd.Parts[0] != "psql" {
+		return cmd
+	}
+
+	parts := []string{cmd.Parts[0], "--no-psqlrc", "--dbname=" + shellQuote(c.DSN)}
+	for _, part := range cmd.Parts[1:] {
+		if part != "--no-psqlrc" {
+			parts = append(parts, part)
+		}
+	}
+	return Command{Parts: parts}
+}
+
+// Env returns the environment that enforces read-only transactions and the statement
+// timeout, and passes the password.
+func (c PsqlConnection) Env() []string {
+	options := "-c default_transaction_read_only=on"
+	if c.StatementTimeout > 0 {
+		options += fmt.Sprintf(" -c statement_timeout=%d", c.StatementTimeout.Milliseconds())
+	}
+
+	env := []string{"PGOPTIONS=" + options, "PGAPPNAME=klama"}
+	if c.Password != "" {
+		env = append(env, "PGPASSWORD="+c.Password)
+	}
+	return env
+}
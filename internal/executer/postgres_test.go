@@ -0,0 +1,49 @@
+package executer
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPsqlConnection_Apply(t *testing.T) {
+	conn := PsqlConnection{DSN: "host=db user=app dbname=shop"}
+
+	tests := []struct {
+		name    string
+		command string
+		want    string
+	}{
+		{"Query", "psql -c 'SELECT 1'", "psql --no-psqlrc --dbname='host=db user=app dbname=shop' -c 'SELECT 1'"},
+		{"Drops psqlrc flags", "psql --no-psqlrc -x -c 'SELECT 1'", "psql --no-psqlrc --dbname='host=db user=app dbname=shop' -x -c 'SELECT 1'"},
+		{"Other command", "grep foo", "grep foo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := conn.Apply(Command{Parts: splitCommand(tt.command, ShellPOSIX)})
+			if joined := strings.Join(got.Parts, " "); joined != tt.want {
+				t.Errorf("Apply() = %q, want %q", joined, tt.want)
+			}
+		})
+	}
+}
+
+func TestPsqlConnection_Env(t *testing.T) {
+	env := PsqlConnection{DSN: "postgres://app@db/shop", Password: "s3cret", StatementTimeout: 30 * time.Second}.Env()
+
+	want := []string{
+		"PGOPTIONS=-c default_transaction_read_only=on -c statement_timeout=30000",
+		"PGAPPNAME=klama",
+		"PGPASSWORD=s3cret",
+	}
+	if !slices.Equal(env, want) {
+		t.Errorf("Env() = %q, want %q", env, want)
+	}
+
+	env = PsqlConnection{DSN: "postgres://app@db/shop"}.Env()
+	if !slices.Equal(env, []string{"PGOPTIONS=-c default_transaction_read_only=on", "PGAPPNAME=klama"}) {
+		t.Errorf("Env() = %q, want no timeout and password", env)
+	}
+}
@@ -0,0 +1,159 @@
+package executer
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Preflight runs the executer type's preflight check for the main command, if any.
+// It returns a warning when the command is expected to fail, for example because
+// RBAC will deny it. An error means the check itself could not be performed.
+func (tx *TerminalExecuter) Preflight(ctx context.Context, command string) (string, error) {
+	if tx.executerType.Preflight == nil {
+		return "", nil
+	}
+
+	// cached commands already succeeded
+	if _, exists := tx.cached(command); exists {
+		return "", nil
+	}
+
+	cmds := splitCommandsByPipe(tx.EffectiveCommand(command))
+	if len(cmds) == 0 || len(cmds[0].Parts) == 0 {
+		return "", nil
+	}
+
+	return tx.executerType.Preflight(ctx, cmds[0])
+}
+
+// kubectlCanI runs `kubectl auth can-i` with the given arguments and returns its output.
+var kubectlCanI = func(ctx context.Context, args []string) (string, error) {
+	output, err := exec.CommandContext(ctx, "kubectl", append([]string{"auth", "can-i"}, args...)...).CombinedOutput()
+	result := strings.TrimSpace(string(output))
+
+	// can-i exits with status 1 when the answer is "no"
+	if err != nil && result != "no" {
+		return "", fmt.Errorf("kubectl auth can-i failed: %w: %s", err, result)
+	}
+	return result, nil
+}
+
+// kubectlPassThroughFlags are the kubectl flags that select the cluster and user and must
+// be forwarded to `kubectl auth can-i`.
+var kubectlPassThroughFlags = []string{"--context", "--kubeconfig", "--cluster", "--user"}
+
+// kubectlAccessCheck is a single `kubectl auth can-i` query.
+type kubectlAccessCheck struct {
+	Verb        string
+	Resource    string
+	Name        string
+	Subresource string
+}
+
+// preflightKubectlCommand asks the API server whether the current user may perform the
+// reads a kubectl command needs.
+func preflightKubectlCommand(ctx context.Context, cmd Command) (string, error) {
+	args, err := parseKubectlArgs(cmd)
+	if err != nil || args.FromFiles {
+		return "", nil
+	}
+
+	flags := canIFlags(args)
+	var denied []string
+	for _, check := range args.accessChecks() {
+		query := []string{check.Verb, check.Resource}
+		if check.Name != "" {
+			query = append(query, check.Name)
+		}
+		if check.Subresource != "" {
+			query = append(query, "--subresource="+check.Subresource)
+		}
+
+		answer, err := kubectlCanI(ctx, append(query, flags...))
+		if err != nil {
+			return "", err
+		}
+		if answer == "no" {
+			denied = append(denied, check.String())
+		}
+	}
+
+	return deniedWarning(denied, args, args.Namespace), nil
+}
+
+// deniedWarning describes the denied access checks, or returns an empty string when
+// nothing was denied.
+func deniedWarning(denied []string, args kubectlArgs, namespace string) string {
+	if len(denied) == 0 {
+		return ""
+	}
+
+	scope := "in the current namespace"
+	switch {
+	case args.AllNamespaces:
+		scope = "across all namespaces"
+	case namespace != "":
+		scope = "in namespace " + namespace
+	}
+
+	return fmt.Sprintf("RBAC will deny %s %s", strings.Join(denied, ", "), scope)
+}
+
+func (c kubectlAccessCheck) String() string {
+	resource := c.Resource
+	if c.Subresource != "" {
+		resource += "/" + c.Subresource
+	}
+	if c.Name != "" {
+		resource += " " + c.Name
+	}
+	return fmt.Sprintf("`%s %s`", c.Verb, resource)
+}
+
+// canIFlags returns the flags of a kubectl command to forward to `kubectl auth can-i`.
+func canIFlags(args kubectlArgs) []string {
+	var flags []string
+	switch {
+	case args.AllNamespaces:
+		flags = append(flags, "--all-namespaces")
+	case args.Namespace != "":
+		flags = append(flags, "--namespace", args.Namespace)
+	}
+	for _, name := range kubectlPassThroughFlags {
+		if value, ok := args.Flags[name]; ok {
+			flags = append(flags, name, value)
+		}
+	}
+	return flags
+}
+
+// accessChecks returns the reads the command performs.
+func (a kubectlArgs) accessChecks() []kubectlAccessCheck {
+	if len(a.Positional) == 0 {
+		return nil
+	}
+
+	var checks []kubectlAccessCheck
+	switch a.SubCommand {
+	case "get", "describe":
+		for _, target := range a.targets() {
+			verb := "get"
+			if target.Name == "" {
+				verb = "list"
+			}
+			checks = append(checks, kubectlAccessCheck{Verb: verb, Resource: target.Resource, Name: target.Name})
+		}
+	case "logs":
+		resource, name, hasResource := strings.Cut(a.Positional[0], "/")
+		if !hasResource {
+			resource, name = "pods", a.Positional[0]
+		}
+		if resource == "pod" || resource == "po" || resource == "pods" {
+			checks = append(checks, kubectlAccessCheck{Verb: "get", Resource: "pods", Name: name, Subresource: "log"})
+		}
+	}
+
+	return checks
+}
@@ -0,0 +1,149 @@
+package executer
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKubectlArgs_accessChecks(t *testing.T) {
+	tests := []struct {
+		name           string
+		command        string
+		expectedChecks []kubectlAccessCheck
+		expectedFlags  []string
+	}{
+		{
+			name:           "List resources",
+			command:        "kubectl get pods -n kube-system",
+			expectedChecks: []kubectlAccessCheck{{Verb: "list", Resource: "pods"}},
+			expectedFlags:  []string{"--namespace", "kube-system"},
+		},
+		{
+			name:    "Named resources",
+			command: "kubectl get pod web-0 web-1 -o yaml",
+			expectedChecks: []kubectlAccessCheck{
+				{Verb: "get", Resource: "pod", Name: "web-0"},
+				{Verb: "get", Resource: "pod", Name: "web-1"},
+			},
+		},
+		{
+			name:    "Multiple resource types",
+			command: "kubectl get pods,services -A",
+			expectedChecks: []kubectlAccessCheck{
+				{Verb: "list", Resource: "pods"},
+				{Verb: "list", Resource: "services"},
+			},
+			expectedFlags: []string{"--all-namespaces"},
+		},
+		{
+			name:           "Resource with slash",
+			command:        "kubectl describe deploy/web --context=prod",
+			expectedChecks: []kubectlAccessCheck{{Verb: "get", Resource: "deploy", Name: "web"}},
+			expectedFlags:  []string{"--context", "prod"},
+		},
+		{
+			name:           "Pod logs",
+			command:        "kubectl logs web-0 -c app --namespace='apps' --tail 100",
+			expectedChecks: []kubectlAccessCheck{{Verb: "get", Resource: "pods", Name: "web-0", Subresource: "log"}},
+			expectedFlags:  []string{"--namespace", "apps"},
+		},
+		{
+			name:    "Logs of a deployment",
+			command: "kubectl logs deploy/web",
+		},
+		{
+			name:    "Resources from files",
+			command: "kubectl get -f manifest.yaml",
+		},
+		{
+			name:    "Top is not checked",
+			command: "kubectl top pods",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := parseKubectlArgs(splitCommandsByPipe(tt.command)[0])
+			if err != nil {
+				t.Fatalf("parseKubectlArgs() error = %v", err)
+			}
+			var checks []kubectlAccessCheck
+			if !args.FromFiles {
+				checks = args.accessChecks()
+			}
+			flags := canIFlags(args)
+			if !reflect.DeepEqual(checks, tt.expectedChecks) {
+				t.Errorf("accessChecks() = %v, want %v", checks, tt.expectedChecks)
+			}
+			if len(tt.expectedChecks) > 0 && !reflect.DeepEqual(flags, tt.expectedFlags) {
+				t.Errorf("canIFlags() = %v, want %v", flags, tt.expectedFlags)
+			}
+		})
+	}
+}
+
+func TestTerminalExecuter_Preflight(t *testing.T) {
+	original := kubectlCanI
+	defer func() { kubectlCanI = original }()
+
+	var queries []string
+	kubectlCanI = func(ctx context.Context, args []string) (string, error) {
+		query := strings.Join(args, " ")
+		queries = append(queries, query)
+		if strings.HasPrefix(query, "list secrets") {
+			return "no", nil
+		}
+		return "yes", nil
+	}
+
+	te := NewTerminalExecuter(KubernetesExecuterType)
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+		queries  []string
+	}{
+		{
+			name:     "Allowed",
+			command:  "kubectl get pods -n default | grep web",
+			expected: "",
+			queries:  []string{"list pods --namespace default"},
+		},
+		{
+			name:     "Denied",
+			command:  "kubectl get pods,secrets -n default",
+			expected: "RBAC will deny `list secrets` in namespace default",
+			queries:  []string{"list pods --namespace default", "list secrets --namespace default"},
+		},
+		{
+			name:     "Not checked",
+			command:  "kubectl explain pods",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries = nil
+			warning, err := te.Preflight(context.Background(), tt.command)
+			if err != nil {
+				t.Fatalf("Preflight() error = %v", err)
+			}
+			if warning != tt.expected {
+				t.Errorf("Preflight() = %q, want %q", warning, tt.expected)
+			}
+			if !reflect.DeepEqual(queries, tt.queries) {
+				t.Errorf("Preflight() queries = %v, want %v", queries, tt.queries)
+			}
+		})
+	}
+
+	// types without a preflight check are never checked
+	warning, err := NewTerminalExecuter(HelmExecuterType).Preflight(context.Background(), "helm list")
+	if warning != "" || err != nil {
+		t.Errorf("Preflight() = %q, %v, want no warning", warning, err)
+	}
+}
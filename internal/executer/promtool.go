@@ -0,0 +1,26 @@
+package executer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// promtoolActions are the read-only actions of the promtool sub commands the executer allows.
+var promtoolActions = map[string][]string{
+	"check": {"config", "rules", "healthy", "ready", "web-config"},
+	"query": {"instant", "range", "series", "labels"},
+	"test":  {"rules"},
+}
+
+// validatePromtoolCommand checks that the command has the form `promtool <sub command>
+// <action>` with a read-only action. Queries go to the Prometheus server the agent names.
+func validatePromtoolCommand(cmd Command) error {
+	if len(cmd.Parts) < 3 || strings.HasPrefix(cmd.Parts[2], "-") {
+		return fmt.Errorf("%w: use promtool %s <%s>", ErrInvalidMainCommand, cmd.Parts[1], strings.Join(promtoolActions[cmd.Parts[1]], "|"))
+	}
+	if !slices.Contains(promtoolActions[cmd.Parts[1]], cmd.Parts[2]) {
+		return fmt.Errorf("%w: promtool %s %s", ErrOperationNotAllowed, cmd.Parts[1], cmd.Parts[2])
+	}
+	return nil
+}
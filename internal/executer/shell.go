@@ -0,0 +1,113 @@
+package executer
+
+import (
+	"slices"
+	"strings"
+)
+
+// Shell is the syntax of command lines, which decides how they are parsed for validation
+// and how they run, since quoting and escaping differ between shells.
+type Shell int
+
+const (
+	// ShellDefault uses the POSIX syntax on every platform.
+	ShellDefault Shell = iota
+	// ShellPOSIX reads command lines with the quoting rules of sh, and runs their commands
+	// without a shell, piping the output of each into the next.
+	ShellPOSIX
+	// ShellPowerShell reads command lines with the quoting rules of PowerShell, and runs
+	// their commands without a shell as well. The cmdlets allowed after a pipe run in
+	// Klama, see powerShellCmdlets.
+	ShellPowerShell
+)
+
+// powerShellPipedCommands are the PowerShell cmdlets allowed after a pipe, in addition
+// to the executer type's piped commands. Cmdlets that take script blocks, such as
+// Where-Object, are not allowed.
+var powerShellPipedCommands = []string{
+	"Select-String",
+	"Select-Object",
+	"Sort-Object",
+	"Measure-Object",
+	"Get-Unique",
+	"findstr",
+}
+
+// Resolve returns the shell used for ShellDefault.
+func (s Shell) Resolve() Shell {
+	if s != ShellDefault {
+		return s
+	}
+	return ShellPOSIX
+}
+
+// String returns the name of the shell.
+func (s Shell) String() string {
+	switch s.Resolve() {
+	case ShellPowerShell:
+		return "PowerShell"
+	default:
+		return "sh"
+	}
+}
+
+// powerShellArgs splits a PowerShell command line into the arguments of its piped
+// commands, with quotes removed. Anything but words and pipes is rejected, as commands
+// run without a shell.
+func powerShellArgs(line string) ([][]string, error) {
+	cmds := splitPipeline(line, ShellPowerShell)
+	if len(cmds) == 0 {
+		return nil, ErrEmptyCommand
+	}
+
+	args := make([][]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		if len(cmd.Parts) == 0 {
+			return nil, ErrEmptyCommand
+		}
+		argv := make([]string, 0, len(cmd.Parts))
+		for _, part := range cmd.Parts {
+			if err := validatePowerShellWord(part); err != nil {
+				return nil, err
+			}
+			argv = append(argv, unquotePowerShellWord(part))
+		}
+		args = append(args, argv)
+	}
+	return args, nil
+}
+
+// unquotePowerShellWord removes the quotes of a word, as PowerShell does before it
+// passes the word to a command. A doubled quote inside quotes is a literal quote.
+func unquotePowerShellWord(word string) string {
+	var b strings.Builder
+	quote := rune(0)
+	runes := []rune(word)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+		case c == quote && i+1 < len(runes) && runes[i+1] == quote:
+			b.WriteRune(c)
+			i++
+		case c == quote:
+			quote = 0
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// allowsPiped reports whether command may receive piped output. PowerShell command
+// names are case insensitive.
+func (s Shell) allowsPiped(allowed []string, command string) bool {
+	if s.Resolve() != ShellPowerShell {
+		return slices.Contains(allowed, command)
+	}
+
+	return slices.ContainsFunc(append(slices.Clone(allowed), powerShellPipedCommands...), func(name string) bool {
+		return strings.EqualFold(name, command)
+	})
+}
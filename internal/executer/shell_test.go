@@ -0,0 +1,101 @@
+package executer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTerminalExecuter_ValidatePowerShell(t *testing.T) {
+	executerType := KubernetesExecuterType
+	executerType.Shell = ShellPowerShell
+	te := NewTerminalExecuter(executerType)
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"Cmdlet pipe", "kubectl get pods | Select-String web", nil},
+		{"Case insensitive cmdlet", "kubectl get pods | select-string web | Select-Object -First 5", nil},
+		{"Single quoted jsonpath", "kubectl get pods -o jsonpath='{.items[*].metadata.name}'", nil},
+		{"Script block cmdlet", "kubectl get pods | Where-Object Name", ErrCommandNotAllowed},
+		{"Script block", "kubectl get pods | Select-Object -Property {Remove-Item x}", ErrScriptBlock},
+		{"Chaining", "kubectl get pods; Remove-Item x", ErrCommandChaining},
+		{"Newline", "kubectl get pods\nRemove-Item x", ErrCommandChaining},
+		{"Backslash is not an escape", `kubectl get "pods\" ; Remove-Item x ; "`, ErrCommandChaining},
+		{"Backslash pipe", `kubectl get pods \| Remove-Item x`, ErrCommandNotAllowed},
+		{"Subexpression in double quotes", `kubectl get "$(Remove-Item x)"`, ErrCommandSubstitution},
+		{"Array subexpression", "kubectl get @(Remove-Item x)", ErrCommandSubstitution},
+		{"Escape character", "kubectl get pods `; Remove-Item x", ErrEscapeCharacter},
+		{"Typographic quotes", "kubectl get “pods”", ErrUnsupportedQuote},
+		{"Redirection", "kubectl get pods > out.txt", ErrRedirection},
+		{"Parenthesized pipeline", `kubectl get pods (Remove-Item -Recurse C:\x)`, ErrSubshell},
+		{"Closing parenthesis", "kubectl get pods)", ErrSubshell},
+		{"Quoted parentheses", "kubectl get pods -l 'tier in (api)'", nil},
+		{"Variable", "kubectl get pods $env:USERPROFILE", ErrVariable},
+		{"Variable in double quotes", `kubectl get "$secret"`, ErrVariable},
+		{"Single quoted dollar", "kubectl get pods -o jsonpath='{$.items}'", nil},
+		{"Splatting", "kubectl get @args", ErrCommandSubstitution},
+		{"Stop parsing", "kubectl get pods --% & calc", ErrStopParsing},
+		{"Unquoted comma", "kubectl get pods,services", ErrArrayArgument},
+		{"Quoted comma", "kubectl get 'pods,services'", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := te.Validate(tt.command)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTerminalExecuter_ValidateNewline(t *testing.T) {
+	te := NewTerminalExecuter(testExecuterType)
+
+	if err := te.Validate("echo hello world\ncat /etc/passwd"); !errors.Is(err, ErrCommandChaining) {
+		t.Errorf("Validate() error = %v, want %v", err, ErrCommandChaining)
+	}
+	if err := te.Validate("echo hello 'big\nworld' | grep world\n"); err != nil {
+		t.Errorf("Validate() error = %v, want nil", err)
+	}
+}
+
+func TestSplitPipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		shell    Shell
+		expected []Command
+	}{
+		{
+			"Escaped pipe in sh",
+			`echo hello \| grep h`,
+			ShellPOSIX,
+			[]Command{{Parts: []string{"echo", "hello", `\|`, "grep", "h"}}},
+		},
+		{
+			"Backslash pipe in PowerShell",
+			`echo hello \| grep h`,
+			ShellPowerShell,
+			[]Command{{Parts: []string{"echo", "hello", `\`}}, {Parts: []string{"grep", "h"}}},
+		},
+		{
+			"Quoted backslash in PowerShell",
+			`echo "C:\temp\" | grep h`,
+			ShellPowerShell,
+			[]Command{{Parts: []string{"echo", `"C:\temp\"`}}, {Parts: []string{"grep", "h"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := splitPipeline(tt.command, tt.shell)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("splitPipeline() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
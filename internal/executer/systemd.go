@@ -0,0 +1,192 @@
+package executer
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultJournalMaxAge is how far back journalctl may read in a systemd session.
+const DefaultJournalMaxAge = 24 * time.Hour
+
+// SystemdExecuterType represents the type of the terminal executer for read-only systemd
+// commands, with journalctl limited to the last DefaultJournalMaxAge.
+var SystemdExecuterType = NewSystemdExecuterType(DefaultJournalMaxAge)
+
+// NewSystemdExecuterType returns the executer type for read-only systemctl, journalctl
+// and loginctl commands. journalctl must pass --since, at most maxAge back.
+func NewSystemdExecuterType(maxAge time.Duration) TerminalExecuterType {
+	return TerminalExecuterType{
+		AllowedCommands: []string{"systemctl", "journalctl", "loginctl"},
+		CommandSubCommands: map[string][]string{
+			"systemctl": {
+				"status",
+				"show",
+				"cat",
+				"list-units",
+				"list-unit-files",
+				"list-timers",
+				"list-dependencies",
+				"list-jobs",
+				"is-active",
+				"is-enabled",
+				"is-failed",
+			},
+			"loginctl": {
+				"list-sessions",
+				"session-status",
+				"show-session",
+				"list-users",
+				"user-status",
+				"show-user",
+				"list-seats",
+				"seat-status",
+				"show-seat",
+			},
+		},
+		AllowedPipedCommands: defaultPipedCommands,
+		ValidateCommand: func(cmd Command) error {
+			if err := validateLinuxCommand(cmd); err != nil {
+				return err
+			}
+			if cmd.Parts[0] == "journalctl" {
+				return validateJournalSince(cmd, maxAge, time.Now())
+			}
+			return nil
+		},
+		Shell: ShellPOSIX,
+	}
+}
+
+// journalValueFlags are the long journalctl flags that take the next argument as their
+// value, which may look like a -S flag.
+var journalValueFlags = []string{
+	"--unit", "--user-unit", "--identifier", "--priority", "--facility", "--output", "--output-fields",
+	"--cursor", "--after-cursor", "--cursor-file", "--until", "--directory", "--file", "--root",
+	"--image", "--namespace", "--grep", "--field", "--machine",
+}
+
+// validateJournalSince requires a journalctl --since that is at most maxAge before now.
+// journalctl reads from the last --since it is given, so every one of them is checked,
+// including abbreviations such as --sinc and -S at the end of a cluster such as -qS.
+func validateJournalSince(cmd Command, maxAge time.Duration, now time.Time) error {
+	valueShort := linuxFlagRules["journalctl"].valueShort
+	var values []string
+	args := cmd.Parts[1:]
+	for i := 0; i < len(args); i++ {
+		arg := unquote(args[i])
+		if arg == "--" {
+			break
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		flags := shortFlags(arg, valueShort)
+		switch _, since := matchLongFlag(arg, []string{"--since"}); {
+		case since:
+			// the value follows the = or is the next argument
+		case len(flags) > 0 && strings.ContainsRune(valueShort, flags[len(flags)-1]):
+			// the value is the rest of the cluster or the next argument
+			value = arg[1+len(string(flags)):]
+			hasValue = value != ""
+			if flags[len(flags)-1] != 'S' {
+				if !hasValue {
+					i++
+				}
+				continue
+			}
+		case slices.Contains(journalValueFlags, name):
+			if !hasValue {
+				i++
+			}
+			continue
+		default:
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				break
+			}
+			i++
+			value = unquote(args[i])
+		}
+		values = append(values, value)
+	}
+
+	limit := fmt.Sprintf("use --since with a time within the last %s, such as --since \"-4h\" or --since \"2 hours ago\"", maxAge)
+	if len(values) == 0 {
+		return fmt.Errorf("%w: journalctl without --since, %s", ErrOperationNotAllowed, limit)
+	}
+
+	for _, since := range values {
+		start, err := journalSince(since, now)
+		if err != nil {
+			return fmt.Errorf("%w: journalctl --since %s, %s", ErrOperationNotAllowed, since, limit)
+		}
+		if start.Before(now.Add(-maxAge)) {
+			return fmt.Errorf("%w: journalctl --since %s reads more than %s, %s", ErrOperationNotAllowed, since, maxAge, limit)
+		}
+	}
+	return nil
+}
+
+// journalSpanPattern matches a systemd time span, such as -4h, 2 hours ago or 1h 30min ago.
+var journalSpanPattern = regexp.MustCompile(`^-?((?:\s*\d+\s*[a-z]+)+)(\s+ago)?$`)
+
+var journalSpanToken = regexp.MustCompile(`(\d+)\s*([a-z]+)`)
+
+var journalSpanUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// journalTimeLayouts are the absolute timestamps journalctl accepts, in local time.
+var journalTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", "2006-01-02T15:04:05"}
+
+// journalSince returns the start time of a journalctl --since value.
+func journalSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	lower := strings.ToLower(value)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch lower {
+	case "now":
+		return now, nil
+	case "today":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	}
+
+	if match := journalSpanPattern.FindStringSubmatch(lower); match != nil {
+		// a span is in the past when it has a minus sign or ago
+		if !strings.HasPrefix(lower, "-") && match[2] == "" {
+			return time.Time{}, fmt.Errorf("time span %q is not in the past", value)
+		}
+		var span time.Duration
+		for _, token := range journalSpanToken.FindAllStringSubmatch(match[1], -1) {
+			unit, ok := journalSpanUnits[token[2]]
+			if !ok {
+				return time.Time{}, fmt.Errorf("unknown time unit %q", token[2])
+			}
+			n, err := strconv.Atoi(token[1])
+			if err != nil {
+				return time.Time{}, err
+			}
+			span += time.Duration(n) * unit
+		}
+		return now.Add(-span), nil
+	}
+
+	for _, layout := range journalTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported time %q", value)
+}
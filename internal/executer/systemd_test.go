@@ -0,0 +1,78 @@
+package executer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJournalSince(t *testing.T) {
+	now := time.Date(2024, 5, 3, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"now", now, false},
+		{"today", time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC), false},
+		{"yesterday", time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), false},
+		{"-4h", now.Add(-4 * time.Hour), false},
+		{"-30min", now.Add(-30 * time.Minute), false},
+		{"2 hours ago", now.Add(-2 * time.Hour), false},
+		{"1h 30min ago", now.Add(-90 * time.Minute), false},
+		{"2024-05-03 09:00", time.Date(2024, 5, 3, 9, 0, 0, 0, time.UTC), false},
+		{"2024-05-01", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2024-05-03T09:00:00", time.Date(2024, 5, 3, 9, 0, 0, 0, time.UTC), false},
+		{"4h", time.Time{}, true},
+		{"3 fortnights ago", time.Time{}, true},
+		{"last monday", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := journalSince(tt.value, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("journalSince() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("journalSince() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateJournalSince(t *testing.T) {
+	now := time.Date(2024, 5, 3, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		command string
+		wantErr bool
+	}{
+		{`journalctl -u nginx --since "-4h" --no-pager`, false},
+		{`journalctl -u nginx --since="2 hours ago"`, false},
+		{"journalctl -p err -S today", false},
+		{"journalctl -S-1h", false},
+		{"journalctl -u nginx -n 100", true},
+		{`journalctl --since "3 days ago"`, true},
+		{"journalctl --since 2024-04-01", true},
+		{`journalctl --since "last monday"`, true},
+		{"journalctl --sinc 2000-01-01", true},
+		{"journalctl --si=2000-01-01", true},
+		{"journalctl -qS2000-01-01", true},
+		{"journalctl -qS -1h", false},
+		{"journalctl -S -1h -S 2000-01-01", true},
+		{`journalctl --since -1h "--since=2000-01-01"`, true},
+		{"journalctl --since -1h --until -S2000-01-01", false},
+		{"journalctl -u -S2000-01-01", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			cmd := Command{Parts: splitCommand(tt.command, ShellPOSIX)}
+			err := validateJournalSince(cmd, DefaultJournalMaxAge, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateJournalSince() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,11 +1,15 @@
 package executer
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"os/exec"
+	"io"
+	"os"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
 )
 
@@ -15,75 +19,68 @@ var (
 	ErrCommandChaining      = fmt.Errorf("command chaining is not allowed")
 	ErrCommandSubstitution  = fmt.Errorf("command substitution is not allowed")
 	ErrRedirection          = fmt.Errorf("redirection is not allowed")
+	ErrScriptBlock          = fmt.Errorf("script blocks are not allowed")
+	ErrEscapeCharacter      = fmt.Errorf("escape characters are not allowed")
+	ErrUnsupportedQuote     = fmt.Errorf("typographic quotes are not allowed")
 	ErrUnmatchedQuote       = fmt.Errorf("unmatched quote in argument")
+	ErrSubshell             = fmt.Errorf("subshells are not allowed")
+	ErrANSIQuote            = fmt.Errorf("$'...' and $\"...\" quotes are not allowed")
+	ErrGlob                 = fmt.Errorf("unquoted glob patterns are not allowed, quote the argument")
+	ErrVariable             = fmt.Errorf("variables are not allowed")
+	ErrArrayArgument        = fmt.Errorf("unquoted commas are not allowed, quote the argument")
+	ErrStopParsing          = fmt.Errorf("the stop-parsing token --%% is not allowed")
 	ErrInvalidMainCommand   = fmt.Errorf("main command is not valid")
 	ErrCommandNotAllowed    = fmt.Errorf("command is not allowed")
 	ErrSubCommandNotAllowed = fmt.Errorf("sub command is not allowed")
+	ErrOperationNotAllowed  = fmt.Errorf("operation is not allowed")
 )
 
 type Command struct {
 	Parts []string
 }
 
-// TerminalExecuterType represents the type of the terminal executer.
-type TerminalExecuterType struct {
-	AllowedCommands      []string
-	AllowedSubCommands   []string
-	AllowedPipedCommands []string
-}
-
-var (
-	// KubernetesExecuterType represents the type of the terminal executer for kubectl commands.
-	KubernetesExecuterType = TerminalExecuterType{
-		AllowedCommands: []string{"kubectl"},
-		AllowedSubCommands: []string{
-			"get",
-			"describe",
-			"logs",
-			"top",
-			"explain",
-		},
-		AllowedPipedCommands: []string{
-			"grep",
-			"awk",
-			"sort",
-			"uniq",
-			"head",
-			"tail",
-			"cut",
-		},
-	}
-)
-
 // TerminalExecuter is a simple executer that manages shell command execution and caching.
+// Commands may run concurrently, such as the steps of a plan.
 type TerminalExecuter struct {
-	executedCommands map[string]string
+	mu               sync.RWMutex // guards executedCommands and cacheOptions
+	executedCommands map[string]CacheEntry
+	cacheOptions     CacheOptions
 	executerType     TerminalExecuterType
+	now              func() time.Time
 }
 
 // NewTerminalExecuter creates a new TerminalExecuter.
 func NewTerminalExecuter(executerType TerminalExecuterType) *TerminalExecuter {
 	return &TerminalExecuter{
-		executedCommands: make(map[string]string),
+		executedCommands: make(map[string]CacheEntry),
 		executerType:     executerType,
+		now:              time.Now,
 	}
 }
 
 // Run executes a command and returns the output.
 // It caches the results of previously executed commands.
-func (tx *TerminalExecuter) Run(ctx context.Context, command string) ExecuterResponse {
-	if output, exists := tx.executedCommands[command]; exists {
-		return ExecuterResponse{Result: output}
+func (tx *TerminalExecuter) Run(ctx context.Context, command string) (response ExecuterResponse) {
+	ctx, span := startSpan(ctx, command)
+	defer func() { endSpan(span, response) }()
+
+	if entry, exists := tx.cached(command); exists && !cacheSkipped(ctx) {
+		return entry.response()
 	}
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", command)
-	output, err := cmd.CombinedOutput()
-	resp := strings.TrimSpace(string(output))
+	var stdout, stderr bytes.Buffer
+	start := time.Now()
+	exitCode, err := tx.run(ctx, tx.EffectiveCommand(command), &stdout, &stderr)
 
-	result := ExecuterResponse{Result: resp}
+	result := ExecuterResponse{
+		Stdout:   strings.TrimSpace(stdout.String()),
+		Stderr:   strings.TrimSpace(stderr.String()),
+		ExitCode: exitCode,
+		Duration: time.Since(start),
+	}
 	switch {
 	case err == nil:
-		tx.executedCommands[command] = resp
+		tx.cache(command, result.Stdout, result.Stderr)
 	case ctx.Err() == context.DeadlineExceeded:
 		result.Error = fmt.Errorf("command execution timed out: %w", ctx.Err())
 	default:
@@ -93,6 +90,71 @@ func (tx *TerminalExecuter) Run(ctx context.Context, command string) ExecuterRes
 	return result
 }
 
+// run runs the command line without a shell and returns the exit code of its last
+// command, -1 when it did not exit.
+func (tx *TerminalExecuter) run(ctx context.Context, line string, stdout, stderr io.Writer) (int, error) {
+	if tx.executerType.Shell.Resolve() != ShellPowerShell {
+		args, err := shellArgs(line)
+		if err != nil {
+			return -1, err
+		}
+		return runArgs(ctx, args, tx.env(), nil, stdout, stderr)
+	}
+
+	args, err := powerShellArgs(line)
+	if err != nil {
+		return -1, err
+	}
+	return runPowerShellArgs(ctx, args, tx.env(), nil, stdout, stderr)
+}
+
+// env returns the environment of the commands, nil for the environment of Klama.
+func (tx *TerminalExecuter) env() []string {
+	if len(tx.executerType.Env) == 0 {
+		return nil
+	}
+	return append(os.Environ(), tx.executerType.Env...)
+}
+
+// EffectiveCommand returns the command line that is executed for command, after the
+// executer type's RewriteCommand is applied to the main command.
+func (tx *TerminalExecuter) EffectiveCommand(command string) string {
+	if tx.executerType.RewriteCommand == nil {
+		return command
+	}
+
+	cmds := splitPipeline(command, tx.executerType.Shell)
+	if len(cmds) == 0 {
+		return command
+	}
+	cmds[0] = tx.executerType.RewriteCommand(cmds[0])
+	return joinCommands(cmds)
+}
+
+// ExecutedCommands returns a copy of the cached command outputs that did not expire.
+func (tx *TerminalExecuter) ExecutedCommands() map[string]string {
+	entries := tx.CacheEntries()
+	commands := make(map[string]string, len(entries))
+	for command, entry := range entries {
+		commands[command] = entry.Output
+	}
+	return commands
+}
+
+// RestoreExecutedCommands adds previously executed commands and their outputs to the
+// cache, keeping the outputs it already has. Their age is unknown, so they expire right
+// away when the cache has a TTL.
+func (tx *TerminalExecuter) RestoreExecutedCommands(commands map[string]string) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	for command, output := range commands {
+		if _, exists := tx.executedCommands[command]; !exists {
+			tx.executedCommands[command] = CacheEntry{Output: output}
+		}
+	}
+}
+
 // Validate validates a command.
 func (tx *TerminalExecuter) Validate(command string) error {
 
@@ -100,11 +162,14 @@ func (tx *TerminalExecuter) Validate(command string) error {
 		return ErrEmptyCommand
 	}
 
-	if _, exists := tx.executedCommands[command]; exists {
+	if _, exists := tx.cached(command); exists {
 		return nil
 	}
 
-	cmds := splitCommandsByPipe(command)
+	cmds := splitPipeline(command, tx.executerType.Shell)
+	if len(cmds) == 0 {
+		return ErrEmptyCommand
+	}
 	for i, cmd := range cmds {
 		if err := tx.validateSingleCommand(cmd, i == 0); err != nil {
 			return err
@@ -114,26 +179,34 @@ func (tx *TerminalExecuter) Validate(command string) error {
 	return nil
 }
 
+// SplitPipeline splits a command line into its piped commands. Quotes are kept in the
+// command parts.
+func SplitPipeline(command string) []Command {
+	return splitCommandsByPipe(command)
+}
+
 func splitCommandsByPipe(command string) []Command {
+	return splitPipeline(command, ShellPOSIX)
+}
+
+// splitPipeline splits a command line into its piped commands, following the quoting
+// rules of the shell.
+func splitPipeline(command string, shell Shell) []Command {
+	if shell.Resolve() != ShellPowerShell {
+		return splitShellPipeline(command)
+	}
+
 	var commands []Command
 	var current strings.Builder
 	inSingleQuote := false
 	inDoubleQuote := false
-	escaped := false
 
 	for _, char := range command {
-		if escaped {
-			current.WriteRune(char)
-			escaped = false
-			continue
-		}
-
 		switch char {
-		case '\\':
-			escaped = true
-			current.WriteRune(char)
 		case '\'':
-			inSingleQuote = !inSingleQuote
+			if !inDoubleQuote {
+				inSingleQuote = !inSingleQuote
+			}
 			current.WriteRune(char)
 		case '"':
 			if !inSingleQuote {
@@ -142,7 +215,7 @@ func splitCommandsByPipe(command string) []Command {
 			current.WriteRune(char)
 		case '|':
 			if !inSingleQuote && !inDoubleQuote {
-				commands = append(commands, Command{Parts: splitCommand(strings.TrimSpace(current.String()))})
+				commands = append(commands, Command{Parts: splitCommand(strings.TrimSpace(current.String()), shell)})
 				current.Reset()
 			} else {
 				current.WriteRune(char)
@@ -153,7 +226,7 @@ func splitCommandsByPipe(command string) []Command {
 	}
 
 	if current.Len() > 0 {
-		commands = append(commands, Command{Parts: splitCommand(strings.TrimSpace(current.String()))})
+		commands = append(commands, Command{Parts: splitCommand(strings.TrimSpace(current.String()), shell)})
 	}
 
 	return commands
@@ -168,20 +241,35 @@ func (tx *TerminalExecuter) validateCommandArguments(args []string) error {
 	return nil
 }
 
+// validateArgument rejects chaining, substitution, subshells, globs and redirection
+// outside quotes, following the syntax of the shell.
 func (tx *TerminalExecuter) validateArgument(arg string) error {
+	if tx.executerType.Shell.Resolve() != ShellPowerShell {
+		return validateShellWords(arg)
+	}
+	return validatePowerShellWord(arg)
+}
+
+// validatePowerShellWord rejects anything in a part of a PowerShell command line but
+// plain words. Variables and subexpressions expand inside double quotes too, and outside
+// quotes, parentheses evaluate a pipeline, commas build arrays and --% stops parsing, so
+// they are rejected along with script blocks, escape characters and typographic quotes.
+func validatePowerShellWord(arg string) error {
+	if arg == "--%" {
+		return ErrStopParsing
+	}
+
 	inSingleQuote := false
 	inDoubleQuote := false
-	escaped := false
 
 	for i, char := range arg {
-		if escaped {
-			escaped = false
-			continue
+		quoted := inSingleQuote || inDoubleQuote
+		next := byte(0)
+		if i+1 < len(arg) {
+			next = arg[i+1]
 		}
 
 		switch char {
-		case '\\':
-			escaped = true
 		case '\'':
 			if !inDoubleQuote {
 				inSingleQuote = !inSingleQuote
@@ -190,22 +278,44 @@ func (tx *TerminalExecuter) validateArgument(arg string) error {
 			if !inSingleQuote {
 				inDoubleQuote = !inDoubleQuote
 			}
-		case ';', '&':
-			if !inSingleQuote && !inDoubleQuote {
+		case ';', '&', '\n', '\r':
+			if !quoted {
 				return ErrCommandChaining
 			}
 		case '`':
-			if !inSingleQuote && !inDoubleQuote {
-				return ErrCommandSubstitution
+			if !inSingleQuote {
+				return ErrEscapeCharacter
 			}
 		case '$':
-			if !inSingleQuote && !inDoubleQuote && i+1 < len(arg) && arg[i+1] == '(' {
+			switch {
+			case inSingleQuote:
+			case next == '(':
+				return ErrCommandSubstitution
+			default:
+				return ErrVariable
+			}
+		case '@':
+			if !quoted && (next == '(' || next == '{' || i == 0) {
 				return ErrCommandSubstitution
 			}
+		case '(', ')':
+			if !quoted {
+				return ErrSubshell
+			}
+		case ',':
+			if !quoted {
+				return ErrArrayArgument
+			}
+		case '{', '}':
+			if !quoted {
+				return ErrScriptBlock
+			}
 		case '>', '<':
-			if !inSingleQuote && !inDoubleQuote {
+			if !quoted {
 				return ErrRedirection
 			}
+		case '‘', '’', '‚', '‛', '“', '”', '„':
+			return ErrUnsupportedQuote
 		}
 	}
 
@@ -216,25 +326,22 @@ func (tx *TerminalExecuter) validateArgument(arg string) error {
 	return nil
 }
 
-func splitCommand(command string) []string {
+// splitCommand splits a command into its parts. Quotes are kept, and operators and
+// newlines outside quotes are kept in the parts so they are rejected during validation.
+func splitCommand(command string, shell Shell) []string {
+	if shell.Resolve() != ShellPowerShell {
+		var parts []string
+		for _, cmd := range splitShellPipeline(command) {
+			parts = append(parts, cmd.Parts...)
+		}
+		return parts
+	}
+
 	var parts []string
 	var current strings.Builder
 	inQuote := rune(0)
-	escaped := false
 
 	for _, char := range command {
-		if escaped {
-			current.WriteRune(char)
-			escaped = false
-			continue
-		}
-
-		if char == '\\' {
-			escaped = true
-			current.WriteRune(char)
-			continue
-		}
-
 		if inQuote != 0 {
 			if char == inQuote {
 				inQuote = 0
@@ -243,7 +350,7 @@ func splitCommand(command string) []string {
 		} else if char == '\'' || char == '"' {
 			inQuote = char
 			current.WriteRune(char)
-		} else if unicode.IsSpace(char) {
+		} else if unicode.IsSpace(char) && char != '\n' && char != '\r' {
 			if current.Len() > 0 {
 				parts = append(parts, current.String())
 				current.Reset()
@@ -265,28 +372,35 @@ func (tx *TerminalExecuter) validateSingleCommand(cmd Command, isMainCommand boo
 		return ErrEmptyCommand
 	}
 
-	checkSubCmd := false
-	minNumParts := 1
-	if len(tx.executerType.AllowedSubCommands) > 0 {
-		checkSubCmd = true
-		minNumParts = 2
-	}
-
 	if isMainCommand {
-		if len(cmd.Parts) < minNumParts {
-			return ErrInvalidMainCommand
-		}
 		if !slices.Contains(tx.executerType.AllowedCommands, cmd.Parts[0]) {
 			return fmt.Errorf("%w: %s", ErrCommandNotAllowed, cmd.Parts[0])
 		}
-		if checkSubCmd {
-			if !slices.Contains(tx.executerType.AllowedSubCommands, cmd.Parts[1]) {
+		allowedSubCommands := tx.executerType.subCommandsFor(cmd.Parts[0])
+		if len(allowedSubCommands) > 0 {
+			if len(cmd.Parts) < 2 {
+				return ErrInvalidMainCommand
+			}
+			if !slices.Contains(allowedSubCommands, cmd.Parts[1]) {
 				return fmt.Errorf("%w: %s", ErrSubCommandNotAllowed, cmd.Parts[1])
 			}
 		}
-	} else if !slices.Contains(tx.executerType.AllowedPipedCommands, cmd.Parts[0]) {
+		if tx.executerType.ValidateCommand != nil {
+			if err := tx.executerType.ValidateCommand(cmd); err != nil {
+				return err
+			}
+		}
+	} else if !tx.executerType.Shell.allowsPiped(tx.executerType.AllowedPipedCommands, cmd.Parts[0]) {
 		return fmt.Errorf("%w: %s", ErrCommandNotAllowed, cmd.Parts[0])
+	} else if err := validatePipedCommand(cmd); err != nil {
+		return err
 	}
 
-	return tx.validateCommandArguments(cmd.Parts)
+	if err := tx.validateCommandArguments(cmd.Parts); err != nil {
+		return err
+	}
+	if !isMainCommand && tx.executerType.Shell.Resolve() == ShellPowerShell {
+		return validateCmdlet(cmd)
+	}
+	return nil
 }
@@ -3,6 +3,7 @@ package executer
 import (
 	"context"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -45,6 +46,27 @@ func TestTerminalExecuter_Run(t *testing.T) {
 	}
 }
 
+func TestTerminalExecuter_RunStreams(t *testing.T) {
+	te := NewTerminalExecuter(TerminalExecuterType{AllowedCommands: []string{"cat"}})
+
+	result := te.Run(context.Background(), "cat missing-file")
+	if result.Error == nil {
+		t.Fatal("Run() expected an error")
+	}
+	if result.Stdout != "" {
+		t.Errorf("Run() stdout = %q, want it empty", result.Stdout)
+	}
+	if !strings.Contains(result.Stderr, "missing-file") {
+		t.Errorf("Run() stderr = %q, want the cat error", result.Stderr)
+	}
+	if result.ExitCode != 1 {
+		t.Errorf("Run() exit code = %d, want 1", result.ExitCode)
+	}
+	if result.Duration <= 0 {
+		t.Errorf("Run() duration = %v, want it measured", result.Duration)
+	}
+}
+
 func TestTerminalExecuter_Validate(t *testing.T) {
 	te := NewTerminalExecuter(testExecuterType)
 
@@ -131,7 +153,7 @@ func TestSplitCommand(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := splitCommand(tt.command)
+			result := splitCommand(tt.command, ShellPOSIX)
 			if !reflect.DeepEqual(result, tt.expected) {
 				t.Errorf("splitCommand() = %v, want %v", result, tt.expected)
 			}
@@ -192,3 +214,20 @@ func TestTerminalExecuter_validateArgument(t *testing.T) {
 		})
 	}
 }
+
+func TestTerminalExecuter_ExecutedCommands(t *testing.T) {
+	te := NewTerminalExecuter(testExecuterType)
+	te.RestoreExecutedCommands(map[string]string{"echo restored": "restored"})
+
+	// restored commands are served from the cache
+	result := te.Run(context.Background(), "echo restored")
+	if result.Stdout != "restored" {
+		t.Errorf("Run() = %v, want cached output", result.Stdout)
+	}
+
+	te.Run(context.Background(), "echo hello")
+	commands := te.ExecutedCommands()
+	if len(commands) != 2 || commands["echo hello"] != "hello" {
+		t.Errorf("ExecutedCommands() = %v", commands)
+	}
+}
@@ -0,0 +1,147 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Tool is a named executer of a multi-tool session, such as kubectl or helm.
+type Tool struct {
+	Name        string
+	Description string // tells the agent what the tool is for
+	Executer    CachingExecuter
+}
+
+// Toolbox runs the commands of a session with one of several tools. The agent names
+// the tool of each command, commands without a tool run with the first tool that
+// accepts them.
+type Toolbox struct {
+	tools []Tool
+}
+
+// NewToolbox returns a toolbox of the tools, in the order they are offered to the agent.
+func NewToolbox(tools ...Tool) *Toolbox {
+	return &Toolbox{tools: tools}
+}
+
+// Tools returns the tools of the toolbox.
+func (tb *Toolbox) Tools() []Tool {
+	return tb.tools
+}
+
+// Tool returns the executer of the named tool.
+func (tb *Toolbox) Tool(name string) (CachingExecuter, error) {
+	names := make([]string, 0, len(tb.tools))
+	for _, tool := range tb.tools {
+		if tool.Name == name {
+			return tool.Executer, nil
+		}
+		names = append(names, tool.Name)
+	}
+	return nil, fmt.Errorf("unknown tool %q, use one of %s", name, strings.Join(names, ", "))
+}
+
+// route returns the tool that accepts the command and the validation error of the
+// command. When no tool accepts it, the error is the one of the tool that allows its
+// main command, if any.
+func (tb *Toolbox) route(command string) (CachingExecuter, error) {
+	if len(tb.tools) == 0 {
+		return nil, ErrCommandNotAllowed
+	}
+
+	var firstErr error
+	for _, tool := range tb.tools {
+		err := tool.Executer.Validate(command)
+		if err == nil {
+			return tool.Executer, nil
+		}
+		if firstErr == nil || errors.Is(firstErr, ErrCommandNotAllowed) && !errors.Is(err, ErrCommandNotAllowed) {
+			firstErr = err
+		}
+	}
+	return tb.tools[0].Executer, firstErr
+}
+
+// Run runs the command with the first tool that accepts it.
+func (tb *Toolbox) Run(ctx context.Context, command string) ExecuterResponse {
+	exec, err := tb.route(command)
+	if exec == nil {
+		return ExecuterResponse{Stderr: err.Error(), ExitCode: -1, Error: err}
+	}
+	return exec.Run(ctx, command)
+}
+
+// Validate checks that a tool accepts the command.
+func (tb *Toolbox) Validate(command string) error {
+	_, err := tb.route(command)
+	return err
+}
+
+// Preflight checks the command with the tool that accepts it.
+func (tb *Toolbox) Preflight(ctx context.Context, command string) (string, error) {
+	exec, err := tb.route(command)
+	if err != nil {
+		return "", nil
+	}
+	return exec.Preflight(ctx, command)
+}
+
+// EffectiveCommand returns the command line the tool that accepts the command runs.
+func (tb *Toolbox) EffectiveCommand(command string) string {
+	exec, err := tb.route(command)
+	if err != nil {
+		return command
+	}
+	return exec.EffectiveCommand(command)
+}
+
+// ExecutedCommands returns the cached command outputs of all tools.
+func (tb *Toolbox) ExecutedCommands() map[string]string {
+	commands := make(map[string]string)
+	for _, tool := range tb.tools {
+		for command, output := range tool.Executer.ExecutedCommands() {
+			commands[command] = output
+		}
+	}
+	return commands
+}
+
+// RestoreExecutedCommands adds previously executed commands to the cache of the tool
+// that accepts them.
+func (tb *Toolbox) RestoreExecutedCommands(commands map[string]string) {
+	for command, output := range commands {
+		if exec, err := tb.route(command); err == nil {
+			exec.RestoreExecutedCommands(map[string]string{command: output})
+		}
+	}
+}
+
+// SetCacheOptions changes how the caches of all tools answer repeated commands.
+func (tb *Toolbox) SetCacheOptions(options CacheOptions) {
+	for _, tool := range tb.tools {
+		tool.Executer.SetCacheOptions(options)
+	}
+}
+
+// CacheEntries returns the cached command outputs of all tools that did not expire.
+func (tb *Toolbox) CacheEntries() map[string]CacheEntry {
+	entries := make(map[string]CacheEntry)
+	for _, tool := range tb.tools {
+		for command, entry := range tool.Executer.CacheEntries() {
+			entries[command] = entry
+		}
+	}
+	return entries
+}
+
+// RestoreCacheEntries adds cached command outputs to the cache of the tool that accepts
+// them.
+func (tb *Toolbox) RestoreCacheEntries(entries map[string]CacheEntry) {
+	for command, entry := range entries {
+		if exec, err := tb.route(command); err == nil {
+			exec.RestoreCacheEntries(map[string]CacheEntry{command: entry})
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestToolbox() *Toolbox {
+	return NewToolbox(
+		Tool{Name: "echo", Description: "prints text", Executer: NewTerminalExecuter(TerminalExecuterType{
+			AllowedCommands:    []string{"echo"},
+			AllowedSubCommands: []string{"hello"},
+		})},
+		Tool{Name: "cat", Description: "prints files", Executer: NewTerminalExecuter(TerminalExecuterType{
+			AllowedCommands: []string{"cat"},
+		})},
+	)
+}
+
+func TestToolbox_Tool(t *testing.T) {
+	tb := newTestToolbox()
+
+	if len(tb.Tools()) != 2 {
+		t.Fatalf("Tools() = %d tools, want 2", len(tb.Tools()))
+	}
+
+	exec, err := tb.Tool("cat")
+	if err != nil {
+		t.Fatalf("Tool() error = %v", err)
+	}
+	if err := exec.Validate("echo hello"); !errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("cat tool Validate() error = %v, want %v", err, ErrCommandNotAllowed)
+	}
+
+	_, err = tb.Tool("kubectl")
+	if err == nil || !strings.Contains(err.Error(), "echo, cat") {
+		t.Errorf("Tool() error = %v, want the available tools", err)
+	}
+}
+
+func TestToolbox_Validate(t *testing.T) {
+	tb := newTestToolbox()
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"First tool", "echo hello", nil},
+		{"Second tool", "cat /etc/hostname", nil},
+		{"Error of the tool of the command", "echo world", ErrSubCommandNotAllowed},
+		{"No tool", "rm -rf /", ErrCommandNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tb.Validate(tt.command)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := NewToolbox().Validate("echo hello"); !errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("empty toolbox Validate() error = %v, want %v", err, ErrCommandNotAllowed)
+	}
+}
+
+func TestToolbox_ExecutedCommands(t *testing.T) {
+	tb := newTestToolbox()
+
+	if resp := tb.Run(context.Background(), "echo hello"); resp.Error != nil || resp.Stdout != "hello" {
+		t.Fatalf("Run() = %q, %v", resp.Stdout, resp.Error)
+	}
+
+	tb.RestoreExecutedCommands(map[string]string{
+		"cat /etc/hostname": "web-1",
+		"rm -rf /":          "",
+	})
+
+	commands := tb.ExecutedCommands()
+	if len(commands) != 2 || commands["echo hello"] != "hello" || commands["cat /etc/hostname"] != "web-1" {
+		t.Errorf("ExecutedCommands() = %v", commands)
+	}
+
+	exec, _ := tb.Tool("cat")
+	if _, ok := exec.ExecutedCommands()["cat /etc/hostname"]; !ok {
+		t.Error("restored command was not added to the cache of its tool")
+	}
+}
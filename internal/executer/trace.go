@@ -0,0 +1,34 @@
+package executer
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the tracer of the commands executers run.
+const tracerName = "github.com/eliran89c/klama/internal/executer"
+
+// startSpan starts the span of a command run.
+func startSpan(ctx context.Context, command string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "executer.Run", trace.WithAttributes(
+		attribute.String("klama.command", command),
+	))
+}
+
+// endSpan records the outcome of a command run and ends its span.
+func endSpan(span trace.Span, response ExecuterResponse) {
+	span.SetAttributes(
+		attribute.Int("klama.exit_code", response.ExitCode),
+		attribute.Bool("klama.cached", response.Cached),
+		attribute.Int64("klama.duration_ms", response.Duration.Milliseconds()),
+	)
+	if response.Error != nil {
+		span.RecordError(response.Error)
+		span.SetStatus(codes.Error, response.Error.Error())
+	}
+	span.End()
+}
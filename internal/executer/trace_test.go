@@ -0,0 +1,48 @@
+package executer
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTerminalExecuter_RunTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	tx := NewTerminalExecuter(TerminalExecuterType{AllowedCommands: []string{"echo", "false"}})
+	tx.Run(context.Background(), "echo hello")
+	tx.Run(context.Background(), "echo hello")
+	tx.Run(context.Background(), "false")
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("recorded %d spans, want 3", len(spans))
+	}
+
+	attrs := func(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		values := map[attribute.Key]attribute.Value{}
+		for _, kv := range span.Attributes() {
+			values[kv.Key] = kv.Value
+		}
+		return values
+	}
+
+	first := attrs(spans[0])
+	if spans[0].Name() != "executer.Run" || first["klama.command"].AsString() != "echo hello" || first["klama.cached"].AsBool() {
+		t.Errorf("unexpected span %s %v", spans[0].Name(), first)
+	}
+	if !attrs(spans[1])["klama.cached"].AsBool() {
+		t.Error("the repeated command was not traced as cached")
+	}
+	if failed := attrs(spans[2]); failed["klama.exit_code"].AsInt64() != 1 || spans[2].Status().Code != codes.Error {
+		t.Errorf("the failed command was traced as %v, %v", failed, spans[2].Status())
+	}
+}
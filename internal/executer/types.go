@@ -0,0 +1,284 @@
+package executer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TerminalExecuterType represents the type of the terminal executer.
+type TerminalExecuterType struct {
+	AllowedCommands      []string
+	AllowedSubCommands   []string
+	AllowedPipedCommands []string
+
+	// CommandSubCommands overrides AllowedSubCommands for specific main commands.
+	// It is populated when combining executer types with CombineExecuterTypes.
+	CommandSubCommands map[string][]string
+
+	// ValidateCommand, when set, runs additional checks on the main command
+	// after the allowlists were verified.
+	ValidateCommand func(Command) error
+
+	// Preflight, when set, checks whether the main command is likely to succeed before
+	// it is confirmed and returns a warning when it is not.
+	Preflight func(context.Context, Command) (string, error)
+
+	// RewriteCommand, when set, rewrites the main command right before it is executed,
+	// for example to pin it to a cluster.
+	RewriteCommand func(Command) Command
+
+	// Env, when set, adds environment variables (KEY=value) to the commands, for example
+	// credentials that must not appear on the command line.
+	Env []string
+
+	// Uncached, when set, runs every command instead of answering repeated commands from
+	// the cache, for commands that change state.
+	Uncached bool
+
+	// Shell runs the commands, the zero value uses the shell of the current platform.
+	Shell Shell
+}
+
+// defaultPipedCommands are the text processing commands allowed after a pipe.
+var defaultPipedCommands = []string{
+	"grep",
+	"awk",
+	"sort",
+	"uniq",
+	"head",
+	"tail",
+	"cut",
+}
+
+var (
+	// KubernetesExecuterType represents the type of the terminal executer for kubectl commands.
+	KubernetesExecuterType = TerminalExecuterType{
+		AllowedCommands: []string{"kubectl"},
+		AllowedSubCommands: []string{
+			"get",
+			"describe",
+			"logs",
+			"top",
+			"explain",
+		},
+		AllowedPipedCommands: append(slices.Clone(defaultPipedCommands), structuredPipedCommands...),
+		ValidateCommand:      denyKubectlResources(DefaultDeniedResources),
+		Preflight:            preflightKubectlCommand,
+	}
+
+	// HelmExecuterType represents the type of the terminal executer for read-only helm commands.
+	HelmExecuterType = TerminalExecuterType{
+		AllowedCommands: []string{"helm"},
+		AllowedSubCommands: []string{
+			"list",
+			"status",
+			"get",
+			"history",
+			"show",
+		},
+		AllowedPipedCommands: defaultPipedCommands,
+	}
+
+	// AWSExecuterType represents the type of the terminal executer for read-only aws CLI commands.
+	AWSExecuterType = TerminalExecuterType{
+		AllowedCommands:      []string{"aws"},
+		AllowedPipedCommands: defaultPipedCommands,
+		ValidateCommand:      validateAWSCommand,
+	}
+
+	// LinuxExecuterType represents the type of the terminal executer for read-only host commands.
+	LinuxExecuterType = TerminalExecuterType{
+		AllowedCommands: []string{
+			"systemctl",
+			"journalctl",
+			"df",
+			"free",
+			"ps",
+			"ss",
+			"dmesg",
+			"top",
+			"uptime",
+			"uname",
+			"lsblk",
+		},
+		CommandSubCommands: map[string][]string{
+			"systemctl": {
+				"status",
+				"show",
+				"cat",
+				"list-units",
+				"list-unit-files",
+				"list-timers",
+				"list-dependencies",
+				"is-active",
+				"is-enabled",
+				"is-failed",
+			},
+		},
+		AllowedPipedCommands: defaultPipedCommands,
+		ValidateCommand:      validateLinuxCommand,
+		Shell:                ShellPOSIX,
+	}
+
+	// GitExecuterType represents the type of the terminal executer for read-only git commands.
+	GitExecuterType = TerminalExecuterType{
+		AllowedCommands: []string{"git"},
+		AllowedSubCommands: []string{
+			"log",
+			"diff",
+			"show",
+			"blame",
+			"bisect",
+		},
+		AllowedPipedCommands: defaultPipedCommands,
+		ValidateCommand:      validateGitCommand,
+	}
+
+	// GitHubActionsExecuterType represents the type of the terminal executer for read-only
+	// gh commands on workflow runs.
+	GitHubActionsExecuterType = TerminalExecuterType{
+		AllowedCommands:      []string{"gh"},
+		AllowedSubCommands:   []string{"run", "workflow"},
+		AllowedPipedCommands: defaultPipedCommands,
+		ValidateCommand:      validateGHCommand,
+	}
+
+	// PromtoolExecuterType represents the type of the terminal executer for promtool checks
+	// and queries.
+	PromtoolExecuterType = TerminalExecuterType{
+		AllowedCommands:      []string{"promtool"},
+		AllowedSubCommands:   []string{"check", "query", "test"},
+		AllowedPipedCommands: defaultPipedCommands,
+		ValidateCommand:      validatePromtoolCommand,
+	}
+
+	// PostgresExecuterType represents the type of the terminal executer for read-only psql queries.
+	PostgresExecuterType = TerminalExecuterType{
+		AllowedCommands:      []string{"psql"},
+		AllowedPipedCommands: defaultPipedCommands,
+		ValidateCommand:      validatePsqlCommand,
+	}
+)
+
+// awsAllowedOperationPrefixes are the read-only aws CLI operation verbs.
+var awsAllowedOperationPrefixes = []string{"describe-", "get-", "list-"}
+
+// awsDeniedOperations are read-only operations that expose credentials or secret values,
+// or write the object they read to a local file.
+var awsDeniedOperations = []string{
+	"secretsmanager get-secret-value",
+	"ssm get-parameter",
+	"ssm get-parameters",
+	"ssm get-parameters-by-path",
+	"ssm get-parameter-history",
+	"lambda get-function",
+	"lambda get-function-configuration",
+	"ec2 get-password-data",
+	"sts get-session-token",
+	"sts get-federation-token",
+	"ecr get-login-password",
+	"ecr get-authorization-token",
+	"ecr-public get-login-password",
+	"ecr-public get-authorization-token",
+	"eks get-token",
+	"codeartifact get-authorization-token",
+	"iam get-credential-report",
+	"s3api get-object",
+	"s3api get-object-torrent",
+	"sso get-role-credentials",
+	"cognito-identity get-credentials-for-identity",
+	"lightsail get-instance-access-details",
+}
+
+// validateAWSCommand checks that the command has the form `aws <service> <operation>`
+// where the operation is a read-only verb.
+func validateAWSCommand(cmd Command) error {
+	if len(cmd.Parts) < 3 {
+		return fmt.Errorf("%w: expected `aws <service> <operation> [options]`", ErrInvalidMainCommand)
+	}
+
+	// the shell removes quotes before aws reads the words, so 'ssm' is the ssm service
+	service, operation := unquote(cmd.Parts[1]), unquote(cmd.Parts[2])
+	if strings.HasPrefix(service, "-") || strings.HasPrefix(operation, "-") {
+		return fmt.Errorf("%w: expected `aws <service> <operation> [options]`", ErrInvalidMainCommand)
+	}
+
+	allowed := false
+	for _, prefix := range awsAllowedOperationPrefixes {
+		if strings.HasPrefix(operation, prefix) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s %s", ErrOperationNotAllowed, service, operation)
+	}
+
+	if slices.Contains(awsDeniedOperations, service+" "+operation) {
+		return fmt.Errorf("%w: %s %s", ErrOperationNotAllowed, service, operation)
+	}
+
+	return nil
+}
+
+// CombineExecuterTypes merges several executer types into one, so a single session can
+// mix their commands. Each main command keeps the sub commands and validation of the
+// type that defined it.
+func CombineExecuterTypes(types ...TerminalExecuterType) TerminalExecuterType {
+	combined := TerminalExecuterType{
+		CommandSubCommands: make(map[string][]string),
+	}
+	validators := make(map[string]func(Command) error)
+	preflights := make(map[string]func(context.Context, Command) (string, error))
+
+	for _, t := range types {
+		for _, command := range t.AllowedCommands {
+			if slices.Contains(combined.AllowedCommands, command) {
+				continue
+			}
+			combined.AllowedCommands = append(combined.AllowedCommands, command)
+			combined.CommandSubCommands[command] = t.subCommandsFor(command)
+			if t.ValidateCommand != nil {
+				validators[command] = t.ValidateCommand
+			}
+			if t.Preflight != nil {
+				preflights[command] = t.Preflight
+			}
+		}
+		for _, piped := range t.AllowedPipedCommands {
+			if !slices.Contains(combined.AllowedPipedCommands, piped) {
+				combined.AllowedPipedCommands = append(combined.AllowedPipedCommands, piped)
+			}
+		}
+	}
+
+	if len(validators) > 0 {
+		combined.ValidateCommand = func(cmd Command) error {
+			if validate, ok := validators[cmd.Parts[0]]; ok {
+				return validate(cmd)
+			}
+			return nil
+		}
+	}
+
+	if len(preflights) > 0 {
+		combined.Preflight = func(ctx context.Context, cmd Command) (string, error) {
+			if preflight, ok := preflights[cmd.Parts[0]]; ok {
+				return preflight(ctx, cmd)
+			}
+			return "", nil
+		}
+	}
+
+	return combined
+}
+
+// subCommandsFor returns the sub commands allowed for the given main command.
+func (t TerminalExecuterType) subCommandsFor(command string) []string {
+	if subCommands, ok := t.CommandSubCommands[command]; ok {
+		return subCommands
+	}
+	return t.AllowedSubCommands
+}
@@ -0,0 +1,315 @@
+package executer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAWSExecuterType_Validate(t *testing.T) {
+	te := NewTerminalExecuter(AWSExecuterType)
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"Describe operation", "aws ec2 describe-instances --region us-east-1", nil},
+		{"List operation", "aws eks list-clusters", nil},
+		{"Get operation with pipe", "aws iam get-role --role-name test | grep Arn", nil},
+		{"Missing operation", "aws ec2", ErrInvalidMainCommand},
+		{"Global option first", "aws --region us-east-1 ec2 describe-instances", ErrInvalidMainCommand},
+		{"Mutating operation", "aws ec2 terminate-instances --instance-ids i-123", ErrOperationNotAllowed},
+		{"Secret value", "aws secretsmanager get-secret-value --secret-id db", ErrOperationNotAllowed},
+		{"Parameter history", "aws ssm get-parameter-history --name /db/password --with-decryption", ErrOperationNotAllowed},
+		{"Lambda environment", "aws lambda get-function-configuration --function-name api", ErrOperationNotAllowed},
+		{"Windows password", "aws ec2 get-password-data --instance-id i-0abc", ErrOperationNotAllowed},
+		{"Object to a local file", "aws s3api get-object --bucket logs --key app.log /tmp/app.log", ErrOperationNotAllowed},
+		{"Object tags", "aws s3api get-object-tagging --bucket logs --key app.log", nil},
+		{"Login password", "aws ecr get-login-password", ErrOperationNotAllowed},
+		{"Quoted service", "aws 'ssm' get-parameter --name x --with-decryption", ErrOperationNotAllowed},
+		{"Quoted operation", `aws secretsmanager get-secret-value"" --secret-id x`, ErrOperationNotAllowed},
+		{"Quoted global option", `aws "--region" us-east-1 ec2 describe-instances`, ErrInvalidMainCommand},
+		{"Role credentials", "aws sso get-role-credentials --role-name admin --account-id 123 --access-token x", ErrOperationNotAllowed},
+		{"Identity credentials", "aws cognito-identity get-credentials-for-identity --identity-id x", ErrOperationNotAllowed},
+		{"Instance access", "aws lightsail get-instance-access-details --instance-name web", ErrOperationNotAllowed},
+		{"Other main command", "kubectl get pods", ErrCommandNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := te.Validate(tt.command)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLinuxExecuterType_Validate(t *testing.T) {
+	te := NewTerminalExecuter(LinuxExecuterType)
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"Service status", "systemctl status nginx --no-pager", nil},
+		{"Failed units", "systemctl list-units --failed --no-pager", nil},
+		{"Restart", "systemctl restart nginx", ErrSubCommandNotAllowed},
+		{"Journal", `journalctl -u nginx --since "4 hours ago" -n 200 --no-pager`, nil},
+		{"Journal unit in a cluster", "journalctl -xeu nginx", nil},
+		{"Journal unit named like a flag", "journalctl -ufoo", nil},
+		{"Follow journal", "journalctl -fu nginx", ErrOperationNotAllowed},
+		{"Vacuum journal", "journalctl --vacuum-time=2d", ErrOperationNotAllowed},
+		{"Kernel messages", "dmesg -T --level=err,warn | tail -50", nil},
+		{"Clear kernel ring buffer", "dmesg -c", ErrOperationNotAllowed},
+		{"Abbreviated read clear", "dmesg --read-c", ErrOperationNotAllowed},
+		{"Abbreviated vacuum", "journalctl --vacuum-t=1s", ErrOperationNotAllowed},
+		{"Abbreviated follow", `journalctl --since "-1h" --fol`, ErrOperationNotAllowed},
+		{"Quoted clear flag", "dmesg '-c'", ErrOperationNotAllowed},
+		{"Quoted vacuum", `journalctl "--vacuum-time=1s"`, ErrOperationNotAllowed},
+		{"Quoted follow", `journalctl "--follow"`, ErrOperationNotAllowed},
+		{"Abbreviated kill", "ss --ki -t", ErrOperationNotAllowed},
+		{"Disk usage", "df -h", nil},
+		{"Memory", "free -m", nil},
+		{"Memory loop", "free -m -s 1", ErrOperationNotAllowed},
+		{"Processes", "ps aux --sort=-%mem | head -20", nil},
+		{"Listeners", "ss -tlnp", nil},
+		{"Kill sockets", "ss -K dst 10.0.0.1", ErrOperationNotAllowed},
+		{"Top snapshot", "top -b -n1", nil},
+		{"Top snapshot cluster", "top -bn 1 -o %MEM", nil},
+		{"Interactive top", "top", ErrOperationNotAllowed},
+		{"Endless top", "top -b", ErrOperationNotAllowed},
+		{"Top iterations", "top -b -n5", ErrOperationNotAllowed},
+		{"Quoted top iterations", `top "-b" -n "5"`, ErrOperationNotAllowed},
+		{"Quoted top snapshot", `top '-bn1'`, nil},
+		{"Other main command", "rm -rf /tmp/x", ErrCommandNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := te.Validate(tt.command)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGitExecuterType_Validate(t *testing.T) {
+	te := NewTerminalExecuter(GitExecuterType)
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"Log since Friday", `git log --since="last friday" --until=now --oneline --first-parent main`, nil},
+		{"Diff between commits", "git diff --stat abc123..def456 -- deploy/", nil},
+		{"Show commit", "git show --stat HEAD~2", nil},
+		{"Blame lines", "git blame -L 10,40 charts/web/values.yaml", nil},
+		{"Log with pipe", "git log -p --since=2.days -- config/ | grep -n replicas", nil},
+		{"Bisect view", "git bisect view --oneline", nil},
+		{"Bisect log", "git bisect log", nil},
+		{"Bisect view without options", "git bisect view", ErrOperationNotAllowed},
+		{"Bisect view running a program", "git bisect view rm -rf .", ErrOperationNotAllowed},
+		{"Bisect start", "git bisect start HEAD v1.2.0", ErrOperationNotAllowed},
+		{"Bisect reset", "git bisect reset", ErrOperationNotAllowed},
+		{"Diff to a file", "git diff --output=/tmp/x HEAD~1", ErrOperationNotAllowed},
+		{"External diff", "git log -p --ext-diff", ErrOperationNotAllowed},
+		{"Diff outside the repository", "git diff --no-index /etc/passwd /dev/null", ErrOperationNotAllowed},
+		{"Abbreviated contents", "git blame --conten /etc/passwd file", ErrOperationNotAllowed},
+		{"Abbreviated output", "git diff --out=/tmp/x HEAD~1", ErrOperationNotAllowed},
+		{"Abbreviated external diff", "git log -p --ext", ErrOperationNotAllowed},
+		{"Abbreviated no index", "git diff --no-i /etc/passwd /dev/null", ErrOperationNotAllowed},
+		{"Checkout", "git checkout main", ErrSubCommandNotAllowed},
+		{"Reset", "git reset --hard HEAD~1", ErrSubCommandNotAllowed},
+		{"Global option first", "git -C /tmp log", ErrSubCommandNotAllowed},
+		{"Other main command", "kubectl get pods", ErrCommandNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := te.Validate(tt.command)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPostgresExecuterType_Validate(t *testing.T) {
+	te := NewTerminalExecuter(PostgresExecuterType)
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"Select", `psql -c 'SELECT pid, state, query FROM pg_stat_activity WHERE state <> ''idle'' LIMIT 20'`, nil},
+		{"Blocking sessions", `psql -x -c "SELECT pid, pg_blocking_pids(pid) FROM pg_stat_activity WHERE cardinality(pg_blocking_pids(pid)) > 0"`, nil},
+		{"With and explain", `psql -c 'WITH t AS (SELECT 1) SELECT * FROM t; EXPLAIN SELECT * FROM orders WHERE id = 1'`, nil},
+		{"Show and pipe", "psql -At -c 'SHOW max_connections' | head -1", nil},
+		{"Meta-commands", `psql -c '\dt+ public.*'`, nil},
+		{"System objects", `psql -c '\dS+ pg_locks'`, nil},
+		{"Connection info", `psql --command='\conninfo'`, nil},
+		{"Missing query", "psql -x", ErrInvalidMainCommand},
+		{"Connection flags", "psql -h db.example.com -c 'SELECT 1'", ErrOperationNotAllowed},
+		{"Positional database", "psql postgres://other/db -c 'SELECT 1'", ErrOperationNotAllowed},
+		{"Script file", "psql -f drop.sql", ErrOperationNotAllowed},
+		{"Delete", "psql -c 'DELETE FROM orders'", ErrOperationNotAllowed},
+		{"Write after select", "psql -c 'SELECT 1; UPDATE orders SET paid = true'", ErrOperationNotAllowed},
+		{"Set read write", "psql -c 'SET default_transaction_read_only = off'", ErrOperationNotAllowed},
+		{"Read write transaction", "psql -c 'BEGIN READ WRITE'", ErrOperationNotAllowed},
+		{"Set config", `psql -c 'SELECT set_config(''default_transaction_read_only'', ''off'', false)'`, ErrOperationNotAllowed},
+		{"Terminate backend", "psql -c 'SELECT pg_terminate_backend(1234)'", ErrOperationNotAllowed},
+		{"Read server file", `psql -c 'SELECT pg_read_file(''/etc/passwd'')'`, ErrOperationNotAllowed},
+		{"Copy", "psql -c 'COPY orders TO STDOUT'", ErrOperationNotAllowed},
+		{"Shell meta-command", `psql -c '\! id'`, ErrOperationNotAllowed},
+		{"Output meta-command", `psql -c '\dt \o /tmp/x'`, ErrOperationNotAllowed},
+		{"Meta-command in pattern", `psql -c '\dt foo\!id'`, ErrOperationNotAllowed},
+		{"Chained meta-command", `psql -c '\dt\\! id'`, ErrOperationNotAllowed},
+		{"Backquote in meta-command", "psql -c '\\dt `id`'", ErrOperationNotAllowed},
+		{"Backquote in pattern", "psql -c '\\d foo`id`'", ErrOperationNotAllowed},
+		{"Variable in double quotes", `psql -c "SELECT '$PGPASSWORD'"`, ErrOperationNotAllowed},
+		{"Other main command", "pg_dump mydb", ErrCommandNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := te.Validate(tt.command)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSystemdExecuterType_Validate(t *testing.T) {
+	te := NewTerminalExecuter(SystemdExecuterType)
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"Failed units", "systemctl list-units --state=failed --no-pager", nil},
+		{"Unit status", "systemctl status nginx.service --no-pager", nil},
+		{"Unit properties", "systemctl show nginx -p ActiveState,Result,NRestarts", nil},
+		{"Restart", "systemctl restart nginx", ErrSubCommandNotAllowed},
+		{"Journal with since", `journalctl -u nginx --since "-2h" -p warning --no-pager | tail -50`, nil},
+		{"Journal without since", "journalctl -u nginx -n 200", ErrOperationNotAllowed},
+		{"Journal too far back", `journalctl -u nginx --since "7 days ago"`, ErrOperationNotAllowed},
+		{"Follow journal", `journalctl -f -u nginx --since "-1h"`, ErrOperationNotAllowed},
+		{"Sessions", "loginctl list-sessions --no-legend", nil},
+		{"User status", "loginctl user-status deploy", nil},
+		{"Terminate session", "loginctl terminate-session 3", ErrSubCommandNotAllowed},
+		{"Other main command", "df -h", ErrCommandNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := te.Validate(tt.command)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGitHubActionsExecuterType_Validate(t *testing.T) {
+	te := NewTerminalExecuter(CombineExecuterTypes(GitHubActionsExecuterType, GitExecuterType))
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"Failed runs", "gh run list --status failure --limit 20 --json databaseId,headSha,workflowName,conclusion", nil},
+		{"Run logs", "gh run view 1234567 --log-failed | tail -100", nil},
+		{"Run jobs", "gh run view 1234567 --json jobs --jq '.jobs[] | select(.conclusion==\"failure\") | .name'", nil},
+		{"Workflow", "gh workflow view ci.yml --yaml -R acme/shop", nil},
+		{"Workflows", "gh workflow list", nil},
+		{"Commits of a run", "git log --oneline abc123..def456", nil},
+		{"Missing action", "gh run", ErrInvalidMainCommand},
+		{"Flag before action", "gh run --repo acme/shop list", ErrInvalidMainCommand},
+		{"Rerun", "gh run rerun 1234567 --failed", ErrOperationNotAllowed},
+		{"Cancel", "gh run cancel 1234567", ErrOperationNotAllowed},
+		{"Download artifacts", "gh run download 1234567", ErrOperationNotAllowed},
+		{"Watch", "gh run watch 1234567", ErrOperationNotAllowed},
+		{"Dispatch workflow", "gh workflow run deploy.yml", ErrOperationNotAllowed},
+		{"Disable workflow", "gh workflow disable ci.yml", ErrOperationNotAllowed},
+		{"Open in browser", "gh run view 1234567 --web", ErrOperationNotAllowed},
+		{"API", "gh api repos/acme/shop/actions/runs", ErrSubCommandNotAllowed},
+		{"Secrets", "gh secret list", ErrSubCommandNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := te.Validate(tt.command)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPromtoolExecuterType_Validate(t *testing.T) {
+	te := NewTerminalExecuter(PromtoolExecuterType)
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"Instant query", "promtool query instant http://prometheus:9090 'up{job=\"api\"} == 0'", nil},
+		{"Range query", "promtool query range --start 2024-06-01T10:00:00Z http://prometheus:9090 'rate(http_requests_total[5m])' | tail -20", nil},
+		{"Series", "promtool query series http://prometheus:9090 --match 'up'", nil},
+		{"Check rules", "promtool check rules alerts.yaml", nil},
+		{"Server health", "promtool check healthy --url http://prometheus:9090", nil},
+		{"Missing action", "promtool query", ErrInvalidMainCommand},
+		{"Flag before action", "promtool check --lint none rules alerts.yaml", ErrInvalidMainCommand},
+		{"Analyze", "promtool query analyze --server http://prometheus:9090", ErrOperationNotAllowed},
+		{"Push metrics", "promtool push metrics http://pushgateway:9091", ErrSubCommandNotAllowed},
+		{"TSDB", "promtool tsdb create-blocks-from openmetrics data.om", ErrSubCommandNotAllowed},
+		{"Debug", "promtool debug all http://prometheus:9090", ErrSubCommandNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := te.Validate(tt.command)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCombineExecuterTypes(t *testing.T) {
+	combined := CombineExecuterTypes(HelmExecuterType, KubernetesExecuterType, AWSExecuterType)
+	te := NewTerminalExecuter(combined)
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr error
+	}{
+		{"Helm command", "helm history my-release -n apps", nil},
+		{"Kubectl command", "kubectl get pods -A | grep my-release", nil},
+		{"AWS command keeps its validation", "aws eks list-clusters", nil},
+		{"Helm sub command not allowed for kubectl", "kubectl history my-release", ErrSubCommandNotAllowed},
+		{"Kubectl sub command not allowed for helm", "helm describe my-release", ErrSubCommandNotAllowed},
+		{"Helm mutation", "helm upgrade my-release chart", ErrSubCommandNotAllowed},
+		{"AWS mutation", "aws ec2 terminate-instances", ErrOperationNotAllowed},
+		{"Unknown command", "terraform plan", ErrCommandNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := te.Validate(tt.command)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,432 @@
+// Package headless answers a single question without the chat UI, for scripts and bots.
+package headless
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/eliran89c/klama/internal/llm"
+	"github.com/eliran89c/klama/internal/logger"
+	"github.com/eliran89c/klama/internal/policy"
+	"github.com/eliran89c/klama/internal/redact"
+)
+
+// Timeouts of runners that set none
+const (
+	defaultAgentTimeout = 90 * time.Second
+	defaultExecTimeout  = 30 * time.Second
+)
+
+// Agent answers prompts, suggesting commands to run along the way.
+type Agent interface {
+	Iterate(context.Context, string) (agent.AgentResponse, error)
+}
+
+// Executer validates and runs suggested commands.
+type Executer interface {
+	Run(context.Context, string) executer.ExecuterResponse
+	Validate(string) error
+}
+
+// MutationChecker is implemented by executers that can run commands which change the
+// environment. Mutations need a confirmation, so they are never run headless.
+type MutationChecker interface {
+	Mutation(string) (executer.MutationTarget, bool)
+}
+
+// InteractiveChecker is implemented by executers with interactive commands, such as
+// kubectl exec -it, which need a terminal, so they are never run headless.
+type InteractiveChecker interface {
+	Interactive(string) bool
+}
+
+// PreflightChecker is implemented by executers that can check whether a command is
+// permitted before it runs.
+type PreflightChecker interface {
+	Preflight(context.Context, string) (string, error)
+}
+
+// ToolSelector is implemented by executers of multi-tool sessions, which run every
+// command with the tool the agent named.
+type ToolSelector interface {
+	Tool(string) (executer.CachingExecuter, error)
+}
+
+// PolicyEvaluator decides whether a suggested command may run.
+type PolicyEvaluator interface {
+	Evaluate(string) (policy.Decision, error)
+}
+
+// Runner answers a question by letting the agent run commands until it has an answer.
+// Nobody can confirm commands, so commands that need a confirmation in the chat UI
+// are refused and the agent is asked to continue without them.
+type Runner struct {
+	Agent    Agent
+	Executer Executer
+
+	MaxCommands int // maximum number of commands the agent may suggest, refused ones included
+
+	Redactor        *redact.Redactor         // scrubs credentials from command output, nil disables redaction
+	OutputProcessor executer.OutputProcessor // shrinks large command outputs before they are sent to the agent
+	Policy          PolicyEvaluator          // decides whether suggested commands may run, nil disables it
+
+	Hints func(ctx context.Context, question string) string // notes the agent gets with the question, nil adds none
+
+	// ProtectedContext, when set, returns the protected context a command runs against,
+	// empty for none. Commands against one always need a confirmation, so none are run.
+	ProtectedContext func(command string) string
+
+	// ConfirmHighRisk refuses commands the agent rates as high risk, which need a
+	// confirmation keyword.
+	ConfirmHighRisk bool
+
+	Memory Memory // records the facts the agent learns, nil disables memory
+
+	Workers int // plan steps run at a time, 0 uses executer.DefaultWorkers
+
+	AgentTimeout time.Duration // timeout of each agent request, 0 uses 90 seconds
+	ExecTimeout  time.Duration // timeout of each command, 0 uses 30 seconds
+
+	DryRun bool // refuses every command, so none are run
+
+	Logger *slog.Logger // receives the records of the run, nil discards them
+}
+
+// log returns the logger of the runner.
+func (r *Runner) log() *slog.Logger {
+	return logger.Or(r.Logger)
+}
+
+// Memory records the durable facts the agent learns for later sessions.
+type Memory interface {
+	Remember(...string) ([]string, error)
+}
+
+// Command is a command the agent suggested, with its output or the reason it did not run.
+type Command struct {
+	Command    string `json:"command"`
+	Reason     string `json:"reason,omitempty"`
+	Tool       string `json:"tool,omitempty"` // tool of the command in multi-tool sessions
+	Output     string `json:"output,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+	ExitCode   int    `json:"exit_code,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Refused    string `json:"refused,omitempty"` // why the command was not run
+	Redacted   int    `json:"redacted,omitempty"`
+	Cached     bool   `json:"cached,omitempty"` // answered from the cache, the command did not run again
+}
+
+// Result is the outcome of a headless run.
+type Result struct {
+	Question string    `json:"question"`
+	Answer   string    `json:"answer"`
+	Commands []Command `json:"commands"`
+	Usage    llm.Usage `json:"usage"`
+	Cost     float64   `json:"cost_usd"`
+	Error    string    `json:"error,omitempty"` // why the run stopped before the agent answered
+}
+
+// ErrNoAnswer is returned when the agent keeps suggesting commands after the command
+// limit was reached.
+var ErrNoAnswer = errors.New("the agent did not answer within the command limit")
+
+// Run asks the question and returns the answer with every command the agent suggested.
+// The partial result is returned with an error.
+func (r *Runner) Run(ctx context.Context, question string) (*Result, error) {
+	result := &Result{Question: question, Commands: []Command{}}
+	if err := r.run(ctx, result); err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	return result, nil
+}
+
+func (r *Runner) run(ctx context.Context, result *Result) error {
+	prompt := result.Question
+	if r.Hints != nil {
+		hintCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		prompt = r.Hints(hintCtx, prompt) + prompt
+		cancel()
+	}
+	suggested := 0
+	limitReached := false
+	for {
+		response, err := r.iterate(ctx, prompt, result)
+		if err != nil {
+			return err
+		}
+		r.rememberFacts(response.Remember)
+
+		steps := response.Plan
+		if response.RunCommand != "" {
+			steps = []agent.PlanStep{{Command: response.RunCommand, Reason: response.Reason, Tool: response.Tool}}
+		}
+		if len(steps) == 0 {
+			result.Answer = response.Answer
+			return nil
+		}
+
+		if suggested >= r.MaxCommands {
+			for _, step := range steps {
+				result.Commands = append(result.Commands, Command{Command: step.Command, Reason: step.Reason, Tool: step.Tool, Refused: "command limit reached"})
+			}
+			if limitReached {
+				return ErrNoAnswer
+			}
+			// give the agent one chance to answer with what it found
+			limitReached = true
+			prompt = fmt.Sprintf("The limit of %d commands was reached, do not suggest more commands. Answer with what you found so far.", r.MaxCommands)
+			continue
+		}
+
+		if len(steps) == 1 {
+			suggested++
+			var command Command
+			if r.ConfirmHighRisk && response.RiskLevel == agent.LevelHigh {
+				command, prompt = r.refuse(Command{Command: steps[0].Command, Tool: steps[0].Tool}, "the command is rated high risk and needs a confirmation")
+			} else {
+				command, prompt = r.runCommand(ctx, steps[0].Command, steps[0].Tool)
+			}
+			command.Reason = steps[0].Reason
+			result.Commands = append(result.Commands, command)
+			continue
+		}
+
+		var commands []Command
+		commands, prompt = r.runPlan(ctx, steps, r.MaxCommands-suggested)
+		suggested += len(commands)
+		result.Commands = append(result.Commands, commands...)
+	}
+}
+
+// runPlan runs the steps of a plan concurrently, up to limit commands. It returns the
+// commands that were tried and the prompt that tells the agent the outcome of every
+// step, in step order.
+func (r *Runner) runPlan(ctx context.Context, steps []agent.PlanStep, limit int) ([]Command, string) {
+	type outcome struct {
+		command  Command
+		response executer.ExecuterResponse
+		prompt   string
+	}
+
+	jobs := make([]func(context.Context) outcome, 0, len(steps))
+	for _, step := range steps[:min(limit, len(steps))] {
+		jobs = append(jobs, func(ctx context.Context) outcome {
+			command, response, prompt := r.tryCommand(ctx, step.Command, step.Tool)
+			command.Reason = step.Reason
+			return outcome{command: command, response: response, prompt: prompt}
+		})
+	}
+	outcomes := executer.RunParallel(ctx, r.Workers, 0, jobs)
+
+	commands := make([]Command, 0, len(outcomes))
+	sections := make([]string, 0, len(steps))
+	for i, step := range steps {
+		header := fmt.Sprintf("Step %d `%v`:\n", i+1, step.Command)
+		if i >= len(outcomes) {
+			sections = append(sections, header+"Not run, the command limit was reached.")
+			continue
+		}
+
+		// the output processor may use a model, so outputs are processed one at a time
+		command, prompt := outcomes[i].command, outcomes[i].prompt
+		if command.Refused == "" {
+			prompt = r.report(ctx, &command, outcomes[i].response)
+		}
+		commands = append(commands, command)
+		sections = append(sections, header+prompt)
+	}
+
+	return commands, "Plan results:\n" + strings.Join(sections, "\n\n")
+}
+
+// RunCommand runs a single command when it needs no confirmation, and returns its
+// redacted output or the reason it was refused.
+func (r *Runner) RunCommand(ctx context.Context, command string) Command {
+	result, _ := r.runCommand(ctx, command, "")
+	return result
+}
+
+// runCommand runs the command with the tool when it may run, and returns the prompt
+// that tells the agent the outcome.
+func (r *Runner) runCommand(ctx context.Context, command, tool string) (Command, string) {
+	result, response, prompt := r.tryCommand(ctx, command, tool)
+	if result.Refused != "" {
+		return result, prompt
+	}
+	return result, r.report(ctx, &result, response)
+}
+
+// tryCommand runs the command with the tool when it may run and records its redacted
+// output. It returns the prompt that tells the agent why a refused command did not run.
+func (r *Runner) tryCommand(ctx context.Context, command, tool string) (Command, executer.ExecuterResponse, string) {
+	if _, ok := r.Executer.(ToolSelector); !ok {
+		tool = ""
+	}
+	result := Command{Command: command, Tool: tool}
+
+	exec, err := r.executerFor(tool)
+	refused := ""
+	if err != nil {
+		refused = fmt.Sprintf("the command is invalid: %v", err)
+	} else {
+		refused = r.check(ctx, exec, command)
+	}
+	if refused != "" {
+		result, prompt := r.refuse(result, refused)
+		return result, executer.ExecuterResponse{}, prompt
+	}
+
+	return result, r.execute(ctx, exec, &result), ""
+}
+
+// refuse records why the command was not run, and returns the prompt that tells the agent.
+func (r *Runner) refuse(command Command, refused string) (Command, string) {
+	r.log().Info("Refused command", "command", command.Command, "reason", refused)
+	command.Refused = refused
+	return command, fmt.Sprintf("The suggested command was not run: %v\nSuggest a different command, or answer with what you found so far.", refused)
+}
+
+// executerFor returns the executer of the tool in multi-tool sessions, and the runner
+// executer otherwise.
+func (r *Runner) executerFor(tool string) (Executer, error) {
+	selector, ok := r.Executer.(ToolSelector)
+	if !ok || tool == "" {
+		return r.Executer, nil
+	}
+	return selector.Tool(tool)
+}
+
+// rememberFacts records the facts the agent learned, without the credentials they may
+// contain.
+func (r *Runner) rememberFacts(facts []string) {
+	if r.Memory == nil || len(facts) == 0 {
+		return
+	}
+
+	redacted := make([]string, 0, len(facts))
+	for _, fact := range facts {
+		fact, _ = r.Redactor.Redact(fact)
+		redacted = append(redacted, fact)
+	}
+	if _, err := r.Memory.Remember(redacted...); err != nil {
+		r.log().Warn("Failed to remember facts", "error", err)
+	}
+}
+
+// iterate sends the prompt to the agent and adds the usage of the iteration to the result.
+func (r *Runner) iterate(ctx context.Context, prompt string, result *Result) (agent.AgentResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, orDefault(r.AgentTimeout, defaultAgentTimeout))
+	defer cancel()
+
+	response, err := r.Agent.Iterate(ctx, prompt)
+	result.Usage = result.Usage.Add(response.Usage)
+	result.Cost += response.Cost
+	return response, err
+}
+
+// check returns why the command may not run, or an empty string when it may.
+func (r *Runner) check(ctx context.Context, exec Executer, command string) string {
+	if err := exec.Validate(command); err != nil {
+		return fmt.Sprintf("the command is invalid: %v", err)
+	}
+
+	if checker, ok := exec.(MutationChecker); ok {
+		if target, mutation := checker.Mutation(command); mutation {
+			return fmt.Sprintf("the command changes %v and mutations need a confirmation", target)
+		}
+	}
+
+	if checker, ok := exec.(InteractiveChecker); ok && checker.Interactive(command) {
+		return "the command is interactive and needs a terminal"
+	}
+
+	if r.Policy != nil {
+		decision, err := r.Policy.Evaluate(command)
+		switch {
+		case err != nil:
+			// a broken rule must not let commands through
+			return fmt.Sprintf("blocked because the policy could not be evaluated: %v", err)
+		case decision.Denied(), decision.RequiresConfirmation():
+			return decision.String()
+		}
+	}
+
+	if r.ProtectedContext != nil {
+		if name := r.ProtectedContext(command); name != "" {
+			return fmt.Sprintf("context %s is protected and commands against it need a confirmation", name)
+		}
+	}
+
+	if r.DryRun {
+		return "dry run mode, commands are not run"
+	}
+
+	if checker, ok := exec.(PreflightChecker); ok {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		warning, err := checker.Preflight(ctx, command)
+		if err != nil {
+			r.log().Warn("Preflight check failed", "command", command, "error", err)
+		}
+		if warning != "" {
+			return fmt.Sprintf("the permission check failed: %v", warning)
+		}
+	}
+
+	return ""
+}
+
+// execute runs the command and records its redacted output.
+func (r *Runner) execute(ctx context.Context, exec Executer, command *Command) executer.ExecuterResponse {
+	runCtx, cancel := context.WithTimeout(ctx, orDefault(r.ExecTimeout, defaultExecTimeout))
+	response := exec.Run(runCtx, command.Command)
+	cancel()
+
+	output, redacted := r.Redactor.Redact(response.Stdout)
+	stderr, redactedStderr := r.Redactor.Redact(response.Stderr)
+	command.Output = output
+	command.Stderr = stderr
+	command.ExitCode = response.ExitCode
+	command.DurationMS = response.Duration.Milliseconds()
+	command.Redacted = redacted + redactedStderr
+	command.Cached = response.Cached
+	if response.Error != nil {
+		command.Error = response.Error.Error()
+	}
+	return response
+}
+
+// report shrinks the stdout of the command with the output processor and returns the
+// prompt for the agent.
+func (r *Runner) report(ctx context.Context, command *Command, response executer.ExecuterResponse) string {
+	output := command.Output
+	if r.OutputProcessor != nil {
+		processCtx, cancel := context.WithTimeout(ctx, orDefault(r.AgentTimeout, defaultAgentTimeout))
+		processed, err := r.OutputProcessor.Process(processCtx, command.Command, output)
+		cancel()
+		if err != nil {
+			r.log().Warn("Failed to process command output", "command", command.Command, "error", err)
+		} else {
+			output = processed
+		}
+	}
+
+	return executer.FormatOutput(response, output, command.Stderr)
+}
+
+// orDefault returns the timeout, or the default when it is not set.
+func orDefault(timeout, fallback time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	return fallback
+}
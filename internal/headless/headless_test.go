@@ -0,0 +1,291 @@
+package headless
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/eliran89c/klama/internal/llm"
+	"github.com/eliran89c/klama/internal/logger"
+	"github.com/eliran89c/klama/internal/policy"
+	"github.com/eliran89c/klama/internal/redact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAgent struct {
+	mock.Mock
+}
+
+func (m *MockAgent) Iterate(ctx context.Context, input string) (agent.AgentResponse, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(agent.AgentResponse), args.Error(1)
+}
+
+type MockExecuter struct {
+	mock.Mock
+}
+
+func (m *MockExecuter) Run(ctx context.Context, command string) executer.ExecuterResponse {
+	args := m.Called(ctx, command)
+	return args.Get(0).(executer.ExecuterResponse)
+}
+
+func (m *MockExecuter) Validate(command string) error {
+	args := m.Called(command)
+	return args.Error(0)
+}
+
+func TestRunner_Run(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	usage := llm.Usage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110}
+
+	mockAgent.On("Iterate", mock.Anything, "why is my pod failing?").Return(agent.AgentResponse{RunCommand: "kubectl get pods", Reason: "list pods", Usage: usage, Cost: 0.01}, nil)
+	mockExecuter.On("Validate", "kubectl get pods").Return(nil)
+	mockExecuter.On("Run", mock.Anything, "kubectl get pods").Return(executer.ExecuterResponse{Stdout: "web CrashLoopBackOff password=hunter2"})
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return prompt == "Command output:\nweb CrashLoopBackOff password="+redact.Placeholder
+	})).Return(agent.AgentResponse{Answer: "The web pod is crash looping.", Usage: usage, Cost: 0.02}, nil)
+
+	redactor, err := redact.New(nil, false)
+	require.NoError(t, err)
+
+	runner := Runner{Agent: mockAgent, Executer: mockExecuter, MaxCommands: 5, Redactor: redactor}
+	result, err := runner.Run(context.Background(), "why is my pod failing?")
+	require.NoError(t, err)
+
+	assert.Equal(t, "why is my pod failing?", result.Question)
+	assert.Equal(t, "The web pod is crash looping.", result.Answer)
+	require.Len(t, result.Commands, 1)
+	assert.Equal(t, Command{Command: "kubectl get pods", Reason: "list pods", Output: "web CrashLoopBackOff password=" + redact.Placeholder, Redacted: 1}, result.Commands[0])
+	assert.Equal(t, 220, result.Usage.TotalTokens)
+	assert.InDelta(t, 0.03, result.Cost, 1e-9)
+	mockAgent.AssertExpectations(t)
+}
+
+func TestRunner_RunWithHints(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("Iterate", mock.Anything, "Loki labels of what the user mentioned: app=\"checkout\".\nwhy is checkout failing?").Return(agent.AgentResponse{Answer: "It is out of memory."}, nil)
+
+	runner := Runner{Agent: mockAgent, Executer: new(MockExecuter), MaxCommands: 5, Hints: func(ctx context.Context, question string) string {
+		return "Loki labels of what the user mentioned: app=\"checkout\".\n"
+	}}
+	result, err := runner.Run(context.Background(), "why is checkout failing?")
+	require.NoError(t, err)
+
+	assert.Equal(t, "why is checkout failing?", result.Question)
+	assert.Equal(t, "It is out of memory.", result.Answer)
+	mockAgent.AssertExpectations(t)
+}
+
+func TestRunner_RunRefusesCommands(t *testing.T) {
+	engine, err := policy.New([]config.PolicyRule{{Name: "no secrets", Expression: `command.contains("secret")`, Verdict: config.VerdictDeny}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		runner  Runner
+		invalid bool
+		refused string
+	}{
+		{name: "invalid", invalid: true, refused: "the command is invalid: not allowed"},
+		{name: "policy", runner: Runner{Policy: engine}, refused: "denied"},
+		{name: "protected context", runner: Runner{ProtectedContext: func(string) string { return "prod" }}, refused: "context prod is protected"},
+		{name: "dry run", runner: Runner{DryRun: true}, refused: "dry run mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAgent := new(MockAgent)
+			mockExecuter := new(MockExecuter)
+
+			mockAgent.On("Iterate", mock.Anything, "question").Return(agent.AgentResponse{RunCommand: "kubectl get secret"}, nil)
+			var validateErr error
+			if tt.invalid {
+				validateErr = errors.New("not allowed")
+			}
+			mockExecuter.On("Validate", "kubectl get secret").Return(validateErr)
+			mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool { return prompt != "question" })).Return(agent.AgentResponse{Answer: "no access"}, nil)
+
+			runner := tt.runner
+			runner.Agent, runner.Executer, runner.MaxCommands = mockAgent, mockExecuter, 5
+			result, err := runner.Run(context.Background(), "question")
+			require.NoError(t, err)
+
+			assert.Equal(t, "no access", result.Answer)
+			require.Len(t, result.Commands, 1)
+			assert.Contains(t, result.Commands[0].Refused, tt.refused)
+			mockExecuter.AssertNotCalled(t, "Run", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// mockMutationExecuter treats every command as a mutation.
+type mockMutationExecuter struct {
+	MockExecuter
+}
+
+func (m *mockMutationExecuter) Mutation(string) (executer.MutationTarget, bool) {
+	return executer.MutationTarget{Kind: "deployment", Name: "web"}, true
+}
+
+func TestRunner_RunRefusesMutations(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(mockMutationExecuter)
+
+	mockAgent.On("Iterate", mock.Anything, "question").Return(agent.AgentResponse{RunCommand: "kubectl rollout restart deployment/web"}, nil)
+	mockExecuter.On("Validate", "kubectl rollout restart deployment/web").Return(nil)
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool { return prompt != "question" })).Return(agent.AgentResponse{Answer: "restart it"}, nil)
+
+	runner := Runner{Agent: mockAgent, Executer: mockExecuter, MaxCommands: 5}
+	result, err := runner.Run(context.Background(), "question")
+	require.NoError(t, err)
+
+	require.Len(t, result.Commands, 1)
+	assert.Equal(t, "the command changes deployment/web and mutations need a confirmation", result.Commands[0].Refused)
+	mockExecuter.AssertNotCalled(t, "Run", mock.Anything, mock.Anything)
+}
+
+func TestRunner_RunRefusesHighRisk(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+
+	mockAgent.On("Iterate", mock.Anything, "question").Return(agent.AgentResponse{RunCommand: "kubectl drain node-1", RiskLevel: agent.LevelHigh}, nil)
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool { return prompt != "question" })).Return(agent.AgentResponse{Answer: "drain it"}, nil)
+
+	runner := Runner{Agent: mockAgent, Executer: mockExecuter, MaxCommands: 5, ConfirmHighRisk: true}
+	result, err := runner.Run(context.Background(), "question")
+	require.NoError(t, err)
+
+	require.Len(t, result.Commands, 1)
+	assert.Equal(t, "the command is rated high risk and needs a confirmation", result.Commands[0].Refused)
+	mockExecuter.AssertNotCalled(t, "Run", mock.Anything, mock.Anything)
+}
+
+func TestRunner_RunLogsRefusals(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("Iterate", mock.Anything, "question").Return(agent.AgentResponse{RunCommand: "kubectl get pods"}, nil)
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool { return prompt != "question" })).Return(agent.AgentResponse{Answer: "run it yourself"}, nil)
+
+	mockExecuter := new(MockExecuter)
+	mockExecuter.On("Validate", "kubectl get pods").Return(nil)
+
+	var logs bytes.Buffer
+	runner := Runner{Agent: mockAgent, Executer: mockExecuter, MaxCommands: 5, DryRun: true, Logger: logger.New(&logs, logger.Options{Format: logger.FormatJSON})}
+	_, err := runner.Run(context.Background(), "question")
+	require.NoError(t, err)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &record))
+	assert.Equal(t, "Refused command", record["msg"])
+	assert.Equal(t, "kubectl get pods", record["command"])
+	assert.Equal(t, "dry run mode, commands are not run", record["reason"])
+}
+
+func TestRunner_RunCommandLimit(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+
+	mockAgent.On("Iterate", mock.Anything, mock.Anything).Return(agent.AgentResponse{RunCommand: "kubectl get pods"}, nil)
+	mockExecuter.On("Validate", "kubectl get pods").Return(nil)
+	mockExecuter.On("Run", mock.Anything, "kubectl get pods").Return(executer.ExecuterResponse{Stdout: "pods"})
+
+	runner := Runner{Agent: mockAgent, Executer: mockExecuter, MaxCommands: 2}
+	result, err := runner.Run(context.Background(), "question")
+	assert.ErrorIs(t, err, ErrNoAnswer)
+
+	require.Len(t, result.Commands, 4)
+	assert.Empty(t, result.Commands[1].Refused)
+	assert.Equal(t, "command limit reached", result.Commands[2].Refused)
+	mockExecuter.AssertNumberOfCalls(t, "Run", 2)
+	mockAgent.AssertCalled(t, "Iterate", mock.Anything, "The limit of 2 commands was reached, do not suggest more commands. Answer with what you found so far.")
+}
+
+func TestRunner_RunAgentError(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("Iterate", mock.Anything, "question").Return(agent.AgentResponse{}, errors.New("boom"))
+
+	runner := Runner{Agent: mockAgent, Executer: new(MockExecuter)}
+	result, err := runner.Run(context.Background(), "question")
+	assert.EqualError(t, err, "boom")
+	assert.Empty(t, result.Answer)
+	assert.Equal(t, "boom", result.Error)
+}
+
+func TestRunner_RunCommand(t *testing.T) {
+	mockExecuter := new(MockExecuter)
+	mockExecuter.On("Validate", "kubectl get pods").Return(nil)
+	mockExecuter.On("Validate", "kubectl delete pod web").Return(errors.New("not allowed"))
+	mockExecuter.On("Run", mock.Anything, "kubectl get pods").Return(executer.ExecuterResponse{Stdout: "web Running", Error: errors.New("exit status 1")})
+
+	runner := Runner{Executer: mockExecuter}
+
+	assert.Equal(t, Command{Command: "kubectl get pods", Output: "web Running", Error: "exit status 1"}, runner.RunCommand(context.Background(), "kubectl get pods"))
+	assert.Equal(t, Command{Command: "kubectl delete pod web", Refused: "the command is invalid: not allowed"}, runner.RunCommand(context.Background(), "kubectl delete pod web"))
+	mockExecuter.AssertNumberOfCalls(t, "Run", 1)
+}
+
+func TestRunner_RunWithTools(t *testing.T) {
+	echo := executer.NewTerminalExecuter(executer.TerminalExecuterType{AllowedCommands: []string{"echo"}})
+	toolbox := executer.NewToolbox(executer.Tool{Name: "echo", Executer: echo})
+
+	mockAgent := new(MockAgent)
+	mockAgent.On("Iterate", mock.Anything, "say hello").Return(agent.AgentResponse{RunCommand: "echo hello", Reason: "greet", Tool: "kubectl"}, nil)
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.Contains(prompt, `unknown tool "kubectl"`)
+	})).Return(agent.AgentResponse{RunCommand: "echo hello", Reason: "greet", Tool: "echo"}, nil)
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.HasPrefix(prompt, "Command output (exit code 0, took ") && strings.HasSuffix(prompt, "):\nhello")
+	})).Return(agent.AgentResponse{Answer: "Said hello."}, nil)
+
+	runner := Runner{Agent: mockAgent, Executer: toolbox, MaxCommands: 5}
+	result, err := runner.Run(context.Background(), "say hello")
+	require.NoError(t, err)
+
+	require.Len(t, result.Commands, 2)
+	assert.Equal(t, "kubectl", result.Commands[0].Tool)
+	assert.Contains(t, result.Commands[0].Refused, "unknown tool")
+	result.Commands[1].DurationMS = 0
+	assert.Equal(t, Command{Command: "echo hello", Reason: "greet", Tool: "echo", Output: "hello"}, result.Commands[1])
+	assert.Equal(t, "Said hello.", result.Answer)
+}
+
+func TestRunner_RunPlan(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockExecuter.On("Validate", mock.Anything).Return(nil)
+	mockExecuter.On("Run", mock.Anything, "kubectl get pods").Return(executer.ExecuterResponse{Stdout: "web Running"})
+	mockExecuter.On("Run", mock.Anything, "kubectl get events").Return(executer.ExecuterResponse{Stdout: "forbidden", Error: errors.New("exit status 1")})
+	mockExecuter.On("Run", mock.Anything, "kubectl get nodes").Return(executer.ExecuterResponse{Stdout: "node-1 Ready"})
+
+	mockAgent.On("Iterate", mock.Anything, "what is wrong?").Return(agent.AgentResponse{Plan: []agent.PlanStep{
+		{Command: "kubectl get pods", Reason: "list pods"},
+		{Command: "kubectl get events", Reason: "list events"},
+		{Command: "kubectl get nodes", Reason: "list nodes"},
+		{Command: "kubectl get namespaces", Reason: "list namespaces"},
+	}}, nil)
+	// a failed step does not stop the others, and the outputs keep the step order
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.HasPrefix(prompt, "Plan results:\nStep 1 `kubectl get pods`:\nCommand output:\nweb Running\n\nStep 2 `kubectl get events`:\nError executing command") &&
+			strings.Contains(prompt, "Step 3 `kubectl get nodes`:\nCommand output:\nnode-1 Ready") &&
+			strings.HasSuffix(prompt, "Step 4 `kubectl get namespaces`:\nNot run, the command limit was reached.")
+	})).Return(agent.AgentResponse{Answer: "Events are forbidden."}, nil)
+
+	runner := Runner{Agent: mockAgent, Executer: mockExecuter, MaxCommands: 3}
+	result, err := runner.Run(context.Background(), "what is wrong?")
+	require.NoError(t, err)
+
+	require.Len(t, result.Commands, 3)
+	assert.Equal(t, "list events", result.Commands[1].Reason)
+	assert.Equal(t, "exit status 1", result.Commands[1].Error)
+	assert.Equal(t, "Events are forbidden.", result.Answer)
+	mockExecuter.AssertNumberOfCalls(t, "Run", 3)
+}
@@ -0,0 +1,120 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+
+	// refreshes the tokens of kubeconfig users with the oidc auth provider
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+)
+
+// Client is a minimal Kubernetes API client on top of client-go, which handles the
+// credentials, TLS settings and impersonation of the kubeconfig.
+type Client struct {
+	config    *RestConfig
+	rest      rest.Interface
+	discovery discovery.DiscoveryInterface
+
+	Logger *slog.Logger // receives the failures the client works around, nil discards them
+
+	mu        sync.Mutex
+	resources []Resource // discovered API resources, see Discover
+}
+
+// StatusError is returned when the API server answers with an error status.
+type StatusError struct {
+	Code    int
+	Reason  string
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	reason := e.Reason
+	if reason == "" {
+		reason = http.StatusText(e.Code)
+	}
+	return fmt.Sprintf("Error from server (%s): %s", reason, e.Message)
+}
+
+// NewClient creates a client for the given REST config.
+func NewClient(config *RestConfig) (*Client, error) {
+	restConfig := rest.CopyConfig(config.Config)
+	// discovery fetches every API group at once, as kubectl does
+	restConfig.QPS, restConfig.Burst = 50, 300
+	restConfig.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+
+	restClient, err := rest.UnversionedRESTClientFor(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the Kubernetes client: %w", err)
+	}
+
+	return &Client{
+		config:    config,
+		rest:      restClient,
+		discovery: discovery.NewDiscoveryClient(restClient),
+	}, nil
+}
+
+// Config returns the REST config of the client.
+func (c *Client) Config() *RestConfig {
+	return c.config
+}
+
+// Get fetches an API path. Accept defaults to JSON.
+func (c *Client) Get(ctx context.Context, path string, query url.Values, accept string) ([]byte, error) {
+	req := c.rest.Get().AbsPath(path)
+	for key, values := range query {
+		for _, value := range values {
+			req.Param(key, value)
+		}
+	}
+	if accept != "" {
+		req.SetHeader("Accept", accept)
+	}
+	return raw(req.Do(ctx))
+}
+
+// Post sends the JSON encoding of body to an API path.
+func (c *Client) Post(ctx context.Context, path string, body any) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	return raw(c.rest.Post().AbsPath(path).SetHeader("Content-Type", "application/json").Body(data).Do(ctx))
+}
+
+// raw returns the body of a response, or the status the API server answered with.
+func raw(result rest.Result) ([]byte, error) {
+	data, err := result.Raw()
+	if err != nil {
+		// Error decodes the status in the body, which Raw leaves out
+		return nil, statusError(result.Error())
+	}
+	return data, nil
+}
+
+// statusError converts the error status of the API server to a StatusError, and
+// describes the other failures of a request.
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var apiStatus apierrors.APIStatus
+	if errors.As(err, &apiStatus) {
+		status := apiStatus.Status()
+		return &StatusError{Code: int(status.Code), Reason: string(status.Reason), Message: strings.TrimSpace(status.Message)}
+	}
+	return fmt.Errorf("failed to reach the API server: %w", err)
+}
@@ -0,0 +1,171 @@
+package kube
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+// newTestAPIServer serves a minimal discovery API.
+func newTestAPIServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"versions":["v1"]}`))
+	})
+	mux.HandleFunc("/api/v1", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"v1","resources":[
+			{"name":"pods","singularName":"pod","namespaced":true,"kind":"Pod","shortNames":["po"]},
+			{"name":"pods/log","singularName":"","namespaced":true,"kind":"Pod"},
+			{"name":"events","singularName":"event","namespaced":true,"kind":"Event","shortNames":["ev"]},
+			{"name":"nodes","singularName":"node","namespaced":false,"kind":"Node","shortNames":["no"]}]}`))
+	})
+	mux.HandleFunc("/apis", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"groups":[
+			{"name":"apps","versions":[{"groupVersion":"apps/v1","version":"v1"}],"preferredVersion":{"groupVersion":"apps/v1","version":"v1"}},
+			{"name":"events.k8s.io","versions":[{"groupVersion":"events.k8s.io/v1","version":"v1"}],"preferredVersion":{"groupVersion":"events.k8s.io/v1","version":"v1"}},
+			{"name":"metrics.k8s.io","versions":[{"groupVersion":"metrics.k8s.io/v1beta1","version":"v1beta1"}],"preferredVersion":{"groupVersion":"metrics.k8s.io/v1beta1","version":"v1beta1"}}]}`))
+	})
+	mux.HandleFunc("/apis/apps/v1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"apps/v1","resources":[
+			{"name":"deployments","singularName":"deployment","namespaced":true,"kind":"Deployment","shortNames":["deploy"]}]}`))
+	})
+	mux.HandleFunc("/apis/events.k8s.io/v1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"events.k8s.io/v1","resources":[
+			{"name":"events","singularName":"event","namespaced":true,"kind":"Event","shortNames":["ev"]}]}`))
+	})
+	mux.HandleFunc("/apis/metrics.k8s.io/v1beta1", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/api/v1/namespaces/default/pods/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","code":404,"reason":"NotFound","message":"pods \"missing\" not found"}`))
+	})
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// client-go decodes discovery documents and error statuses by their content type
+		w.Header().Set("Content-Type", "application/json")
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func newTestClient(t *testing.T, server *httptest.Server) *Client {
+	client, err := NewClient(&RestConfig{
+		Namespace: "default",
+		Config:    &rest.Config{Host: server.URL, BearerToken: "test-token"},
+	})
+	require.NoError(t, err)
+	return client
+}
+
+func TestClient_ResolveResource(t *testing.T) {
+	server := newTestAPIServer(t)
+	defer server.Close()
+
+	client := newTestClient(t, server)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"pods", "/api/v1/namespaces/default/pods"},
+		{"pod", "/api/v1/namespaces/default/pods"},
+		{"po", "/api/v1/namespaces/default/pods"},
+		{"Pod", "/api/v1/namespaces/default/pods"},
+		{"deploy", "/apis/apps/v1/namespaces/default/deployments"},
+		{"deployments.apps", "/apis/apps/v1/namespaces/default/deployments"},
+		{"events", "/api/v1/namespaces/default/events"},
+		{"events.events.k8s.io", "/apis/events.k8s.io/v1/namespaces/default/events"},
+		{"nodes", "/api/v1/nodes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource, err := client.ResolveResource(ctx, tt.name)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, resource.Path("default", ""))
+		})
+	}
+
+	_, err := client.ResolveResource(ctx, "widgets")
+	assert.EqualError(t, err, `the server doesn't have a resource type "widgets"`)
+}
+
+func TestClient_StatusError(t *testing.T) {
+	server := newTestAPIServer(t)
+	defer server.Close()
+
+	_, err := newTestClient(t, server).Get(context.Background(), "/api/v1/namespaces/default/pods/missing", nil, "")
+
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.Code)
+	assert.EqualError(t, err, `Error from server (NotFound): pods "missing" not found`)
+}
+
+func TestClient_Summarize(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"gitVersion":"v1.30.2"}`))
+	})
+	mux.HandleFunc("/api/v1/nodes", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"items":[
+			{"status":{"conditions":[{"type":"Ready","status":"True"}]}},
+			{"status":{"conditions":[{"type":"Ready","status":"False"}]}}]}`))
+	})
+	mux.HandleFunc("/api/v1/namespaces", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewClient(&RestConfig{Context: "prod", Config: &rest.Config{Host: server.URL}})
+	require.NoError(t, err)
+
+	summary, err := client.Summarize(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "- Current kube context: prod\n- Kubernetes server version: v1.30.2\n- Nodes: 2 (1 ready)", summary.String())
+
+	summary.Namespaces = make([]string, maxSummaryNamespaces+2)
+	for i := range summary.Namespaces {
+		summary.Namespaces[i] = "ns"
+	}
+	assert.Contains(t, summary.String(), "ns, ns and 2 more")
+}
+
+func TestClient_CustomResources(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/apis/apiextensions.k8s.io/v1/customresourcedefinitions", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"items":[
+			{"spec":{"group":"widgets.example.com","names":{"plural":"widgets"}}},
+			{"spec":{"group":"cert-manager.io","names":{"plural":"issuers"}}},
+			{"spec":{"group":"cert-manager.io","names":{"plural":"certificates"}}},
+			{"spec":{"group":"rollouts.argoproj.io","names":{"plural":"rollouts"}}}]}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewClient(&RestConfig{Config: &rest.Config{Host: server.URL}})
+	require.NoError(t, err)
+
+	groups, err := client.CustomResources(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []CustomResourceGroup{
+		{Group: "cert-manager.io", Operator: "cert-manager", Resources: []string{"certificates", "issuers"}},
+		{Group: "rollouts.argoproj.io", Operator: "Argo", Resources: []string{"rollouts"}},
+		{Group: "widgets.example.com", Resources: []string{"widgets"}},
+	}, groups)
+
+	summary := ClusterSummary{ServerVersion: "v1.30.2", CustomResources: groups}
+	assert.Equal(t, "- Kubernetes server version: v1.30.2\n"+
+		"- Custom resources, query them with kubectl get <resource>.<group> as well as the core objects:\n"+
+		"  - cert-manager (cert-manager.io): certificates, issuers\n"+
+		"  - Argo (rollouts.argoproj.io): rollouts\n"+
+		"  - widgets.example.com: widgets", summary.String())
+}
@@ -0,0 +1,120 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxSummaryNamespaces caps the namespaces listed in a cluster summary.
+const maxSummaryNamespaces = 50
+
+// ClusterSummary describes a cluster in a few lines, for the agent's system prompt.
+type ClusterSummary struct {
+	Context       string
+	ServerVersion string
+	Nodes         int
+	ReadyNodes    int
+	Namespaces    []string
+
+	CustomResources []CustomResourceGroup // custom resources by API group, such as those of operators
+}
+
+// Summarize gathers the server version, node count, namespaces and custom resources of
+// the cluster.
+func (c *Client) Summarize(ctx context.Context) (*ClusterSummary, error) {
+	summary := &ClusterSummary{Context: c.config.Context}
+
+	data, err := c.Get(ctx, "/version", nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server version: %w", err)
+	}
+	var version struct {
+		GitVersion string `json:"gitVersion"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, fmt.Errorf("failed to parse server version: %w", err)
+	}
+	summary.ServerVersion = version.GitVersion
+
+	// nodes and namespaces are optional, restricted users may not list them
+	if data, err := c.Get(ctx, "/api/v1/nodes", nil, ""); err == nil {
+		var nodes struct {
+			Items []struct {
+				Status struct {
+					Conditions []struct {
+						Type   string `json:"type"`
+						Status string `json:"status"`
+					} `json:"conditions"`
+				} `json:"status"`
+			} `json:"items"`
+		}
+		if json.Unmarshal(data, &nodes) == nil {
+			summary.Nodes = len(nodes.Items)
+			for _, node := range nodes.Items {
+				for _, condition := range node.Status.Conditions {
+					if condition.Type == "Ready" && condition.Status == "True" {
+						summary.ReadyNodes++
+					}
+				}
+			}
+		}
+	}
+
+	if data, err := c.Get(ctx, "/api/v1/namespaces", nil, ""); err == nil {
+		var namespaces struct {
+			Items []struct {
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+			} `json:"items"`
+		}
+		if json.Unmarshal(data, &namespaces) == nil {
+			for _, namespace := range namespaces.Items {
+				summary.Namespaces = append(summary.Namespaces, namespace.Metadata.Name)
+			}
+		}
+	}
+
+	if groups, err := c.CustomResources(ctx); err == nil {
+		summary.CustomResources = groups
+	}
+
+	return summary, nil
+}
+
+// String renders the summary as a compact list.
+func (s *ClusterSummary) String() string {
+	var sb strings.Builder
+	if s.Context != "" {
+		fmt.Fprintf(&sb, "- Current kube context: %s\n", s.Context)
+	}
+	fmt.Fprintf(&sb, "- Kubernetes server version: %s\n", s.ServerVersion)
+	if s.Nodes > 0 {
+		fmt.Fprintf(&sb, "- Nodes: %d (%d ready)\n", s.Nodes, s.ReadyNodes)
+	}
+	if len(s.Namespaces) > 0 {
+		namespaces := s.Namespaces
+		more := ""
+		if len(namespaces) > maxSummaryNamespaces {
+			more = fmt.Sprintf(" and %d more", len(namespaces)-maxSummaryNamespaces)
+			namespaces = namespaces[:maxSummaryNamespaces]
+		}
+		fmt.Fprintf(&sb, "- Namespaces: %s%s\n", strings.Join(namespaces, ", "), more)
+	}
+	if len(s.CustomResources) > 0 {
+		sb.WriteString("- Custom resources, query them with kubectl get <resource>.<group> as well as the core objects:\n")
+		groups := s.CustomResources
+		if len(groups) > maxSummaryCRDGroups {
+			groups = groups[:maxSummaryCRDGroups]
+		}
+		for _, group := range groups {
+			fmt.Fprintf(&sb, "  - %s\n", group)
+		}
+		if more := len(s.CustomResources) - len(groups); more > 0 {
+			fmt.Fprintf(&sb, "  - and %d more groups, list them with kubectl get crd\n", more)
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
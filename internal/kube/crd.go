@@ -0,0 +1,137 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxSummaryCRDGroups caps the API groups of custom resources listed in a cluster
+	// summary, the groups of known operators first.
+	maxSummaryCRDGroups = 30
+
+	// maxSummaryCRDResources caps the resources listed for each API group.
+	maxSummaryCRDResources = 8
+)
+
+// knownOperators maps the API groups of well-known operators and controllers to their
+// names. Groups also match their subgroups, as rollouts.argoproj.io matches argoproj.io.
+var knownOperators = map[string]string{
+	"external-secrets.io":          "External Secrets Operator",
+	"cert-manager.io":              "cert-manager",
+	"acme.cert-manager.io":         "cert-manager",
+	"argoproj.io":                  "Argo",
+	"monitoring.coreos.com":        "Prometheus Operator",
+	"keda.sh":                      "KEDA",
+	"karpenter.sh":                 "Karpenter",
+	"karpenter.k8s.aws":            "Karpenter",
+	"istio.io":                     "Istio",
+	"linkerd.io":                   "Linkerd",
+	"cilium.io":                    "Cilium",
+	"projectcalico.org":            "Calico",
+	"traefik.io":                   "Traefik",
+	"traefik.containo.us":          "Traefik",
+	"gateway.networking.k8s.io":    "Gateway API",
+	"kyverno.io":                   "Kyverno",
+	"templates.gatekeeper.sh":      "Gatekeeper",
+	"constraints.gatekeeper.sh":    "Gatekeeper",
+	"toolkit.fluxcd.io":            "Flux",
+	"crossplane.io":                "Crossplane",
+	"velero.io":                    "Velero",
+	"bitnami.com":                  "Sealed Secrets",
+	"postgresql.cnpg.io":           "CloudNativePG",
+	"snapshot.storage.k8s.io":      "Volume snapshots",
+	"elbv2.k8s.aws":                "AWS Load Balancer Controller",
+	"secrets-store.csi.x-k8s.io":   "Secrets Store CSI Driver",
+	"opentelemetry.io":             "OpenTelemetry Operator",
+	"jaegertracing.io":             "Jaeger Operator",
+	"strimzi.io":                   "Strimzi",
+	"rabbitmq.com":                 "RabbitMQ Cluster Operator",
+	"mongodbcommunity.mongodb.com": "MongoDB Community Operator",
+}
+
+// CustomResourceGroup is an API group of custom resources installed in the cluster.
+type CustomResourceGroup struct {
+	Group     string
+	Operator  string   // known operator that serves the group, empty when unknown
+	Resources []string // plural names of the resources, sorted
+}
+
+// operatorOf returns the name of the known operator that serves the API group.
+func operatorOf(group string) string {
+	for {
+		if operator, ok := knownOperators[group]; ok {
+			return operator
+		}
+		_, parent, found := strings.Cut(group, ".")
+		if !found || !strings.Contains(parent, ".") {
+			return ""
+		}
+		group = parent
+	}
+}
+
+// CustomResources lists the custom resource definitions of the cluster by API group,
+// the groups of known operators first.
+func (c *Client) CustomResources(ctx context.Context) ([]CustomResourceGroup, error) {
+	data, err := c.Get(ctx, "/apis/apiextensions.k8s.io/v1/customresourcedefinitions", nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list custom resource definitions: %w", err)
+	}
+	var crds struct {
+		Items []struct {
+			Spec struct {
+				Group string `json:"group"`
+				Names struct {
+					Plural string `json:"plural"`
+				} `json:"names"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &crds); err != nil {
+		return nil, fmt.Errorf("failed to parse custom resource definitions: %w", err)
+	}
+
+	byGroup := map[string]*CustomResourceGroup{}
+	var groups []*CustomResourceGroup
+	for _, crd := range crds.Items {
+		group, ok := byGroup[crd.Spec.Group]
+		if !ok {
+			group = &CustomResourceGroup{Group: crd.Spec.Group, Operator: operatorOf(crd.Spec.Group)}
+			byGroup[crd.Spec.Group] = group
+			groups = append(groups, group)
+		}
+		group.Resources = append(group.Resources, crd.Spec.Names.Plural)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if known := groups[i].Operator != ""; known != (groups[j].Operator != "") {
+			return known
+		}
+		return groups[i].Group < groups[j].Group
+	})
+	result := make([]CustomResourceGroup, len(groups))
+	for i, group := range groups {
+		sort.Strings(group.Resources)
+		result[i] = *group
+	}
+	return result, nil
+}
+
+// String renders the group as its operator, group and resources.
+func (g CustomResourceGroup) String() string {
+	resources := g.Resources
+	more := ""
+	if len(resources) > maxSummaryCRDResources {
+		more = fmt.Sprintf(" and %d more", len(resources)-maxSummaryCRDResources)
+		resources = resources[:maxSummaryCRDResources]
+	}
+	name := g.Group
+	if g.Operator != "" {
+		name = g.Operator + " (" + g.Group + ")"
+	}
+	return fmt.Sprintf("%s: %s%s", name, strings.Join(resources, ", "), more)
+}
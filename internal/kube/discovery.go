@@ -0,0 +1,133 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eliran89c/klama/internal/logger"
+	"k8s.io/client-go/discovery"
+)
+
+// Resource is an API resource served by the cluster.
+type Resource struct {
+	Group        string
+	Version      string
+	Name         string // plural name, such as deployments
+	SingularName string
+	ShortNames   []string
+	Kind         string
+	Namespaced   bool
+	Verbs        []string
+}
+
+// Path returns the API path of the resource. The namespace is ignored for cluster
+// scoped resources, and an empty name addresses the whole collection.
+func (r Resource) Path(namespace, name string) string {
+	path := "/apis/" + r.Group + "/" + r.Version
+	if r.Group == "" {
+		path = "/api/" + r.Version
+	}
+	if r.Namespaced && namespace != "" {
+		path += "/namespaces/" + namespace
+	}
+	path += "/" + r.Name
+	if name != "" {
+		path += "/" + name
+	}
+	return path
+}
+
+// GroupResource returns the resource name qualified with its group, as used by kubectl.
+func (r Resource) GroupResource() string {
+	if r.Group == "" {
+		return r.Name
+	}
+	return r.Name + "." + r.Group
+}
+
+// Discover returns the resources served by the cluster, using the preferred version of
+// each API group. The result is cached for the lifetime of the client.
+func (c *Client) Discover(ctx context.Context) ([]Resource, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resources != nil {
+		return c.resources, nil
+	}
+
+	// lists are kept in server order, which decides precedence when several groups use
+	// the same resource name
+	lists, err := c.discovery.ServerPreferredResources()
+	if err != nil {
+		var groupErr *discovery.ErrGroupDiscoveryFailed
+		if !errors.As(err, &groupErr) {
+			return nil, fmt.Errorf("failed to discover API resources: %w", statusError(err))
+		}
+		// an unavailable aggregated API must not break discovery
+		for groupVersion, err := range groupErr.Groups {
+			logger.Or(c.Logger).Warn("Failed to discover API group", "group_version", groupVersion.String(), "error", err)
+		}
+	}
+
+	var resources []Resource
+	for _, list := range lists {
+		group, version, found := strings.Cut(list.GroupVersion, "/")
+		if !found {
+			group, version = "", list.GroupVersion
+		}
+		for _, r := range list.APIResources {
+			// skip subresources such as pods/log
+			if strings.Contains(r.Name, "/") {
+				continue
+			}
+			resources = append(resources, Resource{
+				Group:        group,
+				Version:      version,
+				Name:         r.Name,
+				SingularName: r.SingularName,
+				ShortNames:   r.ShortNames,
+				Kind:         r.Kind,
+				Namespaced:   r.Namespaced,
+				Verbs:        r.Verbs,
+			})
+		}
+	}
+	c.resources = resources
+
+	return resources, nil
+}
+
+// ResolveResource finds the resource kubectl would use for the given name. The name may
+// be plural, singular, a short name or a kind, optionally qualified with a group
+// (deployments.apps).
+func (c *Client) ResolveResource(ctx context.Context, name string) (Resource, error) {
+	resources, err := c.Discover(ctx)
+	if err != nil {
+		return Resource{}, err
+	}
+
+	name = strings.ToLower(name)
+	for _, candidate := range []string{name, strings.SplitN(name, ".", 2)[0]} {
+		for _, r := range resources {
+			if r.matches(candidate) && (candidate == name || strings.HasPrefix(r.Group, name[len(candidate)+1:])) {
+				return r, nil
+			}
+		}
+	}
+
+	return Resource{}, fmt.Errorf("the server doesn't have a resource type %q", name)
+}
+
+func (r Resource) matches(name string) bool {
+	if name == r.Name || name == r.SingularName || name == strings.ToLower(r.Kind) {
+		return true
+	}
+	for _, short := range r.ShortNames {
+		if name == short {
+			return true
+		}
+	}
+	return false
+}
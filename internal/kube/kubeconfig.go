@@ -0,0 +1,129 @@
+package kube
+
+import (
+	"cmp"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ErrNoConfig is returned when neither a kubeconfig file nor an in-cluster
+// service account is available.
+var ErrNoConfig = errors.New("no kubeconfig found and not running inside a cluster")
+
+// Kubeconfig is the merged content of the kubeconfig files.
+type Kubeconfig struct {
+	*clientcmdapi.Config
+}
+
+// KubeconfigPaths returns the kubeconfig files to read: the explicit path if set,
+// otherwise $KUBECONFIG or ~/.kube/config.
+func KubeconfigPaths(explicit string) []string {
+	if explicit != "" {
+		return []string{explicit}
+	}
+
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		var paths []string
+		for _, path := range filepath.SplitList(env) {
+			if path != "" {
+				paths = append(paths, path)
+			}
+		}
+		return paths
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(home, ".kube", "config")}
+}
+
+// LoadKubeconfig reads and merges the given kubeconfig files as kubectl does: the first
+// file to define an entry wins, missing files are skipped, and relative file references
+// are resolved against the file that contains them.
+func LoadKubeconfig(paths []string) (*Kubeconfig, error) {
+	config, err := (&clientcmd.ClientConfigLoadingRules{Precedence: paths}).Load()
+	if err != nil {
+		return nil, err
+	}
+	if clientcmdapi.IsConfigEmpty(config) {
+		return nil, ErrNoConfig
+	}
+	return &Kubeconfig{Config: config}, nil
+}
+
+// ContextNames returns the names of all contexts, sorted.
+func (kc *Kubeconfig) ContextNames() []string {
+	names := make([]string, 0, len(kc.Contexts))
+	for name := range kc.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RestConfig holds everything needed to talk to an API server.
+type RestConfig struct {
+	Context   string       // kubeconfig context name, empty when running in-cluster
+	Namespace string       // default namespace of the context
+	Config    *rest.Config // server address, TLS settings and credentials of the context
+}
+
+// LoadRestConfig loads the REST config of the given context, or of the current context
+// when contextName is empty, from the kubeconfig at path (see KubeconfigPaths). When no
+// kubeconfig exists, the in-cluster service account is used. Credential plugins,
+// auth providers and impersonation are handled as kubectl handles them.
+func LoadRestConfig(path, contextName string) (*RestConfig, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = path
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: contextName})
+
+	config, err := loader.ClientConfig()
+	if clientcmd.IsEmptyConfig(err) {
+		return nil, ErrNoConfig
+	}
+	if err != nil {
+		return nil, err
+	}
+	namespace, _, err := loader.Namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	// the context is empty when the in-cluster service account is used
+	raw, err := loader.RawConfig()
+	if err != nil {
+		return nil, err
+	}
+	name := cmp.Or(contextName, raw.CurrentContext)
+	if _, ok := raw.Contexts[name]; !ok {
+		name = ""
+	}
+
+	return &RestConfig{
+		Context:   name,
+		Namespace: cmp.Or(namespace, "default"),
+		Config:    config,
+	}, nil
+}
+
+// MatchContext reports whether the context name matches any of the glob patterns, where
+// `*` matches any sequence of characters, including `/` in EKS cluster ARNs.
+func MatchContext(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+		if regexp.MustCompile(expr).MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package kube
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKubeconfig = `
+current-context: dev
+clusters:
+- name: dev-cluster
+  cluster:
+    server: https://dev.example.com
+    certificate-authority: certs/ca.crt
+- name: prod-cluster
+  cluster:
+    server: https://prod.example.com
+contexts:
+- name: dev
+  context:
+    cluster: dev-cluster
+    user: dev-user
+    namespace: apps
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: prod-user
+users:
+- name: dev-user
+  user:
+    token: dev-token
+    as: oncall
+- name: prod-user
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+      args: ["eks", "get-token"]
+      provideClusterInfo: true
+`
+
+const testOverrideKubeconfig = `
+current-context: prod
+clusters:
+- name: dev-cluster
+  cluster:
+    server: https://ignored.example.com
+contexts:
+- name: staging
+  context:
+    cluster: dev-cluster
+    user: dev-user
+`
+
+func writeKubeconfig(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoadKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	first := writeKubeconfig(t, dir, "config", testKubeconfig)
+	second := writeKubeconfig(t, dir, "override", testOverrideKubeconfig)
+
+	kc, err := LoadKubeconfig([]string{first, filepath.Join(dir, "missing"), second})
+	require.NoError(t, err)
+
+	// the first file wins
+	assert.Equal(t, "dev", kc.CurrentContext)
+	assert.Equal(t, []string{"dev", "prod", "staging"}, kc.ContextNames())
+	assert.Equal(t, "https://dev.example.com", kc.Clusters["dev-cluster"].Server)
+}
+
+func TestLoadKubeconfig_NotFound(t *testing.T) {
+	_, err := LoadKubeconfig([]string{filepath.Join(t.TempDir(), "missing")})
+	assert.ErrorIs(t, err, ErrNoConfig)
+}
+
+func TestLoadRestConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := writeKubeconfig(t, dir, "config", testKubeconfig)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "certs"), 0700))
+	writeKubeconfig(t, dir, "certs/ca.crt", "")
+
+	rc, err := LoadRestConfig(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, "dev", rc.Context)
+	assert.Equal(t, "apps", rc.Namespace)
+	assert.Equal(t, "https://dev.example.com", rc.Config.Host)
+	assert.Equal(t, filepath.Join(dir, "certs", "ca.crt"), rc.Config.CAFile)
+	assert.Equal(t, "dev-token", rc.Config.BearerToken)
+	assert.Equal(t, "oncall", rc.Config.Impersonate.UserName)
+
+	rc, err = LoadRestConfig(path, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "prod", rc.Context)
+	assert.Equal(t, "default", rc.Namespace)
+	require.NotNil(t, rc.Config.ExecProvider)
+	assert.Equal(t, "aws", rc.Config.ExecProvider.Command)
+	assert.Equal(t, []string{"eks", "get-token"}, rc.Config.ExecProvider.Args)
+	assert.True(t, rc.Config.ExecProvider.ProvideClusterInfo)
+
+	_, err = LoadRestConfig(path, "missing")
+	assert.ErrorContains(t, err, `context "missing" does not exist`)
+}
+
+func TestLoadRestConfig_NotFound(t *testing.T) {
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	_, err := LoadRestConfig("", "")
+	assert.ErrorIs(t, err, ErrNoConfig)
+}
+
+func TestKubeconfigPaths(t *testing.T) {
+	assert.Equal(t, []string{"/explicit"}, KubeconfigPaths("/explicit"))
+
+	t.Setenv("KUBECONFIG", "/a"+string(os.PathListSeparator)+"/b")
+	assert.Equal(t, []string{"/a", "/b"}, KubeconfigPaths(""))
+
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("HOME", "/home/test")
+	assert.Equal(t, []string{"/home/test/.kube/config"}, KubeconfigPaths(""))
+}
+
+func TestMatchContext(t *testing.T) {
+	patterns := []string{"*prod*", "arn:aws:eks:us-east-1:123456789012:cluster/live-*"}
+
+	assert.True(t, MatchContext(patterns, "prod"))
+	assert.True(t, MatchContext(patterns, "gke_project_europe-west1_prod-cluster"))
+	assert.True(t, MatchContext(patterns, "arn:aws:eks:us-east-1:123456789012:cluster/live-eu"))
+	assert.False(t, MatchContext(patterns, "staging"))
+	assert.False(t, MatchContext(patterns, "arn:aws:eks:us-east-1:123456789012:cluster/staging"))
+	assert.False(t, MatchContext(nil, "prod"))
+}
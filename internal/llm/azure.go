@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/eliran89c/klama/config"
+)
+
+// azureOpenAIScope is the Microsoft Entra ID scope of Azure OpenAI tokens.
+const azureOpenAIScope = "https://cognitiveservices.azure.com/.default"
+
+// azureTokenRefreshMargin is how long before it expires a token is replaced.
+const azureTokenRefreshMargin = 5 * time.Minute
+
+// newAzureCredential returns the Microsoft Entra ID credential of the configured Azure
+// authentication method. The client secret is read from the auth token.
+func newAzureCredential(modelConfig config.ModelConfig) (azcore.TokenCredential, error) {
+	switch modelConfig.AzureAuth {
+	case config.AzureAuthDefault:
+		return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{TenantID: modelConfig.AzureTenantID})
+	case config.AzureAuthManagedIdentity:
+		options := &azidentity.ManagedIdentityCredentialOptions{}
+		if modelConfig.AzureClientID != "" {
+			options.ID = azidentity.ClientID(modelConfig.AzureClientID)
+		}
+		return azidentity.NewManagedIdentityCredential(options)
+	case config.AzureAuthCLI:
+		return azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: modelConfig.AzureTenantID})
+	case config.AzureAuthClientSecret:
+		return azidentity.NewClientSecretCredential(modelConfig.AzureTenantID, modelConfig.AzureClientID, modelConfig.AuthToken, nil)
+	default:
+		return nil, fmt.Errorf("unsupported Azure authentication %q", modelConfig.AzureAuth)
+	}
+}
+
+// azureTokenSource returns Azure OpenAI tokens of a credential. A token is reused until
+// it is about to expire, and then replaced on the next request.
+type azureTokenSource struct {
+	credential azcore.TokenCredential
+	now        func() time.Time
+
+	mu    sync.Mutex
+	token azcore.AccessToken
+}
+
+// Token returns a valid token, requesting a new one when the current one is about to expire.
+func (s *azureTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Token != "" && s.now().Add(azureTokenRefreshMargin).Before(s.token.ExpiresOn) {
+		return s.token.Token, nil
+	}
+
+	token, err := s.credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{azureOpenAIScope}})
+	if err != nil {
+		return "", fmt.Errorf("failed to get a Microsoft Entra ID token: %w", err)
+	}
+	s.token = token
+	return token.Token, nil
+}
@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/eliran89c/klama/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCredential struct {
+	tokens []string
+	calls  int
+	scopes []string
+	err    error
+}
+
+func (c *fakeCredential) GetToken(_ context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if c.err != nil {
+		return azcore.AccessToken{}, c.err
+	}
+	c.scopes = options.Scopes
+	token := c.tokens[c.calls]
+	c.calls++
+	return azcore.AccessToken{Token: token, ExpiresOn: time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)}, nil
+}
+
+func TestAzureTokenSource(t *testing.T) {
+	credential := &fakeCredential{tokens: []string{"first", "second"}}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := &azureTokenSource{credential: credential, now: func() time.Time { return now }}
+
+	token, err := source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "first", token)
+	assert.Equal(t, []string{azureOpenAIScope}, credential.scopes)
+
+	// the token is reused while it is valid
+	now = now.Add(50 * time.Minute)
+	token, err = source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "first", token)
+	assert.Equal(t, 1, credential.calls)
+
+	// and renewed shortly before it expires
+	now = now.Add(6 * time.Minute)
+	token, err = source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "second", token)
+
+	credential.err = errors.New("login required")
+	source.token = azcore.AccessToken{}
+	_, err = source.Token(context.Background())
+	assert.ErrorContains(t, err, "login required")
+}
+
+func TestModel_AzureEntraID(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	model := NewModel(server.Client(), config.ModelConfig{
+		BaseURL:         server.URL,
+		AzureAPIVersion: "2024-06-01",
+		AzureAuth:       config.AzureAuthManagedIdentity,
+	})
+	require.IsType(t, &azureTokenSource{}, model.TokenSource)
+
+	model.TokenSource = &azureTokenSource{credential: &fakeCredential{tokens: []string{"entra-token"}}, now: time.Now}
+	_, err := model.Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer entra-token", header.Get("Authorization"))
+	assert.Empty(t, header.Get("api-key"))
+
+	model.TokenSource = failedTokenSource{err: errors.New("no credential")}
+	_, err = model.Ask(context.Background(), "hi", 0)
+	assert.ErrorContains(t, err, "no credential")
+}
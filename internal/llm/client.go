@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/eliran89c/klama/config"
+)
+
+const (
+	openRouterReferer = "https://github.com/eliran89c/klama"
+	openRouterTitle   = "klama"
+)
+
+// NewHTTPClient returns the HTTP client of a model, with its extra headers, proxy and CA
+// bundle. Without them it uses the proxy of the environment and the system certificates.
+func NewHTTPClient(modelConfig config.ModelConfig) (*http.Client, error) {
+	headers := modelConfig.Headers
+	if modelConfig.Provider == config.ProviderOpenRouter {
+		headers = openRouterHeaders(modelConfig)
+	}
+	if len(headers) == 0 && modelConfig.ProxyURL == "" && modelConfig.CACert == "" {
+		return &http.Client{}, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if modelConfig.ProxyURL != "" {
+		proxyURL, err := url.Parse(modelConfig.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy address %q: %w", modelConfig.ProxyURL, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if modelConfig.CACert != "" {
+		pem, err := os.ReadFile(modelConfig.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", modelConfig.CACert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if len(headers) > 0 {
+		roundTripper = &headerTransport{headers: headers, next: transport}
+	}
+	return &http.Client{Transport: roundTripper}, nil
+}
+
+// openRouterHeaders returns the headers of an OpenRouter model, with the app attribution
+// headers OpenRouter shows in its rankings and activity log.
+func openRouterHeaders(modelConfig config.ModelConfig) map[string]string {
+	referer, title := http.CanonicalHeaderKey("HTTP-Referer"), http.CanonicalHeaderKey("X-Title")
+	headers := map[string]string{referer: openRouterReferer, title: openRouterTitle}
+	if modelConfig.OpenRouter.Referer != "" {
+		headers[referer] = modelConfig.OpenRouter.Referer
+	}
+	if modelConfig.OpenRouter.Title != "" {
+		headers[title] = modelConfig.OpenRouter.Title
+	}
+	// configured headers take precedence
+	for name, value := range modelConfig.Headers {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	return headers
+}
+
+// headerTransport adds headers to every request.
+type headerTransport struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	return t.next.RoundTrip(req)
+}
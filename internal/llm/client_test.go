@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	var header http.Header
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	// the handshake of the untrusted client fails on purpose
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	modelConfig := config.ModelConfig{
+		BaseURL:   server.URL,
+		AuthToken: "test-token",
+		Headers:   map[string]string{"x-org-id": "acme"},
+		CACert:    caCert,
+	}
+	client, err := NewHTTPClient(modelConfig)
+	require.NoError(t, err)
+
+	_, err = NewModel(client, modelConfig).Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", header.Get("X-Org-Id"))
+	assert.Equal(t, "Bearer test-token", header.Get("Authorization"))
+
+	// the server certificate is not trusted without the CA bundle
+	modelConfig.CACert = ""
+	client, err = NewHTTPClient(modelConfig)
+	require.NoError(t, err)
+	model := NewModel(client, modelConfig)
+	model.MaxAttempts = 1
+	_, err = model.Ask(context.Background(), "hi", 0)
+	assert.Error(t, err)
+}
+
+func TestNewHTTPClient_Proxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer proxy.Close()
+
+	modelConfig := config.ModelConfig{BaseURL: "http://llm.internal/v1", ProxyURL: proxy.URL}
+	client, err := NewHTTPClient(modelConfig)
+	require.NoError(t, err)
+
+	_, err = NewModel(client, modelConfig).Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "http://llm.internal/v1/chat/completions", proxied)
+}
+
+func TestNewHTTPClient_InvalidCABundle(t *testing.T) {
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caCert, []byte("not a certificate"), 0600))
+
+	_, err := NewHTTPClient(config.ModelConfig{CACert: caCert})
+	assert.ErrorContains(t, err, "no certificates found")
+
+	_, err = NewHTTPClient(config.ModelConfig{CACert: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.Error(t, err)
+}
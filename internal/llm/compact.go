@@ -0,0 +1,150 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrContextLengthExceeded is returned when the provider rejects a request because the
+// conversation no longer fits in the model's context window.
+var ErrContextLengthExceeded = fmt.Errorf("conversation exceeds the model context window")
+
+const (
+	// DefaultCompactThreshold is the fraction of the context window that triggers compaction.
+	DefaultCompactThreshold = 0.8
+
+	// compactKeepRecent is the minimum number of recent messages kept verbatim.
+	compactKeepRecent = 6
+
+	compactedHistoryPrefix = "Summary of the earlier conversation:\n"
+
+	compactPrompt = `You compress a troubleshooting conversation between a user and an assistant so it can continue in a smaller context.
+Write a concise summary that preserves:
+1. The user's original problem and goals.
+2. Every command that was run and the key facts its output revealed (resource names, namespaces, errors, versions).
+3. Hypotheses that were confirmed or ruled out, and any conclusions reached.
+Omit pleasantries and repeated output. Reply with the summary only.`
+)
+
+// Compact replaces older conversation turns with a summary written by the model itself.
+// The system prompt and the most recent exchanges are kept verbatim. It is a no-op when
+// there is nothing old enough to summarize.
+func (m *Model) Compact(ctx context.Context) error {
+	start := m.compactStart()
+	if start <= 1 {
+		return nil
+	}
+
+	older := m.History[1:start]
+	temperature := 0.0
+	chatResp, err := m.send(ctx, ChatRequest{
+		Model:       m.Name,
+		Temperature: &temperature,
+		Messages: []Message{
+			{Role: SystemRole, Content: compactPrompt},
+			{Role: UserRole, Content: renderTranscript(older)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to summarize history: %w", err)
+	}
+	if len(chatResp.Choices) == 0 || strings.TrimSpace(chatResp.Choices[0].Message.Content) == "" {
+		return fmt.Errorf("failed to summarize history: empty summary")
+	}
+	m.updateUsage(chatResp.Usage)
+
+	m.log().Info("Compacted history", "model", m.Name, "messages", len(older))
+
+	history := make([]Message, 0, len(m.History)-len(older)+2)
+	history = append(history, m.History[0])
+	history = append(history, Message{
+		Role:    SystemRole,
+		Content: compactedHistoryPrefix + strings.TrimSpace(chatResp.Choices[0].Message.Content),
+	})
+	history = append(history, m.History[start:]...)
+	m.History = history
+	m.lastContextTokens = 0
+
+	return nil
+}
+
+// shouldCompact reports whether the request with the prompt messages, or the last
+// request, comes close enough to the context window to summarize older turns first.
+func (m *Model) shouldCompact(promptMessages []Message) bool {
+	if m.ContextWindow <= 0 {
+		return false
+	}
+
+	threshold := m.CompactThreshold
+	if threshold <= 0 || threshold >= 1 {
+		threshold = DefaultCompactThreshold
+	}
+
+	tokens := max(m.lastContextTokens, m.EstimateTokens(append(slices.Clip(m.History), promptMessages...)))
+	return float64(tokens) >= threshold*float64(m.ContextWindow)
+}
+
+// checkContextWindow returns ErrContextLengthExceeded before a request with the prompt
+// messages is sent, when it is estimated not to fit in the context window.
+func (m *Model) checkContextWindow(promptMessages []Message) error {
+	if m.ContextWindow <= 0 {
+		return nil
+	}
+	if tokens := m.EstimateTokens(append(slices.Clip(m.History), promptMessages...)); tokens > m.ContextWindow {
+		return fmt.Errorf("%w: the request needs about %d tokens of the %d tokens window", ErrContextLengthExceeded, tokens, m.ContextWindow)
+	}
+	return nil
+}
+
+func (m *Model) compactable() bool {
+	return m.compactStart() > 1
+}
+
+// compactStart returns the index of the first message kept verbatim. The cut is placed
+// on a user message, or on an assistant message once the tool calls before it have their
+// results, so tool calls stay next to their results in long tool loops too.
+func (m *Model) compactStart() int {
+	if len(m.History) == 0 || m.History[0].Role != SystemRole {
+		return 0
+	}
+
+	for i := len(m.History) - compactKeepRecent; i > 1; i-- {
+		switch m.History[i].Role {
+		case UserRole:
+			return i
+		case AssistantRole:
+			if len(m.History[i-1].ToolCalls) == 0 {
+				return i
+			}
+		}
+	}
+
+	return 0
+}
+
+// renderTranscript formats messages as plain text for summarization.
+func renderTranscript(messages []Message) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		content := msg.Content
+		if msg.Role == SystemRole {
+			content = strings.TrimPrefix(content, compactedHistoryPrefix)
+		}
+		if content != "" {
+			fmt.Fprintf(&sb, "%s: %s\n\n", msg.Role, content)
+		}
+		for _, call := range msg.ToolCalls {
+			fmt.Fprintf(&sb, "%s called %s: %s\n\n", msg.Role, call.Function.Name, call.Function.Arguments)
+		}
+	}
+
+	return sb.String()
+}
+
+func isContextLengthError(body string) bool {
+	return strings.Contains(body, "context_length_exceeded") ||
+		strings.Contains(body, "maximum context length") ||
+		strings.Contains(body, "context window")
+}
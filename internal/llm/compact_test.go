@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func conversation(turns int) []Message {
+	history := []Message{{Role: SystemRole, Content: "system prompt"}}
+	for i := 0; i < turns; i++ {
+		history = append(history,
+			Message{Role: UserRole, Content: fmt.Sprintf("question %d", i)},
+			Message{Role: AssistantRole, Content: fmt.Sprintf("answer %d", i)},
+		)
+	}
+	return history
+}
+
+func newCompactionServer(t *testing.T, rejectLongRequests bool) (*httptest.Server, *int) {
+	compactions := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		if req.Messages[0].Content == compactPrompt {
+			compactions++
+			assert.Contains(t, req.Messages[1].Content, "question 0")
+			w.Write([]byte(`{"choices":[{"message":{"content":"the user asked about pods"}}],"usage":{"total_tokens":4,"prompt_tokens":3,"completion_tokens":1}}`))
+			return
+		}
+
+		if rejectLongRequests && len(req.Messages) > 10 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":"context_length_exceeded"}}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}],"usage":{"total_tokens":10,"prompt_tokens":8,"completion_tokens":2}}`))
+	}))
+	return server, &compactions
+}
+
+func newCompactionModel(server *httptest.Server) *Model {
+	return &Model{
+		Client:    server.Client(),
+		URL:       server.URL,
+		Name:      "test-model",
+		AuthToken: AuthToken{Key: "test-header", Value: "test-token"},
+	}
+}
+
+func TestAskCompactsNearContextWindow(t *testing.T) {
+	server, compactions := newCompactionServer(t, false)
+	defer server.Close()
+
+	model := newCompactionModel(server)
+	model.ContextWindow = 100
+	model.History = conversation(5)
+	model.lastContextTokens = 85
+
+	_, err := model.Ask(context.Background(), "next question", 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, *compactions)
+	assert.Equal(t, "system prompt", model.History[0].Content)
+	assert.Equal(t, SystemRole, model.History[1].Role)
+	assert.Equal(t, compactedHistoryPrefix+"the user asked about pods", model.History[1].Content)
+	assert.Equal(t, "question 2", model.History[2].Content)
+	assert.Equal(t, "ok", model.History[len(model.History)-1].Content)
+	assert.Equal(t, 14, model.Usage.TotalTokens)
+}
+
+func TestAskSkipsCompactionBelowThreshold(t *testing.T) {
+	server, compactions := newCompactionServer(t, false)
+	defer server.Close()
+
+	model := newCompactionModel(server)
+	model.ContextWindow = 100
+	model.History = conversation(5)
+	model.lastContextTokens = 50
+
+	_, err := model.Ask(context.Background(), "next question", 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, *compactions)
+	assert.Len(t, model.History, 13)
+}
+
+func TestAskCompactsOnContextLengthError(t *testing.T) {
+	server, compactions := newCompactionServer(t, true)
+	defer server.Close()
+
+	model := newCompactionModel(server)
+	model.History = conversation(5)
+
+	resp, err := model.Ask(context.Background(), "next question", 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, "ok", resp.Choices[0].Message.Content)
+	assert.Equal(t, 1, *compactions)
+	assert.True(t, strings.HasPrefix(model.History[1].Content, compactedHistoryPrefix))
+}
+
+func TestCompactStartKeepsToolResults(t *testing.T) {
+	model := &Model{History: []Message{
+		{Role: SystemRole, Content: "system prompt"},
+		{Role: UserRole, Content: "why is my pod failing?"},
+		{Role: AssistantRole, ToolCalls: []ToolCall{{ID: "1"}}},
+		{Role: ToolRole, Content: "output 1", ToolCallID: "1"},
+		{Role: AssistantRole, ToolCalls: []ToolCall{{ID: "2"}}},
+		{Role: ToolRole, Content: "output 2", ToolCallID: "2"},
+		{Role: AssistantRole, ToolCalls: []ToolCall{{ID: "3"}}},
+		{Role: ToolRole, Content: "output 3", ToolCallID: "3"},
+		{Role: AssistantRole, Content: "done"},
+		{Role: UserRole, Content: "thanks"},
+		{Role: AssistantRole, Content: "you're welcome"},
+	}}
+
+	// the cut lands on the assistant message after the first tool result, before the
+	// second tool call
+	start := model.compactStart()
+	assert.Equal(t, 4, start)
+	assert.True(t, model.compactable())
+
+	model.History = append(model.History, conversation(3)[1:]...)
+	start = model.compactStart()
+	assert.NotEqual(t, ToolRole, model.History[start].Role)
+	assert.Empty(t, model.History[start-1].ToolCalls)
+	assert.GreaterOrEqual(t, len(model.History)-start, compactKeepRecent)
+}
@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ModelInfo describes a model served by the endpoint.
+type ModelInfo struct {
+	ID            string `json:"id"`
+	ContextWindow int    `json:"context_window,omitempty"` // 0 when the endpoint does not report it
+}
+
+// modelEntry is an entry of a /models response. Providers report the context size
+// under different names: OpenRouter and Together as context_length, vLLM as
+// max_model_len, Mistral as max_context_length and Groq as context_window.
+type modelEntry struct {
+	ID               string `json:"id"`
+	ContextLength    int    `json:"context_length"`
+	MaxModelLen      int    `json:"max_model_len"`
+	MaxContextLength int    `json:"max_context_length"`
+	ContextWindow    int    `json:"context_window"`
+}
+
+// ListModels returns the models of the endpoint's OpenAI compatible /models API,
+// sorted by ID.
+func (m *Model) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.modelsURL(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := m.authorize(ctx, req); err != nil {
+		return nil, err
+	}
+
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("endpoint is not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("%w (status code: %d)", ErrUnauthorized, resp.StatusCode)
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return nil, fmt.Errorf("the endpoint does not list its models (status code: %d)", resp.StatusCode)
+	default:
+		errMsg := fmt.Sprintf("unexpected status code %d", resp.StatusCode)
+		if apiErr := parseAPIError(body); apiErr != nil {
+			errMsg += ": " + apiErr.Message
+		}
+		return nil, fmt.Errorf("failed to list models: %s", errMsg)
+	}
+
+	var list struct {
+		Data []modelEntry `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal models response: %w", err)
+	}
+
+	models := make([]ModelInfo, 0, len(list.Data))
+	for _, entry := range list.Data {
+		if entry.ID == "" {
+			continue
+		}
+		models = append(models, ModelInfo{
+			ID:            entry.ID,
+			ContextWindow: firstNonZero(entry.ContextLength, entry.MaxModelLen, entry.MaxContextLength, entry.ContextWindow),
+		})
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models, nil
+}
+
+// modelsURL returns the URL of the /models API next to the chat completions API,
+// keeping query parameters such as the Azure API version.
+func (m *Model) modelsURL() string {
+	return strings.Replace(m.URL, "/chat/completions", "/models", 1)
+}
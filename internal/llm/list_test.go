@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_ListModels(t *testing.T) {
+	var (
+		status int
+		body   string
+		path   string
+		auth   string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.RequestURI(), r.Header.Get("Authorization")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	model := NewModel(server.Client(), config.ModelConfig{BaseURL: server.URL + "/v1", AuthToken: "token"})
+
+	t.Run("lists the models with their context size", func(t *testing.T) {
+		status = http.StatusOK
+		body = `{"object":"list","data":[
+			{"id":"mistral-large","max_context_length":131072},
+			{"id":"gpt-4o"},
+			{"id":"llama-3.1-8b","max_model_len":8192},
+			{"id":"anthropic/claude-3.5-sonnet","context_length":200000},
+			{"id":""}
+		]}`
+		models, err := model.ListModels(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "/v1/models", path)
+		assert.Equal(t, "Bearer token", auth)
+		assert.Equal(t, []ModelInfo{
+			{ID: "anthropic/claude-3.5-sonnet", ContextWindow: 200000},
+			{ID: "gpt-4o"},
+			{ID: "llama-3.1-8b", ContextWindow: 8192},
+			{ID: "mistral-large", ContextWindow: 131072},
+		}, models)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		status, body = http.StatusUnauthorized, ""
+		_, err := model.ListModels(context.Background())
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	})
+
+	t.Run("endpoint without a models API", func(t *testing.T) {
+		status, body = http.StatusNotFound, ""
+		_, err := model.ListModels(context.Background())
+		assert.ErrorContains(t, err, "does not list its models")
+	})
+
+	t.Run("error message of the provider", func(t *testing.T) {
+		status, body = http.StatusBadGateway, `{"error":{"message":"upstream is down"}}`
+		_, err := model.ListModels(context.Background())
+		assert.ErrorContains(t, err, "upstream is down")
+	})
+}
+
+func TestModel_ModelsURL(t *testing.T) {
+	model := NewModel(http.DefaultClient, config.ModelConfig{BaseURL: "https://example.openai.azure.com/openai", AzureAPIVersion: "2024-06-01"})
+	assert.Equal(t, "https://example.openai.azure.com/openai/models?api-version=2024-06-01", model.modelsURL())
+}
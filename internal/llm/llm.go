@@ -4,14 +4,36 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"reflect"
+	"strings"
+	"time"
 
-	"github.com/eliran89c/klama/internal/logger"
+	"github.com/eliran89c/klama/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrToolsUnsupported is returned when the provider rejects a request because of its tools.
+var ErrToolsUnsupported = fmt.Errorf("model does not support tool calling")
+
+// ErrTemperatureUnsupported is returned when the provider rejects the temperature of a
+// request, as reasoning models do.
+var ErrTemperatureUnsupported = fmt.Errorf("model does not support the temperature parameter")
+
+// ErrBudgetExceeded is returned when the session token or spend budget is used up.
+var ErrBudgetExceeded = fmt.Errorf("session budget exceeded")
+
+const skippedToolCallMessage = "Skipped: only one command can be executed per turn."
+
+// tracerName names the tracer of the model requests.
+const tracerName = "github.com/eliran89c/klama/internal/llm"
+
 // SetSystemPrompt sets or updates the system prompt in the model's history.
 func (m *Model) SetSystemPrompt(prompt string) {
 	if len(m.History) == 0 {
@@ -39,7 +61,19 @@ func (m *Model) GuidedAsk(ctx context.Context, prompt string, maxAttempts int, r
 			return fmt.Errorf("failed to interact with the model: %w", err)
 		}
 
-		if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), result); err != nil {
+		msg := resp.Choices[0].Message
+		if parser, ok := result.(ToolCallParser); ok && len(m.Tools) > 0 {
+			if err := parser.ParseToolCalls(msg.Content, msg.ToolCalls); err != nil {
+				if attempt == maxAttempts {
+					return fmt.Errorf("failed to parse model tool calls after %d attempts: %w", maxAttempts, err)
+				}
+				prompt = fmt.Sprintf("Error: Failed to parse your tool call. The error was: %v\n\nOriginal prompt: %s\nDo not apologize or mention the error in your response", err, prompt)
+				continue
+			}
+			return nil
+		}
+
+		if err := json.Unmarshal([]byte(msg.Content), result); err != nil {
 			if attempt == maxAttempts {
 				return fmt.Errorf("failed to parse model response after %d attempts: %w", maxAttempts, err)
 			}
@@ -53,36 +87,205 @@ func (m *Model) GuidedAsk(ctx context.Context, prompt string, maxAttempts int, r
 	return fmt.Errorf("failed to get a valid response after %d attempts", maxAttempts)
 }
 
-// Ask sends a prompt to the model and returns the response.
+// Ask sends a prompt to the model and returns the response. The request is traced with
+// the model and its token counts. Responses without a usable message, such as filtered
+// or refused ones, are returned as errors, so the response always has a choice.
 func (m *Model) Ask(ctx context.Context, prompt string, temperature float64) (*ChatResponse, error) {
-	logger.Debugf("Asking model %s: %s\n", m.Name, prompt)
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "llm.Ask", trace.WithAttributes(
+		attribute.String("gen_ai.request.model", m.Name),
+	))
+	defer span.End()
+
+	chatResp, err := m.ask(ctx, prompt, temperature)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(
+		attribute.Int("gen_ai.usage.input_tokens", chatResp.Usage.PromptTokens),
+		attribute.Int("gen_ai.usage.output_tokens", chatResp.Usage.CompletionTokens),
+	)
+	return chatResp, nil
+}
+
+func (m *Model) ask(ctx context.Context, prompt string, temperature float64) (*ChatResponse, error) {
+	m.log().Debug("Asking model", "model", m.Name, "prompt", prompt)
 
-	data, err := json.Marshal(ChatRequest{
+	if err := m.checkBudget(); err != nil {
+		return nil, err
+	}
+
+	promptMessages := m.promptMessages(prompt)
+	if m.shouldCompact(promptMessages) {
+		if err := m.Compact(ctx); err != nil {
+			m.log().Warn("Failed to compact history", "model", m.Name, "error", err)
+		}
+		promptMessages = m.promptMessages(prompt)
+	}
+	if err := m.checkContextWindow(promptMessages); err != nil {
+		return nil, err
+	}
+
+	chatResp, err := m.send(ctx, ChatRequest{
 		Model:       m.Name,
-		Temperature: temperature,
-		Messages:    append(m.History, Message{Role: UserRole, Content: prompt}),
+		Temperature: &temperature,
+		Messages:    append(m.History, promptMessages...),
+		Tools:       m.Tools,
+	})
+	if errors.Is(err, ErrContextLengthExceeded) && m.compactable() {
+		// the provider rejected the conversation as too long, compact it and try once more
+		if cerr := m.Compact(ctx); cerr != nil {
+			return nil, fmt.Errorf("%w: %v", err, cerr)
+		}
+		promptMessages = m.promptMessages(prompt)
+		chatResp, err = m.send(ctx, ChatRequest{
+			Model:       m.Name,
+			Temperature: &temperature,
+			Messages:    append(m.History, promptMessages...),
+			Tools:       m.Tools,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(chatResp); err != nil {
+		// the provider bills the tokens of the response even when it is unusable
+		m.updateUsage(chatResp.Usage)
+		m.log().Warn("Model returned an unusable response", "model", m.Name, "error", err)
+		return nil, err
+	}
+	m.fillUsage(chatResp)
+
+	m.log().Debug("Model responded", "model", m.Name, "content", chatResp.Choices[0].Message.Content, "tool_calls", chatResp.Choices[0].Message.ToolCalls)
+
+	// Update the model's state with the response
+	m.History = append(m.History, promptMessages...)
+	m.updateUsage(chatResp.Usage)
+	m.lastContextTokens = chatResp.Usage.TotalTokens
+	m.lastReasoning = chatResp.Choices[0].Message.Thinking()
+	m.History = append(m.History, Message{
+		Role:      AssistantRole,
+		Content:   chatResp.Choices[0].Message.Content,
+		ToolCalls: chatResp.Choices[0].Message.ToolCalls,
 	})
+
+	return chatResp, nil
+}
+
+// send posts a chat request to the model and decodes the response, retrying transient
+// failures. When the provider rejects the temperature, the request is sent once more
+// without it and later requests omit it. It does not modify the model's history or usage.
+func (m *Model) send(ctx context.Context, chatReq ChatRequest) (*ChatResponse, error) {
+	m.applyOptions(&chatReq)
+
+	chatResp, err := m.sendWithRetries(ctx, chatReq)
+	if errors.Is(err, ErrTemperatureUnsupported) && chatReq.Temperature != nil {
+		m.log().Info("Model does not support the temperature parameter, omitting it", "model", m.Name)
+		m.OmitTemperature = true
+		chatReq.Temperature = nil
+		chatResp, err = m.sendWithRetries(ctx, chatReq)
+	}
+	return chatResp, err
+}
+
+// applyOptions adds the sampling, provider and reasoning options of the model to a
+// request. Reasoning models only accept their default temperature.
+func (m *Model) applyOptions(chatReq *ChatRequest) {
+	reasoning := m.ReasoningEffort != "" || m.ThinkingBudget > 0
+	if m.Temperature != nil {
+		chatReq.Temperature = m.Temperature
+	}
+	if m.OmitTemperature || reasoning {
+		chatReq.Temperature = nil
+	}
+	chatReq.TopP = m.TopP
+	chatReq.MaxTokens = m.MaxOutputTokens
+
+	if m.Provider == config.ProviderOpenRouter {
+		chatReq.Provider = m.Routing
+		chatReq.Usage = &UsageOptions{Include: true}
+		if reasoning {
+			chatReq.Reasoning = &ReasoningOptions{Effort: m.ReasoningEffort, MaxTokens: m.ThinkingBudget}
+		}
+		return
+	}
+
+	// reasoning models reject max_tokens, as the limit includes their reasoning
+	if reasoning {
+		chatReq.MaxTokens, chatReq.MaxCompletionTokens = 0, m.MaxOutputTokens
+	}
+	chatReq.ReasoningEffort = m.ReasoningEffort
+	if m.ThinkingBudget > 0 {
+		chatReq.Thinking = &ThinkingOptions{Type: "enabled", BudgetTokens: m.ThinkingBudget}
+	}
+}
+
+// sendWithRetries posts a chat request, retrying transient failures.
+func (m *Model) sendWithRetries(ctx context.Context, chatReq ChatRequest) (*ChatResponse, error) {
+	data, err := json.Marshal(chatReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal chat request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, bytes.NewBuffer(data))
+	maxAttempts := m.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		chatResp, err := m.post(ctx, data, len(chatReq.Tools) > 0)
+
+		var retryErr *retryableError
+		if !errors.As(err, &retryErr) {
+			return chatResp, err
+		}
+		if attempt >= maxAttempts || ctx.Err() != nil {
+			return nil, retryErr.err
+		}
+
+		delay := retryDelay(attempt, retryErr.retryAfter)
+		m.log().Warn("Model request failed, retrying", "model", m.Name, "attempt", attempt, "max_attempts", maxAttempts, "delay", delay, "error", retryErr.err)
+		if m.OnRetry != nil {
+			m.OnRetry(attempt+1, maxAttempts, retryErr.err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, retryErr.err
+		case <-timer.C:
+		}
+	}
+}
+
+// post sends a single request to the model. Failures worth retrying are wrapped in a
+// retryableError.
+func (m *Model) post(ctx context.Context, data []byte, withTools bool) (*ChatResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(m.AuthToken.Key, m.AuthToken.Value)
+	if err := m.authorize(ctx, req); err != nil {
+		return nil, err
+	}
 
 	resp, err := m.Client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		err = fmt.Errorf("failed to send request: %w", err)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &retryableError{err: err}
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, &retryableError{err: fmt.Errorf("failed to read response body: %w", err)}
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -96,29 +299,84 @@ func (m *Model) Ask(ctx context.Context, prompt string, temperature float64) (*C
 			errMsg = "model not found"
 		case http.StatusInternalServerError:
 			errMsg = "internal server error"
+		case http.StatusBadGateway:
+			errMsg = "bad gateway"
+		case http.StatusServiceUnavailable:
+			errMsg = "service unavailable"
 		case http.StatusBadRequest:
 			errMsg = "bad request"
 		default:
 			errMsg = fmt.Sprintf("unexpected status code %d", resp.StatusCode)
 		}
 
-		logger.Debugf("Model %s responded with status code %d: %s\n", m.Name, resp.StatusCode, body)
-		return nil, fmt.Errorf("%s (status code: %d)", errMsg, resp.StatusCode)
+		m.log().Warn("Model responded with an error status", "model", m.Name, "status", resp.StatusCode, "body", string(body))
+		apiErr := parseAPIError(body)
+		if apiErr.contentFiltered() {
+			return nil, fmt.Errorf("%w (status code: %d)", apiErr.err(), resp.StatusCode)
+		}
+		if apiErr != nil {
+			errMsg += ": " + apiErr.Message
+		}
+		if resp.StatusCode == http.StatusBadRequest {
+			lowerBody := strings.ToLower(string(body))
+			if isContextLengthError(lowerBody) {
+				return nil, fmt.Errorf("%w (status code: %d)", ErrContextLengthExceeded, resp.StatusCode)
+			}
+			if strings.Contains(lowerBody, "temperature") {
+				return nil, fmt.Errorf("%w (status code: %d)", ErrTemperatureUnsupported, resp.StatusCode)
+			}
+			if withTools && strings.Contains(lowerBody, "tool") {
+				return nil, fmt.Errorf("%w (status code: %d)", ErrToolsUnsupported, resp.StatusCode)
+			}
+		}
+
+		err := fmt.Errorf("%s (status code: %d)", errMsg, resp.StatusCode)
+		if isRetryableStatus(resp.StatusCode) {
+			return nil, &retryableError{err: err, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+		}
+		return nil, err
 	}
 
 	var chatResp ChatResponse
 	if err := json.Unmarshal(body, &chatResp); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal chat response: %w", err)
 	}
+	m.normalizeUsage(&chatResp)
+	if chatResp.Error != nil && chatResp.Error.Message != "" {
+		m.log().Warn("Model responded with an error", "model", m.Name, "body", string(body))
+		// errors of the upstream provider are often transient, unlike the content filter
+		if chatResp.Error.contentFiltered() {
+			return nil, chatResp.Error.err()
+		}
+		return nil, &retryableError{err: chatResp.Error.err()}
+	}
 
-	logger.Debugf("Model %s responded: %s\n", m.Name, chatResp.Choices[0].Message.Content)
+	return &chatResp, nil
+}
 
-	// Update the model's state with the response
-	m.addMessage(UserRole, prompt)
-	m.updateUsage(chatResp.Usage)
-	m.addMessage(AssistantRole, chatResp.Choices[0].Message.Content)
+// promptMessages builds the messages that carry the prompt. When the last assistant
+// message requested tool calls, the prompt is sent as the result of the first call
+// and any additional calls are answered as skipped.
+func (m *Model) promptMessages(prompt string) []Message {
+	if len(m.History) == 0 {
+		return []Message{{Role: UserRole, Content: prompt}}
+	}
 
-	return &chatResp, nil
+	last := m.History[len(m.History)-1]
+	if last.Role != AssistantRole || len(last.ToolCalls) == 0 {
+		return []Message{{Role: UserRole, Content: prompt}}
+	}
+
+	messages := make([]Message, 0, len(last.ToolCalls))
+	for i, call := range last.ToolCalls {
+		content := prompt
+		if i > 0 {
+			content = skippedToolCallMessage
+		}
+		messages = append(messages, Message{Role: ToolRole, Content: content, ToolCallID: call.ID})
+	}
+
+	return messages
 }
 
 func (m *Model) addMessage(role Role, content string) {
@@ -126,16 +384,77 @@ func (m *Model) addMessage(role Role, content string) {
 }
 
 func (m *Model) updateUsage(usage Usage) {
-	m.Usage.TotalTokens += usage.TotalTokens
-	m.Usage.PromptTokens += usage.PromptTokens
-	m.Usage.CompletionTokens += usage.CompletionTokens
+	m.Usage = m.Usage.Add(usage)
+}
+
+// LastReasoning returns the reasoning of the most recent response, empty when the
+// provider returned none.
+func (m *Model) LastReasoning() string {
+	return m.lastReasoning
+}
+
+// Cost returns the total price of the model's usage.
+func (m *Model) Cost() float64 {
+	return m.Price(m.Usage)
+}
+
+// Price returns the price of the given usage: the cost billed by the provider when it
+// reports one, otherwise the price with the model's pricing.
+func (m *Model) Price(usage Usage) float64 {
+	if usage.Cost > 0 {
+		return usage.Cost
+	}
+	inputPrice, outputPrice := m.usagePrices(usage)
+	return inputPrice + outputPrice
+}
+
+// BudgetLeft returns the tokens and spend left in the session budget, -1 for the limits
+// that are not set.
+func (m *Model) BudgetLeft() (tokens int, cost float64) {
+	tokens, cost = -1, -1
+	if m.MaxTokens > 0 {
+		tokens = max(m.MaxTokens-m.Usage.TotalTokens, 0)
+	}
+	if m.MaxCost > 0 {
+		cost = max(m.MaxCost-m.Cost(), 0)
+	}
+	return tokens, cost
 }
 
 // LogUsage returns a string representation of the model's usage statistics.
 func (m *Model) LogUsage() string {
-	inputPrice := m.InputPrice * float64(m.Usage.PromptTokens) / 1000
-	outputPrice := m.OutputPrice * float64(m.Usage.CompletionTokens) / 1000
+	var usage string
+	if m.Usage.Cost > 0 {
+		usage = fmt.Sprintf("%s: %.4f$ billed for input(%d) and output(%d)",
+			m.Name, m.Usage.Cost, m.Usage.PromptTokens, m.Usage.CompletionTokens)
+	} else {
+		inputPrice, outputPrice := m.usagePrices(m.Usage)
+		usage = fmt.Sprintf("%s: %.4f$ for input(%d), %.4f$ for output(%d), %.4f$ in total",
+			m.Name, inputPrice, m.Usage.PromptTokens, outputPrice, m.Usage.CompletionTokens, inputPrice+outputPrice)
+	}
 
-	return fmt.Sprintf("%s: %.4f$ for input(%d), %.4f$ for output(%d)",
-		m.Name, inputPrice, m.Usage.PromptTokens, outputPrice, m.Usage.CompletionTokens)
+	var remaining []string
+	tokensLeft, costLeft := m.BudgetLeft()
+	if costLeft >= 0 {
+		remaining = append(remaining, fmt.Sprintf("%.4f$", costLeft))
+	}
+	if tokensLeft >= 0 {
+		remaining = append(remaining, fmt.Sprintf("%d tokens", tokensLeft))
+	}
+	if len(remaining) > 0 {
+		usage += ", budget left: " + strings.Join(remaining, ", ")
+	}
+
+	return usage
+}
+
+// checkBudget returns ErrBudgetExceeded once the session token or spend budget is used up.
+func (m *Model) checkBudget() error {
+	if m.MaxTokens > 0 && m.Usage.TotalTokens >= m.MaxTokens {
+		return fmt.Errorf("%w: used %d of %d tokens", ErrBudgetExceeded, m.Usage.TotalTokens, m.MaxTokens)
+	}
+	if m.MaxCost > 0 && m.Cost() >= m.MaxCost {
+		return fmt.Errorf("%w: spent %.4f$ of %.4f$", ErrBudgetExceeded, m.Cost(), m.MaxCost)
+	}
+	return nil
 }
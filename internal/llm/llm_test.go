@@ -8,6 +8,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestSetSystemPrompt(t *testing.T) {
@@ -49,6 +53,32 @@ func TestAsk(t *testing.T) {
 	assert.Equal(t, 5, resp.Usage.CompletionTokens)
 }
 
+func TestAsk_Trace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"content":"Test response"}}],"usage":{"total_tokens":10,"prompt_tokens":7,"completion_tokens":3}}`))
+	}))
+	defer server.Close()
+
+	model := &Model{Client: server.Client(), URL: server.URL, Name: "test-model", AuthToken: AuthToken{Key: "Authorization", Value: "Bearer test-token"}}
+	_, err := model.Ask(context.Background(), "Test prompt", 0.5)
+	assert.NoError(t, err)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "llm.Ask", spans[0].Name())
+		assert.ElementsMatch(t, []attribute.KeyValue{
+			attribute.String("gen_ai.request.model", "test-model"),
+			attribute.Int("gen_ai.usage.input_tokens", 7),
+			attribute.Int("gen_ai.usage.output_tokens", 3),
+		}, spans[0].Attributes())
+	}
+}
+
 func TestLogUsage(t *testing.T) {
 	model := &Model{
 		Name:        "test-model",
@@ -65,6 +95,7 @@ func TestLogUsage(t *testing.T) {
 	assert.Contains(t, usage, "test-model")
 	assert.Contains(t, usage, "0.0005$")
 	assert.Contains(t, usage, "0.0010$")
+	assert.InDelta(t, 0.0015, model.Cost(), 1e-9)
 }
 
 func TestAddMessage(t *testing.T) {
@@ -182,3 +213,105 @@ func TestModel_GuidedAsk(t *testing.T) {
 		})
 	}
 }
+
+func TestAsk_ToolCalls(t *testing.T) {
+	var lastRequest ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&lastRequest)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"run_command","arguments":"{}"}},{"id":"call_2","type":"function","function":{"name":"run_command","arguments":"{}"}}]}}]}`))
+	}))
+	defer server.Close()
+
+	model := &Model{
+		Client: server.Client(),
+		URL:    server.URL,
+		Tools:  []Tool{{Type: "function", Function: ToolFunction{Name: "run_command", Parameters: json.RawMessage(`{}`)}}},
+		AuthToken: AuthToken{
+			Key:   "test-header",
+			Value: "test-token",
+		},
+	}
+
+	_, err := model.Ask(context.Background(), "first prompt", 0)
+	assert.NoError(t, err)
+	assert.Len(t, lastRequest.Tools, 1)
+	assert.Len(t, model.History[len(model.History)-1].ToolCalls, 2)
+
+	// the next prompt answers the pending tool calls
+	_, err = model.Ask(context.Background(), "command output", 0)
+	assert.NoError(t, err)
+
+	messages := lastRequest.Messages
+	assert.Equal(t, Message{Role: ToolRole, Content: "command output", ToolCallID: "call_1"}, messages[len(messages)-2])
+	assert.Equal(t, Message{Role: ToolRole, Content: skippedToolCallMessage, ToolCallID: "call_2"}, messages[len(messages)-1])
+}
+
+func TestAsk_ToolsUnsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"\"auto\" tool choice requires --enable-auto-tool-choice"}}`))
+	}))
+	defer server.Close()
+
+	model := &Model{
+		Client: server.Client(),
+		URL:    server.URL,
+		Tools:  []Tool{{Type: "function", Function: ToolFunction{Name: "run_command", Parameters: json.RawMessage(`{}`)}}},
+		AuthToken: AuthToken{
+			Key:   "test-header",
+			Value: "test-token",
+		},
+	}
+
+	_, err := model.Ask(context.Background(), "Test prompt", 0)
+	assert.ErrorIs(t, err, ErrToolsUnsupported)
+	assert.Empty(t, model.History)
+}
+
+func TestAskBudget(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}],"usage":{"total_tokens":1000,"prompt_tokens":800,"completion_tokens":200}}`))
+	}))
+	defer server.Close()
+
+	t.Run("tokens", func(t *testing.T) {
+		requests = 0
+		model := newCompactionModel(server)
+		model.MaxTokens = 1500
+
+		_, err := model.Ask(context.Background(), "first", 0)
+		assert.NoError(t, err)
+		assert.Contains(t, model.LogUsage(), "budget left: 500 tokens")
+
+		_, err = model.Ask(context.Background(), "second", 0)
+		assert.NoError(t, err)
+		assert.Contains(t, model.LogUsage(), "budget left: 0 tokens")
+
+		_, err = model.Ask(context.Background(), "third", 0)
+		assert.ErrorIs(t, err, ErrBudgetExceeded)
+		assert.EqualError(t, err, "session budget exceeded: used 2000 of 1500 tokens")
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("cost", func(t *testing.T) {
+		requests = 0
+		model := newCompactionModel(server)
+		model.InputPrice = 0.01
+		model.OutputPrice = 0.02
+		model.MaxCost = 0.02
+
+		_, err := model.Ask(context.Background(), "first", 0)
+		assert.NoError(t, err)
+		assert.Contains(t, model.LogUsage(), "budget left: 0.0080$")
+
+		_, err = model.Ask(context.Background(), "second", 0)
+		assert.NoError(t, err)
+
+		_, err = model.Ask(context.Background(), "third", 0)
+		assert.ErrorIs(t, err, ErrBudgetExceeded)
+		assert.Equal(t, 2, requests)
+		assert.NotContains(t, (&Model{}).LogUsage(), "budget left")
+	})
+}
@@ -1,10 +1,16 @@
 package llm
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"reflect"
+	"time"
 
 	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/logger"
 )
 
 // Model represents a language model and its associated data.
@@ -17,6 +23,50 @@ type Model struct {
 	OutputPrice float64 // price per 1K output tokens
 	History     []Message
 	Usage       Usage
+	Tools       []Tool // tools offered to the model on every request
+	NativeTools bool   // whether the provider supports native tool calling
+
+	ContextWindow    int     // maximum number of tokens the model accepts, 0 disables compaction
+	CompactThreshold float64 // fraction of the context window that triggers compaction
+
+	TokenSource TokenSource // when set, requests use its bearer token instead of AuthToken
+
+	Temperature     *float64 // replaces the temperature of every request, nil keeps it
+	TopP            *float64 // nucleus sampling, nil uses the provider default
+	MaxOutputTokens int      // tokens of each response, 0 uses the provider default
+
+	ReasoningEffort string // reasoning effort of reasoning models, empty for the model default
+	ThinkingBudget  int    // tokens the model may think before answering, 0 disables extended thinking
+	OmitTemperature bool   // whether requests omit the temperature, which reasoning models reject
+
+	Provider string                    // provider with specific behavior, such as config.ProviderOpenRouter
+	Routing  *config.OpenRouterRouting // OpenRouter provider routing preferences, nil for the default routing
+
+	MaxAttempts int                                       // attempts per request on transient failures, 0 uses DefaultMaxAttempts
+	OnRetry     func(attempt, maxAttempts int, err error) // called before a failed request is retried
+
+	CachedInputPrice float64 // price per 1K prompt tokens read from the cache, 0 uses InputPrice
+	CacheWritePrice  float64 // price per 1K prompt tokens written to the cache, 0 uses InputPrice
+	ReasoningPrice   float64 // price per 1K reasoning tokens, 0 uses OutputPrice
+
+	UnknownPricing bool // whether the configuration sets no pricing and the model is not in the pricing table
+
+	UsageNormalizer UsageNormalizer // reads the usage of responses, nil detects the provider's format
+
+	MaxTokens int     // session token budget, 0 means unlimited
+	MaxCost   float64 // session spend budget in USD, 0 means unlimited
+
+	Logger *slog.Logger // receives the requests and responses of the model, nil discards them
+
+	lastContextTokens int    // tokens used by the most recent request and its response
+	lastReasoning     string // reasoning of the most recent response
+
+	tokenCache map[string]int // tokens of message contents, so estimates only count new messages
+}
+
+// log returns the logger of the model.
+func (m *Model) log() *slog.Logger {
+	return logger.Or(m.Logger)
 }
 
 // AuthToken represents the authentication token for the model.
@@ -25,6 +75,21 @@ type AuthToken struct {
 	Value string
 }
 
+// TokenSource returns the bearer token of a request, such as a Microsoft Entra ID token
+// that is renewed before it expires.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// failedTokenSource reports a credential that could not be created on every request.
+type failedTokenSource struct {
+	err error
+}
+
+func (s failedTokenSource) Token(context.Context) (string, error) {
+	return "", s.err
+}
+
 // NewModel creates a new Model instance.
 func NewModel(client *http.Client, modelConfig config.ModelConfig) *Model {
 	auth := AuthToken{
@@ -46,13 +111,78 @@ func NewModel(client *http.Client, modelConfig config.ModelConfig) *Model {
 		auth.Value = modelConfig.AuthToken
 	}
 
+	var tokenSource TokenSource
+	if modelConfig.AzureAuth != "" && modelConfig.AzureAuth != config.AzureAuthAPIKey {
+		credential, err := newAzureCredential(modelConfig)
+		if err != nil {
+			tokenSource = failedTokenSource{err: fmt.Errorf("failed to create Azure credential: %w", err)}
+		} else {
+			tokenSource = &azureTokenSource{credential: credential, now: time.Now}
+		}
+	}
+
+	var routing *config.OpenRouterRouting
+	if modelConfig.Provider == config.ProviderOpenRouter && !reflect.DeepEqual(modelConfig.OpenRouter.Routing, config.OpenRouterRouting{}) {
+		routing = &modelConfig.OpenRouter.Routing
+	}
+
+	pricing := modelConfig.Pricing
+	unknownPricing := false
+	if pricing == (config.Pricing{}) && billsByPricing(modelConfig.Provider) {
+		var known bool
+		pricing, known = LookupPricing(modelConfig.Name)
+		unknownPricing = !known
+	}
+
 	return &Model{
 		Client:      client,
 		Name:        modelConfig.Name,
 		URL:         modelURL,
 		AuthToken:   auth,
-		InputPrice:  modelConfig.Pricing.Input,
-		OutputPrice: modelConfig.Pricing.Output,
+		TokenSource: tokenSource,
+		Provider:    modelConfig.Provider,
+		Routing:     routing,
+		InputPrice:  pricing.Input,
+		OutputPrice: pricing.Output,
 		History:     []Message{},
+		NativeTools: !modelConfig.DisableTools,
+
+		Temperature:     modelConfig.Temperature,
+		TopP:            modelConfig.TopP,
+		MaxOutputTokens: modelConfig.MaxTokens,
+
+		ReasoningEffort: modelConfig.ReasoningEffort,
+		ThinkingBudget:  modelConfig.ThinkingBudget,
+		OmitTemperature: modelConfig.DisableTemperature,
+
+		CachedInputPrice: pricing.CachedInput,
+		CacheWritePrice:  pricing.CacheWrite,
+		ReasoningPrice:   pricing.Reasoning,
+		UnknownPricing:   unknownPricing,
+
+		ContextWindow:    modelConfig.ContextWindow,
+		CompactThreshold: modelConfig.CompactThreshold,
+		MaxAttempts:      modelConfig.MaxAttempts,
+	}
+}
+
+// billsByPricing reports whether the cost of a provider is computed from the pricing,
+// unlike OpenRouter, which reports the billed cost, and the scripted mock provider.
+func billsByPricing(provider string) bool {
+	return provider != config.ProviderOpenRouter && provider != config.ProviderMock
+}
+
+// authorize sets the authentication header of a request.
+func (m *Model) authorize(ctx context.Context, req *http.Request) error {
+	if m.TokenSource == nil {
+		req.Header.Set(m.AuthToken.Key, m.AuthToken.Value)
+		return nil
+	}
+
+	token, err := m.TokenSource.Token(ctx)
+	if err != nil {
+		return err
 	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
 }
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_OpenRouter(t *testing.T) {
+	var (
+		header  http.Header
+		request map[string]any
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}],"usage":{"prompt_tokens":1000,"completion_tokens":100,"total_tokens":1100,"cost":0.0123}}`))
+	}))
+	defer server.Close()
+
+	allowFallbacks := false
+	modelConfig := config.ModelConfig{
+		Name:     "anthropic/claude-3.5-sonnet",
+		BaseURL:  server.URL,
+		Provider: config.ProviderOpenRouter,
+		Pricing:  config.Pricing{Input: 1, Output: 1},
+		Headers:  map[string]string{"x-title": "platform-klama"},
+		OpenRouter: config.OpenRouterConfig{
+			Routing: config.OpenRouterRouting{Order: []string{"anthropic"}, AllowFallbacks: &allowFallbacks},
+		},
+	}
+	client, err := NewHTTPClient(modelConfig)
+	require.NoError(t, err)
+	model := NewModel(client, modelConfig)
+
+	resp, err := model.Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://github.com/eliran89c/klama", header.Get("HTTP-Referer"))
+	assert.Equal(t, "platform-klama", header.Get("X-Title"))
+	assert.Equal(t, map[string]any{"order": []any{"anthropic"}, "allow_fallbacks": false}, request["provider"])
+	assert.Equal(t, map[string]any{"include": true}, request["usage"])
+
+	// the billed cost replaces the configured pricing
+	assert.InDelta(t, 0.0123, model.Price(resp.Usage), 1e-9)
+	assert.InDelta(t, 0.0123, model.Cost(), 1e-9)
+	assert.Equal(t, "anthropic/claude-3.5-sonnet: 0.0123$ billed for input(1000) and output(100)", model.LogUsage())
+}
+
+func TestModel_WithoutProvider(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	model := NewModel(server.Client(), config.ModelConfig{BaseURL: server.URL})
+	_, err := model.Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+	assert.NotContains(t, request, "provider")
+	assert.NotContains(t, request, "usage")
+}
@@ -0,0 +1,45 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ErrUnauthorized is returned by Ping when the endpoint rejects the auth token.
+var ErrUnauthorized = fmt.Errorf("the endpoint rejected the auth token")
+
+// Ping checks that the model endpoint is reachable and accepts the auth token, without
+// using any tokens. It sends a chat request without messages, which providers reject as
+// a bad request only after the token was accepted.
+func (m *Model) Ping(ctx context.Context) error {
+	data, err := json.Marshal(ChatRequest{Model: m.Name, Messages: []Message{}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := m.authorize(ctx, req); err != nil {
+		return err
+	}
+
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("endpoint is not reachable: %w", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w (status code: %d)", ErrUnauthorized, resp.StatusCode)
+	case http.StatusNotFound:
+		return fmt.Errorf("endpoint or model not found, check base_url and name (status code: %d)", resp.StatusCode)
+	}
+	return nil
+}
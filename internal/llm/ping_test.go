@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_Ping(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr error
+		errText string
+	}{
+		{"Bad request means the token was accepted", http.StatusBadRequest, nil, ""},
+		{"Unauthorized", http.StatusUnauthorized, ErrUnauthorized, ""},
+		{"Forbidden", http.StatusForbidden, ErrUnauthorized, ""},
+		{"Not found", http.StatusNotFound, nil, "endpoint or model not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/chat/completions", r.URL.Path)
+				assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			model := NewModel(server.Client(), config.ModelConfig{Name: "gpt-4o", BaseURL: server.URL, AuthToken: "test-token"})
+			err := model.Ping(context.Background())
+
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.errText != "":
+				assert.ErrorContains(t, err, tt.errText)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	model := NewModel(http.DefaultClient, config.ModelConfig{Name: "gpt-4o", BaseURL: "http://127.0.0.1:1"})
+	assert.ErrorContains(t, model.Ping(context.Background()), "endpoint is not reachable")
+}
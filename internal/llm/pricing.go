@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/eliran89c/klama/config"
+)
+
+// knownPricing holds the list prices in USD per 1K tokens of common models, used when
+// the configuration sets no pricing. Dated and provider prefixed names, such as
+// gpt-4o-2024-08-06 or anthropic/claude-3.5-sonnet, match the entry of their model.
+var knownPricing = map[string]config.Pricing{
+	// OpenAI
+	"gpt-5":         {Input: 0.00125, Output: 0.01, CachedInput: 0.000125},
+	"gpt-5-mini":    {Input: 0.00025, Output: 0.002, CachedInput: 0.000025},
+	"gpt-5-nano":    {Input: 0.00005, Output: 0.0004, CachedInput: 0.000005},
+	"gpt-4.1":       {Input: 0.002, Output: 0.008, CachedInput: 0.0005},
+	"gpt-4.1-mini":  {Input: 0.0004, Output: 0.0016, CachedInput: 0.0001},
+	"gpt-4.1-nano":  {Input: 0.0001, Output: 0.0004, CachedInput: 0.000025},
+	"gpt-4o":        {Input: 0.0025, Output: 0.01, CachedInput: 0.00125},
+	"gpt-4o-mini":   {Input: 0.00015, Output: 0.0006, CachedInput: 0.000075},
+	"gpt-4-turbo":   {Input: 0.01, Output: 0.03},
+	"gpt-4":         {Input: 0.03, Output: 0.06},
+	"gpt-3.5-turbo": {Input: 0.0005, Output: 0.0015},
+	"o1":            {Input: 0.015, Output: 0.06, CachedInput: 0.0075},
+	"o1-mini":       {Input: 0.0011, Output: 0.0044, CachedInput: 0.00055},
+	"o3":            {Input: 0.002, Output: 0.008, CachedInput: 0.0005},
+	"o3-mini":       {Input: 0.0011, Output: 0.0044, CachedInput: 0.00055},
+	"o4-mini":       {Input: 0.0011, Output: 0.0044, CachedInput: 0.000275},
+
+	// Anthropic
+	"claude-opus-4.5":   {Input: 0.005, Output: 0.025, CachedInput: 0.0005, CacheWrite: 0.00625},
+	"claude-haiku-4.5":  {Input: 0.001, Output: 0.005, CachedInput: 0.0001, CacheWrite: 0.00125},
+	"claude-opus-4":     {Input: 0.015, Output: 0.075, CachedInput: 0.0015, CacheWrite: 0.01875},
+	"claude-sonnet-4":   {Input: 0.003, Output: 0.015, CachedInput: 0.0003, CacheWrite: 0.00375},
+	"claude-3.7-sonnet": {Input: 0.003, Output: 0.015, CachedInput: 0.0003, CacheWrite: 0.00375},
+	"claude-3.5-sonnet": {Input: 0.003, Output: 0.015, CachedInput: 0.0003, CacheWrite: 0.00375},
+	"claude-3.5-haiku":  {Input: 0.0008, Output: 0.004, CachedInput: 0.00008, CacheWrite: 0.001},
+	"claude-3-opus":     {Input: 0.015, Output: 0.075, CachedInput: 0.0015, CacheWrite: 0.01875},
+	"claude-3-haiku":    {Input: 0.00025, Output: 0.00125, CachedInput: 0.00003, CacheWrite: 0.0003},
+
+	// Google
+	"gemini-2.5-pro":   {Input: 0.00125, Output: 0.01},
+	"gemini-2.5-flash": {Input: 0.0003, Output: 0.0025},
+	"gemini-2.0-flash": {Input: 0.0001, Output: 0.0004},
+	"gemini-1.5-pro":   {Input: 0.00125, Output: 0.005},
+	"gemini-1.5-flash": {Input: 0.000075, Output: 0.0003},
+
+	// DeepSeek
+	"deepseek-chat":     {Input: 0.00027, Output: 0.0011, CachedInput: 0.00007},
+	"deepseek-reasoner": {Input: 0.00055, Output: 0.00219, CachedInput: 0.00014},
+}
+
+// versionDot matches the dot of versions such as 3.5, which other names spell 3-5.
+var versionDot = regexp.MustCompile(`(\d)\.(\d)`)
+
+// LookupPricing returns the list price of a model from the built-in pricing table.
+// The longest entry that the name contains as a whole word wins, so gpt-4o-mini is not
+// priced as gpt-4o.
+func LookupPricing(name string) (config.Pricing, bool) {
+	name = normalizeModelName(name)
+
+	var (
+		best    config.Pricing
+		bestLen int
+	)
+	for model, pricing := range knownPricing {
+		model = normalizeModelName(model)
+		if len(model) > bestLen && containsModel(name, model) {
+			best, bestLen = pricing, len(model)
+		}
+	}
+	return best, bestLen > 0
+}
+
+// normalizeModelName lowercases a model name and spells its versions with dashes.
+func normalizeModelName(name string) string {
+	return versionDot.ReplaceAllString(strings.ToLower(name), "$1-$2")
+}
+
+// containsModel reports whether name contains model, starting at a separator such as
+// the / of OpenRouter or the . of Bedrock and ending at a separator or the end.
+func containsModel(name, model string) bool {
+	for start := 0; start <= len(name)-len(model); start++ {
+		if !strings.HasPrefix(name[start:], model) {
+			continue
+		}
+		end := start + len(model)
+		if (start == 0 || isNameSeparator(name[start-1])) && (end == len(name) || isNameSeparator(name[end])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNameSeparator(c byte) bool {
+	return strings.IndexByte("/.:-@_", c) >= 0
+}
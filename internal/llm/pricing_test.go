@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupPricing(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string // entry of the pricing table, empty when unknown
+	}{
+		{name: "gpt-4o", expected: "gpt-4o"},
+		{name: "gpt-4o-2024-08-06", expected: "gpt-4o"},
+		{name: "gpt-4o-mini", expected: "gpt-4o-mini"},
+		{name: "openai/gpt-4o-mini", expected: "gpt-4o-mini"},
+		{name: "gpt-4.1", expected: "gpt-4.1"},
+		{name: "gpt-4", expected: "gpt-4"},
+		{name: "gpt-4-turbo-2024-04-09", expected: "gpt-4-turbo"},
+		{name: "claude-3-5-sonnet-20241022", expected: "claude-3.5-sonnet"},
+		{name: "anthropic/claude-3.5-sonnet", expected: "claude-3.5-sonnet"},
+		{name: "us.anthropic.claude-3-5-sonnet-20241022-v2:0", expected: "claude-3.5-sonnet"},
+		{name: "claude-opus-4-5-20251101", expected: "claude-opus-4.5"},
+		{name: "claude-opus-4-1", expected: "claude-opus-4"},
+		{name: "GPT-4o", expected: "gpt-4o"},
+		{name: "llama3.1"},
+		{name: "prod-gpt-4o-eu", expected: "gpt-4o"},
+		{name: "gpt-4o1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pricing, ok := LookupPricing(tt.name)
+			if tt.expected == "" {
+				assert.False(t, ok)
+				return
+			}
+			assert.True(t, ok)
+			assert.Equal(t, knownPricing[tt.expected], pricing)
+		})
+	}
+}
+
+func TestNewModel_Pricing(t *testing.T) {
+	t.Run("looked up without pricing", func(t *testing.T) {
+		model := NewModel(http.DefaultClient, config.ModelConfig{Name: "gpt-4o"})
+		assert.Equal(t, 0.0025, model.InputPrice)
+		assert.Equal(t, 0.01, model.OutputPrice)
+		assert.Equal(t, 0.00125, model.CachedInputPrice)
+		assert.False(t, model.UnknownPricing)
+	})
+
+	t.Run("configured pricing wins", func(t *testing.T) {
+		model := NewModel(http.DefaultClient, config.ModelConfig{Name: "gpt-4o", Pricing: config.Pricing{Input: 1}})
+		assert.Equal(t, 1.0, model.InputPrice)
+		assert.Zero(t, model.OutputPrice)
+		assert.False(t, model.UnknownPricing)
+	})
+
+	t.Run("unknown model", func(t *testing.T) {
+		model := NewModel(http.DefaultClient, config.ModelConfig{Name: "llama3.1"})
+		assert.Zero(t, model.InputPrice)
+		assert.True(t, model.UnknownPricing)
+	})
+
+	t.Run("openrouter reports the billed cost", func(t *testing.T) {
+		model := NewModel(http.DefaultClient, config.ModelConfig{Name: "vendor/unknown", Provider: config.ProviderOpenRouter})
+		assert.False(t, model.UnknownPricing)
+	})
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_Reasoning(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok","reasoning_content":"The pod restarts, check the logs."}}]}`))
+	}))
+	defer server.Close()
+
+	model := NewModel(server.Client(), config.ModelConfig{BaseURL: server.URL, ReasoningEffort: "high", ThinkingBudget: 2048})
+	_, err := model.Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+
+	assert.NotContains(t, request, "temperature")
+	assert.Equal(t, "high", request["reasoning_effort"])
+	assert.Equal(t, map[string]any{"type": "enabled", "budget_tokens": float64(2048)}, request["thinking"])
+	assert.Equal(t, "The pod restarts, check the logs.", model.LastReasoning())
+	assert.Empty(t, model.History[len(model.History)-1].Thinking(), "reasoning is not sent back")
+
+	// OpenRouter takes a reasoning object instead
+	model = NewModel(server.Client(), config.ModelConfig{BaseURL: server.URL, Provider: config.ProviderOpenRouter, ReasoningEffort: "low"})
+	_, err = model.Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"effort": "low"}, request["reasoning"])
+	assert.NotContains(t, request, "reasoning_effort")
+}
+
+func TestModel_Sampling(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	// the request temperature is sent unless the model sets one
+	model := NewModel(server.Client(), config.ModelConfig{BaseURL: server.URL})
+	_, err := model.Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), request["temperature"])
+	assert.NotContains(t, request, "top_p")
+	assert.NotContains(t, request, "max_tokens")
+
+	temperature, topP := 0.7, 0.9
+	model = NewModel(server.Client(), config.ModelConfig{BaseURL: server.URL, Temperature: &temperature, TopP: &topP, MaxTokens: 1024})
+	_, err = model.Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0.7, request["temperature"])
+	assert.Equal(t, 0.9, request["top_p"])
+	assert.Equal(t, float64(1024), request["max_tokens"])
+
+	// reasoning models take max_completion_tokens
+	model = NewModel(server.Client(), config.ModelConfig{BaseURL: server.URL, MaxTokens: 1024, ReasoningEffort: "low"})
+	_, err = model.Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+	assert.NotContains(t, request, "max_tokens")
+	assert.Equal(t, float64(1024), request["max_completion_tokens"])
+}
+
+func TestModel_TemperatureUnsupported(t *testing.T) {
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		requests = append(requests, request)
+		if _, ok := request["temperature"]; ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"Unsupported value: 'temperature' does not support 0 with this model."}}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	model := NewModel(server.Client(), config.ModelConfig{BaseURL: server.URL})
+	resp, err := model.Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.Choices[0].Message.Content)
+	assert.Len(t, requests, 2)
+	assert.True(t, model.OmitTemperature)
+
+	// later requests omit the temperature right away
+	_, err = model.Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+	assert.Len(t, requests, 3)
+}
@@ -0,0 +1,188 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Exchange is a recorded chat request and the response of the model.
+type Exchange struct {
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Request  json.RawMessage `json:"request"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response"`
+}
+
+// Recorder saves every request and its response to a directory, one numbered JSON file
+// per exchange. Headers are not saved, so the files never contain auth tokens.
+type Recorder struct {
+	dir string
+
+	mu    sync.Mutex
+	count int
+}
+
+// NewRecorder returns a Recorder that saves to the directory. A second recording into
+// the same directory continues its numbering.
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	files, err := exchangeFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{dir: dir, count: len(files)}, nil
+}
+
+// Wrap returns a transport that sends requests with next, or the default transport when
+// next is nil, and records them. Transports of several clients share the numbering.
+func (r *Recorder) Wrap(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &recordingTransport{recorder: r, next: next}
+}
+
+type recordingTransport struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		if requestBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	responseBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	exchange := Exchange{
+		Method:   req.Method,
+		URL:      req.URL.Redacted(),
+		Request:  rawJSON(requestBody),
+		Status:   resp.StatusCode,
+		Response: rawJSON(responseBody),
+	}
+	if err := t.recorder.save(exchange); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Recorder) save(exchange Exchange) error {
+	data, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal recorded exchange: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.count++
+	path := filepath.Join(r.dir, fmt.Sprintf("%04d.json", r.count))
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to record exchange: %w", err)
+	}
+	return nil
+}
+
+// Replayer is an http.RoundTripper that answers requests with the responses saved by a
+// Recorder, in order, without network calls.
+type Replayer struct {
+	mu        sync.Mutex
+	exchanges []Exchange
+	next      int
+}
+
+// NewReplayer loads the exchanges recorded in a directory.
+func NewReplayer(dir string) (*Replayer, error) {
+	files, err := exchangeFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no recorded exchanges in %s", dir)
+	}
+
+	exchanges := make([]Exchange, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recorded exchange: %w", err)
+		}
+		var exchange Exchange
+		if err := json.Unmarshal(data, &exchange); err != nil {
+			return nil, fmt.Errorf("failed to parse recorded exchange %s: %w", file, err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	return &Replayer{exchanges: exchanges}, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next >= len(r.exchanges) {
+		return nil, fmt.Errorf("replay has no more recorded responses, all %d were used", len(r.exchanges))
+	}
+	exchange := r.exchanges[r.next]
+	r.next++
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.Status, http.StatusText(exchange.Status)),
+		StatusCode:    exchange.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(exchange.Response)),
+		ContentLength: int64(len(exchange.Response)),
+		Request:       req,
+	}, nil
+}
+
+// exchangeFiles returns the recorded exchanges of a directory in order.
+func exchangeFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "[0-9][0-9][0-9][0-9]*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// rawJSON returns the body as JSON, or as a JSON string when it is not valid JSON.
+func rawJSON(body []byte) json.RawMessage {
+	if json.Valid(body) {
+		return body
+	}
+	quoted, _ := json.Marshal(string(body))
+	return quoted
+}
@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReplay(t *testing.T) {
+	responses := []string{
+		`{"choices":[{"message":{"content":"first"}}],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}`,
+		`{"choices":[{"message":{"content":"second"}}]}`,
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(responses[requests]))
+		requests++
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "recording")
+	recorder, err := NewRecorder(dir)
+	require.NoError(t, err)
+
+	modelConfig := config.ModelConfig{Name: "test-model", BaseURL: server.URL, AuthToken: "secret-token"}
+	model := NewModel(&http.Client{Transport: recorder.Wrap(nil)}, modelConfig)
+	for _, want := range []string{"first", "second"} {
+		resp, err := model.Ask(context.Background(), "hi", 0)
+		require.NoError(t, err)
+		assert.Equal(t, want, resp.Choices[0].Message.Content)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"content": "hi"`)
+	assert.NotContains(t, string(data), "secret-token")
+
+	// the replay answers in order without the server
+	server.Close()
+	replayer, err := NewReplayer(dir)
+	require.NoError(t, err)
+	replayed := NewModel(&http.Client{Transport: replayer}, modelConfig)
+	replayed.MaxAttempts = 1
+
+	resp, err := replayed.Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "first", resp.Choices[0].Message.Content)
+	assert.Equal(t, 12, replayed.Usage.TotalTokens)
+
+	resp, err = replayed.Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "second", resp.Choices[0].Message.Content)
+
+	_, err = replayed.Ask(context.Background(), "hi", 0)
+	assert.ErrorContains(t, err, "no more recorded responses")
+}
+
+func TestNewReplayer_Empty(t *testing.T) {
+	_, err := NewReplayer(t.TempDir())
+	assert.ErrorContains(t, err, "no recorded exchanges")
+}
+
+func TestRecorder_ContinuesNumbering(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0001.json"), []byte(`{}`), 0600))
+
+	recorder, err := NewRecorder(dir)
+	require.NoError(t, err)
+	require.NoError(t, recorder.save(Exchange{Status: http.StatusOK}))
+	assert.FileExists(t, filepath.Join(dir, "0002.json"))
+}
@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ErrEmptyResponse is returned when the provider answers without a message.
+var ErrEmptyResponse = fmt.Errorf("model returned an empty response")
+
+// ErrContentFiltered is returned when the content filter of the provider, such as the
+// content management policy of Azure OpenAI, blocks the prompt or the response.
+var ErrContentFiltered = fmt.Errorf("the provider's content filter blocked the request")
+
+// ErrRefused is returned when the model refuses to answer.
+var ErrRefused = fmt.Errorf("model refused to answer")
+
+// Finish reasons of a choice that do not end with an answer
+const (
+	finishContentFilter = "content_filter"
+	finishLength        = "length"
+)
+
+// APIError is the error object providers return in the body of failed requests, and
+// some providers, such as OpenRouter, in responses with a 200 status.
+type APIError struct {
+	Message string          `json:"message"`
+	Type    string          `json:"type"`
+	Code    json.RawMessage `json:"code"` // a string or a number, depending on the provider
+}
+
+// contentFiltered reports whether the content filter of the provider caused the error.
+func (e *APIError) contentFiltered() bool {
+	return e != nil && (strings.Trim(string(e.Code), `"`) == finishContentFilter || e.Type == finishContentFilter)
+}
+
+// err returns the error of a provider error object, with the content filter sentinel
+// when the filter caused it.
+func (e *APIError) err() error {
+	if e.contentFiltered() {
+		return fmt.Errorf("%w: %s", ErrContentFiltered, e.Message)
+	}
+	return fmt.Errorf("provider error: %s", e.Message)
+}
+
+// parseAPIError returns the error object of a response body, nil when there is none.
+func parseAPIError(body []byte) *APIError {
+	var payload struct {
+		Error *APIError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Error == nil || payload.Error.Message == "" {
+		return nil
+	}
+	return payload.Error
+}
+
+// checkResponse returns an error for responses without a usable message: no choices, a
+// choice the content filter stopped, a refusal, or a reply cut off before any content.
+func checkResponse(chatResp *ChatResponse) error {
+	if len(chatResp.Choices) == 0 {
+		return fmt.Errorf("%w: no choices", ErrEmptyResponse)
+	}
+	choice := chatResp.Choices[0]
+	if choice.FinishReason == finishContentFilter {
+		return fmt.Errorf("%w: the response was filtered", ErrContentFiltered)
+	}
+	if refusal := strings.TrimSpace(choice.Message.Refusal); refusal != "" {
+		return fmt.Errorf("%w: %s", ErrRefused, refusal)
+	}
+	if choice.FinishReason == finishLength && choice.Message.Content == "" && len(choice.Message.ToolCalls) == 0 {
+		return fmt.Errorf("%w: the output token limit was reached before it answered", ErrEmptyResponse)
+	}
+	return nil
+}
+
+// fillUsage completes the usage of providers that report the prompt and completion
+// tokens without their total. Providers that report no usage are logged, their requests
+// count as free, and the context window falls back to the local estimate.
+func (m *Model) fillUsage(chatResp *ChatResponse) {
+	usage := &chatResp.Usage
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	if usage.TotalTokens == 0 {
+		m.log().Debug("Provider reported no usage", "model", m.Name)
+	}
+}
@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsk_UnusableResponses(t *testing.T) {
+	withFastRetries(t)
+
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantErr  error
+		contains string
+		usage    int
+	}{
+		{
+			name:     "No choices",
+			status:   http.StatusOK,
+			body:     `{"choices":[],"usage":{"total_tokens":3,"prompt_tokens":3}}`,
+			wantErr:  ErrEmptyResponse,
+			contains: "no choices",
+			usage:    3,
+		},
+		{
+			name:     "Filtered choice",
+			status:   http.StatusOK,
+			body:     `{"choices":[{"message":{"content":""},"finish_reason":"content_filter"}],"usage":{"total_tokens":4}}`,
+			wantErr:  ErrContentFiltered,
+			contains: "the response was filtered",
+			usage:    4,
+		},
+		{
+			name:     "Refusal",
+			status:   http.StatusOK,
+			body:     `{"choices":[{"message":{"content":"","refusal":"I can't help with that."}}]}`,
+			wantErr:  ErrRefused,
+			contains: "I can't help with that.",
+		},
+		{
+			name:     "Cut off",
+			status:   http.StatusOK,
+			body:     `{"choices":[{"message":{"content":""},"finish_reason":"length"}]}`,
+			wantErr:  ErrEmptyResponse,
+			contains: "output token limit",
+		},
+		{
+			name:     "Azure content filter",
+			status:   http.StatusBadRequest,
+			body:     `{"error":{"message":"The response was filtered due to the prompt triggering Azure OpenAI's content management policy.","type":null,"param":"prompt","code":"content_filter","status":400}}`,
+			wantErr:  ErrContentFiltered,
+			contains: "content management policy. (status code: 400)",
+		},
+		{
+			name:     "Error payload with a 200 status",
+			status:   http.StatusOK,
+			body:     `{"error":{"message":"Upstream provider overloaded","code":502}}`,
+			contains: "provider error: Upstream provider overloaded",
+		},
+		{
+			name:     "Error status with a message",
+			status:   http.StatusForbidden,
+			body:     `{"error":{"message":"Key limit exceeded","code":403}}`,
+			contains: "unexpected status code 403: Key limit exceeded (status code: 403)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			model := newCompactionModel(server)
+			resp, err := model.Ask(context.Background(), "prompt", 0)
+			assert.Nil(t, resp)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.ErrorContains(t, err, tt.contains)
+			assert.Empty(t, model.History)
+			assert.Equal(t, tt.usage, model.Usage.TotalTokens)
+		})
+	}
+}
+
+func TestAsk_MissingTotalTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	model := newCompactionModel(server)
+	resp, err := model.Ask(context.Background(), "prompt", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, resp.Usage.TotalTokens)
+	assert.Equal(t, 7, model.Usage.TotalTokens)
+}
@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withFastRetries(t *testing.T) {
+	base, maxDelay := retryBaseDelay, retryMaxDelay
+	retryBaseDelay, retryMaxDelay = time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() {
+		retryBaseDelay, retryMaxDelay = base, maxDelay
+	})
+}
+
+func newFlakyServer(failures int, status int) (*httptest.Server, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}],"usage":{"total_tokens":2,"prompt_tokens":1,"completion_tokens":1}}`))
+	}))
+	return server, &requests
+}
+
+func TestAskRetriesTransientErrors(t *testing.T) {
+	withFastRetries(t)
+
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable} {
+		server, requests := newFlakyServer(2, status)
+
+		var retries []int
+		model := newCompactionModel(server)
+		model.OnRetry = func(attempt, maxAttempts int, err error) {
+			assert.Equal(t, DefaultMaxAttempts, maxAttempts)
+			assert.Error(t, err)
+			retries = append(retries, attempt)
+		}
+
+		resp, err := model.Ask(context.Background(), "prompt", 0)
+		require.NoError(t, err, "status %d", status)
+		assert.Equal(t, "ok", resp.Choices[0].Message.Content)
+		assert.Equal(t, 3, *requests)
+		assert.Equal(t, []int{2, 3}, retries)
+		assert.Len(t, model.History, 2)
+
+		server.Close()
+	}
+}
+
+func TestAskGivesUpAfterMaxAttempts(t *testing.T) {
+	withFastRetries(t)
+
+	server, requests := newFlakyServer(10, http.StatusTooManyRequests)
+	defer server.Close()
+
+	model := newCompactionModel(server)
+	model.MaxAttempts = 4
+
+	_, err := model.Ask(context.Background(), "prompt", 0)
+	assert.EqualError(t, err, "rate limit exceeded (status code: 429)")
+	assert.Equal(t, 4, *requests)
+	assert.Empty(t, model.History)
+}
+
+func TestAskDoesNotRetryClientErrors(t *testing.T) {
+	withFastRetries(t)
+
+	server, requests := newFlakyServer(10, http.StatusUnauthorized)
+	defer server.Close()
+
+	_, err := newCompactionModel(server).Ask(context.Background(), "prompt", 0)
+	assert.Error(t, err)
+	assert.Equal(t, 1, *requests)
+}
+
+func TestAskRetriesNetworkErrors(t *testing.T) {
+	withFastRetries(t)
+
+	server, _ := newFlakyServer(0, http.StatusOK)
+	model := newCompactionModel(server)
+	server.Close()
+
+	retries := 0
+	model.OnRetry = func(int, int, error) { retries++ }
+
+	_, err := model.Ask(context.Background(), "prompt", 0)
+	assert.ErrorContains(t, err, "failed to send request")
+	assert.Equal(t, DefaultMaxAttempts-1, retries)
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 2*time.Second, retryDelay(1, 2*time.Second))
+	assert.Equal(t, retryMaxDelay, retryDelay(1, time.Hour))
+
+	for attempt := 1; attempt <= 20; attempt++ {
+		delay := retryDelay(attempt, 0)
+		expected := min(retryBaseDelay<<min(attempt-1, 15), retryMaxDelay)
+		assert.GreaterOrEqual(t, delay, expected/2)
+		assert.LessOrEqual(t, delay, expected)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"-1", 0},
+		{"Sat, 01 Jun 2024 12:00:10 GMT", 10 * time.Second},
+		{"Sat, 01 Jun 2024 11:59:00 GMT", 0},
+		{"soon", 0},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, parseRetryAfter(tt.value, now), tt.value)
+	}
+}
@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+const (
+	// defaultEncoding estimates the tokens of models without a known tokenizer, such as
+	// models of other vendors, closely enough to protect the context window.
+	defaultEncoding = tiktoken.MODEL_O200K_BASE
+
+	// messageTokens and replyTokens are the tokens the chat format adds per message and
+	// to prime the reply.
+	messageTokens = 3
+	replyTokens   = 3
+)
+
+var (
+	encodingsMu sync.Mutex
+	encodings   = map[string]*tiktoken.Tiktoken{}
+)
+
+func init() {
+	// the encodings are embedded, so counting tokens never downloads them
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// encodingName returns the tokenizer of a model. Provider prefixes, such as openai/ on
+// OpenRouter, are ignored.
+func encodingName(model string) string {
+	model = model[strings.LastIndex(model, "/")+1:]
+	if name, ok := tiktoken.MODEL_TO_ENCODING[model]; ok {
+		return name
+	}
+	for prefix, name := range tiktoken.MODEL_PREFIX_TO_ENCODING {
+		if strings.HasPrefix(model, prefix) {
+			return name
+		}
+	}
+	return defaultEncoding
+}
+
+// encodingFor returns the loaded tokenizer of a model, or nil when it cannot be loaded.
+func encodingFor(model string, log *slog.Logger) *tiktoken.Tiktoken {
+	name := encodingName(model)
+
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	if encoding, ok := encodings[name]; ok {
+		return encoding
+	}
+
+	encoding, err := tiktoken.GetEncoding(name)
+	if err != nil {
+		log.Warn("Failed to load the tokenizer, estimating tokens from the text length", "encoding", name, "error", err)
+	}
+	encodings[name] = encoding
+	return encoding
+}
+
+// countTokens returns the tokens of a text with the model's tokenizer, or about four
+// characters per token when the tokenizer cannot be loaded.
+func (m *Model) countTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	if count, ok := m.tokenCache[text]; ok {
+		return count
+	}
+
+	var count int
+	if encoding := encodingFor(m.Name, m.log()); encoding != nil {
+		count = len(encoding.EncodeOrdinary(text))
+	} else {
+		count = (len(text) + 3) / 4
+	}
+
+	if m.tokenCache == nil || len(m.tokenCache) > 2*len(m.History)+64 {
+		m.tokenCache = map[string]int{}
+	}
+	m.tokenCache[text] = count
+	return count
+}
+
+// EstimateTokens returns the estimated prompt tokens of a request with the messages and
+// the model's tools, counted locally before it is sent.
+func (m *Model) EstimateTokens(messages []Message) int {
+	tokens := replyTokens
+	for _, msg := range messages {
+		tokens += messageTokens + m.countTokens(msg.Content)
+		for _, call := range msg.ToolCalls {
+			tokens += m.countTokens(call.Function.Name) + m.countTokens(call.Function.Arguments)
+		}
+	}
+	if len(m.Tools) > 0 {
+		tools, _ := json.Marshal(m.Tools)
+		tokens += m.countTokens(string(tools))
+	}
+	return tokens
+}
+
+// ContextUsage returns the estimated tokens of the conversation and the context window,
+// or zeros when the context window is not configured.
+func (m *Model) ContextUsage() (used, window int) {
+	if m.ContextWindow <= 0 {
+		return 0, 0
+	}
+	return m.EstimateTokens(m.History), m.ContextWindow
+}
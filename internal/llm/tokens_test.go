@@ -0,0 +1,72 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkoukk/tiktoken-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodingName(t *testing.T) {
+	assert.Equal(t, tiktoken.MODEL_O200K_BASE, encodingName("gpt-4o-mini"))
+	assert.Equal(t, tiktoken.MODEL_O200K_BASE, encodingName("openai/gpt-4o"))
+	assert.Equal(t, tiktoken.MODEL_CL100K_BASE, encodingName("gpt-4-turbo"))
+	assert.Equal(t, defaultEncoding, encodingName("anthropic.claude-3-5-sonnet-20240620-v1:0"))
+}
+
+func TestModel_EstimateTokens(t *testing.T) {
+	model := &Model{Name: "gpt-4o"}
+
+	// "hello world" is two tokens, and the chat format adds three per message and three
+	// for the reply
+	assert.Equal(t, 8, model.EstimateTokens([]Message{{Role: UserRole, Content: "hello world"}}))
+
+	model.Tools = []Tool{{Type: "function", Function: ToolFunction{Name: "run_command", Parameters: []byte(`{}`)}}}
+	assert.Greater(t, model.EstimateTokens([]Message{{Role: UserRole, Content: "hello world"}}), 8)
+}
+
+func TestModel_ContextUsage(t *testing.T) {
+	model := &Model{Name: "gpt-4o", History: []Message{{Role: SystemRole, Content: "hello world"}}}
+
+	used, window := model.ContextUsage()
+	assert.Zero(t, used)
+	assert.Zero(t, window)
+
+	model.ContextWindow = 128000
+	used, window = model.ContextUsage()
+	assert.Equal(t, 8, used)
+	assert.Equal(t, 128000, window)
+}
+
+func TestAsk_ExceedsContextWindow(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	model := &Model{
+		Client:        server.Client(),
+		URL:           server.URL,
+		Name:          "gpt-4o",
+		AuthToken:     AuthToken{Key: "Authorization", Value: "Bearer test-token"},
+		History:       []Message{{Role: SystemRole, Content: "You are a helpful assistant."}},
+		ContextWindow: 100,
+	}
+
+	_, err := model.Ask(context.Background(), strings.Repeat("kubectl get pods ", 100), 0)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrContextLengthExceeded))
+	assert.Zero(t, requests, "the request is not sent")
+
+	_, err = model.Ask(context.Background(), "hi", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+}
@@ -1,5 +1,11 @@
 package llm
 
+import (
+	"encoding/json"
+
+	"github.com/eliran89c/klama/config"
+)
+
 // Role represents the role of a message in a conversation.
 type Role string
 
@@ -7,17 +13,22 @@ const (
 	SystemRole    Role = "system"
 	UserRole      Role = "user"
 	AssistantRole Role = "assistant"
+	ToolRole      Role = "tool"
 )
 
 // ChatResponse represents the response from a chat completion API.
 type ChatResponse struct {
-	Usage   Usage    `json:"usage"`
-	Choices []Choice `json:"choices"`
+	// RawUsage holds the usage as reported by the provider, Usage holds it normalized.
+	RawUsage json.RawMessage `json:"usage,omitempty"`
+	Usage    Usage           `json:"-"`
+	Choices  []Choice        `json:"choices"`
+	Error    *APIError       `json:"error,omitempty"`
 }
 
 // Choice represents a single choice in a chat completion response.
 type Choice struct {
-	Message Message `json:"message"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason,omitempty"`
 }
 
 // Usage represents the token usage information for a chat completion.
@@ -25,17 +36,135 @@ type Usage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+
+	// CachedTokens and CacheWriteTokens are the prompt tokens read from and written to
+	// the prompt cache, ReasoningTokens are the completion tokens spent thinking.
+	CachedTokens     int `json:"cached_tokens,omitempty"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+	ReasoningTokens  int `json:"reasoning_tokens,omitempty"`
+
+	// Cost is the billed cost in USD, reported by providers such as OpenRouter.
+	Cost float64 `json:"cost,omitempty"`
+}
+
+// Add returns the sum of both usages.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+		CachedTokens:     u.CachedTokens + other.CachedTokens,
+		CacheWriteTokens: u.CacheWriteTokens + other.CacheWriteTokens,
+		ReasoningTokens:  u.ReasoningTokens + other.ReasoningTokens,
+		Cost:             u.Cost + other.Cost,
+	}
+}
+
+// Sub returns the usage added since other.
+func (u Usage) Sub(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens - other.PromptTokens,
+		CompletionTokens: u.CompletionTokens - other.CompletionTokens,
+		TotalTokens:      u.TotalTokens - other.TotalTokens,
+		CachedTokens:     u.CachedTokens - other.CachedTokens,
+		CacheWriteTokens: u.CacheWriteTokens - other.CacheWriteTokens,
+		ReasoningTokens:  u.ReasoningTokens - other.ReasoningTokens,
+		Cost:             u.Cost - other.Cost,
+	}
 }
 
 // ChatRequest represents a request to a chat completion API.
 type ChatRequest struct {
 	Model       string    `json:"model"`
 	Messages    []Message `json:"messages"`
-	Temperature float64   `json:"temperature"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	TopP        *float64  `json:"top_p,omitempty"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Tools       []Tool    `json:"tools,omitempty"`
+
+	// ReasoningEffort and Thinking configure reasoning models, such as the OpenAI
+	// o-series and Anthropic extended thinking.
+	// Reasoning models take MaxCompletionTokens instead of MaxTokens.
+	ReasoningEffort     string           `json:"reasoning_effort,omitempty"`
+	Thinking            *ThinkingOptions `json:"thinking,omitempty"`
+	MaxCompletionTokens int              `json:"max_completion_tokens,omitempty"`
+
+	// OpenRouter extensions
+	Provider *config.OpenRouterRouting `json:"provider,omitempty"`
+	Usage    *UsageOptions             `json:"usage,omitempty"`
+	// Reasoning replaces ReasoningEffort and Thinking for OpenRouter.
+	Reasoning *ReasoningOptions `json:"reasoning,omitempty"`
+}
+
+// ThinkingOptions enables extended thinking with a budget of tokens.
+type ThinkingOptions struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// ReasoningOptions configures the reasoning of OpenRouter models.
+type ReasoningOptions struct {
+	Effort    string `json:"effort,omitempty"`
+	MaxTokens int    `json:"max_tokens,omitempty"`
+}
+
+// UsageOptions asks OpenRouter to report the billed cost in the usage of the response.
+type UsageOptions struct {
+	Include bool `json:"include"`
 }
 
 // Message represents a single message in a conversation.
 type Message struct {
-	Role    Role   `json:"role"`
-	Content string `json:"content"`
+	Role       Role       `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+
+	// ReasoningContent and Reasoning hold the reasoning of a response, depending on the
+	// provider. They are never sent back to the model.
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	Reasoning        string `json:"reasoning,omitempty"`
+
+	// Refusal explains why the model refused to answer, never sent back to the model.
+	Refusal string `json:"refusal,omitempty"`
+}
+
+// Thinking returns the reasoning of a response message.
+func (m Message) Thinking() string {
+	if m.ReasoningContent != "" {
+		return m.ReasoningContent
+	}
+	return m.Reasoning
+}
+
+// Tool represents a function the model may call instead of answering in prose.
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction describes a callable function and its JSON schema parameters.
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ToolCall represents a single function call requested by the model.
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall holds the name and JSON-encoded arguments of a tool call.
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolCallParser is implemented by GuidedAsk results that can be populated
+// from a native tool-calling response.
+type ToolCallParser interface {
+	ParseToolCalls(content string, calls []ToolCall) error
 }
@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// UsageNormalizer maps the usage reported by a provider to a Usage, so cached and
+// reasoning tokens are priced the same way whatever the provider calls them.
+type UsageNormalizer interface {
+	NormalizeUsage(raw json.RawMessage) (Usage, error)
+}
+
+// OpenAIUsage normalizes the usage of OpenAI compatible providers, including the
+// cache fields of OpenRouter, DeepSeek and LiteLLM. Its prompt tokens include the
+// cached tokens and its completion tokens include the reasoning tokens.
+type OpenAIUsage struct{}
+
+type openAIUsage struct {
+	PromptTokens        int     `json:"prompt_tokens"`
+	CompletionTokens    int     `json:"completion_tokens"`
+	TotalTokens         int     `json:"total_tokens"`
+	Cost                float64 `json:"cost"`
+	PromptTokensDetails struct {
+		CachedTokens     int `json:"cached_tokens"`
+		CacheWriteTokens int `json:"cache_write_tokens"`
+	} `json:"prompt_tokens_details"`
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+	PromptCacheHitTokens     int `json:"prompt_cache_hit_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+}
+
+// NormalizeUsage implements UsageNormalizer.
+func (OpenAIUsage) NormalizeUsage(raw json.RawMessage) (Usage, error) {
+	var u openAIUsage
+	if err := json.Unmarshal(raw, &u); err != nil {
+		return Usage{}, fmt.Errorf("failed to unmarshal usage: %w", err)
+	}
+
+	usage := Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		CachedTokens:     firstNonZero(u.PromptTokensDetails.CachedTokens, u.PromptCacheHitTokens, u.CacheReadInputTokens),
+		CacheWriteTokens: firstNonZero(u.PromptTokensDetails.CacheWriteTokens, u.CacheCreationInputTokens),
+		ReasoningTokens:  u.CompletionTokensDetails.ReasoningTokens,
+		Cost:             u.Cost,
+	}
+	return usage, nil
+}
+
+// AnthropicUsage normalizes the usage of the Anthropic Messages API, whose input
+// tokens exclude the tokens read from and written to the prompt cache.
+type AnthropicUsage struct{}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+}
+
+// NormalizeUsage implements UsageNormalizer.
+func (AnthropicUsage) NormalizeUsage(raw json.RawMessage) (Usage, error) {
+	var u anthropicUsage
+	if err := json.Unmarshal(raw, &u); err != nil {
+		return Usage{}, fmt.Errorf("failed to unmarshal usage: %w", err)
+	}
+
+	prompt := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	usage := Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+		CachedTokens:     u.CacheReadInputTokens,
+		CacheWriteTokens: u.CacheCreationInputTokens,
+	}
+	return usage, nil
+}
+
+// usageNormalizer returns the normalizer of the model, or the one that matches the
+// format of the reported usage when none is set.
+func (m *Model) usageNormalizer(raw json.RawMessage) UsageNormalizer {
+	if m.UsageNormalizer != nil {
+		return m.UsageNormalizer
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(raw, &fields) == nil {
+		_, hasInput := fields["input_tokens"]
+		_, hasPrompt := fields["prompt_tokens"]
+		if hasInput && !hasPrompt {
+			return AnthropicUsage{}
+		}
+	}
+	return OpenAIUsage{}
+}
+
+// normalizeUsage fills the usage of a response from the usage reported by the provider.
+// A usage that cannot be read is logged and counts as none.
+func (m *Model) normalizeUsage(chatResp *ChatResponse) {
+	if len(chatResp.RawUsage) == 0 || string(chatResp.RawUsage) == "null" {
+		return
+	}
+
+	usage, err := m.usageNormalizer(chatResp.RawUsage).NormalizeUsage(chatResp.RawUsage)
+	if err != nil {
+		m.log().Warn("Failed to read the usage of the response", "model", m.Name, "error", err)
+		return
+	}
+	chatResp.Usage = usage
+}
+
+// usagePrices returns the price of the input and output tokens of a usage. Cached,
+// cache write and reasoning tokens use their own price when it is set.
+func (m *Model) usagePrices(usage Usage) (float64, float64) {
+	cached, writes := usage.CachedTokens, usage.CacheWriteTokens
+	regularInput := max(usage.PromptTokens-cached-writes, 0)
+	reasoning := usage.ReasoningTokens
+	regularOutput := max(usage.CompletionTokens-reasoning, 0)
+
+	inputPrice := (m.InputPrice*float64(regularInput) +
+		priceOr(m.CachedInputPrice, m.InputPrice)*float64(cached) +
+		priceOr(m.CacheWritePrice, m.InputPrice)*float64(writes)) / 1000
+	outputPrice := (m.OutputPrice*float64(regularOutput) +
+		priceOr(m.ReasoningPrice, m.OutputPrice)*float64(reasoning)) / 1000
+	return inputPrice, outputPrice
+}
+
+// priceOr returns price, or fallback when price is not set.
+func priceOr(price, fallback float64) float64 {
+	if price > 0 {
+		return price
+	}
+	return fallback
+}
+
+func firstNonZero(values ...int) int {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
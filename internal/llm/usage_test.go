@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageNormalizers(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalizer UsageNormalizer
+		raw        string
+		expected   Usage
+	}{
+		{
+			name:       "openai cached and reasoning tokens",
+			normalizer: OpenAIUsage{},
+			raw:        `{"prompt_tokens":1000,"completion_tokens":300,"total_tokens":1300,"prompt_tokens_details":{"cached_tokens":800},"completion_tokens_details":{"reasoning_tokens":200}}`,
+			expected:   Usage{PromptTokens: 1000, CompletionTokens: 300, TotalTokens: 1300, CachedTokens: 800, ReasoningTokens: 200},
+		},
+		{
+			name:       "openrouter cache writes and cost",
+			normalizer: OpenAIUsage{},
+			raw:        `{"prompt_tokens":1000,"completion_tokens":10,"total_tokens":1010,"cost":0.01,"prompt_tokens_details":{"cached_tokens":0,"cache_write_tokens":900}}`,
+			expected:   Usage{PromptTokens: 1000, CompletionTokens: 10, TotalTokens: 1010, CacheWriteTokens: 900, Cost: 0.01},
+		},
+		{
+			name:       "deepseek cache hits",
+			normalizer: OpenAIUsage{},
+			raw:        `{"prompt_tokens":100,"completion_tokens":10,"total_tokens":110,"prompt_cache_hit_tokens":64,"prompt_cache_miss_tokens":36}`,
+			expected:   Usage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110, CachedTokens: 64},
+		},
+		{
+			name:       "litellm anthropic cache fields",
+			normalizer: OpenAIUsage{},
+			raw:        `{"prompt_tokens":100,"completion_tokens":10,"total_tokens":110,"cache_read_input_tokens":50,"cache_creation_input_tokens":20}`,
+			expected:   Usage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110, CachedTokens: 50, CacheWriteTokens: 20},
+		},
+		{
+			name:       "anthropic input excludes the cache",
+			normalizer: AnthropicUsage{},
+			raw:        `{"input_tokens":30,"output_tokens":10,"cache_read_input_tokens":50,"cache_creation_input_tokens":20}`,
+			expected:   Usage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110, CachedTokens: 50, CacheWriteTokens: 20},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, err := tt.normalizer.NormalizeUsage(json.RawMessage(tt.raw))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, usage)
+		})
+	}
+}
+
+func TestModel_UsageNormalizer(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	t.Run("detects the anthropic format", func(t *testing.T) {
+		body = `{"choices":[{"message":{"content":"ok"}}],"usage":{"input_tokens":30,"output_tokens":10,"cache_read_input_tokens":50}}`
+		model := NewModel(server.Client(), config.ModelConfig{BaseURL: server.URL})
+		resp, err := model.Ask(context.Background(), "hi", 0)
+		require.NoError(t, err)
+		assert.Equal(t, Usage{PromptTokens: 80, CompletionTokens: 10, TotalTokens: 90, CachedTokens: 50}, resp.Usage)
+	})
+
+	t.Run("unreadable usage counts as none", func(t *testing.T) {
+		body = `{"choices":[{"message":{"content":"ok"}}],"usage":"n/a"}`
+		model := NewModel(server.Client(), config.ModelConfig{BaseURL: server.URL})
+		resp, err := model.Ask(context.Background(), "hi", 0)
+		require.NoError(t, err)
+		assert.Equal(t, Usage{}, resp.Usage)
+	})
+
+	t.Run("uses the configured normalizer", func(t *testing.T) {
+		body = `{"choices":[{"message":{"content":"ok"}}],"usage":{"input_tokens":30,"output_tokens":10,"prompt_tokens":5}}`
+		model := NewModel(server.Client(), config.ModelConfig{BaseURL: server.URL})
+		model.UsageNormalizer = AnthropicUsage{}
+		resp, err := model.Ask(context.Background(), "hi", 0)
+		require.NoError(t, err)
+		assert.Equal(t, 30, resp.Usage.PromptTokens)
+	})
+}
+
+func TestModel_UsagePrices(t *testing.T) {
+	usage := Usage{PromptTokens: 1000, CompletionTokens: 1000, CachedTokens: 600, CacheWriteTokens: 200, ReasoningTokens: 500}
+
+	t.Run("separate rates", func(t *testing.T) {
+		model := NewModel(http.DefaultClient, config.ModelConfig{Pricing: config.Pricing{
+			Input: 1, Output: 4, CachedInput: 0.1, CacheWrite: 1.25, Reasoning: 2,
+		}})
+		inputPrice, outputPrice := model.usagePrices(usage)
+		// 200 regular, 600 cached and 200 written tokens
+		assert.InDelta(t, 0.2+0.06+0.25, inputPrice, 1e-9)
+		// 500 regular and 500 reasoning tokens
+		assert.InDelta(t, 2+1, outputPrice, 1e-9)
+	})
+
+	t.Run("unset rates fall back to input and output", func(t *testing.T) {
+		model := NewModel(http.DefaultClient, config.ModelConfig{Pricing: config.Pricing{Input: 1, Output: 4}})
+		assert.InDelta(t, 1+4, model.Price(usage), 1e-9)
+	})
+}
@@ -1,34 +1,73 @@
+// Package logger creates the structured loggers klama components write their logs to.
+// Components receive a *slog.Logger instead of logging to a global, so tests and the
+// REST mode can capture the logs of a session.
 package logger
 
 import (
+	"context"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"strings"
 )
 
-var (
-	// global logger
-	logger *log.Logger
+// Log record formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
 )
 
-// Init initializes the logger with the given writer
-func Init(w io.Writer) {
-	logger = log.New(w, "", log.LstdFlags)
+// Options configure a logger.
+type Options struct {
+	Level  slog.Level // records below the level are dropped
+	Format string     // FormatText or FormatJSON, empty uses FormatText
 }
 
-// Debug logs debug messages
-func Debug(args ...interface{}) {
-	if logger == nil {
-		Init(io.Discard)
+// New returns a logger that writes the records at or above the level to w.
+func New(w io.Writer, opts Options) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	if opts.Format == FormatJSON {
+		return slog.New(slog.NewJSONHandler(w, handlerOpts))
 	}
+	return slog.New(slog.NewTextHandler(w, handlerOpts))
+}
 
-	logger.Println(args...)
+// Open returns a logger that appends to the file at path, rotating it as configured, and
+// a function that closes it. The directory of the file is created when missing.
+func Open(path string, rotation Rotation, opts Options) (*slog.Logger, func() error, error) {
+	file, err := openRotating(path, rotation)
+	if err != nil {
+		return nil, nil, err
+	}
+	return New(file, opts), file.Close, nil
 }
 
-// Debugf logs formatted debug messages
-func Debugf(format string, args ...interface{}) {
-	if logger == nil {
-		Init(io.Discard)
+// ParseLevel parses a level name: debug, info, warn or error.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", name)
 	}
+	return level, nil
+}
+
+// Discard returns a logger that drops every record.
+func Discard() *slog.Logger {
+	return slog.New(discardHandler{})
+}
 
-	logger.Printf(format, args...)
+// Or returns l, or a logger that drops every record when l is nil, so components can
+// leave their logger unset.
+func Or(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return Discard()
+	}
+	return l
 }
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
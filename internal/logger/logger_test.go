@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, Options{Level: slog.LevelWarn, Format: FormatJSON})
+
+	log.Info("dropped")
+	log.Warn("Request failed", "attempt", 2)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("failed to parse record %q: %v", buf.String(), err)
+	}
+	if record["msg"] != "Request failed" || record["level"] != "WARN" || record["attempt"] != float64(2) {
+		t.Errorf("unexpected record %v", record)
+	}
+
+	buf.Reset()
+	New(&buf, Options{}).Debug("dropped")
+	New(&buf, Options{}).Info("Compacted history", "messages", 4)
+	if got := buf.String(); strings.Contains(got, "dropped") || !strings.Contains(got, `msg="Compacted history" messages=4`) {
+		t.Errorf("text logger wrote %q", got)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "klama.log")
+	for _, msg := range []string{"first", "second"} {
+		log, closeFile, err := Open(path, Rotation{}, Options{})
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		log.Info(msg)
+		closeFile()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "msg=first") || !strings.Contains(string(data), "msg=second") {
+		t.Errorf("log file was not appended to: %q", data)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{"debug": slog.LevelDebug, "info": slog.LevelInfo, "WARN": slog.LevelWarn, "error": slog.LevelError}
+	for name, want := range tests {
+		got, err := ParseLevel(name)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel() accepted an invalid level")
+	}
+}
+
+func TestOr(t *testing.T) {
+	if Or(nil).Enabled(context.Background(), slog.LevelError) {
+		t.Error("Or(nil) logs records")
+	}
+	log := New(&bytes.Buffer{}, Options{})
+	if Or(log) != log {
+		t.Error("Or() replaced the logger")
+	}
+}
@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Rotation limits the disk space of a log file.
+type Rotation struct {
+	MaxSize  int64 // bytes after which the file is rotated, 0 never rotates it
+	MaxFiles int   // rotated files that are kept, the oldest are deleted first
+}
+
+// rotatingFile is a log file that is rotated once it reaches its maximum size: the file
+// is renamed to <path>.1, <path>.1 to <path>.2 and so on, and a new file is started.
+type rotatingFile struct {
+	path     string
+	rotation Rotation
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// openRotating opens the log file at path for appending, creating its directory.
+func openRotating(path string, rotation Rotation) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	rf := &rotatingFile{path: path, rotation: rotation}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	rf.file, rf.size = file, info.Size()
+	return nil
+}
+
+// Write appends p to the file, rotating it first when p would make it too large. A
+// single record is never split across files.
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.rotation.MaxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.rotation.MaxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the rotated files by one, deleting the oldest, and starts a new file.
+func (rf *rotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+
+	if rf.rotation.MaxFiles > 0 {
+		os.Remove(rf.rotated(rf.rotation.MaxFiles))
+		for i := rf.rotation.MaxFiles - 1; i >= 1; i-- {
+			os.Rename(rf.rotated(i), rf.rotated(i+1))
+		}
+		os.Rename(rf.path, rf.rotated(1))
+	} else {
+		os.Remove(rf.path)
+	}
+	return rf.open()
+}
+
+// rotated returns the path of the nth rotated file.
+func (rf *rotatingFile) rotated(n int) string {
+	return fmt.Sprintf("%s.%d", rf.path, n)
+}
+
+// Close closes the file.
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.file.Close()
+}
@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "klama.log")
+	rf, err := openRotating(path, Rotation{MaxSize: 10, MaxFiles: 2})
+	if err != nil {
+		t.Fatalf("openRotating() error = %v", err)
+	}
+	defer rf.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := rf.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for file, content := range want {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file, err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), data, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("kept more rotated files than MaxFiles")
+	}
+}
+
+func TestRotatingFile_Append(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "klama.log")
+	if err := os.WriteFile(path, []byte("earlier session\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rf, err := openRotating(path, Rotation{MaxSize: 1 << 20, MaxFiles: 1})
+	if err != nil {
+		t.Fatalf("openRotating() error = %v", err)
+	}
+	rf.Write([]byte("this session\n"))
+	rf.Close()
+
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "earlier session\n") {
+		t.Errorf("the log was not appended to: %q", data)
+	}
+}
@@ -0,0 +1,316 @@
+// Package loki queries logs from a Grafana Loki server.
+package loki
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Client queries the Loki HTTP API.
+type Client struct {
+	baseURL     *url.URL
+	bearerToken string
+	tenantID    string
+	httpClient  *http.Client
+}
+
+// NewClient returns a client for the Loki server at baseURL. The bearer token and the
+// tenant ID (X-Scope-OrgID) are optional.
+func NewClient(baseURL, bearerToken, tenantID string, httpClient *http.Client) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Loki URL %q", baseURL)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		baseURL:     u,
+		bearerToken: bearerToken,
+		tenantID:    tenantID,
+		httpClient:  httpClient,
+	}, nil
+}
+
+// Entry is a single log line.
+type Entry struct {
+	Time   time.Time
+	Labels map[string]string
+	Line   string
+}
+
+// Sample is a single value of a metric query.
+type Sample struct {
+	Time  time.Time
+	Value string
+}
+
+// Series are the samples of a metric query for one set of labels.
+type Series struct {
+	Labels  map[string]string
+	Samples []Sample
+}
+
+// QueryResult holds the log lines of a log query, or the series of a metric query.
+type QueryResult struct {
+	Entries []Entry  // sorted by time
+	Series  []Series // set for metric queries
+	Streams int      // number of log streams the entries came from
+}
+
+// QueryRange runs a LogQL query over the time range, returning up to limit log lines,
+// the most recent ones first.
+func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, limit int) (*QueryResult, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("direction", "backward")
+
+	var data struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	}
+	if err := c.get(ctx, "/loki/api/v1/query_range", params, &data); err != nil {
+		return nil, err
+	}
+
+	switch data.ResultType {
+	case "streams":
+		return decodeStreams(data.Result)
+	case "matrix":
+		return decodeMatrix(data.Result)
+	default:
+		return nil, fmt.Errorf("unsupported result type %q", data.ResultType)
+	}
+}
+
+// Labels returns the label names of the streams in the time range.
+func (c *Client) Labels(ctx context.Context, start, end time.Time) ([]string, error) {
+	var labels []string
+	err := c.get(ctx, "/loki/api/v1/labels", timeRange(start, end), &labels)
+	return labels, err
+}
+
+// LabelValues returns the values of the label in the time range.
+func (c *Client) LabelValues(ctx context.Context, name string, start, end time.Time) ([]string, error) {
+	var values []string
+	err := c.get(ctx, "/loki/api/v1/label/"+url.PathEscape(name)+"/values", timeRange(start, end), &values)
+	return values, err
+}
+
+// HintLabels are the labels whose values name the namespaces, apps and pods of the
+// streams, in the order Hints lists them.
+var HintLabels = []string{"namespace", "app", "app_kubernetes_io_name", "service_name", "container", "job", "pod"}
+
+// Hints returns the labels of HintLabels whose values the message mentions, at most limit
+// of them, as label="value" pairs. Pods are also mentioned by the name of their workload,
+// which their name starts with.
+func (c *Client) Hints(ctx context.Context, message string, start, end time.Time, limit int) ([]string, error) {
+	labels, err := c.Labels(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	words := mentionedWords(message)
+	var hints []string
+	for _, name := range HintLabels {
+		if !slices.Contains(labels, name) {
+			continue
+		}
+		values, err := c.LabelValues(ctx, name, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			if len(hints) == limit {
+				return hints, nil
+			}
+			if mentions(words, value, name == "pod") {
+				hints = append(hints, fmt.Sprintf("%s=%q", name, value))
+			}
+		}
+	}
+	return hints, nil
+}
+
+// mentionedWords returns the lowercase words of the message, which keep the dashes, dots
+// and underscores of Kubernetes names.
+func mentionedWords(message string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(message), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_.", r)
+	}) {
+		if word = strings.Trim(word, "-_."); word != "" {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// mentions reports whether the words name the value, or with workload set, a workload
+// of at least 3 characters the value starts with, as in checkout for checkout-7d9f8-x2k4q.
+func mentions(words map[string]bool, value string, workload bool) bool {
+	value = strings.ToLower(value)
+	if words[value] {
+		return true
+	}
+	if !workload {
+		return false
+	}
+	for word := range words {
+		if len(word) >= 3 && strings.HasPrefix(value, word+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+func timeRange(start, end time.Time) url.Values {
+	params := url.Values{}
+	params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+	return params
+}
+
+// get calls an API path and decodes the data field of the response into v.
+func (c *Client) get(ctx context.Context, path string, params url.Values, v any) error {
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	}
+	if c.tenantID != "" {
+		req.Header.Set("X-Scope-OrgID", c.tenantID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query Loki: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("loki returned status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var envelope struct {
+		Status string          `json:"status"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if envelope.Status != "success" {
+		return fmt.Errorf("loki returned status %q", envelope.Status)
+	}
+	return json.Unmarshal(envelope.Data, v)
+}
+
+func decodeStreams(data json.RawMessage) (*QueryResult, error) {
+	var streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	if err := json.Unmarshal(data, &streams); err != nil {
+		return nil, fmt.Errorf("failed to decode streams: %w", err)
+	}
+
+	result := &QueryResult{Streams: len(streams)}
+	for _, stream := range streams {
+		for _, value := range stream.Values {
+			ns, err := strconv.ParseInt(value[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q", value[0])
+			}
+			result.Entries = append(result.Entries, Entry{Time: time.Unix(0, ns), Labels: stream.Stream, Line: value[1]})
+		}
+	}
+
+	slices.SortStableFunc(result.Entries, func(a, b Entry) int { return a.Time.Compare(b.Time) })
+	return result, nil
+}
+
+func decodeMatrix(data json.RawMessage) (*QueryResult, error) {
+	var matrix []struct {
+		Metric map[string]string `json:"metric"`
+		Values [][2]any          `json:"values"`
+	}
+	if err := json.Unmarshal(data, &matrix); err != nil {
+		return nil, fmt.Errorf("failed to decode matrix: %w", err)
+	}
+
+	result := &QueryResult{}
+	for _, m := range matrix {
+		series := Series{Labels: m.Metric}
+		for _, value := range m.Values {
+			seconds, _ := value[0].(float64)
+			sample, _ := value[1].(string)
+			series.Samples = append(series.Samples, Sample{Time: time.Unix(0, int64(seconds*float64(time.Second))), Value: sample})
+		}
+		result.Series = append(result.Series, series)
+	}
+	return result, nil
+}
+
+// String formats the result like logcli: a line per log entry, with the stream labels
+// when the entries come from several streams, or a line per metric sample.
+func (r *QueryResult) String() string {
+	var b strings.Builder
+
+	for _, series := range r.Series {
+		fmt.Fprintf(&b, "%s\n", FormatLabels(series.Labels))
+		for _, sample := range series.Samples {
+			fmt.Fprintf(&b, "  %s %s\n", sample.Time.UTC().Format(time.RFC3339), sample.Value)
+		}
+	}
+
+	if r.Streams == 1 && len(r.Entries) > 0 {
+		fmt.Fprintf(&b, "%s\n", FormatLabels(r.Entries[0].Labels))
+	}
+	for _, entry := range r.Entries {
+		fmt.Fprintf(&b, "%s ", entry.Time.UTC().Format(time.RFC3339Nano))
+		if r.Streams > 1 {
+			fmt.Fprintf(&b, "%s ", FormatLabels(entry.Labels))
+		}
+		fmt.Fprintf(&b, "%s\n", strings.TrimRight(entry.Line, "\n"))
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// FormatLabels formats labels as a LogQL stream selector.
+func FormatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
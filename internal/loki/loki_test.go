@@ -0,0 +1,131 @@
+package loki
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL+"/", "token", "tenant-a", nil)
+	require.NoError(t, err)
+	return client
+}
+
+func TestClient_QueryRangeStreams(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/loki/api/v1/query_range", r.URL.Path)
+		assert.Equal(t, `{app="web"} |= "error"`, r.URL.Query().Get("query"))
+		assert.Equal(t, "50", r.URL.Query().Get("limit"))
+		assert.Equal(t, "1714557600000000000", r.URL.Query().Get("start"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "tenant-a", r.Header.Get("X-Scope-OrgID"))
+
+		w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[
+			{"stream":{"app":"web","pod":"web-1"},"values":[["1714557602000000000","error: timeout\n"]]},
+			{"stream":{"app":"web","pod":"web-0"},"values":[["1714557603000000000","error: refused"],["1714557601000000000","error: reset"]]}]}}`))
+	})
+
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	result, err := client.QueryRange(context.Background(), `{app="web"} |= "error"`, start, start.Add(time.Hour), 50)
+	require.NoError(t, err)
+
+	assert.Equal(t, `2024-05-01T10:00:01Z {app="web", pod="web-0"} error: reset
+2024-05-01T10:00:02Z {app="web", pod="web-1"} error: timeout
+2024-05-01T10:00:03Z {app="web", pod="web-0"} error: refused`, result.String())
+}
+
+func TestClient_QueryRangeMatrix(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"pod":"web-0"},"values":[[1714557600,"3"],[1714557660,"5"]]}]}}`))
+	})
+
+	result, err := client.QueryRange(context.Background(), `sum by (pod) (count_over_time({app="web"}[1m]))`, time.Now().Add(-time.Hour), time.Now(), 100)
+	require.NoError(t, err)
+
+	assert.Equal(t, "{pod=\"web-0\"}\n  2024-05-01T10:00:00Z 3\n  2024-05-01T10:01:00Z 5", result.String())
+}
+
+func TestClient_SingleStream(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[
+			{"stream":{"pod":"web-0"},"values":[["1714557601000000000","started"]]}]}}`))
+	})
+
+	result, err := client.QueryRange(context.Background(), `{pod="web-0"}`, time.Now().Add(-time.Hour), time.Now(), 100)
+	require.NoError(t, err)
+
+	assert.Equal(t, "{pod=\"web-0\"}\n2024-05-01T10:00:01Z started", result.String())
+}
+
+func TestClient_Labels(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loki/api/v1/labels":
+			w.Write([]byte(`{"status":"success","data":["app","namespace"]}`))
+		case "/loki/api/v1/label/namespace/values":
+			w.Write([]byte(`{"status":"success","data":["shop","kube-system"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	labels, err := client.Labels(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app", "namespace"}, labels)
+
+	values, err := client.LabelValues(context.Background(), "namespace", time.Now().Add(-time.Hour), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shop", "kube-system"}, values)
+}
+
+func TestClient_Hints(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loki/api/v1/labels":
+			w.Write([]byte(`{"status":"success","data":["app","filename","namespace","pod"]}`))
+		case "/loki/api/v1/label/namespace/values":
+			w.Write([]byte(`{"status":"success","data":["shop","kube-system"]}`))
+		case "/loki/api/v1/label/app/values":
+			w.Write([]byte(`{"status":"success","data":["checkout","cart"]}`))
+		case "/loki/api/v1/label/pod/values":
+			w.Write([]byte(`{"status":"success","data":["cart-5c8d7-abcde","checkout-7d9f8-x2k4q","checkout-7d9f8-p9m2z"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	hints, err := client.Hints(context.Background(), "Why does Checkout in the shop namespace return 502s?", time.Now().Add(-time.Hour), time.Now(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`namespace="shop"`, `app="checkout"`, `pod="checkout-7d9f8-x2k4q"`, `pod="checkout-7d9f8-p9m2z"`}, hints)
+
+	hints, err = client.Hints(context.Background(), "checkout is slow", time.Now().Add(-time.Hour), time.Now(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`app="checkout"`, `pod="checkout-7d9f8-x2k4q"`}, hints)
+
+	hints, err = client.Hints(context.Background(), "why are nodes not ready?", time.Now().Add(-time.Hour), time.Now(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, hints)
+}
+
+func TestClient_Errors(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "parse error at line 1, col 5: syntax error", http.StatusBadRequest)
+	})
+
+	_, err := client.QueryRange(context.Background(), `{app=`, time.Now().Add(-time.Hour), time.Now(), 100)
+	assert.EqualError(t, err, "loki returned status code 400: parse error at line 1, col 5: syntax error")
+
+	_, err = NewClient("loki:3100", "", "", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,206 @@
+// Package mcp serves tools over the Model Context Protocol, using newline delimited
+// JSON-RPC messages on stdio.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/eliran89c/klama/internal/logger"
+)
+
+// protocolVersions are the MCP versions the server speaks, the latest first.
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// maxMessageBytes bounds a single message read from the client.
+const maxMessageBytes = 10 << 20
+
+// Tool is a tool the server exposes to the client.
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+
+	// Handler runs the tool with its JSON arguments and returns the text result. An
+	// error is reported to the client as a failed tool call.
+	Handler func(ctx context.Context, arguments json.RawMessage) (string, error) `json:"-"`
+}
+
+// Server answers MCP requests with its tools.
+type Server struct {
+	Name    string
+	Version string
+	Tools   []Tool
+
+	Logger *slog.Logger // receives the requests the server answers, nil discards them
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type callResult struct {
+	Content []textContent `json:"content"`
+	IsError bool          `json:"isError"`
+}
+
+// Serve reads requests from r and writes responses to w until r is closed or ctx is
+// canceled. Requests are answered one at a time.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageBytes)
+
+	encoder := json.NewEncoder(w)
+
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		resp, ok := s.handle(ctx, line)
+		if !ok {
+			continue
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// handle answers a single message. It returns false for notifications, which get no
+// response.
+func (s *Server) handle(ctx context.Context, message []byte) (response, bool) {
+	var req request
+	if err := json.Unmarshal(message, &req); err != nil {
+		return errorResponse(json.RawMessage("null"), codeParseError, "parse error"), true
+	}
+
+	// notifications have no ID
+	if len(req.ID) == 0 {
+		logger.Or(s.Logger).Debug("MCP notification", "method", req.Method)
+		return response{}, false
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, codeInvalidRequest, "invalid request"), true
+	}
+
+	logger.Or(s.Logger).Debug("MCP request", "method", req.Method)
+
+	var result any
+	var err error
+	switch req.Method {
+	case "initialize":
+		result, err = s.initialize(req.Params)
+	case "ping":
+		result = struct{}{}
+	case "tools/list":
+		result = map[string]any{"tools": s.Tools}
+	case "tools/call":
+		result, err = s.callTool(ctx, req.Params)
+	default:
+		return errorResponse(req.ID, codeMethodNotFound, fmt.Sprintf("method %q not found", req.Method)), true
+	}
+
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) {
+		return errorResponse(req.ID, rpcErr.Code, rpcErr.Message), true
+	}
+	return response{JSONRPC: "2.0", ID: req.ID, Result: result}, true
+}
+
+func errorResponse(id json.RawMessage, code int, message string) response {
+	return response{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: message}}
+}
+
+// initialize agrees on the protocol version, the client's when it is supported.
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	var init struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if err := json.Unmarshal(params, &init); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "invalid initialize params"}
+	}
+
+	version := protocolVersions[0]
+	if slices.Contains(protocolVersions, init.ProtocolVersion) {
+		version = init.ProtocolVersion
+	}
+
+	return map[string]any{
+		"protocolVersion": version,
+		"capabilities":    map[string]any{"tools": map[string]any{}},
+		"serverInfo":      map[string]string{"name": s.Name, "version": s.Version},
+	}, nil
+}
+
+// callTool runs the named tool. Tool failures are results, so the client's model can
+// see and react to them.
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (any, error) {
+	var call struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &call); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "invalid tools/call params"}
+	}
+
+	index := slices.IndexFunc(s.Tools, func(tool Tool) bool { return tool.Name == call.Name })
+	if index < 0 {
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool %q", call.Name)}
+	}
+	if len(call.Arguments) == 0 {
+		call.Arguments = json.RawMessage("{}")
+	}
+
+	text, err := s.Tools[index].Handler(ctx, call.Arguments)
+	if err != nil {
+		logger.Or(s.Logger).Warn("MCP tool failed", "tool", call.Name, "error", err)
+		return callResult{Content: []textContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+	return callResult{Content: []textContent{{Type: "text", Text: text}}}, nil
+}
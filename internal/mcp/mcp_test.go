@@ -0,0 +1,125 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer() *Server {
+	return &Server{
+		Name:    "klama",
+		Version: "test",
+		Tools: []Tool{{
+			Name:        "echo",
+			Description: "Echoes the text",
+			InputSchema: map[string]any{"type": "object"},
+			Handler: func(_ context.Context, arguments json.RawMessage) (string, error) {
+				var args struct {
+					Text string `json:"text"`
+				}
+				if err := json.Unmarshal(arguments, &args); err != nil {
+					return "", err
+				}
+				if args.Text == "" {
+					return "", errors.New("text is required")
+				}
+				return args.Text, nil
+			},
+		}},
+	}
+}
+
+// serve sends the messages to a test server and returns the decoded responses.
+func serve(t *testing.T, messages ...string) []map[string]any {
+	t.Helper()
+
+	var out strings.Builder
+	err := newTestServer().Serve(context.Background(), strings.NewReader(strings.Join(messages, "\n")+"\n"), &out)
+	require.NoError(t, err)
+
+	var responses []map[string]any
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &resp))
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+func TestServer_Initialize(t *testing.T) {
+	tests := []struct {
+		name            string
+		clientVersion   string
+		expectedVersion string
+	}{
+		{name: "supported version", clientVersion: "2024-11-05", expectedVersion: "2024-11-05"},
+		{name: "unknown version", clientVersion: "2099-01-01", expectedVersion: protocolVersions[0]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := serve(t,
+				`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+tt.clientVersion+`"}}`,
+				`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+			)
+
+			require.Len(t, responses, 1)
+			result := responses[0]["result"].(map[string]any)
+			assert.Equal(t, tt.expectedVersion, result["protocolVersion"])
+			assert.Equal(t, map[string]any{"name": "klama", "version": "test"}, result["serverInfo"])
+			assert.Contains(t, result["capabilities"], "tools")
+		})
+	}
+}
+
+func TestServer_Tools(t *testing.T) {
+	responses := serve(t,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hello"}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"missing"}}`,
+	)
+	require.Len(t, responses, 4)
+
+	tools := responses[0]["result"].(map[string]any)["tools"].([]any)
+	require.Len(t, tools, 1)
+	assert.Equal(t, "echo", tools[0].(map[string]any)["name"])
+	assert.Contains(t, tools[0], "inputSchema")
+
+	assert.Equal(t, map[string]any{
+		"content": []any{map[string]any{"type": "text", "text": "hello"}},
+		"isError": false,
+	}, responses[1]["result"])
+
+	assert.Equal(t, map[string]any{
+		"content": []any{map[string]any{"type": "text", "text": "text is required"}},
+		"isError": true,
+	}, responses[2]["result"])
+
+	assert.Equal(t, float64(codeInvalidParams), responses[3]["error"].(map[string]any)["code"])
+}
+
+func TestServer_Errors(t *testing.T) {
+	responses := serve(t,
+		`not json`,
+		`{"jsonrpc":"2.0","id":"a","method":"resources/list"}`,
+		`{"jsonrpc":"1.0","id":2,"method":"ping"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"ping"}`,
+	)
+	require.Len(t, responses, 4)
+
+	assert.Nil(t, responses[0]["id"])
+	assert.Equal(t, float64(codeParseError), responses[0]["error"].(map[string]any)["code"])
+	assert.Equal(t, "a", responses[1]["id"])
+	assert.Equal(t, float64(codeMethodNotFound), responses[1]["error"].(map[string]any)["code"])
+	assert.Equal(t, float64(codeInvalidRequest), responses[2]["error"].(map[string]any)["code"])
+	assert.Equal(t, map[string]any{}, responses[3]["result"])
+}
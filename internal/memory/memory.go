@@ -0,0 +1,183 @@
+// Package memory keeps durable facts the agent learns about an environment, such as the
+// ingress controller of a cluster, so later sessions against it can skip rediscovering
+// them.
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/eliran89c/klama/config"
+)
+
+// MaxFactLength is the longest fact that is remembered, longer facts are cut.
+const MaxFactLength = 300
+
+// Fact is a remembered fact about an environment.
+type Fact struct {
+	Text    string    `json:"text"`
+	Learned time.Time `json:"learned"`
+}
+
+// Store keeps facts per scope, such as an assistant and kube context, in a JSON file.
+// When a scope has more than MaxFacts facts, the oldest are forgotten.
+type Store struct {
+	Path     string
+	MaxFacts int // zero keeps every fact
+}
+
+// DefaultPath returns the memory location, $XDG_STATE_HOME/klama/memory.json.
+func DefaultPath() (string, error) {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, "memory.json"), nil
+}
+
+// ScopeName returns the scope of the facts learned by an assistant in an environment,
+// such as k8s/prod-eu.
+func ScopeName(agent, environment string) string {
+	return agent + "/" + environment
+}
+
+// Facts returns the facts of every scope.
+func (s *Store) Facts() (map[string][]Fact, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string][]Fact{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memory: %w", err)
+	}
+
+	facts := map[string][]Fact{}
+	if err := json.Unmarshal(data, &facts); err != nil {
+		return nil, fmt.Errorf("failed to parse memory %s: %w", s.Path, err)
+	}
+	return facts, nil
+}
+
+// ScopeFacts returns the facts of the scope, oldest first.
+func (s *Store) ScopeFacts(scope string) ([]Fact, error) {
+	facts, err := s.Facts()
+	if err != nil {
+		return nil, err
+	}
+	return facts[scope], nil
+}
+
+// Remember adds the facts to the scope and returns the ones that were not known yet.
+// Facts are compared case insensitively.
+func (s *Store) Remember(scope string, texts ...string) ([]string, error) {
+	facts, err := s.Facts()
+	if err != nil {
+		return nil, err
+	}
+
+	var added []string
+	now := time.Now().UTC()
+	for _, text := range texts {
+		text = normalize(text)
+		if text == "" || slices.ContainsFunc(facts[scope], func(f Fact) bool { return strings.EqualFold(f.Text, text) }) {
+			continue
+		}
+		facts[scope] = append(facts[scope], Fact{Text: text, Learned: now})
+		added = append(added, text)
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	if s.MaxFacts > 0 && len(facts[scope]) > s.MaxFacts {
+		facts[scope] = facts[scope][len(facts[scope])-s.MaxFacts:]
+	}
+	return added, s.save(facts)
+}
+
+// Forget removes the facts of the scope, or of every scope when scope is empty.
+func (s *Store) Forget(scope string) error {
+	facts := map[string][]Fact{}
+	if scope != "" {
+		var err error
+		if facts, err = s.Facts(); err != nil {
+			return err
+		}
+		if _, ok := facts[scope]; !ok {
+			return fmt.Errorf("no facts are remembered for %s", scope)
+		}
+		delete(facts, scope)
+	}
+	return s.save(facts)
+}
+
+// Scope returns the facts of a single scope.
+func (s *Store) Scope(scope string) *Scope {
+	return &Scope{store: s, name: scope}
+}
+
+func (s *Store) save(facts map[string][]Fact) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create memory directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(facts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal memory: %w", err)
+	}
+
+	// write a temporary file first, so a failed write keeps the previous memory
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write memory: %w", err)
+	}
+	if err := os.Rename(tmp, s.Path); err != nil {
+		return fmt.Errorf("failed to write memory: %w", err)
+	}
+	return nil
+}
+
+// normalize trims a fact to a single line of at most MaxFactLength characters.
+func normalize(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > MaxFactLength {
+		text = string(runes[:MaxFactLength])
+	}
+	return text
+}
+
+// Scope is the memory of a single scope.
+type Scope struct {
+	store *Store
+	name  string
+}
+
+// Name returns the name of the scope.
+func (s *Scope) Name() string {
+	return s.name
+}
+
+// Facts returns the texts of the remembered facts, oldest first.
+func (s *Scope) Facts() ([]string, error) {
+	facts, err := s.store.ScopeFacts(s.name)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]string, 0, len(facts))
+	for _, fact := range facts {
+		texts = append(texts, fact.Text)
+	}
+	return texts, nil
+}
+
+// Remember adds the facts and returns the ones that were not known yet.
+func (s *Scope) Remember(facts ...string) ([]string, error) {
+	return s.store.Remember(s.name, facts...)
+}
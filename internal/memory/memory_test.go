@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Remember(t *testing.T) {
+	store := &Store{Path: filepath.Join(t.TempDir(), "klama", "memory.json"), MaxFacts: 3}
+	prod := store.Scope(ScopeName("k8s", "prod"))
+
+	facts, err := prod.Facts()
+	require.NoError(t, err)
+	assert.Empty(t, facts)
+
+	added, err := prod.Remember("ingress is nginx in namespace infra", "  metrics-server is\nnot installed ", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ingress is nginx in namespace infra", "metrics-server is not installed"}, added)
+
+	// known facts are skipped
+	added, err = prod.Remember("Ingress is NGINX in namespace infra")
+	require.NoError(t, err)
+	assert.Empty(t, added)
+
+	// other scopes are kept apart
+	_, err = store.Remember(ScopeName("k8s", "staging"), "cert-manager is installed")
+	require.NoError(t, err)
+
+	// the oldest facts are forgotten past the limit
+	_, err = prod.Remember("nodes run Bottlerocket", "the cluster runs Karpenter")
+	require.NoError(t, err)
+	facts, err = prod.Facts()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"metrics-server is not installed", "nodes run Bottlerocket", "the cluster runs Karpenter"}, facts)
+
+	all, err := store.Facts()
+	require.NoError(t, err)
+	assert.Len(t, all["k8s/staging"], 1)
+}
+
+func TestStore_RememberLongFact(t *testing.T) {
+	store := &Store{Path: filepath.Join(t.TempDir(), "memory.json")}
+
+	added, err := store.Remember("k8s/prod", strings.Repeat("a", MaxFactLength+10))
+	require.NoError(t, err)
+	require.Len(t, added, 1)
+	assert.Len(t, added[0], MaxFactLength)
+}
+
+func TestStore_Forget(t *testing.T) {
+	store := &Store{Path: filepath.Join(t.TempDir(), "memory.json")}
+	_, err := store.Remember("k8s/prod", "ingress is nginx")
+	require.NoError(t, err)
+	_, err = store.Remember("k8s/staging", "ingress is traefik")
+	require.NoError(t, err)
+
+	assert.Error(t, store.Forget("k8s/dev"))
+
+	require.NoError(t, store.Forget("k8s/prod"))
+	facts, err := store.Facts()
+	require.NoError(t, err)
+	assert.NotContains(t, facts, "k8s/prod")
+	assert.Contains(t, facts, "k8s/staging")
+
+	require.NoError(t, store.Forget(""))
+	facts, err = store.Facts()
+	require.NoError(t, err)
+	assert.Empty(t, facts)
+}
@@ -0,0 +1,226 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/google/cel-go/cel"
+)
+
+// Decision is the outcome of evaluating a command against the policy. A zero Decision
+// means no rule matched and the command follows the regular approval flow.
+type Decision struct {
+	Verdict string // config.VerdictAllow, config.VerdictDeny or config.VerdictRequireConfirmation
+	Rule    string // name of the matched rule
+	Message string
+}
+
+// Matched reports whether a rule matched the command.
+func (d Decision) Matched() bool {
+	return d.Verdict != ""
+}
+
+// Allowed reports whether the command may run without confirmation.
+func (d Decision) Allowed() bool {
+	return d.Verdict == config.VerdictAllow
+}
+
+// Denied reports whether the command must not run.
+func (d Decision) Denied() bool {
+	return d.Verdict == config.VerdictDeny
+}
+
+// RequiresConfirmation reports whether the command must be confirmed, even with
+// auto-approve.
+func (d Decision) RequiresConfirmation() bool {
+	return d.Verdict == config.VerdictRequireConfirmation
+}
+
+// String describes the decision, such as `denied by policy rule "pci": PCI data`.
+func (d Decision) String() string {
+	var verdict string
+	switch d.Verdict {
+	case config.VerdictAllow:
+		verdict = "allowed"
+	case config.VerdictDeny:
+		verdict = "denied"
+	default:
+		verdict = "held for confirmation"
+	}
+
+	description := fmt.Sprintf("%s by policy rule %q", verdict, d.Rule)
+	if d.Message != "" {
+		description += ": " + d.Message
+	}
+	return description
+}
+
+// kubernetesPrograms are the programs whose namespace and context flags are read.
+var kubernetesPrograms = []string{"kubectl", "helm"}
+
+type rule struct {
+	config.PolicyRule
+	program cel.Program
+}
+
+// Engine evaluates suggested commands against CEL rules. The first matching rule wins.
+//
+// Rules can use the following variables:
+//   - command: the command line as it is executed
+//   - program: the main program, such as kubectl
+//   - subcommand: the first argument that is not a flag, such as get
+//   - args: the arguments of the main program
+//   - piped: the programs the output is piped to
+//   - ns: the namespace set with -n or --namespace, or the session's namespace, empty
+//     with all_namespaces (namespace is a reserved word in CEL)
+//   - all_namespaces: whether -A or --all-namespaces is set
+//   - context: the kube context set with --context, or the session's context
+//
+// ns, all_namespaces and context are only read from kubectl and helm commands.
+type Engine struct {
+	rules []rule
+
+	// Context and Namespace are used when a command does not set them.
+	Context   string
+	Namespace string
+
+	// Rewrite, when set, returns the command line that is actually executed, for
+	// example with the flags of a pinned kube context.
+	Rewrite func(string) string
+}
+
+// New compiles the given rules.
+func New(rules []config.PolicyRule) (*Engine, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("command", cel.StringType),
+		cel.Variable("program", cel.StringType),
+		cel.Variable("subcommand", cel.StringType),
+		cel.Variable("args", cel.ListType(cel.StringType)),
+		cel.Variable("piped", cel.ListType(cel.StringType)),
+		cel.Variable("ns", cel.StringType),
+		cel.Variable("all_namespaces", cel.BoolType),
+		cel.Variable("context", cel.StringType),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create policy environment: %w", err)
+	}
+
+	e := &Engine{}
+	for _, r := range rules {
+		ast, issues := env.Compile(r.Expression)
+		if issues.Err() != nil {
+			return nil, fmt.Errorf("invalid expression of policy rule %q: %w", r.Name, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType {
+			return nil, fmt.Errorf("expression of policy rule %q must be a boolean, got %v", r.Name, ast.OutputType())
+		}
+
+		program, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression of policy rule %q: %w", r.Name, err)
+		}
+		e.rules = append(e.rules, rule{PolicyRule: r, program: program})
+	}
+
+	return e, nil
+}
+
+// Evaluate returns the decision of the first rule that matches the command. A rule
+// that fails to evaluate returns an error, the command should not run in that case.
+func (e *Engine) Evaluate(command string) (Decision, error) {
+	if e == nil || len(e.rules) == 0 {
+		return Decision{}, nil
+	}
+
+	if e.Rewrite != nil {
+		command = e.Rewrite(command)
+	}
+	activation := e.activation(command)
+
+	for _, r := range e.rules {
+		value, _, err := r.program.Eval(activation)
+		if err != nil {
+			return Decision{}, fmt.Errorf("failed to evaluate policy rule %q: %w", r.Name, err)
+		}
+		if matched, ok := value.Value().(bool); ok && matched {
+			return Decision{Verdict: r.Verdict, Rule: r.Name, Message: r.Message}, nil
+		}
+	}
+
+	return Decision{}, nil
+}
+
+// activation returns the variables of the given command.
+func (e *Engine) activation(command string) map[string]any {
+	vars := map[string]any{
+		"command":        command,
+		"program":        "",
+		"subcommand":     "",
+		"args":           []string{},
+		"piped":          []string{},
+		"ns":             e.Namespace,
+		"all_namespaces": false,
+		"context":        e.Context,
+	}
+
+	cmds := executer.SplitPipeline(command)
+	if len(cmds) == 0 || len(cmds[0].Parts) == 0 {
+		return vars
+	}
+
+	piped := make([]string, 0, len(cmds)-1)
+	for _, cmd := range cmds[1:] {
+		if len(cmd.Parts) > 0 {
+			piped = append(piped, executer.UnquoteWord(cmd.Parts[0]))
+		}
+	}
+	vars["piped"] = piped
+
+	// words are unquoted as the shell does, so rules see the argv the command runs with
+	parts := cmds[0].Parts
+	program := executer.UnquoteWord(parts[0])
+	vars["program"] = program
+
+	args := make([]string, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		args = append(args, executer.UnquoteWord(part))
+	}
+	vars["args"] = args
+
+	scoped := slices.Contains(kubernetesPrograms, program)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		next := func() string {
+			if hasValue {
+				return value
+			}
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+
+		switch {
+		case scoped && (name == "-n" || name == "--namespace"):
+			vars["ns"] = next()
+		case scoped && strings.HasPrefix(arg, "-n") && !strings.HasPrefix(arg, "--"):
+			vars["ns"] = strings.TrimPrefix(arg, "-n")
+		case scoped && (name == "--context" || name == "--kube-context"):
+			vars["context"] = next()
+		case scoped && (arg == "-A" || arg == "--all-namespaces" || arg == "--all-namespaces=true"):
+			vars["all_namespaces"] = true
+		case vars["subcommand"] == "" && !strings.HasPrefix(arg, "-"):
+			vars["subcommand"] = arg
+		}
+	}
+	if vars["all_namespaces"] == true {
+		vars["ns"] = ""
+	}
+
+	return vars
+}
@@ -0,0 +1,115 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Evaluate(t *testing.T) {
+	engine, err := New([]config.PolicyRule{
+		{Name: "pci", Expression: `subcommand == "logs" && ns.matches("^pci-")`, Verdict: config.VerdictDeny, Message: "PCI data"},
+		{Name: "everywhere", Expression: `all_namespaces`, Verdict: config.VerdictRequireConfirmation},
+		{Name: "prod", Expression: `context.contains("prod")`, Verdict: config.VerdictRequireConfirmation},
+		{Name: "reads", Expression: `program == "kubectl" && subcommand in ["get", "describe"] && !("grep" in piped)`, Verdict: config.VerdictAllow},
+	})
+	require.NoError(t, err)
+	engine.Context = "staging"
+	engine.Namespace = "default"
+
+	tests := []struct {
+		name    string
+		command string
+		want    Decision
+	}{
+		{
+			name:    "namespace flag",
+			command: "kubectl logs web-0 -n pci-payments --tail 100",
+			want:    Decision{Verdict: config.VerdictDeny, Rule: "pci", Message: "PCI data"},
+		},
+		{
+			name:    "quoted namespace",
+			command: `kubectl logs web-0 --namespace="pci-cards"`,
+			want:    Decision{Verdict: config.VerdictDeny, Rule: "pci", Message: "PCI data"},
+		},
+		{
+			name:    "partly quoted namespace",
+			command: `kubectl logs -n 'pci'-prod web`,
+			want:    Decision{Verdict: config.VerdictDeny, Rule: "pci", Message: "PCI data"},
+		},
+		{
+			name:    "quoted program",
+			command: `"kubectl" get pods -o wide`,
+			want:    Decision{Verdict: config.VerdictAllow, Rule: "reads"},
+		},
+		{
+			name:    "session namespace",
+			command: "kubectl logs web-0",
+			want:    Decision{},
+		},
+		{
+			name:    "all namespaces",
+			command: "kubectl get pods -A",
+			want:    Decision{Verdict: config.VerdictRequireConfirmation, Rule: "everywhere"},
+		},
+		{
+			name:    "context flag",
+			command: "kubectl get pods --context prod-eu",
+			want:    Decision{Verdict: config.VerdictRequireConfirmation, Rule: "prod"},
+		},
+		{
+			name:    "allowed",
+			command: "kubectl get pods -o wide",
+			want:    Decision{Verdict: config.VerdictAllow, Rule: "reads"},
+		},
+		{
+			name:    "piped",
+			command: "kubectl get pods | grep web",
+			want:    Decision{},
+		},
+		{
+			name:    "namespace flags of other programs",
+			command: "journalctl -n50 -u kubelet",
+			want:    Decision{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Evaluate(tt.command)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEngine_Rewrite(t *testing.T) {
+	engine, err := New([]config.PolicyRule{
+		{Name: "pci", Expression: `ns.startsWith("pci-")`, Verdict: config.VerdictDeny},
+	})
+	require.NoError(t, err)
+	engine.Rewrite = func(command string) string { return command + " --namespace=pci-cards" }
+
+	got, err := engine.Evaluate("kubectl get pods -n default")
+	require.NoError(t, err)
+	assert.True(t, got.Denied())
+}
+
+func TestNew_InvalidRules(t *testing.T) {
+	_, err := New([]config.PolicyRule{{Name: "syntax", Expression: `subcommand ==`, Verdict: config.VerdictDeny}})
+	assert.ErrorContains(t, err, `invalid expression of policy rule "syntax"`)
+
+	_, err = New([]config.PolicyRule{{Name: "unknown", Expression: `verb == "get"`, Verdict: config.VerdictDeny}})
+	assert.ErrorContains(t, err, "undeclared reference to 'verb'")
+
+	_, err = New([]config.PolicyRule{{Name: "string", Expression: `subcommand`, Verdict: config.VerdictDeny}})
+	assert.ErrorContains(t, err, `expression of policy rule "string" must be a boolean`)
+}
+
+func TestDecision_String(t *testing.T) {
+	assert.Equal(t, `denied by policy rule "pci": PCI data`, Decision{Verdict: config.VerdictDeny, Rule: "pci", Message: "PCI data"}.String())
+	assert.Equal(t, `allowed by policy rule "reads"`, Decision{Verdict: config.VerdictAllow, Rule: "reads"}.String())
+	assert.Equal(t, `held for confirmation by policy rule "prod"`, Decision{Verdict: config.VerdictRequireConfirmation, Rule: "prod"}.String())
+}
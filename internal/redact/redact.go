@@ -0,0 +1,148 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Placeholder replaces every redacted value.
+const Placeholder = "[REDACTED]"
+
+// secretGroup is the name of the capture group that holds the secret. When a pattern
+// does not define it, the whole match is redacted.
+const secretGroup = "secret"
+
+type pattern struct {
+	re *regexp.Regexp
+	// match, when set, must accept the secret for it to be redacted
+	match func(string) bool
+}
+
+// credentialKeys matches the keys and names that hold credentials.
+const credentialKeys = `(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|client[_-]?secret)`
+
+// builtinPatterns detect common credentials in command output.
+var builtinPatterns = []pattern{
+	// private key blocks
+	{re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	// bearer tokens
+	{re: regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]{8,}=*)`)},
+	// JSON web tokens
+	{re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}`)},
+	// AWS access key IDs
+	{re: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b`)},
+	// AWS secret access keys
+	{re: regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})`)},
+	// GitHub, Slack and OpenAI style API tokens
+	{re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|xox[abposr]-[A-Za-z0-9-]{10,}|sk-[A-Za-z0-9_-]{20,})\b`)},
+	// key/value credentials, e.g. password=..., "api_key": "..."
+	{re: regexp.MustCompile(`(?i)` + credentialKeys + `["']?[ \t]*[:=][ \t]*["']?(?P<secret>[^\s"',;]{4,})`), match: notLiteral},
+	// values of named credentials, e.g. the value of a DB_PASSWORD environment variable
+	{re: regexp.MustCompile(`(?i)"?name"?[ \t]*:[ \t]*["']?[\w.-]*` + credentialKeys + `[\w.-]*["']?,?[ \t]*\r?\n[ \t]*"?value"?[ \t]*:[ \t]*["']?(?P<secret>[^\s"',;]+)`), match: notLiteral},
+	// base64 encoded values, e.g. the data of a Kubernetes secret
+	{re: regexp.MustCompile(`(?m)[:=][ \t]*["']?(?P<secret>[A-Za-z0-9+/]{20,}={0,2})["']?[ \t]*,?$`), match: looksLikeBase64Secret},
+}
+
+// Redactor scrubs credentials from text.
+type Redactor struct {
+	patterns []pattern
+}
+
+// New creates a Redactor with the built-in patterns followed by the given custom
+// regular expressions. If a custom expression has a capture group named "secret",
+// only that group is redacted.
+func New(customPatterns []string, disableBuiltin bool) (*Redactor, error) {
+	r := &Redactor{}
+	if !disableBuiltin {
+		r.patterns = append(r.patterns, builtinPatterns...)
+	}
+
+	for _, expr := range customPatterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", expr, err)
+		}
+		r.patterns = append(r.patterns, pattern{re: re})
+	}
+
+	return r, nil
+}
+
+// Redact replaces every detected credential with Placeholder and returns the
+// redacted text and the number of redactions.
+func (r *Redactor) Redact(text string) (string, int) {
+	if r == nil {
+		return text, 0
+	}
+
+	count := 0
+	for _, p := range r.patterns {
+		text = p.redact(text, &count)
+	}
+
+	return text, count
+}
+
+func (p pattern) redact(text string, count *int) string {
+	matches := p.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	group := p.re.SubexpIndex(secretGroup)
+
+	var sb strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if group > 0 && match[2*group] >= 0 {
+			start, end = match[2*group], match[2*group+1]
+		}
+
+		secret := text[start:end]
+		if secret == Placeholder || (p.match != nil && !p.match(secret)) {
+			continue
+		}
+
+		sb.WriteString(text[last:start])
+		sb.WriteString(Placeholder)
+		last = end
+		*count++
+	}
+	sb.WriteString(text[last:])
+
+	return sb.String()
+}
+
+// notLiteral reports whether s is not a boolean or null literal.
+func notLiteral(s string) bool {
+	switch strings.ToLower(s) {
+	case "true", "false", "null", "none", "<none>":
+		return false
+	}
+	return true
+}
+
+// looksLikeBase64Secret reports whether s looks like base64 encoded data rather
+// than a plain identifier.
+func looksLikeBase64Secret(s string) bool {
+	if len(s)%4 != 0 {
+		return false
+	}
+
+	var upper, lower, digit bool
+	for _, c := range s {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		}
+	}
+
+	return upper && lower && digit
+}
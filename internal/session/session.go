@@ -0,0 +1,241 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/llm"
+)
+
+// ErrNotFound is returned when a session does not exist in the store.
+var ErrNotFound = errors.New("session not found")
+
+// Entry represents a single message in the session transcript.
+type Entry struct {
+	Sender  string `json:"sender"`
+	Content string `json:"content"`
+	Output  bool   `json:"output,omitempty"`
+	Usage   string `json:"usage,omitempty"`
+
+	Thinking bool `json:"thinking,omitempty"`
+
+	Time    time.Time     `json:"time"`
+	Elapsed time.Duration `json:"elapsed,omitempty"`
+}
+
+// Session holds everything needed to resume a conversation.
+type Session struct {
+	ID               string            `json:"id"`
+	Agent            string            `json:"agent"`
+	Model            string            `json:"model"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+	Title            string            `json:"title"`
+	Outcome          string            `json:"outcome"`
+	Cost             float64           `json:"cost"`
+	History          []llm.Message     `json:"history"`
+	Transcript       []Entry           `json:"transcript"`
+	ExecutedCommands map[string]string `json:"executed_commands,omitempty"`
+	Usage            llm.Usage         `json:"usage"`
+
+	// Unfinished is set by the snapshots saved while the session runs, and cleared when
+	// the session is saved on exit. A session left unfinished ended with a crash.
+	Unfinished bool `json:"unfinished,omitempty"`
+}
+
+// New creates a new empty session for the given agent.
+func New(agent string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:        NewID(now),
+		Agent:     agent,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// NewID returns a sortable, human friendly session ID.
+func NewID(t time.Time) string {
+	suffix := make([]byte, 2)
+	rand.Read(suffix)
+	return t.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// Store persists sessions as JSON files in a directory.
+type Store struct {
+	Dir string
+}
+
+// NewStore creates a new Store rooted at dir.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// DefaultStore returns the store located at $XDG_STATE_HOME/klama/sessions.
+func DefaultStore() (*Store, error) {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(filepath.Join(stateDir, "sessions")), nil
+}
+
+// Save writes the session to the store.
+func (s *Store) Save(sess *Session) error {
+	if sess.ID == "" {
+		return fmt.Errorf("session ID is required")
+	}
+
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+
+	sess.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	// write to a temporary file first so a crash never leaves a truncated session
+	tmp := s.path(sess.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := os.Rename(tmp, s.path(sess.ID)); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads the session with the given ID from the store.
+func (s *Store) Load(id string) (*Session, error) {
+	if id == "" || filepath.Base(id) != id {
+		return nil, fmt.Errorf("invalid session ID %q", id)
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("failed to decode session %s: %w", id, err)
+	}
+
+	return &sess, nil
+}
+
+// Summary is a lightweight view of a session used for listings.
+type Summary struct {
+	ID        string    `json:"id"`
+	Agent     string    `json:"agent"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Title     string    `json:"title"`
+	Outcome   string    `json:"outcome"`
+	Usage     llm.Usage `json:"usage"`
+	Cost      float64   `json:"cost"`
+}
+
+// Summary returns the summary of the session.
+func (sess *Session) Summary() Summary {
+	return Summary{
+		ID:        sess.ID,
+		Agent:     sess.Agent,
+		Model:     sess.Model,
+		CreatedAt: sess.CreatedAt,
+		UpdatedAt: sess.UpdatedAt,
+		Title:     sess.Title,
+		Outcome:   sess.Outcome,
+		Usage:     sess.Usage,
+		Cost:      sess.Cost,
+	}
+}
+
+// Filter selects sessions by agent and creation time. Zero values match everything.
+type Filter struct {
+	Agent string
+	Since time.Time
+	Until time.Time
+}
+
+// Match reports whether the session matches the filter.
+func (f Filter) Match(sess *Session) bool {
+	if f.Agent != "" && f.Agent != sess.Agent {
+		return false
+	}
+	if !f.Since.IsZero() && sess.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && sess.CreatedAt.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// List returns the sessions matching the filter, newest first.
+// Files that cannot be decoded are skipped.
+func (s *Store) List(filter Filter) ([]*Session, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sessions directory: %w", err)
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		sess, err := s.Load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		if filter.Match(sess) {
+			sessions = append(sessions, sess)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+
+	return sessions, nil
+}
+
+// Recoverable returns the newest unfinished session of the agent that has not been saved
+// for idle, which a running Klama would have done, or nil when there is none.
+func (s *Store) Recoverable(agent string, idle time.Duration) (*Session, error) {
+	sessions, err := s.List(Filter{Agent: agent})
+	if err != nil {
+		return nil, err
+	}
+	for _, sess := range sessions {
+		if sess.Unfinished && time.Since(sess.UpdatedAt) >= idle {
+			return sess, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
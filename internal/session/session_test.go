@@ -0,0 +1,114 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eliran89c/klama/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewID(t *testing.T) {
+	now := time.Date(2024, 7, 1, 10, 30, 0, 0, time.UTC)
+
+	id := NewID(now)
+	assert.Regexp(t, `^20240701-103000-[0-9a-f]{4}$`, id)
+	assert.NotEqual(t, id, NewID(now))
+}
+
+func TestStore_SaveLoad(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	sess := New("k8s")
+	sess.Model = "test-model"
+	sess.History = []llm.Message{{Role: llm.SystemRole, Content: "system"}, {Role: llm.UserRole, Content: "why is my pod failing?"}}
+	sess.Transcript = []Entry{{Sender: "You", Content: "why is my pod failing?"}}
+	sess.ExecutedCommands = map[string]string{"kubectl get pods -A": "NAME READY"}
+	sess.Usage = llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+
+	require.NoError(t, store.Save(sess))
+
+	loaded, err := store.Load(sess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sess.ID, loaded.ID)
+	assert.Equal(t, "k8s", loaded.Agent)
+	assert.Equal(t, sess.History, loaded.History)
+	assert.Equal(t, sess.Transcript, loaded.Transcript)
+	assert.Equal(t, sess.ExecutedCommands, loaded.ExecutedCommands)
+	assert.Equal(t, sess.Usage, loaded.Usage)
+}
+
+func TestStore_Load(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	_, err := store.Load("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = store.Load("../outside")
+	assert.Error(t, err)
+
+	_, err = store.Load("")
+	assert.Error(t, err)
+}
+
+func TestStore_List(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	base := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	for i, agent := range []string{"k8s", "aws", "k8s"} {
+		sess := New(agent)
+		sess.ID = NewID(base.Add(time.Duration(i) * time.Hour))
+		sess.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, store.Save(sess))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(store.Dir, "broken.json"), []byte("{"), 0600))
+
+	all, err := store.List(Filter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.True(t, all[0].CreatedAt.After(all[1].CreatedAt))
+
+	k8s, err := store.List(Filter{Agent: "k8s"})
+	require.NoError(t, err)
+	assert.Len(t, k8s, 2)
+
+	ranged, err := store.List(Filter{Since: base.Add(30 * time.Minute), Until: base.Add(90 * time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, ranged, 1)
+	assert.Equal(t, "aws", ranged[0].Agent)
+
+	empty, err := NewStore(filepath.Join(t.TempDir(), "missing")).List(Filter{})
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestStore_Recoverable(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	finished := New("k8s")
+	require.NoError(t, store.Save(finished))
+	other := New("aws")
+	other.Unfinished = true
+	require.NoError(t, store.Save(other))
+
+	sess, err := store.Recoverable("k8s", 0)
+	require.NoError(t, err)
+	assert.Nil(t, sess)
+
+	crashed := New("k8s")
+	crashed.Unfinished = true
+	require.NoError(t, store.Save(crashed))
+
+	// a session saved recently may still be running
+	sess, err = store.Recoverable("k8s", time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, sess)
+
+	sess, err = store.Recoverable("k8s", 0)
+	require.NoError(t, err)
+	require.NotNil(t, sess)
+	assert.Equal(t, crashed.ID, sess.ID)
+}
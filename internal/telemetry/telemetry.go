@@ -0,0 +1,66 @@
+// Package telemetry exports OpenTelemetry traces of klama sessions over OTLP, so agent
+// requests and the commands they run show up in an existing observability stack.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// serviceName identifies klama in the exported traces.
+const serviceName = "klama"
+
+// Enabled reports whether traces are exported: when an endpoint is configured, or set
+// with the standard OTEL_EXPORTER_OTLP_ENDPOINT variables.
+func Enabled(cfg config.TracingConfig) bool {
+	return cfg.Endpoint != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs the global tracer provider, which exports spans over OTLP/HTTP in the
+// background, and returns a function that exports the remaining spans and stops it.
+// Export failures are logged instead of printed, as they would break the chat UI.
+// When tracing is not enabled spans are not recorded, and the function does nothing.
+func Setup(ctx context.Context, cfg config.TracingConfig, version string, log *slog.Logger) (func(context.Context) error, error) {
+	if !Enabled(cfg) {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the trace exporter: %w", err)
+	}
+
+	log = logger.Or(log)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Warn("Failed to export traces", "error", err)
+	}))
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(version),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eliran89c/klama/config"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup_Disabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	shutdown, err := Setup(context.Background(), config.TracingConfig{}, "dev", nil)
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown() error = %v", err)
+	}
+}
+
+func TestSetup_Exports(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case requests <- r:
+		default:
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.TracingConfig{Endpoint: server.URL, Headers: map[string]string{"x-api-key": "secret"}}
+	shutdown, err := Setup(context.Background(), cfg, "dev", nil)
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	_, span := otel.Tracer("test").Start(context.Background(), "agent.Iterate")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+
+	select {
+	case r := <-requests:
+		if r.URL.Path != "/v1/traces" || r.Header.Get("x-api-key") != "secret" {
+			t.Errorf("exported to %s with key %q", r.URL.Path, r.Header.Get("x-api-key"))
+		}
+	default:
+		t.Fatal("no spans were exported")
+	}
+}
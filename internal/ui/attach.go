@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/redact"
+)
+
+const (
+	attachCommand      = "/attach"
+	maxAttachmentBytes = 200 * 1024
+)
+
+// attachment is a local file included as context in the next message to the agent.
+type attachment struct {
+	path    string
+	content string
+}
+
+// handleAttach reads the file named in an `/attach <path>` message, redacts it, and
+// queues it for the next message to the agent.
+func (m Model) handleAttach(query string) (tea.Model, tea.Cmd) {
+	path := strings.TrimSpace(strings.TrimPrefix(query, attachCommand))
+	if path == "" {
+		m.err = fmt.Errorf("usage: %s <path>", attachCommand)
+		return m, nil
+	}
+
+	content, err := readAttachment(path)
+	if err != nil {
+		m.log().Warn("Failed to attach file", "path", path, "error", err)
+		m.err = fmt.Errorf("failed to attach %s: %w", path, err)
+		return m, nil
+	}
+
+	// never send credentials to the model
+	content, redacted := m.config.Redactor.Redact(content)
+
+	m.err = nil
+	m.attachments = append(m.attachments, attachment{path: path, content: content})
+	m.textarea.Reset()
+	m.addCommandOutput(fmt.Sprintf("%s:\n%s", path, content), 0)
+	m.updateChat(SenderSystem, fmt.Sprintf("Attached %s (%d lines), it is sent with your next message.", path, strings.Count(content, "\n")+1))
+	if redacted > 0 {
+		m.updateChat(SenderSystem, fmt.Sprintf("%d sensitive value(s) were replaced with %s before attaching the file.", redacted, redact.Placeholder))
+	}
+	return m, nil
+}
+
+// readAttachment reads a text file, expanding a leading ~ to the home directory.
+func readAttachment(path string) (string, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, rest)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("is a directory")
+	}
+	if info.Size() > maxAttachmentBytes {
+		return "", fmt.Errorf("file is larger than %d KB", maxAttachmentBytes/1024)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("not a text file")
+	}
+
+	return strings.TrimRight(string(data), "\n"), nil
+}
+
+// withAttachments prepends the queued attachments to the user message.
+func withAttachments(attachments []attachment, query string) string {
+	if len(attachments) == 0 {
+		return query
+	}
+
+	var b strings.Builder
+	for _, a := range attachments {
+		fmt.Fprintf(&b, "Attached file %s:\n```\n%s\n```\n\n", a.path, a.content)
+	}
+	b.WriteString(query)
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/redact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_handleAttach(t *testing.T) {
+	mockAgent := new(MockAgent)
+	redactor, err := redact.New(nil, false)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "deployment.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("kind: Deployment\nenv:\n- DB_PASSWORD=hunter2\n"), 0644))
+
+	model := InitialModel(Config{Agent: mockAgent, Redactor: redactor})
+	model.textarea.SetValue("/attach " + path)
+
+	newModel, cmd := model.handleEnterKey()
+	model = newModel.(Model)
+	assert.Nil(t, cmd)
+	assert.NoError(t, model.err)
+	assert.Equal(t, StateTyping, model.state)
+	assert.Empty(t, model.textarea.Value())
+
+	transcript := model.Transcript()
+	require.Len(t, transcript, 3)
+	assert.True(t, transcript[0].Output)
+	assert.Contains(t, transcript[0].Content, "DB_PASSWORD=[REDACTED]")
+	assert.Contains(t, transcript[1].Content, "Attached "+path+" (3 lines)")
+	assert.Contains(t, transcript[2].Content, "1 sensitive value(s) were replaced")
+
+	// the attachment is sent once, with the next message
+	expected := "Attached file " + path + ":\n```\nkind: Deployment\nenv:\n- DB_PASSWORD=[REDACTED]\n```\n\nwhy is it crashing?"
+	mockAgent.On("Iterate", mock.Anything, expected).Return(agent.AgentResponse{Answer: "Test response"}, nil)
+
+	model.textarea.SetValue("why is it crashing?")
+	newModel, cmd = model.handleEnterKey()
+	model = newModel.(Model)
+	assert.Empty(t, model.attachments)
+	assert.Equal(t, "why is it crashing?", model.Transcript()[3].Content)
+
+	cmd().(tea.BatchMsg)[0]()
+	mockAgent.AssertExpectations(t)
+}
+
+func TestModel_handleAttach_Errors(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "binary")
+	require.NoError(t, os.WriteFile(binary, []byte{0xff, 0xfe, 0x00}, 0644))
+
+	tests := []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{"Missing path", "/attach", "usage: /attach <path>"},
+		{"Missing file", "/attach " + filepath.Join(dir, "missing.yaml"), "failed to attach"},
+		{"Directory", "/attach " + dir, "is a directory"},
+		{"Binary file", "/attach " + binary, "not a text file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := InitialModel(Config{})
+			model.textarea.SetValue(tt.query)
+
+			newModel, _ := model.handleEnterKey()
+			model = newModel.(Model)
+			assert.ErrorContains(t, model.err, tt.err)
+			assert.Empty(t, model.attachments)
+			assert.Empty(t, model.Transcript())
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package ui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// autosaveMsg asks the model to save a snapshot of the conversation.
+type autosaveMsg struct{}
+
+// scheduleAutosave saves the next snapshot after the autosave interval, nil when
+// autosave is disabled.
+func (m Model) scheduleAutosave() tea.Cmd {
+	if m.config.Autosave == nil || m.config.AutosaveInterval <= 0 {
+		return nil
+	}
+	return tea.Tick(m.config.AutosaveInterval, func(time.Time) tea.Msg {
+		return autosaveMsg{}
+	})
+}
+
+// handleAutosave saves a snapshot of the conversation, so it can be recovered when Klama
+// crashes. Snapshots are skipped while Klama answers or runs a command, as the agent and
+// the executer change their state until they are done.
+func (m Model) handleAutosave() (tea.Model, tea.Cmd) {
+	if !m.busy() && len(m.messages) > 0 {
+		if err := m.config.Autosave(m); err != nil {
+			m.log().Warn("Failed to autosave the session", "error", err)
+		}
+	}
+	return m, m.scheduleAutosave()
+}
@@ -0,0 +1,38 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_handleAutosave(t *testing.T) {
+	var snapshots []int
+	model := InitialModel(Config{
+		Autosave: func(m Model) error {
+			snapshots = append(snapshots, len(m.Transcript()))
+			return nil
+		},
+		AutosaveInterval: time.Minute,
+	})
+
+	// nothing to save before the first message
+	model, cmd := updateModel(model, autosaveMsg{})
+	assert.NotNil(t, cmd)
+	assert.Empty(t, snapshots)
+
+	model.updateChat(SenderUser, "why is api pending?")
+	model.state = StateAsking
+	model, _ = updateModel(model, autosaveMsg{})
+	assert.Empty(t, snapshots)
+
+	model.state = StateTyping
+	_, cmd = updateModel(model, autosaveMsg{})
+	assert.NotNil(t, cmd)
+	assert.Equal(t, []int{1}, snapshots)
+
+	// disabled without an interval
+	model.config.AutosaveInterval = 0
+	assert.Nil(t, model.scheduleAutosave())
+}
@@ -0,0 +1,39 @@
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// checkpointPrompt asks the agent to summarize its findings instead of running the
+// command or plan it suggested.
+const checkpointPrompt = "Your %s was not run: you ran %d commands in a row without the user's input. " +
+	"Do not suggest a command now. Summarize what you found so far and what is left to check, " +
+	"and ask the user whether to continue the investigation."
+
+// checkpointDue reports whether Klama ran as many commands in a row without the user's
+// input as it may.
+func (m Model) checkpointDue() bool {
+	return m.config.MaxIterations > 0 && m.autoRun >= m.config.MaxIterations
+}
+
+// checkpoint skips the suggested command or plan and asks the agent to summarize its
+// findings and ask the user whether to continue. Until the user answers, later commands
+// need confirmation.
+func (m Model) checkpoint(suggestion string) (tea.Model, tea.Cmd) {
+	m.log().Info("Iteration limit reached, asking the agent to summarize", "commands", m.autoRun)
+	m.checkpointAsked = true
+	m.plan = planState{}
+	m.state = StateAsking
+	m.updateChat(SenderSystem, fmt.Sprintf("Klama ran %d commands in a row without you, asking it to summarize its findings before it continues.", m.autoRun))
+	return m, tea.Batch(
+		m.waitForAgentResponse(fmt.Sprintf(checkpointPrompt, suggestion, m.autoRun)),
+		m.think(),
+	)
+}
+
+// checkpointNote explains why a command needs confirmation after the checkpoint.
+func (m Model) checkpointNote() string {
+	return fmt.Sprintf("Klama ran %d commands in a row without you, confirmation is required to continue.", m.autoRun)
+}
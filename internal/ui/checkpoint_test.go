@@ -0,0 +1,67 @@
+package ui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_checkpoint(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockAgent.On("LogUsage").Return("Test usage").Maybe()
+	mockExecuter.On("Validate", mock.Anything).Return(nil)
+
+	model := InitialModel(Config{
+		Agent:           mockAgent,
+		Executer:        mockExecuter,
+		AutoApprove:     true,
+		MaxAutoApproved: 10,
+		MaxIterations:   2,
+	})
+
+	suggest := func(model Model, command string) (Model, tea.Cmd) {
+		newModel, cmd := model.handleAgentResponse(agent.AgentResponse{RunCommand: command, Reason: "Test reason"})
+		return newModel.(Model), cmd
+	}
+
+	for _, command := range []string{"kubectl get pods", "kubectl get events"} {
+		model, _ = suggest(model, command)
+		assert.Equal(t, StateExecuting, model.state)
+	}
+	assert.Equal(t, 2, model.autoRun)
+
+	// the third command is not run, the agent summarizes its findings instead
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return assert.Contains(t, prompt, "Your command `kubectl logs api-0` was not run: you ran 2 commands in a row")
+	})).Return(agent.AgentResponse{}, nil).Once()
+	model, cmd := suggest(model, "kubectl logs api-0")
+	assert.Equal(t, StateAsking, model.state)
+	assert.True(t, model.checkpointAsked)
+	assert.Contains(t, model.messages[len(model.messages)-1].Content, "asking it to summarize its findings")
+	require.NotNil(t, cmd)
+	cmd().(tea.BatchMsg)[0]()
+	mockAgent.AssertExpectations(t)
+
+	// until the user answers, commands need confirmation
+	model, _ = suggest(model, "kubectl logs api-0")
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.Contains(t, model.messages[len(model.messages)-2].Content, "confirmation is required to continue")
+
+	// once the user is back, commands are auto-approved again
+	model.textarea.SetValue("yes")
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Equal(t, StateExecuting, model.state)
+	assert.Zero(t, model.autoRun)
+	assert.False(t, model.checkpointAsked)
+}
+
+func TestModel_checkpointDisabled(t *testing.T) {
+	model := InitialModel(Config{AutoApprove: true, MaxAutoApproved: 10})
+	model.autoRun = 100
+	assert.False(t, model.checkpointDue())
+}
@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const noticeDuration = 2 * time.Second
+
+// clearNoticeMsg clears the footer notice with the given id, unless a newer notice replaced it.
+type clearNoticeMsg int
+
+// writeClipboard copies text to the system clipboard, and with an OSC 52 escape
+// sequence so copying also works over SSH and without a clipboard utility.
+func writeClipboard(text string) error {
+	nativeErr := clipboard.WriteAll(text)
+	if _, err := osc52.New(text).WriteTo(os.Stderr); err != nil && nativeErr != nil {
+		return nativeErr
+	}
+	return nil
+}
+
+// handleCopyCommand copies the pending command.
+func (m Model) handleCopyCommand() (tea.Model, tea.Cmd) {
+	if len(m.pendingResult) > 0 && m.state == StateTyping {
+		return m.copyText(strings.Join(m.pendingResult, "\n"), "Command copied to the clipboard")
+	}
+	if m.confirmationCmd == "" || (m.state != StateWaitingForConfirmation && m.state != StateEditingCommand) {
+		m.err = fmt.Errorf("there is no suggested command to copy")
+		return m, nil
+	}
+	return m.copyText(m.confirmationCmd, "Command copied to the clipboard")
+}
+
+// handleCopyAnswer copies the last Klama message.
+func (m Model) handleCopyAnswer() (tea.Model, tea.Cmd) {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Sender == SenderKlama {
+			return m.copyText(ansi.Strip(m.messages[i].Content), "Klama's answer copied to the clipboard")
+		}
+	}
+	m.err = fmt.Errorf("there is no answer to copy")
+	return m, nil
+}
+
+func (m Model) copyText(text, notice string) (tea.Model, tea.Cmd) {
+	write := m.config.Clipboard
+	if write == nil {
+		write = writeClipboard
+	}
+	if err := write(text); err != nil {
+		m.log().Warn("Failed to copy to the clipboard", "error", err)
+		m.err = fmt.Errorf("failed to copy to the clipboard: %w", err)
+		return m, nil
+	}
+
+	m.err = nil
+	return m.showNotice(notice)
+}
+
+// showNotice shows a transient notice in the footer.
+func (m Model) showNotice(notice string) (tea.Model, tea.Cmd) {
+	m.noticeID++
+	m.notice = notice
+	id := m.noticeID
+	return m, tea.Tick(noticeDuration, func(time.Time) tea.Msg {
+		return clearNoticeMsg(id)
+	})
+}
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"fmt"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_handleCopy(t *testing.T) {
+	var copied string
+	model := InitialModel(Config{Clipboard: func(text string) error {
+		copied = text
+		return nil
+	}})
+
+	// nothing to copy yet
+	newModel, _ := model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyCtrlY})
+	assert.ErrorContains(t, newModel.(Model).err, "no suggested command")
+	newModel, _ = model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y"), Alt: true})
+	assert.ErrorContains(t, newModel.(Model).err, "no answer")
+
+	model.messages = []ChatMessage{
+		{Sender: SenderKlama, Content: "The pod is out of memory."},
+		{Sender: SenderKlama, Content: "I suggest running the command `" + model.systemStyle.Render("kubectl get pods") + "`"},
+	}
+	model.state = StateWaitingForConfirmation
+	model.confirmationCmd = "kubectl get pods"
+
+	newModel, cmd := model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyCtrlY})
+	model = newModel.(Model)
+	assert.Equal(t, "kubectl get pods", copied)
+	assert.Contains(t, model.renderErrorMessage(), "Command copied to the clipboard")
+	require.NotNil(t, cmd)
+
+	newModel, _ = model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y"), Alt: true})
+	model = newModel.(Model)
+	assert.Equal(t, "I suggest running the command `kubectl get pods`", copied)
+
+	// only the latest notice is cleared
+	newModel, _ = model.Update(clearNoticeMsg(1))
+	assert.NotEmpty(t, newModel.(Model).notice)
+	newModel, _ = model.Update(clearNoticeMsg(2))
+	assert.Empty(t, newModel.(Model).notice)
+}
+
+func TestModel_handleCopy_Error(t *testing.T) {
+	model := InitialModel(Config{Clipboard: func(string) error {
+		return fmt.Errorf("no clipboard")
+	}})
+	model.state = StateWaitingForConfirmation
+	model.confirmationCmd = "kubectl get pods"
+
+	newModel, cmd := model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyCtrlY})
+	assert.Nil(t, cmd)
+	assert.ErrorContains(t, newModel.(Model).err, "failed to copy to the clipboard: no clipboard")
+	assert.Empty(t, newModel.(Model).notice)
+}
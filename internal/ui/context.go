@@ -0,0 +1,131 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const contextCommand = "/context"
+
+// ContextSwitcher is implemented by sessions whose commands run against a kube context,
+// to list the contexts of the kubeconfig and point the session at another one without
+// changing the current context of the kubeconfig.
+type ContextSwitcher interface {
+	Contexts() ([]string, error)
+	SwitchContext(ctx context.Context, name string) (SwitchedContext, error)
+}
+
+// SwitchedContext is the session after it switched to another kube context.
+type SwitchedContext struct {
+	Executer Executer // runs the commands against the context, with an empty cache
+	Target   Target
+	Memory   Memory // facts learned about the context, nil when memory is disabled
+}
+
+// contextSwitchedMsg reports the outcome of switching the kube context.
+type contextSwitchedMsg struct {
+	name     string
+	switched SwitchedContext
+	err      error
+}
+
+// handleContext lists the contexts of the kubeconfig for a `/context` message, and
+// switches the session to the context of a `/context <name>` message.
+func (m Model) handleContext(query string) (tea.Model, tea.Cmd) {
+	if m.config.Contexts == nil {
+		m.err = fmt.Errorf("this session does not run commands against a kube context")
+		return m, nil
+	}
+	contexts, err := m.config.Contexts.Contexts()
+	if err != nil {
+		m.log().Warn("Failed to list the kube contexts", "error", err)
+		m.err = fmt.Errorf("failed to list the kube contexts: %w", err)
+		return m, nil
+	}
+
+	name := strings.TrimSpace(strings.TrimPrefix(query, contextCommand))
+	if name == "" {
+		m.err = nil
+		m.textarea.Reset()
+		m.updateChat(SenderSystem, m.renderContexts(contexts))
+		return m, nil
+	}
+	switch {
+	case !slices.Contains(contexts, name):
+		m.err = fmt.Errorf("context %s is not in the kubeconfig, type %s to list the contexts", name, contextCommand)
+		return m, nil
+	case name == m.config.Target.Context:
+		m.err = fmt.Errorf("commands already run against context %s", name)
+		return m, nil
+	case m.config.DryRun:
+		m.err = fmt.Errorf("the context cannot be switched in dry run mode, as you run the commands")
+		return m, nil
+	}
+
+	m.err = nil
+	m.textarea.Reset()
+	m.switchingContext = name
+	m.showPrompt("Switching to context " + name + "...")
+
+	switcher := m.config.Contexts
+	ctx := m.requestCtx
+	return m, func() tea.Msg {
+		switched, err := switcher.SwitchContext(ctx, name)
+		return contextSwitchedMsg{name: name, switched: switched, err: err}
+	}
+}
+
+// renderContexts lists the contexts, marking the one commands run against.
+func (m Model) renderContexts(contexts []string) string {
+	if len(contexts) == 0 {
+		return "The kubeconfig has no contexts."
+	}
+
+	var b strings.Builder
+	b.WriteString("Contexts of the kubeconfig, type " + contextCommand + " <name> to switch:")
+	for _, name := range contexts {
+		marker := "  "
+		if name == m.config.Target.Context {
+			marker = "* "
+		}
+		b.WriteString("\n" + marker + name)
+	}
+	return b.String()
+}
+
+// handleContextSwitched points the session at the new context, stops the watch of the
+// previous one, and tells the agent with the next message.
+func (m Model) handleContextSwitched(msg contextSwitchedMsg) (tea.Model, tea.Cmd) {
+	m.switchingContext = ""
+	m.notice = ""
+	if msg.err != nil {
+		m.log().Warn("Failed to switch the kube context", "context", msg.name, "error", msg.err)
+		m.err = fmt.Errorf("failed to switch to context %s: %w", msg.name, msg.err)
+		return m, nil
+	}
+
+	previous := m.config.Target.Context
+	m.log().Info("Switched the kube context", "from", previous, "to", msg.name)
+	m.executer = msg.switched.Executer
+	m.config.Executer = msg.switched.Executer
+	m.config.Target = msg.switched.Target
+	m.config.Memory = msg.switched.Memory
+
+	text := fmt.Sprintf("Switched to context %s, commands now run against it.", msg.name)
+	if m.watch != nil {
+		text += fmt.Sprintf(" Stopped watching `%v`.", m.systemStyle.Render(m.watch.command))
+		m.watch = nil
+	}
+	if m.config.Target.Protected {
+		text += " The context is protected, approve commands by typing its name."
+	}
+	m.updateChat(SenderSystem, text)
+
+	m.contextNote = fmt.Sprintf("The user switched the session from kube context %s to %s. "+
+		"Commands now run against %s, the outputs of earlier commands describe %s.\n", previous, msg.name, msg.name, previous)
+	return m, nil
+}
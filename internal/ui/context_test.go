@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockContextSwitcher struct {
+	mock.Mock
+}
+
+func (m *MockContextSwitcher) Contexts() ([]string, error) {
+	args := m.Called()
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockContextSwitcher) SwitchContext(ctx context.Context, name string) (SwitchedContext, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(SwitchedContext), args.Error(1)
+}
+
+func TestModel_handleContext(t *testing.T) {
+	mockAgent := new(MockAgent)
+	switcher := new(MockContextSwitcher)
+	prodExecuter := new(MockExecuter)
+	switcher.On("Contexts").Return([]string{"staging", "prod"}, nil)
+	switcher.On("SwitchContext", mock.Anything, "prod").Return(SwitchedContext{
+		Executer: prodExecuter,
+		Target:   Target{Context: "prod", Namespace: "default", Protected: true},
+	}, nil)
+
+	model := InitialModel(Config{
+		Agent:    mockAgent,
+		Executer: new(MockExecuter),
+		Target:   Target{Context: "staging", Namespace: "default"},
+		Contexts: switcher,
+	})
+	model.watch = &watch{id: 1, command: "kubectl get pods"}
+
+	// the current context is marked
+	model.textarea.SetValue("/context")
+	newModel, cmd := model.handleEnterKey()
+	model = newModel.(Model)
+	assert.Nil(t, cmd)
+	assert.Equal(t, "Contexts of the kubeconfig, type /context <name> to switch:\n* staging\n  prod", model.Transcript()[0].Content)
+
+	model.textarea.SetValue("/context dev")
+	newModel, _ = model.handleEnterKey()
+	model = newModel.(Model)
+	require.Error(t, model.err)
+	assert.Contains(t, model.err.Error(), "context dev is not in the kubeconfig")
+
+	model.textarea.SetValue("/context prod")
+	newModel, cmd = model.handleEnterKey()
+	model = newModel.(Model)
+	require.NoError(t, model.err)
+	require.NotNil(t, cmd)
+	assert.Equal(t, "prod", model.switchingContext)
+
+	// messages wait for the switch
+	model.textarea.SetValue("why are pods pending?")
+	newModel, _ = model.handleEnterKey()
+	model = newModel.(Model)
+	require.Error(t, model.err)
+
+	model, _ = updateModel(model, cmd())
+	assert.Empty(t, model.switchingContext)
+	assert.Same(t, prodExecuter, model.executer)
+	assert.Equal(t, "prod", model.config.Target.Context)
+	assert.True(t, model.config.Target.Protected)
+	assert.Nil(t, model.watch)
+	assert.Contains(t, model.Transcript()[1].Content, "Switched to context prod")
+	assert.Contains(t, model.Transcript()[1].Content, "Stopped watching")
+
+	// the agent is told with the next message
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(message string) bool {
+		return strings.HasPrefix(message, "The user switched the session from kube context staging to prod.") &&
+			strings.HasSuffix(message, "why are pods pending?")
+	})).Return(agent.AgentResponse{Answer: "Checking prod"}, nil)
+	model.err = nil
+	model.textarea.SetValue("why are pods pending?")
+	newModel, cmd = model.handleEnterKey()
+	model = newModel.(Model)
+	assert.Empty(t, model.contextNote)
+	cmd().(tea.BatchMsg)[0]()
+	mockAgent.AssertExpectations(t)
+}
+
+func TestModel_handleContext_NotAvailable(t *testing.T) {
+	model := InitialModel(Config{Agent: new(MockAgent), Executer: new(MockExecuter)})
+	model.textarea.SetValue("/context")
+	newModel, cmd := model.handleEnterKey()
+	model = newModel.(Model)
+	assert.Nil(t, cmd)
+	require.Error(t, model.err)
+	assert.Contains(t, model.err.Error(), "does not run commands against a kube context")
+}
@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/eliran89c/klama/internal/redact"
+)
+
+const resultCommand = "/result"
+
+// awaitResult asks the user to run the approved commands themselves, as dry runs never
+// execute commands, and to paste their output back with /result.
+func (m Model) awaitResult(commands []string) (tea.Model, tea.Cmd) {
+	m.state = StateTyping
+	m.commandTimeout = 0
+	m.pendingResult = commands
+
+	lines := make([]string, len(commands))
+	for i, command := range commands {
+		lines[i] = fmt.Sprintf("`%v`", m.systemStyle.Render(command))
+	}
+	m.updateChat(SenderSystem, fmt.Sprintf(
+		"Dry run, the command was not executed. Run it yourself:\n%s\nThen paste its output with `%s <output>`, copy it with Ctrl+Y, or send any other message to skip it.",
+		strings.Join(lines, "\n"), resultCommand))
+	return m, nil
+}
+
+// handleResult sends the output the user pasted with `/result <output>` to the agent as
+// the output of the commands awaiting it.
+func (m Model) handleResult(query string) (tea.Model, tea.Cmd) {
+	if len(m.pendingResult) == 0 {
+		m.err = fmt.Errorf("there is no command waiting for its output")
+		return m, nil
+	}
+	output := strings.TrimSpace(strings.TrimPrefix(query, resultCommand))
+	if output == "" {
+		m.err = fmt.Errorf("usage: %s <output>", resultCommand)
+		return m, nil
+	}
+
+	commands := m.pendingResult
+	m.pendingResult = nil
+	m.err = nil
+	m.textarea.Reset()
+	m.updateChat(SenderUser, fmt.Sprintf("%s (%d lines)", resultCommand, strings.Count(output, "\n")+1))
+
+	if len(commands) == 1 {
+		return m.handleExecuterResponse(executer.ExecuterResponse{Stdout: output})
+	}
+
+	// the output of a plan answers all of its steps at once
+	m.state = StateAsking
+	result, redacted := m.config.Redactor.Redact(output)
+	m.addCommandOutput(result, 0)
+	if redacted > 0 {
+		m.updateChat(SenderSystem, fmt.Sprintf("%d sensitive value(s) were replaced with %s before sending the output to Klama.", redacted, redact.Placeholder))
+	}
+	return m, tea.Batch(
+		m.waitForAgentResponse(fmt.Sprintf("The user ran the plan commands `%s` and pasted their output:\n%s", strings.Join(commands, "`, `"), result)),
+		m.think(),
+	)
+}
+
+// skippedResultNote tells the agent the user did not paste the output of the commands
+// awaiting it, and sent a message instead.
+func (m *Model) skippedResultNote() string {
+	if len(m.pendingResult) == 0 {
+		return ""
+	}
+	note := fmt.Sprintf("The user did not run `%s` or did not paste its output.\n", strings.Join(m.pendingResult, "`, `"))
+	m.pendingResult = nil
+	return note
+}
@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_DryRun(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockExecuter.On("Validate", "kubectl get pods").Return(nil)
+
+	model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter, DryRun: true, AutoApprove: true, MaxAutoApproved: 5})
+	model.ready = true
+	assert.Contains(t, model.headerView(), "[dry run]")
+
+	// auto-approve does not run commands either
+	model, _ = updateModel(model, agent.AgentResponse{RunCommand: "kubectl get pods", Reason: "list pods"})
+	require.Equal(t, StateWaitingForConfirmation, model.state)
+
+	model.textarea.SetValue("yes")
+	model, cmd := updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Nil(t, cmd)
+	assert.Equal(t, StateTyping, model.state)
+	assert.Equal(t, []string{"kubectl get pods"}, model.pendingResult)
+	assert.Contains(t, model.messages[len(model.messages)-1].Content, "/result <output>")
+
+	mockAgent.On("Iterate", mock.Anything, "Command output:\nNAME  READY\nweb   1/1").Return(agent.AgentResponse{Answer: "The pod is ready."}, nil)
+
+	model.textarea.SetValue("/result NAME  READY\nweb   1/1\n")
+	model, cmd = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.NoError(t, model.err)
+	assert.Equal(t, StateAsking, model.state)
+	assert.Empty(t, model.pendingResult)
+	transcript := model.Transcript()
+	assert.Equal(t, "/result (2 lines)", transcript[len(transcript)-2].Content)
+	assert.True(t, transcript[len(transcript)-1].Output)
+
+	cmd().(tea.BatchMsg)[0]()
+	mockAgent.AssertExpectations(t)
+	mockExecuter.AssertNotCalled(t, "Run", mock.Anything, mock.Anything)
+}
+
+func TestModel_DryRun_SkippedResult(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("Iterate", mock.Anything, "The user did not run `kubectl get pods` or did not paste its output.\nwhat else can I check?").Return(agent.AgentResponse{Answer: "Check the events."}, nil)
+
+	model := InitialModel(Config{Agent: mockAgent, DryRun: true})
+	model.confirmationCmd = "kubectl get pods"
+	newModel, _ := model.executeConfirmedCommand()
+	model = newModel.(Model)
+
+	model.textarea.SetValue("what else can I check?")
+	model, cmd := updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Empty(t, model.pendingResult)
+
+	cmd().(tea.BatchMsg)[0]()
+	mockAgent.AssertExpectations(t)
+}
+
+func TestModel_DryRun_Plan(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockExecuter.On("Validate", mock.Anything).Return(nil)
+
+	model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter, DryRun: true})
+	model.ready = true
+	model, _ = updateModel(model, agent.AgentResponse{Plan: []agent.PlanStep{
+		{Command: "kubectl get pods", Reason: "list pods"},
+		{Command: "kubectl get nodes", Reason: "list nodes"},
+	}})
+	require.Equal(t, StatePlanApproval, model.state)
+
+	model.textarea.SetValue("yes")
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Equal(t, StateTyping, model.state)
+	assert.False(t, model.plan.active())
+	assert.Equal(t, []string{"kubectl get pods", "kubectl get nodes"}, model.pendingResult)
+
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.HasPrefix(prompt, "The user ran the plan commands `kubectl get pods`, `kubectl get nodes` and pasted their output:\nweb Running")
+	})).Return(agent.AgentResponse{Answer: "All good."}, nil)
+
+	model.textarea.SetValue("/result web Running\nnode-1 Ready")
+	model, cmd := updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Equal(t, StateAsking, model.state)
+
+	cmd().(tea.BatchMsg)[0]()
+	mockAgent.AssertExpectations(t)
+	mockExecuter.AssertNotCalled(t, "Run", mock.Anything, mock.Anything)
+}
+
+func TestModel_handleResult_Errors(t *testing.T) {
+	model := InitialModel(Config{DryRun: true})
+	model.textarea.SetValue("/result some output")
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.ErrorContains(t, model.err, "there is no command waiting for its output")
+
+	model.pendingResult = []string{"kubectl get pods"}
+	model.textarea.SetValue("/result")
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.ErrorContains(t, model.err, "usage: /result <output>")
+	assert.Equal(t, []string{"kubectl get pods"}, model.pendingResult)
+}
@@ -0,0 +1,67 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Explainer breaks a suggested command down in plain language before it is approved.
+type Explainer interface {
+	Explain(ctx context.Context, command string) (string, error)
+}
+
+// explanationMsg carries the explanation of a suggested command.
+type explanationMsg struct {
+	command     string
+	explanation string
+	err         error
+}
+
+// explain asks the explainer to break the pending command down, flag by flag. The
+// command keeps waiting for its confirmation meanwhile.
+func (m Model) explain() (tea.Model, tea.Cmd) {
+	if m.config.Explainer == nil {
+		m.err = fmt.Errorf("command explanations are not available in this session")
+		return m, nil
+	}
+	if m.explaining != "" {
+		m.err = fmt.Errorf("the command is being explained")
+		return m, nil
+	}
+
+	command := m.confirmationCmd
+	m.explaining = command
+	m.showPrompt("Explaining the command...")
+
+	explainer := m.config.Explainer
+	ctx := m.requestCtx
+	timeout := m.agentTimeout()
+	return m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		explanation, err := explainer.Explain(ctx, command)
+		return explanationMsg{command: command, explanation: explanation, err: err}
+	}
+}
+
+// handleExplanation shows the explanation of a command that still waits for its
+// confirmation, followed by how to answer it.
+func (m Model) handleExplanation(msg explanationMsg) (tea.Model, tea.Cmd) {
+	m.explaining = ""
+	m.notice = ""
+	if m.state != StateWaitingForConfirmation || msg.command != m.confirmationCmd {
+		return m, nil
+	}
+	if msg.err != nil {
+		m.log().Warn("Failed to explain the command", "command", msg.command, "error", msg.err)
+		m.err = msg.err
+		return m, nil
+	}
+
+	m.updateChat(SenderKlama, fmt.Sprintf("Here is what `%v` does:\n%s", m.systemStyle.Render(msg.command), msg.explanation))
+	m.updateChat(SenderSystem, m.confirmationHelp())
+	return m, nil
+}
@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// explainerFunc adapts a function to the Explainer interface.
+type explainerFunc func(context.Context, string) (string, error)
+
+func (f explainerFunc) Explain(ctx context.Context, command string) (string, error) {
+	return f(ctx, command)
+}
+
+func TestModel_explain(t *testing.T) {
+	newModel := func(explainer Explainer) Model {
+		mockAgent := new(MockAgent)
+		mockExecuter := new(MockExecuter)
+		mockAgent.On("LogUsage").Return("Test usage").Maybe()
+		mockExecuter.On("Validate", mock.Anything).Return(nil)
+
+		model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter, Explainer: explainer})
+		newModel, _ := model.handleAgentResponse(agent.AgentResponse{RunCommand: "kubectl get pods -A", Reason: "Test reason"})
+		model = newModel.(Model)
+		require.Equal(t, StateWaitingForConfirmation, model.state)
+		return model
+	}
+	answer := func(model Model, input string) (Model, tea.Cmd) {
+		model.textarea.SetValue(input)
+		newModel, cmd := model.handleConfirmation()
+		return newModel.(Model), cmd
+	}
+
+	t.Run("shows the explanation and keeps waiting for confirmation", func(t *testing.T) {
+		model := newModel(explainerFunc(func(_ context.Context, command string) (string, error) {
+			return "- " + command + ": lists the pods of all namespaces", nil
+		}))
+
+		model, cmd := answer(model, "x")
+		require.NotNil(t, cmd)
+		assert.Equal(t, "Explaining the command...", model.notice)
+
+		// a second request waits for the first
+		model, _ = answer(model, "explain")
+		assert.EqualError(t, model.err, "the command is being explained")
+
+		model, _ = updateModel(model, cmd())
+		assert.Equal(t, StateWaitingForConfirmation, model.state)
+		assert.Empty(t, model.notice)
+		assert.Contains(t, model.messages[len(model.messages)-2].Content, "- kubectl get pods -A: lists the pods of all namespaces")
+		assert.Equal(t, model.confirmationHelp(), model.messages[len(model.messages)-1].Content)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		model := newModel(explainerFunc(func(context.Context, string) (string, error) {
+			return "", errors.New("failed to explain the command: rate limit exceeded")
+		}))
+		model, cmd := answer(model, "explain")
+		messages := len(model.messages)
+		model, _ = updateModel(model, cmd())
+		assert.EqualError(t, model.err, "failed to explain the command: rate limit exceeded")
+		assert.Len(t, model.messages, messages)
+	})
+
+	t.Run("the command was answered meanwhile", func(t *testing.T) {
+		model := newModel(explainerFunc(func(context.Context, string) (string, error) {
+			return "explanation", nil
+		}))
+		model, cmd := answer(model, "x")
+		model, _ = answer(model, "ask")
+		messages := len(model.messages)
+		model, _ = updateModel(model, cmd())
+		assert.Len(t, model.messages, messages)
+		assert.Empty(t, model.explaining)
+	})
+
+	t.Run("no explainer", func(t *testing.T) {
+		model, cmd := answer(newModel(nil), "x")
+		assert.Nil(t, cmd)
+		assert.EqualError(t, model.err, "command explanations are not available in this session")
+	})
+}
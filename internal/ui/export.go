@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// handleExport writes the transcript to a Markdown file in the current directory.
+func (m Model) handleExport() (tea.Model, tea.Cmd) {
+	if len(m.messages) == 0 {
+		m.err = fmt.Errorf("nothing to export yet")
+		return m, nil
+	}
+
+	path, err := m.exportMarkdown(".", time.Now())
+	if err != nil {
+		m.log().Warn("Failed to export transcript", "error", err)
+		m.err = fmt.Errorf("failed to export transcript: %w", err)
+		return m, nil
+	}
+
+	m.err = nil
+	m.appendMessage(ChatMessage{Sender: SenderSystem, Content: "Transcript exported to " + path})
+	m.updateViewportContent()
+	return m, nil
+}
+
+// exportMarkdown writes the transcript to a timestamped Markdown file in dir and returns its path.
+func (m Model) exportMarkdown(dir string, now time.Time) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("klama-transcript-%s.md", now.Format("20060102-150405")))
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	if err := os.WriteFile(path, []byte(m.renderMarkdown(now)), 0644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// renderMarkdown renders the full transcript, including command outputs, as Markdown.
+func (m Model) renderMarkdown(now time.Time) string {
+	var sb strings.Builder
+
+	title := "Klama transcript"
+	if m.agentName != "" {
+		title += " - " + m.agentName
+	}
+	fmt.Fprintf(&sb, "# %s\n\n_Exported on %s_\n", title, now.Format(time.RFC1123))
+
+	for _, msg := range m.messages {
+		content := strings.TrimSpace(ansi.Strip(msg.Content))
+		if msg.Output {
+			fmt.Fprintf(&sb, "\n#### Command output\n\n```text\n%s\n```\n", content)
+			continue
+		}
+		if msg.Thinking {
+			fmt.Fprintf(&sb, "\n<details>\n<summary>Thinking</summary>\n\n%s\n\n</details>\n", content)
+			continue
+		}
+		fmt.Fprintf(&sb, "\n### %s\n\n%s\n", msg.Sender, content)
+		if msg.Usage != "" {
+			fmt.Fprintf(&sb, "\n_%s_\n", msg.Usage)
+		}
+	}
+
+	if usage := m.agent.LogUsage(); usage != "" {
+		fmt.Fprintf(&sb, "\n---\n\n_Usage: %s_\n", usage)
+	}
+
+	return sb.String()
+}
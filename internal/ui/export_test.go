@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_exportMarkdown(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("LogUsage").Return("Test usage")
+
+	model := InitialModel(Config{Agent: mockAgent, AgentName: "Kubernetes"})
+	model.messages = []ChatMessage{
+		{Sender: SenderUser, Content: "why is my pod failing?"},
+		{Sender: SenderKlama, Content: "I suggest running the command `" + model.systemStyle.Render("kubectl get pods -A") + "`"},
+		{Sender: SenderSystem, Content: "Command output:\nNAME READY", Output: true},
+	}
+
+	now := time.Date(2024, 7, 1, 10, 30, 0, 0, time.UTC)
+	path, err := model.exportMarkdown(t.TempDir(), now)
+	require.NoError(t, err)
+	assert.Equal(t, "klama-transcript-20240701-103000.md", filepath.Base(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	content := string(data)
+	assert.Contains(t, content, "# Klama transcript - Kubernetes")
+	assert.Contains(t, content, "### You\n\nwhy is my pod failing?")
+	assert.Contains(t, content, "I suggest running the command `kubectl get pods -A`")
+	assert.Contains(t, content, "#### Command output\n\n```text\nCommand output:\nNAME READY\n```")
+	assert.Contains(t, content, "Test usage")
+	assert.NotContains(t, content, "\x1b[")
+}
+
+func TestModel_handleExport_Empty(t *testing.T) {
+	model := InitialModel(Config{})
+
+	newModel, _ := model.handleExport()
+	assert.Error(t, newModel.(Model).err)
+}
@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// showFollowups lists the follow-up questions the agent suggested under its answer. The
+// user asks one by pressing its number while the input is empty.
+func (m *Model) showFollowups(followups []string) {
+	if len(followups) == 0 {
+		return
+	}
+	m.followups = followups
+
+	var b strings.Builder
+	b.WriteString("Suggested follow-ups:")
+	for i, followup := range followups {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, followup)
+	}
+	fmt.Fprintf(&b, "\nPress 1-%d to ask one, or type your own question.", len(followups))
+	m.updateChat(SenderSystem, b.String())
+}
+
+// followupKey returns the index of the follow-up picked by the key. Numbers pick a
+// follow-up only while the input is empty, so they can still be typed in a message.
+func (m Model) followupKey(msg tea.KeyMsg) (int, bool) {
+	if m.state != StateTyping || len(m.followups) == 0 || m.textarea.Value() != "" {
+		return 0, false
+	}
+	if msg.Type != tea.KeyRunes || msg.Paste || len(msg.Runes) != 1 {
+		return 0, false
+	}
+
+	i := int(msg.Runes[0] - '1')
+	if i < 0 || i >= len(m.followups) {
+		return 0, false
+	}
+	return i, true
+}
@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_Followups(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("Iterate", mock.Anything, "How do I raise the limit?").Return(agent.AgentResponse{Answer: "Edit the deployment."}, nil)
+
+	model := InitialModel(Config{Agent: mockAgent})
+	model.ready = true
+	model, _ = updateModel(model, agent.AgentResponse{
+		Answer:             "The pod is out of memory.",
+		SuggestedFollowups: []string{"Which pods are affected?", "How do I raise the limit?"},
+	})
+
+	followups := model.messages[len(model.messages)-1].Content
+	assert.Contains(t, followups, "1. Which pods are affected?\n2. How do I raise the limit?")
+	assert.Contains(t, followups, "Press 1-2 to ask one")
+
+	// numbers out of range and numbers typed in a message are regular input
+	model, cmd := updateModel(model, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("3")})
+	assert.Equal(t, StateTyping, model.state)
+	assert.Equal(t, "3", model.textarea.Value())
+	model.textarea.Reset()
+
+	model, cmd = updateModel(model, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("2")})
+	require.Equal(t, StateAsking, model.state)
+	assert.Equal(t, "How do I raise the limit?", model.messages[len(model.messages)-1].Content)
+	assert.Nil(t, model.followups)
+
+	response := cmd().(tea.BatchMsg)[0]()
+	model, _ = updateModel(model, response)
+	assert.Equal(t, "Edit the deployment.", model.messages[len(model.messages)-1].Content)
+	mockAgent.AssertExpectations(t)
+}
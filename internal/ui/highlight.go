@@ -0,0 +1,166 @@
+package ui
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"gopkg.in/yaml.v3"
+)
+
+// maxHighlightSize is the size of the largest output that is highlighted, larger
+// outputs would slow down every redraw of the chat.
+const maxHighlightSize = 256 * 1024
+
+// Output formats that are highlighted
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+	formatDiff = "diff"
+)
+
+// yamlKeyPattern matches the first line of a YAML document: a key, a list item or a
+// document separator.
+var yamlKeyPattern = regexp.MustCompile(`^(---|- |[\w./-]+:(\s|$))`)
+
+// detectFormat returns the format of a command output, or an empty string when it is
+// neither a JSON value, a YAML mapping or list nor a unified diff, such as a table.
+func detectFormat(output string) string {
+	trimmed := strings.TrimSpace(output)
+	if trimmed == "" || len(trimmed) > maxHighlightSize {
+		return ""
+	}
+	if strings.HasPrefix(trimmed, "--- ") && strings.Contains(trimmed, "\n+++ ") {
+		return formatDiff
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return formatJSON
+	}
+	// a single line such as "error: not found" is a message rather than a document
+	if !strings.Contains(trimmed, "\n") || !yamlKeyPattern.MatchString(trimmed) {
+		return ""
+	}
+	var document any
+	if err := yaml.Unmarshal([]byte(trimmed), &document); err != nil {
+		return ""
+	}
+	switch document.(type) {
+	case map[string]any, []any:
+		return formatYAML
+	default:
+		return ""
+	}
+}
+
+// highlightOutput highlights a command output that is YAML, JSON or a diff and adds
+// indentation guides to documents. The first line, which describes the command, and stderr are left as is.
+func (m Model) highlightOutput(content string) string {
+	header, output, ok := strings.Cut(content, "\n")
+	if !ok {
+		return content
+	}
+	output, stderr, hasStderr := strings.Cut(output, "\nStderr:\n")
+
+	format := detectFormat(output)
+	if format == "" || m.config.Plain {
+		return content
+	}
+	highlighted, err := m.highlight(format, output)
+	if err != nil {
+		m.log().Debug("Failed to highlight command output", "format", format, "error", err)
+		return content
+	}
+
+	content = header + "\n" + highlighted
+	if hasStderr {
+		content += "\nStderr:\n" + stderr
+	}
+	return content
+}
+
+// highlight colors output with the syntax style of the theme, and draws a guide at every
+// indentation level.
+func (m Model) highlight(format, output string) (string, error) {
+	iterator, err := lexers.Get(format).Tokenise(nil, output)
+	if err != nil {
+		return "", err
+	}
+	style := styles.Get(m.theme.syntax())
+	var b strings.Builder
+	if err := formatters.TTY256.Format(&b, style, iterator); err != nil {
+		return "", err
+	}
+
+	// the lines of a diff start with their change, not their indentation, and its lexer
+	// ends the output with a newline
+	if format == formatDiff {
+		highlighted := b.String()
+		if i := strings.LastIndex(highlighted, "\n"); i >= 0 && !strings.HasSuffix(output, "\n") {
+			highlighted = highlighted[:i] + highlighted[i+1:]
+		}
+		return highlighted, nil
+	}
+
+	// the formatter keeps the lines of the output, so they match the plain lines
+	plainLines := strings.Split(output, "\n")
+	lines := strings.Split(b.String(), "\n")
+	if len(lines) != len(plainLines) {
+		return b.String(), nil
+	}
+	step := indentStep(plainLines)
+	for i, line := range lines {
+		indent := len(plainLines[i]) - len(strings.TrimLeft(plainLines[i], " "))
+		lines[i] = m.indentGuides(line, indent, step)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// indentStep returns the smallest indentation of the lines, the width of a level.
+func indentStep(lines []string) int {
+	step := 0
+	for _, line := range lines {
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent > 0 && strings.TrimSpace(line) != "" && (step == 0 || indent < step) {
+			step = indent
+		}
+	}
+	return step
+}
+
+// indentGuides replaces the first space of every indentation level in the leading
+// spaces of a highlighted line with a guide, skipping the escape sequences of the colors.
+func (m Model) indentGuides(line string, indent, step int) string {
+	if step == 0 || indent == 0 {
+		return line
+	}
+	guide := m.helpStyle.Render("│")
+
+	var b strings.Builder
+	spaces := 0
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\x1b':
+			end := strings.IndexByte(line[i:], 'm')
+			if end < 0 {
+				b.WriteString(line[i:])
+				return b.String()
+			}
+			b.WriteString(line[i : i+end+1])
+			i += end
+		case spaces < indent && line[i] == ' ':
+			if spaces%step == 0 {
+				b.WriteString(guide)
+			} else {
+				b.WriteByte(' ')
+			}
+			spaces++
+		default:
+			b.WriteString(line[i:])
+			return b.String()
+		}
+	}
+	return b.String()
+}
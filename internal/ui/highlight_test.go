@@ -0,0 +1,51 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"json object", "{\n  \"kind\": \"Pod\"\n}\n", formatJSON},
+		{"json list", `["a", "b"]`, formatJSON},
+		{"yaml mapping", "apiVersion: v1\nkind: Pod\nmetadata:\n  name: api\n", formatYAML},
+		{"yaml list", "- name: api\n- name: web\n", formatYAML},
+		{"table", "NAME   READY   STATUS\napi-0  1/1     Running\n", ""},
+		{"single line", "error: the server doesn't have a resource type \"pod\"", ""},
+		{"logs", "2024-05-01T10:00:00Z level: info\n2024-05-01T10:00:01Z level: warn\n", ""},
+		{"invalid json", "{\"kind\": ", ""},
+		{"diff", "--- kubectl get deploy web\n+++ kubectl get pod web-0\n@@ -1 +1 @@\n-image: web:1.2\n+image: web:1.1\n", formatDiff},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, detectFormat(tt.output))
+		})
+	}
+}
+
+func TestModel_highlightOutput(t *testing.T) {
+	model := InitialModel(Config{})
+
+	content := "Command output:\napiVersion: v1\nmetadata:\n  labels:\n    app: api\nStderr:\nWarning: deprecated"
+	highlighted := model.highlightOutput(content)
+	assert.NotEqual(t, content, highlighted)
+	assert.Equal(t, "Command output:\napiVersion: v1\nmetadata:\n│ labels:\n│ │ app: api\nStderr:\nWarning: deprecated", ansi.Strip(highlighted))
+
+	table := "Command output:\nNAME   READY\napi-0  1/1"
+	assert.Equal(t, table, model.highlightOutput(table))
+
+	// diffs are colored without indentation guides
+	diff := "Command output:\n--- a\n+++ b\n@@ -1,2 +1,2 @@\n spec:\n-  image: web:1.2\n+  image: web:1.1"
+	highlighted = model.highlightOutput(diff)
+	assert.NotEqual(t, diff, highlighted)
+	assert.Equal(t, diff, ansi.Strip(highlighted))
+}
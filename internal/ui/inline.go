@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// printMessages prints the messages added since the last update above the input in
+// inline mode, where they become part of the scrollback of the terminal. A plan waiting
+// for approval is printed once it is answered, as its steps change until then. Plain mode
+// also prints the changes of the state.
+func (m Model) printMessages(cmd tea.Cmd) (Model, tea.Cmd) {
+	if m.width == 0 {
+		return m, cmd
+	}
+
+	var text string
+	end := m.printableMessages()
+	if m.printed < end {
+		rendered := make([]string, 0, end-m.printed)
+		for i := m.printed; i < end; i++ {
+			rendered = append(rendered, m.renderMessage(i, m.messages[i]))
+		}
+		m.printed = end
+		text = lipgloss.NewStyle().Width(m.width).Render(strings.Join(rendered, "\n\n")) + "\n"
+	}
+	if lines := m.announcements(); len(lines) > 0 {
+		text += strings.Join(lines, "\n")
+	}
+	if text == "" {
+		return m, cmd
+	}
+	return m, tea.Batch(cmd, tea.Println(strings.TrimSuffix(text, "\n")))
+}
+
+// printableMessages is the number of messages that no longer change.
+func (m Model) printableMessages() int {
+	if m.state == StatePlanApproval {
+		return min(len(m.messages), m.plan.message)
+	}
+	return len(m.messages)
+}
+
+// inlineView renders the messages that are not printed yet above the input, without the
+// header and the chat history, which is in the scrollback.
+func (m Model) inlineView() string {
+	var rendered []string
+	for i := m.printed; i < len(m.messages); i++ {
+		rendered = append(rendered, m.renderMessage(i, m.messages[i]))
+	}
+	var pending string
+	if len(rendered) > 0 {
+		pending = lipgloss.NewStyle().Width(m.width).Render(strings.Join(rendered, "\n\n")) + "\n"
+	}
+	return pending + lipgloss.JoinVertical(
+		lipgloss.Left,
+		m.renderInputArea(),
+		m.renderErrorMessage(),
+		m.renderHelpText(),
+		m.renderStatusBar(),
+	)
+}
@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_Inline(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("LogUsage").Return("Test usage")
+	model := InitialModel(Config{Agent: mockAgent, Inline: true, SplitPane: true})
+	assert.False(t, model.split)
+
+	model, _ = updateModel(model, tea.WindowSizeMsg{Width: 102, Height: 60})
+	model.ready = true
+	model.updateChat(SenderUser, "why is api pending?")
+	model.addCommandOutput(podList(20), 0)
+	assert.Equal(t, 0, model.printed)
+
+	// pending messages are shown above the input, in full, until the next update prints them
+	view := ansi.Strip(model.View())
+	assert.Contains(t, view, "why is api pending?")
+	assert.Contains(t, view, "api-19 1/1 Running")
+	assert.NotContains(t, view, "more lines")
+
+	model, cmd := updateModel(model, tickMsg{})
+	assert.NotNil(t, cmd)
+	assert.Equal(t, 2, model.printed)
+	view = ansi.Strip(model.View())
+	assert.NotContains(t, view, "why is api pending?")
+	assert.NotContains(t, view, "Ctrl+N")
+
+	// the output pane and the search need the full-screen chat
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyCtrlS})
+	assert.False(t, model.split)
+	assert.ErrorContains(t, model.err, "not available in inline mode")
+
+	model.textarea.SetValue("/api")
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.False(t, model.search.active())
+	assert.ErrorContains(t, model.err, "search the scrollback")
+}
+
+func TestModel_Inline_HoldsPlan(t *testing.T) {
+	model := InitialModel(Config{Inline: true})
+	model.width = 100
+	model.updateChat(SenderUser, "check the namespace")
+	model.updateChat(SenderKlama, "I suggest running this plan:")
+	model.state = StatePlanApproval
+	model.plan.message = 1
+
+	model, _ = model.printMessages(nil)
+	assert.Equal(t, 1, model.printed)
+
+	model.state = StateTyping
+	model, _ = model.printMessages(nil)
+	assert.Equal(t, 2, model.printed)
+}
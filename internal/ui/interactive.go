@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"fmt"
+	"os/exec"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// InteractiveRunner is implemented by executers with interactive commands, such as
+// kubectl exec -it, that take over the terminal instead of running in the background.
+type InteractiveRunner interface {
+	Interactive(string) bool
+	InteractiveCommand(string) (*exec.Cmd, error)
+}
+
+// interactiveDoneMsg reports that an interactive command exited and the chat resumed.
+type interactiveDoneMsg struct {
+	err error
+}
+
+// interactiveOf returns the runner of the command when it is interactive.
+func interactiveOf(exec Executer, command string) (InteractiveRunner, bool) {
+	runner, ok := exec.(InteractiveRunner)
+	if !ok || !runner.Interactive(command) {
+		return nil, false
+	}
+	return runner, true
+}
+
+// runInteractive suspends the chat and hands the terminal to the approved interactive
+// command until it exits.
+func (m Model) runInteractive(runner InteractiveRunner) (tea.Model, tea.Cmd) {
+	cmd, err := runner.InteractiveCommand(m.confirmationCmd)
+	if err != nil {
+		m.state = StateTyping
+		m.err = fmt.Errorf("failed to start the command: %w", err)
+		return m, nil
+	}
+
+	m.log().Info("Handing the terminal to the command", "command", m.confirmationCmd)
+	m.state = StateExecuting
+	m.commandTimeout = 0
+	m.updateChat(SenderSystem, fmt.Sprintf("Running `%v` in the terminal, the chat resumes when it exits.", m.systemStyle.Render(m.confirmationCmd)))
+	return m, tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return interactiveDoneMsg{err: err}
+	})
+}
+
+// handleInteractiveDone resumes the chat after an interactive command and asks the user
+// to describe what happened, as its output is not captured.
+func (m Model) handleInteractiveDone(msg interactiveDoneMsg) (tea.Model, tea.Cmd) {
+	m.state = StateTyping
+	m.interactiveCmd = m.confirmationCmd
+	m.interactiveErr = msg.err
+
+	status := "exited"
+	if msg.err != nil {
+		status = fmt.Sprintf("failed: %v", msg.err)
+	}
+	m.updateChat(SenderSystem, fmt.Sprintf("`%v` %s. Describe what you found, your summary is sent to Klama as the outcome of the command.", m.systemStyle.Render(m.interactiveCmd), status))
+	return m, nil
+}
+
+// interactiveNote tells the agent that the next message summarizes the interactive
+// command the user ran.
+func (m *Model) interactiveNote() string {
+	if m.interactiveCmd == "" {
+		return ""
+	}
+
+	note := fmt.Sprintf("The user ran `%v` in the terminal", m.interactiveCmd)
+	if m.interactiveErr != nil {
+		note += fmt.Sprintf(", it failed: %v", m.interactiveErr)
+	}
+	note += ". Their summary of what happened:\n"
+
+	if m.editedFromCmd != "" {
+		note = fmt.Sprintf("The user modified your suggested command `%v`.\n", m.editedFromCmd) + note
+		m.editedFromCmd = ""
+	}
+	m.interactiveCmd, m.interactiveErr = "", nil
+	return note
+}
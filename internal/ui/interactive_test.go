@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockInteractiveExecuter runs interactiveCmd in the terminal.
+type MockInteractiveExecuter struct {
+	MockExecuter
+	interactiveCmd string
+}
+
+func (m *MockInteractiveExecuter) Interactive(command string) bool {
+	return command == m.interactiveCmd
+}
+
+func (m *MockInteractiveExecuter) InteractiveCommand(command string) (*exec.Cmd, error) {
+	return exec.Command("true"), nil
+}
+
+func TestModel_Interactive(t *testing.T) {
+	const command = "kubectl exec -it web-0 -- sh"
+	mockAgent := new(MockAgent)
+	mockExecuter := &MockInteractiveExecuter{interactiveCmd: command}
+	mockExecuter.On("Validate", command).Return(nil)
+
+	model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter, AutoApprove: true, MaxAutoApproved: 5})
+	model.ready = true
+
+	// interactive commands are never approved automatically
+	model, _ = updateModel(model, agent.AgentResponse{RunCommand: command, Reason: "look at the config"})
+	require.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.Equal(t, 0, model.autoApproved)
+
+	model.textarea.SetValue("yes")
+	model, cmd := updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.NotNil(t, cmd)
+	assert.Equal(t, StateExecuting, model.state)
+
+	model, _ = updateModel(model, interactiveDoneMsg{err: errors.New("exit status 130")})
+	assert.Equal(t, StateTyping, model.state)
+	assert.Contains(t, model.messages[len(model.messages)-1].Content, "failed: exit status 130")
+
+	mockAgent.On("Iterate", mock.Anything, "The user ran `"+command+"` in the terminal, it failed: exit status 130. Their summary of what happened:\nthe config file is missing").
+		Return(agent.AgentResponse{Answer: "The config map is not mounted."}, nil)
+
+	model.textarea.SetValue("the config file is missing")
+	model, cmd = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Empty(t, model.interactiveCmd)
+
+	cmd().(tea.BatchMsg)[0]()
+	mockAgent.AssertExpectations(t)
+	mockExecuter.AssertNotCalled(t, "Run", mock.Anything, mock.Anything)
+}
+
+func TestModel_proposePlan_InteractiveStep(t *testing.T) {
+	mockExecuter := &MockInteractiveExecuter{interactiveCmd: "kubectl port-forward svc/web 8080:80"}
+	mockExecuter.On("Validate", mock.Anything).Return(nil)
+
+	model := InitialModel(Config{Agent: new(MockAgent), Executer: mockExecuter})
+	model.ready = true
+	model, _ = updateModel(model, agent.AgentResponse{Plan: []agent.PlanStep{
+		{Command: "kubectl get svc web", Reason: "check the service"},
+		{Command: "kubectl port-forward svc/web 8080:80", Reason: "try it"},
+	}})
+	require.Equal(t, StatePlanApproval, model.state)
+	assert.Contains(t, model.plan.steps[1].blocked, "takes over the terminal")
+	assert.False(t, model.plan.steps[1].checked)
+}
@@ -0,0 +1,35 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Memory records the durable facts the agent learns for later sessions.
+type Memory interface {
+	Remember(...string) ([]string, error)
+}
+
+// rememberFacts records the facts the agent learned, without the credentials they may
+// contain, and tells the user which facts are new.
+func (m *Model) rememberFacts(facts []string) {
+	if m.config.Memory == nil || len(facts) == 0 {
+		return
+	}
+
+	redacted := make([]string, 0, len(facts))
+	for _, fact := range facts {
+		fact, _ = m.config.Redactor.Redact(fact)
+		redacted = append(redacted, fact)
+	}
+
+	added, err := m.config.Memory.Remember(redacted...)
+	if err != nil {
+		m.log().Warn("Failed to remember facts", "error", err)
+		m.updateChat(SenderSystem, m.errorStyle.Render(fmt.Sprintf("Failed to remember facts: %v", err)))
+		return
+	}
+	if len(added) > 0 {
+		m.updateChat(SenderSystem, "Remembered for later sessions:\n- "+strings.Join(added, "\n- "))
+	}
+}
@@ -0,0 +1,44 @@
+package ui
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/redact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMemory remembers every fact it has not seen.
+type fakeMemory struct {
+	facts []string
+}
+
+func (f *fakeMemory) Remember(facts ...string) ([]string, error) {
+	var added []string
+	for _, fact := range facts {
+		if !slices.Contains(f.facts, fact) {
+			f.facts = append(f.facts, fact)
+			added = append(added, fact)
+		}
+	}
+	return added, nil
+}
+
+func TestModel_RememberFacts(t *testing.T) {
+	redactor, err := redact.New(nil, false)
+	require.NoError(t, err)
+	memory := &fakeMemory{facts: []string{"ingress is nginx in namespace infra"}}
+
+	model := InitialModel(Config{Agent: new(MockAgent), Memory: memory, Redactor: redactor})
+	model, _ = updateModel(model, agent.AgentResponse{
+		Answer:   "The ingress has no endpoints.",
+		Remember: []string{"ingress is nginx in namespace infra", "registry password=hunter2 is in secret regcred"},
+	})
+
+	assert.Equal(t, []string{"ingress is nginx in namespace infra", "registry password=" + redact.Placeholder + " is in secret regcred"}, memory.facts)
+	require.Len(t, model.messages, 2)
+	assert.Equal(t, "Remembered for later sessions:\n- registry password="+redact.Placeholder+" is in secret regcred", model.messages[0].Content)
+	assert.Equal(t, "The ingress has no endpoints.", model.messages[1].Content)
+}
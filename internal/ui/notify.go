@@ -0,0 +1,100 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Notification methods
+const (
+	NotifyBell   = "bell"
+	NotifyOSC9   = "osc9"
+	NotifyOSC777 = "osc777"
+)
+
+// Notifications alert the user when Klama answers while they are away.
+type Notifications struct {
+	Method string        // NotifyBell, NotifyOSC9 or NotifyOSC777, empty disables notifications
+	After  time.Duration // also notify when the terminal is focused, when the answer took longer, 0 never does
+}
+
+// notificationSequence returns the escape sequence that shows the notification with the
+// method. Control characters are removed from the message, as they would end it early.
+func notificationSequence(method, message string) string {
+	message = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f || (method == NotifyOSC777 && r == ';') {
+			return ' '
+		}
+		return r
+	}, message)
+
+	switch method {
+	case NotifyOSC9:
+		return "\x1b]9;" + message + "\a"
+	case NotifyOSC777:
+		return "\x1b]777;notify;Klama;" + message + "\a"
+	default:
+		return "\a"
+	}
+}
+
+// writeNotification writes the notification to the terminal.
+func writeNotification(method, message string) error {
+	_, err := fmt.Fprint(os.Stderr, notificationSequence(method, message))
+	return err
+}
+
+// trackBusy records when Klama became busy, and notifies the user when Klama is done
+// and they are away: the terminal is not focused, or Klama took longer than configured.
+// Keys never notify, the user is there to press them.
+func (m Model) trackBusy(wasBusy bool, msg tea.Msg, cmd tea.Cmd) (Model, tea.Cmd) {
+	switch {
+	case !wasBusy && m.busy():
+		m.busySince = time.Now()
+	case wasBusy && !m.busy():
+		if _, ok := msg.(tea.KeyMsg); ok {
+			return m, cmd
+		}
+		if notify := m.notification(); notify != nil {
+			return m, tea.Batch(cmd, notify)
+		}
+	}
+	return m, cmd
+}
+
+// notification returns the command that notifies the user Klama is done, nil when the
+// user does not need to be notified.
+func (m Model) notification() tea.Cmd {
+	settings := m.config.Notifications
+	if settings.Method == "" {
+		return nil
+	}
+	slow := settings.After > 0 && !m.busySince.IsZero() && time.Since(m.busySince) >= settings.After
+	if !m.blurred && !slow {
+		return nil
+	}
+
+	message := "Klama answered"
+	switch {
+	case m.err != nil:
+		message = "Klama failed: " + m.err.Error()
+	case m.state == StateWaitingForConfirmation || m.state == StatePlanApproval:
+		message = "Klama is waiting for your approval"
+	}
+
+	notify := m.config.Notify
+	if notify == nil {
+		notify = writeNotification
+	}
+	log := m.log()
+	return func() tea.Msg {
+		if err := notify(settings.Method, message); err != nil {
+			log.Warn("Failed to send a notification", "error", err)
+		}
+		return nil
+	}
+}
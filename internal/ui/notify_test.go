@@ -0,0 +1,92 @@
+package ui
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/stretchr/testify/assert"
+)
+
+// notifyingModel returns a model asking the agent, with the notifications it sends.
+func notifyingModel(method string, after time.Duration) (Model, *[]string) {
+	var sent []string
+	mockAgent := new(MockAgent)
+	mockAgent.On("LogUsage").Return("Test usage")
+	model := InitialModel(Config{
+		Agent:         mockAgent,
+		Notifications: Notifications{Method: method, After: after},
+		Notify: func(method, message string) error {
+			sent = append(sent, method+": "+message)
+			return nil
+		},
+	})
+	model.ready = true
+	model.state = StateAsking
+	return model, &sent
+}
+
+// runCmd runs a command and the commands of its batches.
+func runCmd(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	if batch, ok := cmd().(tea.BatchMsg); ok {
+		for _, c := range batch {
+			runCmd(c)
+		}
+	}
+}
+
+func TestModel_Notifications(t *testing.T) {
+	t.Run("Unfocused terminal", func(t *testing.T) {
+		model, sent := notifyingModel(NotifyOSC9, 0)
+		model, _ = updateModel(model, tea.BlurMsg{})
+		model, cmd := updateModel(model, agent.AgentResponse{Answer: "The pod is running."})
+		runCmd(cmd)
+		assert.Equal(t, []string{"osc9: Klama answered"}, *sent)
+	})
+
+	t.Run("Focused terminal", func(t *testing.T) {
+		model, sent := notifyingModel(NotifyBell, 0)
+		model, _ = updateModel(model, tea.BlurMsg{})
+		model, _ = updateModel(model, tea.FocusMsg{})
+		_, cmd := updateModel(model, agent.AgentResponse{Answer: "The pod is running."})
+		runCmd(cmd)
+		assert.Empty(t, *sent)
+	})
+
+	t.Run("Slow answer", func(t *testing.T) {
+		model, sent := notifyingModel(NotifyBell, time.Second)
+		model.busySince = time.Now().Add(-time.Minute)
+		_, cmd := updateModel(model, errMsg(errors.New("request failed")))
+		runCmd(cmd)
+		assert.Equal(t, []string{"bell: Klama failed: request failed"}, *sent)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		model, sent := notifyingModel("", 0)
+		model, _ = updateModel(model, tea.BlurMsg{})
+		_, cmd := updateModel(model, agent.AgentResponse{Answer: "The pod is running."})
+		runCmd(cmd)
+		assert.Empty(t, *sent)
+	})
+
+	t.Run("Busy since", func(t *testing.T) {
+		model, _ := notifyingModel(NotifyBell, 0)
+		model.state = StateTyping
+		model.textarea.SetValue("why is the pod pending?")
+		model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+		assert.Equal(t, StateAsking, model.state)
+		assert.False(t, model.busySince.IsZero())
+	})
+}
+
+func TestNotificationSequence(t *testing.T) {
+	assert.Equal(t, "\a", notificationSequence(NotifyBell, "Klama answered"))
+	assert.Equal(t, "\x1b]9;Klama answered\a", notificationSequence(NotifyOSC9, "Klama answered"))
+	assert.Equal(t, "\x1b]777;notify;Klama;Klama failed: a b\a", notificationSequence(NotifyOSC777, "Klama failed: a;b"))
+	assert.Equal(t, "\x1b]9;failed  exit\a", notificationSequence(NotifyOSC9, "failed\n\x1bexit"))
+}
@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// collapsedOutputLines is the number of lines a collapsed command output shows.
+const collapsedOutputLines = 5
+
+// collapsible reports whether the message is a command output too long to show in full
+// until it is expanded.
+func collapsible(msg ChatMessage) bool {
+	return msg.Output && strings.Count(msg.Content, "\n") >= collapsedOutputLines
+}
+
+// handleOutputFocusKey moves the focus between the focusable command outputs with
+// Tab and Shift+Tab, starting from the most recent one, and expands or collapses the
+// focused output with Enter or Space. It reports whether it handled the key.
+func (m Model) handleOutputFocusKey(msg tea.KeyMsg) (Model, bool) {
+	switch {
+	case msg.Type == tea.KeyTab:
+		return m.focusOutput(-1), true
+	case msg.Type == tea.KeyShiftTab:
+		return m.focusOutput(1), true
+	case m.focusedOutput < 0:
+		return m, false
+	case msg.Type == tea.KeyEnter, msg.Type == tea.KeySpace:
+		m.expandedOutputs[m.focusedOutput] = !m.expandedOutputs[m.focusedOutput]
+		m.updateViewportContent()
+		return m, true
+	case msg.Type == tea.KeyEsc:
+		m.focusedOutput = -1
+		m.updateViewportContent()
+		return m, true
+	case msg.Type == tea.KeyUp, msg.Type == tea.KeyDown, msg.Type == tea.KeyPgUp, msg.Type == tea.KeyPgDown,
+		msg.Type == tea.KeyShiftUp, msg.Type == tea.KeyShiftDown:
+		return m, false
+	default:
+		// any other key goes back to the chat
+		m.focusedOutput = -1
+		m.updateViewportContent()
+		return m, false
+	}
+}
+
+// focusOutput moves the focus to the next focusable output in direction, -1 for older
+// outputs and 1 for newer ones, wrapping around the ends.
+func (m Model) focusOutput(direction int) Model {
+	var outputs []int
+	for i, msg := range m.messages {
+		if m.focusable(msg) {
+			outputs = append(outputs, i)
+		}
+	}
+	if len(outputs) == 0 {
+		return m
+	}
+
+	next := len(outputs) - 1
+	for i, index := range outputs {
+		if index == m.focusedOutput {
+			next = ((i+direction)%len(outputs) + len(outputs)) % len(outputs)
+		}
+	}
+	m.focusedOutput = outputs[next]
+	m.updateViewportContent()
+	return m
+}
+
+// renderOutput renders a command output, showing the first lines of long outputs until
+// they are expanded.
+func (m Model) renderOutput(index int, msg ChatMessage) string {
+	text := m.renderOutputContent(index, msg)
+	if annotation := m.renderAnnotation(msg); annotation != "" {
+		text += "\n" + annotation
+	}
+	return text
+}
+
+func (m Model) renderOutputContent(index int, msg ChatMessage) string {
+	if m.split {
+		return m.renderOutputStub(index, msg)
+	}
+	sender := m.senderStyleFor(msg.Sender).Render(msg.Sender + ": ")
+	content := m.highlightOutput(msg.Content)
+	// the scrollback cannot expand outputs, so inline mode shows them in full
+	if !collapsible(msg) || m.config.Inline {
+		return sender + content
+	}
+
+	markerStyle := m.helpStyle
+	if index == m.focusedOutput {
+		markerStyle = matchStyle
+	}
+	if m.expandedOutputs[index] {
+		return markerStyle.Render("▾") + " " + sender + content
+	}
+
+	lines := strings.Split(content, "\n")
+	hidden := m.helpStyle.Render(fmt.Sprintf("… %d more lines", len(lines)-collapsedOutputLines))
+	return markerStyle.Render("▸") + " " + sender + strings.Join(lines[:collapsedOutputLines], "\n") + "\n" + hidden
+}
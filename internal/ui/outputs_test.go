@@ -0,0 +1,92 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/assert"
+)
+
+func podList(pods int) string {
+	lines := []string{"Command output:"}
+	for i := range pods {
+		lines = append(lines, fmt.Sprintf("api-%d 1/1 Running", i))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// chatText returns the visible chat without styles and padding.
+func chatText(model Model) string {
+	lines := strings.Split(ansi.Strip(model.viewport.View()), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestModel_renderOutput(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("LogUsage").Return("Test usage")
+	model := InitialModel(Config{Agent: mockAgent})
+	model, _ = updateModel(model, tea.WindowSizeMsg{Width: 100, Height: 60})
+
+	model.addCommandOutput("Command output:\nshort-output", 0)
+	model.addCommandOutput(podList(20), 0)
+
+	content := chatText(model)
+	assert.Contains(t, content, "System: Command output:\nshort-output")
+	assert.Contains(t, content, "▸ System: Command output:")
+	assert.Contains(t, content, "api-3 1/1 Running")
+	assert.NotContains(t, content, "api-4 1/1 Running")
+	assert.Contains(t, content, "… 16 more lines")
+}
+
+func TestModel_handleOutputFocusKey(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("LogUsage").Return("Test usage")
+	model := InitialModel(Config{Agent: mockAgent})
+	model, _ = updateModel(model, tea.WindowSizeMsg{Width: 100, Height: 60})
+	model.addCommandOutput(podList(10), 0)
+	model.addCommandOutput("Command output:\nshort-output", 0)
+	model.addCommandOutput(podList(30), 0)
+
+	// Tab focuses the most recent long output, then the older ones
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyTab})
+	assert.Equal(t, 2, model.focusedOutput)
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyTab})
+	assert.Equal(t, 0, model.focusedOutput)
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyShiftTab})
+	assert.Equal(t, 2, model.focusedOutput)
+
+	// Enter expands the focused output instead of sending a message
+	model.textarea.SetValue("draft")
+	model, cmd := updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Nil(t, cmd)
+	assert.True(t, model.expandedOutputs[2])
+	assert.Equal(t, "draft", model.textarea.Value())
+	assert.Contains(t, chatText(model), "▾ System: Command output:\napi-0 1/1 Running")
+	assert.NotContains(t, chatText(model), "26 more lines")
+
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeySpace})
+	assert.False(t, model.expandedOutputs[2])
+
+	// typing returns to the chat
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("!")})
+	assert.Equal(t, -1, model.focusedOutput)
+	assert.Equal(t, "draft!", model.textarea.Value())
+	assert.True(t, model.viewport.AtBottom())
+}
+
+func TestModel_handleOutputFocusKey_NoOutputs(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("LogUsage").Return("Test usage")
+	model := InitialModel(Config{Agent: mockAgent})
+	model, _ = updateModel(model, tea.WindowSizeMsg{Width: 100, Height: 60})
+	model.addCommandOutput("Command output:\nshort-output", 0)
+
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyTab})
+	assert.Equal(t, -1, model.focusedOutput)
+}
@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// option is an item of the picker.
+type option string
+
+func (o option) FilterValue() string { return string(o) }
+func (o option) Title() string       { return string(o) }
+func (o option) Description() string { return "" }
+
+// showOptions lists the options the agent offered with its question, and opens a picker
+// in place of the input to choose one. Plain mode only lists them, the user types the
+// choice.
+func (m *Model) showOptions(options []string) {
+	if len(options) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("Options:")
+	for i, option := range options {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, option)
+	}
+	if m.config.Plain {
+		b.WriteString("\nType your choice.")
+		m.updateChat(SenderSystem, b.String())
+		return
+	}
+	b.WriteString("\nChoose one below, or press Esc to type your own answer.")
+	m.updateChat(SenderSystem, b.String())
+
+	items := make([]list.Item, len(options))
+	for i, o := range options {
+		items[i] = option(o)
+	}
+
+	delegate := list.NewDefaultDelegate()
+	delegate.ShowDescription = false
+	delegate.SetSpacing(0)
+	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.
+		Foreground(m.systemStyle.GetForeground()).
+		BorderForeground(m.systemStyle.GetForeground())
+
+	picker := list.New(items, delegate, m.textarea.Width(), m.textarea.Height())
+	picker.SetShowTitle(false)
+	picker.SetShowStatusBar(false)
+	picker.SetShowPagination(false)
+	picker.SetShowHelp(false)
+	picker.KeyMap.Quit.SetEnabled(false)
+	picker.KeyMap.ForceQuit.SetEnabled(false)
+	picker.KeyMap.ShowFullHelp.SetEnabled(false)
+
+	m.picker = &picker
+	m.state = StateChoosing
+}
+
+// handlePickerKey moves through the options, filters them, sends the chosen one as the
+// user's answer or closes the picker so the user can type an answer instead.
+func (m Model) handlePickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	filtering := m.picker.FilterState() == list.Filtering
+
+	switch {
+	case msg.Type == tea.KeyEnter && !filtering:
+		choice, ok := m.picker.SelectedItem().(option)
+		m.closePicker()
+		if !ok {
+			return m, nil
+		}
+		m.autoRun, m.checkpointAsked = 0, false
+		return m.sendMessage(string(choice))
+
+	case msg.Type == tea.KeyEsc && m.picker.FilterState() == list.Unfiltered:
+		m.closePicker()
+		return m, nil
+	}
+
+	picker, cmd := m.picker.Update(msg)
+	m.picker = &picker
+	return m, cmd
+}
+
+// closePicker closes the picker and returns to the input.
+func (m *Model) closePicker() {
+	m.picker = nil
+	if m.state == StateChoosing {
+		m.state = StateTyping
+	}
+}
+
+// renderPickerHelp shows the position in the options and how to choose one.
+func (m Model) renderPickerHelp() string {
+	items := m.picker.VisibleItems()
+	position := fmt.Sprintf("%d/%d", min(m.picker.Index()+1, len(items)), len(items))
+	return m.helpStyle.Render(position + " ↑/↓: to move, /: to filter, Enter: to choose, Esc: to type your own answer.")
+}
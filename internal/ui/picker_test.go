@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_picker(t *testing.T) {
+	response := agent.AgentResponse{Answer: "Which pod should I look at?", Options: []string{"web-0", "web-1", "web-2"}}
+
+	newModel := func(cfg Config) Model {
+		mockAgent := new(MockAgent)
+		mockAgent.On("LogUsage").Return("Test usage").Maybe()
+		cfg.Agent = mockAgent
+		cfg.Executer = new(MockExecuter)
+		newModel, _ := InitialModel(cfg).handleAgentResponse(response)
+		return newModel.(Model)
+	}
+	press := func(model Model, key tea.KeyMsg) (Model, tea.Cmd) {
+		newModel, cmd := model.handleKeyMsg(key)
+		return newModel.(Model), cmd
+	}
+
+	t.Run("sends the chosen option", func(t *testing.T) {
+		model := newModel(Config{})
+		require.Equal(t, StateChoosing, model.state)
+		assert.Contains(t, model.messages[len(model.messages)-1].Content, "1. web-0\n2. web-1\n3. web-2")
+		assert.Contains(t, model.View(), "web-2")
+		assert.Contains(t, model.renderErrorMessage(), "1/3")
+
+		model, _ = press(model, tea.KeyMsg{Type: tea.KeyDown})
+		assert.Contains(t, model.renderErrorMessage(), "2/3")
+
+		model, cmd := press(model, tea.KeyMsg{Type: tea.KeyEnter})
+		require.NotNil(t, cmd)
+		assert.Nil(t, model.picker)
+		assert.Equal(t, StateAsking, model.state)
+		assert.Equal(t, SenderUser, model.messages[len(model.messages)-1].Sender)
+		assert.Equal(t, "web-1", model.messages[len(model.messages)-1].Content)
+	})
+
+	t.Run("keys are not typed in the input", func(t *testing.T) {
+		model := newModel(Config{})
+		model, cmd := press(model, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+		assert.Equal(t, StateChoosing, model.state)
+		assert.Empty(t, model.textarea.Value())
+		if cmd != nil {
+			assert.NotEqual(t, tea.Quit(), cmd())
+		}
+	})
+
+	t.Run("esc returns to the input", func(t *testing.T) {
+		model := newModel(Config{})
+		model, _ = press(model, tea.KeyMsg{Type: tea.KeyEsc})
+		assert.Nil(t, model.picker)
+		assert.Equal(t, StateTyping, model.state)
+	})
+
+	t.Run("plain mode only lists the options", func(t *testing.T) {
+		model := newModel(Config{Plain: true})
+		assert.Equal(t, StateTyping, model.state)
+		assert.Nil(t, model.picker)
+		assert.Contains(t, model.messages[len(model.messages)-1].Content, "Type your choice.")
+	})
+}
@@ -0,0 +1,51 @@
+package ui
+
+// glyph returns the decorative character, or its plain text replacement in plain mode,
+// where screen readers would read the decoration aloud.
+func (m Model) glyph(fancy, plain string) string {
+	if m.config.Plain {
+		return plain
+	}
+	return fancy
+}
+
+// stateLine describes the state of the session as a sentence, printed on every change in
+// plain mode instead of the animated status.
+func (m Model) stateLine() string {
+	switch m.state {
+	case StateAsking:
+		return "Klama is thinking."
+	case StateExecuting:
+		return "Running the command."
+	case StateWaitingForConfirmation, StatePlanApproval:
+		return "Waiting for your approval."
+	case StateEditingCommand:
+		return "Editing the command."
+	default:
+		return "Ready for your message."
+	}
+}
+
+// announcements returns the lines that describe what changed since the last update in
+// plain mode: the state of the session and a new error.
+func (m *Model) announcements() []string {
+	if !m.config.Plain {
+		return nil
+	}
+	var lines []string
+	if line := m.stateLine(); line != m.announcedState {
+		m.announcedState = line
+		lines = append(lines, line)
+	}
+	var errText string
+	if m.err != nil {
+		errText = "Error: " + m.err.Error()
+	}
+	if errText != m.announcedErr {
+		m.announcedErr = errText
+		if errText != "" {
+			lines = append(lines, errText)
+		}
+	}
+	return lines
+}
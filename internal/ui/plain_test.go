@@ -0,0 +1,38 @@
+package ui
+
+import (
+	"errors"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_Plain(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("LogUsage").Return("Test usage")
+	model := InitialModel(Config{Agent: mockAgent, Plain: true, ShowThinking: true})
+	assert.True(t, model.config.Inline)
+	assert.Nil(t, model.Init())
+	assert.Nil(t, model.think())
+
+	model, _ = updateModel(model, tea.WindowSizeMsg{Width: 100, Height: 40})
+	assert.Equal(t, "Ready for your message.", model.announcedState)
+
+	// every change of the state and every new error is announced once
+	model.state = StateAsking
+	model.err = errors.New("request failed")
+	assert.Equal(t, []string{"Klama is thinking.", "Error: request failed"}, model.announcements())
+	assert.Empty(t, model.announcements())
+
+	model.state = StateTyping
+	model.err = nil
+	assert.Equal(t, []string{"Ready for your message."}, model.announcements())
+
+	model.appendMessage(ChatMessage{Sender: SenderKlama, Content: "pondering", Thinking: true})
+	view := model.View()
+	assert.Contains(t, view, "> ")
+	assert.Contains(t, view, "Thinking (1 words), Ctrl+T to expand")
+	assert.NotContains(t, view, "▸")
+	assert.NotContains(t, view, "│")
+}
@@ -0,0 +1,436 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/eliran89c/klama/internal/redact"
+)
+
+// planState is a plan of independent commands the agent proposed, approved at once and
+// run concurrently.
+type planState struct {
+	steps   []planStep
+	intro   string // text of the plan message before the steps
+	message int    // index of the chat message that shows the plan
+	results []planResult
+}
+
+// planResult is the redacted output of a plan step.
+type planResult struct {
+	index    int
+	response executer.ExecuterResponse
+	output   string // redacted stdout
+	stderr   string // redacted stderr
+	warning  string // failed permission check, the step did not run
+}
+
+// planStep is a step of the plan.
+type planStep struct {
+	agent.PlanStep
+	exec    Executer
+	checked bool
+	blocked string // why the step cannot run, such as a policy denial
+	ran     bool
+}
+
+// planStepMsg is the outcome of a plan step.
+type planStepMsg struct {
+	index    int
+	response executer.ExecuterResponse
+	warning  string // failed permission check, the step did not run
+}
+
+// planStepsMsg carries the outcomes of the plan steps that ran, in step order.
+type planStepsMsg []planStepMsg
+
+func (p planState) active() bool {
+	return len(p.steps) > 0
+}
+
+// checked returns the number of checked steps.
+func (p planState) checked() int {
+	n := 0
+	for _, step := range p.steps {
+		if step.checked {
+			n++
+		}
+	}
+	return n
+}
+
+// proposePlan validates the steps of the agent's plan and presents it for approval.
+// Steps that fail validation or are denied by the policy are shown unchecked and
+// cannot run.
+func (m Model) proposePlan(msg agent.AgentResponse) (tea.Model, tea.Cmd) {
+	_, multiTool := m.executer.(ToolSelector)
+
+	steps := make([]planStep, 0, len(msg.Plan))
+	var blocked []string
+	needsConfirmation := false
+	for i, step := range msg.Plan {
+		if !multiTool {
+			step.Tool = ""
+		}
+		s := planStep{PlanStep: step, checked: true}
+
+		exec, err := m.executerFor(step.Tool)
+		if err == nil {
+			err = exec.Validate(step.Command)
+		}
+		if err != nil {
+			s.blocked = fmt.Sprintf("invalid: %v", err)
+		} else if target, ok := mutationOf(exec, step.Command); ok {
+			s.blocked = fmt.Sprintf("changes %v, suggest it on its own so it can be confirmed", target)
+		} else if _, ok := interactiveOf(exec, step.Command); ok {
+			s.blocked = "takes over the terminal, suggest it on its own"
+		} else if name := m.config.Target.ProtectedContext(step.Command); name != "" && name != m.config.Target.protectedName() {
+			s.blocked = fmt.Sprintf("runs against protected context %s, suggest it on its own so it can be confirmed", name)
+		} else if m.config.Policy != nil {
+			decision, err := m.config.Policy.Evaluate(step.Command)
+			switch {
+			case err != nil:
+				s.blocked = fmt.Sprintf("blocked because the policy could not be evaluated: %v", err)
+			case decision.Denied():
+				s.blocked = decision.String()
+			case decision.RequiresConfirmation():
+				needsConfirmation = true
+			}
+		}
+
+		s.exec = exec
+		if s.blocked != "" {
+			s.checked = false
+			blocked = append(blocked, fmt.Sprintf("%d. `%v` %v", i+1, step.Command, s.blocked))
+		}
+		steps = append(steps, s)
+	}
+
+	if len(blocked) == len(steps) {
+		m.state = StateAsking
+		prompt := fmt.Sprintf("None of the steps of the suggested plan can run:\n%v\nSuggest different commands, or end the session.", strings.Join(blocked, "\n"))
+		return m, tea.Batch(
+			m.waitForAgentResponse(prompt),
+			m.think(),
+		)
+	}
+
+	m.state = StatePlanApproval
+	m.plan = planState{steps: steps}
+
+	var klamaResp string
+	if msg.Answer != "" {
+		klamaResp += msg.Answer + "\n"
+	}
+	m.plan.intro = klamaResp + "I suggest running this plan:"
+	m.addKlamaMessage(m.plan.intro)
+	m.plan.message = len(m.messages) - 1
+	m.renderPlan()
+
+	if m.config.AutoApprove {
+		checked := m.plan.checked()
+		switch {
+		case m.config.Target.Protected:
+			m.updateChat(SenderSystem, fmt.Sprintf("Context %s is protected, confirmation is required.", m.config.Target.protectedName()))
+		case needsConfirmation:
+			m.updateChat(SenderSystem, "The policy requires confirmation.")
+		case m.checkpointDue() && !m.checkpointAsked:
+			return m.checkpoint("plan")
+		case m.checkpointDue():
+			m.updateChat(SenderSystem, m.checkpointNote())
+		case m.autoApproved+checked <= m.config.MaxAutoApproved:
+			m.autoApproved += checked
+			m.autoRun++
+			m.updateChat(SenderSystem, fmt.Sprintf("Auto-approved (%d/%d), executing the plan", m.autoApproved, m.config.MaxAutoApproved))
+			return m.runPlan()
+		default:
+			m.updateChat(SenderSystem, fmt.Sprintf("The plan exceeds the auto-approve limit of %d commands, confirmation is required.", m.config.MaxAutoApproved))
+		}
+	}
+
+	m.updateChat(SenderSystem, m.planHelp())
+	return m, nil
+}
+
+// renderPlan shows the plan with a checkbox per step in its chat message.
+func (m *Model) renderPlan() {
+	lines := []string{m.plan.intro}
+	for i, step := range m.plan.steps {
+		box := "[ ]"
+		if step.checked {
+			box = "[x]"
+		}
+		line := fmt.Sprintf("%s %d. `%v`", box, i+1, m.systemStyle.Render(step.Command))
+		if step.Tool != "" {
+			line += " with the " + m.systemStyle.Render(step.Tool) + " tool"
+		}
+		if step.blocked != "" {
+			line += " " + m.errorStyle.Render(step.blocked)
+		}
+		if step.Reason != "" {
+			line += "\n    " + step.Reason
+		}
+		lines = append(lines, line)
+	}
+
+	m.messages[m.plan.message].Content = strings.Join(lines, "\n")
+	m.updateViewportContent()
+}
+
+// planHelp explains how to answer a plan.
+func (m Model) planHelp() string {
+	approve := "'yes' to run the checked steps, with a timeout such as 'yes 2m' for slow commands"
+	if m.config.Target.Protected {
+		approve = fmt.Sprintf("the context name (%s) to run the checked steps", m.config.Target.protectedName())
+	}
+	return fmt.Sprintf("Enter %s, step numbers to check or uncheck them (for example '2 4'), 'no' to reject the plan, or 'ask' to break out and ask a question.", approve)
+}
+
+// handlePlanApproval handles the answer to a proposed plan.
+func (m Model) handlePlanApproval() (tea.Model, tea.Cmd) {
+	userInput, timeout, err := splitTimeout(strings.TrimSpace(strings.ToLower(m.textarea.Value())))
+	m.textarea.Reset()
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+
+	if m.config.Target.Protected && userInput == strings.ToLower(m.config.Target.protectedName()) {
+		m.commandTimeout = timeout
+		return m.runPlan()
+	}
+
+	switch userInput {
+	case "yes", "y":
+		if m.config.Target.Protected {
+			m.err = fmt.Errorf("context %s is protected, enter its name to approve the plan", m.config.Target.protectedName())
+			return m, nil
+		}
+		m.commandTimeout = timeout
+		return m.runPlan()
+
+	case "no", "n":
+		m.state = StateAsking
+		m.plan = planState{}
+		rejectMsg := "User did not approve the plan. Please suggest a different command or end the session."
+		m.updateChat(SenderSystem, rejectMsg)
+		return m, tea.Batch(
+			m.waitForAgentResponse(rejectMsg),
+			m.think(),
+		)
+
+	case "ask", "a":
+		m.state = StateTyping
+		m.plan = planState{}
+		m.updateChat(SenderSystem, "Breaking out to ask a question")
+		return m, nil
+	}
+
+	toggled, err := m.toggleSteps(userInput)
+	if err != nil {
+		approve := "'yes'"
+		if m.config.Target.Protected {
+			approve = "the context name"
+		}
+		m.err = fmt.Errorf("please answer with %s, step numbers, 'no', or 'ask': %w", approve, err)
+		return m, nil
+	}
+	m.plan.steps = toggled
+	m.renderPlan()
+	return m, nil
+}
+
+// toggleSteps returns the steps with the numbered steps checked or unchecked.
+func (m Model) toggleSteps(input string) ([]planStep, error) {
+	steps := append([]planStep(nil), m.plan.steps...)
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ' ' || r == ',' }) {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > len(steps) {
+			return nil, fmt.Errorf("there is no step %q", field)
+		}
+		if steps[n-1].blocked != "" {
+			return nil, fmt.Errorf("step %d cannot run", n)
+		}
+		steps[n-1].checked = !steps[n-1].checked
+	}
+	return steps, nil
+}
+
+// runPlan runs the checked steps concurrently, as plans only hold independent
+// read-only commands.
+func (m Model) runPlan() (tea.Model, tea.Cmd) {
+	if m.plan.checked() == 0 {
+		m.err = fmt.Errorf("check at least one step to run the plan")
+		m.commandTimeout = 0
+		return m, nil
+	}
+
+	if m.config.DryRun {
+		var commands []string
+		for _, step := range m.plan.steps {
+			if step.checked {
+				commands = append(commands, step.Command)
+			}
+		}
+		m.plan = planState{}
+		return m.awaitResult(commands)
+	}
+
+	var indexes []int
+	var lines []string
+	for i, step := range m.plan.steps {
+		if step.checked {
+			indexes = append(indexes, i)
+			lines = append(lines, fmt.Sprintf("Executing step %d `%v`", i+1, m.systemStyle.Render(step.Command)))
+		}
+	}
+
+	m.state = StateExecuting
+	m.updateChat(SenderSystem, strings.Join(lines, "\n"))
+	cmd := m.waitForPlanSteps(indexes, m.execTimeout())
+	m.commandTimeout = 0
+	return m, tea.Batch(
+		cmd,
+		m.think(),
+	)
+}
+
+// waitForPlanSteps checks and runs the plan steps with a worker pool, each with the
+// timeout, and returns their outcomes in step order.
+func (m Model) waitForPlanSteps(indexes []int, timeout time.Duration) tea.Cmd {
+	jobs := make([]func(context.Context) planStepMsg, len(indexes))
+	for i, index := range indexes {
+		step := m.plan.steps[index]
+		jobs[i] = func(ctx context.Context) planStepMsg {
+			return m.runPlanStep(ctx, index, step)
+		}
+	}
+
+	return func() tea.Msg {
+		results := executer.RunParallel(m.requestCtx, m.config.PlanWorkers, timeout, jobs)
+		if m.requestCtx.Err() != nil {
+			return nil
+		}
+		return planStepsMsg(results)
+	}
+}
+
+// runPlanStep checks and runs a plan step.
+func (m Model) runPlanStep(ctx context.Context, index int, step planStep) planStepMsg {
+	if checker, ok := step.exec.(PreflightChecker); ok {
+		preflightCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		warning, err := checker.Preflight(preflightCtx, step.Command)
+		cancel()
+		if err != nil {
+			m.log().Warn("Preflight check failed", "command", step.Command, "error", err)
+		}
+		if warning != "" {
+			return planStepMsg{index: index, warning: warning}
+		}
+	}
+
+	return planStepMsg{index: index, response: step.exec.Run(ctx, step.Command)}
+}
+
+// handlePlanSteps records the outcomes of the plan steps and sends them to the agent.
+func (m Model) handlePlanSteps(msg planStepsMsg) (tea.Model, tea.Cmd) {
+	if !m.plan.active() {
+		return m, nil
+	}
+
+	var failed []string
+	for _, step := range msg {
+		m.plan.steps[step.index].ran = true
+
+		if step.warning != "" {
+			m.updateChat(SenderSystem, m.errorStyle.Render(fmt.Sprintf("Step %d was not run, the permission check failed: %v", step.index+1, step.warning)))
+			m.plan.results = append(m.plan.results, planResult{index: step.index, warning: step.warning})
+			continue
+		}
+
+		result, redacted := m.config.Redactor.Redact(step.response.Stdout)
+		stderr, redactedStderr := m.config.Redactor.Redact(step.response.Stderr)
+		redacted += redactedStderr
+		m.addCommandOutput(executer.FormatOutput(step.response, result, stderr), step.response.Duration)
+		if redacted > 0 {
+			m.updateChat(SenderSystem, fmt.Sprintf("%d sensitive value(s) were replaced with %s before sending the output to Klama.", redacted, redact.Placeholder))
+		}
+		m.plan.results = append(m.plan.results, planResult{index: step.index, response: step.response, output: result, stderr: stderr})
+
+		if step.response.Error != nil {
+			failed = append(failed, strconv.Itoa(step.index+1))
+		}
+	}
+
+	if len(failed) > 0 {
+		m.updateChat(SenderSystem, m.errorStyle.Render(fmt.Sprintf("Step(s) %s failed.", strings.Join(failed, ", "))))
+	}
+	return m.finishPlan()
+}
+
+// finishPlan sends the results of the plan to the agent, telling it which steps did
+// not run.
+func (m Model) finishPlan() (tea.Model, tea.Cmd) {
+	var notes []string
+	for i, step := range m.plan.steps {
+		switch {
+		case step.ran:
+		case step.blocked != "":
+			notes = append(notes, fmt.Sprintf("Step %d `%v` was not run: %v", i+1, step.Command, step.blocked))
+		case !step.checked:
+			notes = append(notes, fmt.Sprintf("Step %d `%v` was skipped by the user.", i+1, step.Command))
+		}
+	}
+
+	plan := m.plan
+	m.state = StateAsking
+	m.plan = planState{}
+	return m, tea.Batch(
+		m.processPlanResults(plan, notes),
+		m.think(),
+	)
+}
+
+// processPlanResults formats the outputs of the plan steps for the agent, shrinking
+// them with the configured output processor, and asks the agent to continue.
+func (m Model) processPlanResults(plan planState, notes []string) tea.Cmd {
+	return func() tea.Msg {
+		sections := make([]string, 0, len(plan.results))
+		for _, result := range plan.results {
+			command := plan.steps[result.index].Command
+			header := fmt.Sprintf("Step %d `%v`:\n", result.index+1, command)
+			if result.warning != "" {
+				sections = append(sections, header+"Not run, the permission check failed: "+result.warning)
+				continue
+			}
+
+			output := result.output
+			if m.config.OutputProcessor != nil {
+				ctx, cancel := context.WithTimeout(m.requestCtx, m.agentTimeout())
+				processed, err := m.config.OutputProcessor.Process(ctx, command, output)
+				cancel()
+				if m.requestCtx.Err() != nil {
+					return nil
+				}
+				if err != nil {
+					m.log().Warn("Failed to process command output", "command", command, "error", err)
+				} else {
+					output = processed
+				}
+			}
+			sections = append(sections, header+executer.FormatOutput(result.response, output, result.stderr))
+		}
+
+		message := "Plan results:\n" + strings.Join(sections, "\n\n")
+		if len(notes) > 0 {
+			message += "\n\n" + strings.Join(notes, "\n")
+		}
+		return outputProcessedMsg(message)
+	}
+}
@@ -0,0 +1,111 @@
+package ui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var testPlan = agent.AgentResponse{Answer: "Let's look around.", Plan: []agent.PlanStep{
+	{Command: "kubectl get pods", Reason: "list pods"},
+	{Command: "kubectl delete pod web", Reason: "restart"},
+	{Command: "kubectl get events", Reason: "list events"},
+	{Command: "kubectl get nodes", Reason: "list nodes"},
+}}
+
+func TestModel_proposePlan(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockExecuter.On("Validate", "kubectl delete pod web").Return(executer.ErrSubCommandNotAllowed)
+	mockExecuter.On("Validate", mock.Anything).Return(nil)
+	mockExecuter.On("Run", mock.Anything, "kubectl get pods").Return(executer.ExecuterResponse{Stdout: "web Running"})
+	mockExecuter.On("Run", mock.Anything, "kubectl get nodes").Return(executer.ExecuterResponse{Stdout: "node-1 Ready"})
+
+	model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter})
+	model, _ = updateModel(model, testPlan)
+	require.Equal(t, StatePlanApproval, model.state)
+	assert.Equal(t, OutcomeAwaitingApproval, model.Outcome())
+
+	// the invalid step is unchecked and cannot be checked
+	plan := model.messages[model.plan.message].Content
+	assert.Contains(t, plan, "[x] 1. `kubectl get pods`")
+	assert.Contains(t, plan, "[ ] 2. `kubectl delete pod web`")
+	model.textarea.SetValue("2")
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Error(t, model.err)
+
+	// steps are unchecked by their number
+	model.textarea.SetValue("3")
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Contains(t, model.messages[model.plan.message].Content, "[ ] 3. `kubectl get events`")
+	assert.Equal(t, StatePlanApproval, model.state)
+
+	model.textarea.SetValue("yes")
+	model, cmd := updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Equal(t, StateExecuting, model.state)
+	model, cmd = updateModel(model, cmd().(tea.BatchMsg)[0]())
+	assert.Equal(t, StateAsking, model.state)
+	assert.False(t, model.plan.active())
+
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.HasPrefix(prompt, "Plan results:\nStep 1 `kubectl get pods`:\nCommand output:\nweb Running\n\nStep 4 `kubectl get nodes`:") &&
+			strings.Contains(prompt, "Step 2 `kubectl delete pod web` was not run: invalid") &&
+			strings.Contains(prompt, "Step 3 `kubectl get events` was skipped by the user.")
+	})).Return(agent.AgentResponse{Answer: "All good."}, nil)
+	_, cmd = updateModel(model, cmd().(tea.BatchMsg)[0]())
+	cmd().(tea.BatchMsg)[0]()
+
+	mockAgent.AssertExpectations(t)
+	mockExecuter.AssertNumberOfCalls(t, "Run", 2)
+}
+
+func TestModel_proposePlan_FailedStep(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockExecuter.On("Validate", mock.Anything).Return(nil)
+	mockExecuter.On("Run", mock.Anything, "kubectl get pods").Return(executer.ExecuterResponse{Stdout: "forbidden", Error: errors.New("exit status 1")})
+	mockExecuter.On("Run", mock.Anything, "kubectl get events").Return(executer.ExecuterResponse{Stdout: "no events"})
+
+	model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter, AutoApprove: true, MaxAutoApproved: 10})
+
+	// auto-approved plans run right away and count every step
+	model, cmd := updateModel(model, agent.AgentResponse{Plan: []agent.PlanStep{
+		{Command: "kubectl get pods"},
+		{Command: "kubectl get events"},
+	}})
+	assert.Equal(t, StateExecuting, model.state)
+	assert.Equal(t, 2, model.autoApproved)
+
+	model, cmd = updateModel(model, cmd().(tea.BatchMsg)[0]())
+	assert.Equal(t, StateAsking, model.state)
+
+	// a failed step does not stop the others
+	msg := string(cmd().(tea.BatchMsg)[0]().(outputProcessedMsg))
+	assert.Contains(t, msg, "Step 1 `kubectl get pods`:\n")
+	assert.Contains(t, msg, "forbidden")
+	assert.Contains(t, msg, "Step 2 `kubectl get events`:\nCommand output:\nno events")
+	mockExecuter.AssertNumberOfCalls(t, "Run", 2)
+}
+
+func TestModel_proposePlan_AllStepsBlocked(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockExecuter.On("Validate", mock.Anything).Return(executer.ErrCommandNotAllowed)
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.HasPrefix(prompt, "None of the steps of the suggested plan can run")
+	})).Return(agent.AgentResponse{Answer: "ok"}, nil)
+
+	model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter})
+	model, cmd := updateModel(model, testPlan)
+	assert.Equal(t, StateAsking, model.state)
+	cmd().(tea.BatchMsg)[0]()
+
+	mockAgent.AssertExpectations(t)
+}
@@ -0,0 +1,85 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// probeTimeout bounds the check of the model at the start of the session.
+const probeTimeout = 10 * time.Second
+
+// probeMsg reports the outcome of the check of the model.
+type probeMsg struct {
+	latency time.Duration
+	err     error
+}
+
+// probe checks that the model answers before the first message, nil when the session
+// has no probe.
+func (m Model) probe() tea.Cmd {
+	if m.config.Probe == nil {
+		return nil
+	}
+	ctx := m.ctx
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+		latency, err := m.config.Probe(ctx)
+		return probeMsg{latency: latency, err: err}
+	}
+}
+
+// handleProbe shows the outcome of the check under the welcome message. Once the chat
+// shows messages, such as a resumed conversation, only a failure is shown, as an error.
+func (m Model) handleProbe(msg probeMsg) (tea.Model, tea.Cmd) {
+	m.probed = &msg
+	if msg.err != nil {
+		m.log().Warn("Model check failed", "error", msg.err)
+	} else {
+		m.log().Debug("Model check succeeded", "latency", msg.latency)
+	}
+
+	switch {
+	case m.config.Inline:
+		return m, tea.Println(m.renderProbeStatus())
+	case m.ready:
+		if msg.err != nil && m.err == nil {
+			m.err = fmt.Errorf("could not reach the model: %w", msg.err)
+		}
+	default:
+		m.viewport.SetContent(m.welcome())
+	}
+	return m, nil
+}
+
+// welcome returns the message shown before the first message of the session, with the
+// outcome of the check of the model.
+func (m Model) welcome() string {
+	if m.config.Probe == nil {
+		return welcomeMsg
+	}
+	return welcomeMsg + "\n\n" + m.renderProbeStatus()
+}
+
+// renderProbeStatus renders the outcome of the check of the model, or that it runs.
+func (m Model) renderProbeStatus() string {
+	switch {
+	case m.probed == nil:
+		return m.helpStyle.Render("Checking the connection to the model...")
+	case m.probed.err != nil:
+		return m.errorStyle.Render(fmt.Sprintf("Could not reach %s: %v", m.modelName(), m.probed.err))
+	default:
+		return m.helpStyle.Render(fmt.Sprintf("Connected to %s in %s.", m.modelName(), m.probed.latency.Round(time.Millisecond)))
+	}
+}
+
+// modelName returns the name of the model shown to the user.
+func (m Model) modelName() string {
+	if m.config.ModelName != "" {
+		return m.config.ModelName
+	}
+	return "the model"
+}
@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_probe(t *testing.T) {
+	newModel := func(err error) Model {
+		mockAgent := new(MockAgent)
+		mockAgent.On("LogUsage").Return("Test usage")
+		model := InitialModel(Config{
+			Agent:     mockAgent,
+			ModelName: "gpt-4o",
+			Probe: func(context.Context) (time.Duration, error) {
+				return 312 * time.Millisecond, err
+			},
+		})
+		model, _ = updateModel(model, tea.WindowSizeMsg{Width: 100, Height: 30})
+		return model
+	}
+
+	t.Run("shows the latency under the welcome message", func(t *testing.T) {
+		model := newModel(nil)
+		assert.Contains(t, chatText(model), "Checking the connection to the model...")
+
+		cmd := model.probe()
+		require.NotNil(t, cmd)
+		model, _ = updateModel(model, cmd())
+		assert.Contains(t, chatText(model), "Welcome to Klama!")
+		assert.Contains(t, chatText(model), "Connected to gpt-4o in 312ms.")
+		assert.NoError(t, model.err)
+	})
+
+	t.Run("shows a failure under the welcome message", func(t *testing.T) {
+		model := newModel(errors.New("the endpoint rejected the auth token"))
+		model, _ = updateModel(model, model.probe()())
+		assert.Contains(t, chatText(model), "Could not reach gpt-4o: the endpoint rejected the auth token")
+	})
+
+	t.Run("shows a failure as an error once the chat started", func(t *testing.T) {
+		model := newModel(errors.New("connection refused"))
+		model.ready = true
+		model, _ = updateModel(model, model.probe()())
+		assert.EqualError(t, model.err, "could not reach the model: connection refused")
+	})
+
+	t.Run("no probe", func(t *testing.T) {
+		model := InitialModel(Config{})
+		assert.Nil(t, model.probe())
+		assert.Equal(t, welcomeMsg, model.welcome())
+	})
+}
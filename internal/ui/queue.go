@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// busy reports whether Klama is answering or a command is running.
+func (m Model) busy() bool {
+	return m.state == StateAsking || m.state == StateExecuting
+}
+
+// queueMessage keeps a message typed while Klama is busy, to send it once Klama answers.
+func (m Model) queueMessage() (tea.Model, tea.Cmd) {
+	query := m.textarea.Value()
+	if strings.TrimSpace(query) == "" {
+		m.err = fmt.Errorf("message cannot be empty")
+		return m, nil
+	}
+	if strings.HasPrefix(query, "/") {
+		m.err = fmt.Errorf("wait for Klama to answer before running %s", strings.Fields(query)[0])
+		return m, nil
+	}
+
+	m.log().Debug("Queued a message", "queued", len(m.queued)+1)
+	m.queued = append(m.queued, query)
+	m.err = nil
+	m.textarea.Reset()
+	return m, nil
+}
+
+// sendQueued sends the queued messages as one message once Klama is done with the
+// previous one, keeping what the user is typing.
+func (m Model) sendQueued(cmd tea.Cmd) (tea.Model, tea.Cmd) {
+	if m.state != StateTyping || len(m.queued) == 0 {
+		return m, cmd
+	}
+
+	query := strings.Join(m.queued, "\n\n")
+	m.queued = nil
+	draft := m.textarea.Value()
+	next, sendCmd := m.sendMessage(query)
+	model := next.(Model)
+	model.textarea.SetValue(draft)
+	return model, tea.Batch(cmd, sendCmd)
+}
+
+// restoreQueued puts the queued messages back in the input when Klama fails or is
+// canceled, so they can be edited before sending them.
+func (m *Model) restoreQueued() {
+	if len(m.queued) == 0 {
+		return
+	}
+	value := strings.Join(m.queued, "\n")
+	if draft := m.textarea.Value(); draft != "" {
+		value += "\n" + draft
+	}
+	m.queued = nil
+	m.textarea.SetValue(value)
+}
+
+// renderQueued shows how many messages are queued in the status bar.
+func (m Model) renderQueued() string {
+	switch len(m.queued) {
+	case 0:
+		return ""
+	case 1:
+		return "1 message queued"
+	default:
+		return fmt.Sprintf("%d messages queued", len(m.queued))
+	}
+}
@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"errors"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_queueMessage(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("LogUsage").Return("Test usage")
+	model := InitialModel(Config{Agent: mockAgent})
+	model.ready = true
+	model.state = StateAsking
+
+	model.textarea.SetValue("and the deployment?")
+	model, cmd := updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Nil(t, cmd)
+	assert.Equal(t, []string{"and the deployment?"}, model.queued)
+	assert.Empty(t, model.textarea.Value())
+	assert.Contains(t, model.renderStatusBar(), "1 message queued")
+
+	model.textarea.SetValue("/attach values.yaml")
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.ErrorContains(t, model.err, "wait for Klama to answer before running /attach")
+	assert.Len(t, model.queued, 1)
+
+	model.textarea.SetValue("and its events?")
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Contains(t, model.renderStatusBar(), "2 messages queued")
+
+	// the answer sends the queued messages, the message being typed is kept
+	model.textarea.SetValue("draft")
+	model, cmd = updateModel(model, agent.AgentResponse{Answer: "The pod is running."})
+	assert.NotNil(t, cmd)
+	assert.Equal(t, StateAsking, model.state)
+	assert.Empty(t, model.queued)
+	assert.Equal(t, "draft", model.textarea.Value())
+
+	transcript := model.Transcript()
+	assert.Equal(t, "The pod is running.", transcript[0].Content)
+	assert.Equal(t, ChatMessage{Sender: SenderUser, Content: "and the deployment?\n\nand its events?"}, ChatMessage{Sender: transcript[1].Sender, Content: transcript[1].Content})
+}
+
+func TestModel_queueMessage_WaitsForCommands(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter})
+	model.ready = true
+	model.state = StateAsking
+	model.queued = []string{"and the deployment?"}
+
+	// a suggested command is answered first
+	mockExecuter.On("Validate", "kubectl get pods").Return(nil)
+	model, _ = updateModel(model, agent.AgentResponse{RunCommand: "kubectl get pods", Reason: "list pods"})
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.Equal(t, []string{"and the deployment?"}, model.queued)
+}
+
+func TestModel_restoreQueued(t *testing.T) {
+	model := InitialModel(Config{})
+	model.state = StateAsking
+	model.queued = []string{"and the deployment?"}
+	model.textarea.SetValue("and")
+
+	model, _ = updateModel(model, errMsg(errors.New("request failed")))
+	assert.Equal(t, StateTyping, model.state)
+	assert.Empty(t, model.queued)
+	assert.Equal(t, "and the deployment?\nand", model.textarea.Value())
+}
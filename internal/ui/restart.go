@@ -0,0 +1,95 @@
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// RestartOptions are the choices of the user when restarting the conversation.
+type RestartOptions struct {
+	Archive   bool // save the conversation as a session to resume later
+	KeepUsage bool // keep the tokens and cost of the conversation in the totals and the budget
+}
+
+// Questions asked before restarting the conversation
+const (
+	restartAskArchive = iota + 1
+	restartAskUsage
+)
+
+// startRestart asks whether to save the conversation and keep its usage before
+// restarting it. A conversation without messages restarts at once.
+func (m Model) startRestart() (tea.Model, tea.Cmd) {
+	if len(m.messages) == 0 {
+		return m.restart(RestartOptions{KeepUsage: true})
+	}
+	m.restartStep = restartAskArchive
+	m.restartOptions = RestartOptions{}
+	m.err = nil
+	m.showPrompt("Restart: save this conversation to resume it later? y/n, Esc: to cancel")
+	return m, nil
+}
+
+// handleRestartKey answers the questions of the restart with y or n, Esc cancels the
+// restart. Other keys are ignored until the questions are answered.
+func (m Model) handleRestartKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var yes bool
+	switch msg.String() {
+	case "y", "Y":
+		yes = true
+	case "n", "N":
+	case "esc":
+		m.restartStep = 0
+		m.showPrompt("")
+		return m, nil
+	case "ctrl+c":
+		m.cancel()
+		return m, tea.Quit
+	default:
+		return m, nil
+	}
+
+	if m.restartStep == restartAskArchive {
+		m.restartOptions.Archive = yes
+		m.restartStep = restartAskUsage
+		m.showPrompt("Restart: keep the tokens and cost of this conversation in the totals and the budget? y/n, Esc: to cancel")
+		return m, nil
+	}
+	m.restartOptions.KeepUsage = yes
+	return m.restart(m.restartOptions)
+}
+
+// showPrompt shows a notice that stays until it is replaced, unlike showNotice.
+func (m *Model) showPrompt(prompt string) {
+	m.noticeID++
+	m.notice = prompt
+}
+
+// restart starts a new conversation with the same configuration, after saving the
+// current one and resetting its usage as the user chose.
+func (m Model) restart(options RestartOptions) (tea.Model, tea.Cmd) {
+	m.log().Debug("Restarting the session", "archive", options.Archive, "keep_usage", options.KeepUsage)
+	m.cancel()
+
+	var restartErr error
+	if m.config.Restart != nil {
+		restartErr = m.config.Restart(m, options)
+	}
+	m.agent.Reset()
+
+	cfg := m.config
+	cfg.Transcript = nil
+	newModel := InitialModel(cfg)
+	newModel.expandThinking = m.expandThinking
+	newModel.probed = m.probed
+	newModel.viewport.SetContent(newModel.welcome())
+	switch {
+	case restartErr != nil:
+		m.log().Warn("Failed to save the conversation before restarting", "error", restartErr)
+		newModel.err = fmt.Errorf("failed to save the previous conversation: %w", restartErr)
+	case options.Archive:
+		newModel.showPrompt("The previous conversation was saved, find it with klama history.")
+	}
+	return newModel.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+}
@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"errors"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_Restart(t *testing.T) {
+	newModel := func(restart func(Model, RestartOptions) error) Model {
+		mockAgent := new(MockAgent)
+		mockAgent.On("LogUsage").Return("Test usage")
+		mockAgent.On("Reset").Return()
+		model := InitialModel(Config{Agent: mockAgent, Restart: restart})
+		model, _ = updateModel(model, tea.WindowSizeMsg{Width: 100, Height: 40})
+		model.updateChat(SenderUser, "why is api pending?")
+		return model
+	}
+	key := func(r rune) tea.KeyMsg {
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}
+	}
+
+	t.Run("Asks before restarting", func(t *testing.T) {
+		var chosen []RestartOptions
+		model := newModel(func(m Model, options RestartOptions) error {
+			assert.Len(t, m.Transcript(), 1)
+			chosen = append(chosen, options)
+			return nil
+		})
+
+		model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyCtrlR})
+		assert.Contains(t, model.renderErrorMessage(), "save this conversation")
+		model, _ = updateModel(model, key('x'))
+		assert.Equal(t, restartAskArchive, model.restartStep)
+		assert.Empty(t, model.textarea.Value())
+
+		model, _ = updateModel(model, key('y'))
+		assert.Contains(t, model.renderErrorMessage(), "keep the tokens and cost")
+		model, _ = updateModel(model, key('n'))
+
+		assert.Equal(t, []RestartOptions{{Archive: true, KeepUsage: false}}, chosen)
+		assert.Empty(t, model.messages)
+		assert.Equal(t, 0, model.restartStep)
+		assert.Contains(t, model.renderErrorMessage(), "previous conversation was saved")
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		model := newModel(func(Model, RestartOptions) error {
+			t.Fatal("the restart was cancelled")
+			return nil
+		})
+
+		model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyCtrlR})
+		model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEsc})
+		assert.Equal(t, 0, model.restartStep)
+		assert.Len(t, model.messages, 1)
+		assert.Empty(t, model.renderErrorMessage())
+	})
+
+	t.Run("Failed to save", func(t *testing.T) {
+		model := newModel(func(Model, RestartOptions) error {
+			return errors.New("disk full")
+		})
+
+		model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyCtrlR})
+		model, _ = updateModel(model, key('y'))
+		model, _ = updateModel(model, key('y'))
+		assert.Empty(t, model.messages)
+		assert.ErrorContains(t, model.err, "failed to save the previous conversation: disk full")
+	})
+}
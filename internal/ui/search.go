@@ -0,0 +1,111 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var matchStyle = lipgloss.NewStyle().Reverse(true)
+
+// searchState is the state of a search in the chat history.
+type searchState struct {
+	query   string
+	pattern *regexp.Regexp
+	matches []int // viewport line of every match
+	current int
+}
+
+func (s searchState) active() bool {
+	return s.pattern != nil
+}
+
+// handleSearch starts a case-insensitive search for query in the chat history and
+// jumps to its most recent match.
+func (m Model) handleSearch(query string) (tea.Model, tea.Cmd) {
+	m.search = searchState{
+		query:   query,
+		pattern: regexp.MustCompile("(?i)" + regexp.QuoteMeta(query)),
+	}
+	m.updateViewportContent()
+
+	if len(m.search.matches) == 0 {
+		m.search = searchState{}
+		m.updateViewportContent()
+		m.err = fmt.Errorf("no matches for %q", query)
+		return m, nil
+	}
+
+	m.err = nil
+	m.textarea.Reset()
+	return m.jumpToMatch(len(m.search.matches) - 1)
+}
+
+// jumpToMatch highlights the match at index, wrapping around the ends, and scrolls to it.
+func (m Model) jumpToMatch(index int) (tea.Model, tea.Cmd) {
+	count := len(m.search.matches)
+	m.search.current = (index%count + count) % count
+	m.updateViewportContent()
+	return m, nil
+}
+
+// closeSearch removes the search highlights and scrolls back to the bottom.
+func (m *Model) closeSearch() {
+	m.search = searchState{}
+	m.updateViewportContent()
+}
+
+// highlightMatches highlights the search matches in the rendered chat and records the
+// line of every match. Lines with a match lose their other styles.
+func (m *Model) highlightMatches(content string) string {
+	m.search.matches = m.search.matches[:0]
+	currentMatchStyle := lipgloss.NewStyle().Background(m.systemStyle.GetForeground()).Foreground(m.theme.color(func(t Theme) string { return t.Background }))
+	lines := strings.Split(content, "\n")
+
+	for i, line := range lines {
+		plain := ansi.Strip(line)
+		locations := m.search.pattern.FindAllStringIndex(plain, -1)
+		if len(locations) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, loc := range locations {
+			style := matchStyle
+			if len(m.search.matches) == m.search.current {
+				style = currentMatchStyle
+			}
+			m.search.matches = append(m.search.matches, i)
+
+			b.WriteString(plain[last:loc[0]])
+			b.WriteString(style.Render(plain[loc[0]:loc[1]]))
+			last = loc[1]
+		}
+		b.WriteString(plain[last:])
+		lines[i] = b.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// scrollToMatch centers the current match in the viewport.
+func (m *Model) scrollToMatch() {
+	if len(m.search.matches) == 0 {
+		return
+	}
+	m.search.current = min(m.search.current, len(m.search.matches)-1)
+	m.viewport.SetYOffset(m.search.matches[m.search.current] - m.viewport.Height/2)
+}
+
+// renderSearchStatus shows the position of the current match in the footer.
+func (m Model) renderSearchStatus() string {
+	if !m.search.active() {
+		return ""
+	}
+	return m.systemStyle.Render(fmt.Sprintf(" %q %d/%d, n/N: next/previous, Esc: close ", m.search.query, m.search.current+1, len(m.search.matches)))
+}
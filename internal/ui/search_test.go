@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_handleSearch(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("LogUsage").Return("Test usage")
+
+	model := InitialModel(Config{Agent: mockAgent})
+	model, _ = updateModel(model, tea.WindowSizeMsg{Width: 100, Height: 20})
+	model.messages = []ChatMessage{
+		{Sender: SenderUser, Content: "why is my pod failing?"},
+		{Sender: SenderKlama, Content: "The pod is in CrashLoopBackOff."},
+		{Sender: SenderSystem, Content: "Command output:\napi-0 0/1 CrashLoopBackOff\napi-1 1/1 Running", Output: true},
+		{Sender: SenderKlama, Content: "Both restarts show crashloopbackoff."},
+	}
+	model.updateViewportContent()
+
+	model.textarea.SetValue("/CrashLoopBackOff")
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+
+	// matches in command outputs are found too, case insensitively
+	assert.NoError(t, model.err)
+	assert.Len(t, model.search.matches, 3)
+	assert.Equal(t, 2, model.search.current)
+	assert.Empty(t, model.textarea.Value())
+	assert.Contains(t, ansi.Strip(model.footerView()), `"CrashLoopBackOff" 3/3`)
+
+	// n wraps around to the first match, N goes back
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	assert.Equal(t, 0, model.search.current)
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("N")})
+	assert.Equal(t, 2, model.search.current)
+
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEsc})
+	assert.False(t, model.search.active())
+	assert.NotContains(t, ansi.Strip(model.footerView()), "n/N")
+	assert.True(t, model.viewport.AtBottom())
+}
+
+func TestModel_handleSearch_NoMatches(t *testing.T) {
+	model := InitialModel(Config{})
+	model.messages = []ChatMessage{{Sender: SenderKlama, Content: "The pod is running."}}
+
+	model.textarea.SetValue("/OOMKilled")
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+
+	assert.ErrorContains(t, model.err, `no matches for "OOMKilled"`)
+	assert.False(t, model.search.active())
+	assert.Equal(t, "/OOMKilled", model.textarea.Value())
+}
+
+func TestModel_handleSearch_TypingClosesSearch(t *testing.T) {
+	model := InitialModel(Config{})
+	model.messages = []ChatMessage{{Sender: SenderKlama, Content: "The pod is running."}}
+
+	model.textarea.SetValue("/running")
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.True(t, model.search.active())
+
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("w")})
+	assert.False(t, model.search.active())
+	assert.Equal(t, "w", model.textarea.Value())
+}
+
+func updateModel(model Model, msg tea.Msg) (Model, tea.Cmd) {
+	newModel, cmd := model.Update(msg)
+	return newModel.(Model), cmd
+}
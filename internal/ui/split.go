@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// toggleSplit shows or hides the pane with the latest command output next to the chat.
+func (m Model) toggleSplit() (tea.Model, tea.Cmd) {
+	if m.config.Inline {
+		m.err = fmt.Errorf("the output pane is not available in inline mode")
+		return m, nil
+	}
+	m.log().Debug("Toggling the output pane")
+	width := m.fullWidth()
+	m.split = !m.split
+	m.layout(width)
+	m.paneOutput = -1
+	if m.ready {
+		m.updateViewportContent()
+	}
+	return m, nil
+}
+
+// layout divides the width between the chat and the output pane.
+func (m *Model) layout(width int) {
+	if !m.split {
+		m.viewport.Width = width
+		return
+	}
+	m.viewport.Width = width / 2
+	m.pane.Width = width - m.viewport.Width - 1
+	m.pane.Height = m.viewport.Height
+}
+
+// fullWidth is the width of the chat and the output pane with the line between them.
+func (m Model) fullWidth() int {
+	if m.split {
+		return m.viewport.Width + 1 + m.pane.Width
+	}
+	return m.viewport.Width
+}
+
+// focusable reports whether the message is a command output the focus moves to. With the
+// output pane every output is, to show it in the pane.
+func (m Model) focusable(msg ChatMessage) bool {
+	return !m.config.Inline && (collapsible(msg) || (m.split && msg.Output))
+}
+
+// shownOutput returns the message index of the command output shown in the pane: the
+// focused output or the latest one, -1 when there is none.
+func (m Model) shownOutput() int {
+	if m.focusedOutput >= 0 {
+		return m.focusedOutput
+	}
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Output {
+			return i
+		}
+	}
+	return -1
+}
+
+// updatePane shows the selected command output in the pane, scrolled to its top when it
+// changed.
+func (m *Model) updatePane() {
+	if !m.split {
+		return
+	}
+	shown := m.shownOutput()
+	if shown < 0 {
+		m.pane.SetContent(m.helpStyle.Render("Command outputs are shown here."))
+		m.paneOutput = -1
+		return
+	}
+
+	content := m.highlightOutput(m.messages[shown].Content)
+	m.pane.SetContent(lipgloss.NewStyle().Width(m.pane.Width).Render(content))
+	if shown != m.paneOutput {
+		m.pane.GotoTop()
+		m.paneOutput = shown
+	}
+}
+
+// renderOutputStub renders a command output in the chat as its first line when the
+// output is shown in the pane.
+func (m Model) renderOutputStub(index int, msg ChatMessage) string {
+	sender := m.senderStyleFor(msg.Sender).Render(msg.Sender + ": ")
+	header, _, _ := strings.Cut(msg.Content, "\n")
+
+	markerStyle := m.helpStyle
+	if index == m.focusedOutput {
+		markerStyle = matchStyle
+	}
+	note := fmt.Sprintf(" (%d lines)", strings.Count(msg.Content, "\n"))
+	if index == m.shownOutput() {
+		note = fmt.Sprintf(" (%d lines, in the pane)", strings.Count(msg.Content, "\n"))
+	}
+	return markerStyle.Render("▸") + " " + sender + header + m.helpStyle.Render(note)
+}
+
+// splitView renders the chat and the output pane side by side.
+func (m Model) splitView() string {
+	if !m.split {
+		return m.viewport.View()
+	}
+	separator := m.helpStyle.Render(strings.TrimSuffix(strings.Repeat("│\n", m.viewport.Height), "\n"))
+	return lipgloss.JoinHorizontal(lipgloss.Top, m.viewport.View(), separator, m.pane.View())
+}
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_toggleSplit(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("LogUsage").Return("Test usage")
+	model := InitialModel(Config{Agent: mockAgent})
+	model, _ = updateModel(model, tea.WindowSizeMsg{Width: 102, Height: 60})
+	model.ready = true
+	model.addCommandOutput(podList(3), 0)
+	model.addCommandOutput(podList(20), 0)
+	assert.Equal(t, 100, model.viewport.Width)
+
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyCtrlS})
+	assert.Equal(t, 50, model.viewport.Width)
+	assert.Equal(t, 49, model.pane.Width)
+	assert.Equal(t, 100, model.fullWidth())
+
+	// the chat shows the first line of the outputs, the pane shows the latest in full
+	chat := chatText(model)
+	assert.Contains(t, chat, "▸ System: Command output: (3 lines)")
+	assert.Contains(t, chat, "▸ System: Command output: (20 lines, in the pane)")
+	assert.NotContains(t, chat, "api-0")
+	pane := ansi.Strip(model.pane.View())
+	assert.Contains(t, pane, "api-0 1/1 Running")
+	assert.Contains(t, pane, "api-19 1/1 Running")
+	assert.True(t, strings.HasPrefix(ansi.Strip(model.View()), "╭"))
+	assert.Contains(t, ansi.Strip(model.splitView()), "│")
+
+	// the pane scrolls on its own
+	model.pane.Height = 5
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyShiftDown})
+	assert.Equal(t, 1, model.pane.YOffset)
+
+	// focusing an output shows it in the pane, even a short one
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyTab})
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyTab})
+	assert.Equal(t, 0, model.focusedOutput)
+	assert.Equal(t, 0, model.pane.YOffset)
+	assert.NotContains(t, ansi.Strip(model.pane.View()), "api-19")
+
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyCtrlS})
+	assert.Equal(t, 100, model.viewport.Width)
+	assert.Contains(t, chatText(model), "api-0 1/1 Running")
+}
+
+func TestModel_updatePane_NoOutputs(t *testing.T) {
+	model := InitialModel(Config{SplitPane: true})
+	model.pane.Width, model.pane.Height = 40, 2
+	model.updateViewportContent()
+	assert.Contains(t, model.pane.View(), "Command outputs are shown here.")
+}
@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/eliran89c/klama/internal/llm"
+)
+
+// UsageReporter is implemented by agents that report the price of the session and the
+// budget left, which the status bar shows instead of the full usage log.
+type UsageReporter interface {
+	Cost() float64
+	BudgetLeft() (tokens int, cost float64) // -1 for the limits that are not set
+}
+
+// statusSeparator separates the segments of the status bar.
+const statusSeparator = " │ "
+
+// stateLabel describes what the session is doing.
+func (m Model) stateLabel() string {
+	switch m.state {
+	case StateAsking:
+		return "thinking"
+	case StateExecuting:
+		return "executing"
+	case StateWaitingForConfirmation, StatePlanApproval:
+		return "waiting for approval"
+	case StateEditingCommand:
+		return "editing"
+	default:
+		return "idle"
+	}
+}
+
+// renderStatusBar shows the state of the session, the model, the target, how much of
+// the context window is used, and the spend and budget left.
+func (m Model) renderStatusBar() string {
+	stateStyle := m.helpStyle
+	if m.state == StateAsking || m.state == StateExecuting {
+		stateStyle = m.typingStyle
+	}
+	segments := []string{stateStyle.Render(m.stateLabel())}
+	if queued := m.renderQueued(); queued != "" {
+		segments = append(segments, m.typingStyle.Render(queued))
+	}
+	if watching := m.renderWatch(); watching != "" {
+		segments = append(segments, m.typingStyle.Render(watching))
+	}
+
+	if m.config.ModelName != "" {
+		segments = append(segments, m.priceStyle.Render(m.config.ModelName))
+	}
+	if target := m.config.Target; target.Context != "" || target.Protected {
+		text := cmp.Or(target.Context, unknownContext)
+		if target.Namespace != "" {
+			text += "/" + target.Namespace
+		}
+		style := m.priceStyle
+		if target.Protected {
+			style = m.errorStyle
+		}
+		segments = append(segments, style.Render(text))
+	}
+	if m.contextWindow > 0 {
+		style := m.priceStyle
+		if float64(m.contextUsed) >= llm.DefaultCompactThreshold*float64(m.contextWindow) {
+			style = m.errorStyle
+		}
+		segments = append(segments, style.Render(fmt.Sprintf("context used: %s/%s", formatTokens(m.contextUsed), formatTokens(m.contextWindow))))
+	}
+	segments = append(segments, m.renderSpend()...)
+
+	return lipgloss.NewStyle().Width(m.width).Render(strings.Join(segments, m.priceStyle.Render(m.glyph(statusSeparator, " | "))))
+}
+
+// renderSpend shows the price of the session and the budget left, or the usage log of
+// agents that do not report them.
+func (m Model) renderSpend() []string {
+	reporter, ok := m.agent.(UsageReporter)
+	if !ok {
+		return []string{m.priceStyle.Render(m.agent.LogUsage())}
+	}
+
+	spend := []string{m.priceStyle.Render(fmt.Sprintf("%.4f$", reporter.Cost()))}
+	var left []string
+	tokens, cost := reporter.BudgetLeft()
+	if cost >= 0 {
+		left = append(left, fmt.Sprintf("%.4f$", cost))
+	}
+	if tokens >= 0 {
+		left = append(left, formatTokens(tokens)+" tokens")
+	}
+	if len(left) > 0 {
+		style := m.priceStyle
+		if tokens == 0 || cost == 0 {
+			style = m.errorStyle
+		}
+		spend = append(spend, style.Render("budget left: "+strings.Join(left, ", ")))
+	}
+	return spend
+}
@@ -0,0 +1,221 @@
+package ui
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxTabTitle is the number of characters of the first message shown as a tab title.
+const maxTabTitle = 20
+
+// TabMsg is a message for the tab with the given ID, such as a response to a request
+// the tab made. Messages that are not for a tab go to the active tab.
+type TabMsg struct {
+	Tab int
+	Msg tea.Msg
+}
+
+// Tabs runs several independent conversations in one TUI. Each tab is a Model with its
+// own agent, executer and usage; Ctrl+N opens a new tab, and Ctrl+Left, Ctrl+Right and
+// Alt+1 to Alt+9 switch between them. The tab bar is shown once there are two tabs.
+type Tabs struct {
+	tabs   []Model
+	active int
+
+	// newTab returns the configuration of a new conversation, nil disables new tabs
+	newTab func(id int) (Config, error)
+
+	width  int
+	height int
+}
+
+// NewTabs returns a TUI with the first conversation. newTab returns the configuration
+// of the conversation of each new tab, whose ID is its position, nil disables new tabs.
+func NewTabs(first Model, newTab func(id int) (Config, error)) Tabs {
+	return Tabs{tabs: []Model{first}, newTab: newTab}
+}
+
+// Models returns the conversation of every tab, in the order the tabs were opened.
+func (t Tabs) Models() []Model {
+	return t.tabs
+}
+
+// Init initializes the first tab.
+func (t Tabs) Init() tea.Cmd {
+	return forTab(0, t.tabs[0].Init())
+}
+
+// Update routes messages to their tab, and keys to the active tab.
+func (t Tabs) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		t.width, t.height = msg.Width, msg.Height
+		return t.resize()
+
+	case tea.FocusMsg, tea.BlurMsg:
+		// every tab notifies when the terminal is not focused
+		for i := range t.tabs {
+			next, _ := t.tabs[i].Update(msg)
+			t.tabs[i] = next.(Model)
+		}
+		return t, nil
+
+	case tea.KeyMsg:
+		switch {
+		case msg.Type == tea.KeyCtrlN && t.newTab != nil:
+			return t.openTab()
+		case msg.Type == tea.KeyCtrlRight:
+			return t.switchTab(t.active + 1), nil
+		case msg.Type == tea.KeyCtrlLeft:
+			return t.switchTab(t.active - 1), nil
+		}
+		if i, ok := tabKey(msg); ok {
+			if i < len(t.tabs) {
+				t.active = i
+			}
+			return t, nil
+		}
+
+	case TabMsg:
+		if msg.Tab < 0 || msg.Tab >= len(t.tabs) {
+			return t, nil
+		}
+		return t.updateTab(msg.Tab, msg.Msg)
+	}
+
+	return t.updateTab(t.active, msg)
+}
+
+// updateTab passes a message to a tab.
+func (t Tabs) updateTab(i int, msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := t.tabs[i].Update(msg)
+	t.tabs[i] = next.(Model)
+	return t, forTab(i, cmd)
+}
+
+// openTab opens a new tab with a new conversation and switches to it.
+func (t Tabs) openTab() (tea.Model, tea.Cmd) {
+	id := len(t.tabs)
+	cfg, err := t.newTab(id)
+	if err != nil {
+		t.tabs[t.active].log().Warn("Failed to open a new tab", "error", err)
+		t.tabs[t.active].err = fmt.Errorf("failed to open a new tab: %w", err)
+		return t, nil
+	}
+
+	t.tabs = append(t.tabs, InitialModel(cfg))
+	t.active = id
+	model, cmd := t.resize()
+	return model, tea.Batch(cmd, forTab(id, t.tabs[id].Init()))
+}
+
+// switchTab activates the tab at i, wrapping around the ends.
+func (t Tabs) switchTab(i int) Tabs {
+	t.active = (i%len(t.tabs) + len(t.tabs)) % len(t.tabs)
+	return t
+}
+
+// resize lays out every tab below the tab bar.
+func (t Tabs) resize() (tea.Model, tea.Cmd) {
+	if t.width == 0 {
+		return t, nil
+	}
+	height := t.height
+	if len(t.tabs) > 1 {
+		height -= lipgloss.Height(t.tabBar())
+	}
+
+	var cmds []tea.Cmd
+	for i := range t.tabs {
+		next, cmd := t.tabs[i].Update(tea.WindowSizeMsg{Width: t.width, Height: height})
+		t.tabs[i] = next.(Model)
+		cmds = append(cmds, forTab(i, cmd))
+	}
+	return t, tea.Batch(cmds...)
+}
+
+// View renders the tab bar above the active tab.
+func (t Tabs) View() string {
+	if len(t.tabs) == 1 {
+		return t.tabs[0].View()
+	}
+	return t.tabBar() + "\n" + t.tabs[t.active].View()
+}
+
+// tabBar shows the number and title of every tab, with a dot on busy tabs.
+func (t Tabs) tabBar() string {
+	active := t.tabs[t.active]
+	titles := make([]string, 0, len(t.tabs))
+	for i, tab := range t.tabs {
+		title := fmt.Sprintf(" %d %s ", i+1, tab.title())
+		if tab.busy() {
+			title += "● "
+		}
+		if i == t.active {
+			titles = append(titles, matchStyle.Render(title))
+		} else {
+			titles = append(titles, active.helpStyle.Render(title))
+		}
+	}
+	return lipgloss.NewStyle().MaxWidth(t.width).Render(strings.Join(titles, " "))
+}
+
+// title is the first message of the user, shortened, or "new" before it.
+func (m Model) title() string {
+	for _, msg := range m.messages {
+		if msg.Sender != SenderUser {
+			continue
+		}
+		title := strings.Join(strings.Fields(msg.Content), " ")
+		if runes := []rune(title); len(runes) > maxTabTitle {
+			title = string(runes[:maxTabTitle-1]) + "…"
+		}
+		return title
+	}
+	return "new"
+}
+
+// tabKey returns the tab Alt+1 to Alt+9 switch to.
+func tabKey(msg tea.KeyMsg) (int, bool) {
+	if !msg.Alt || msg.Type != tea.KeyRunes || len(msg.Runes) != 1 {
+		return 0, false
+	}
+	i := int(msg.Runes[0] - '1')
+	if i < 0 || i > 8 {
+		return 0, false
+	}
+	return i, true
+}
+
+// teaPackage is the package of the messages Bubble Tea handles itself, such as quitting
+// or running an interactive process.
+var teaPackage = reflect.TypeOf(tea.QuitMsg{}).PkgPath()
+
+// forTab tags the messages of cmd with the tab that made it, so they reach that tab
+// even when another tab is active.
+func forTab(tab int, cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		switch msg := cmd().(type) {
+		case nil:
+			return nil
+		case tea.BatchMsg:
+			cmds := make([]tea.Cmd, len(msg))
+			for i, c := range msg {
+				cmds[i] = forTab(tab, c)
+			}
+			return tea.BatchMsg(cmds)
+		default:
+			if reflect.TypeOf(msg).PkgPath() == teaPackage {
+				return msg
+			}
+			return TabMsg{Tab: tab, Msg: msg}
+		}
+	}
+}
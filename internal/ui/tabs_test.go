@@ -0,0 +1,88 @@
+package ui
+
+import (
+	"errors"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func updateTabs(tabs Tabs, msg tea.Msg) (Tabs, tea.Cmd) {
+	next, cmd := tabs.Update(msg)
+	return next.(Tabs), cmd
+}
+
+func TestTabs(t *testing.T) {
+	firstAgent, secondAgent := new(MockAgent), new(MockAgent)
+	firstAgent.On("LogUsage").Return("first usage")
+	secondAgent.On("LogUsage").Return("second usage")
+
+	var ids []int
+	tabs := NewTabs(InitialModel(Config{Agent: firstAgent}), func(id int) (Config, error) {
+		ids = append(ids, id)
+		return Config{Agent: secondAgent}, nil
+	})
+	tabs, _ = updateTabs(tabs, tea.WindowSizeMsg{Width: 100, Height: 40})
+	assert.NotContains(t, tabs.View(), " 1 new ")
+
+	// the first tab sends a message, its response comes back tagged with the tab
+	firstAgent.On("Iterate", mock.Anything, "why is api failing?").Return(agent.AgentResponse{Answer: "It is out of memory."}, nil)
+	tabs.tabs[0].textarea.SetValue("why is api failing?")
+	tabs, cmd := updateTabs(tabs, tea.KeyMsg{Type: tea.KeyEnter})
+	response := cmd().(tea.BatchMsg)[0]()
+	assert.Equal(t, 0, response.(TabMsg).Tab)
+
+	// Ctrl+N opens a second tab, which the response does not reach
+	tabs, _ = updateTabs(tabs, tea.KeyMsg{Type: tea.KeyCtrlN})
+	assert.Equal(t, []int{1}, ids)
+	assert.Equal(t, 1, tabs.active)
+	assert.Equal(t, 39, tabs.tabs[1].height)
+	view := ansi.Strip(tabs.View())
+	assert.Contains(t, view, " 1 why is api failing? ● ")
+	assert.Contains(t, view, " 2 new ")
+	assert.Contains(t, view, "second usage")
+
+	tabs, _ = updateTabs(tabs, response)
+	assert.Equal(t, StateTyping, tabs.tabs[0].state)
+	assert.Equal(t, "It is out of memory.", tabs.tabs[0].messages[1].Content)
+	assert.Empty(t, tabs.tabs[1].messages)
+
+	// keys go to the active tab
+	tabs, _ = updateTabs(tabs, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	assert.Equal(t, "x", tabs.tabs[1].textarea.Value())
+	assert.Empty(t, tabs.tabs[0].textarea.Value())
+
+	tabs, _ = updateTabs(tabs, tea.KeyMsg{Type: tea.KeyCtrlRight})
+	assert.Equal(t, 0, tabs.active)
+	tabs, _ = updateTabs(tabs, tea.KeyMsg{Type: tea.KeyCtrlLeft})
+	assert.Equal(t, 1, tabs.active)
+	tabs, _ = updateTabs(tabs, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("1"), Alt: true})
+	assert.Equal(t, 0, tabs.active)
+	tabs, _ = updateTabs(tabs, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("5"), Alt: true})
+	assert.Equal(t, 0, tabs.active)
+
+	assert.Len(t, tabs.Models(), 2)
+	firstAgent.AssertExpectations(t)
+}
+
+func TestTabs_NewTabFails(t *testing.T) {
+	tabs := NewTabs(InitialModel(Config{}), func(int) (Config, error) {
+		return Config{}, errors.New("no credentials")
+	})
+
+	tabs, _ = updateTabs(tabs, tea.KeyMsg{Type: tea.KeyCtrlN})
+	assert.Len(t, tabs.tabs, 1)
+	assert.EqualError(t, tabs.tabs[0].err, "failed to open a new tab: no credentials")
+}
+
+func TestForTab(t *testing.T) {
+	assert.Nil(t, forTab(1, nil))
+	assert.Equal(t, TabMsg{Tab: 1, Msg: tickMsg{}}, forTab(1, func() tea.Msg { return tickMsg{} })())
+
+	// messages of Bubble Tea itself are not tagged
+	assert.Equal(t, tea.QuitMsg{}, forTab(1, tea.Quit)())
+}
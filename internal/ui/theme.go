@@ -0,0 +1,118 @@
+package ui
+
+import "github.com/charmbracelet/lipgloss"
+
+// Theme holds the colors of the chat, as ANSI color numbers or hex values. An adaptive
+// theme has colors for light terminals too, chosen by the background of the terminal.
+type Theme struct {
+	Sender     string
+	Klama      string
+	System     string
+	Error      string
+	Help       string
+	Price      string
+	Background string // text color on highlighted backgrounds
+	Syntax     string // chroma style of YAML and JSON command outputs
+
+	Light *Theme // colors on light backgrounds, nil when the theme is not adaptive
+}
+
+// Built-in themes
+const (
+	ThemeAuto         = "auto" // dark or light, following the background of the terminal
+	ThemeDark         = "dark"
+	ThemeLight        = "light"
+	ThemeHighContrast = "high-contrast"
+)
+
+// Themes are the built-in themes by name.
+var Themes = map[string]Theme{
+	ThemeAuto:         adaptive(darkTheme, lightTheme),
+	ThemeDark:         darkTheme,
+	ThemeLight:        lightTheme,
+	ThemeHighContrast: highContrastTheme,
+}
+
+var (
+	darkTheme = Theme{
+		Sender:     "2",   // green
+		Klama:      "5",   // magenta
+		System:     "3",   // yellow
+		Error:      "1",   // red
+		Help:       "241", // light gray
+		Price:      "6",   // cyan
+		Background: "0",   // black
+		Syntax:     "monokai",
+	}
+	lightTheme = Theme{
+		Sender:     "28",  // dark green
+		Klama:      "90",  // dark magenta
+		System:     "130", // dark orange
+		Error:      "160", // dark red
+		Help:       "243", // gray
+		Price:      "25",  // dark blue
+		Background: "15",  // white
+		Syntax:     "github",
+	}
+	highContrastTheme = Theme{
+		Sender:     "10", // bright green
+		Klama:      "13", // bright magenta
+		System:     "11", // bright yellow
+		Error:      "9",  // bright red
+		Help:       "15", // white
+		Price:      "14", // bright cyan
+		Background: "0",  // black
+		Syntax:     "native",
+	}
+)
+
+// adaptive returns a theme with the dark colors on dark terminals and the light colors
+// on light terminals.
+func adaptive(dark, light Theme) Theme {
+	dark.Light = &light
+	return dark
+}
+
+// color returns a color of the theme, which follows the background of the terminal in
+// adaptive themes.
+func (t Theme) color(field func(Theme) string) lipgloss.TerminalColor {
+	if t.Light == nil {
+		return lipgloss.Color(field(t))
+	}
+	return lipgloss.AdaptiveColor{Dark: field(t), Light: field(*t.Light)}
+}
+
+// syntax returns the chroma style of command outputs for the background of the terminal.
+func (t Theme) syntax() string {
+	if t.Light != nil && !lipgloss.HasDarkBackground() {
+		return t.Light.Syntax
+	}
+	return t.Syntax
+}
+
+// Override returns the theme with the colors that are set in other, in both variants of
+// an adaptive theme. The light colors of other replace the light colors of the theme.
+func (t Theme) Override(other Theme) Theme {
+	override := func(color *string, value string) {
+		if value != "" {
+			*color = value
+		}
+	}
+	override(&t.Sender, other.Sender)
+	override(&t.Klama, other.Klama)
+	override(&t.System, other.System)
+	override(&t.Error, other.Error)
+	override(&t.Help, other.Help)
+	override(&t.Price, other.Price)
+	override(&t.Background, other.Background)
+	override(&t.Syntax, other.Syntax)
+
+	switch {
+	case other.Light != nil:
+		t.Light = other.Light
+	case t.Light != nil:
+		light := t.Light.Override(other)
+		t.Light = &light
+	}
+	return t
+}
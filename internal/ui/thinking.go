@@ -0,0 +1,25 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// addThinking records the reasoning of a response, shown collapsed until the user
+// expands the reasoning sections.
+func (m *Model) addThinking(thinking string) {
+	thinking = strings.TrimSpace(thinking)
+	if !m.config.ShowThinking || thinking == "" {
+		return
+	}
+	m.appendMessage(ChatMessage{Sender: SenderKlama, Content: thinking, Thinking: true})
+	m.updateViewportContent()
+}
+
+// renderThinking renders a reasoning section, collapsed to its length unless expanded.
+func (m Model) renderThinking(msg ChatMessage) string {
+	if !m.expandThinking {
+		return m.helpStyle.Render(fmt.Sprintf("%sThinking (%d words), Ctrl+T to expand", m.glyph("▸ ", ""), len(strings.Fields(msg.Content))))
+	}
+	return m.helpStyle.Render(m.glyph("▾ ", "") + "Thinking\n" + msg.Content)
+}
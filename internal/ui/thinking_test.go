@@ -0,0 +1,34 @@
+package ui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_Thinking(t *testing.T) {
+	model := InitialModel(Config{Agent: new(MockAgent), ShowThinking: true})
+	model.ready = true
+	model, _ = updateModel(model, agent.AgentResponse{Answer: "The pod is out of memory.", Thinking: "The container was OOMKilled twice."})
+
+	require.Len(t, model.messages, 2)
+	assert.True(t, model.messages[0].Thinking)
+	assert.Contains(t, model.viewport.View(), "▸ Thinking (5 words)")
+	assert.NotContains(t, model.viewport.View(), "OOMKilled")
+
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyCtrlT})
+	assert.Contains(t, model.viewport.View(), "The container was OOMKilled twice.")
+	assert.Contains(t, model.renderHelpText(), "Ctrl+T: to collapse thinking.")
+}
+
+func TestModel_ThinkingHidden(t *testing.T) {
+	model := InitialModel(Config{Agent: new(MockAgent)})
+	model.ready = true
+	model, _ = updateModel(model, agent.AgentResponse{Answer: "The pod is out of memory.", Thinking: "The container was OOMKilled twice."})
+
+	require.Len(t, model.messages, 1)
+	assert.NotContains(t, model.renderHelpText(), "Ctrl+T")
+}
@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Default timeouts of agent requests and commands
+const (
+	DefaultAgentTimeout = 90 * time.Second
+	DefaultExecTimeout  = 30 * time.Second
+)
+
+// agentTimeout returns the timeout of an agent request.
+func (m Model) agentTimeout() time.Duration {
+	if m.config.AgentTimeout > 0 {
+		return m.config.AgentTimeout
+	}
+	return DefaultAgentTimeout
+}
+
+// execTimeout returns the timeout of the next command, the one the user gave with the
+// approval or the configured one.
+func (m Model) execTimeout() time.Duration {
+	if m.commandTimeout > 0 {
+		return m.commandTimeout
+	}
+	if m.config.ExecTimeout > 0 {
+		return m.config.ExecTimeout
+	}
+	return DefaultExecTimeout
+}
+
+// splitTimeout splits a timeout such as "2m" from the end of an approval, as in
+// "yes 2m", and returns the approval without it.
+func splitTimeout(input string) (string, time.Duration, error) {
+	fields := strings.Fields(input)
+	if len(fields) < 2 {
+		return input, 0, nil
+	}
+
+	last := fields[len(fields)-1]
+	timeout, err := time.ParseDuration(last)
+	if err != nil {
+		return input, 0, nil
+	}
+	if timeout <= 0 {
+		return input, 0, fmt.Errorf("the timeout %s must be positive", last)
+	}
+	return strings.Join(fields[:len(fields)-1], " "), timeout, nil
+}
+
+// renderRemaining shows the time left before the running command times out.
+func (m Model) renderRemaining() string {
+	if m.execDeadline.IsZero() {
+		return ""
+	}
+	remaining := max(time.Until(m.execDeadline), 0).Round(time.Second)
+	return fmt.Sprintf(" (%s left)", remaining)
+}
@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitTimeout(t *testing.T) {
+	tests := []struct {
+		input   string
+		answer  string
+		timeout time.Duration
+		wantErr bool
+	}{
+		{input: "yes", answer: "yes"},
+		{input: "yes 2m", answer: "yes", timeout: 2 * time.Minute},
+		{input: "prod-eu 90s", answer: "prod-eu", timeout: 90 * time.Second},
+		{input: "2m", answer: "2m"},
+		{input: "yes please", answer: "yes please"},
+		{input: "yes 0s", wantErr: true},
+		{input: "yes -1m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			answer, timeout, err := splitTimeout(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.answer, answer)
+			assert.Equal(t, tt.timeout, timeout)
+		})
+	}
+}
+
+func TestModel_CommandTimeout(t *testing.T) {
+	mockExecuter := new(MockExecuter)
+	var deadlines []time.Duration
+	mockExecuter.On("Run", mock.Anything, "kubectl logs web").Run(func(args mock.Arguments) {
+		deadline, ok := args.Get(0).(context.Context).Deadline()
+		require.True(t, ok)
+		deadlines = append(deadlines, time.Until(deadline))
+	}).Return(executer.ExecuterResponse{Stdout: "log line"})
+
+	model := InitialModel(Config{Agent: new(MockAgent), Executer: mockExecuter, ExecTimeout: 45 * time.Second})
+	model.confirmationCmd = "kubectl logs web"
+	model.state = StateWaitingForConfirmation
+
+	// the approval overrides the configured timeout of this command only
+	model.textarea.SetValue("yes 5m")
+	model, cmd := updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	require.Equal(t, StateExecuting, model.state)
+	assert.Contains(t, model.renderInputArea(), "Command executing (5m0s left)")
+	cmd().(tea.BatchMsg)[0]()
+	assert.Zero(t, model.commandTimeout)
+
+	model.state = StateWaitingForConfirmation
+	model.textarea.SetValue("y")
+	model, cmd = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.True(t, strings.HasSuffix(model.renderInputArea(), "(45s left)"))
+	cmd().(tea.BatchMsg)[0]()
+
+	require.Len(t, deadlines, 2)
+	assert.InDelta(t, 5*time.Minute, deadlines[0], float64(time.Second))
+	assert.InDelta(t, 45*time.Second, deadlines[1], float64(time.Second))
+
+	// an invalid timeout is not an approval
+	model.state = StateWaitingForConfirmation
+	model.textarea.SetValue("yes 0s")
+	model, _ = updateModel(model, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.EqualError(t, model.err, "the timeout 0s must be positive")
+}
@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"strings"
+	"time"
+
+	"github.com/eliran89c/klama/internal/agent"
+)
+
+// timedResponse is a response of the agent with how long the agent took to answer.
+type timedResponse struct {
+	response agent.AgentResponse
+	elapsed  time.Duration
+}
+
+// appendMessage adds a message to the transcript, stamped with the current time.
+func (m *Model) appendMessage(msg ChatMessage) {
+	msg.Time = time.Now()
+	m.messages = append(m.messages, msg)
+}
+
+// renderAnnotation renders the line under a message: when it was added and how long the
+// agent or the command took, when timestamps are enabled, and the usage of the response.
+func (m Model) renderAnnotation(msg ChatMessage) string {
+	var parts []string
+	if m.config.Timestamps {
+		if !msg.Time.IsZero() {
+			parts = append(parts, msg.Time.Format(time.TimeOnly))
+		}
+		if msg.Elapsed > 0 {
+			verb := "answered in"
+			if msg.Output {
+				verb = "ran in"
+			}
+			parts = append(parts, verb+" "+formatElapsed(msg.Elapsed))
+		}
+	}
+	if msg.Usage != "" {
+		parts = append(parts, msg.Usage)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return m.helpStyle.Render(strings.Join(parts, " • "))
+}
+
+// formatElapsed formats a duration as 350ms, 7.3s or 1m2.5s.
+func formatElapsed(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
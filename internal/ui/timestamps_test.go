@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_renderAnnotation(t *testing.T) {
+	at := time.Date(2024, 5, 1, 14, 3, 12, 0, time.Local)
+	answer := ChatMessage{Sender: SenderKlama, Content: "done", Usage: "10 in / 5 out • 0.0001$", Time: at, Elapsed: 7320 * time.Millisecond}
+	output := ChatMessage{Sender: SenderSystem, Content: "Command output:\nok", Output: true, Time: at, Elapsed: 1234 * time.Millisecond}
+
+	model := InitialModel(Config{})
+	assert.Equal(t, "10 in / 5 out • 0.0001$", ansi.Strip(model.renderAnnotation(answer)))
+	assert.Empty(t, model.renderAnnotation(output))
+
+	model = InitialModel(Config{Timestamps: true})
+	assert.Equal(t, "14:03:12 • answered in 7.3s • 10 in / 5 out • 0.0001$", ansi.Strip(model.renderAnnotation(answer)))
+	assert.Equal(t, "14:03:12 • ran in 1.2s", ansi.Strip(model.renderAnnotation(output)))
+
+	// restored messages of older sessions have no time
+	assert.Empty(t, model.renderAnnotation(ChatMessage{Sender: SenderUser, Content: "hi"}))
+}
+
+func TestModel_timedResponse(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("LogUsage").Return("Test usage")
+	model := InitialModel(Config{Agent: mockAgent, Timestamps: true})
+	model.ready = true
+
+	model, _ = updateModel(model, timedResponse{
+		response: agent.AgentResponse{Answer: "The pod is running.", Usage: llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}},
+		elapsed:  2 * time.Second,
+	})
+
+	answer := model.Transcript()[0]
+	assert.Equal(t, 2*time.Second, answer.Elapsed)
+	assert.WithinDuration(t, time.Now(), answer.Time, time.Minute)
+	assert.Zero(t, model.pendingElapsed)
+	assert.Contains(t, ansi.Strip(model.viewport.View()), "answered in 2s")
+}
+
+func TestFormatElapsed(t *testing.T) {
+	assert.Equal(t, "350ms", formatElapsed(350*time.Millisecond+200*time.Microsecond))
+	assert.Equal(t, "7.3s", formatElapsed(7320*time.Millisecond))
+	assert.Equal(t, "1m2.5s", formatElapsed(62480*time.Millisecond))
+}
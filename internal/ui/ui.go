@@ -1,43 +1,81 @@
 package ui
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/runeutil"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/eliran89c/klama/internal/agent"
 	"github.com/eliran89c/klama/internal/executer"
+	"github.com/eliran89c/klama/internal/llm"
 	"github.com/eliran89c/klama/internal/logger"
+	"github.com/eliran89c/klama/internal/policy"
+	"github.com/eliran89c/klama/internal/redact"
 )
 
 type (
 	modelState int
 	errMsg     error
 	tickMsg    time.Time
+
+	// outputProcessedMsg carries the processed command output to send to the agent
+	outputProcessedMsg string
 )
 
+// preflightMsg carries a suggested command together with the result of its preflight check.
+type preflightMsg struct {
+	response agent.AgentResponse
+	warning  string
+}
+
+// RetryMsg reports that a request to the model failed and is being retried.
+type RetryMsg struct {
+	Attempt     int
+	MaxAttempts int
+	Err         error
+}
+
 const (
 	StateTyping modelState = iota
 	StateAsking
 	StateExecuting
 	StateWaitingForConfirmation
+	StateEditingCommand
+	StatePlanApproval
+	StateChoosing
 )
 
 const (
-	colorSender     = "2"   // green
-	colorKlama      = "5"   // magenta
-	colorSystem     = "3"   // yellow
-	colorError      = "1"   // red
-	colorHelp       = "241" // light gray
-	colorPrice      = "6"   // cyan
-	colorBackground = "0"   // black
-
 	welcomeMsg = "Welcome to Klama!\nEnter your question or issue."
+
+	defaultCharLimit = 8000 // fits pasted pod events and log excerpts
+)
+
+// Chat message senders
+const (
+	SenderUser   = "You"
+	SenderKlama  = "Klama"
+	SenderSystem = "System"
+)
+
+// Session outcomes
+const (
+	OutcomeAnswered         = "answered"
+	OutcomeAwaitingApproval = "awaiting approval"
+	OutcomeError            = "error"
+	OutcomeInProgress       = "in progress"
 )
 
 var (
@@ -67,11 +105,51 @@ type Executer interface {
 	Validate(string) error
 }
 
+// PreflightChecker is implemented by executers that can check whether a command is
+// permitted before the user is asked to confirm it.
+type PreflightChecker interface {
+	Preflight(context.Context, string) (string, error)
+}
+
+// ToolSelector is implemented by executers of multi-tool sessions, which run every
+// command with the tool the agent named.
+type ToolSelector interface {
+	Tool(string) (executer.CachingExecuter, error)
+}
+
+// MutationChecker is implemented by executers that can run commands which change the
+// environment, such as the kubectl write operations of fix mode. A mutation must be
+// approved by typing the name of the resource it changes.
+type MutationChecker interface {
+	Mutation(string) (executer.MutationTarget, bool)
+}
+
+// BuiltinToolChecker is implemented by executers with built-in read-only tools, such as
+// the collection of Kubernetes events, which run without confirmation in autopilot and do
+// not count toward its limit.
+type BuiltinToolChecker interface {
+	BuiltinTool(string) bool
+}
+
+// ContextReporter is implemented by agents that estimate how much of the model's context
+// window the conversation uses.
+type ContextReporter interface {
+	ContextUsage() (used, window int)
+}
+
+// PolicyEvaluator decides how a suggested command is approved.
+type PolicyEvaluator interface {
+	Evaluate(string) (policy.Decision, error)
+}
+
 // Model represents the application state.
 type Model struct {
-	agent    Agent
-	executer Executer
-	ready    bool
+	config    Config
+	theme     Theme
+	agent     Agent
+	executer  Executer
+	agentName string
+	ready     bool
 
 	viewport viewport.Model
 	textarea textarea.Model
@@ -84,173 +162,670 @@ type Model struct {
 	priceStyle  lipgloss.Style
 	typingStyle lipgloss.Style
 
-	messages        []string
-	err             error
-	state           modelState
-	waitingDots     int
-	confirmationCmd string
-	showCmdResponse bool
+	messages         []ChatMessage
+	err              error
+	state            modelState
+	waitingDots      int
+	confirmationCmd  string
+	confirmationTool string          // tool of the pending command in multi-tool sessions
+	mutation         string          // name of the resource the pending command changes, empty for read-only commands
+	protectedContext string          // protected context the pending command runs against, empty for none
+	highRisk         bool            // the agent rated the pending command as high risk
+	explaining       string          // command being explained, empty when none
+	editedFromCmd    string          // the agent's original command when the user edited it
+	autoApproved     int             // number of commands executed without confirmation
+	autoRun          int             // commands and plans run in a row without the user's input
+	checkpointAsked  bool            // the agent was asked to summarize its findings after autoRun reached the limit
+	retryStatus      string          // shown while a failed model request is retried
+	commandTimeout   time.Duration   // timeout the user approved the next command with, 0 for the configured one
+	execDeadline     time.Time       // when the running command times out, zero while no command runs
+	preflightWarn    string          // warning from the preflight check of the pending command
+	policyDecision   policy.Decision // policy decision of the pending command
+	verdict          agent.Verdict   // validator verdict of the pending command
+	verdictErr       error           // why the validator could not review the pending command
+	attachments      []attachment    // files sent with the next message
+	notice           string          // transient notice shown in the footer
+	search           searchState
+	plan             planState   // plan of commands the agent proposed
+	followups        []string    // follow-up questions the user can ask by pressing their number
+	picker           *list.Model // options the agent asked the user to choose from, nil when none
+	interruptNote    string      // tells the agent about a canceled command with the next message
+	pendingResult    []string    // commands of a dry run the user runs and pastes the output of
+	watch            *watch      // command that runs again at an interval, nil when none
+	watchSeq         int         // id of the latest watch
+	watchNote        string      // tells the agent how the watched command changed with the next message
+	switchingContext string      // kube context the session is switching to, empty when none
+	contextNote      string      // tells the agent about the switched kube context with the next message
+	interactiveCmd   string      // interactive command the next message summarizes
+	interactiveErr   error       // how the interactive command failed
+	pendingUsage     llm.Usage   // usage of responses not shown yet, such as invalid commands
+	pendingCost      float64
+	pendingElapsed   time.Duration // time the agent took on responses not shown yet
+	queued           []string      // messages typed while Klama was busy, sent with its next turn
+	noticeID         int
+	focusedOutput    int            // message index of the focused command output, -1 when none
+	split            bool           // command outputs are shown in the pane next to the chat
+	pane             viewport.Model // the command output shown next to the chat
+	paneOutput       int            // message index of the output in the pane, -1 when none
+	busySince        time.Time      // when Klama started answering or running commands
+	blurred          bool           // the terminal is not focused
+	printed          int            // number of messages printed to the scrollback in inline mode
+	announcedState   string         // last state printed in plain mode
+	announcedErr     string         // last error printed in plain mode
+	restartStep      int            // question of the restart being asked, 0 when not restarting
+	restartOptions   RestartOptions // answers to the questions of the restart
+	probed           *probeMsg      // outcome of the check of the model, nil while it runs
+	expandedOutputs  map[int]bool   // message indexes of the command outputs shown in full
+	expandThinking   bool
+
+	contextUsed   int // estimated tokens of the conversation
+	contextWindow int // context window of the model, 0 when unknown
 
 	width  int
 	height int
 
 	ctx    context.Context
 	cancel context.CancelFunc
+
+	// requestCtx is canceled to stop the running agent request or command
+	requestCtx    context.Context
+	cancelRequest context.CancelFunc
+}
+
+// ChatMessage represents a single message in the chat transcript.
+type ChatMessage struct {
+	Sender  string `json:"sender"`
+	Content string `json:"content"`
+	Output  bool   `json:"output,omitempty"` // command output, long outputs are collapsed
+	Usage   string `json:"usage,omitempty"`  // tokens and price of the response
+
+	Thinking bool `json:"thinking,omitempty"` // reasoning of the model, collapsed unless expanded
+
+	Time    time.Time     `json:"time"`              // when the message was added
+	Elapsed time.Duration `json:"elapsed,omitempty"` // how long the agent took to answer or the command ran
 }
 
 // Config holds the configuration for initializing the Model.
 type Config struct {
-	Agent    Agent
-	Executer Executer
+	Agent      Agent
+	Executer   Executer
+	AgentName  string        // display name shown in the header
+	ModelName  string        // model profile or model shown in the status bar
+	Transcript []ChatMessage // messages restored from a previous session
+
+	AutoApprove     bool // run valid commands without asking for confirmation
+	MaxAutoApproved int  // maximum number of auto-approved commands per session
+
+	// MaxIterations is how many commands and plans run in a row without the user's input,
+	// auto-approved or allowed by the policy, before Klama summarizes its findings and asks
+	// whether to continue, 0 disables the limit
+	MaxIterations int
+
+	Redactor *redact.Redactor // scrubs credentials from command output, nil disables redaction
+
+	OutputProcessor executer.OutputProcessor // shrinks large command outputs before they are sent to the agent
+
+	Hints func(ctx context.Context, message string) string // notes the agent gets with the user's messages, nil adds none
+
+	Target Target // the environment commands run against
+
+	Contexts ContextSwitcher // lists and switches the kube contexts with /context, nil disables the command
+
+	Policy PolicyEvaluator // decides how suggested commands are approved, nil disables it
+
+	// HighRiskKeyword, when set, is typed instead of 'yes' to approve commands the agent
+	// rates as high risk, which are then never approved automatically.
+	HighRiskKeyword string
+
+	Memory Memory // records the facts the agent learns, nil disables memory
+
+	Explainer Explainer // breaks suggested commands down when the user asks, nil disables it
+	Validator Validator // reviews suggested commands before they are presented, nil disables it
+
+	PlanWorkers int // plan steps run at a time, 0 uses executer.DefaultWorkers
+
+	AgentTimeout time.Duration // timeout of each agent request, 0 uses DefaultAgentTimeout
+	ExecTimeout  time.Duration // timeout of each command, 0 uses DefaultExecTimeout
+
+	WatchInterval time.Duration // time between the runs of a watched command, 0 uses DefaultWatchInterval
+
+	ShowThinking bool // shows the reasoning of reasoning models in collapsed sections
+
+	Timestamps bool // shows when each message was added and how long answers and commands took
+
+	SplitPane bool // shows the latest command output in a pane next to the chat
+	Inline    bool // prints the conversation into the scrollback instead of a full-screen chat
+	Plain     bool // no decorations or animations and a line per state change, for screen readers, implies Inline
+
+	Notifications Notifications                      // alerts the user when Klama answers while they are away
+	Notify        func(method, message string) error // shows a notification, nil writes it to the terminal
+
+	Autosave         func(Model) error // saves a snapshot of the conversation, nil disables autosave
+	AutosaveInterval time.Duration     // time between snapshots
+
+	// Probe checks that the model answers and returns its latency when the session
+	// starts, so a broken endpoint or token shows before the first message, nil skips it
+	Probe func(context.Context) (time.Duration, error)
+
+	// Restart saves the conversation and resets its usage before Ctrl+R restarts it, as
+	// the user chose, nil only resets the agent
+	Restart func(Model, RestartOptions) error
+
+	CharLimit int // maximum number of characters in a message, zero uses the default and -1 disables the limit
+
+	Clipboard func(string) error // copies text, nil uses the system clipboard and OSC 52
+
+	Theme Theme // colors that override the dark theme
+
+	// DryRun never executes commands. The user runs approved commands and pastes their
+	// output back with /result.
+	DryRun bool
+
+	Logger *slog.Logger // receives the records of the session, nil discards them
+}
+
+// log returns the logger of the session.
+func (m Model) log() *slog.Logger {
+	return logger.Or(m.config.Logger)
+}
+
+// unknownContext names a protected target whose context could not be loaded.
+const unknownContext = "unknown"
+
+// Target describes the environment commands run against, such as a kube context.
+type Target struct {
+	Context   string
+	Namespace string
+	Protected bool // approving a command requires typing the context name, or unknownContext without one
+
+	// ProtectedFlags returns the protected context or cluster a command names with its
+	// flags instead of Context, empty when it names none, nil when commands cannot
+	ProtectedFlags func(command string) string
+}
+
+// ProtectedContext returns the protected context a command runs against: the one it
+// names with its flags, or Context when it is protected. It is empty for none.
+func (t Target) ProtectedContext(command string) string {
+	if t.ProtectedFlags != nil {
+		if name := t.ProtectedFlags(command); name != "" {
+			return name
+		}
+	}
+	return t.protectedName()
+}
+
+// protectedName returns the name typed to approve commands against the target, empty
+// when it is not protected.
+func (t Target) protectedName() string {
+	if !t.Protected {
+		return ""
+	}
+	return cmp.Or(t.Context, unknownContext)
 }
 
 // InitialModel creates and returns a new instance of Model with default values.
 func InitialModel(cfg Config) Model {
-	logger.Debug("Initializing UI model")
+	if cfg.Plain {
+		cfg.Inline = true
+	}
+	logger.Or(cfg.Logger).Debug("Initializing UI model")
 
 	ta := textarea.New()
 	ta.Placeholder = "Send a message..."
 	ta.Focus()
 	ta.Prompt = "┃ "
-	ta.CharLimit = 280
+	if cfg.Plain {
+		ta.Prompt = "> "
+		ta.Cursor.SetMode(cursor.CursorStatic)
+	}
+	ta.CharLimit = charLimit(cfg.CharLimit)
+	ta.MaxHeight = 0 // pasted text keeps all of its lines
 	ta.ShowLineNumbers = false
 	ta.KeyMap.InsertNewline.SetEnabled(false)
 	ta.SetHeight(3)
 	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
 
 	vp := viewport.New(80, 20)
-	vp.SetContent(welcomeMsg)
+
+	// a restored transcript is rendered on the first window size message
+	ready := len(cfg.Transcript) > 0
 
 	ctx, cancel := context.WithCancel(context.Background())
+	requestCtx, cancelRequest := context.WithCancel(ctx)
 
-	newStyle := func(color string) lipgloss.Style {
-		return lipgloss.NewStyle().Foreground(lipgloss.Color(color))
+	theme := Themes[ThemeDark].Override(cfg.Theme)
+	newStyle := func(field func(Theme) string) lipgloss.Style {
+		return lipgloss.NewStyle().Foreground(theme.color(field))
 	}
 
-	return Model{
+	m := Model{
+		config:      cfg,
+		theme:       theme,
 		agent:       cfg.Agent,
 		executer:    cfg.Executer,
+		agentName:   cfg.AgentName,
 		textarea:    ta,
 		viewport:    vp,
-		messages:    []string{},
-		senderStyle: newStyle(colorSender),
-		klamaStyle:  newStyle(colorKlama),
-		systemStyle: newStyle(colorSystem),
-		errorStyle:  newStyle(colorError),
-		helpStyle:   newStyle(colorHelp),
-		priceStyle:  newStyle(colorPrice),
-		typingStyle: newStyle(colorHelp),
+		messages:    cfg.Transcript,
+		senderStyle: newStyle(func(t Theme) string { return t.Sender }),
+		klamaStyle:  newStyle(func(t Theme) string { return t.Klama }),
+		systemStyle: newStyle(func(t Theme) string { return t.System }),
+		errorStyle:  newStyle(func(t Theme) string { return t.Error }),
+		helpStyle:   newStyle(func(t Theme) string { return t.Help }),
+		priceStyle:  newStyle(func(t Theme) string { return t.Price }),
+		typingStyle: newStyle(func(t Theme) string { return t.Help }),
 		ctx:         ctx,
 		cancel:      cancel,
 		state:       StateTyping,
+		ready:       ready,
+
+		focusedOutput:   -1,
+		split:           cfg.SplitPane && !cfg.Inline,
+		pane:            viewport.New(0, 0),
+		paneOutput:      -1,
+		expandedOutputs: map[int]bool{},
+
+		requestCtx:    requestCtx,
+		cancelRequest: cancelRequest,
+	}
+	m.viewport.SetContent(m.welcome())
+	// a restored conversation already fills part of the context window
+	m.updateContextUsage()
+	return m
+}
+
+// charLimit returns the textarea character limit for the configured limit.
+func charLimit(limit int) int {
+	switch {
+	case limit == 0:
+		return defaultCharLimit
+	case limit < 0:
+		return 0
+	default:
+		return limit
 	}
 }
 
 // Init initializes the Model.
 func (m Model) Init() tea.Cmd {
-	return textarea.Blink
+	if m.config.Plain {
+		return tea.Batch(m.scheduleAutosave(), m.probe())
+	}
+	return tea.Batch(textarea.Blink, m.scheduleAutosave(), m.probe())
 }
 
 // View renders the current state of the application.
 func (m Model) View() string {
-	return fmt.Sprintf("%s\n%s\n%s", m.headerView(), m.viewport.View(), m.footerView())
+	if m.config.Inline {
+		return m.inlineView()
+	}
+	return fmt.Sprintf("%s\n%s\n%s", m.headerView(), m.splitView(), m.footerView())
 }
 
 func (m Model) headerView() string {
-	title := titleStyle.Render("Klama")
-	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(title)))
+	titleText := "Klama"
+	if m.agentName != "" {
+		titleText += " - " + m.agentName
+	}
+	if target := m.config.Target; target.Context != "" || target.Protected {
+		titleText += " [ctx: " + cmp.Or(target.Context, unknownContext)
+		if target.Namespace != "" {
+			titleText += " | ns: " + target.Namespace
+		}
+		titleText += "]"
+		if target.Protected {
+			titleText += " PROTECTED"
+		}
+	}
+	if m.config.DryRun {
+		titleText += " [dry run]"
+	} else if m.config.AutoApprove && !m.config.Target.Protected {
+		titleText += fmt.Sprintf(" [autopilot %d/%d]", m.autoApproved, m.config.MaxAutoApproved)
+	}
+
+	style := titleStyle
+	if m.config.Target.Protected {
+		style = style.Foreground(m.errorStyle.GetForeground())
+	}
+	title := style.Render(titleText)
+	line := strings.Repeat("─", max(0, m.fullWidth()-lipgloss.Width(title)))
 	return lipgloss.JoinHorizontal(lipgloss.Center, title, line)
 }
 
 func (m Model) footerView() string {
 	info := infoStyle.Render(fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100))
-	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(info)))
-	border := lipgloss.JoinHorizontal(lipgloss.Center, line, info)
+	counter := m.renderSearchStatus()
+	if counter == "" {
+		counter = m.renderCharCounter()
+	}
+	line := strings.Repeat("─", max(0, m.fullWidth()-lipgloss.Width(info)-lipgloss.Width(counter)))
+	border := lipgloss.JoinHorizontal(lipgloss.Center, line, counter, info)
 	return lipgloss.JoinVertical(
 		lipgloss.Left,
 		border,
 		m.renderInputArea(),
 		m.renderErrorMessage(),
 		m.renderHelpText(),
-		m.renderPriceText(),
+		m.renderStatusBar(),
 	)
 }
 
 func (m Model) renderInputArea() string {
+	var status string
 	switch m.state {
 	case StateAsking:
-		return m.typingStyle.Render("\n\nKlama is typing" + strings.Repeat(".", m.waitingDots))
+		status = "Klama is typing" + strings.Repeat(".", m.waitingDots) + m.retryStatus
 	case StateExecuting:
-		return m.typingStyle.Render("\n\nCommand executing" + strings.Repeat(".", m.waitingDots))
+		status = "Command executing" + strings.Repeat(".", m.waitingDots) + m.renderRemaining()
+	case StateChoosing:
+		return m.picker.View()
 	default:
 		return m.textarea.View()
 	}
+	if m.textarea.Value() == "" {
+		return m.typingStyle.Render("\n\n" + status)
+	}
+
+	// the message being queued replaces the empty lines above the status
+	ta := m.textarea
+	ta.SetHeight(ta.Height() - 1)
+	return m.typingStyle.Render(status) + "\n" + ta.View()
+}
+
+// renderCharCounter shows how many characters are left in the message.
+func (m Model) renderCharCounter() string {
+	if m.textarea.CharLimit == 0 || !m.acceptsInput() {
+		return ""
+	}
+	remaining := m.textarea.CharLimit - m.textarea.Length()
+	style := m.helpStyle
+	if remaining <= m.textarea.CharLimit/10 {
+		style = m.errorStyle
+	}
+	return style.Render(fmt.Sprintf(" %d characters left ", remaining))
+}
+
+// acceptsInput reports whether the textarea accepts input. Messages typed while Klama is
+// busy are queued.
+func (m Model) acceptsInput() bool {
+	return m.state == StateTyping || m.state == StateWaitingForConfirmation || m.state == StateEditingCommand || m.state == StatePlanApproval || m.busy()
 }
 
 func (m Model) renderErrorMessage() string {
 	if m.err != nil {
 		return m.errorStyle.Render("Error: " + m.err.Error())
 	}
+	if m.notice != "" {
+		return m.systemStyle.Render(m.notice)
+	}
+	if m.state == StateChoosing {
+		return m.renderPickerHelp()
+	}
 	return ""
 }
 
 func (m Model) renderHelpText() string {
+	// inline mode leaves the history, and scrolling it, to the terminal
 	var helpText string
-	if m.showCmdResponse {
-		helpText += "Ctrl+S: to hide command response."
-	} else {
-		helpText += "Ctrl+S: to show command response."
+	switch {
+	case m.config.Inline:
+	case m.split:
+		helpText = "Tab: to focus a command output, Enter: to expand or collapse it. Ctrl+S: to hide the output pane, Shift+↑/↓: to scroll it. "
+	default:
+		helpText = "Tab: to focus a command output, Enter: to expand or collapse it. Ctrl+S: to show outputs in a pane. "
 	}
 
-	helpText += "\nCtrl+C: to exit, Ctrl+R: to restart. Scroll with ↑, ↓, Page Up, Page Down, and mouse wheel."
+	if m.config.ShowThinking {
+		if m.expandThinking {
+			helpText += "Ctrl+T: to collapse thinking. "
+		} else {
+			helpText += "Ctrl+T: to expand thinking. "
+		}
+	}
+
+	helpText += "Ctrl+E: to export the transcript. /attach <path>: to attach a file. /watch <command>: to watch a command."
+	if m.config.Contexts != nil {
+		helpText += " /context: to switch the kube context."
+	}
+	helpText += "\nCtrl+Y: to copy the suggested command, Alt+Y: to copy the last answer."
+	if !m.config.Inline {
+		helpText += " Ctrl+N: to open a new tab, Ctrl+←/→: to switch tabs."
+	}
+	helpText += "\nCtrl+C: to exit, Esc: to cancel a running request, Ctrl+R: to restart."
+	if !m.config.Inline {
+		helpText += " Scroll with ↑, ↓, Page Up, Page Down, and mouse wheel."
+	}
 
 	return m.helpStyle.Width(m.width).Render(helpText)
 }
 
-func (m Model) renderPriceText() string {
-	return m.priceStyle.Width(m.width).Render(m.agent.LogUsage())
+// updateContextUsage refreshes the context usage shown in the status bar.
+func (m *Model) updateContextUsage() {
+	if reporter, ok := m.agent.(ContextReporter); ok {
+		m.contextUsed, m.contextWindow = reporter.ContextUsage()
+	}
+}
+
+// updateChat adds a message to the chat. A message of the user was sent from the input,
+// which is cleared, other messages keep what the user is typing.
+func (m *Model) updateChat(sender, message string) {
+	m.appendMessage(ChatMessage{Sender: sender, Content: message})
+	if sender == SenderUser {
+		m.textarea.Reset()
+	}
+	m.updateViewportContent()
+}
+
+// addKlamaMessage adds a Klama message, annotated with the usage of the responses that
+// led to it.
+func (m *Model) addKlamaMessage(message string) {
+	var usage string
+	if m.pendingUsage.TotalTokens > 0 {
+		usage = formatUsage(m.pendingUsage, m.pendingCost)
+	}
+	elapsed := m.pendingElapsed
+	m.pendingUsage, m.pendingCost, m.pendingElapsed = llm.Usage{}, 0, 0
+
+	m.appendMessage(ChatMessage{Sender: SenderKlama, Content: message, Usage: usage, Elapsed: elapsed})
+	m.updateViewportContent()
+}
+
+// formatUsage formats usage as "1.2k in / 300 out • 0.0040$".
+func formatUsage(usage llm.Usage, cost float64) string {
+	return fmt.Sprintf("%s in / %s out • %.4f$", formatTokens(usage.PromptTokens), formatTokens(usage.CompletionTokens), cost)
 }
 
-func (m *Model) updateChat(style lipgloss.Style, prefix, message string) {
-	m.messages = append(m.messages, style.Render(prefix+": ")+message)
+// formatTokens formats a number of tokens as 300, 1.2k or 128k.
+func formatTokens(n int) string {
+	if n < 1000 {
+		return strconv.Itoa(n)
+	}
+	return strings.TrimSuffix(strconv.FormatFloat(float64(n)/1000, 'f', 1, 64), ".0") + "k"
+}
+
+// addCommandOutput records a command output in the transcript, with how long the command
+// ran, 0 when it is unknown.
+func (m *Model) addCommandOutput(output string, elapsed time.Duration) {
+	m.appendMessage(ChatMessage{Sender: SenderSystem, Content: output, Output: true, Elapsed: elapsed})
 	m.updateViewportContent()
+}
 
+func (m Model) senderStyleFor(sender string) lipgloss.Style {
+	switch sender {
+	case SenderUser:
+		return m.senderStyle
+	case SenderKlama:
+		return m.klamaStyle
+	case SenderSystem:
+		return m.systemStyle
+	default:
+		return lipgloss.NewStyle()
+	}
+}
+
+// Transcript returns the chat messages of the session.
+func (m Model) Transcript() []ChatMessage {
+	return m.messages
+}
+
+// Outcome summarizes how the session ended.
+func (m Model) Outcome() string {
+	switch {
+	case m.err != nil:
+		return OutcomeError
+	case m.state == StateWaitingForConfirmation || m.state == StateEditingCommand || m.state == StatePlanApproval:
+		return OutcomeAwaitingApproval
+	case m.state == StateTyping && len(m.messages) > 0 && m.messages[len(m.messages)-1].Sender == SenderKlama:
+		return OutcomeAnswered
+	default:
+		return OutcomeInProgress
+	}
 }
 
 func (m *Model) updateViewportContent() {
-	content := lipgloss.NewStyle().Width(m.viewport.Width).Render(strings.Join(m.messages, "\n\n"))
+	rendered := make([]string, 0, len(m.messages))
+	wrap := lipgloss.NewStyle().Width(m.viewport.Width)
+	focusedLine := 0
+	for i, msg := range m.messages {
+		if i == m.focusedOutput {
+			focusedLine = lipgloss.Height(wrap.Render(strings.Join(rendered, "\n\n"))) + 1
+		}
+		rendered = append(rendered, m.renderMessage(i, msg))
+	}
+	content := wrap.Render(strings.Join(rendered, "\n\n"))
+	m.updatePane()
+	if m.search.active() {
+		m.viewport.SetContent(m.highlightMatches(content))
+		m.scrollToMatch()
+		return
+	}
 	m.viewport.SetContent(content)
-	m.textarea.Reset()
+	if m.focusedOutput >= 0 {
+		m.viewport.SetYOffset(focusedLine)
+		return
+	}
 	m.viewport.GotoBottom()
 }
 
+// renderMessage renders a message of the chat at index.
+func (m Model) renderMessage(index int, msg ChatMessage) string {
+	if msg.Output {
+		return m.renderOutput(index, msg)
+	}
+	if msg.Thinking {
+		return m.renderThinking(msg)
+	}
+	text := m.senderStyleFor(msg.Sender).Render(msg.Sender+": ") + msg.Content
+	if annotation := m.renderAnnotation(msg); annotation != "" {
+		text += "\n" + annotation
+	}
+	return text
+}
+
 // Update handles all the application logic and state transitions.
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	wasBusy := m.busy()
+	next, cmd := m.update(msg)
+	m, cmd = next.(Model).trackBusy(wasBusy, msg, cmd)
+	if m.config.Inline {
+		return m.printMessages(cmd)
+	}
+	return m, cmd
+}
 
+func (m Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		return m.handleWindowSizeMsg(msg)
 
+	case tea.FocusMsg:
+		m.blurred = false
+		return m, nil
+
+	case tea.BlurMsg:
+		m.blurred = true
+		return m, nil
+
 	case tea.MouseMsg:
 		return m.handleMouseMsg(msg)
 
 	case tea.KeyMsg:
 		return m.handleKeyMsg(msg)
 
+	case autosaveMsg:
+		return m.handleAutosave()
+
+	case probeMsg:
+		return m.handleProbe(msg)
+
+	case explanationMsg:
+		return m.handleExplanation(msg)
+
+	case watchTickMsg:
+		return m.handleWatchTick(msg)
+
+	case watchResultMsg:
+		return m.handleWatchResult(msg)
+
+	case contextSwitchedMsg:
+		return m.handleContextSwitched(msg)
+
 	case tickMsg:
 		m.waitingDots = (m.waitingDots + 1) % 4
 		return m, m.think()
 
+	case RetryMsg:
+		m.log().Warn("Retrying model request", "attempt", msg.Attempt, "max_attempts", msg.MaxAttempts, "error", msg.Err)
+		m.retryStatus = fmt.Sprintf(" retrying (%d/%d)...", msg.Attempt, msg.MaxAttempts)
+		return m, nil
+
+	case timedResponse:
+		m.pendingElapsed += msg.elapsed
+		return m.Update(msg.response)
+
 	case agent.AgentResponse:
-		return m.handleAgentResponse(msg)
+		m.retryStatus = ""
+		m.pendingUsage = m.pendingUsage.Add(msg.Usage)
+		m.pendingCost += msg.Cost
+		m.rememberFacts(msg.Remember)
+		m.addThinking(msg.Thinking)
+		m.updateContextUsage()
+		next, cmd := m.handleAgentResponse(msg)
+		return next.(Model).sendQueued(cmd)
+
+	case validationMsg:
+		return m.handleValidation(msg)
+
+	case preflightMsg:
+		return m.suggestCommand(msg.response, msg.warning)
+
+	case planStepsMsg:
+		return m.handlePlanSteps(msg)
 
 	case executer.ExecuterResponse:
 		return m.handleExecuterResponse(msg)
 
+	case interactiveDoneMsg:
+		return m.handleInteractiveDone(msg)
+
+	case outputProcessedMsg:
+		return m, tea.Batch(
+			m.waitForAgentResponse(string(msg)),
+			m.think(),
+		)
+
+	case clearNoticeMsg:
+		if int(msg) == m.noticeID {
+			m.notice = ""
+		}
+		return m, nil
+
 	case errMsg:
 		m.err = msg
-		if m.state == StateAsking || m.state == StateExecuting {
+		m.retryStatus = ""
+		if m.busy() {
 			m.state = StateTyping
+			m.restoreQueued()
 		}
 		return m, nil
 	}
@@ -259,7 +834,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) handleWindowSizeMsg(msg tea.WindowSizeMsg) (tea.Model, tea.Cmd) {
-	logger.Debugf("Window size message received: %v\n", msg)
+	m.log().Debug("Window resized", "width", msg.Width, "height", msg.Height)
 
 	m.width = msg.Width
 	m.height = msg.Height
@@ -267,9 +842,12 @@ func (m Model) handleWindowSizeMsg(msg tea.WindowSizeMsg) (tea.Model, tea.Cmd) {
 	headerHeight := lipgloss.Height(m.headerView())
 	footerHeight := lipgloss.Height(m.footerView())
 
-	m.viewport.Width = msg.Width - 2
 	m.viewport.Height = msg.Height - headerHeight - footerHeight
+	m.layout(msg.Width - 2)
 	m.textarea.SetWidth(msg.Width - 2)
+	if m.picker != nil {
+		m.picker.SetWidth(msg.Width - 2)
+	}
 
 	// update chat history if the session is on `ready` state
 	if m.ready {
@@ -281,43 +859,107 @@ func (m Model) handleWindowSizeMsg(msg tea.WindowSizeMsg) (tea.Model, tea.Cmd) {
 
 func (m Model) handleMouseMsg(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
+	if m.split && msg.X > m.viewport.Width {
+		m.pane, cmd = m.pane.Update(msg)
+		return m, cmd
+	}
 	m.viewport, cmd = m.viewport.Update(msg)
 	return m, cmd
 }
 
 func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.restartStep != 0 {
+		return m.handleRestartKey(msg)
+	}
+	if m.state == StateChoosing && msg.Type != tea.KeyCtrlC {
+		return m.handlePickerKey(msg)
+	}
+	if m.search.active() {
+		switch msg.String() {
+		case "n":
+			return m.jumpToMatch(m.search.current + 1)
+		case "N":
+			return m.jumpToMatch(m.search.current - 1)
+		case "esc":
+			m.closeSearch()
+			return m, nil
+		}
+	}
+
+	var handled bool
+	if m, handled = m.handleOutputFocusKey(msg); handled {
+		return m, nil
+	}
+
 	switch msg.Type {
 	case tea.KeyUp, tea.KeyDown, tea.KeyPgUp, tea.KeyPgDown:
 		var cmd tea.Cmd
 		m.viewport, cmd = m.viewport.Update(msg)
 		return m, cmd
 
-	case tea.KeyCtrlC, tea.KeyEsc:
+	case tea.KeyEsc:
+		if m.state == StateAsking || m.state == StateExecuting {
+			return m.handleInterrupt()
+		}
+		if m.state == StateEditingCommand {
+			return m.cancelEdit()
+		}
 		m.cancel()
 		return m, tea.Quit
 
-	case tea.KeyCtrlR:
-		logger.Debug("Restarting the session")
+	case tea.KeyCtrlC:
 		m.cancel()
-		m.agent.Reset()
-		newModel := InitialModel(Config{
-			Agent:    m.agent,
-			Executer: m.executer,
-		})
-		newModel.showCmdResponse = m.showCmdResponse
-		return newModel.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+		return m, tea.Quit
+
+	case tea.KeyCtrlR:
+		return m.startRestart()
+
+	case tea.KeyShiftUp, tea.KeyShiftDown:
+		if msg.Type == tea.KeyShiftUp {
+			m.pane.LineUp(1)
+		} else {
+			m.pane.LineDown(1)
+		}
+		return m, nil
 
 	case tea.KeyCtrlS:
-		logger.Debug("Toggling command response visibility")
-		m.showCmdResponse = !m.showCmdResponse
+		return m.toggleSplit()
+
+	case tea.KeyCtrlT:
+		if !m.config.ShowThinking {
+			return m, nil
+		}
+		m.log().Debug("Toggling thinking visibility")
+		m.expandThinking = !m.expandThinking
+		if m.ready {
+			m.updateViewportContent()
+		}
 		return m, nil
 
+	case tea.KeyCtrlE:
+		return m.handleExport()
+
+	case tea.KeyCtrlY:
+		return m.handleCopyCommand()
+
 	case tea.KeyEnter:
 		return m.handleEnterKey()
 
 	default:
-		if m.state == StateTyping || m.state == StateWaitingForConfirmation {
+		if msg.Alt && msg.String() == "alt+y" {
+			return m.handleCopyAnswer()
+		}
+		if i, ok := m.followupKey(msg); ok {
+			return m.sendMessage(m.followups[i])
+		}
+		if m.acceptsInput() {
 			m.err = nil
+			if m.search.active() {
+				m.closeSearch()
+			}
+			if msg.Paste {
+				return m.handlePaste(msg)
+			}
 			var cmd tea.Cmd
 			m.textarea, cmd = m.textarea.Update(msg)
 			return m, cmd
@@ -327,72 +969,309 @@ func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// handlePaste inserts pasted text, and reports when it did not fit in the character limit
+// instead of truncating it silently.
+func (m Model) handlePaste(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	pasted := len(runeutil.NewSanitizer().Sanitize(slices.Clone(msg.Runes)))
+	before := m.textarea.Length()
+
+	var cmd tea.Cmd
+	m.textarea, cmd = m.textarea.Update(msg)
+
+	if inserted := m.textarea.Length() - before; inserted < pasted {
+		m.log().Info("Pasted text truncated", "pasted", pasted, "inserted", inserted)
+		m.err = fmt.Errorf("pasted text was truncated to %d of %d characters, raise ui.char_limit to paste more", inserted, pasted)
+	}
+	return m, cmd
+}
+
+// handleInterrupt cancels the running agent request or command and lets the user type a
+// new message. Results of the canceled request are dropped.
+func (m Model) handleInterrupt() (tea.Model, tea.Cmd) {
+	m.log().Debug("Canceling the running request")
+	m.cancelRequest()
+	m.requestCtx, m.cancelRequest = context.WithCancel(m.ctx)
+
+	if m.state == StateExecuting && m.plan.active() {
+		// the next message answers the plan, let the agent know it did not finish
+		m.interruptNote = "The user canceled the plan before its steps finished.\n"
+		m.updateChat(SenderSystem, "Plan canceled.")
+	} else if m.state == StateExecuting {
+		// the next message answers the command, let the agent know it did not finish
+		m.interruptNote = fmt.Sprintf("The user canceled the command `%v` before it finished.\n", m.confirmationCmd)
+		m.updateChat(SenderSystem, "Command canceled.")
+	} else {
+		m.updateChat(SenderSystem, "Request canceled.")
+	}
+
+	m.state = StateTyping
+	m.restoreQueued()
+	m.plan = planState{}
+	m.retryStatus = ""
+	m.execDeadline = time.Time{}
+	m.editedFromCmd = ""
+	m.err = nil
+	return m, nil
+}
+
 func (m Model) handleEnterKey() (tea.Model, tea.Cmd) {
 	if !m.ready {
 		m.ready = true
 	}
+	// the user is back, Klama may run commands on its own again
+	m.autoRun, m.checkpointAsked = 0, false
 
 	switch m.state {
 	case StateTyping:
+		if m.search.active() {
+			m.closeSearch()
+		}
 		query := m.textarea.Value()
 		if query == "" {
 			m.err = fmt.Errorf("message cannot be empty")
 			return m, nil
 		}
-		m.updateChat(m.senderStyle, "You", query)
-		m.state = StateAsking
-		return m, tea.Batch(
-			m.waitForAgentResponse(query),
-			m.think(),
-		)
+		if m.switchingContext != "" {
+			m.err = fmt.Errorf("switching to context %s, wait until it is done", m.switchingContext)
+			return m, nil
+		}
+		if query == attachCommand || strings.HasPrefix(query, attachCommand+" ") {
+			return m.handleAttach(query)
+		}
+		if query == resultCommand || strings.HasPrefix(query, resultCommand+" ") || strings.HasPrefix(query, resultCommand+"\n") {
+			return m.handleResult(query)
+		}
+		if query == watchCommand || strings.HasPrefix(query, watchCommand+" ") {
+			return m.handleWatch(query)
+		}
+		if query == contextCommand || strings.HasPrefix(query, contextCommand+" ") {
+			return m.handleContext(query)
+		}
+		if term, ok := strings.CutPrefix(query, "/"); ok && strings.TrimSpace(term) != "" {
+			if m.config.Inline {
+				m.err = fmt.Errorf("search the scrollback of the terminal in inline mode")
+				return m, nil
+			}
+			return m.handleSearch(term)
+		}
+		return m.sendMessage(query)
 
 	case StateWaitingForConfirmation:
 		return m.handleConfirmation()
+
+	case StateEditingCommand:
+		return m.handleEditedCommand()
+
+	case StatePlanApproval:
+		return m.handlePlanApproval()
+
+	case StateAsking, StateExecuting:
+		return m.queueMessage()
 	}
 
 	return m, nil
 }
 
+// sendMessage sends the user's message to the agent, with the pending attachments.
+func (m Model) sendMessage(query string) (tea.Model, tea.Cmd) {
+	m.updateChat(SenderUser, query)
+	m.state = StateAsking
+	message := m.interruptNote + m.watchNote + m.contextNote + m.preflightNote() + m.skippedResultNote() + m.interactiveNote() + withAttachments(m.attachments, query)
+	m.attachments = nil
+	m.interruptNote = ""
+	m.watchNote = ""
+	m.contextNote = ""
+	m.followups = nil
+	return m, tea.Batch(
+		m.askWithHints(query, message),
+		m.think(),
+	)
+}
+
+// askWithHints asks the agent with the message, preceded by the hints about what the
+// user's query mentions.
+func (m Model) askWithHints(query, message string) tea.Cmd {
+	if m.config.Hints == nil {
+		return m.waitForAgentResponse(message)
+	}
+
+	hints, ctx := m.config.Hints, m.requestCtx
+	return func() tea.Msg {
+		hintCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		note := hints(hintCtx, query)
+		cancel()
+		return m.waitForAgentResponse(note + message)()
+	}
+}
+
 func (m Model) handleConfirmation() (tea.Model, tea.Cmd) {
-	userInput := strings.TrimSpace(strings.ToLower(m.textarea.Value()))
+	userInput, timeout, err := splitTimeout(strings.TrimSpace(strings.ToLower(m.textarea.Value())))
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	m.textarea.Reset()
+
+	if name := m.approvalName(); name != "" && userInput == strings.ToLower(name) {
+		m.commandTimeout = timeout
+		return m.executeConfirmedCommand()
+	}
 
 	switch userInput {
 	case "yes", "y":
-		m.state = StateExecuting
-		m.updateChat(m.systemStyle, "System", fmt.Sprintf("Executing command `%v`", m.systemStyle.Render(m.confirmationCmd)))
-		return m, tea.Batch(
-			m.waitForExecution(m.confirmationCmd),
-			m.think(),
-		)
+		switch {
+		case m.mutation != "":
+			m.err = fmt.Errorf("the command changes %s, enter its name to approve the command", m.mutation)
+			return m, nil
+		case m.protectedContext != "":
+			m.err = fmt.Errorf("context %s is protected, enter its name to approve the command", m.protectedContext)
+			return m, nil
+		case m.highRiskKeyword() != "":
+			m.err = fmt.Errorf("the command is rated high risk, enter '%s' to approve the command", m.highRiskKeyword())
+			return m, nil
+		}
+		m.commandTimeout = timeout
+		return m.executeConfirmedCommand()
 
 	case "no", "n":
 		m.state = StateAsking
 		rejectMsg := "User did not approve the command. Please suggest a different command or end the session."
-		m.updateChat(m.systemStyle, "System", rejectMsg)
+		m.updateChat(SenderSystem, rejectMsg)
+		if m.preflightWarn != "" {
+			rejectMsg = fmt.Sprintf("User did not approve the command because a permission check failed: %v\nPlease suggest a different command or end the session.", m.preflightWarn)
+			m.preflightWarn = ""
+		}
 		return m, tea.Batch(
 			m.waitForAgentResponse(rejectMsg),
 			m.think(),
 		)
 
+	case "edit", "e":
+		m.state = StateEditingCommand
+		m.updateChat(SenderSystem, "Edit the command and press Enter to run it, or Esc to go back to the suggested command.")
+		m.textarea.SetValue(m.confirmationCmd)
+		return m, nil
+
 	case "ask", "a":
 		m.state = StateTyping
-		m.updateChat(m.systemStyle, "System", "Breaking out to ask a question")
+		m.updateChat(SenderSystem, "Breaking out to ask a question")
 		return m, nil
 
+	case "explain", "x":
+		return m.explain()
+
 	default:
-		m.err = fmt.Errorf("please answer with 'yes', 'no', or 'ask'")
-		m.textarea.Reset()
+		approve := "'yes'"
+		switch {
+		case m.mutation != "":
+			approve = "the resource name"
+		case m.protectedContext != "":
+			approve = "the context name"
+		case m.highRiskKeyword() != "":
+			approve = fmt.Sprintf("'%s'", m.highRiskKeyword())
+		}
+		m.err = fmt.Errorf("please answer with %s, 'no', 'edit', 'explain', or 'ask'", approve)
 		return m, nil
 	}
 }
 
+// cancelEdit drops the edit and asks again to confirm the command as it was suggested.
+func (m Model) cancelEdit() (tea.Model, tea.Cmd) {
+	m.state = StateWaitingForConfirmation
+	m.err = nil
+	m.textarea.Reset()
+	m.updateChat(SenderSystem, fmt.Sprintf("Edit canceled, the command is still `%v`\n%v", m.systemStyle.Render(m.confirmationCmd), m.confirmationHelp()))
+	return m, nil
+}
+
+func (m Model) handleEditedCommand() (tea.Model, tea.Cmd) {
+	command := strings.TrimSpace(m.textarea.Value())
+
+	exec, err := m.executerFor(m.confirmationTool)
+	if err == nil {
+		err = exec.Validate(command)
+	}
+	if err != nil {
+		m.log().Info("Edited command is invalid", "command", command, "error", err)
+		m.err = fmt.Errorf("the edited command is invalid: %w", err)
+		return m, nil
+	}
+
+	if m.config.Policy != nil {
+		decision, err := m.config.Policy.Evaluate(command)
+		switch {
+		case err != nil:
+			m.err = fmt.Errorf("the edited command was blocked because the policy could not be evaluated: %w", err)
+			return m, nil
+		case decision.Denied():
+			m.err = fmt.Errorf("the edited command was %v", decision)
+			return m, nil
+		}
+	}
+
+	m.textarea.Reset()
+	if command != m.confirmationCmd {
+		m.editedFromCmd = m.confirmationCmd
+		m.confirmationCmd = command
+	}
+
+	m.mutation = ""
+	if target, ok := mutationOf(exec, command); ok {
+		m.mutation = target.Name
+	}
+	m.protectedContext = m.config.Target.ProtectedContext(command)
+
+	// the edited command must be approved again with the context or resource name
+	if m.approvalName() != "" {
+		m.state = StateWaitingForConfirmation
+		m.updateChat(SenderSystem, fmt.Sprintf("Edited command `%v`\n%v", m.systemStyle.Render(m.confirmationCmd), m.confirmationHelp()))
+		return m, nil
+	}
+
+	return m.executeConfirmedCommand()
+}
+
+// executeConfirmedCommand runs the command the user approved.
+func (m Model) executeConfirmedCommand() (tea.Model, tea.Cmd) {
+	if m.config.DryRun {
+		return m.awaitResult([]string{m.confirmationCmd})
+	}
+	exec, _ := m.executerFor(m.confirmationTool)
+	if runner, ok := interactiveOf(exec, m.confirmationCmd); ok {
+		return m.runInteractive(runner)
+	}
+
+	m.state = StateExecuting
+	m.updateChat(SenderSystem, fmt.Sprintf("Executing command `%v`", m.systemStyle.Render(m.confirmationCmd)))
+	m.execDeadline = time.Now().Add(m.execTimeout())
+	m.commandTimeout = 0
+	return m, tea.Batch(
+		m.waitForExecution(m.confirmationCmd),
+		m.think(),
+	)
+}
+
 func (m Model) handleAgentResponse(msg agent.AgentResponse) (tea.Model, tea.Cmd) {
 	m.state = StateTyping
+	m.followups = nil
+	if len(msg.Plan) > 0 && msg.RunCommand == "" {
+		m.log().Debug("Agent proposed a plan", "commands", len(msg.Plan))
+		return m.proposePlan(msg)
+	}
+
 	if msg.RunCommand != "" {
-		logger.Debugf("Agent suggested a command to run: `%v`\n", msg.RunCommand)
-		// validate the command
-		if err := m.executer.Validate(msg.RunCommand); err != nil {
-			logger.Debug(err)
+		m.log().Debug("Agent suggested a command", "command", msg.RunCommand)
+		if _, ok := m.executer.(ToolSelector); !ok {
+			msg.Tool = ""
+		}
+
+		// validate the command with the tool it targets
+		exec, err := m.executerFor(msg.Tool)
+		if err == nil {
+			err = exec.Validate(msg.RunCommand)
+		}
+		if err != nil {
+			m.log().Info("Suggested command is invalid", "command", msg.RunCommand, "error", err)
 			// command is invalid, return to the agent
 			prompt := fmt.Sprintf("The suggested command is invalid: %v\nDo not apologize or mention the incorrect suggestion in your response", err)
 			m.state = StateAsking
@@ -402,69 +1281,378 @@ func (m Model) handleAgentResponse(msg agent.AgentResponse) (tea.Model, tea.Cmd)
 			)
 		}
 
-		m.state = StateWaitingForConfirmation
-		m.confirmationCmd = msg.RunCommand
+		m.policyDecision = policy.Decision{}
+		if m.config.Policy != nil {
+			decision, err := m.config.Policy.Evaluate(msg.RunCommand)
+			switch {
+			case err != nil:
+				// a broken rule must not let commands through
+				m.log().Error("Failed to evaluate the policy", "command", msg.RunCommand, "error", err)
+				return m.blockCommand(msg.RunCommand, fmt.Sprintf("blocked because the policy could not be evaluated: %v", err))
+			case decision.Denied():
+				return m.blockCommand(msg.RunCommand, decision.String())
+			}
+			m.policyDecision = decision
+		}
 
-		var klamaResp string
-		if msg.Answer != "" {
-			klamaResp += msg.Answer + "\n"
+		m.verdict, m.verdictErr = agent.Verdict{}, nil
+		if m.config.Validator != nil {
+			m.state = StateAsking
+			return m, tea.Batch(
+				m.validate(msg),
+				m.think(),
+			)
 		}
-		klamaResp += "I suggest running the command `" + m.systemStyle.Render(msg.RunCommand)
-		klamaResp += fmt.Sprintf("`\n%v", msg.Reason)
 
-		m.updateChat(m.klamaStyle, "Klama", klamaResp)
-		m.updateChat(m.systemStyle, "System", "Enter 'yes' to approve, 'no' to reject, or 'ask' to break out and ask a question.")
-	} else {
-		m.updateChat(m.klamaStyle, "Klama", msg.Answer)
+		return m.presentCommand(msg)
+	}
+
+	m.addKlamaMessage(msg.Answer)
+	m.showFollowups(msg.SuggestedFollowups)
+	m.showOptions(msg.Options)
+	return m, nil
+}
+
+// presentCommand runs the preflight check of a valid command, if its executer has one,
+// and presents it to the user.
+func (m Model) presentCommand(msg agent.AgentResponse) (tea.Model, tea.Cmd) {
+	exec, _ := m.executerFor(msg.Tool)
+
+	// preflight checks run commands, dry runs never do
+	if checker, ok := exec.(PreflightChecker); ok && !m.config.DryRun {
+		m.state = StateAsking
+		return m, tea.Batch(
+			m.runPreflight(checker, msg),
+			m.think(),
+		)
+	}
+
+	return m.suggestCommand(msg, "")
+}
+
+// blockCommand tells the user and the agent that the suggested command was not run
+// because of the policy.
+func (m Model) blockCommand(command, reason string) (tea.Model, tea.Cmd) {
+	m.state = StateAsking
+	m.updateChat(SenderSystem, m.errorStyle.Render(fmt.Sprintf("Command `%v` %v", command, reason)))
+
+	prompt := fmt.Sprintf("The suggested command was %v\nSuggest a different command that complies with the policy, or end the session.", reason)
+	return m, tea.Batch(
+		m.waitForAgentResponse(prompt),
+		m.think(),
+	)
+}
+
+// suggestCommand presents a validated command to the user for confirmation. A preflight
+// warning is shown with it and always requires an explicit confirmation.
+func (m Model) suggestCommand(msg agent.AgentResponse, warning string) (tea.Model, tea.Cmd) {
+	m.state = StateWaitingForConfirmation
+	m.confirmationCmd = msg.RunCommand
+	m.confirmationTool = msg.Tool
+	m.preflightWarn = warning
+
+	m.highRisk = msg.RiskLevel == agent.LevelHigh
+	m.mutation = ""
+	exec, _ := m.executerFor(msg.Tool)
+	target, mutation := mutationOf(exec, msg.RunCommand)
+	if mutation {
+		m.mutation = target.Name
 	}
+	m.protectedContext = m.config.Target.ProtectedContext(msg.RunCommand)
 
+	var klamaResp string
+	if msg.Answer != "" {
+		klamaResp += msg.Answer + "\n"
+	}
+	klamaResp += "I suggest running the command `" + m.systemStyle.Render(msg.RunCommand) + "`"
+	if msg.Tool != "" {
+		klamaResp += " with the " + m.systemStyle.Render(msg.Tool) + " tool"
+	}
+	klamaResp += fmt.Sprintf("\n%v", msg.Reason)
+	if ratings := m.renderRatings(msg); ratings != "" {
+		klamaResp += "\n" + ratings
+	}
+
+	m.addKlamaMessage(klamaResp)
+
+	if warning != "" {
+		m.updateChat(SenderSystem, m.errorStyle.Render("Warning: "+warning))
+	}
+	if mutation {
+		m.updateChat(SenderSystem, m.errorStyle.Render(fmt.Sprintf("This command changes %v, it is recorded in the audit log.", target)))
+	}
+	_, interactive := interactiveOf(exec, msg.RunCommand)
+	if interactive {
+		m.updateChat(SenderSystem, "This command takes over the terminal. Its output is not sent to Klama, describe what you found once it exits.")
+	}
+
+	decision := m.policyDecision
+	if decision.Matched() {
+		m.updateChat(SenderSystem, "Command "+decision.String())
+	}
+	if verdict := m.renderVerdict(); verdict != "" {
+		m.updateChat(SenderSystem, verdict)
+	}
+
+	// an allow rule approves the command, unless it needs a closer look
+	if decision.Allowed() && m.approvalName() == "" && warning == "" && !interactive && !m.verdict.Unsafe() {
+		switch {
+		case !m.checkpointDue():
+			m.autoRun++
+			return m.executeConfirmedCommand()
+		case !m.checkpointAsked:
+			return m.checkpoint("command `" + msg.RunCommand + "`")
+		case !m.config.AutoApprove || m.config.DryRun:
+			m.updateChat(SenderSystem, m.checkpointNote())
+		}
+	}
+
+	if m.config.AutoApprove && !m.config.DryRun {
+		switch {
+		case mutation:
+			m.updateChat(SenderSystem, "The command changes a resource, confirmation is required.")
+		case m.protectedContext != "":
+			m.updateChat(SenderSystem, fmt.Sprintf("Context %s is protected, confirmation is required.", m.protectedContext))
+		case m.highRiskKeyword() != "":
+			m.updateChat(SenderSystem, "The command is rated high risk, confirmation is required.")
+		case warning != "":
+			m.updateChat(SenderSystem, "The permission check failed, confirmation is required.")
+		case interactive:
+			m.updateChat(SenderSystem, "The command takes over the terminal, confirmation is required.")
+		case m.verdict.Unsafe():
+			m.updateChat(SenderSystem, "The validator flagged the command as unsafe, confirmation is required.")
+		case decision.RequiresConfirmation():
+			m.updateChat(SenderSystem, "The policy requires confirmation.")
+		case m.checkpointDue() && !m.checkpointAsked:
+			return m.checkpoint("command `" + msg.RunCommand + "`")
+		case m.checkpointDue():
+			m.updateChat(SenderSystem, m.checkpointNote())
+		case builtinTool(exec, msg.RunCommand):
+			m.autoRun++
+			return m.executeConfirmedCommand()
+		case m.autoApproved < m.config.MaxAutoApproved:
+			m.autoApproved++
+			m.autoRun++
+			m.state = StateExecuting
+			m.updateChat(SenderSystem, fmt.Sprintf("Auto-approved (%d/%d), executing command `%v`", m.autoApproved, m.config.MaxAutoApproved, m.systemStyle.Render(m.confirmationCmd)))
+			m.execDeadline = time.Now().Add(m.execTimeout())
+			return m, tea.Batch(
+				m.waitForExecution(m.confirmationCmd),
+				m.think(),
+			)
+		default:
+			m.updateChat(SenderSystem, fmt.Sprintf("Auto-approve limit of %d commands reached, confirmation is required.", m.config.MaxAutoApproved))
+		}
+	}
+
+	m.updateChat(SenderSystem, m.confirmationHelp())
 	return m, nil
 }
 
+// confirmationHelp explains how to answer a suggested command.
+func (m Model) confirmationHelp() string {
+	if m.mutation != "" {
+		return fmt.Sprintf("Enter the resource name %s to approve, 'no' to reject, 'edit' to modify the command, 'explain' to break it down, or 'ask' to break out and ask a question.", m.mutation)
+	}
+	if m.protectedContext != "" {
+		return fmt.Sprintf("Context %s is protected. Enter the context name to approve, 'no' to reject, 'edit' to modify the command, 'explain' to break it down, or 'ask' to break out and ask a question.", m.protectedContext)
+	}
+	if keyword := m.highRiskKeyword(); keyword != "" {
+		return fmt.Sprintf("The command is rated high risk. Enter '%s' to approve, 'no' to reject, 'edit' to modify the command, 'explain' to break it down, or 'ask' to break out and ask a question.", keyword)
+	}
+	return "Enter 'yes' to approve, with a timeout such as 'yes 2m' for slow commands, 'no' to reject, 'edit' to modify the command, 'explain' to break it down, or 'ask' to break out and ask a question."
+}
+
 func (m Model) handleExecuterResponse(msg executer.ExecuterResponse) (tea.Model, tea.Cmd) {
 	m.state = StateAsking
-	var systemResponse string
+	m.execDeadline = time.Time{}
 
-	if msg.Error != nil {
-		systemResponse = fmt.Sprintf("Error executing command: %v\n%v\nFOLLOW YOUR GUIDELINES", msg.Error.Error(), msg.Result)
-	} else {
-		systemResponse = fmt.Sprintf("Command output:\n%v", msg.Result)
+	result, redacted := m.config.Redactor.Redact(msg.Stdout)
+	stderr, redactedStderr := m.config.Redactor.Redact(msg.Stderr)
+	redacted += redactedStderr
+
+	m.addCommandOutput(executer.FormatOutput(msg, result, stderr), msg.Duration)
+	if redacted > 0 {
+		m.updateChat(SenderSystem, fmt.Sprintf("%d sensitive value(s) were replaced with %s before sending the output to Klama.", redacted, redact.Placeholder))
+	}
+
+	// let the agent know its command was not run as suggested
+	note := m.preflightNote()
+	if m.editedFromCmd != "" {
+		note += fmt.Sprintf("The user modified your suggested command `%v` and ran `%v` instead.\n", m.editedFromCmd, m.confirmationCmd)
+		m.editedFromCmd = ""
 	}
 
-	if m.showCmdResponse {
-		m.updateChat(m.systemStyle, "System", systemResponse)
+	if m.config.OutputProcessor == nil {
+		return m, tea.Batch(
+			m.waitForAgentResponse(note+executer.FormatOutput(msg, result, stderr)),
+			m.think(),
+		)
 	}
 
 	return m, tea.Batch(
-		m.waitForAgentResponse(systemResponse),
+		m.processOutput(msg, result, stderr, note),
 		m.think(),
 	)
 }
 
+// processOutput shrinks the stdout of the command with the configured output processor
+// before it is sent to the agent. The stderr is sent as is, it is short and explains
+// failures.
+func (m Model) processOutput(msg executer.ExecuterResponse, result, stderr, note string) tea.Cmd {
+	command := m.confirmationCmd
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.requestCtx, m.agentTimeout())
+		defer cancel()
+
+		processed, err := m.config.OutputProcessor.Process(ctx, command, result)
+		if m.requestCtx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			m.log().Warn("Failed to process command output", "command", command, "error", err)
+			processed = result
+		}
+
+		return outputProcessedMsg(note + executer.FormatOutput(msg, processed, stderr))
+	}
+}
+
 func (m Model) waitForAgentResponse(userMessage string) tea.Cmd {
 	return func() tea.Msg {
-		//TODO: get timeout from config
-		ctx, cancel := context.WithTimeout(m.ctx, 90*time.Second)
+		ctx, cancel := context.WithTimeout(m.requestCtx, m.agentTimeout())
 		defer cancel()
 
+		start := time.Now()
 		response, err := m.agent.Iterate(ctx, userMessage)
+		if m.requestCtx.Err() != nil {
+			return nil
+		}
 		if err != nil {
 			return errMsg(err)
 		}
-		return response
+		return timedResponse{response: response, elapsed: time.Since(start)}
+	}
+}
+
+// runPreflight checks the suggested command before it is presented. A failed check is
+// logged and does not block the suggestion.
+func (m Model) runPreflight(checker PreflightChecker, response agent.AgentResponse) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.requestCtx, 10*time.Second)
+		defer cancel()
+
+		warning, err := checker.Preflight(ctx, response.RunCommand)
+		if m.requestCtx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			m.log().Warn("Preflight check failed", "command", response.RunCommand, "error", err)
+		}
+		return preflightMsg{response: response, warning: warning}
+	}
+}
+
+// preflightNote tells the agent, with its next message, about the failed permission
+// check the user saw with its suggested command.
+func (m *Model) preflightNote() string {
+	if m.preflightWarn == "" {
+		return ""
 	}
+	note := fmt.Sprintf("The permission check of your suggested command failed: %v\n", m.preflightWarn)
+	m.preflightWarn = ""
+	return note
+}
+
+// executerFor returns the executer of the tool in multi-tool sessions, and the session
+// executer otherwise.
+// approvalName returns the name the user must type to approve the pending command: the
+// resource a mutation changes, the protected context or the high risk keyword, and empty
+// when 'yes' approves it.
+func (m Model) approvalName() string {
+	if m.mutation != "" {
+		return m.mutation
+	}
+	if m.protectedContext != "" {
+		return m.protectedContext
+	}
+	return m.highRiskKeyword()
+}
+
+// highRiskKeyword returns the keyword that approves the pending command when it is rated
+// high risk and a keyword is configured.
+func (m Model) highRiskKeyword() string {
+	if !m.highRisk {
+		return ""
+	}
+	return strings.TrimSpace(m.config.HighRiskKeyword)
+}
+
+// renderRatings renders the agent's confidence and risk ratings of a command, colored
+// from green for a safe rating to red for a concerning one.
+func (m Model) renderRatings(msg agent.AgentResponse) string {
+	riskStyles := map[string]lipgloss.Style{agent.LevelLow: m.senderStyle, agent.LevelMedium: m.systemStyle, agent.LevelHigh: m.errorStyle}
+	confidenceStyles := map[string]lipgloss.Style{agent.LevelLow: m.errorStyle, agent.LevelMedium: m.systemStyle, agent.LevelHigh: m.senderStyle}
+	render := func(label, level string, style lipgloss.Style) string {
+		return style.Render(label + ": " + level)
+	}
+
+	var ratings []string
+	if msg.Confidence != "" {
+		ratings = append(ratings, render("confidence", msg.Confidence, confidenceStyles[msg.Confidence]))
+	}
+	if msg.RiskLevel != "" {
+		ratings = append(ratings, render("risk", msg.RiskLevel, riskStyles[msg.RiskLevel]))
+	}
+	return strings.Join(ratings, " • ")
+}
+
+// mutationOf returns the resource the command changes, when the executer runs it as a
+// mutation.
+func mutationOf(exec Executer, command string) (executer.MutationTarget, bool) {
+	checker, ok := exec.(MutationChecker)
+	if !ok {
+		return executer.MutationTarget{}, false
+	}
+	return checker.Mutation(command)
+}
+
+// builtinTool reports whether the command runs a built-in read-only tool of the executer.
+func builtinTool(exec Executer, command string) bool {
+	checker, ok := exec.(BuiltinToolChecker)
+	return ok && checker.BuiltinTool(command)
+}
+
+func (m Model) executerFor(tool string) (Executer, error) {
+	selector, ok := m.executer.(ToolSelector)
+	if !ok || tool == "" {
+		return m.executer, nil
+	}
+	return selector.Tool(tool)
 }
 
 func (m Model) waitForExecution(command string) tea.Cmd {
+	exec, err := m.executerFor(m.confirmationTool)
 	return func() tea.Msg {
-		ctx, cancel := context.WithTimeout(m.ctx, 30*time.Second)
+		if err != nil {
+			return executer.ExecuterResponse{Stderr: err.Error(), ExitCode: -1, Error: err}
+		}
+
+		ctx, cancel := context.WithDeadline(m.requestCtx, m.execDeadline)
 		defer cancel()
 
-		return m.executer.Run(ctx, command)
+		response := exec.Run(ctx, command)
+		if m.requestCtx.Err() != nil {
+			return nil
+		}
+		return response
 	}
 }
 
 func (m Model) think() tea.Cmd {
+	if m.config.Plain {
+		return nil
+	}
 	return tea.Tick(time.Millisecond*300, func(t time.Time) tea.Msg {
 		return tickMsg(t)
 	})
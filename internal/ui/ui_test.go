@@ -3,12 +3,18 @@ package ui
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/eliran89c/klama/config"
 	"github.com/eliran89c/klama/internal/agent"
 	"github.com/eliran89c/klama/internal/executer"
+	"github.com/eliran89c/klama/internal/llm"
+	"github.com/eliran89c/klama/internal/policy"
+	"github.com/eliran89c/klama/internal/redact"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -154,6 +160,40 @@ func TestModel_handleKeyMsg(t *testing.T) {
 	mockAgent.AssertExpectations(t)
 }
 
+func TestModel_handlePaste(t *testing.T) {
+	events := "Warning  BackOff  kubelet  Back-off restarting failed container\n" + strings.Repeat("x", 300)
+
+	t.Run("Fits in the limit", func(t *testing.T) {
+		model := InitialModel(Config{})
+
+		newModel, _ := model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(events), Paste: true})
+
+		m := newModel.(Model)
+		assert.Equal(t, events, m.textarea.Value())
+		assert.NoError(t, m.err)
+	})
+
+	t.Run("Truncated paste is reported", func(t *testing.T) {
+		model := InitialModel(Config{CharLimit: 100})
+
+		newModel, _ := model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(events), Paste: true})
+
+		m := newModel.(Model)
+		assert.Equal(t, 100, m.textarea.Length())
+		assert.ErrorContains(t, m.err, fmt.Sprintf("truncated to 100 of %d characters", len(events)))
+	})
+
+	t.Run("No limit", func(t *testing.T) {
+		model := InitialModel(Config{CharLimit: -1})
+
+		newModel, _ := model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(strings.Repeat("y", 20000)), Paste: true})
+
+		m := newModel.(Model)
+		assert.Equal(t, 20000, m.textarea.Length())
+		assert.NoError(t, m.err)
+	})
+}
+
 func TestModel_handleEnterKey(t *testing.T) {
 	mockAgent := new(MockAgent)
 	mockExecuter := new(MockExecuter)
@@ -174,6 +214,67 @@ func TestModel_handleEnterKey(t *testing.T) {
 	assert.Equal(t, StateAsking, InitialModel.(Model).state)
 }
 
+func TestModel_handleEnterKey_Hints(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockAgent.On("Iterate", mock.Anything, "Loki labels of what the user mentioned: namespace=\"shop\".\n"+
+		"The user switched the session from kube context staging to prod.\nwhy is shop down?").Return(agent.AgentResponse{Answer: "Checking"}, nil)
+
+	var hinted string
+	model := InitialModel(Config{Agent: mockAgent, Executer: new(MockExecuter), Hints: func(ctx context.Context, message string) string {
+		hinted = message
+		return "Loki labels of what the user mentioned: namespace=\"shop\".\n"
+	}})
+
+	// the hints are gathered for the user's own words, not the notes sent with them
+	model.contextNote = "The user switched the session from kube context staging to prod.\n"
+	model.textarea.SetValue("why is shop down?")
+	_, cmd := model.handleEnterKey()
+	cmd().(tea.BatchMsg)[0]()
+	assert.Equal(t, "why is shop down?", hinted)
+	mockAgent.AssertExpectations(t)
+}
+
+func TestModel_handleInterrupt(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter})
+
+	// the agent request is canceled and its result is dropped
+	mockAgent.On("Iterate", mock.Anything, "why is my pod failing?").Return(agent.AgentResponse{}, context.Canceled)
+	model.textarea.SetValue("why is my pod failing?")
+	newModel, cmd := model.handleEnterKey()
+	model = newModel.(Model)
+
+	newModel, _ = model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyEsc})
+	model = newModel.(Model)
+	assert.Equal(t, StateTyping, model.state)
+	assert.Equal(t, "Request canceled.", model.Transcript()[1].Content)
+	assert.Nil(t, cmd().(tea.BatchMsg)[0]())
+
+	// a canceled command is reported to the agent with the next message
+	mockExecuter.On("Run", mock.Anything, "kubectl logs -f api").Return(executer.ExecuterResponse{Error: context.Canceled})
+	model.state = StateWaitingForConfirmation
+	model.confirmationCmd = "kubectl logs -f api"
+	newModel, cmd = model.executeConfirmedCommand()
+	model = newModel.(Model)
+	assert.Equal(t, StateExecuting, model.state)
+
+	newModel, _ = model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyEsc})
+	model = newModel.(Model)
+	assert.Equal(t, StateTyping, model.state)
+	assert.Nil(t, cmd().(tea.BatchMsg)[0]())
+
+	mockAgent.On("Iterate", mock.Anything, "The user canceled the command `kubectl logs -f api` before it finished.\nshow the last lines only").Return(agent.AgentResponse{Answer: "OK"}, nil)
+	model.textarea.SetValue("show the last lines only")
+	newModel, cmd = model.handleEnterKey()
+	model = newModel.(Model)
+	assert.Equal(t, agent.AgentResponse{Answer: "OK"}, cmd().(tea.BatchMsg)[0]().(timedResponse).response)
+	assert.Empty(t, model.interruptNote)
+
+	mockAgent.AssertExpectations(t)
+	mockExecuter.AssertExpectations(t)
+}
+
 func TestModel_handleAgentResponse(t *testing.T) {
 	mockExecuter := new(MockExecuter)
 	model := InitialModel(Config{Executer: mockExecuter})
@@ -199,6 +300,39 @@ func TestModel_handleAgentResponse(t *testing.T) {
 	}
 }
 
+func TestModel_handleAgentResponse_Usage(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter})
+
+	// the usage of a rejected command is added to the next message
+	mockExecuter.On("Validate", "rm -rf /").Return(executer.ErrCommandNotAllowed)
+	mockAgent.On("Iterate", mock.Anything, mock.Anything).Return(agent.AgentResponse{}, nil).Maybe()
+	newModel, _ := model.Update(agent.AgentResponse{
+		RunCommand: "rm -rf /",
+		Usage:      llm.Usage{PromptTokens: 1000, CompletionTokens: 50, TotalTokens: 1050},
+		Cost:       0.001,
+	})
+	model = newModel.(Model)
+	assert.Empty(t, model.Transcript())
+
+	newModel, _ = model.Update(agent.AgentResponse{
+		Answer: "The pod is out of memory.",
+		Usage:  llm.Usage{PromptTokens: 1200, CompletionTokens: 250, TotalTokens: 1450},
+		Cost:   0.003,
+	})
+	model = newModel.(Model)
+
+	transcript := model.Transcript()
+	assert.Equal(t, "2.2k in / 300 out • 0.0040$", transcript[0].Usage)
+	assert.Contains(t, model.viewport.View(), "2.2k in / 300 out")
+	assert.Equal(t, llm.Usage{}, model.pendingUsage)
+
+	// responses without usage are not annotated
+	newModel, _ = model.Update(agent.AgentResponse{Answer: "Anything else?"})
+	assert.Empty(t, newModel.(Model).Transcript()[1].Usage)
+}
+
 func TestModel_handleExecuterResponse(t *testing.T) {
 	mockAgent := new(MockAgent)
 	model := InitialModel(Config{Agent: mockAgent})
@@ -211,7 +345,7 @@ func TestModel_handleExecuterResponse(t *testing.T) {
 		response executer.ExecuterResponse
 		expected modelState
 	}{
-		{"Successful execution", executer.ExecuterResponse{Result: "Test result"}, StateAsking},
+		{"Successful execution", executer.ExecuterResponse{Stdout: "Test result"}, StateAsking},
 		{"Failed execution", executer.ExecuterResponse{Error: assert.AnError}, StateAsking},
 	}
 
@@ -223,6 +357,14 @@ func TestModel_handleExecuterResponse(t *testing.T) {
 	}
 }
 
+func TestModel_handleExecuterResponse_Cached(t *testing.T) {
+	model := InitialModel(Config{Agent: new(MockAgent)})
+
+	newModel, _ := model.handleExecuterResponse(executer.ExecuterResponse{Stdout: "web Running", Cached: true, CachedAt: time.Now().Add(-2 * time.Minute)})
+	model = newModel.(Model)
+	assert.Contains(t, model.messages[len(model.messages)-1].Content, "Command output (cached 2m0s ago):\nweb Running")
+}
+
 func TestModel_handleConfirmation(t *testing.T) {
 	mockAgent := new(MockAgent)
 	mockExecuter := new(MockExecuter)
@@ -242,6 +384,7 @@ func TestModel_handleConfirmation(t *testing.T) {
 		{"Yes", "yes", StateExecuting},
 		{"No", "no", StateAsking},
 		{"Ask", "ask", StateTyping},
+		{"Edit", "edit", StateEditingCommand},
 		{"Invalid", "invalid", StateWaitingForConfirmation},
 	}
 
@@ -249,7 +392,7 @@ func TestModel_handleConfirmation(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			model.textarea.SetValue(tt.input)
 			mockAgent.On("Iterate", mock.Anything, mock.Anything).Return(agent.AgentResponse{Answer: "Test response"}, nil).Maybe()
-			mockExecuter.On("Run", mock.Anything, mock.Anything).Return(executer.ExecuterResponse{Result: "Test result"}).Maybe()
+			mockExecuter.On("Run", mock.Anything, mock.Anything).Return(executer.ExecuterResponse{Stdout: "Test result"}).Maybe()
 
 			newModel, _ := model.handleConfirmation()
 			assert.Equal(t, tt.expected, newModel.(Model).state)
@@ -262,7 +405,7 @@ func TestModel_handleConfirmation(t *testing.T) {
 
 func TestModel_updateChat(t *testing.T) {
 	model := InitialModel(Config{})
-	model.updateChat(model.senderStyle, "Test", "Test message")
+	model.updateChat("Test", "Test message")
 
 	assert.Contains(t, model.viewport.View(), "Test: Test message")
 }
@@ -278,6 +421,9 @@ func TestModel_headerView(t *testing.T) {
 
 	assert.Contains(t, header, "Klama")
 
+	model.agentName = "Kubernetes"
+	assert.Contains(t, model.headerView(), "Klama - Kubernetes")
+
 	mockAgent.AssertExpectations(t)
 }
 
@@ -292,6 +438,17 @@ func TestModel_footerView(t *testing.T) {
 
 	assert.Contains(t, footer, "Ctrl+C: to exit")
 	assert.Contains(t, footer, "Test usage")
+	assert.Contains(t, footer, fmt.Sprintf("%d characters left", defaultCharLimit))
+
+	model.textarea.SetValue("kubectl get pods")
+	assert.Contains(t, model.footerView(), fmt.Sprintf("%d characters left", defaultCharLimit-16))
+
+	// messages typed while Klama is busy are queued, and count towards the limit too
+	model.state = StateAsking
+	footer = model.footerView()
+	assert.Contains(t, footer, "Klama is typing")
+	assert.Contains(t, footer, "kubectl get pods")
+	assert.Contains(t, footer, fmt.Sprintf("%d characters left", defaultCharLimit-16))
 
 	mockAgent.AssertExpectations(t)
 }
@@ -318,6 +475,20 @@ func TestModel_renderInputArea(t *testing.T) {
 	}
 }
 
+func TestModel_RetryMsg(t *testing.T) {
+	model := InitialModel(Config{})
+	model.state = StateAsking
+
+	updated, cmd := model.Update(RetryMsg{Attempt: 2, MaxAttempts: 5, Err: assert.AnError})
+	assert.Nil(t, cmd)
+	model = updated.(Model)
+	assert.Contains(t, model.renderInputArea(), "retrying (2/5)...")
+
+	updated, _ = model.Update(errMsg(assert.AnError))
+	model = updated.(Model)
+	assert.Empty(t, model.retryStatus)
+}
+
 func TestModel_renderErrorMessage(t *testing.T) {
 	model := InitialModel(Config{})
 	model.err = assert.AnError
@@ -334,12 +505,680 @@ func TestModel_renderHelpText(t *testing.T) {
 	assert.Contains(t, helpText, "Ctrl+R: to restart")
 }
 
-func TestModel_renderPriceText(t *testing.T) {
+func TestModel_renderStatusBar(t *testing.T) {
 	mockAgent := new(MockAgent)
-	model := InitialModel(Config{Agent: mockAgent})
+	model := InitialModel(Config{
+		Agent:     mockAgent,
+		ModelName: "gpt4o",
+		Target:    Target{Context: "staging", Namespace: "shop"},
+	})
+	model.width = 200
 
 	mockAgent.On("LogUsage").Return("Test usage")
 
-	priceText := model.renderPriceText()
-	assert.Contains(t, priceText, "Test usage")
+	statusBar := model.renderStatusBar()
+	assert.Contains(t, statusBar, "idle │ gpt4o │ staging/shop │ Test usage")
+
+	model.state = StateAsking
+	assert.Contains(t, model.renderStatusBar(), "thinking │")
+	model.state = StateExecuting
+	assert.Contains(t, model.renderStatusBar(), "executing │")
+}
+
+type contextAgent struct {
+	*MockAgent
+	used, window int
+}
+
+func (a *contextAgent) ContextUsage() (int, int) {
+	return a.used, a.window
+}
+
+func TestModel_renderStatusBar_ContextUsage(t *testing.T) {
+	mockAgent := &contextAgent{MockAgent: new(MockAgent), used: 12000, window: 128000}
+	mockAgent.On("LogUsage").Return("Test usage")
+	model := InitialModel(Config{Agent: mockAgent})
+	model.width = 200
+
+	assert.Contains(t, model.renderStatusBar(), "context used: 12k/128k │ Test usage")
+
+	// the usage is refreshed with every response
+	mockAgent.used = 110500
+	model, _ = updateModel(model, agent.AgentResponse{Answer: "done"})
+	assert.Contains(t, model.renderStatusBar(), "context used: 110.5k/128k")
+}
+
+type budgetAgent struct {
+	*MockAgent
+	cost       float64
+	tokensLeft int
+	costLeft   float64
+}
+
+func (a *budgetAgent) Cost() float64 {
+	return a.cost
+}
+
+func (a *budgetAgent) BudgetLeft() (int, float64) {
+	return a.tokensLeft, a.costLeft
+}
+
+func TestModel_renderStatusBar_Budget(t *testing.T) {
+	mockAgent := &budgetAgent{MockAgent: new(MockAgent), cost: 0.0123, tokensLeft: -1, costLeft: -1}
+	model := InitialModel(Config{Agent: mockAgent})
+	model.width = 200
+
+	statusBar := model.renderStatusBar()
+	assert.Contains(t, statusBar, "idle │ 0.0123$")
+	assert.NotContains(t, statusBar, "budget left")
+
+	mockAgent.tokensLeft, mockAgent.costLeft = 48500, 0.9877
+	assert.Contains(t, model.renderStatusBar(), "0.0123$ │ budget left: 0.9877$, 48.5k tokens")
+	mockAgent.AssertNotCalled(t, "LogUsage")
+}
+
+func TestInitialModel_Transcript(t *testing.T) {
+	transcript := []ChatMessage{
+		{Sender: SenderUser, Content: "why is my pod failing?"},
+		{Sender: SenderKlama, Content: "The image tag does not exist."},
+	}
+
+	model := InitialModel(Config{Transcript: transcript})
+	assert.True(t, model.ready)
+	assert.Equal(t, transcript, model.Transcript())
+
+	model.updateViewportContent()
+	assert.Contains(t, model.viewport.View(), "The image tag does not exist.")
+}
+
+func TestModel_Outcome(t *testing.T) {
+	model := InitialModel(Config{})
+	assert.Equal(t, OutcomeInProgress, model.Outcome())
+
+	model.messages = []ChatMessage{{Sender: SenderUser, Content: "question"}, {Sender: SenderKlama, Content: "answer"}}
+	assert.Equal(t, OutcomeAnswered, model.Outcome())
+
+	model.state = StateWaitingForConfirmation
+	assert.Equal(t, OutcomeAwaitingApproval, model.Outcome())
+
+	model.err = assert.AnError
+	assert.Equal(t, OutcomeError, model.Outcome())
+}
+
+func TestModel_cancelEdit(t *testing.T) {
+	model := InitialModel(Config{Agent: new(MockAgent), Executer: new(MockExecuter)})
+	model.confirmationCmd = "kubectl get pods -n default"
+	model.state = StateWaitingForConfirmation
+
+	model.textarea.SetValue("edit")
+	newModel, _ := model.handleConfirmation()
+	model = newModel.(Model)
+	model.textarea.SetValue("kubectl get pods -n pay")
+
+	// Esc drops the edit and asks again about the suggested command, without quitting
+	newModel, cmd := model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyEsc})
+	model = newModel.(Model)
+	assert.Nil(t, cmd)
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.Equal(t, "kubectl get pods -n default", model.confirmationCmd)
+	assert.Empty(t, model.editedFromCmd)
+	assert.Empty(t, model.textarea.Value())
+	assert.Contains(t, model.messages[len(model.messages)-1].Content, "Edit canceled")
+	assert.Contains(t, model.messages[len(model.messages)-1].Content, "Enter 'yes' to approve")
+}
+
+func TestModel_handleEditedCommand(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+
+	model := InitialModel(Config{
+		Agent:    mockAgent,
+		Executer: mockExecuter,
+	})
+	model.confirmationCmd = "kubectl get pods -n default"
+	model.state = StateWaitingForConfirmation
+
+	model.textarea.SetValue("edit")
+	newModel, _ := model.handleConfirmation()
+	model = newModel.(Model)
+	assert.Equal(t, StateEditingCommand, model.state)
+	assert.Equal(t, "kubectl get pods -n default", model.textarea.Value())
+
+	mockExecuter.On("Validate", "kubectl delete pods --all").Return(fmt.Errorf("not allowed"))
+	model.textarea.SetValue("kubectl delete pods --all")
+	newModel, _ = model.handleEditedCommand()
+	assert.Equal(t, StateEditingCommand, newModel.(Model).state)
+	assert.Error(t, newModel.(Model).err)
+
+	mockExecuter.On("Validate", "kubectl get pods -n payments").Return(nil)
+	model.textarea.SetValue("kubectl get pods -n payments")
+	newModel, _ = model.handleEditedCommand()
+	model = newModel.(Model)
+	assert.Equal(t, StateExecuting, model.state)
+	assert.Equal(t, "kubectl get pods -n payments", model.confirmationCmd)
+	assert.Equal(t, "kubectl get pods -n default", model.editedFromCmd)
+
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.Contains(prompt, "modified your suggested command `kubectl get pods -n default`")
+	})).Return(agent.AgentResponse{Answer: "Test response"}, nil)
+
+	newModel, cmd := model.handleExecuterResponse(executer.ExecuterResponse{Stdout: "Test result"})
+	assert.Empty(t, newModel.(Model).editedFromCmd)
+
+	// the first batched command asks the agent
+	cmd().(tea.BatchMsg)[0]()
+
+	mockAgent.AssertExpectations(t)
+}
+
+type MockPreflightExecuter struct {
+	MockExecuter
+}
+
+func (m *MockPreflightExecuter) Preflight(ctx context.Context, command string) (string, error) {
+	args := m.Called(ctx, command)
+	return args.String(0), args.Error(1)
+}
+
+func TestModel_handleAgentResponse_Preflight(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockPreflightExecuter)
+	mockExecuter.On("Validate", "kubectl get secrets").Return(nil)
+	mockExecuter.On("Preflight", mock.Anything, "kubectl get secrets").Return("RBAC will deny `list secrets` in the current namespace", nil)
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.Contains(prompt, "permission check failed: RBAC will deny")
+	})).Return(agent.AgentResponse{Answer: "ok"}, nil)
+
+	model := InitialModel(Config{
+		Agent:           mockAgent,
+		Executer:        mockExecuter,
+		AutoApprove:     true,
+		MaxAutoApproved: 5,
+	})
+
+	newModel, cmd := model.handleAgentResponse(agent.AgentResponse{RunCommand: "kubectl get secrets", Reason: "Test reason"})
+	model = newModel.(Model)
+	assert.Equal(t, StateAsking, model.state)
+
+	msg := cmd().(tea.BatchMsg)[0]()
+	newModel, _ = model.Update(msg)
+	model = newModel.(Model)
+
+	// a failed permission check is never auto-approved
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.Equal(t, 0, model.autoApproved)
+	assert.Contains(t, model.messages[len(model.messages)-3].Content, "RBAC will deny")
+
+	model.textarea.SetValue("no")
+	_, cmd = model.handleConfirmation()
+	cmd().(tea.BatchMsg)[0]()
+
+	mockExecuter.AssertExpectations(t)
+	mockAgent.AssertExpectations(t)
+}
+
+func TestModel_handleAgentResponse_PreflightApproved(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockPreflightExecuter)
+	mockAgent.On("LogUsage").Return("Test usage").Maybe()
+	mockExecuter.On("Validate", "kubectl get secrets").Return(nil)
+	mockExecuter.On("Preflight", mock.Anything, "kubectl get secrets").Return("RBAC will deny `list secrets` in the current namespace", nil)
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.HasPrefix(prompt, "The permission check of your suggested command failed: RBAC will deny") &&
+			strings.Contains(prompt, "Forbidden")
+	})).Return(agent.AgentResponse{Answer: "ok"}, nil).Once()
+
+	model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter})
+	newModel, cmd := model.handleAgentResponse(agent.AgentResponse{RunCommand: "kubectl get secrets", Reason: "Test reason"})
+	model = newModel.(Model)
+	model, _ = updateModel(model, cmd().(tea.BatchMsg)[0]())
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+
+	// the agent learns about the warning with the output of the approved command
+	model.textarea.SetValue("yes")
+	newModel, _ = model.handleConfirmation()
+	model = newModel.(Model)
+	newModel, cmd = model.handleExecuterResponse(executer.ExecuterResponse{Stderr: "Error from server (Forbidden)"})
+	model = newModel.(Model)
+	cmd().(tea.BatchMsg)[0]()
+	assert.Empty(t, model.preflightWarn)
+
+	mockExecuter.AssertExpectations(t)
+	mockAgent.AssertExpectations(t)
+}
+
+func TestModel_ProtectedTarget(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockAgent.On("LogUsage").Return("Test usage").Maybe()
+	mockExecuter.On("Validate", mock.Anything).Return(nil)
+	mockExecuter.On("Run", mock.Anything, "kubectl get pods -n web").Return(executer.ExecuterResponse{Stdout: "ok"})
+
+	model := InitialModel(Config{
+		Agent:           mockAgent,
+		Executer:        mockExecuter,
+		AutoApprove:     true,
+		MaxAutoApproved: 5,
+		Target:          Target{Context: "Prod-EU", Namespace: "apps", Protected: true},
+	})
+
+	header := model.headerView()
+	assert.Contains(t, header, "[ctx: Prod-EU | ns: apps] PROTECTED")
+	assert.NotContains(t, header, "autopilot")
+
+	// protected contexts are never auto-approved
+	newModel, _ := model.handleAgentResponse(agent.AgentResponse{RunCommand: "kubectl get pods", Reason: "Test reason"})
+	model = newModel.(Model)
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.Contains(t, model.messages[len(model.messages)-1].Content, "Enter the context name to approve")
+
+	// 'yes' is not enough
+	model.textarea.SetValue("yes")
+	newModel, cmd := model.handleConfirmation()
+	model = newModel.(Model)
+	assert.Nil(t, cmd)
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.EqualError(t, model.err, "context Prod-EU is protected, enter its name to approve the command")
+
+	// an edited command must be approved again
+	model.textarea.SetValue("edit")
+	newModel, _ = model.handleConfirmation()
+	model = newModel.(Model)
+	model.textarea.SetValue("kubectl get pods -n web")
+	newModel, cmd = model.handleEditedCommand()
+	model = newModel.(Model)
+	assert.Nil(t, cmd)
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.Equal(t, "kubectl get pods -n web", model.confirmationCmd)
+
+	model.textarea.SetValue("prod-eu")
+	newModel, cmd = model.handleConfirmation()
+	model = newModel.(Model)
+	assert.Equal(t, StateExecuting, model.state)
+	cmd().(tea.BatchMsg)[0]()
+
+	mockExecuter.AssertExpectations(t)
+}
+
+func TestModel_ProtectedFlags(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockAgent.On("LogUsage").Return("Test usage").Maybe()
+	mockExecuter.On("Validate", mock.Anything).Return(nil)
+	mockExecuter.On("Run", mock.Anything, "kubectl get pods --context prod").Return(executer.ExecuterResponse{Stdout: "ok"})
+
+	model := InitialModel(Config{
+		Agent:           mockAgent,
+		Executer:        mockExecuter,
+		AutoApprove:     true,
+		MaxAutoApproved: 5,
+		Target: Target{Context: "staging", ProtectedFlags: func(command string) string {
+			if strings.Contains(command, "--context prod") {
+				return "prod"
+			}
+			return ""
+		}},
+	})
+
+	// a command naming a protected context is not auto-approved
+	newModel, _ := model.handleAgentResponse(agent.AgentResponse{RunCommand: "kubectl get pods --context prod", Reason: "Test reason"})
+	model = newModel.(Model)
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.Contains(t, model.messages[len(model.messages)-1].Content, "Context prod is protected")
+
+	model.textarea.SetValue("staging")
+	newModel, _ = model.handleConfirmation()
+	model = newModel.(Model)
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+
+	model.textarea.SetValue("prod")
+	newModel, cmd := model.handleConfirmation()
+	model = newModel.(Model)
+	assert.Equal(t, StateExecuting, model.state)
+	cmd().(tea.BatchMsg)[0]()
+	mockExecuter.AssertExpectations(t)
+}
+
+func TestModel_ProtectedUnknownTarget(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockAgent.On("LogUsage").Return("Test usage").Maybe()
+	mockExecuter.On("Validate", mock.Anything).Return(nil)
+
+	model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter, Target: Target{Protected: true}})
+	assert.Contains(t, model.headerView(), "[ctx: unknown] PROTECTED")
+
+	newModel, _ := model.handleAgentResponse(agent.AgentResponse{RunCommand: "kubectl get pods", Reason: "Test reason"})
+	model = newModel.(Model)
+	model.textarea.SetValue("yes")
+	newModel, _ = model.handleConfirmation()
+	model = newModel.(Model)
+	assert.EqualError(t, model.err, "context unknown is protected, enter its name to approve the command")
+}
+
+// mockMutationExecuter treats commands starting with "kubectl scale" as mutations.
+type mockMutationExecuter struct {
+	MockExecuter
+}
+
+func (m *mockMutationExecuter) Mutation(command string) (executer.MutationTarget, bool) {
+	if !strings.HasPrefix(command, "kubectl scale") {
+		return executer.MutationTarget{}, false
+	}
+	return executer.MutationTarget{Kind: "deployment", Name: "web"}, true
+}
+
+func TestModel_Mutation(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(mockMutationExecuter)
+	mockAgent.On("LogUsage").Return("Test usage").Maybe()
+	mockExecuter.On("Validate", mock.Anything).Return(nil)
+	mockExecuter.On("Run", mock.Anything, "kubectl scale deployment/web --replicas 2").Return(executer.ExecuterResponse{Stdout: "scaled"})
+
+	model := InitialModel(Config{
+		Agent:           mockAgent,
+		Executer:        mockExecuter,
+		AutoApprove:     true,
+		MaxAutoApproved: 5,
+	})
+
+	// read-only commands are still auto-approved
+	newModel, _ := model.handleAgentResponse(agent.AgentResponse{RunCommand: "kubectl get deployment web", Reason: "Test reason"})
+	model = newModel.(Model)
+	assert.Equal(t, StateExecuting, model.state)
+
+	// mutations are never auto-approved
+	newModel, _ = model.handleAgentResponse(agent.AgentResponse{RunCommand: "kubectl scale deployment/web --replicas 3", Reason: "Test reason"})
+	model = newModel.(Model)
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.Contains(t, model.messages[len(model.messages)-1].Content, "Enter the resource name web to approve")
+
+	model.textarea.SetValue("yes")
+	newModel, cmd := model.handleConfirmation()
+	model = newModel.(Model)
+	assert.Nil(t, cmd)
+	assert.EqualError(t, model.err, "the command changes web, enter its name to approve the command")
+
+	// an edited mutation must be approved again
+	model.textarea.SetValue("edit")
+	newModel, _ = model.handleConfirmation()
+	model = newModel.(Model)
+	model.textarea.SetValue("kubectl scale deployment/web --replicas 2")
+	newModel, cmd = model.handleEditedCommand()
+	model = newModel.(Model)
+	assert.Nil(t, cmd)
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+
+	model.textarea.SetValue("web")
+	newModel, cmd = model.handleConfirmation()
+	model = newModel.(Model)
+	assert.Equal(t, StateExecuting, model.state)
+	cmd().(tea.BatchMsg)[0]()
+
+	mockExecuter.AssertExpectations(t)
+}
+
+func TestModel_HighRiskKeyword(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockAgent.On("LogUsage").Return("Test usage").Maybe()
+	mockExecuter.On("Validate", mock.Anything).Return(nil)
+
+	model := InitialModel(Config{
+		Agent:           mockAgent,
+		Executer:        mockExecuter,
+		AutoApprove:     true,
+		MaxAutoApproved: 5,
+		HighRiskKeyword: "Proceed",
+	})
+
+	// ratings are shown with the command, and low risk commands are still auto-approved
+	newModel, _ := model.handleAgentResponse(agent.AgentResponse{RunCommand: "kubectl get pods", Reason: "list pods", Confidence: agent.LevelHigh, RiskLevel: agent.LevelLow})
+	model = newModel.(Model)
+	assert.Equal(t, StateExecuting, model.state)
+	assert.Contains(t, model.messages[0].Content, "confidence: high")
+	assert.Contains(t, model.messages[0].Content, "risk: low")
+
+	// high risk commands need the keyword
+	newModel, _ = model.handleAgentResponse(agent.AgentResponse{RunCommand: "kubectl drain node-1", Reason: "drain", RiskLevel: agent.LevelHigh})
+	model = newModel.(Model)
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.Contains(t, model.messages[len(model.messages)-1].Content, "Enter 'Proceed' to approve")
+
+	model.textarea.SetValue("yes")
+	newModel, _ = model.handleConfirmation()
+	model = newModel.(Model)
+	assert.EqualError(t, model.err, "the command is rated high risk, enter 'Proceed' to approve the command")
+
+	model.textarea.SetValue("proceed")
+	newModel, _ = model.handleConfirmation()
+	model = newModel.(Model)
+	assert.Equal(t, StateExecuting, model.state)
+}
+
+func TestModel_handleAgentResponse_AutoApprove(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockAgent.On("LogUsage").Return("Test usage").Maybe()
+	mockExecuter.On("Validate", "allowed").Return(nil)
+
+	model := InitialModel(Config{
+		Agent:           mockAgent,
+		Executer:        mockExecuter,
+		AutoApprove:     true,
+		MaxAutoApproved: 1,
+	})
+	assert.Contains(t, model.headerView(), "autopilot 0/1")
+
+	response := agent.AgentResponse{RunCommand: "allowed", Reason: "Test reason"}
+
+	newModel, _ := model.handleAgentResponse(response)
+	model = newModel.(Model)
+	assert.Equal(t, StateExecuting, model.state)
+	assert.Equal(t, 1, model.autoApproved)
+	assert.Contains(t, model.headerView(), "autopilot 1/1")
+
+	// the limit is reached, confirmation is required again
+	newModel, _ = model.handleAgentResponse(response)
+	model = newModel.(Model)
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.Equal(t, 1, model.autoApproved)
+}
+
+// MockBuiltinToolExecuter runs builtinCmd as a built-in read-only tool.
+type MockBuiltinToolExecuter struct {
+	MockExecuter
+	builtinCmd string
+}
+
+func (m *MockBuiltinToolExecuter) BuiltinTool(command string) bool {
+	return command == m.builtinCmd
+}
+
+func TestModel_handleAgentResponse_BuiltinTool(t *testing.T) {
+	const command = "events -n shop pod/web-0"
+	mockAgent := new(MockAgent)
+	mockExecuter := &MockBuiltinToolExecuter{builtinCmd: command}
+	mockAgent.On("LogUsage").Return("Test usage").Maybe()
+	mockExecuter.On("Validate", command).Return(nil)
+
+	// without autopilot, built-in tools are confirmed like any command
+	model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter})
+	newModel, _ := model.handleAgentResponse(agent.AgentResponse{RunCommand: command})
+	assert.Equal(t, StateWaitingForConfirmation, newModel.(Model).state)
+
+	// in autopilot they run without counting toward the limit
+	model = InitialModel(Config{
+		Agent:           mockAgent,
+		Executer:        mockExecuter,
+		AutoApprove:     true,
+		MaxAutoApproved: 1,
+	})
+	for range 2 {
+		newModel, _ = model.handleAgentResponse(agent.AgentResponse{RunCommand: command})
+		model = newModel.(Model)
+		assert.Equal(t, StateExecuting, model.state)
+		assert.Equal(t, 0, model.autoApproved)
+		model.state = StateTyping
+	}
+}
+
+func TestModel_handleExecuterResponse_Redaction(t *testing.T) {
+	mockAgent := new(MockAgent)
+	redactor, err := redact.New(nil, false)
+	assert.NoError(t, err)
+
+	model := InitialModel(Config{Agent: mockAgent, Redactor: redactor})
+
+	mockAgent.On("Iterate", mock.Anything, "Command output:\nDB_PASSWORD=[REDACTED]").Return(agent.AgentResponse{Answer: "Test response"}, nil)
+
+	newModel, cmd := model.handleExecuterResponse(executer.ExecuterResponse{Stdout: "DB_PASSWORD=hunter2"})
+	model = newModel.(Model)
+
+	transcript := model.Transcript()
+	assert.Equal(t, "Command output:\nDB_PASSWORD=[REDACTED]", transcript[0].Content)
+	assert.Contains(t, transcript[1].Content, "1 sensitive value(s) were replaced")
+
+	cmd().(tea.BatchMsg)[0]()
+	mockAgent.AssertExpectations(t)
+}
+
+func TestModel_handleExecuterResponse_OutputProcessor(t *testing.T) {
+	mockAgent := new(MockAgent)
+	model := InitialModel(Config{
+		Agent:           mockAgent,
+		OutputProcessor: executer.Truncator{MaxLines: 2},
+	})
+
+	newModel, cmd := model.handleExecuterResponse(executer.ExecuterResponse{Stdout: "line 1\nline 2\nline 3"})
+	model = newModel.(Model)
+
+	// the full output is shown in the chat
+	assert.Equal(t, "Command output:\nline 1\nline 2\nline 3", model.Transcript()[0].Content)
+
+	// the processed output is sent to the agent
+	msg := cmd().(tea.BatchMsg)[0]()
+	assert.Equal(t, outputProcessedMsg("Command output:\nline 1\n... 1 lines omitted ...\nline 3"), msg)
+
+	mockAgent.On("Iterate", mock.Anything, string(msg.(outputProcessedMsg))).Return(agent.AgentResponse{Answer: "Test response"}, nil)
+	_, cmd = model.Update(msg)
+	cmd().(tea.BatchMsg)[0]()
+	mockAgent.AssertExpectations(t)
+}
+
+func TestModel_handleAgentResponse_Policy(t *testing.T) {
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockAgent.On("LogUsage").Return("Test usage").Maybe()
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(input string) bool {
+		return strings.HasPrefix(input, "The suggested command was denied by policy rule \"pci\": no PCI logs")
+	})).Return(agent.AgentResponse{Answer: "OK"}, nil)
+	mockExecuter.On("Validate", mock.Anything).Return(nil)
+
+	engine, err := policy.New([]config.PolicyRule{
+		{Name: "pci", Expression: `subcommand == "logs" && ns.startsWith("pci-")`, Verdict: config.VerdictDeny, Message: "no PCI logs"},
+		{Name: "reads", Expression: `subcommand == "get"`, Verdict: config.VerdictAllow},
+		{Name: "secrets", Expression: `"secrets" in args`, Verdict: config.VerdictRequireConfirmation},
+	})
+	assert.NoError(t, err)
+
+	model := InitialModel(Config{
+		Agent:           mockAgent,
+		Executer:        mockExecuter,
+		AutoApprove:     true,
+		MaxAutoApproved: 5,
+		Policy:          engine,
+	})
+
+	// denied commands go back to the agent
+	newModel, cmd := model.handleAgentResponse(agent.AgentResponse{RunCommand: "kubectl logs web-0 -n pci-payments", Reason: "Test reason"})
+	model = newModel.(Model)
+	assert.Equal(t, StateAsking, model.state)
+	assert.Contains(t, model.messages[len(model.messages)-1].Content, `denied by policy rule "pci": no PCI logs`)
+	cmd().(tea.BatchMsg)[0]()
+
+	// allowed commands run without confirmation, even without auto-approve
+	model.config.AutoApprove = false
+	newModel, _ = model.handleAgentResponse(agent.AgentResponse{RunCommand: "kubectl get pods", Reason: "Test reason"})
+	model = newModel.(Model)
+	assert.Equal(t, StateExecuting, model.state)
+	assert.Contains(t, model.messages[len(model.messages)-2].Content, `allowed by policy rule "reads"`)
+
+	// confirmation is required even with auto-approve
+	model.config.AutoApprove = true
+	newModel, _ = model.handleAgentResponse(agent.AgentResponse{RunCommand: "kubectl describe secrets", Reason: "Test reason"})
+	model = newModel.(Model)
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.Equal(t, 0, model.autoApproved)
+
+	// edited commands are checked too
+	model.state = StateEditingCommand
+	model.textarea.SetValue("kubectl logs web-0 -n pci-cards")
+	newModel, cmd = model.handleEditedCommand()
+	model = newModel.(Model)
+	assert.Nil(t, cmd)
+	assert.EqualError(t, model.err, `the edited command was denied by policy rule "pci": no PCI logs`)
+
+	mockAgent.AssertExpectations(t)
+}
+
+func TestInitialModel_Theme(t *testing.T) {
+	model := InitialModel(Config{Theme: Theme{Klama: "#ff8800"}})
+
+	// unset colors fall back to the dark theme
+	assert.Equal(t, lipgloss.Color("#ff8800"), model.klamaStyle.GetForeground())
+	assert.Equal(t, lipgloss.Color(Themes[ThemeDark].Sender), model.senderStyle.GetForeground())
+
+	light := Themes[ThemeLight].Override(Theme{Error: "9"})
+	assert.Equal(t, "9", light.Error)
+	assert.Equal(t, Themes[ThemeLight].Sender, light.Sender)
+
+	// adaptive themes follow the background, overrides apply to both variants
+	auto := Themes[ThemeAuto].Override(Theme{Error: "9"})
+	model = InitialModel(Config{Theme: auto})
+	assert.Equal(t, lipgloss.AdaptiveColor{Dark: Themes[ThemeDark].Sender, Light: Themes[ThemeLight].Sender}, model.senderStyle.GetForeground())
+	assert.Equal(t, lipgloss.AdaptiveColor{Dark: "9", Light: "9"}, model.errorStyle.GetForeground())
+	assert.Equal(t, Themes[ThemeLight].Syntax, Themes[ThemeAuto].Light.Syntax)
+	assert.Nil(t, Themes[ThemeLight].Light)
+}
+
+func TestModel_handleAgentResponse_Tools(t *testing.T) {
+	mockAgent := new(MockAgent)
+	toolbox := executer.NewToolbox(
+		executer.Tool{Name: "echo", Executer: executer.NewTerminalExecuter(executer.TerminalExecuterType{AllowedCommands: []string{"echo"}})},
+		executer.Tool{Name: "cat", Executer: executer.NewTerminalExecuter(executer.TerminalExecuterType{AllowedCommands: []string{"cat"}})},
+	)
+	model := InitialModel(Config{Agent: mockAgent, Executer: toolbox})
+
+	// an unknown tool is returned to the agent
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.Contains(prompt, `unknown tool "kubectl"`)
+	})).Return(agent.AgentResponse{Answer: "ok"}, nil)
+	newModel, cmd := model.handleAgentResponse(agent.AgentResponse{RunCommand: "echo hello", Tool: "kubectl"})
+	assert.Equal(t, StateAsking, newModel.(Model).state)
+	cmd().(tea.BatchMsg)[0]()
+
+	// the command must be allowed by the tool the agent named
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.Contains(prompt, "The suggested command is invalid")
+	})).Return(agent.AgentResponse{Answer: "ok"}, nil)
+	newModel, cmd = model.handleAgentResponse(agent.AgentResponse{RunCommand: "echo hello", Tool: "cat"})
+	assert.Equal(t, StateAsking, newModel.(Model).state)
+	cmd().(tea.BatchMsg)[0]()
+
+	newModel, cmd = model.handleAgentResponse(agent.AgentResponse{RunCommand: "echo hello", Reason: "greet", Tool: "echo"})
+	model = newModel.(Model)
+	newModel, _ = model.Update(cmd().(tea.BatchMsg)[0]())
+	model = newModel.(Model)
+	assert.Equal(t, StateWaitingForConfirmation, model.state)
+	assert.Equal(t, "echo", model.confirmationTool)
+	assert.Contains(t, model.messages[len(model.messages)-2].Content, "with the echo tool")
+
+	model.textarea.SetValue("yes")
+	_, cmd = model.handleConfirmation()
+	response := cmd().(tea.BatchMsg)[0]().(executer.ExecuterResponse)
+	assert.NoError(t, response.Error)
+	assert.Equal(t, "hello", response.Stdout)
+
+	mockAgent.AssertExpectations(t)
 }
@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/eliran89c/klama/internal/agent"
+)
+
+const (
+	// reviewMessages is how many of the latest chat messages the validator reads.
+	reviewMessages = 6
+
+	// reviewMessageLength bounds each message the validator reads, command outputs
+	// can be long.
+	reviewMessageLength = 1000
+)
+
+// Validator reviews a suggested command with a second model before it is presented.
+type Validator interface {
+	Validate(ctx context.Context, review agent.CommandReview) (agent.Verdict, error)
+}
+
+// validationMsg carries a suggested command together with the validator's verdict.
+type validationMsg struct {
+	response agent.AgentResponse
+	verdict  agent.Verdict
+	err      error
+}
+
+// validate asks the validator to review the suggested command against the policy
+// decision and the latest messages of the conversation.
+func (m Model) validate(response agent.AgentResponse) tea.Cmd {
+	review := agent.CommandReview{
+		Command:      response.RunCommand,
+		Reason:       response.Reason,
+		Conversation: m.reviewConversation(),
+	}
+	if m.policyDecision.Matched() {
+		review.Policy = m.policyDecision.String()
+	}
+
+	validator := m.config.Validator
+	ctx := m.requestCtx
+	timeout := m.agentTimeout()
+	return func() tea.Msg {
+		reviewCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		verdict, err := validator.Validate(reviewCtx, review)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return validationMsg{response: response, verdict: verdict, err: err}
+	}
+}
+
+// handleValidation keeps the verdict to annotate the suggested command with, and goes on
+// presenting it. A failed review is shown and does not block the suggestion.
+func (m Model) handleValidation(msg validationMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.log().Warn("Failed to validate the command", "command", msg.response.RunCommand, "error", msg.err)
+		m.verdictErr = msg.err
+	} else {
+		m.log().Debug("Command validated", "command", msg.response.RunCommand, "verdict", msg.verdict.Verdict)
+		m.verdict = msg.verdict
+	}
+	return m.presentCommand(msg.response)
+}
+
+// reviewConversation returns the latest messages of the chat as plain text, without the
+// reasoning of the model.
+func (m Model) reviewConversation() string {
+	var lines []string
+	for i := len(m.messages) - 1; i >= 0 && len(lines) < reviewMessages; i-- {
+		msg := m.messages[i]
+		if msg.Thinking {
+			continue
+		}
+		content := strings.TrimSpace(ansi.Strip(msg.Content))
+		if len(content) > reviewMessageLength {
+			content = content[:reviewMessageLength] + "..."
+		}
+		sender := msg.Sender
+		if msg.Output {
+			sender = "Command output"
+		}
+		lines = append(lines, sender+": "+content)
+	}
+
+	// oldest first
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderVerdict renders the validator's verdict on the pending command, empty when the
+// session has no validator.
+func (m Model) renderVerdict() string {
+	switch {
+	case m.verdictErr != nil:
+		return m.errorStyle.Render(fmt.Sprintf("The validator could not review the command: %v", m.verdictErr))
+	case m.verdict.Verdict == "":
+		return ""
+	case m.verdict.Verdict == agent.VerdictSafe:
+		return fmt.Sprintf("Validator: %s, %s", m.verdict.Verdict, m.verdict.Reason)
+	default:
+		return m.errorStyle.Render(fmt.Sprintf("Validator: %s, %s", m.verdict.Verdict, m.verdict.Reason))
+	}
+}
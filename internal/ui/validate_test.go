@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// validatorFunc adapts a function to the Validator interface.
+type validatorFunc func(context.Context, agent.CommandReview) (agent.Verdict, error)
+
+func (f validatorFunc) Validate(ctx context.Context, review agent.CommandReview) (agent.Verdict, error) {
+	return f(ctx, review)
+}
+
+func TestModel_validate(t *testing.T) {
+	response := agent.AgentResponse{RunCommand: "kubectl delete namespace prod", Reason: "Clean up"}
+
+	validated := func(t *testing.T, cfg Config, verdict agent.Verdict, err error) (Model, agent.CommandReview) {
+		mockAgent := new(MockAgent)
+		mockExecuter := new(MockExecuter)
+		mockAgent.On("LogUsage").Return("Test usage").Maybe()
+		mockExecuter.On("Validate", mock.Anything).Return(nil)
+
+		var review agent.CommandReview
+		cfg.Agent = mockAgent
+		cfg.Executer = mockExecuter
+		cfg.Validator = validatorFunc(func(_ context.Context, r agent.CommandReview) (agent.Verdict, error) {
+			review = r
+			return verdict, err
+		})
+		model := InitialModel(cfg)
+		model.updateChat(SenderUser, "why is prod down?")
+
+		newModel, cmd := model.handleAgentResponse(response)
+		model = newModel.(Model)
+		require.NotNil(t, cmd)
+		assert.Equal(t, StateAsking, model.state)
+		assert.Empty(t, model.confirmationCmd)
+
+		model, _ = updateModel(model, model.validate(response)())
+		return model, review
+	}
+
+	t.Run("annotates the suggestion with the verdict", func(t *testing.T) {
+		model, review := validated(t, Config{}, agent.Verdict{Verdict: agent.VerdictCaution, Reason: "deletes every resource of the namespace"}, nil)
+
+		assert.Equal(t, "kubectl delete namespace prod", review.Command)
+		assert.Equal(t, "Clean up", review.Reason)
+		assert.Equal(t, "You: why is prod down?", review.Conversation)
+
+		assert.Equal(t, StateWaitingForConfirmation, model.state)
+		assert.Equal(t, "kubectl delete namespace prod", model.confirmationCmd)
+		assert.Contains(t, model.messages[len(model.messages)-2].Content, "Validator: caution, deletes every resource of the namespace")
+	})
+
+	t.Run("an unsafe command is not auto-approved", func(t *testing.T) {
+		model, _ := validated(t, Config{AutoApprove: true, MaxAutoApproved: 10}, agent.Verdict{Verdict: agent.VerdictUnsafe, Reason: "deletes production"}, nil)
+
+		assert.Equal(t, StateWaitingForConfirmation, model.state)
+		assert.Equal(t, 0, model.autoApproved)
+		assert.Contains(t, model.messages[len(model.messages)-2].Content, "The validator flagged the command as unsafe, confirmation is required.")
+	})
+
+	t.Run("a failed review does not block the suggestion", func(t *testing.T) {
+		model, _ := validated(t, Config{}, agent.Verdict{}, errors.New("rate limit exceeded"))
+
+		assert.Equal(t, StateWaitingForConfirmation, model.state)
+		assert.Contains(t, model.messages[len(model.messages)-2].Content, "The validator could not review the command: rate limit exceeded")
+	})
+}
@@ -0,0 +1,279 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/eliran89c/klama/internal/executer"
+)
+
+const (
+	watchCommand = "/watch"
+
+	// DefaultWatchInterval is the time between the runs of a watched command.
+	DefaultWatchInterval = 5 * time.Second
+
+	// minWatchInterval bounds how often a watched command runs.
+	minWatchInterval = time.Second
+)
+
+var (
+	// watchTimeColumns are the columns of kubectl tables that change as time passes.
+	watchTimeColumns = []string{"AGE", "LAST SEEN"}
+
+	// watchColumnPattern matches the names of the columns of a table header.
+	watchColumnPattern = regexp.MustCompile(`\S+(?: \S+)*`)
+
+	// watchAgoPattern matches the times of the last restart, as in "3 (2m ago)".
+	watchAgoPattern = regexp.MustCompile(`\s*\(\S+ ago\)`)
+)
+
+// watch is a read-only command that runs again at an interval.
+type watch struct {
+	id       int // tells the runs of the current watch from the ones of a stopped watch
+	command  string
+	interval time.Duration
+	notify   bool   // tells the agent when the output changes
+	state    string // output of the last run, without the columns that change with time
+	runs     int
+}
+
+// watchTickMsg runs the watched command again.
+type watchTickMsg struct{ id int }
+
+// watchResultMsg carries the output of a run of the watched command.
+type watchResultMsg struct {
+	id       int
+	response executer.ExecuterResponse
+}
+
+// handleWatch starts watching the command of a `/watch [--notify] [<interval>] <command>`
+// message, or stops the running watch with `/watch` alone.
+func (m Model) handleWatch(query string) (tea.Model, tea.Cmd) {
+	usage := fmt.Errorf("usage: %s [--notify] [<interval>] <command>, or %s alone to stop watching", watchCommand, watchCommand)
+	rest := strings.TrimSpace(strings.TrimPrefix(query, watchCommand))
+	if rest == "" {
+		m.textarea.Reset()
+		if m.watch == nil {
+			m.err = usage
+			return m, nil
+		}
+		m.err = nil
+		m.updateChat(SenderSystem, fmt.Sprintf("Stopped watching `%v`.", m.systemStyle.Render(m.watch.command)))
+		m.watch = nil
+		return m, nil
+	}
+
+	w := watch{id: m.watchSeq + 1, interval: m.watchInterval()}
+	if after, ok := strings.CutPrefix(rest, "--notify "); ok {
+		w.notify, rest = true, strings.TrimSpace(after)
+	}
+	if first, after, ok := strings.Cut(rest, " "); ok {
+		if interval, err := time.ParseDuration(first); err == nil {
+			if interval < minWatchInterval {
+				m.err = fmt.Errorf("the watch interval must be at least %s", minWatchInterval)
+				return m, nil
+			}
+			w.interval, rest = interval, strings.TrimSpace(after)
+		}
+	}
+	w.command = rest
+	if strings.HasPrefix(w.command, "-") {
+		m.err = usage
+		return m, nil
+	}
+
+	if err := m.validateWatch(w.command); err != nil {
+		m.log().Info("Command cannot be watched", "command", w.command, "error", err)
+		m.err = fmt.Errorf("cannot watch the command: %w", err)
+		return m, nil
+	}
+
+	m.err = nil
+	m.textarea.Reset()
+	m.watchSeq = w.id
+	m.watch = &w
+	text := fmt.Sprintf("Watching `%v` every %s", m.systemStyle.Render(w.command), w.interval)
+	if w.notify {
+		text += ", Klama is told when its output changes"
+	}
+	m.updateChat(SenderSystem, text+". Type "+watchCommand+" to stop.")
+	return m, m.runWatch(w)
+}
+
+// validateWatch checks that the command is valid, read-only and allowed by the policy.
+func (m Model) validateWatch(command string) error {
+	if m.config.DryRun {
+		return fmt.Errorf("commands are not executed in dry run mode")
+	}
+	if err := m.executer.Validate(command); err != nil {
+		return err
+	}
+	if _, ok := mutationOf(m.executer, command); ok {
+		return fmt.Errorf("it changes a resource, only read-only commands can be watched")
+	}
+	if _, ok := interactiveOf(m.executer, command); ok {
+		return fmt.Errorf("it takes over the terminal")
+	}
+	if m.config.Policy != nil {
+		decision, err := m.config.Policy.Evaluate(command)
+		switch {
+		case err != nil:
+			return fmt.Errorf("the policy could not be evaluated: %w", err)
+		case decision.Denied():
+			return fmt.Errorf("it was %v", decision)
+		}
+	}
+	return nil
+}
+
+// watchInterval returns the time between the runs of a watched command.
+func (m Model) watchInterval() time.Duration {
+	if m.config.WatchInterval > 0 {
+		return m.config.WatchInterval
+	}
+	return DefaultWatchInterval
+}
+
+// runWatch runs the watched command, skipping the cache so every change shows.
+func (m Model) runWatch(w watch) tea.Cmd {
+	exec := m.executer
+	ctx := executer.WithoutCache(m.ctx)
+	timeout := m.execTimeout()
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return watchResultMsg{id: w.id, response: exec.Run(ctx, w.command)}
+	}
+}
+
+// handleWatchTick runs the watched command again, unless the watch was stopped.
+func (m Model) handleWatchTick(msg watchTickMsg) (tea.Model, tea.Cmd) {
+	if m.watch == nil || m.watch.id != msg.id {
+		return m, nil
+	}
+	return m, m.runWatch(*m.watch)
+}
+
+// handleWatchResult shows the first output of the watched command, and then only how
+// it changed, and schedules the next run. With notify, the agent is told about changes.
+func (m Model) handleWatchResult(msg watchResultMsg) (tea.Model, tea.Cmd) {
+	if m.watch == nil || m.watch.id != msg.id {
+		return m, nil
+	}
+	w := *m.watch
+	next := tea.Tick(w.interval, func(time.Time) tea.Msg { return watchTickMsg{id: w.id} })
+
+	output := msg.response.Stdout
+	if msg.response.Error != nil {
+		output = "Error: " + msg.response.Error.Error()
+		if msg.response.Stderr != "" {
+			output += "\n" + msg.response.Stderr
+		}
+	}
+	output, _ = m.config.Redactor.Redact(output)
+	state := watchState(output)
+
+	w.runs++
+	previous := w.state
+	w.state = state
+	m.watch = &w
+
+	if w.runs == 1 {
+		m.addCommandOutput(fmt.Sprintf("%s:\n%s", w.command, output), msg.response.Duration)
+		return m, next
+	}
+	if state == previous {
+		return m, next
+	}
+
+	m.log().Debug("Watched command changed", "command", w.command, "runs", w.runs)
+	diff := watchDiff(previous, state)
+	m.addCommandOutput(diff, msg.response.Duration)
+	m.updateChat(SenderSystem, fmt.Sprintf("The output of `%v` changed at %s.", m.systemStyle.Render(w.command), time.Now().Format(time.TimeOnly)))
+	if !w.notify {
+		return m, next
+	}
+
+	note := fmt.Sprintf("The output of the watched command `%s` changed:\n%s\n", w.command, diff)
+	if m.state != StateTyping {
+		// the agent is told with the next message
+		m.watchNote += note
+		return m, next
+	}
+	m.state = StateAsking
+	return m, tea.Batch(
+		next,
+		m.waitForAgentResponse(note+"Tell the user what changed and whether it matters for the investigation."),
+		m.think(),
+	)
+}
+
+// watchState returns the output without what changes as time passes, such as the AGE
+// column of kubectl tables, so only changes of the state are reported.
+func watchState(output string) string {
+	lines := strings.Split(output, "\n")
+
+	// the columns of kubectl tables are aligned with the names of the header
+	header := lines[0]
+	spans := watchColumnPattern.FindAllStringIndex(header, -1)
+	for i := len(spans) - 1; i >= 0; i-- {
+		name := header[spans[i][0]:spans[i][1]]
+		if !slices.Contains(watchTimeColumns, name) {
+			continue
+		}
+		start, end := spans[i][0], -1
+		if i+1 < len(spans) {
+			end = spans[i+1][0]
+		}
+		for j, line := range lines {
+			lines[j] = cutColumn(line, start, end)
+		}
+	}
+
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(watchAgoPattern.ReplaceAllString(line, ""), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cutColumn removes the bytes of the line from start to end, or to the end of the line
+// when end is negative.
+func cutColumn(line string, start, end int) string {
+	if start >= len(line) {
+		return line
+	}
+	if end < 0 || end > len(line) {
+		return line[:start]
+	}
+	return line[:start] + line[end:]
+}
+
+// watchDiff returns a unified diff of two outputs of the watched command.
+func watchDiff(previous, current string) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(previous + "\n"),
+		B:        difflib.SplitLines(current + "\n"),
+		FromFile: "before",
+		ToFile:   "now",
+	})
+	if err != nil {
+		return current
+	}
+	return strings.TrimSpace(diff)
+}
+
+// renderWatch describes the running watch in the status bar.
+func (m Model) renderWatch() string {
+	if m.watch == nil {
+		return ""
+	}
+	return fmt.Sprintf("watching every %s", m.watch.interval)
+}
@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eliran89c/klama/internal/agent"
+	"github.com/eliran89c/klama/internal/executer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_handleWatch(t *testing.T) {
+	const command = "kubectl get pods -n shop"
+	mockAgent := new(MockAgent)
+	mockExecuter := new(MockExecuter)
+	mockAgent.On("LogUsage").Return("Test usage").Maybe()
+	mockExecuter.On("Validate", command).Return(nil)
+
+	model := InitialModel(Config{Agent: mockAgent, Executer: mockExecuter})
+	model.textarea.SetValue("/watch --notify 10s " + command)
+	newModel, cmd := model.handleEnterKey()
+	model = newModel.(Model)
+	require.NoError(t, model.err)
+	require.NotNil(t, model.watch)
+	assert.Equal(t, command, model.watch.command)
+	assert.Equal(t, 10*time.Second, model.watch.interval)
+	assert.True(t, model.watch.notify)
+	assert.Contains(t, model.renderStatusBar(), "watching every 10s")
+
+	outputs := []string{
+		"NAME    READY   STATUS    RESTARTS   AGE\nweb-0   0/1     Running   0          5s",
+		"NAME    READY   STATUS    RESTARTS   AGE\nweb-0   0/1     Running   0          10s",
+		"NAME    READY   STATUS             RESTARTS     AGE\nweb-0   0/1     CrashLoopBackOff   1 (1s ago)   15s",
+	}
+	for _, output := range outputs {
+		mockExecuter.On("Run", mock.Anything, command).Return(executer.ExecuterResponse{Stdout: output}).Once()
+	}
+	msg := cmd()
+	model, cmd = updateModel(model, msg)
+	assert.NotNil(t, cmd)
+	assert.Equal(t, command+":\n"+outputs[0], model.Transcript()[1].Content)
+
+	// only the age changed
+	model, _ = updateModel(model, watchTickMsg{id: model.watch.id})
+	model, _ = updateModel(model, model.runWatch(*model.watch)())
+	assert.Len(t, model.Transcript(), 2)
+
+	// the agent is told when the state changes
+	mockAgent.On("Iterate", mock.Anything, mock.MatchedBy(func(message string) bool {
+		return assert.Contains(t, message, "-web-0   0/1     Running   0") &&
+			assert.Contains(t, message, "+web-0   0/1     CrashLoopBackOff   1")
+	})).Return(agent.AgentResponse{Answer: "web-0 is crashing"}, nil)
+	model, cmd = updateModel(model, model.runWatch(*model.watch)())
+	assert.Equal(t, StateAsking, model.state)
+	transcript := model.Transcript()
+	assert.Contains(t, transcript[2].Content, "--- before")
+	assert.Contains(t, transcript[3].Content, "changed at")
+	cmd().(tea.BatchMsg)[1]()
+	mockAgent.AssertExpectations(t)
+
+	// results of a stopped watch are dropped
+	stale := model.watch.id
+	model.state = StateTyping
+	model.textarea.SetValue("/watch")
+	newModel, _ = model.handleEnterKey()
+	model = newModel.(Model)
+	assert.Nil(t, model.watch)
+	assert.Contains(t, model.Transcript()[4].Content, "Stopped watching")
+	_, cmd = updateModel(model, watchTickMsg{id: stale})
+	assert.Nil(t, cmd)
+}
+
+func TestModel_handleWatch_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{"Nothing to stop", "/watch", "usage: /watch"},
+		{"Short interval", "/watch 100ms kubectl get pods", "at least 1s"},
+		{"Invalid command", "/watch kubectl delete pod web-0", "cannot watch the command: not allowed"},
+		{"Mutation", "/watch kubectl scale deploy/web --replicas=3", "only read-only commands"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecuter := new(mockMutationExecuter)
+			mockExecuter.On("Validate", "kubectl delete pod web-0").Return(errors.New("not allowed"))
+			mockExecuter.On("Validate", mock.Anything).Return(nil)
+
+			model := InitialModel(Config{Agent: new(MockAgent), Executer: mockExecuter})
+			model.textarea.SetValue(tt.query)
+			newModel, cmd := model.handleEnterKey()
+			model = newModel.(Model)
+			assert.Nil(t, cmd)
+			assert.Nil(t, model.watch)
+			require.Error(t, model.err)
+			assert.Contains(t, model.err.Error(), tt.err)
+		})
+	}
+}
+
+func TestWatchState(t *testing.T) {
+	events := "LAST SEEN   TYPE      REASON    OBJECT      MESSAGE\n" +
+		"2m          Warning   BackOff   pod/web-0   Back-off restarting failed container"
+	assert.Equal(t, "TYPE      REASON    OBJECT      MESSAGE\nWarning   BackOff   pod/web-0   Back-off restarting failed container", watchState(events))
+	assert.Equal(t, "plain output", watchState("plain output"))
+}
@@ -0,0 +1,178 @@
+// Package usage records the tokens and spend of every session in a ledger, so spend can
+// be reported even after sessions are deleted or resumed.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/eliran89c/klama/config"
+	"github.com/eliran89c/klama/internal/llm"
+)
+
+// Record is the usage of a single run of a session. A resumed session adds a new record
+// with the usage of the resumed run only.
+type Record struct {
+	SessionID string    `json:"session_id"`
+	Time      time.Time `json:"time"`
+	Agent     string    `json:"agent"`
+	Model     string    `json:"model"`
+	Usage     llm.Usage `json:"usage"`
+	Cost      float64   `json:"cost"`
+}
+
+// Ledger appends usage records to a JSON lines file.
+type Ledger struct {
+	Path string
+}
+
+// DefaultLedger returns the ledger located at $XDG_STATE_HOME/klama/usage.jsonl.
+func DefaultLedger() (*Ledger, error) {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Ledger{Path: filepath.Join(stateDir, "usage.jsonl")}, nil
+}
+
+// Append adds a record to the ledger.
+func (l *Ledger) Append(record Record) error {
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage record: %w", err)
+	}
+
+	file, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage record: %w", err)
+	}
+	return nil
+}
+
+// Records returns the records created between since and until, oldest first. Zero times
+// match everything, and lines that cannot be decoded are skipped.
+func (l *Ledger) Records(since, until time.Time) ([]Record, error) {
+	file, err := os.Open(l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if !since.IsZero() && record.Time.Before(since) {
+			continue
+		}
+		if !until.IsZero() && record.Time.After(until) {
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+
+	slices.SortStableFunc(records, func(a, b Record) int {
+		return a.Time.Compare(b.Time)
+	})
+	return records, nil
+}
+
+// Report groupings
+const (
+	GroupDay   = "day"
+	GroupAgent = "agent"
+	GroupModel = "model"
+)
+
+// Row is the usage of a group of records. Fields that are not grouped by are empty.
+type Row struct {
+	Day      string    `json:"day,omitempty"`
+	Agent    string    `json:"agent,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Sessions int       `json:"sessions"`
+	Usage    llm.Usage `json:"usage"`
+	Cost     float64   `json:"cost"`
+}
+
+// ParseGroups parses a comma separated list of groupings.
+func ParseGroups(value string) ([]string, error) {
+	var groups []string
+	for _, group := range strings.Split(value, ",") {
+		group = strings.TrimSpace(group)
+		switch group {
+		case GroupDay, GroupAgent, GroupModel:
+			if !slices.Contains(groups, group) {
+				groups = append(groups, group)
+			}
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown grouping %q, use %s, %s or %s", group, GroupDay, GroupAgent, GroupModel)
+		}
+	}
+	return groups, nil
+}
+
+// Summarize adds up the records by the given groupings, without groupings it returns a
+// single total row. Rows keep the order in which their group first appears, days use the
+// local time zone.
+func Summarize(records []Record, groups []string) []Row {
+	var rows []Row
+	index := make(map[Row]int)
+	sessions := make(map[Row]map[string]bool)
+
+	for _, record := range records {
+		var key Row
+		for _, group := range groups {
+			switch group {
+			case GroupDay:
+				key.Day = record.Time.Local().Format("2006-01-02")
+			case GroupAgent:
+				key.Agent = record.Agent
+			case GroupModel:
+				key.Model = record.Model
+			}
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(rows)
+			index[key] = i
+			sessions[key] = make(map[string]bool)
+			rows = append(rows, key)
+		}
+
+		rows[i].Usage = rows[i].Usage.Add(record.Usage)
+		rows[i].Cost += record.Cost
+		if !sessions[key][record.SessionID] {
+			sessions[key][record.SessionID] = true
+			rows[i].Sessions++
+		}
+	}
+
+	return rows
+}
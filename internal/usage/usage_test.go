@@ -0,0 +1,84 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eliran89c/klama/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedger(t *testing.T) {
+	ledger := &Ledger{Path: filepath.Join(t.TempDir(), "klama", "usage.jsonl")}
+
+	records, err := ledger.Records(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	day1 := time.Date(2024, 7, 1, 10, 0, 0, 0, time.Local)
+	day2 := day1.Add(24 * time.Hour)
+	require.NoError(t, ledger.Append(Record{SessionID: "b", Time: day2, Agent: "aws", Model: "gpt-4o", Cost: 0.2}))
+	require.NoError(t, ledger.Append(Record{SessionID: "a", Time: day1, Agent: "k8s", Model: "gpt-4o", Cost: 0.1}))
+
+	// undecodable lines are skipped
+	file, err := os.OpenFile(ledger.Path, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	file.WriteString("not json\n")
+	file.Close()
+
+	records, err = ledger.Records(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "a", records[0].SessionID)
+	assert.Equal(t, "b", records[1].SessionID)
+
+	records, err = ledger.Records(day1.Add(time.Hour), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "b", records[0].SessionID)
+}
+
+func TestSummarize(t *testing.T) {
+	day1 := time.Date(2024, 7, 1, 10, 0, 0, 0, time.Local)
+	day2 := day1.Add(24 * time.Hour)
+	tokens := llm.Usage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110}
+
+	records := []Record{
+		{SessionID: "a", Time: day1, Agent: "k8s", Model: "gpt-4o", Usage: tokens, Cost: 0.1},
+		{SessionID: "b", Time: day1, Agent: "aws", Model: "gpt-4o-mini", Usage: tokens, Cost: 0.01},
+		{SessionID: "a", Time: day2, Agent: "k8s", Model: "gpt-4o", Usage: tokens, Cost: 0.1}, // resumed
+	}
+
+	rows := Summarize(records, []string{GroupDay, GroupAgent, GroupModel})
+	require.Len(t, rows, 3)
+	assert.Equal(t, Row{Day: "2024-07-01", Agent: "k8s", Model: "gpt-4o", Sessions: 1, Usage: tokens, Cost: 0.1}, rows[0])
+
+	rows = Summarize(records, []string{GroupAgent})
+	require.Len(t, rows, 2)
+	assert.Equal(t, "k8s", rows[0].Agent)
+	assert.Empty(t, rows[0].Day)
+	assert.Equal(t, 1, rows[0].Sessions)
+	assert.Equal(t, 220, rows[0].Usage.TotalTokens)
+	assert.InDelta(t, 0.2, rows[0].Cost, 1e-9)
+
+	total := Summarize(records, nil)
+	require.Len(t, total, 1)
+	assert.Equal(t, 2, total[0].Sessions)
+	assert.Equal(t, 330, total[0].Usage.TotalTokens)
+}
+
+func TestParseGroups(t *testing.T) {
+	groups, err := ParseGroups("model, day,model")
+	require.NoError(t, err)
+	assert.Equal(t, []string{GroupModel, GroupDay}, groups)
+
+	groups, err = ParseGroups("")
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+
+	_, err = ParseGroups("week")
+	assert.Error(t, err)
+}